@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/api/middleware"
+	"github.com/gosuda/x402-facilitator/attestation"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+)
+
+// WithResponseSigning signs the responses of /verify and /settle with the key
+// in the X-Facilitator-Signature header, see package attestation. Errors
+// rendered by the error handler aren't signed.
+func WithResponseSigning(key attestation.Key) Option {
+	return func(s *Server) {
+		s.responseKey = key
+	}
+}
+
+// attest signs the response the handler wrote, over the request body as the
+// client sent it. It holds the response back until it is signed.
+func (s *Server) attest(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.responseKey == nil {
+			return next(c)
+		}
+		req := c.Request()
+		body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxRequestBodySize))
+		if err != nil {
+			return decodeError(err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		res := c.Response()
+		held := &heldResponse{ResponseWriter: res.Writer}
+		res.Writer = held
+		err = next(c)
+		res.Writer = held.ResponseWriter
+		if held.status == 0 {
+			return err
+		}
+
+		header, signErr := attestation.Sign(s.responseKey, middleware.RoutePath(c), body, held.body.Bytes(), s.clock.Now())
+		if signErr != nil {
+			// sent unsigned, clients checking signatures reject it
+			logging.Ctx(req.Context(), logging.HTTP).Error().Err(signErr).Msg("Failed to sign response")
+		} else {
+			res.Header().Set(attestation.HeaderSignature, header)
+		}
+		res.Writer.WriteHeader(held.status)
+		if _, writeErr := res.Writer.Write(held.body.Bytes()); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		return err
+	}
+}
+
+// heldResponse buffers a response until it is signed.
+type heldResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *heldResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *heldResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
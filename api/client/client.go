@@ -8,7 +8,12 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/attestation"
+	"github.com/gosuda/x402-facilitator/internal/hmacauth"
 	"github.com/gosuda/x402-facilitator/types"
 )
 
@@ -16,6 +21,42 @@ type Client struct {
 	BaseURL          *url.URL
 	HTTPClient       *http.Client
 	CreateAuthHeader func() (map[string]map[string]string, error)
+	// HMAC signs every request with a shared secret if set
+	HMAC *HMACCredentials
+	// APIKey is sent in the X-API-Key header of every request if set
+	APIKey string
+	// SettleTimeout asks the server to abort settlements and estimates after this long, the server default applies if 0
+	SettleTimeout time.Duration
+	// SettlePriority asks the server to settle ahead of lower priorities, capped by the priority of the tenant
+	SettlePriority int
+	// ResponseSigner is the address the facilitator signs verify and settle responses with. If set, responses
+	// without its valid signature are rejected, see package attestation
+	ResponseSigner string
+}
+
+// signedPaths are the paths whose responses the facilitator signs
+var signedPaths = map[string]bool{"/verify": true, "/settle": true}
+
+// referenceKey is the context key of the reference of verify and settle requests
+type referenceKey struct{}
+
+// WithReference returns a copy of ctx whose verify and settle requests carry
+// the reference, e.g. an order ID. The facilitator returns it in the response
+// and keeps it with the settlement, its webhook events and receipt.
+func WithReference(ctx context.Context, reference string) context.Context {
+	return context.WithValue(ctx, referenceKey{}, reference)
+}
+
+// referenceFrom returns the reference of ctx, empty if it has none.
+func referenceFrom(ctx context.Context) string {
+	reference, _ := ctx.Value(referenceKey{}).(string)
+	return reference
+}
+
+// HMACCredentials identify a shared secret of the facilitator.
+type HMACCredentials struct {
+	KeyID  string
+	Secret string
 }
 
 func NewClient(baseURL string) (*Client, error) {
@@ -29,13 +70,23 @@ func NewClient(baseURL string) (*Client, error) {
 	}, nil
 }
 
-// Supported fetches the list of supported schemes.
-func (c *Client) Supported(ctx context.Context) ([]types.SupportedKind, error) {
-	var result []types.SupportedKind
+// Supported fetches the supported payment kinds and signer addresses.
+func (c *Client) Supported(ctx context.Context) (*types.SupportedResponse, error) {
+	var result types.SupportedResponse
 	if err := c.doRequest(ctx, http.MethodGet, "/supported", nil, "", &result); err != nil {
 		return nil, err
 	}
-	return result, nil
+	return &result, nil
+}
+
+// WellKnown fetches the description of the facilitator, including the signer
+// addresses of every network.
+func (c *Client) WellKnown(ctx context.Context) (*types.WellKnownResponse, error) {
+	var result types.WellKnownResponse
+	if err := c.doRequest(ctx, http.MethodGet, "/.well-known/x402", nil, "", &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
 }
 
 func (c *Client) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
@@ -43,6 +94,7 @@ func (c *Client) Verify(ctx context.Context, payload *types.PaymentPayload, req
 		X402Version:         int(types.X402VersionV1),
 		PaymentHeader:       *payload,
 		PaymentRequirements: *req,
+		Reference:           referenceFrom(ctx),
 	}
 
 	var resp types.PaymentVerifyResponse
@@ -58,6 +110,9 @@ func (c *Client) Settle(ctx context.Context, payload *types.PaymentPayload, req
 		X402Version:         int(types.X402VersionV1),
 		PaymentHeader:       *payload,
 		PaymentRequirements: *req,
+		TimeoutMs:           c.SettleTimeout.Milliseconds(),
+		Priority:            c.SettlePriority,
+		Reference:           referenceFrom(ctx),
 	}
 
 	var resp types.PaymentSettleResponse
@@ -67,28 +122,179 @@ func (c *Client) Settle(ctx context.Context, payload *types.PaymentPayload, req
 	return &resp, nil
 }
 
+// ScheduleSettle requests the settlement of a payment within the window
+// between notBefore and notAfter, either of which may be zero. The facilitator
+// settles it in the background and publishes the outcome as settlement events.
+// Payments the facilitator rejects return an error carrying the reason.
+func (c *Client) ScheduleSettle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements, notBefore, notAfter time.Time) (*types.ScheduledSettlementResponse, error) {
+	body := types.PaymentSettleRequest{
+		X402Version:         int(types.X402VersionV1),
+		PaymentHeader:       *payload,
+		PaymentRequirements: *req,
+		Priority:            c.SettlePriority,
+		Reference:           referenceFrom(ctx),
+	}
+	if !notBefore.IsZero() {
+		body.NotBefore = notBefore.Unix()
+	}
+	if !notAfter.IsZero() {
+		body.NotAfter = notAfter.Unix()
+	}
+
+	// rejected payments are answered with a failed settle response instead
+	var resp struct {
+		types.ScheduledSettlementResponse
+		Error string `json:"error"`
+	}
+	if err := c.doRequest(ctx, http.MethodPost, "/settle", body, "settle", &resp); err != nil {
+		return nil, err
+	}
+	if resp.SettlementID == "" {
+		return nil, fmt.Errorf("payment rejected: %s", resp.Error)
+	}
+	return &resp.ScheduledSettlementResponse, nil
+}
+
+// Estimate simulates a payment settlement without broadcasting it.
+func (c *Client) Estimate(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentEstimateResponse, error) {
+	body := types.PaymentSettleRequest{
+		X402Version:         int(types.X402VersionV1),
+		PaymentHeader:       *payload,
+		PaymentRequirements: *req,
+		TimeoutMs:           c.SettleTimeout.Milliseconds(),
+	}
+
+	var resp types.PaymentEstimateResponse
+	if err := c.doRequest(ctx, http.MethodPost, "/settle/estimate", body, "settle", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RegisterRequirements registers the payment requirements, so payments can
+// name them by the returned ID with VerifyRegistered and SettleRegistered.
+func (c *Client) RegisterRequirements(ctx context.Context, req *types.PaymentRequirements) (string, error) {
+	var resp types.RegisteredRequirements
+	if err := c.doRequest(ctx, http.MethodPost, "/requirements", req, "verify", &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// VerifyRegistered verifies a payment against the requirements registered under the ID.
+func (c *Client) VerifyRegistered(ctx context.Context, payload *types.PaymentPayload, requirementsID string) (*types.PaymentVerifyResponse, error) {
+	body := types.PaymentVerifyRequest{
+		X402Version:    int(types.X402VersionV1),
+		PaymentHeader:  *payload,
+		RequirementsID: requirementsID,
+		Reference:      referenceFrom(ctx),
+	}
+
+	var resp types.PaymentVerifyResponse
+	if err := c.doRequest(ctx, http.MethodPost, "/verify", body, "verify", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SettleRegistered settles a payment against the requirements registered under the ID.
+func (c *Client) SettleRegistered(ctx context.Context, payload *types.PaymentPayload, requirementsID string) (*types.PaymentSettleResponse, error) {
+	body := types.PaymentSettleRequest{
+		X402Version:    int(types.X402VersionV1),
+		PaymentHeader:  *payload,
+		RequirementsID: requirementsID,
+		TimeoutMs:      c.SettleTimeout.Milliseconds(),
+		Priority:       c.SettlePriority,
+		Reference:      referenceFrom(ctx),
+	}
+
+	var resp types.PaymentSettleResponse
+	if err := c.doRequest(ctx, http.MethodPost, "/settle", body, "settle", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Receipt fetches the signed receipt of the settlement transaction.
+func (c *Client) Receipt(ctx context.Context, txHash string) (*types.SettlementReceipt, error) {
+	var receipt types.SettlementReceipt
+	if err := c.doRequest(ctx, http.MethodGet, "/receipts/"+url.PathEscape(txHash), nil, "settle", &receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// Costs fetches the report of the fees paid for settlements created in [from, to).
+// Zero times select the server defaults.
+func (c *Client) Costs(ctx context.Context, from, to time.Time) (*types.CostReport, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	var report types.CostReport
+	if err := c.doRequest(ctx, http.MethodGet, "/admin/costs?"+query.Encode(), nil, "", &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Stats fetches the statistics of the settlements created in [from, to) by
+// bucket of the granularity: "minute", "hour" or "day". Zero values select the
+// server defaults.
+func (c *Client) Stats(ctx context.Context, granularity string, from, to time.Time) (*types.SettlementStats, error) {
+	query := url.Values{}
+	if granularity != "" {
+		query.Set("granularity", granularity)
+	}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+
+	var stats types.SettlementStats
+	if err := c.doRequest(ctx, http.MethodGet, "/admin/stats?"+query.Encode(), nil, "", &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
 func (c *Client) doRequest(ctx context.Context, method, path string, body any, authKey string, out any) error {
 	// Build URL
-	u := c.BaseURL.ResolveReference(&url.URL{Path: path})
+	ref, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	u := c.BaseURL.ResolveReference(ref)
 
 	// Prepare body
-	var reader io.Reader
+	var payload []byte
 	if body != nil {
-		payload, err := json.Marshal(body)
+		payload, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("marshal request body: %w", err)
 		}
-		reader = bytes.NewReader(payload)
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if c.HMAC != nil {
+		hmacauth.Sign(req, payload, c.HMAC.KeyID, []byte(c.HMAC.Secret))
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
 
 	if authKey != "" && c.CreateAuthHeader != nil {
 		hdrs, err := c.CreateAuthHeader()
@@ -109,15 +315,32 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body any, a
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		data, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("%s %s failed: status %d, body: %s", method, path, resp.StatusCode, string(data))
 	}
 
-	if out != nil {
+	if out == nil {
+		return nil
+	}
+	if c.ResponseSigner == "" || !signedPaths[path] {
 		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
 			return fmt.Errorf("decode %s response: %w", path, err)
 		}
+		return nil
+	}
+	if !common.IsHexAddress(c.ResponseSigner) {
+		return fmt.Errorf("invalid response signer %q", c.ResponseSigner)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read %s response: %w", path, err)
+	}
+	if err := attestation.Verify(common.HexToAddress(c.ResponseSigner), path, payload, data, resp.Header.Get(attestation.HeaderSignature)); err != nil {
+		return fmt.Errorf("%s response: %w", path, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
 	}
 	return nil
 }
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types of the binary encodings the payment endpoints speak besides JSON
+const (
+	MIMEApplicationCBOR    = "application/cbor"
+	MIMEApplicationMsgpack = "application/msgpack"
+)
+
+// codecKey is the context key of the codec a response is encoded with, unset for JSON
+const codecKey = "codec"
+
+// codec encodes the JSON data model in a binary format. Payloads are converted
+// to and from JSON at the edge, so validation, error messages and the custom
+// JSON encodings of the types apply unchanged.
+type codec struct {
+	mediaType string
+	marshal   func(any) ([]byte, error)
+	unmarshal func([]byte) (any, error)
+}
+
+var (
+	cborEncoder, _ = cbor.EncOptions{Sort: cbor.SortCoreDeterministic}.EncMode()
+	cborDecoder, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any(nil))}.DecMode()
+
+	cborCodec = &codec{
+		mediaType: MIMEApplicationCBOR,
+		marshal:   cborEncoder.Marshal,
+		unmarshal: func(data []byte) (v any, err error) { return v, cborDecoder.Unmarshal(data, &v) },
+	}
+	msgpackCodec = &codec{
+		mediaType: MIMEApplicationMsgpack,
+		marshal: func(v any) ([]byte, error) {
+			var buf bytes.Buffer
+			enc := msgpack.NewEncoder(&buf)
+			enc.SetSortMapKeys(true)
+			err := enc.Encode(v)
+			return buf.Bytes(), err
+		},
+		unmarshal: func(data []byte) (v any, err error) { return v, msgpack.Unmarshal(data, &v) },
+	}
+
+	// codecs by media type, including the unregistered names of MessagePack
+	codecs = map[string]*codec{
+		MIMEApplicationCBOR:       cborCodec,
+		MIMEApplicationMsgpack:    msgpackCodec,
+		"application/x-msgpack":   msgpackCodec,
+		"application/vnd.msgpack": msgpackCodec,
+	}
+)
+
+// negotiate lets clients of the route send CBOR or MessagePack instead of
+// JSON, by Content-Type, and receive it, by Accept. Responses are encoded like
+// the request unless Accept asks for another supported type.
+func (s *Server) negotiate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		requestCodec := codecOf(req.Header.Get(echo.HeaderContentType))
+		if responseCodec, ok := acceptedCodec(req.Header.Get(echo.HeaderAccept)); ok {
+			if responseCodec != nil {
+				c.Set(codecKey, responseCodec)
+			}
+		} else if requestCodec != nil {
+			c.Set(codecKey, requestCodec)
+		}
+		if requestCodec == nil {
+			return next(c)
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxRequestBodySize))
+		if err != nil {
+			return decodeError(err)
+		}
+		value, err := requestCodec.unmarshal(body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Received malformed request body")
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			// e.g. maps with keys other than strings
+			return echo.NewHTTPError(http.StatusBadRequest, "Request body can't be represented as JSON")
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		return next(c)
+	}
+}
+
+// codecOf returns the codec of the media type, nil for JSON and unknown types.
+func codecOf(contentType string) *codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	return codecs[mediaType]
+}
+
+// acceptedCodec returns the codec of the first supported media type of the
+// Accept header, nil for JSON. It reports false if the header names none,
+// e.g. if it is empty or */*.
+func acceptedCodec(accept string) (*codec, bool) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == echo.MIMEApplicationJSON {
+			return nil, true
+		}
+		if c, ok := codecs[mediaType]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// respond writes the value as JSON or in the encoding negotiated for the request.
+func respond(c echo.Context, status int, v any) error {
+	enc, ok := c.Get(codecKey).(*codec)
+	if !ok {
+		return c.JSON(status, v)
+	}
+	data, err := enc.encode(v)
+	if err != nil {
+		return err
+	}
+	return c.Blob(status, enc.mediaType, data)
+}
+
+// encode encodes the JSON representation of v.
+func (c *codec) encode(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return c.marshal(fromJSON(value))
+}
+
+// fromJSON replaces the numbers of a decoded JSON value by integers where
+// they are whole, so they are encoded as such.
+func fromJSON(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = fromJSON(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = fromJSON(item)
+		}
+	}
+	return value
+}
+
+// handleError renders errors like echo does, in the encoding negotiated for the request.
+func (s *Server) handleError(err error, c echo.Context) {
+	enc, ok := c.Get(codecKey).(*codec)
+	if !ok || c.Response().Committed {
+		s.DefaultHTTPErrorHandler(err, c)
+		return
+	}
+	he := &echo.HTTPError{Code: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+	errors.As(err, &he)
+	message := he.Message
+	switch m := message.(type) {
+	case string:
+		message = map[string]any{"message": m}
+	case error:
+		message = map[string]any{"message": m.Error()}
+	}
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(he.Code)
+	} else if data, encErr := enc.encode(message); encErr != nil {
+		err = encErr
+	} else {
+		err = c.Blob(he.Code, enc.mediaType, data)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+)
+
+// Content codings responses can be compressed with
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// Defaults of the response compression
+const (
+	defaultGzipLevel   = gzip.DefaultCompression
+	defaultBrotliLevel = 4
+	// smaller responses gain little and cost a compressor
+	defaultMinCompressLength = 1024
+)
+
+var defaultEncodings = []string{EncodingBrotli, EncodingGzip}
+
+// CompressionConfig controls the compression of responses. The zero value
+// compresses responses of at least 1 KiB with brotli or gzip, whichever the
+// client prefers, brotli on a tie.
+type CompressionConfig struct {
+	// Sends every response uncompressed, e.g. behind a proxy that compresses
+	Disabled bool `mapstructure:"disabled"`
+	// Content codings offered, br and gzip, in order of preference. Both if empty
+	Encodings []string `mapstructure:"encodings"`
+	// gzip level from 1, fastest, to 9, smallest. 6 if 0
+	GzipLevel int `mapstructure:"gzipLevel"`
+	// brotli quality from 1, fastest, to 11, smallest. 4 if 0
+	BrotliLevel int `mapstructure:"brotliLevel"`
+	// Responses shorter than this many bytes are sent uncompressed, 1024 if 0
+	MinLength int `mapstructure:"minLength"`
+}
+
+func (c CompressionConfig) withDefaults() CompressionConfig {
+	if len(c.Encodings) == 0 {
+		c.Encodings = defaultEncodings
+	}
+	c.GzipLevel = cmp.Or(c.GzipLevel, defaultGzipLevel)
+	c.BrotliLevel = cmp.Or(c.BrotliLevel, defaultBrotliLevel)
+	c.MinLength = cmp.Or(c.MinLength, defaultMinCompressLength)
+	return c
+}
+
+// WithCompression replaces the default response compression.
+func WithCompression(config CompressionConfig) Option {
+	return func(s *Server) {
+		s.compression = config
+	}
+}
+
+// compressor compresses responses in the encoding the client accepts,
+// reusing the writers of every encoding.
+type compressor struct {
+	encodings []string
+	minLength int
+	pools     map[string]*sync.Pool
+}
+
+// encoder is a compressing writer that can be reused for another response.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func newCompressor(config CompressionConfig) *compressor {
+	config = config.withDefaults()
+	return &compressor{
+		encodings: config.Encodings,
+		minLength: config.MinLength,
+		pools: map[string]*sync.Pool{
+			EncodingBrotli: {New: func() any {
+				return brotli.NewWriterLevel(io.Discard, config.BrotliLevel)
+			}},
+			EncodingGzip: {New: func() any {
+				w, _ := gzip.NewWriterLevel(io.Discard, config.GzipLevel)
+				return w
+			}},
+		},
+	}
+}
+
+// middleware compresses the responses of clients accepting one of the
+// encodings. Websocket upgrades and HEAD requests are passed through.
+func (p *compressor) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req, res := c.Request(), c.Response()
+		res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		encoding := p.negotiate(req.Header.Get(echo.HeaderAcceptEncoding))
+		if encoding == "" || req.Method == http.MethodHead || strings.EqualFold(req.Header.Get(echo.HeaderUpgrade), "websocket") {
+			return next(c)
+		}
+
+		w := &compressWriter{ResponseWriter: res.Writer, encoding: encoding, compressor: p}
+		res.Writer = w
+		defer func() {
+			w.close()
+			res.Writer = w.ResponseWriter
+		}()
+		return next(c)
+	}
+}
+
+// negotiate returns the encoding of the Accept-Encoding header to compress
+// with, the most preferred among those of the highest quality, or "" if the
+// client accepts none.
+func (p *compressor) negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range p.encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it reaches the minimum
+// length, then compresses it. Shorter responses are written as they are.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding   string
+	compressor *compressor
+
+	status  int
+	buf     bytes.Buffer
+	encoder encoder
+	// passthrough is set once the response is written uncompressed
+	passthrough bool
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	switch {
+	case w.encoder != nil:
+		return w.encoder.Write(data)
+	case w.passthrough:
+		return w.ResponseWriter.Write(data)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.buf.Write(data)
+	if w.buf.Len() >= w.compressor.minLength {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// start writes the headers and the buffered start of the response, compressed
+// unless the handler encoded it itself.
+func (w *compressWriter) start() error {
+	header := w.Header()
+	if header.Get(echo.HeaderContentEncoding) != "" || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(cmp.Or(w.status, http.StatusOK))
+		_, err := w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+
+	header.Set(echo.HeaderContentEncoding, w.encoding)
+	header.Del(echo.HeaderContentLength)
+	w.ResponseWriter.WriteHeader(cmp.Or(w.status, http.StatusOK))
+	w.encoder = w.compressor.pools[w.encoding].Get().(encoder)
+	w.encoder.Reset(w.ResponseWriter)
+	_, err := w.encoder.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Flush compresses what was written so far and sends it, so streamed
+// responses reach the client without waiting for the minimum length.
+func (w *compressWriter) Flush() {
+	if w.encoder == nil && !w.passthrough {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.start(); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// close finishes the response, writing short responses uncompressed.
+func (w *compressWriter) close() {
+	switch {
+	case w.encoder != nil:
+		w.encoder.Close()
+		w.encoder.Reset(io.Discard)
+		w.compressor.pools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	case !w.passthrough && w.status != 0:
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultCostPeriod is the period reported if no start is given
+const defaultCostPeriod = 24 * time.Hour
+
+// Costs reports the gas fees paid for settlements
+// @Summary      Settlement cost report
+// @ID           costs
+// @Description  Sum the gas used and fees paid for the settlements created in [from, to) by network and asset (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        from  query     string  false  "Start of the period (RFC 3339), defaults to 24 hours before to"
+// @Param        to    query     string  false  "End of the period (RFC 3339), defaults to now"
+// @Success      200   {object}  types.CostReport
+// @Failure      400   {object}  echo.HTTPError
+// @Failure      403   {object}  echo.HTTPError
+// @Failure      500   {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/costs [get]
+func (s *Server) Costs(c echo.Context) error {
+	from, to, err := queryPeriod(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := s.settlements.CostReport(c.Request().Context(), from, to)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// queryPeriod returns the period [from, to) of the from and to query
+// parameters, the 24 hours before to if from is missing.
+func queryPeriod(c echo.Context) (time.Time, time.Time, error) {
+	to := time.Now()
+	if param := c.QueryParam("to"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			return time.Time{}, time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid to, expected an RFC 3339 timestamp")
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultCostPeriod)
+	if param := c.QueryParam("from"); param != "" {
+		parsed, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			return time.Time{}, time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid from, expected an RFC 3339 timestamp")
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, echo.NewHTTPError(http.StatusBadRequest, "from must be before to")
+	}
+	return from, to, nil
+}
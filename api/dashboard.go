@@ -0,0 +1,79 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// dashboardWindow is the period of settlement activity the dashboard shows
+const dashboardWindow = time.Hour
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// Dashboard serves the settlement dashboard
+// @Summary      Settlement dashboard
+// @ID           dashboard
+// @Description  Serve an HTML page showing settlement throughput, error rates, queue depth and signer balances, refreshed every few seconds (localhost or admin API key)
+// @Tags         admin
+// @Produce      html
+// @Success      200  {string}  string
+// @Failure      403  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/dashboard [get]
+func (s *Server) Dashboard(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMETextHTMLCharsetUTF8, dashboardPage)
+}
+
+// DashboardData returns the state the dashboard shows
+// @Summary      Dashboard data
+// @ID           dashboardData
+// @Description  Count the settlements of the last hour by minute and by network, and report the settlement queue, signer gas balances and paused networks (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  types.Dashboard
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/dashboard/data [get]
+func (s *Server) DashboardData(c echo.Context) error {
+	now := time.Now()
+	// the last bucket is the current minute
+	from := now.Truncate(time.Minute).Add(time.Minute - dashboardWindow)
+	activity, networks, err := s.settlements.Activity(c.Request().Context(), from, now, time.Minute)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	signers := []types.DashboardSigner{}
+	for _, balance := range metrics.SignerBalances() {
+		signers = append(signers, types.DashboardSigner{
+			Network:  balance.Network,
+			Signer:   balance.Signer,
+			Balance:  balance.Balance,
+			Currency: balance.Currency,
+		})
+	}
+	paused := []types.NetworkPause{}
+	for network, since := range s.registry.PausedNetworks() {
+		paused = append(paused, types.NetworkPause{Network: network, Paused: true, PausedAt: &since})
+	}
+	slices.SortFunc(paused, func(a, b types.NetworkPause) int { return strings.Compare(a.Network, b.Network) })
+	return c.JSON(http.StatusOK, types.Dashboard{
+		GeneratedAt: now,
+		Activity:    activity,
+		Networks:    networks,
+		QueueDepth:  int(metrics.GaugeValue(metrics.SettlementQueueDepth)),
+		InFlight:    int(metrics.GaugeValue(metrics.SettlementsInFlight)),
+		Signers:     signers,
+		Paused:      paused,
+	})
+}
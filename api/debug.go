@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// SettlementDebug returns the diagnostic trail of a settlement
+// @Summary      Debug settlement
+// @ID           settlementDebug
+// @Description  Get a settlement and the diagnostic trail it failed with: the simulation result and revert data, gas estimates, RPC errors, retries and the raw signed transaction. The trail is only kept for failed and expired settlements (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "Settlement ID"
+// @Success      200  {object}  types.SettlementDebug
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      404  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/settlements/{id}/debug [get]
+func (s *Server) SettlementDebug(c echo.Context) error {
+	record, err := s.settlements.GetSettlement(c.Request().Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Settlement not found")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	trail := record.Diagnostics
+	if trail == nil {
+		trail = []diagnostics.Entry{}
+	}
+	return c.JSON(http.StatusOK, types.SettlementDebug{
+		ID:          record.ID,
+		Scheme:      record.Scheme,
+		Network:     record.Network,
+		Payer:       record.Payer,
+		Tenant:      record.Tenant,
+		RequestID:   record.RequestID,
+		Reference:   record.Reference,
+		Asset:       record.Asset,
+		Status:      record.Status,
+		Error:       record.Error,
+		TxHash:      record.TxHash,
+		BlockNumber: record.BlockNumber,
+		Reverted:    record.Reverted,
+		GasUsed:     record.GasUsed,
+		Diagnostics: trail,
+		CreatedAt:   record.CreatedAt,
+		UpdatedAt:   record.UpdatedAt,
+	})
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/settlement"
+)
+
+// exportTypes are the content types of the export formats
+var exportTypes = map[string]string{
+	settlement.ExportCSV:     "text/csv; charset=utf-8",
+	settlement.ExportParquet: "application/vnd.apache.parquet",
+}
+
+// Export streams the settlements of a period for accounting
+// @Summary      Export settlements
+// @ID           exportSettlements
+// @Description  Stream the settlements created in [from, to) as CSV or Parquet, oldest first: status, payer, recipient, asset, amount, transaction hash, gas used, gas price and fee. Amounts are in atomic units, and in whole units if the decimals of the asset are known (localhost or admin API key)
+// @Tags         admin
+// @Produce      text/csv,application/vnd.apache.parquet
+// @Param        format  query     string  false  "csv (default) or parquet"
+// @Param        from    query     string  false  "Start of the period (RFC 3339), defaults to 24 hours before to"
+// @Param        to      query     string  false  "End of the period (RFC 3339), defaults to now"
+// @Success      200     {string}  string
+// @Failure      400     {object}  echo.HTTPError
+// @Failure      403     {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/export [get]
+func (s *Server) Export(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = settlement.ExportCSV
+	}
+	contentType, ok := exportTypes[format]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid format, expected csv or parquet")
+	}
+	from, to, err := queryPeriod(c)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, contentType)
+	resp.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="settlements-%s.%s"`, from.UTC().Format("20060102T150405Z"), format))
+	resp.WriteHeader(http.StatusOK)
+	ctx := c.Request().Context()
+	if err := s.settlements.Export(ctx, resp, format, from, to); err != nil {
+		// the status is sent already, the client gets a truncated file
+		logging.Ctx(ctx, logging.HTTP).Error().Err(err).Str("format", format).Msg("Failed to export settlements")
+	}
+	return nil
+}
@@ -0,0 +1,1234 @@
+// Code generated by internal/openapi/generate from api/openapi.yaml. DO NOT EDIT.
+
+// Package gen is a client of the x402 Facilitator API generated from its OpenAPI document.
+package gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type APIKey struct {
+	CreatedAt string `json:"createdAt,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Networks the key may pay on, all if absent
+	Networks []string `json:"networks,omitempty"`
+	// When the key was revoked, absent while it is valid
+	RevokedAt string `json:"revokedAt,omitempty"`
+	// Scopes granted to the key: verify, settle, read-status or admin
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type CostReport struct {
+	// Costs by network and asset
+	Entries []*CostReportEntry `json:"entries,omitempty"`
+	From    string             `json:"from,omitempty"`
+	To      string             `json:"to,omitempty"`
+	// Sum of the settled payments that could be priced in USD
+	TotalAmountUSD float64 `json:"totalAmountUsd,omitempty"`
+	// Sum of the fees that could be priced in USD
+	TotalFeeUSD float64 `json:"totalFeeUsd,omitempty"`
+}
+
+type CostReportEntry struct {
+	// Total value of the successful settlements in USD. Only present if every payment could be priced
+	AmountUSD float64 `json:"amountUsd,omitempty"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset string `json:"asset,omitempty"`
+	// Total fees in atomic units of the fee currency
+	Fee string `json:"fee,omitempty"`
+	// Symbol of the token fees are paid in
+	FeeCurrency string `json:"feeCurrency,omitempty"`
+	// Total fees in the fee currency, as a decimal string
+	FeeNative string `json:"feeNative,omitempty"`
+	// Total fees in USD, priced when each transaction was mined. Only present if every fee could be
+	// priced
+	FeeUSD float64 `json:"feeUsd,omitempty"`
+	// Total gas used
+	GasUsed int64  `json:"gasUsed,omitempty"`
+	Network string `json:"network,omitempty"`
+	// Number of settlement transactions that reverted
+	Reverted int64 `json:"reverted,omitempty"`
+	// Number of mined settlement transactions, including reverted ones
+	Settlements int64 `json:"settlements,omitempty"`
+}
+
+type CreatedAPIKey struct {
+	CreatedAt string `json:"createdAt,omitempty"`
+	ID        string `json:"id,omitempty"`
+	// The key, sent in the X-API-Key header. It is not stored and only returned once
+	Key  string `json:"key,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Networks the key may pay on, all if absent
+	Networks []string `json:"networks,omitempty"`
+	// When the key was revoked, absent while it is valid
+	RevokedAt string `json:"revokedAt,omitempty"`
+	// Scopes granted to the key: verify, settle, read-status or admin
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type Dashboard struct {
+	// Settlements created in the window, by minute
+	Activity    []*DashboardBucket `json:"activity,omitempty"`
+	GeneratedAt string             `json:"generatedAt,omitempty"`
+	// Settlements being submitted by a worker
+	InFlight int64 `json:"inFlight,omitempty"`
+	// Settlements created in the window by network
+	Networks []*DashboardNetwork `json:"networks,omitempty"`
+	// Networks whose settlements are paused
+	Paused []*NetworkPause `json:"paused,omitempty"`
+	// Settlements waiting for a worker
+	QueueDepth int64 `json:"queueDepth,omitempty"`
+	// Gas balances of the signers when they were last checked
+	Signers []*DashboardSigner `json:"signers,omitempty"`
+}
+
+type DashboardBucket struct {
+	Failed int64 `json:"failed,omitempty"`
+	// Settlements not submitted yet
+	Pending int64 `json:"pending,omitempty"`
+	// Settlements submitted, mined or confirmed
+	Settled int64  `json:"settled,omitempty"`
+	Start   string `json:"start,omitempty"`
+}
+
+type DashboardNetwork struct {
+	// Share of the finished settlements that failed, 0 if none finished
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	Failed    int64   `json:"failed,omitempty"`
+	Network   string  `json:"network,omitempty"`
+	Pending   int64   `json:"pending,omitempty"`
+	Settled   int64   `json:"settled,omitempty"`
+}
+
+type DashboardSigner struct {
+	Balance  float64 `json:"balance,omitempty"`
+	Currency string  `json:"currency,omitempty"`
+	Network  string  `json:"network,omitempty"`
+	Signer   string  `json:"signer,omitempty"`
+}
+
+type Entry struct {
+	Data    map[string]string `json:"data,omitempty"`
+	Kind    Kind              `json:"kind,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Time    string            `json:"time,omitempty"`
+}
+
+// Machine readable code of a payment or request error. Facilitators may report codes added later,
+// clients should accept unknown codes
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidPayloadFormat         ErrorCode = "invalid_payload_format"
+	ErrorCodeIncompatiblePayloadScheme    ErrorCode = "incompatible_payload_scheme"
+	ErrorCodeNetworkMismatch              ErrorCode = "network_mismatch"
+	ErrorCodeInvalidNetwork               ErrorCode = "invalid_network"
+	ErrorCodeNetworkIDMismatch            ErrorCode = "network_id_mismatch"
+	ErrorCodeInvalidSignature             ErrorCode = "invalid_signature"
+	ErrorCodeInvalidToken                 ErrorCode = "invalid_token"
+	ErrorCodeTokenMismatch                ErrorCode = "token_mismatch"
+	ErrorCodeInsufficientBalance          ErrorCode = "insufficient_balance"
+	ErrorCodeRPCAnomaly                   ErrorCode = "rpc_anomaly"
+	ErrorCodeAmountExceedsLimit           ErrorCode = "amount_exceeds_limit"
+	ErrorCodePriceUnavailable             ErrorCode = "price_unavailable"
+	ErrorCodeAuthorizationAlreadyUsed     ErrorCode = "authorization_already_used"
+	ErrorCodeAuthorizationExpired         ErrorCode = "authorization_expired"
+	ErrorCodeAuthorizationNotYetValid     ErrorCode = "authorization_not_yet_valid"
+	ErrorCodeInsufficientAllowance        ErrorCode = "insufficient_allowance"
+	ErrorCodeTransactionReverted          ErrorCode = "transaction_reverted"
+	ErrorCodeTransferNotEmitted           ErrorCode = "transfer_not_emitted"
+	ErrorCodeRecipientMismatch            ErrorCode = "recipient_mismatch"
+	ErrorCodeSpenderMismatch              ErrorCode = "spender_mismatch"
+	ErrorCodeUnregisteredRequirements     ErrorCode = "unregistered_requirements"
+	ErrorCodeValueMismatch                ErrorCode = "value_mismatch"
+	ErrorCodeNonceTooHigh                 ErrorCode = "nonce_too_high"
+	ErrorCodeFeeOnTransferToken           ErrorCode = "fee_on_transfer_token"
+	ErrorCodeGasTooExpensive              ErrorCode = "gas_too_expensive"
+	ErrorCodeFeePayerInsufficientFunds    ErrorCode = "fee_payer_insufficient_funds"
+	ErrorCodeRecipientAccountMissing      ErrorCode = "recipient_account_missing"
+	ErrorCodeNetworkNotAllowed            ErrorCode = "network_not_allowed"
+	ErrorCodeAssetNotAllowed              ErrorCode = "asset_not_allowed"
+	ErrorCodeRecipientNotAllowed          ErrorCode = "recipient_not_allowed"
+	ErrorCodeRecipientNotRegistered       ErrorCode = "recipient_not_registered"
+	ErrorCodeRecipientRegistryUnavailable ErrorCode = "recipient_registry_unavailable"
+	ErrorCodeComplianceRejected           ErrorCode = "compliance_rejected"
+	ErrorCodeScreeningUnavailable         ErrorCode = "screening_unavailable"
+	ErrorCodeInvalidSplit                 ErrorCode = "invalid_split"
+	ErrorCodeSplitNotSupported            ErrorCode = "split_not_supported"
+	ErrorCodeSplitFailed                  ErrorCode = "split_failed"
+	ErrorCodeTimeout                      ErrorCode = "TIMEOUT"
+	ErrorCodeChainUnavailable             ErrorCode = "CHAIN_UNAVAILABLE"
+	ErrorCodeUnsupportedNetwork           ErrorCode = "UNSUPPORTED_NETWORK"
+)
+
+type ErrorResponse struct {
+	Code    ErrorCode `json:"code,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+type Event struct {
+	// Amount paid in atomic units of the asset
+	Amount string `json:"amount,omitempty"`
+	// USD value of the payment at submission, present only if a price oracle is configured
+	AmountUSD float64 `json:"amountUsd,omitempty"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset string `json:"asset,omitempty"`
+	// Block number the transaction was included in, once mined
+	BlockNumber int64 `json:"blockNumber,omitempty"`
+	// Error message, if the settlement failed
+	Error string `json:"error,omitempty"`
+	// Unique ID of the settlement
+	ID string `json:"id,omitempty"`
+	// Network the settlement is executed on
+	Network string `json:"network,omitempty"`
+	// Recipient of the payment
+	PayTo string `json:"payTo,omitempty"`
+	// Address of the payer, if known
+	Payer string `json:"payer,omitempty"`
+	// Reference the resource server attached to the settle request, if any
+	Reference string `json:"reference,omitempty"`
+	// ID of the refund of the settlement the event is about, absent for events of the settlement
+	// itself
+	RefundID string `json:"refundId,omitempty"`
+	// ID of the API request of the settlement, the X-Request-ID header
+	RequestID string `json:"requestId,omitempty"`
+	// Scheme used for the settlement
+	Scheme string `json:"scheme,omitempty"`
+	// New status of the settlement
+	Status Status `json:"status,omitempty"`
+	// Tenant whose API key requested the settlement, if any
+	Tenant string `json:"tenant,omitempty"`
+	// Time of the transition
+	Timestamp string `json:"timestamp,omitempty"`
+	// Transaction hash, once submitted
+	TxHash string `json:"txHash,omitempty"`
+}
+
+type FieldError struct {
+	// JSON path of the field (e.g. "paymentRequirements.payTo")
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type HTTPError struct {
+	Message any `json:"message,omitempty"`
+}
+
+type IndexedNetwork struct {
+	// Last block whose transfers were reconciled, 0 before the first one
+	Block int64 `json:"block,omitempty"`
+	// Error of the last indexing pass, empty if it succeeded
+	Error   string `json:"error,omitempty"`
+	Network string `json:"network,omitempty"`
+}
+
+type Info struct {
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+	// Whether the working tree had uncommitted changes, known for VCS builds only
+	Modified bool   `json:"modified,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+type Kind string
+
+const (
+	KindSimulation        Kind = "simulation"
+	KindGasEstimate       Kind = "gas_estimate"
+	KindSignedTransaction Kind = "signed_transaction"
+	KindRetry             Kind = "retry"
+	KindRPCError          Kind = "rpc_error"
+	KindDeferral          Kind = "deferral"
+	KindReceipt           Kind = "receipt"
+	KindError             Kind = "error"
+)
+
+type NetworkPause struct {
+	// CAIP-2 identifier of the network
+	Network string `json:"network,omitempty"`
+	Paused  bool   `json:"paused,omitempty"`
+	// When settlements were paused, unset while they proceed
+	PausedAt string `json:"pausedAt,omitempty"`
+}
+
+type PaymentEstimateResponse struct {
+	// Error message of the failed simulation, if any
+	Error ErrorCode `json:"error,omitempty"`
+	// Estimated gas cost in atomic units of the native token
+	GasCost string `json:"gasCost,omitempty"`
+	// Estimated gas cost in the native token, as a decimal string
+	GasCostNative string `json:"gasCostNative,omitempty"`
+	// Estimated gas cost in USD, present only if a price oracle is configured
+	GasCostUSD float64 `json:"gasCostUsd,omitempty"`
+	// Estimated gas limit of the settlement transaction
+	GasLimit int64 `json:"gasLimit,omitempty"`
+	// Gas price in atomic units of the native token
+	GasPrice string `json:"gasPrice,omitempty"`
+	// Symbol of the native token the gas is paid in
+	NativeCurrency string `json:"nativeCurrency,omitempty"`
+	// Address of the payer
+	Payer string `json:"payer,omitempty"`
+	// Whether the simulated settlement succeeded
+	Success bool `json:"success,omitempty"`
+}
+
+type PaymentPayload struct {
+	// Network ID of the accepted paymentRequirements the client is using to pay
+	Network string `json:"network,omitempty"`
+	// Payload is E-dependent and may contain authorization and signature data
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Scheme value of the accepted paymentRequirements the client is using to pay
+	Scheme string `json:"scheme,omitempty"`
+	// Version of the x402 payment protocol
+	X402Version int64 `json:"x402Version,omitempty"`
+}
+
+type PaymentRequirements struct {
+	// Address of the EIP-3009 compliant ERC20 contract
+	Asset string `json:"asset,omitempty"`
+	// Description of the resource
+	Description string `json:"description,omitempty"`
+	// Extra information about the payment details specific to the scheme
+	Extra json.RawMessage `json:"extra,omitempty"`
+	// Maximum amount required to pay for the resource in atomic units
+	MaxAmountRequired string `json:"maxAmountRequired,omitempty"`
+	// Maximum time in seconds for the resource server to respond
+	MaxTimeoutSeconds int64 `json:"maxTimeoutSeconds,omitempty"`
+	// MIME type of the resource response
+	MimeType string `json:"mimeType,omitempty"`
+	// Network of the blockchain to send payment on (e.g., "base-sepolia")
+	Network string `json:"network,omitempty"`
+	// Output schema of the resource response (optional)
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+	// Address to pay value to
+	PayTo string `json:"payTo,omitempty"`
+	// URL of the resource to pay for
+	Resource string `json:"resource,omitempty"`
+	// Scheme of the payment protocol to use (e.g., "exact")
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type PaymentSettleRequest struct {
+	// Unix time by which the settlement is submitted, at the latest when the
+	// authorization expires. The window opens at notBefore or right away
+	NotAfter int64 `json:"notAfter,omitempty"`
+	// Unix time before which the settlement isn't submitted. Requests with a
+	// scheduling window are answered with 202 and settled in the background
+	NotBefore int64 `json:"notBefore,omitempty"`
+	// Settlements of the same tenant and payee with this key are submitted one
+	// after the other in the order they arrive, at most 128 characters
+	OrderingKey   string          `json:"orderingKey,omitempty"`
+	PaymentHeader *PaymentPayload `json:"paymentHeader,omitempty"`
+	// Requirements of the payment, required unless requirementsId is set
+	PaymentRequirements *PaymentRequirements `json:"paymentRequirements,omitempty"`
+	// Priority of the settlement when the queue is backed up, capped by the priority of the tenant.
+	// The tenant's applies if 0
+	Priority int64 `json:"priority,omitempty"`
+	// Opaque reference of the resource server, e.g. an order ID, returned in the
+	// response and, on settlement, kept with the settlement, its webhook events
+	// and receipt. At most 256 characters
+	Reference string `json:"reference,omitempty"`
+	// ID of requirements registered with POST /requirements, instead of paymentRequirements
+	RequirementsID string `json:"requirementsId,omitempty"`
+	// Deadline of the settlement in milliseconds, capped by the server maximum. The server default
+	// applies if 0
+	TimeoutMs   int64 `json:"timeoutMs,omitempty"`
+	X402Version int64 `json:"x402Version,omitempty"`
+}
+
+type PaymentSettleResponse struct {
+	// Value of the settled amount in USD, present only if a price oracle is configured
+	AmountUSD float64 `json:"amountUsd,omitempty"`
+	// Error message, if any
+	Error ErrorCode `json:"error,omitempty"`
+	// Network ID where the transaction was submitted
+	NetworkID string `json:"networkId,omitempty"`
+	// Address of the payer
+	Payer string `json:"payer,omitempty"`
+	// Receipt of the settlement signed by the facilitator, present only if receipts are enabled
+	Receipt *SettlementReceipt `json:"receipt,omitempty"`
+	// Reference of the request, if it had one
+	Reference string `json:"reference,omitempty"`
+	// Shares of a split payment sent to their recipients
+	Splits []*SplitTransfer `json:"splits,omitempty"`
+	// Whether the payment was successful
+	Success bool `json:"success,omitempty"`
+	// Transaction hash of the settled payment
+	TxHash string `json:"txHash,omitempty"`
+}
+
+type PaymentVerifyRequest struct {
+	PaymentHeader *PaymentPayload `json:"paymentHeader,omitempty"`
+	// Requirements of the payment, required unless requirementsId is set
+	PaymentRequirements *PaymentRequirements `json:"paymentRequirements,omitempty"`
+	// Opaque reference of the resource server, e.g. an order ID, returned in the
+	// response and, on settlement, kept with the settlement, its webhook events
+	// and receipt. At most 256 characters
+	Reference string `json:"reference,omitempty"`
+	// ID of requirements registered with POST /requirements, instead of paymentRequirements
+	RequirementsID string `json:"requirementsId,omitempty"`
+	X402Version    int64  `json:"x402Version,omitempty"`
+}
+
+type PaymentVerifyResponse struct {
+	// Whether the chain couldn't be reached, so only the signature and terms of
+	// the payment were checked, not the balance of the payer or whether the
+	// authorization was used
+	ChainChecksSkipped bool `json:"chainChecksSkipped,omitempty"`
+	// Error message or reason for invalidity, if applicable
+	InvalidReason ErrorCode `json:"invalidReason,omitempty"`
+	// Whether the payment payload is valid
+	IsValid bool   `json:"isValid,omitempty"`
+	Payer   string `json:"payer,omitempty"`
+	// Reference of the request, if it had one
+	Reference string `json:"reference,omitempty"`
+}
+
+type Recipient struct {
+	Address string `json:"address,omitempty"`
+	// Registration message and the signature of the address over it
+	Message      string `json:"message,omitempty"`
+	Network      string `json:"network,omitempty"`
+	RegisteredAt string `json:"registeredAt,omitempty"`
+	// When the registration was revoked, absent while it is valid
+	RevokedAt string `json:"revokedAt,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type Reconciliation struct {
+	// Most recent findings, newest first
+	Findings []*ReconciliationFinding `json:"findings,omitempty"`
+	// Progress of the indexer by network
+	Networks []*IndexedNetwork `json:"networks,omitempty"`
+}
+
+type ReconciliationFinding struct {
+	// Block of the transfer, 0 for missing receipts
+	Block      int64  `json:"block,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	DetectedAt string `json:"detectedAt,omitempty"`
+	// FindingOrphanedTransfer or FindingMissingReceipt
+	Kind    string `json:"kind,omitempty"`
+	Network string `json:"network,omitempty"`
+	// Settlement the finding concerns, empty for transfers without one
+	SettlementID string `json:"settlementId,omitempty"`
+	TxHash       string `json:"txHash,omitempty"`
+}
+
+type Refund struct {
+	// Refunded amount in atomic units of the asset
+	Amount      string `json:"amount,omitempty"`
+	Asset       string `json:"asset,omitempty"`
+	BlockNumber int64  `json:"blockNumber,omitempty"`
+	CreatedAt   string `json:"createdAt,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Sender of the refund, absent for transactions sent by the payee
+	From         string `json:"from,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Network      string `json:"network,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	SettlementID string `json:"settlementId,omitempty"`
+	// submitted, mined, confirmed or failed
+	Status    string `json:"status,omitempty"`
+	To        string `json:"to,omitempty"`
+	TxHash    string `json:"txHash,omitempty"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+type RefundRequest struct {
+	// Refunded amount in atomic units of the asset
+	Amount string `json:"amount,omitempty"`
+	// Address of the refunded asset, the asset of the settlement if empty
+	Asset string `json:"asset,omitempty"`
+	// Authorization of the transfer back to the payer, mutually exclusive with TxHash
+	PaymentPayload *PaymentPayload `json:"paymentPayload,omitempty"`
+	Reason         string          `json:"reason,omitempty"`
+	// ID of the settlement the refund reverses
+	SettlementID string `json:"settlementId,omitempty"`
+	// Hash of a refund transaction sent by the payee, mutually exclusive with PaymentPayload
+	TxHash string `json:"txHash,omitempty"`
+}
+
+type RegisteredRequirements struct {
+	// ID to send as requirementsId of verify and settle requests
+	ID                  string               `json:"id,omitempty"`
+	PaymentRequirements *PaymentRequirements `json:"paymentRequirements,omitempty"`
+}
+
+type Registration struct {
+	Address string `json:"address,omitempty"`
+	// When the owner signed the registration message
+	IssuedAt string `json:"issuedAt,omitempty"`
+	// CAIP-2 identifier of the network, e.g. "eip155:8453"
+	Network string `json:"network,omitempty"`
+	// Signature of the address over Message: EIP-191 personal_sign in hex on
+	// EVM networks, ed25519 in base58 on Solana
+	Signature string `json:"signature,omitempty"`
+}
+
+type Route struct {
+	Method string `json:"method,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+type RuntimeGC struct {
+	// Completed collections
+	Count int64 `json:"count,omitempty"`
+	// Share of the CPU time used by the collector since the process started
+	CpuFraction float64 `json:"cpuFraction,omitempty"`
+	LastAt      string  `json:"lastAt,omitempty"`
+	// Heap size the next collection starts at, in bytes
+	NextHeap int64 `json:"nextHeap,omitempty"`
+	// Total stop-the-world pause time in milliseconds
+	PauseTotalMs float64 `json:"pauseTotalMs,omitempty"`
+	// Pauses of the most recent collections in milliseconds, newest first
+	RecentPausesMs []float64 `json:"recentPausesMs,omitempty"`
+}
+
+type RuntimeHeap struct {
+	// Bytes of allocated heap objects
+	Alloc int64 `json:"alloc,omitempty"`
+	// Bytes in spans without objects, including those returned to the OS
+	Idle int64 `json:"idle,omitempty"`
+	// Bytes in spans holding at least one object
+	InUse int64 `json:"inUse,omitempty"`
+	// Number of allocated heap objects
+	Objects int64 `json:"objects,omitempty"`
+	// Bytes returned to the OS
+	Released int64 `json:"released,omitempty"`
+	// Bytes of heap memory obtained from the OS
+	Sys int64 `json:"sys,omitempty"`
+	// Total bytes of memory obtained from the OS, not only for the heap
+	TotalSys int64 `json:"totalSys,omitempty"`
+}
+
+type RuntimeStats struct {
+	Gc          *RuntimeGC   `json:"gc,omitempty"`
+	GeneratedAt string       `json:"generatedAt,omitempty"`
+	GoVersion   string       `json:"goVersion,omitempty"`
+	Goroutines  int64        `json:"goroutines,omitempty"`
+	Heap        *RuntimeHeap `json:"heap,omitempty"`
+	// Value of GOMAXPROCS
+	MaxProcs int64 `json:"maxProcs,omitempty"`
+	// Open connections to the RPC endpoints by network
+	RPCConnections map[string]int64 `json:"rpcConnections,omitempty"`
+	// Seconds since the process started
+	UptimeSeconds float64 `json:"uptimeSeconds,omitempty"`
+}
+
+type ScheduledSettlementResponse struct {
+	// Unix time the window closes, capped by the expiry of the authorization
+	NotAfter int64 `json:"notAfter,omitempty"`
+	// Unix time the window opens
+	NotBefore int64 `json:"notBefore,omitempty"`
+	// Reference of the request, if it had one
+	Reference string `json:"reference,omitempty"`
+	// ID of the settlement
+	SettlementID string `json:"settlementId,omitempty"`
+	// Status of the settlement, "scheduled"
+	Status string `json:"status,omitempty"`
+}
+
+type SettlementDebug struct {
+	Asset       string `json:"asset,omitempty"`
+	BlockNumber int64  `json:"blockNumber,omitempty"`
+	CreatedAt   string `json:"createdAt,omitempty"`
+	// Steps of the settlement in the order they happened, empty unless it failed
+	Diagnostics []*Entry `json:"diagnostics,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	GasUsed     int64    `json:"gasUsed,omitempty"`
+	ID          string   `json:"id,omitempty"`
+	Network     string   `json:"network,omitempty"`
+	Payer       string   `json:"payer,omitempty"`
+	// Reference the resource server attached to the settle request
+	Reference string `json:"reference,omitempty"`
+	// ID of the API request of the settlement, the X-Request-ID header
+	RequestID string `json:"requestId,omitempty"`
+	Reverted  bool   `json:"reverted,omitempty"`
+	Scheme    string `json:"scheme,omitempty"`
+	// queued, submitted, mined, confirmed, failed or expired
+	Status    string `json:"status,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+	TxHash    string `json:"txHash,omitempty"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+type SettlementReceipt struct {
+	// Amount in atomic units of the asset
+	Amount string `json:"amount,omitempty"`
+	// Address of the paid asset
+	Asset string `json:"asset,omitempty"`
+	// CAIP-2 identifier of the network the payment was settled on
+	Network string `json:"network,omitempty"`
+	// Address of the payee
+	Payee string `json:"payee,omitempty"`
+	// Address of the payer
+	Payer string `json:"payer,omitempty"`
+	// Reference the settle request carried, signed only if present
+	Reference string `json:"reference,omitempty"`
+	// EIP-712 signature of the signer over the receipt, hex encoded
+	Signature string `json:"signature,omitempty"`
+	// Address of the facilitator key that signed the receipt
+	Signer string `json:"signer,omitempty"`
+	// Unix time in seconds the settlement was submitted at
+	Timestamp int64 `json:"timestamp,omitempty"`
+	// Hash of the settlement transaction
+	TxHash string `json:"txHash,omitempty"`
+}
+
+type SettlementStats struct {
+	// Buckets of the period in order, including empty ones
+	Buckets []*SettlementStatsBucket `json:"buckets,omitempty"`
+	From    string                   `json:"from,omitempty"`
+	// Length of the buckets: minute, hour or day
+	Granularity string `json:"granularity,omitempty"`
+	To          string `json:"to,omitempty"`
+}
+
+type SettlementStatsBucket struct {
+	// Mean time from the settle request to the confirmation of confirmed settlements in milliseconds,
+	// absent if none was confirmed
+	AvgLatencyMs float64 `json:"avgLatencyMs,omitempty"`
+	Failed       int64   `json:"failed,omitempty"`
+	// Settlements not submitted yet
+	Pending int64 `json:"pending,omitempty"`
+	// Settlements submitted, mined or confirmed
+	Settled int64 `json:"settled,omitempty"`
+	// Settlements created
+	Settlements int64  `json:"settlements,omitempty"`
+	Start       string `json:"start,omitempty"`
+	// Share of the settled and failed settlements that settled, absent if none finished
+	SuccessRate float64 `json:"successRate,omitempty"`
+	// Settled amounts by network and asset
+	Volumes []*SettlementVolume `json:"volumes,omitempty"`
+}
+
+type SettlementVolume struct {
+	// Total amount in atomic units of the asset
+	Amount string `json:"amount,omitempty"`
+	// Total amount in whole units, absent if the decimals of the asset are unknown
+	AmountDecimal string `json:"amountDecimal,omitempty"`
+	// Total value in USD at submission. Only present if every payment could be priced
+	AmountUSD float64 `json:"amountUsd,omitempty"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset       string `json:"asset,omitempty"`
+	Network     string `json:"network,omitempty"`
+	Settlements int64  `json:"settlements,omitempty"`
+}
+
+type Spec struct {
+	// ID of the key, generated if empty. Lowercase letters, digits and dashes
+	ID string `json:"id,omitempty"`
+	// Description of the key, e.g. who it was issued to
+	Name string `json:"name,omitempty"`
+	// CAIP-2 identifiers or families like "eip155:*" of the networks the key may pay on, all if empty
+	Networks []string `json:"networks,omitempty"`
+	// Scopes granted to the key: verify, settle, read-status or admin
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type SplitTransfer struct {
+	// Amount in atomic units of the asset
+	Amount    string `json:"amount,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+	// Transaction of the transfer, the settlement transaction with splitter contracts
+	TxHash string `json:"txHash,omitempty"`
+}
+
+type Status string
+
+const (
+	StatusScheduled Status = "scheduled"
+	StatusQueued    Status = "queued"
+	StatusSubmitted Status = "submitted"
+	StatusMined     Status = "mined"
+	StatusConfirmed Status = "confirmed"
+	StatusFailed    Status = "failed"
+	StatusExpired   Status = "expired"
+)
+
+type SupportedKind struct {
+	// Extra information clients need to pay with this kind (e.g. the Solana fee
+	// payer), the accepted assets under "assets"
+	Extra       map[string]any `json:"extra,omitempty"`
+	Network     string         `json:"network,omitempty"`
+	Scheme      string         `json:"scheme,omitempty"`
+	X402Version int64          `json:"x402Version,omitempty"`
+}
+
+type SupportedResponse struct {
+	Kinds []*SupportedKind `json:"kinds,omitempty"`
+	// Addresses of the facilitator signers by CAIP-2 family (e.g. "eip155:*")
+	Signers map[string][]string `json:"signers,omitempty"`
+}
+
+type UnsupportedNetworkResponse struct {
+	Code    ErrorCode `json:"code,omitempty"`
+	Message string    `json:"message,omitempty"`
+	// CAIP-2 identifiers of the networks payments are accepted on
+	SupportedNetworks []string `json:"supportedNetworks,omitempty"`
+}
+
+type ValidationErrorResponse struct {
+	Errors  []*FieldError `json:"errors,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+type WellKnownResponse struct {
+	Kinds []*SupportedKind `json:"kinds,omitempty"`
+	// Addresses of the facilitator signers by CAIP-2 network (e.g. "eip155:8453")
+	Signers map[string][]string `json:"signers,omitempty"`
+	// x402 versions the facilitator accepts
+	X402Versions []int64 `json:"x402Versions,omitempty"`
+}
+
+// Client calls the API of a facilitator.
+type Client struct {
+	// BaseURL of the facilitator, e.g. https://facilitator.example.com
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// RequestEditors are applied to every request before it is sent, e.g. to authenticate it
+	RequestEditors []func(*http.Request) error
+}
+
+// NewClient returns a client of the facilitator at the base URL.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Error is returned for responses of failed requests.
+type Error struct {
+	StatusCode int
+	// Code of the error, if the facilitator reported one
+	Code string
+	// Message of the error, the response body if it isn't an error object
+	Message string
+	// Body of the response
+	Body []byte
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("facilitator responded %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("facilitator responded %d: %s", e.StatusCode, e.Message)
+}
+
+// expandPath replaces the parameters of the path with their escaped values.
+func expandPath(path string, params ...string) string {
+	for i := 0; i+1 < len(params); i += 2 {
+		path = strings.ReplaceAll(path, "{"+params[i]+"}", url.PathEscape(params[i+1]))
+	}
+	return path
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	target := c.BaseURL + path
+	for key, values := range query {
+		if len(values) == 0 || values[0] == "" {
+			delete(query, key)
+		}
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, edit := range c.RequestEditors {
+		if err := edit(req); err != nil {
+			return err
+		}
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data)), Body: data}
+		var decoded struct {
+			Code    string `json:"code"`
+			Message any    `json:"message"`
+		}
+		if json.Unmarshal(data, &decoded) == nil {
+			apiErr.Code = decoded.Code
+			if message, ok := decoded.Message.(string); ok {
+				apiErr.Message = message
+			}
+		}
+		return apiErr
+	}
+	switch result := result.(type) {
+	case nil:
+		return nil
+	case *string:
+		*result = string(data)
+		return nil
+	default:
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+// Config calls GET /admin/config: Configuration.
+//
+// Get the merged configuration of defaults, file and environment with secrets redacted (localhost
+// or admin API key)
+func (c *Client) Config(ctx context.Context) (map[string]any, error) {
+	var result map[string]any
+	err := c.do(ctx, "GET", "/admin/config", nil, nil, &result)
+	return result, err
+}
+
+// CostsParams are the query parameters of Costs.
+type CostsParams struct {
+	// Start of the period (RFC 3339), defaults to 24 hours before to
+	From string
+	// End of the period (RFC 3339), defaults to now
+	To string
+}
+
+// Costs calls GET /admin/costs: Settlement cost report.
+//
+// Sum the gas used and fees paid for the settlements created in [from, to) by network and asset
+// (localhost or admin API key)
+func (c *Client) Costs(ctx context.Context, params CostsParams) (*CostReport, error) {
+	var result CostReport
+	if err := c.do(ctx, "GET", "/admin/costs", url.Values{"from": {params.From}, "to": {params.To}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateAPIKey calls POST /admin/keys: Create API key.
+//
+// Create an API key granting the scopes, optionally limited to some networks. The key is returned
+// once, only the hash of its secret is stored (localhost or admin API key)
+func (c *Client) CreateAPIKey(ctx context.Context, body *Spec) (*CreatedAPIKey, error) {
+	var result CreatedAPIKey
+	if err := c.do(ctx, "POST", "/admin/keys", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateRefund calls POST /admin/refunds: Refund settlement.
+//
+// Record a refund of a settlement to its payer. Either submit an EIP-3009 authorization of the
+// payee transferring the amount to the payer, which is settled like a payment, or the hash of a
+// refund transaction the payee sent. The refund is tracked until it is confirmed or fails, and its
+// transitions are published as settlement events carrying the refund ID to the websocket stream and
+// webhooks (localhost or admin API key)
+func (c *Client) CreateRefund(ctx context.Context, body *RefundRequest) (*Refund, error) {
+	var result Refund
+	if err := c.do(ctx, "POST", "/admin/refunds", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Dashboard calls GET /admin/dashboard: Settlement dashboard.
+//
+// Serve an HTML page showing settlement throughput, error rates, queue depth and signer balances,
+// refreshed every few seconds (localhost or admin API key)
+func (c *Client) Dashboard(ctx context.Context) (string, error) {
+	var result string
+	err := c.do(ctx, "GET", "/admin/dashboard", nil, nil, &result)
+	return result, err
+}
+
+// DashboardData calls GET /admin/dashboard/data: Dashboard data.
+//
+// Count the settlements of the last hour by minute and by network, and report the settlement queue,
+// signer gas balances and paused networks (localhost or admin API key)
+func (c *Client) DashboardData(ctx context.Context) (*Dashboard, error) {
+	var result Dashboard
+	if err := c.do(ctx, "GET", "/admin/dashboard/data", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EstimateSettle calls POST /settle/estimate: Estimate settlement.
+//
+// Simulate a settlement without broadcasting it and estimate its gas cost
+func (c *Client) EstimateSettle(ctx context.Context, body *PaymentSettleRequest) (*PaymentEstimateResponse, error) {
+	var result PaymentEstimateResponse
+	if err := c.do(ctx, "POST", "/settle/estimate", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportSettlementsParams are the query parameters of ExportSettlements.
+type ExportSettlementsParams struct {
+	// csv (default) or parquet
+	Format string
+	// Start of the period (RFC 3339), defaults to 24 hours before to
+	From string
+	// End of the period (RFC 3339), defaults to now
+	To string
+}
+
+// ExportSettlements calls GET /admin/export: Export settlements.
+//
+// Stream the settlements created in [from, to) as CSV or Parquet, oldest first: status, payer,
+// recipient, asset, amount, transaction hash, gas used, gas price and fee. Amounts are in atomic
+// units, and in whole units if the decimals of the asset are known (localhost or admin API key)
+func (c *Client) ExportSettlements(ctx context.Context, params ExportSettlementsParams) (string, error) {
+	var result string
+	err := c.do(ctx, "GET", "/admin/export", url.Values{"format": {params.Format}, "from": {params.From}, "to": {params.To}}, nil, &result)
+	return result, err
+}
+
+// GetAPIKey calls GET /admin/keys/{id}: Get API key.
+//
+// Get an API key, without its secret (localhost or admin API key)
+func (c *Client) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	var result APIKey
+	if err := c.do(ctx, "GET", expandPath("/admin/keys/{id}", "id", id), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRefund calls GET /admin/refunds/{id}: Get refund.
+//
+// Get a refund and its progress (localhost or admin API key)
+func (c *Client) GetRefund(ctx context.Context, id string) (*Refund, error) {
+	var result Refund
+	if err := c.do(ctx, "GET", expandPath("/admin/refunds/{id}", "id", id), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRequirements calls GET /requirements/{id}: Get requirements.
+//
+// Get the payment requirements registered under the ID
+func (c *Client) GetRequirements(ctx context.Context, id string) (*RegisteredRequirements, error) {
+	var result RegisteredRequirements
+	if err := c.do(ctx, "GET", expandPath("/requirements/{id}", "id", id), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListAPIKeys calls GET /admin/keys: List API keys.
+//
+// List all API keys, including revoked ones, oldest first (localhost or admin API key)
+func (c *Client) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	var result []*APIKey
+	err := c.do(ctx, "GET", "/admin/keys", nil, nil, &result)
+	return result, err
+}
+
+// ListRecipients calls GET /admin/recipients: List recipients.
+//
+// List the registered recipients of all networks, including revoked ones, oldest first (localhost
+// or admin API key)
+func (c *Client) ListRecipients(ctx context.Context) ([]*Recipient, error) {
+	var result []*Recipient
+	err := c.do(ctx, "GET", "/admin/recipients", nil, nil, &result)
+	return result, err
+}
+
+// ListRefundsParams are the query parameters of ListRefunds.
+type ListRefundsParams struct {
+	// Only list the refunds of this settlement
+	SettlementID string
+}
+
+// ListRefunds calls GET /admin/refunds: List refunds.
+//
+// List the refunds of a settlement, or of all settlements, oldest first (localhost or admin API
+// key)
+func (c *Client) ListRefunds(ctx context.Context, params ListRefundsParams) ([]*Refund, error) {
+	var result []*Refund
+	err := c.do(ctx, "GET", "/admin/refunds", url.Values{"settlementId": {params.SettlementID}}, nil, &result)
+	return result, err
+}
+
+// ListRoutes calls GET /debug/routes: List routes.
+//
+// List all routes registered on the server (localhost only)
+func (c *Client) ListRoutes(ctx context.Context) ([]*Route, error) {
+	var result []*Route
+	err := c.do(ctx, "GET", "/debug/routes", nil, nil, &result)
+	return result, err
+}
+
+// OpenAPI calls GET /openapi.yaml: OpenAPI document.
+//
+// Get the OpenAPI 3.1 document of the API, which the clients under api/gen are generated from
+func (c *Client) OpenAPI(ctx context.Context) (string, error) {
+	var result string
+	err := c.do(ctx, "GET", "/openapi.yaml", nil, nil, &result)
+	return result, err
+}
+
+// PauseNetwork calls POST /admin/networks/{network}/pause: Pause network.
+//
+// Stop settling payments on a network, e.g. during a reorg or an incident of its RPC endpoints.
+// Settle requests are answered with 503 and settlements still queued fail without being broadcast,
+// while payments are still verified. /supported lists the network with "paused" in its extra. The
+// pause lasts until the network is resumed or the instance restarts, and applies to this instance
+// only (localhost or admin API key)
+func (c *Client) PauseNetwork(ctx context.Context, network string) (*NetworkPause, error) {
+	var result NetworkPause
+	if err := c.do(ctx, "POST", expandPath("/admin/networks/{network}/pause", "network", network), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Receipt calls GET /receipts/{txHash}: Settlement receipt.
+//
+// Get the receipt of a settlement signed by the facilitator. The signature is an EIP-712 signature
+// of the signer over the receipt in the domain {name: "x402 facilitator receipt", version: "1"}, of
+// the primary type Receipt, or ReferencedReceipt for settlements with a reference, see package
+// receipt
+func (c *Client) Receipt(ctx context.Context, txHash string) (*SettlementReceipt, error) {
+	var result SettlementReceipt
+	if err := c.do(ctx, "GET", expandPath("/receipts/{txHash}", "txHash", txHash), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Reconciliation calls GET /admin/reconciliation: Reconciliation findings.
+//
+// Get the progress of the indexer per network and the most recent transfers of the signers without
+// a settlement on record and settlements whose transaction wasn't mined, newest first (localhost or
+// admin API key)
+func (c *Client) Reconciliation(ctx context.Context) (*Reconciliation, error) {
+	var result Reconciliation
+	if err := c.do(ctx, "GET", "/admin/reconciliation", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RegisterRecipient calls POST /admin/recipients: Register recipient.
+//
+// Register an address to receive payments on networks that only pay registered recipients. The
+// signature proves ownership of the address: it signs the message "x402 facilitator recipient
+// registration\nNetwork: <network>\nAddress: <address>\nIssued At: <issuedAt in RFC 3339, UTC>"
+// with EIP-191 personal_sign (hex) on EVM networks or ed25519 (base58) on Solana (localhost or
+// admin API key)
+func (c *Client) RegisterRecipient(ctx context.Context, body *Registration) (*Recipient, error) {
+	var result Recipient
+	if err := c.do(ctx, "POST", "/admin/recipients", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RegisterRequirements calls POST /requirements: Register requirements.
+//
+// Register payment requirements and get their ID, which verify and settle requests can send as
+// requirementsId instead of paymentRequirements. Registering the same requirements again returns
+// the same ID and renews them. IDs are only valid for the tenant that registered them and expire a
+// TTL after the last registration
+func (c *Client) RegisterRequirements(ctx context.Context, body *PaymentRequirements) (*RegisteredRequirements, error) {
+	var result RegisteredRequirements
+	if err := c.do(ctx, "POST", "/requirements", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ResumeNetwork calls POST /admin/networks/{network}/resume: Resume network.
+//
+// Settle payments on a paused network again (localhost or admin API key)
+func (c *Client) ResumeNetwork(ctx context.Context, network string) (*NetworkPause, error) {
+	var result NetworkPause
+	if err := c.do(ctx, "POST", expandPath("/admin/networks/{network}/resume", "network", network), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RevokeAPIKey calls DELETE /admin/keys/{id}: Revoke API key.
+//
+// Revoke an API key, requests carrying it are rejected afterwards. Other instances apply the
+// revocation within 30 seconds (localhost or admin API key)
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", expandPath("/admin/keys/{id}", "id", id), nil, nil, nil)
+}
+
+// RevokeRecipient calls DELETE /admin/recipients/{network}/{address}: Revoke recipient.
+//
+// Revoke the registration of an address, payments to it are rejected afterwards. Registering it
+// again needs a message issued after the revocation (localhost or admin API key)
+func (c *Client) RevokeRecipient(ctx context.Context, network string, address string) error {
+	return c.do(ctx, "DELETE", expandPath("/admin/recipients/{network}/{address}", "network", network, "address", address), nil, nil, nil)
+}
+
+// Runtime calls GET /admin/runtime: Runtime diagnostics.
+//
+// Report the goroutine count, heap statistics, recent GC pauses and open RPC connections by
+// network. CPU, heap, goroutine and other profiles are served by net/http/pprof under
+// /admin/debug/pprof/ and runtime variables by expvar at /admin/debug/vars (localhost or admin API
+// key)
+func (c *Client) Runtime(ctx context.Context) (*RuntimeStats, error) {
+	var result RuntimeStats
+	if err := c.do(ctx, "GET", "/admin/runtime", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Settle calls POST /settle: Settle payment.
+//
+// Settle a payment using the facilitator. Version 2 requests (paymentPayload instead of
+// paymentHeader) are answered with a version 2 settle response. Payments are routed by their
+// network, those on networks that aren't configured are answered with 400 and UNSUPPORTED_NETWORK.
+// Requests with a scheduling window (notBefore or notAfter) are verified, answered with 202, or 200
+// and a failed settle response if the payment is rejected, and settled in the background within the
+// window, once the gas price of the network falls to its threshold or the window closes. Their
+// outcome is published as settlement events
+func (c *Client) Settle(ctx context.Context, body *PaymentSettleRequest) (*PaymentSettleResponse, error) {
+	var result PaymentSettleResponse
+	if err := c.do(ctx, "POST", "/settle", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SettlementDebug calls GET /admin/settlements/{id}/debug: Debug settlement.
+//
+// Get a settlement and the diagnostic trail it failed with: the simulation result and revert data,
+// gas estimates, RPC errors, retries and the raw signed transaction. The trail is only kept for
+// failed and expired settlements (localhost or admin API key)
+func (c *Client) SettlementDebug(ctx context.Context, id string) (*SettlementDebug, error) {
+	var result SettlementDebug
+	if err := c.do(ctx, "GET", expandPath("/admin/settlements/{id}/debug", "id", id), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SettlementStatsParams are the query parameters of SettlementStats.
+type SettlementStatsParams struct {
+	// minute, hour (default) or day
+	Granularity string
+	// Start of the period (RFC 3339), defaults to 24 hours before to
+	From string
+	// End of the period (RFC 3339), defaults to now
+	To string
+}
+
+// SettlementStats calls GET /admin/stats: Settlement statistics.
+//
+// Count the settlements created in [from, to) by minute, hour or day with their success rate, mean
+// time to confirmation and settled volume by network and asset, for reports that don't need the raw
+// data of /admin/export. Buckets start at from truncated to the granularity, days at midnight UTC
+// (localhost or admin API key)
+func (c *Client) SettlementStats(ctx context.Context, params SettlementStatsParams) (*SettlementStats, error) {
+	var result SettlementStats
+	if err := c.do(ctx, "GET", "/admin/stats", url.Values{"granularity": {params.Granularity}, "from": {params.From}, "to": {params.To}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Supported calls GET /supported: List supported kinds.
+//
+// Get the supported payment kinds of every configured network and the facilitator signer addresses
+func (c *Client) Supported(ctx context.Context) (*SupportedResponse, error) {
+	var result SupportedResponse
+	if err := c.do(ctx, "GET", "/supported", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateAPIKey calls PUT /admin/keys/{id}: Update API key.
+//
+// Replace the name, scopes and networks of an API key, its secret stays the same. Other instances
+// apply the change within 30 seconds (localhost or admin API key)
+func (c *Client) UpdateAPIKey(ctx context.Context, id string, body *Spec) (*APIKey, error) {
+	var result APIKey
+	if err := c.do(ctx, "PUT", expandPath("/admin/keys/{id}", "id", id), nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Verify calls POST /verify: Verify payment.
+//
+// Verify a payment using the facilitator. Version 2 requests (paymentPayload instead of
+// paymentHeader) are answered with a version 2 verify response. Payments are routed by their
+// network, those on networks that aren't configured are answered with 400 and UNSUPPORTED_NETWORK.
+// Networks that verify through RPC outages answer with chainChecksSkipped while the chain can't be
+// reached. Valid results are reused for the same payload and requirements for a few seconds,
+// requests with Cache-Control: no-cache verify against the chain again
+func (c *Client) Verify(ctx context.Context, body *PaymentVerifyRequest) (*PaymentVerifyResponse, error) {
+	var result PaymentVerifyResponse
+	if err := c.do(ctx, "POST", "/verify", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Version calls GET /version: Build information.
+//
+// Get the version, commit and build date of the running facilitator
+func (c *Client) Version(ctx context.Context) (*Info, error) {
+	var result Info
+	if err := c.do(ctx, "GET", "/version", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WellKnown calls GET /.well-known/x402: Describe the facilitator.
+//
+// Get the accepted x402 versions, the supported payment kinds and the signer addresses of every
+// configured network
+func (c *Client) WellKnown(ctx context.Context) (*WellKnownResponse, error) {
+	var result WellKnownResponse
+	if err := c.do(ctx, "GET", "/.well-known/x402", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
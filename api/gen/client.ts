@@ -0,0 +1,1441 @@
+// Code generated by internal/openapi/generate from api/openapi.yaml. DO NOT EDIT.
+
+// Client of the x402 Facilitator API generated from its OpenAPI document.
+
+export interface APIKey {
+  createdAt?: string;
+  id?: string;
+  name?: string;
+  /**
+   * Networks the key may pay on, all if absent
+   */
+  networks?: string[];
+  /**
+   * When the key was revoked, absent while it is valid
+   */
+  revokedAt?: string;
+  /**
+   * Scopes granted to the key: verify, settle, read-status or admin
+   */
+  scopes?: string[];
+}
+
+export interface CostReport {
+  /**
+   * Costs by network and asset
+   */
+  entries?: CostReportEntry[];
+  from?: string;
+  to?: string;
+  /**
+   * Sum of the settled payments that could be priced in USD
+   */
+  totalAmountUsd?: number;
+  /**
+   * Sum of the fees that could be priced in USD
+   */
+  totalFeeUsd?: number;
+}
+
+export interface CostReportEntry {
+  /**
+   * Total value of the successful settlements in USD. Only present if every payment could be priced
+   */
+  amountUsd?: number;
+  /**
+   * Symbol of the paid asset, or its address if the symbol is unknown
+   */
+  asset?: string;
+  /**
+   * Total fees in atomic units of the fee currency
+   */
+  fee?: string;
+  /**
+   * Symbol of the token fees are paid in
+   */
+  feeCurrency?: string;
+  /**
+   * Total fees in the fee currency, as a decimal string
+   */
+  feeNative?: string;
+  /**
+   * Total fees in USD, priced when each transaction was mined. Only present if every fee could be
+   * priced
+   */
+  feeUsd?: number;
+  /**
+   * Total gas used
+   */
+  gasUsed?: number;
+  network?: string;
+  /**
+   * Number of settlement transactions that reverted
+   */
+  reverted?: number;
+  /**
+   * Number of mined settlement transactions, including reverted ones
+   */
+  settlements?: number;
+}
+
+export interface CreatedAPIKey {
+  createdAt?: string;
+  id?: string;
+  /**
+   * The key, sent in the X-API-Key header. It is not stored and only returned once
+   */
+  key?: string;
+  name?: string;
+  /**
+   * Networks the key may pay on, all if absent
+   */
+  networks?: string[];
+  /**
+   * When the key was revoked, absent while it is valid
+   */
+  revokedAt?: string;
+  /**
+   * Scopes granted to the key: verify, settle, read-status or admin
+   */
+  scopes?: string[];
+}
+
+export interface Dashboard {
+  /**
+   * Settlements created in the window, by minute
+   */
+  activity?: DashboardBucket[];
+  generatedAt?: string;
+  /**
+   * Settlements being submitted by a worker
+   */
+  inFlight?: number;
+  /**
+   * Settlements created in the window by network
+   */
+  networks?: DashboardNetwork[];
+  /**
+   * Networks whose settlements are paused
+   */
+  paused?: NetworkPause[];
+  /**
+   * Settlements waiting for a worker
+   */
+  queueDepth?: number;
+  /**
+   * Gas balances of the signers when they were last checked
+   */
+  signers?: DashboardSigner[];
+}
+
+export interface DashboardBucket {
+  failed?: number;
+  /**
+   * Settlements not submitted yet
+   */
+  pending?: number;
+  /**
+   * Settlements submitted, mined or confirmed
+   */
+  settled?: number;
+  start?: string;
+}
+
+export interface DashboardNetwork {
+  /**
+   * Share of the finished settlements that failed, 0 if none finished
+   */
+  errorRate?: number;
+  failed?: number;
+  network?: string;
+  pending?: number;
+  settled?: number;
+}
+
+export interface DashboardSigner {
+  balance?: number;
+  currency?: string;
+  network?: string;
+  signer?: string;
+}
+
+export interface Entry {
+  data?: Record<string, string>;
+  kind?: Kind;
+  message?: string;
+  time?: string;
+}
+
+/**
+ * Machine readable code of a payment or request error. Facilitators may report codes added later,
+ * clients should accept unknown codes
+ */
+export type ErrorCode =
+  | "invalid_payload_format"
+  | "incompatible_payload_scheme"
+  | "network_mismatch"
+  | "invalid_network"
+  | "network_id_mismatch"
+  | "invalid_signature"
+  | "invalid_token"
+  | "token_mismatch"
+  | "insufficient_balance"
+  | "rpc_anomaly"
+  | "amount_exceeds_limit"
+  | "price_unavailable"
+  | "authorization_already_used"
+  | "authorization_expired"
+  | "authorization_not_yet_valid"
+  | "insufficient_allowance"
+  | "transaction_reverted"
+  | "transfer_not_emitted"
+  | "recipient_mismatch"
+  | "spender_mismatch"
+  | "unregistered_requirements"
+  | "value_mismatch"
+  | "nonce_too_high"
+  | "fee_on_transfer_token"
+  | "gas_too_expensive"
+  | "fee_payer_insufficient_funds"
+  | "recipient_account_missing"
+  | "network_not_allowed"
+  | "asset_not_allowed"
+  | "recipient_not_allowed"
+  | "recipient_not_registered"
+  | "recipient_registry_unavailable"
+  | "compliance_rejected"
+  | "screening_unavailable"
+  | "invalid_split"
+  | "split_not_supported"
+  | "split_failed"
+  | "TIMEOUT"
+  | "CHAIN_UNAVAILABLE"
+  | "UNSUPPORTED_NETWORK";
+
+export interface ErrorResponse {
+  code?: ErrorCode | string;
+  message?: string;
+}
+
+export interface Event {
+  /**
+   * Amount paid in atomic units of the asset
+   */
+  amount?: string;
+  /**
+   * USD value of the payment at submission, present only if a price oracle is configured
+   */
+  amountUsd?: number;
+  /**
+   * Symbol of the paid asset, or its address if the symbol is unknown
+   */
+  asset?: string;
+  /**
+   * Block number the transaction was included in, once mined
+   */
+  blockNumber?: number;
+  /**
+   * Error message, if the settlement failed
+   */
+  error?: string;
+  /**
+   * Unique ID of the settlement
+   */
+  id?: string;
+  /**
+   * Network the settlement is executed on
+   */
+  network?: string;
+  /**
+   * Recipient of the payment
+   */
+  payTo?: string;
+  /**
+   * Address of the payer, if known
+   */
+  payer?: string;
+  /**
+   * Reference the resource server attached to the settle request, if any
+   */
+  reference?: string;
+  /**
+   * ID of the refund of the settlement the event is about, absent for events of the settlement
+   * itself
+   */
+  refundId?: string;
+  /**
+   * ID of the API request of the settlement, the X-Request-ID header
+   */
+  requestId?: string;
+  /**
+   * Scheme used for the settlement
+   */
+  scheme?: string;
+  /**
+   * New status of the settlement
+   */
+  status?: Status;
+  /**
+   * Tenant whose API key requested the settlement, if any
+   */
+  tenant?: string;
+  /**
+   * Time of the transition
+   */
+  timestamp?: string;
+  /**
+   * Transaction hash, once submitted
+   */
+  txHash?: string;
+}
+
+export interface FieldError {
+  /**
+   * JSON path of the field (e.g. "paymentRequirements.payTo")
+   */
+  field?: string;
+  message?: string;
+}
+
+export interface HTTPError {
+  message?: unknown;
+}
+
+export interface IndexedNetwork {
+  /**
+   * Last block whose transfers were reconciled, 0 before the first one
+   */
+  block?: number;
+  /**
+   * Error of the last indexing pass, empty if it succeeded
+   */
+  error?: string;
+  network?: string;
+}
+
+export interface Info {
+  commit?: string;
+  date?: string;
+  goVersion?: string;
+  /**
+   * Whether the working tree had uncommitted changes, known for VCS builds only
+   */
+  modified?: boolean;
+  version?: string;
+}
+
+export type Kind =
+  | "simulation"
+  | "gas_estimate"
+  | "signed_transaction"
+  | "retry"
+  | "rpc_error"
+  | "deferral"
+  | "receipt"
+  | "error";
+
+export interface NetworkPause {
+  /**
+   * CAIP-2 identifier of the network
+   */
+  network?: string;
+  paused?: boolean;
+  /**
+   * When settlements were paused, unset while they proceed
+   */
+  pausedAt?: string;
+}
+
+export interface PaymentEstimateResponse {
+  /**
+   * Error message of the failed simulation, if any
+   */
+  error?: ErrorCode | string;
+  /**
+   * Estimated gas cost in atomic units of the native token
+   */
+  gasCost?: string;
+  /**
+   * Estimated gas cost in the native token, as a decimal string
+   */
+  gasCostNative?: string;
+  /**
+   * Estimated gas cost in USD, present only if a price oracle is configured
+   */
+  gasCostUsd?: number;
+  /**
+   * Estimated gas limit of the settlement transaction
+   */
+  gasLimit?: number;
+  /**
+   * Gas price in atomic units of the native token
+   */
+  gasPrice?: string;
+  /**
+   * Symbol of the native token the gas is paid in
+   */
+  nativeCurrency?: string;
+  /**
+   * Address of the payer
+   */
+  payer?: string;
+  /**
+   * Whether the simulated settlement succeeded
+   */
+  success?: boolean;
+}
+
+export interface PaymentPayload {
+  /**
+   * Network ID of the accepted paymentRequirements the client is using to pay
+   */
+  network?: string;
+  /**
+   * Payload is E-dependent and may contain authorization and signature data
+   */
+  payload?: Record<string, unknown>;
+  /**
+   * Scheme value of the accepted paymentRequirements the client is using to pay
+   */
+  scheme?: string;
+  /**
+   * Version of the x402 payment protocol
+   */
+  x402Version?: number;
+}
+
+export interface PaymentRequirements {
+  /**
+   * Address of the EIP-3009 compliant ERC20 contract
+   */
+  asset?: string;
+  /**
+   * Description of the resource
+   */
+  description?: string;
+  /**
+   * Extra information about the payment details specific to the scheme
+   */
+  extra?: Record<string, unknown>;
+  /**
+   * Maximum amount required to pay for the resource in atomic units
+   */
+  maxAmountRequired?: string;
+  /**
+   * Maximum time in seconds for the resource server to respond
+   */
+  maxTimeoutSeconds?: number;
+  /**
+   * MIME type of the resource response
+   */
+  mimeType?: string;
+  /**
+   * Network of the blockchain to send payment on (e.g., "base-sepolia")
+   */
+  network?: string;
+  /**
+   * Output schema of the resource response (optional)
+   */
+  outputSchema?: Record<string, unknown>;
+  /**
+   * Address to pay value to
+   */
+  payTo?: string;
+  /**
+   * URL of the resource to pay for
+   */
+  resource?: string;
+  /**
+   * Scheme of the payment protocol to use (e.g., "exact")
+   */
+  scheme?: string;
+}
+
+export interface PaymentSettleRequest {
+  /**
+   * Unix time by which the settlement is submitted, at the latest when the
+   * authorization expires. The window opens at notBefore or right away
+   */
+  notAfter?: number;
+  /**
+   * Unix time before which the settlement isn't submitted. Requests with a
+   * scheduling window are answered with 202 and settled in the background
+   */
+  notBefore?: number;
+  /**
+   * Settlements of the same tenant and payee with this key are submitted one
+   * after the other in the order they arrive, at most 128 characters
+   */
+  orderingKey?: string;
+  paymentHeader?: PaymentPayload;
+  /**
+   * Requirements of the payment, required unless requirementsId is set
+   */
+  paymentRequirements?: PaymentRequirements;
+  /**
+   * Priority of the settlement when the queue is backed up, capped by the priority of the tenant.
+   * The tenant's applies if 0
+   */
+  priority?: number;
+  /**
+   * Opaque reference of the resource server, e.g. an order ID, returned in the
+   * response and, on settlement, kept with the settlement, its webhook events
+   * and receipt. At most 256 characters
+   */
+  reference?: string;
+  /**
+   * ID of requirements registered with POST /requirements, instead of paymentRequirements
+   */
+  requirementsId?: string;
+  /**
+   * Deadline of the settlement in milliseconds, capped by the server maximum. The server default
+   * applies if 0
+   */
+  timeoutMs?: number;
+  x402Version?: number;
+}
+
+export interface PaymentSettleResponse {
+  /**
+   * Value of the settled amount in USD, present only if a price oracle is configured
+   */
+  amountUsd?: number;
+  /**
+   * Error message, if any
+   */
+  error?: ErrorCode | string;
+  /**
+   * Network ID where the transaction was submitted
+   */
+  networkId?: string;
+  /**
+   * Address of the payer
+   */
+  payer?: string;
+  /**
+   * Receipt of the settlement signed by the facilitator, present only if receipts are enabled
+   */
+  receipt?: SettlementReceipt;
+  /**
+   * Reference of the request, if it had one
+   */
+  reference?: string;
+  /**
+   * Shares of a split payment sent to their recipients
+   */
+  splits?: SplitTransfer[];
+  /**
+   * Whether the payment was successful
+   */
+  success?: boolean;
+  /**
+   * Transaction hash of the settled payment
+   */
+  txHash?: string;
+}
+
+export interface PaymentVerifyRequest {
+  paymentHeader?: PaymentPayload;
+  /**
+   * Requirements of the payment, required unless requirementsId is set
+   */
+  paymentRequirements?: PaymentRequirements;
+  /**
+   * Opaque reference of the resource server, e.g. an order ID, returned in the
+   * response and, on settlement, kept with the settlement, its webhook events
+   * and receipt. At most 256 characters
+   */
+  reference?: string;
+  /**
+   * ID of requirements registered with POST /requirements, instead of paymentRequirements
+   */
+  requirementsId?: string;
+  x402Version?: number;
+}
+
+export interface PaymentVerifyResponse {
+  /**
+   * Whether the chain couldn't be reached, so only the signature and terms of
+   * the payment were checked, not the balance of the payer or whether the
+   * authorization was used
+   */
+  chainChecksSkipped?: boolean;
+  /**
+   * Error message or reason for invalidity, if applicable
+   */
+  invalidReason?: ErrorCode | string;
+  /**
+   * Whether the payment payload is valid
+   */
+  isValid?: boolean;
+  payer?: string;
+  /**
+   * Reference of the request, if it had one
+   */
+  reference?: string;
+}
+
+export interface Recipient {
+  address?: string;
+  /**
+   * Registration message and the signature of the address over it
+   */
+  message?: string;
+  network?: string;
+  registeredAt?: string;
+  /**
+   * When the registration was revoked, absent while it is valid
+   */
+  revokedAt?: string;
+  signature?: string;
+}
+
+export interface Reconciliation {
+  /**
+   * Most recent findings, newest first
+   */
+  findings?: ReconciliationFinding[];
+  /**
+   * Progress of the indexer by network
+   */
+  networks?: IndexedNetwork[];
+}
+
+export interface ReconciliationFinding {
+  /**
+   * Block of the transfer, 0 for missing receipts
+   */
+  block?: number;
+  detail?: string;
+  detectedAt?: string;
+  /**
+   * FindingOrphanedTransfer or FindingMissingReceipt
+   */
+  kind?: string;
+  network?: string;
+  /**
+   * Settlement the finding concerns, empty for transfers without one
+   */
+  settlementId?: string;
+  txHash?: string;
+}
+
+export interface Refund {
+  /**
+   * Refunded amount in atomic units of the asset
+   */
+  amount?: string;
+  asset?: string;
+  blockNumber?: number;
+  createdAt?: string;
+  error?: string;
+  /**
+   * Sender of the refund, absent for transactions sent by the payee
+   */
+  from?: string;
+  id?: string;
+  network?: string;
+  reason?: string;
+  settlementId?: string;
+  /**
+   * submitted, mined, confirmed or failed
+   */
+  status?: string;
+  to?: string;
+  txHash?: string;
+  updatedAt?: string;
+}
+
+export interface RefundRequest {
+  /**
+   * Refunded amount in atomic units of the asset
+   */
+  amount?: string;
+  /**
+   * Address of the refunded asset, the asset of the settlement if empty
+   */
+  asset?: string;
+  /**
+   * Authorization of the transfer back to the payer, mutually exclusive with TxHash
+   */
+  paymentPayload?: PaymentPayload;
+  reason?: string;
+  /**
+   * ID of the settlement the refund reverses
+   */
+  settlementId?: string;
+  /**
+   * Hash of a refund transaction sent by the payee, mutually exclusive with PaymentPayload
+   */
+  txHash?: string;
+}
+
+export interface RegisteredRequirements {
+  /**
+   * ID to send as requirementsId of verify and settle requests
+   */
+  id?: string;
+  paymentRequirements?: PaymentRequirements;
+}
+
+export interface Registration {
+  address?: string;
+  /**
+   * When the owner signed the registration message
+   */
+  issuedAt?: string;
+  /**
+   * CAIP-2 identifier of the network, e.g. "eip155:8453"
+   */
+  network?: string;
+  /**
+   * Signature of the address over Message: EIP-191 personal_sign in hex on
+   * EVM networks, ed25519 in base58 on Solana
+   */
+  signature?: string;
+}
+
+export interface Route {
+  method?: string;
+  name?: string;
+  path?: string;
+}
+
+export interface RuntimeGC {
+  /**
+   * Completed collections
+   */
+  count?: number;
+  /**
+   * Share of the CPU time used by the collector since the process started
+   */
+  cpuFraction?: number;
+  lastAt?: string;
+  /**
+   * Heap size the next collection starts at, in bytes
+   */
+  nextHeap?: number;
+  /**
+   * Total stop-the-world pause time in milliseconds
+   */
+  pauseTotalMs?: number;
+  /**
+   * Pauses of the most recent collections in milliseconds, newest first
+   */
+  recentPausesMs?: number[];
+}
+
+export interface RuntimeHeap {
+  /**
+   * Bytes of allocated heap objects
+   */
+  alloc?: number;
+  /**
+   * Bytes in spans without objects, including those returned to the OS
+   */
+  idle?: number;
+  /**
+   * Bytes in spans holding at least one object
+   */
+  inUse?: number;
+  /**
+   * Number of allocated heap objects
+   */
+  objects?: number;
+  /**
+   * Bytes returned to the OS
+   */
+  released?: number;
+  /**
+   * Bytes of heap memory obtained from the OS
+   */
+  sys?: number;
+  /**
+   * Total bytes of memory obtained from the OS, not only for the heap
+   */
+  totalSys?: number;
+}
+
+export interface RuntimeStats {
+  gc?: RuntimeGC;
+  generatedAt?: string;
+  goVersion?: string;
+  goroutines?: number;
+  heap?: RuntimeHeap;
+  /**
+   * Value of GOMAXPROCS
+   */
+  maxProcs?: number;
+  /**
+   * Open connections to the RPC endpoints by network
+   */
+  rpcConnections?: Record<string, number>;
+  /**
+   * Seconds since the process started
+   */
+  uptimeSeconds?: number;
+}
+
+export interface ScheduledSettlementResponse {
+  /**
+   * Unix time the window closes, capped by the expiry of the authorization
+   */
+  notAfter?: number;
+  /**
+   * Unix time the window opens
+   */
+  notBefore?: number;
+  /**
+   * Reference of the request, if it had one
+   */
+  reference?: string;
+  /**
+   * ID of the settlement
+   */
+  settlementId?: string;
+  /**
+   * Status of the settlement, "scheduled"
+   */
+  status?: string;
+}
+
+export interface SettlementDebug {
+  asset?: string;
+  blockNumber?: number;
+  createdAt?: string;
+  /**
+   * Steps of the settlement in the order they happened, empty unless it failed
+   */
+  diagnostics?: Entry[];
+  error?: string;
+  gasUsed?: number;
+  id?: string;
+  network?: string;
+  payer?: string;
+  /**
+   * Reference the resource server attached to the settle request
+   */
+  reference?: string;
+  /**
+   * ID of the API request of the settlement, the X-Request-ID header
+   */
+  requestId?: string;
+  reverted?: boolean;
+  scheme?: string;
+  /**
+   * queued, submitted, mined, confirmed, failed or expired
+   */
+  status?: string;
+  tenant?: string;
+  txHash?: string;
+  updatedAt?: string;
+}
+
+export interface SettlementReceipt {
+  /**
+   * Amount in atomic units of the asset
+   */
+  amount?: string;
+  /**
+   * Address of the paid asset
+   */
+  asset?: string;
+  /**
+   * CAIP-2 identifier of the network the payment was settled on
+   */
+  network?: string;
+  /**
+   * Address of the payee
+   */
+  payee?: string;
+  /**
+   * Address of the payer
+   */
+  payer?: string;
+  /**
+   * Reference the settle request carried, signed only if present
+   */
+  reference?: string;
+  /**
+   * EIP-712 signature of the signer over the receipt, hex encoded
+   */
+  signature?: string;
+  /**
+   * Address of the facilitator key that signed the receipt
+   */
+  signer?: string;
+  /**
+   * Unix time in seconds the settlement was submitted at
+   */
+  timestamp?: number;
+  /**
+   * Hash of the settlement transaction
+   */
+  txHash?: string;
+}
+
+export interface SettlementStats {
+  /**
+   * Buckets of the period in order, including empty ones
+   */
+  buckets?: SettlementStatsBucket[];
+  from?: string;
+  /**
+   * Length of the buckets: minute, hour or day
+   */
+  granularity?: string;
+  to?: string;
+}
+
+export interface SettlementStatsBucket {
+  /**
+   * Mean time from the settle request to the confirmation of confirmed settlements in milliseconds,
+   * absent if none was confirmed
+   */
+  avgLatencyMs?: number;
+  failed?: number;
+  /**
+   * Settlements not submitted yet
+   */
+  pending?: number;
+  /**
+   * Settlements submitted, mined or confirmed
+   */
+  settled?: number;
+  /**
+   * Settlements created
+   */
+  settlements?: number;
+  start?: string;
+  /**
+   * Share of the settled and failed settlements that settled, absent if none finished
+   */
+  successRate?: number;
+  /**
+   * Settled amounts by network and asset
+   */
+  volumes?: SettlementVolume[];
+}
+
+export interface SettlementVolume {
+  /**
+   * Total amount in atomic units of the asset
+   */
+  amount?: string;
+  /**
+   * Total amount in whole units, absent if the decimals of the asset are unknown
+   */
+  amountDecimal?: string;
+  /**
+   * Total value in USD at submission. Only present if every payment could be priced
+   */
+  amountUsd?: number;
+  /**
+   * Symbol of the paid asset, or its address if the symbol is unknown
+   */
+  asset?: string;
+  network?: string;
+  settlements?: number;
+}
+
+export interface Spec {
+  /**
+   * ID of the key, generated if empty. Lowercase letters, digits and dashes
+   */
+  id?: string;
+  /**
+   * Description of the key, e.g. who it was issued to
+   */
+  name?: string;
+  /**
+   * CAIP-2 identifiers or families like "eip155:*" of the networks the key may pay on, all if empty
+   */
+  networks?: string[];
+  /**
+   * Scopes granted to the key: verify, settle, read-status or admin
+   */
+  scopes?: string[];
+}
+
+export interface SplitTransfer {
+  /**
+   * Amount in atomic units of the asset
+   */
+  amount?: string;
+  recipient?: string;
+  /**
+   * Transaction of the transfer, the settlement transaction with splitter contracts
+   */
+  txHash?: string;
+}
+
+export type Status =
+  | "scheduled"
+  | "queued"
+  | "submitted"
+  | "mined"
+  | "confirmed"
+  | "failed"
+  | "expired";
+
+export interface SupportedKind {
+  /**
+   * Extra information clients need to pay with this kind (e.g. the Solana fee
+   * payer), the accepted assets under "assets"
+   */
+  extra?: Record<string, unknown>;
+  network?: string;
+  scheme?: string;
+  x402Version?: number;
+}
+
+export interface SupportedResponse {
+  kinds?: SupportedKind[];
+  /**
+   * Addresses of the facilitator signers by CAIP-2 family (e.g. "eip155:*")
+   */
+  signers?: Record<string, string[]>;
+}
+
+export interface UnsupportedNetworkResponse {
+  code?: ErrorCode | string;
+  message?: string;
+  /**
+   * CAIP-2 identifiers of the networks payments are accepted on
+   */
+  supportedNetworks?: string[];
+}
+
+export interface ValidationErrorResponse {
+  errors?: FieldError[];
+  message?: string;
+}
+
+export interface WellKnownResponse {
+  kinds?: SupportedKind[];
+  /**
+   * Addresses of the facilitator signers by CAIP-2 network (e.g. "eip155:8453")
+   */
+  signers?: Record<string, string[]>;
+  /**
+   * x402 versions the facilitator accepts
+   */
+  x402Versions?: number[];
+}
+
+/**
+ * Error of a failed request.
+ */
+export class FacilitatorError extends Error {
+  readonly status: number;
+  readonly code: string | undefined;
+  readonly body: string;
+
+  constructor(status: number, code: string | undefined, message: string, body: string) {
+    super(code ? `facilitator responded ${status} ${code}: ${message}` : `facilitator responded ${status}: ${message}`);
+    this.name = "FacilitatorError";
+    this.status = status;
+    this.code = code;
+    this.body = body;
+  }
+}
+
+export interface ClientOptions {
+  /**
+   * Sends the requests, the global fetch by default.
+   */
+  fetch?: typeof fetch;
+  /**
+   * Headers sent with every request, e.g. to authenticate them.
+   */
+  headers?: Record<string, string>;
+}
+
+/**
+ * Client calls the API of a facilitator.
+ */
+export class Client {
+  private readonly baseUrl: string;
+  private readonly options: ClientOptions;
+
+  constructor(baseUrl: string, options: ClientOptions = {}) {
+    this.baseUrl = baseUrl.replace(/\/+$/, "");
+    this.options = options;
+  }
+
+  private async request(
+    method: string,
+    path: string,
+    parse: "json" | "text" | "none",
+    query: Record<string, string | undefined> | undefined,
+    body: unknown,
+    init: RequestInit,
+  ): Promise<unknown> {
+    const params = new URLSearchParams();
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== "") {
+        params.set(key, value);
+      }
+    }
+    const search = params.toString();
+    const headers = new Headers(init.headers);
+    headers.set("Accept", "application/json");
+    for (const [key, value] of Object.entries(this.options.headers ?? {})) {
+      headers.set(key, value);
+    }
+    if (body !== undefined) {
+      headers.set("Content-Type", "application/json");
+    }
+    const send = this.options.fetch ?? fetch;
+    const response = await send(this.baseUrl + path + (search ? "?" + search : ""), {
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await response.text();
+    if (!response.ok) {
+      let code: string | undefined;
+      let message = text.trim();
+      try {
+        const decoded = JSON.parse(text);
+        if (typeof decoded?.code === "string") {
+          code = decoded.code;
+        }
+        if (typeof decoded?.message === "string") {
+          message = decoded.message;
+        }
+      } catch {
+        // the body isn't an error object
+      }
+      throw new FacilitatorError(response.status, code, message, text);
+    }
+    switch (parse) {
+      case "json":
+        return JSON.parse(text);
+      case "text":
+        return text;
+      default:
+        return undefined;
+    }
+  }
+
+  /**
+   * GET /admin/config: Configuration
+   * Get the merged configuration of defaults, file and environment with secrets redacted (localhost
+   * or admin API key)
+   */
+  async config(init: RequestInit = {}): Promise<Record<string, unknown>> {
+    return (await this.request("GET", `/admin/config`, "json", undefined, undefined, init)) as Record<string, unknown>;
+  }
+
+  /**
+   * GET /admin/costs: Settlement cost report
+   * Sum the gas used and fees paid for the settlements created in [from, to) by network and asset
+   * (localhost or admin API key)
+   */
+  async costs(query: { from?: string; to?: string } = {}, init: RequestInit = {}): Promise<CostReport> {
+    return (await this.request("GET", `/admin/costs`, "json", query, undefined, init)) as CostReport;
+  }
+
+  /**
+   * POST /admin/keys: Create API key
+   * Create an API key granting the scopes, optionally limited to some networks. The key is returned
+   * once, only the hash of its secret is stored (localhost or admin API key)
+   */
+  async createApiKey(body: Spec, init: RequestInit = {}): Promise<CreatedAPIKey> {
+    return (await this.request("POST", `/admin/keys`, "json", undefined, body, init)) as CreatedAPIKey;
+  }
+
+  /**
+   * POST /admin/refunds: Refund settlement
+   * Record a refund of a settlement to its payer. Either submit an EIP-3009 authorization of the
+   * payee transferring the amount to the payer, which is settled like a payment, or the hash of a
+   * refund transaction the payee sent. The refund is tracked until it is confirmed or fails, and
+   * its transitions are published as settlement events carrying the refund ID to the websocket
+   * stream and webhooks (localhost or admin API key)
+   */
+  async createRefund(body: RefundRequest, init: RequestInit = {}): Promise<Refund> {
+    return (await this.request("POST", `/admin/refunds`, "json", undefined, body, init)) as Refund;
+  }
+
+  /**
+   * GET /admin/dashboard: Settlement dashboard
+   * Serve an HTML page showing settlement throughput, error rates, queue depth and signer balances,
+   * refreshed every few seconds (localhost or admin API key)
+   */
+  async dashboard(init: RequestInit = {}): Promise<string> {
+    return (await this.request("GET", `/admin/dashboard`, "text", undefined, undefined, init)) as string;
+  }
+
+  /**
+   * GET /admin/dashboard/data: Dashboard data
+   * Count the settlements of the last hour by minute and by network, and report the settlement
+   * queue, signer gas balances and paused networks (localhost or admin API key)
+   */
+  async dashboardData(init: RequestInit = {}): Promise<Dashboard> {
+    return (await this.request("GET", `/admin/dashboard/data`, "json", undefined, undefined, init)) as Dashboard;
+  }
+
+  /**
+   * POST /settle/estimate: Estimate settlement
+   * Simulate a settlement without broadcasting it and estimate its gas cost
+   */
+  async estimateSettle(body: PaymentSettleRequest, init: RequestInit = {}): Promise<PaymentEstimateResponse> {
+    return (await this.request("POST", `/settle/estimate`, "json", undefined, body, init)) as PaymentEstimateResponse;
+  }
+
+  /**
+   * GET /admin/export: Export settlements
+   * Stream the settlements created in [from, to) as CSV or Parquet, oldest first: status, payer,
+   * recipient, asset, amount, transaction hash, gas used, gas price and fee. Amounts are in atomic
+   * units, and in whole units if the decimals of the asset are known (localhost or admin API key)
+   */
+  async exportSettlements(query: { format?: string; from?: string; to?: string } = {}, init: RequestInit = {}): Promise<string> {
+    return (await this.request("GET", `/admin/export`, "text", query, undefined, init)) as string;
+  }
+
+  /**
+   * GET /admin/keys/{id}: Get API key
+   * Get an API key, without its secret (localhost or admin API key)
+   */
+  async getApiKey(id: string, init: RequestInit = {}): Promise<APIKey> {
+    return (await this.request("GET", `/admin/keys/${encodeURIComponent(id)}`, "json", undefined, undefined, init)) as APIKey;
+  }
+
+  /**
+   * GET /admin/refunds/{id}: Get refund
+   * Get a refund and its progress (localhost or admin API key)
+   */
+  async getRefund(id: string, init: RequestInit = {}): Promise<Refund> {
+    return (await this.request("GET", `/admin/refunds/${encodeURIComponent(id)}`, "json", undefined, undefined, init)) as Refund;
+  }
+
+  /**
+   * GET /requirements/{id}: Get requirements
+   * Get the payment requirements registered under the ID
+   */
+  async getRequirements(id: string, init: RequestInit = {}): Promise<RegisteredRequirements> {
+    return (await this.request("GET", `/requirements/${encodeURIComponent(id)}`, "json", undefined, undefined, init)) as RegisteredRequirements;
+  }
+
+  /**
+   * GET /admin/keys: List API keys
+   * List all API keys, including revoked ones, oldest first (localhost or admin API key)
+   */
+  async listApiKeys(init: RequestInit = {}): Promise<APIKey[]> {
+    return (await this.request("GET", `/admin/keys`, "json", undefined, undefined, init)) as APIKey[];
+  }
+
+  /**
+   * GET /admin/recipients: List recipients
+   * List the registered recipients of all networks, including revoked ones, oldest first (localhost
+   * or admin API key)
+   */
+  async listRecipients(init: RequestInit = {}): Promise<Recipient[]> {
+    return (await this.request("GET", `/admin/recipients`, "json", undefined, undefined, init)) as Recipient[];
+  }
+
+  /**
+   * GET /admin/refunds: List refunds
+   * List the refunds of a settlement, or of all settlements, oldest first (localhost or admin API
+   * key)
+   */
+  async listRefunds(query: { settlementId?: string } = {}, init: RequestInit = {}): Promise<Refund[]> {
+    return (await this.request("GET", `/admin/refunds`, "json", query, undefined, init)) as Refund[];
+  }
+
+  /**
+   * GET /debug/routes: List routes
+   * List all routes registered on the server (localhost only)
+   */
+  async listRoutes(init: RequestInit = {}): Promise<Route[]> {
+    return (await this.request("GET", `/debug/routes`, "json", undefined, undefined, init)) as Route[];
+  }
+
+  /**
+   * GET /openapi.yaml: OpenAPI document
+   * Get the OpenAPI 3.1 document of the API, which the clients under api/gen are generated from
+   */
+  async openAPI(init: RequestInit = {}): Promise<string> {
+    return (await this.request("GET", `/openapi.yaml`, "text", undefined, undefined, init)) as string;
+  }
+
+  /**
+   * POST /admin/networks/{network}/pause: Pause network
+   * Stop settling payments on a network, e.g. during a reorg or an incident of its RPC endpoints.
+   * Settle requests are answered with 503 and settlements still queued fail without being
+   * broadcast, while payments are still verified. /supported lists the network with "paused" in its
+   * extra. The pause lasts until the network is resumed or the instance restarts, and applies to
+   * this instance only (localhost or admin API key)
+   */
+  async pauseNetwork(network: string, init: RequestInit = {}): Promise<NetworkPause> {
+    return (await this.request("POST", `/admin/networks/${encodeURIComponent(network)}/pause`, "json", undefined, undefined, init)) as NetworkPause;
+  }
+
+  /**
+   * GET /receipts/{txHash}: Settlement receipt
+   * Get the receipt of a settlement signed by the facilitator. The signature is an EIP-712
+   * signature of the signer over the receipt in the domain {name: "x402 facilitator receipt",
+   * version: "1"}, of the primary type Receipt, or ReferencedReceipt for settlements with a
+   * reference, see package receipt
+   */
+  async receipt(txHash: string, init: RequestInit = {}): Promise<SettlementReceipt> {
+    return (await this.request("GET", `/receipts/${encodeURIComponent(txHash)}`, "json", undefined, undefined, init)) as SettlementReceipt;
+  }
+
+  /**
+   * GET /admin/reconciliation: Reconciliation findings
+   * Get the progress of the indexer per network and the most recent transfers of the signers
+   * without a settlement on record and settlements whose transaction wasn't mined, newest first
+   * (localhost or admin API key)
+   */
+  async reconciliation(init: RequestInit = {}): Promise<Reconciliation> {
+    return (await this.request("GET", `/admin/reconciliation`, "json", undefined, undefined, init)) as Reconciliation;
+  }
+
+  /**
+   * POST /admin/recipients: Register recipient
+   * Register an address to receive payments on networks that only pay registered recipients. The
+   * signature proves ownership of the address: it signs the message "x402 facilitator recipient
+   * registration\nNetwork: <network>\nAddress: <address>\nIssued At: <issuedAt in RFC 3339, UTC>"
+   * with EIP-191 personal_sign (hex) on EVM networks or ed25519 (base58) on Solana (localhost or
+   * admin API key)
+   */
+  async registerRecipient(body: Registration, init: RequestInit = {}): Promise<Recipient> {
+    return (await this.request("POST", `/admin/recipients`, "json", undefined, body, init)) as Recipient;
+  }
+
+  /**
+   * POST /requirements: Register requirements
+   * Register payment requirements and get their ID, which verify and settle requests can send as
+   * requirementsId instead of paymentRequirements. Registering the same requirements again returns
+   * the same ID and renews them. IDs are only valid for the tenant that registered them and expire
+   * a TTL after the last registration
+   */
+  async registerRequirements(body: PaymentRequirements, init: RequestInit = {}): Promise<RegisteredRequirements> {
+    return (await this.request("POST", `/requirements`, "json", undefined, body, init)) as RegisteredRequirements;
+  }
+
+  /**
+   * POST /admin/networks/{network}/resume: Resume network
+   * Settle payments on a paused network again (localhost or admin API key)
+   */
+  async resumeNetwork(network: string, init: RequestInit = {}): Promise<NetworkPause> {
+    return (await this.request("POST", `/admin/networks/${encodeURIComponent(network)}/resume`, "json", undefined, undefined, init)) as NetworkPause;
+  }
+
+  /**
+   * DELETE /admin/keys/{id}: Revoke API key
+   * Revoke an API key, requests carrying it are rejected afterwards. Other instances apply the
+   * revocation within 30 seconds (localhost or admin API key)
+   */
+  async revokeApiKey(id: string, init: RequestInit = {}): Promise<void> {
+    return (await this.request("DELETE", `/admin/keys/${encodeURIComponent(id)}`, "none", undefined, undefined, init)) as void;
+  }
+
+  /**
+   * DELETE /admin/recipients/{network}/{address}: Revoke recipient
+   * Revoke the registration of an address, payments to it are rejected afterwards. Registering it
+   * again needs a message issued after the revocation (localhost or admin API key)
+   */
+  async revokeRecipient(network: string, address: string, init: RequestInit = {}): Promise<void> {
+    return (await this.request("DELETE", `/admin/recipients/${encodeURIComponent(network)}/${encodeURIComponent(address)}`, "none", undefined, undefined, init)) as void;
+  }
+
+  /**
+   * GET /admin/runtime: Runtime diagnostics
+   * Report the goroutine count, heap statistics, recent GC pauses and open RPC connections by
+   * network. CPU, heap, goroutine and other profiles are served by net/http/pprof under
+   * /admin/debug/pprof/ and runtime variables by expvar at /admin/debug/vars (localhost or admin
+   * API key)
+   */
+  async runtime(init: RequestInit = {}): Promise<RuntimeStats> {
+    return (await this.request("GET", `/admin/runtime`, "json", undefined, undefined, init)) as RuntimeStats;
+  }
+
+  /**
+   * POST /settle: Settle payment
+   * Settle a payment using the facilitator. Version 2 requests (paymentPayload instead of
+   * paymentHeader) are answered with a version 2 settle response. Payments are routed by their
+   * network, those on networks that aren't configured are answered with 400 and
+   * UNSUPPORTED_NETWORK. Requests with a scheduling window (notBefore or notAfter) are verified,
+   * answered with 202, or 200 and a failed settle response if the payment is rejected, and settled
+   * in the background within the window, once the gas price of the network falls to its threshold
+   * or the window closes. Their outcome is published as settlement events
+   */
+  async settle(body: PaymentSettleRequest, init: RequestInit = {}): Promise<PaymentSettleResponse> {
+    return (await this.request("POST", `/settle`, "json", undefined, body, init)) as PaymentSettleResponse;
+  }
+
+  /**
+   * GET /admin/settlements/{id}/debug: Debug settlement
+   * Get a settlement and the diagnostic trail it failed with: the simulation result and revert
+   * data, gas estimates, RPC errors, retries and the raw signed transaction. The trail is only kept
+   * for failed and expired settlements (localhost or admin API key)
+   */
+  async settlementDebug(id: string, init: RequestInit = {}): Promise<SettlementDebug> {
+    return (await this.request("GET", `/admin/settlements/${encodeURIComponent(id)}/debug`, "json", undefined, undefined, init)) as SettlementDebug;
+  }
+
+  /**
+   * GET /admin/stats: Settlement statistics
+   * Count the settlements created in [from, to) by minute, hour or day with their success rate,
+   * mean time to confirmation and settled volume by network and asset, for reports that don't need
+   * the raw data of /admin/export. Buckets start at from truncated to the granularity, days at
+   * midnight UTC (localhost or admin API key)
+   */
+  async settlementStats(query: { granularity?: string; from?: string; to?: string } = {}, init: RequestInit = {}): Promise<SettlementStats> {
+    return (await this.request("GET", `/admin/stats`, "json", query, undefined, init)) as SettlementStats;
+  }
+
+  /**
+   * GET /supported: List supported kinds
+   * Get the supported payment kinds of every configured network and the facilitator signer
+   * addresses
+   */
+  async supported(init: RequestInit = {}): Promise<SupportedResponse> {
+    return (await this.request("GET", `/supported`, "json", undefined, undefined, init)) as SupportedResponse;
+  }
+
+  /**
+   * PUT /admin/keys/{id}: Update API key
+   * Replace the name, scopes and networks of an API key, its secret stays the same. Other instances
+   * apply the change within 30 seconds (localhost or admin API key)
+   */
+  async updateApiKey(id: string, body: Spec, init: RequestInit = {}): Promise<APIKey> {
+    return (await this.request("PUT", `/admin/keys/${encodeURIComponent(id)}`, "json", undefined, body, init)) as APIKey;
+  }
+
+  /**
+   * POST /verify: Verify payment
+   * Verify a payment using the facilitator. Version 2 requests (paymentPayload instead of
+   * paymentHeader) are answered with a version 2 verify response. Payments are routed by their
+   * network, those on networks that aren't configured are answered with 400 and
+   * UNSUPPORTED_NETWORK. Networks that verify through RPC outages answer with chainChecksSkipped
+   * while the chain can't be reached. Valid results are reused for the same payload and
+   * requirements for a few seconds, requests with Cache-Control: no-cache verify against the chain
+   * again
+   */
+  async verify(body: PaymentVerifyRequest, init: RequestInit = {}): Promise<PaymentVerifyResponse> {
+    return (await this.request("POST", `/verify`, "json", undefined, body, init)) as PaymentVerifyResponse;
+  }
+
+  /**
+   * GET /version: Build information
+   * Get the version, commit and build date of the running facilitator
+   */
+  async version(init: RequestInit = {}): Promise<Info> {
+    return (await this.request("GET", `/version`, "json", undefined, undefined, init)) as Info;
+  }
+
+  /**
+   * GET /.well-known/x402: Describe the facilitator
+   * Get the accepted x402 versions, the supported payment kinds and the signer addresses of every
+   * configured network
+   */
+  async wellKnown(init: RequestInit = {}): Promise<WellKnownResponse> {
+    return (await this.request("GET", `/.well-known/x402`, "json", undefined, undefined, init)) as WellKnownResponse;
+  }
+
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"cmp"
+	"time"
+
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+// Defaults of the security headers
+const (
+	defaultFrameOptions   = "DENY"
+	defaultReferrerPolicy = "no-referrer"
+)
+
+// CORSConfig controls which browser origins may call the API. The zero value
+// allows every origin, as the server did before this was configurable.
+type CORSConfig struct {
+	// Omits the CORS headers, so browsers refuse cross-origin calls. For private
+	// deployments that are only called by servers
+	Disabled bool `mapstructure:"disabled"`
+	// Origins allowed to call the API, all if empty
+	AllowOrigins []string `mapstructure:"allowOrigins"`
+	// Methods allowed in cross-origin calls, the common methods if empty
+	AllowMethods []string `mapstructure:"allowMethods"`
+	// Request headers allowed in cross-origin calls, those the browser asks for if empty
+	AllowHeaders []string `mapstructure:"allowHeaders"`
+	// Allows cross-origin calls with cookies and HTTP authentication. Requires explicit origins
+	AllowCredentials bool `mapstructure:"allowCredentials"`
+	// How long browsers may cache the result of a preflight request, not cached if 0
+	MaxAge time.Duration `mapstructure:"maxAge"`
+}
+
+func (c CORSConfig) echoConfig() echomiddleware.CORSConfig {
+	return echomiddleware.CORSConfig{
+		AllowOrigins:     c.AllowOrigins,
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int(c.MaxAge.Seconds()),
+	}
+}
+
+// WithCORS replaces the default CORS policy, which allows every origin.
+func WithCORS(config CORSConfig) Option {
+	return func(s *Server) {
+		s.cors = config
+	}
+}
+
+// SecurityHeadersConfig sets the security headers of every response. Zero values
+// select the defaults, X-Content-Type-Options is always nosniff.
+type SecurityHeadersConfig struct {
+	// max-age of the Strict-Transport-Security header, which is only sent on
+	// HTTPS requests, including those a proxy marks with X-Forwarded-Proto. Not sent if 0
+	HSTSMaxAge time.Duration `mapstructure:"hstsMaxAge"`
+	// Leaves subdomains out of the HSTS policy
+	HSTSExcludeSubdomains bool `mapstructure:"hstsExcludeSubdomains"`
+	// Asks browsers to include the domain in their HSTS preload lists
+	HSTSPreload bool `mapstructure:"hstsPreload"`
+	// Value of the X-Frame-Options header, DENY by default
+	FrameOptions string `mapstructure:"frameOptions"`
+	// Value of the Referrer-Policy header, no-referrer by default
+	ReferrerPolicy string `mapstructure:"referrerPolicy"`
+	// Value of the Content-Security-Policy header, not sent if empty
+	ContentSecurityPolicy string `mapstructure:"contentSecurityPolicy"`
+}
+
+func (c SecurityHeadersConfig) withDefaults() SecurityHeadersConfig {
+	c.FrameOptions = cmp.Or(c.FrameOptions, defaultFrameOptions)
+	c.ReferrerPolicy = cmp.Or(c.ReferrerPolicy, defaultReferrerPolicy)
+	return c
+}
+
+func (c SecurityHeadersConfig) echoConfig() echomiddleware.SecureConfig {
+	return echomiddleware.SecureConfig{
+		ContentTypeNosniff:    "nosniff",
+		XFrameOptions:         c.FrameOptions,
+		HSTSMaxAge:            int(c.HSTSMaxAge.Seconds()),
+		HSTSExcludeSubdomains: c.HSTSExcludeSubdomains,
+		HSTSPreloadEnabled:    c.HSTSPreload,
+		ContentSecurityPolicy: c.ContentSecurityPolicy,
+		ReferrerPolicy:        c.ReferrerPolicy,
+	}
+}
+
+// WithSecurityHeaders overrides the default security headers.
+func WithSecurityHeaders(config SecurityHeadersConfig) Option {
+	return func(s *Server) {
+		s.securityHeaders = config.withDefaults()
+	}
+}
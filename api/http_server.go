@@ -0,0 +1,83 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"time"
+)
+
+// Defaults of the HTTP server, chosen so that clients sending or reading
+// slowly can't hold connections open indefinitely
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	// longer than the default maximum settle deadline
+	defaultWriteTimeout   = 3 * time.Minute
+	defaultIdleTimeout    = 2 * time.Minute
+	defaultMaxHeaderBytes = 64 << 10
+)
+
+// DefaultShutdownTimeout is how long a stopping process waits for requests and
+// settlements by default
+const DefaultShutdownTimeout = time.Minute
+
+// HTTPServerConfig configures the limits of the HTTP server. Zero values
+// select the defaults.
+type HTTPServerConfig struct {
+	// How long reading the request headers may take
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout"`
+	// How long reading the whole request may take
+	ReadTimeout time.Duration `mapstructure:"readTimeout"`
+	// How long a request may take from the end of its headers to the end of the
+	// response. Must exceed the settle deadlines, websockets aren't affected
+	WriteTimeout time.Duration `mapstructure:"writeTimeout"`
+	// How long idle keep-alive connections are kept open
+	IdleTimeout time.Duration `mapstructure:"idleTimeout"`
+	// Maximum size of the request headers
+	MaxHeaderBytes int `mapstructure:"maxHeaderBytes"`
+	// Serves HTTP/1.1 only. By default clients may also speak HTTP/2 without
+	// TLS (h2c) with prior knowledge, multiplexing requests over one connection
+	DisableHTTP2 bool `mapstructure:"disableHTTP2"`
+	// Binds the port with SO_REUSEPORT, so the process of an upgrade can bind it
+	// while the old one is still serving. Sockets passed by systemd socket
+	// activation are used regardless
+	ReusePort bool `mapstructure:"reusePort"`
+	// How long a stopping process waits for the requests and settlements it
+	// handles to finish, 0 means a minute. Settlements unfinished by then are
+	// resumed by the next process
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
+}
+
+func (c HTTPServerConfig) withDefaults() HTTPServerConfig {
+	return HTTPServerConfig{
+		ReadHeaderTimeout: cmp.Or(c.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       cmp.Or(c.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:      cmp.Or(c.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       cmp.Or(c.IdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes:    cmp.Or(c.MaxHeaderBytes, defaultMaxHeaderBytes),
+		DisableHTTP2:      c.DisableHTTP2,
+		ReusePort:         c.ReusePort,
+		ShutdownTimeout:   cmp.Or(c.ShutdownTimeout, DefaultShutdownTimeout),
+	}
+}
+
+// NewHTTPServer creates an HTTP server listening on addr with the limits and
+// protocols of the config.
+func NewHTTPServer(addr string, handler http.Handler, config HTTPServerConfig) *http.Server {
+	config = config.withDefaults()
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	if !config.DisableHTTP2 {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+		Protocols:         protocols,
+	}
+}
@@ -0,0 +1,2330 @@
+package api_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/gosuda/x402-facilitator/api"
+	"github.com/gosuda/x402-facilitator/api/client"
+	"github.com/gosuda/x402-facilitator/api/gen"
+	"github.com/gosuda/x402-facilitator/api/middleware"
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/attestation"
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/internal/version"
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/receipt"
+	"github.com/gosuda/x402-facilitator/recipient"
+	"github.com/gosuda/x402-facilitator/requirement"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/tenant"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	testNetwork  = "eip155:84532"
+	testChain    = "base-sepolia"
+	testToken    = "USDC"
+	testSigner   = "0x00000000000000000000000000000000000000fa"
+	testPayTo    = "0x00000000000000000000000000000000000000b0"
+	testAmount   = 10_000
+	eventTimeout = 10 * time.Second
+)
+
+// testEnv runs the HTTP server on top of a registry whose EVM facilitator talks to a mock chain.
+type testEnv struct {
+	chain       *mock.EVMSigner
+	client      *client.Client
+	handler     http.Handler
+	settlements *settlement.Manager
+
+	token  string
+	signer types.Signer
+	payer  string
+}
+
+func newTestEnv(t *testing.T, confirmations uint64) *testEnv {
+	return newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), confirmations)
+}
+
+func newTestEnvOnChain(t *testing.T, chain *mock.EVMSigner, confirmations uint64, opts ...api.Option) *testEnv {
+	return newTestEnvWithStore(t, chain, confirmations, store.NewMemory(), opts...)
+}
+
+func newTestEnvWithStore(t *testing.T, chain *mock.EVMSigner, confirmations uint64, records store.Store, opts ...api.Option) *testEnv {
+	return newTestEnvWithManager(t, chain, confirmations, records, nil, opts...)
+}
+
+func newTestEnvWithManager(t *testing.T, chain *mock.EVMSigner, confirmations uint64, records store.Store, managerOpts []settlement.Option, opts ...api.Option) *testEnv {
+	t.Helper()
+	config := facilitator.NetworkConfig{Network: testNetwork, Confirmations: confirmations, AcceptNative: true}
+	return newTestEnvWithConfig(t, chain, config, records, managerOpts, opts...)
+}
+
+// newTestEnvWithConfig is newTestEnvWithManager with the configuration of the test network.
+func newTestEnvWithConfig(t *testing.T, chain *mock.EVMSigner, config facilitator.NetworkConfig, records store.Store, managerOpts []settlement.Option, opts ...api.Option) *testEnv {
+	t.Helper()
+
+	require.NoError(t, config.Normalize())
+
+	evmFacilitator, err := facilitator.NewEVMFacilitatorWithSigner(config, chain)
+	require.NoError(t, err)
+
+	priceOracle := oracle.NewStatic(map[string]float64{"ETH": 2500, testToken: 1})
+	registry := facilitator.NewRegistry()
+	registry.SetPriceOracle(priceOracle)
+	require.NoError(t, registry.Register(config, evmFacilitator))
+	settlements := settlement.NewManager(registry, records, managerOpts...)
+	t.Cleanup(settlements.Close)
+
+	handler := api.NewServer(registry, settlements, priceOracle, opts...)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := client.NewClient(srv.URL)
+	require.NoError(t, err)
+
+	privKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(privKey.Serialize())
+	require.NoError(t, err)
+
+	env := &testEnv{
+		chain:       chain,
+		client:      c,
+		handler:     handler,
+		settlements: settlements,
+		token:       evm.GetDomainConfig(testChain, testToken).VerifyingContract.Hex(),
+		signer:      evm.NewRawPrivateSigner(privKey.Serialize()),
+		payer:       payer.Hex(),
+	}
+	chain.SetBalance(env.token, env.payer, big.NewInt(testAmount))
+	return env
+}
+
+// payment creates a payment of the amount signed by the payer.
+func (e *testEnv) payment(t *testing.T, amount int64) (*types.PaymentPayload, *types.PaymentRequirements) {
+	t.Helper()
+
+	evmPayload, err := evm.NewEVMPayload(testChain, testToken, e.payer, testPayTo, big.NewInt(amount).String(), e.signer)
+	require.NoError(t, err)
+	evmPayloadJson, err := json.Marshal(evmPayload)
+	require.NoError(t, err)
+
+	payload := &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      string(types.EVM),
+		Network:     testNetwork,
+		Payload:     evmPayloadJson,
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           testNetwork,
+		MaxAmountRequired: big.NewInt(amount).String(),
+		PayTo:             testPayTo,
+		Asset:             e.token,
+	}
+	return payload, req
+}
+
+// waitStatus waits for a settlement event of the transaction with the status.
+func waitStatus(t *testing.T, events <-chan settlement.Event, txHash string, status settlement.Status) settlement.Event {
+	t.Helper()
+
+	timeout := time.After(eventTimeout)
+	for {
+		select {
+		case evt := <-events:
+			if evt.TxHash != txHash {
+				continue
+			}
+			if evt.Status == status {
+				return evt
+			}
+			require.False(t, evt.Status.IsFinal(), "settlement ended as %s: %s", evt.Status, evt.Error)
+		case <-timeout:
+			t.Fatalf("timed out waiting for settlement %s to become %s", txHash, status)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	env := newTestEnv(t, 1)
+
+	supported, err := env.client.Supported(t.Context())
+	require.NoError(t, err)
+	require.Len(t, supported.Kinds, len(types.SupportedX402Versions))
+	require.Equal(t, testNetwork, supported.Kinds[0].Network)
+	require.Equal(t, []string{env.chain.GetAddresses()[0]}, supported.Signers["eip155:*"])
+	assets, err := supported.Kinds[0].Assets()
+	require.NoError(t, err)
+	require.Contains(t, assets, types.SupportedAsset{
+		Address:        env.token,
+		Symbol:         testToken,
+		Decimals:       6,
+		TransferMethod: types.TransferMethodEIP3009,
+		Name:           "USDC",
+		Version:        "2",
+	})
+	require.Equal(t, types.SupportedAsset{
+		Address:        evm.NativeAsset,
+		Symbol:         "ETH",
+		Decimals:       18,
+		TransferMethod: types.TransferMethodTransaction,
+	}, assets[len(assets)-1], "the native currency is listed last")
+
+	wellKnown, err := env.client.WellKnown(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []int{int(types.X402VersionV1), int(types.X402VersionV2)}, wellKnown.X402Versions)
+	require.Equal(t, supported.Kinds, wellKnown.Kinds)
+	require.Equal(t, map[string][]string{testNetwork: {env.chain.GetAddresses()[0]}}, wellKnown.Signers)
+
+	resp, err := http.Get(env.client.BaseURL.JoinPath("/version").String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var build version.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&build))
+	require.Equal(t, version.Version, build.Version)
+	require.NotEmpty(t, build.GoVersion)
+}
+
+func TestVerifySettle(t *testing.T) {
+	env := newTestEnv(t, 1)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	payload, req := env.payment(t, testAmount)
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	require.Equal(t, env.payer, verified.Payer)
+
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.Equal(t, "84532", settled.NetworkId)
+	require.NotNil(t, settled.AmountUsd)
+	require.InDelta(t, 0.01, *settled.AmountUsd, 1e-9)
+
+	confirmed := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+	require.Equal(t, testToken, confirmed.Asset)
+	require.Equal(t, settled.AmountUsd, confirmed.AmountUSD)
+	require.Zero(t, env.chain.Balance(env.token, env.payer).Sign())
+	require.Equal(t, int64(testAmount), env.chain.Balance(env.token, testPayTo).Int64())
+}
+
+func TestRequirementIDs(t *testing.T) {
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithRequirements(requirement.New(store.NewMemory(), requirement.Config{})))
+	payload, req := env.payment(t, testAmount)
+
+	id, err := env.client.RegisterRequirements(t.Context(), req)
+	require.NoError(t, err)
+	verified, err := env.client.VerifyRegistered(t.Context(), payload, id)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	settled, err := env.client.SettleRegistered(t.Context(), payload, id)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+
+	_, err = env.client.VerifyRegistered(t.Context(), payload, "req_unknown")
+	require.ErrorContains(t, err, "is not a registered requirements ID")
+
+	// requirements are either registered or sent
+	body, err := json.Marshal(types.PaymentVerifyRequest{
+		X402Version: int(types.X402VersionV1), PaymentHeader: *payload, PaymentRequirements: *req, RequirementsID: id,
+	})
+	require.NoError(t, err)
+	resp, err := http.Post(env.client.BaseURL.JoinPath("/verify").String(), "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp, err = http.Get(env.client.BaseURL.JoinPath("/requirements", id).String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var registered types.RegisteredRequirements
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&registered))
+	require.Equal(t, *req, registered.PaymentRequirements)
+}
+
+func TestPermitRequirements(t *testing.T) {
+	const permitToken = "0x00000000000000000000000000000000000000c0"
+	chain := mock.NewEVMSigner(84532, testSigner)
+	config := facilitator.NetworkConfig{Network: testNetwork, Confirmations: 1, Assets: []facilitator.AssetConfig{{
+		Symbol:         "PRM",
+		Address:        permitToken,
+		Decimals:       6,
+		Name:           "Permit Token",
+		Version:        "1",
+		TransferMethod: types.TransferMethodEIP2612,
+	}}}
+	env := newTestEnvWithConfig(t, chain, config, store.NewMemory(), nil, api.WithRequirements(requirement.New(store.NewMemory(), requirement.Config{})))
+	chain.SetBalance(permitToken, env.payer, big.NewInt(testAmount))
+
+	permit := &evm.Permit{
+		Owner:    common.HexToAddress(env.payer),
+		Spender:  common.HexToAddress(testSigner),
+		Value:    big.NewInt(testAmount),
+		Nonce:    big.NewInt(0),
+		Deadline: big.NewInt(time.Now().Add(time.Hour).Unix()),
+	}
+	permitPayload, err := evm.NewPermitPayload(permit, evm.NewDomainConfig("Permit Token", "1", big.NewInt(84532), permitToken), env.signer)
+	require.NoError(t, err)
+	raw, err := json.Marshal(permitPayload)
+	require.NoError(t, err)
+	payload := &types.PaymentPayload{X402Version: int(types.X402VersionV1), Scheme: string(types.EVM), Network: testNetwork, Payload: raw}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           testNetwork,
+		MaxAmountRequired: strconv.Itoa(testAmount),
+		PayTo:             testPayTo,
+		Asset:             permitToken,
+	}
+
+	// permits don't name the recipient, requirements sent along could pay anyone
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, verified.IsValid)
+	require.Equal(t, types.ErrUnregisteredRequirements.Error(), verified.InvalidReason)
+
+	id, err := env.client.RegisterRequirements(t.Context(), req)
+	require.NoError(t, err)
+	verified, err = env.client.VerifyRegistered(t.Context(), payload, id)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	settled, err := env.client.SettleRegistered(t.Context(), payload, id)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.Equal(t, big.NewInt(testAmount), chain.Balance(permitToken, testPayTo))
+}
+
+func TestReceipts(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := facilitator.NewPrivateKeySigner(crypto.FromECDSA(key))
+	require.NoError(t, err)
+	records := store.NewMemory()
+	issuer := receipt.NewIssuer(signer, records)
+	env := newTestEnvWithManager(t, mock.NewEVMSigner(84532, testSigner), 1, records,
+		[]settlement.Option{settlement.WithReceipts(issuer)}, api.WithReceipts(issuer))
+
+	payload, req := env.payment(t, testAmount)
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.NotNil(t, settled.Receipt)
+	require.Equal(t, env.payer, settled.Receipt.Payer)
+	require.Equal(t, testPayTo, settled.Receipt.Payee)
+	require.Equal(t, "10000", settled.Receipt.Amount)
+	require.Equal(t, settled.TxHash, settled.Receipt.TxHash)
+	require.Equal(t, signer.Address().Hex(), settled.Receipt.Signer)
+	require.NoError(t, receipt.Verify(settled.Receipt))
+
+	fetched, err := env.client.Receipt(t.Context(), settled.TxHash)
+	require.NoError(t, err)
+	require.Equal(t, settled.Receipt, fetched)
+
+	_, err = env.client.Receipt(t.Context(), "0x01")
+	require.ErrorContains(t, err, "status 404")
+}
+
+func TestReferences(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := facilitator.NewPrivateKeySigner(crypto.FromECDSA(key))
+	require.NoError(t, err)
+	records := store.NewMemory()
+	issuer := receipt.NewIssuer(signer, records)
+	env := newTestEnvWithManager(t, mock.NewEVMSigner(84532, testSigner), 1, records,
+		[]settlement.Option{settlement.WithReceipts(issuer)}, api.WithReceipts(issuer))
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	ctx := client.WithReference(t.Context(), "order-1")
+	payload, req := env.payment(t, testAmount)
+	verified, err := env.client.Verify(ctx, payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	require.Equal(t, "order-1", verified.Reference)
+
+	settled, err := env.client.Settle(ctx, payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.Equal(t, "order-1", settled.Reference)
+	require.Equal(t, "order-1", settled.Receipt.Reference)
+	require.NoError(t, receipt.Verify(settled.Receipt))
+
+	confirmed := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+	require.Equal(t, "order-1", confirmed.Reference)
+	record, err := records.GetSettlement(t.Context(), confirmed.ID)
+	require.NoError(t, err)
+	require.Equal(t, "order-1", record.Reference)
+
+	t.Run("version 2", func(t *testing.T) {
+		requirements := sdk.PaymentRequirements{
+			Scheme:            sdk.SchemeExact,
+			Network:           testNetwork,
+			Asset:             req.Asset,
+			Amount:            req.MaxAmountRequired,
+			PayTo:             req.PayTo,
+			MaxTimeoutSeconds: 60,
+		}
+		body, err := json.Marshal(types.PaymentVerifyRequestV2{
+			X402Version:         int(types.X402VersionV2),
+			PaymentPayload:      sdk.PaymentPayload{X402Version: int(types.X402VersionV2), Payload: wirePayload(t, payload), Accepted: requirements},
+			PaymentRequirements: requirements,
+			Reference:           "order-2",
+		})
+		require.NoError(t, err)
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/verify").String(), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var verified struct {
+			Reference string `json:"reference"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&verified))
+		require.Equal(t, "order-2", verified.Reference)
+	})
+
+	t.Run("long references are rejected", func(t *testing.T) {
+		_, err := env.client.Verify(client.WithReference(t.Context(), strings.Repeat("x", types.MaxReferenceLength+1)), payload, req)
+		require.ErrorContains(t, err, "status 422")
+	})
+}
+
+func TestResponseSigning(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := facilitator.NewPrivateKeySigner(crypto.FromECDSA(key))
+	require.NoError(t, err)
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+		api.WithResponseSigning(signer), api.WithCompression(api.CompressionConfig{MinLength: 1}))
+	env.client.ResponseSigner = signer.Address().Hex()
+
+	payload, req := env.payment(t, testAmount)
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+
+	t.Run("signatures of other keys are rejected", func(t *testing.T) {
+		other, err := client.NewClient(env.client.BaseURL.String())
+		require.NoError(t, err)
+		other.ResponseSigner = testPayTo
+		_, err = other.Verify(t.Context(), payload, req)
+		require.ErrorIs(t, err, attestation.ErrInvalidSignature)
+	})
+
+	t.Run("compressed and encoded responses", func(t *testing.T) {
+		data, err := json.Marshal(types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+		require.NoError(t, err)
+		var value map[string]any
+		require.NoError(t, json.Unmarshal(data, &value))
+		body, err := cbor.Marshal(value)
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, env.client.BaseURL.JoinPath("/verify").String(), bytes.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set("Content-Type", "application/cbor")
+		request.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		data, err = io.ReadAll(reader)
+		require.NoError(t, err)
+		// signed over the request and response as sent, before compression
+		require.NoError(t, attestation.Verify(signer.Address(), "/verify", body, data, resp.Header.Get(attestation.HeaderSignature)))
+	})
+
+	t.Run("errors aren't signed", func(t *testing.T) {
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/verify").String(), "application/json", strings.NewReader("{"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(attestation.HeaderSignature))
+	})
+}
+
+func TestNativePayment(t *testing.T) {
+	env := newTestEnv(t, 1)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	payer := crypto.PubkeyToAddress(key.PublicKey)
+	env.chain.SetBalance("", payer.Hex(), big.NewInt(1e18))
+	payTo := common.HexToAddress(testPayTo)
+
+	payment := func(t *testing.T, chainID int64, nonce uint64, value int64) (*types.PaymentPayload, *types.PaymentRequirements) {
+		t.Helper()
+		signer := ethTypes.LatestSignerForChainID(big.NewInt(chainID))
+		tx, err := ethTypes.SignNewTx(key, signer, &ethTypes.DynamicFeeTx{
+			ChainID:   big.NewInt(chainID),
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(1_000_000_000),
+			Gas:       21_000,
+			To:        &payTo,
+			Value:     big.NewInt(value),
+		})
+		require.NoError(t, err)
+		nativePayload, err := evm.NewNativePayload(tx)
+		require.NoError(t, err)
+		raw, err := json.Marshal(nativePayload)
+		require.NoError(t, err)
+		payload := &types.PaymentPayload{
+			X402Version: int(types.X402VersionV1),
+			Scheme:      string(types.EVM),
+			Network:     testNetwork,
+			Payload:     raw,
+		}
+		req := &types.PaymentRequirements{
+			Scheme:            string(types.EVM),
+			Network:           testNetwork,
+			MaxAmountRequired: "1000000000000000",
+			PayTo:             testPayTo,
+			Asset:             evm.NativeAsset,
+		}
+		return payload, req
+	}
+
+	t.Run("rejects", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			chainID int64
+			nonce   uint64
+			value   int64
+			reason  error
+		}{
+			"other chain":  {1, 0, 1e15, types.ErrNetworkIDMismatch},
+			"wrong amount": {84532, 0, 1e15 - 1, types.ErrValueMismatch},
+			"nonce gap":    {84532, 1, 1e15, types.ErrNonceTooHigh},
+		} {
+			payload, req := payment(t, tc.chainID, tc.nonce, tc.value)
+			verified, err := env.client.Verify(t.Context(), payload, req)
+			require.NoError(t, err, name)
+			require.False(t, verified.IsValid, name)
+			require.Equal(t, tc.reason.Error(), verified.InvalidReason, name)
+		}
+	})
+
+	payload, req := payment(t, 84532, 0, 1e15)
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	require.Equal(t, payer.Hex(), verified.Payer)
+
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.InDelta(t, 2.5, *settled.AmountUsd, 1e-9)
+
+	confirmed := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+	require.Equal(t, "ETH", confirmed.Asset)
+	require.Equal(t, int64(1e15), env.chain.Balance("", testPayTo).Int64())
+
+	// the transaction can't be settled twice
+	settled, err = env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, settled.Success)
+	require.Equal(t, types.ErrAuthorizationUsed.Error(), settled.Error)
+}
+
+func TestHMACAuth(t *testing.T) {
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithHMACAuth(middleware.HMACConfig{
+		Secrets: map[string]string{"shop": "s3cret"},
+	}))
+	payload, req := env.payment(t, testAmount)
+
+	_, err := env.client.Verify(t.Context(), payload, req)
+	require.ErrorContains(t, err, "status 401")
+
+	env.client.HMAC = &client.HMACCredentials{KeyID: "shop", Secret: "s3cret"}
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+
+	// discovery stays public
+	env.client.HMAC = nil
+	_, err = env.client.Supported(t.Context())
+	require.NoError(t, err)
+}
+
+func TestTenants(t *testing.T) {
+	tenants, err := tenant.New(map[string]tenant.Config{
+		"shop":   {Keys: []string{"shop"}, Assets: []string{testToken}, RateLimit: tenant.RateLimitConfig{Rate: 0.001, Burst: 3}},
+		"outlet": {Keys: []string{"outlet"}, Recipients: []string{"0x00000000000000000000000000000000000000ff"}},
+	})
+	require.NoError(t, err)
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+		api.WithHMACAuth(middleware.HMACConfig{Secrets: map[string]string{"shop": "s3cret", "outlet": "0utlet", "ops": "0ps"}}),
+		api.WithTenants(tenants),
+	)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+	payload, req := env.payment(t, testAmount)
+
+	// outlet may only pay to its own recipient
+	env.client.HMAC = &client.HMACCredentials{KeyID: "outlet", Secret: "0utlet"}
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, verified.IsValid)
+	require.Equal(t, types.ErrRecipientNotAllowed.Error(), verified.InvalidReason)
+
+	// keys without a tenant are not scoped
+	env.client.HMAC = &client.HMACCredentials{KeyID: "ops", Secret: "0ps"}
+	verified, err = env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+
+	env.client.HMAC = &client.HMACCredentials{KeyID: "shop", Secret: "s3cret"}
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	confirmed := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+	require.Equal(t, "shop", confirmed.Tenant)
+
+	// the burst of three requests is used up
+	for range 2 {
+		_, err = env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+	}
+	_, err = env.client.Verify(t.Context(), payload, req)
+	require.ErrorContains(t, err, "status 429")
+}
+
+func TestAPIKeys(t *testing.T) {
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithAPIKeys(apikey.New(store.NewMemory())))
+	endpoint := env.client.BaseURL.JoinPath("/admin/keys")
+	create := func(t *testing.T, spec apikey.Spec) string {
+		t.Helper()
+		body, _ := json.Marshal(spec)
+		resp, err := http.Post(endpoint.String(), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var created types.CreatedAPIKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		require.Equal(t, spec.Scopes, created.Scopes)
+		return created.Key
+	}
+	payload, req := env.payment(t, testAmount)
+
+	_, err := env.client.Verify(t.Context(), payload, req)
+	require.ErrorContains(t, err, "status 401")
+
+	env.client.APIKey = create(t, apikey.Spec{ID: "checkout", Scopes: []string{apikey.ScopeVerify}})
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+	_, err = env.client.Settle(t.Context(), payload, req)
+	require.ErrorContains(t, err, "status 403")
+
+	env.client.APIKey = create(t, apikey.Spec{ID: "solana-only", Scopes: []string{apikey.ScopeSettle}, Networks: []string{"solana:*"}})
+	verified, err = env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, verified.IsValid)
+	require.Equal(t, types.ErrNetworkNotAllowed.Error(), verified.InvalidReason)
+
+	env.client.APIKey = create(t, apikey.Spec{ID: "shop", Scopes: []string{apikey.ScopeSettle}, Networks: []string{"eip155:*"}})
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+
+	revoke, err := http.NewRequest(http.MethodDelete, endpoint.JoinPath("shop").String(), nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(revoke)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, err = env.client.Verify(t.Context(), payload, req)
+	require.ErrorContains(t, err, "status 401")
+}
+
+func TestSettlementStream(t *testing.T) {
+	dial := func(t *testing.T, env *testEnv, header http.Header) (*websocket.Conn, int) {
+		t.Helper()
+		endpoint := *env.client.BaseURL.JoinPath("/ws/settlements")
+		endpoint.Scheme = "ws"
+		conn, resp, err := websocket.DefaultDialer.DialContext(t.Context(), endpoint.String(), header)
+		if err != nil {
+			require.NotNil(t, resp, err)
+			resp.Body.Close()
+			return nil, resp.StatusCode
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, resp.StatusCode
+	}
+
+	t.Run("requires authentication", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		_, status := dial(t, env, nil)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+
+	t.Run("accepts the origins of CORS", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+			api.WithAPIKeys(apikey.New(store.NewMemory())),
+			api.WithCORS(api.CORSConfig{AllowOrigins: []string{"https://shop.example"}}))
+		body, err := json.Marshal(apikey.Spec{ID: "dashboard", Scopes: []string{apikey.ScopeReadStatus}})
+		require.NoError(t, err)
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/admin/keys").String(), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var created types.CreatedAPIKey
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+		_, status := dial(t, env, nil)
+		require.Equal(t, http.StatusUnauthorized, status)
+
+		header := http.Header{middleware.HeaderAPIKey: {created.Key}}
+		_, status = dial(t, env, header)
+		require.Equal(t, http.StatusSwitchingProtocols, status, "clients without origin aren't browsers")
+
+		header.Set(echo.HeaderOrigin, "https://evil.example")
+		_, status = dial(t, env, header)
+		require.Equal(t, http.StatusForbidden, status)
+
+		header.Set(echo.HeaderOrigin, "https://shop.example")
+		_, status = dial(t, env, header)
+		require.Equal(t, http.StatusSwitchingProtocols, status)
+	})
+}
+
+func TestHeaders(t *testing.T) {
+	preflight := func(t *testing.T, env *testEnv, origin string) http.Header {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodOptions, env.client.BaseURL.JoinPath("/verify").String(), nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.Header
+	}
+
+	t.Run("defaults allow every origin", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		header := preflight(t, env, "https://shop.example")
+		require.Equal(t, "*", header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+		require.Equal(t, "DENY", header.Get("X-Frame-Options"))
+		require.Empty(t, header.Get("Strict-Transport-Security"))
+	})
+
+	t.Run("configured origins and HSTS", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+			api.WithCORS(api.CORSConfig{AllowOrigins: []string{"https://shop.example"}, MaxAge: time.Minute}),
+			api.WithSecurityHeaders(api.SecurityHeadersConfig{HSTSMaxAge: time.Hour}),
+		)
+		header := preflight(t, env, "https://shop.example")
+		require.Equal(t, "https://shop.example", header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "60", header.Get("Access-Control-Max-Age"))
+		require.Equal(t, "max-age=3600; includeSubdomains", header.Get("Strict-Transport-Security"))
+
+		require.Empty(t, preflight(t, env, "https://evil.example").Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("CORS disabled", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithCORS(api.CORSConfig{Disabled: true}))
+		header := preflight(t, env, "https://shop.example")
+		require.Empty(t, header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+	})
+}
+
+func TestEmbedding(t *testing.T) {
+	host := echo.New()
+	host.GET("/health", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+	var seen []string
+	keys := apikey.New(store.NewMemory())
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+		api.WithEcho(host),
+		api.WithPrefix("/x402/"),
+		api.WithAPIKeys(keys),
+		api.WithoutSwagger(),
+		api.WithMiddleware(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				seen = append(seen, c.Path())
+				return next(c)
+			}
+		}),
+		api.WithErrorHandler(func(err error, c echo.Context) {
+			c.String(http.StatusTeapot, err.Error())
+		}),
+	)
+	_, key, err := keys.Create(t.Context(), apikey.Spec{ID: "checkout", Scopes: []string{apikey.ScopeVerify}})
+	require.NoError(t, err)
+	call := func(t *testing.T, method, path string, body any) *http.Response {
+		t.Helper()
+		raw, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, env.client.BaseURL.JoinPath(path).String(), bytes.NewReader(raw))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.HeaderAPIKey, key)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	payload, req := env.payment(t, testAmount)
+	body := types.PaymentVerifyRequest{X402Version: int(types.X402VersionV1), PaymentHeader: *payload, PaymentRequirements: *req}
+
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/health", nil).StatusCode, "the routes of the host are kept")
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, "/x402/verify", body).StatusCode)
+	require.Equal(t, http.StatusNotFound, call(t, http.MethodPost, "/verify", body).StatusCode)
+	require.Equal(t, []string{"/x402/verify"}, seen, "the middleware wraps the routes of the facilitator only")
+
+	// the scope of the key is checked without the prefix, errors are rendered by the handler
+	require.Equal(t, http.StatusTeapot, call(t, http.MethodPost, "/x402/settle", body).StatusCode)
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/x402/supported", nil).StatusCode)
+	require.Equal(t, http.StatusTeapot, call(t, http.MethodGet, "/x402/swagger/index.html", nil).StatusCode)
+}
+
+func TestCompression(t *testing.T) {
+	// a transport that leaves responses compressed, unlike the default one
+	transport := &http.Transport{DisableCompression: true}
+	t.Cleanup(transport.CloseIdleConnections)
+	get := func(t *testing.T, env *testEnv, path, acceptEncoding string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, env.client.BaseURL.JoinPath(path).String(), nil)
+		require.NoError(t, err)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	env := newTestEnv(t, 1)
+	_, spec := get(t, env, "/openapi.yaml", "")
+	require.Greater(t, len(spec), 1024)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		encoding       string
+		decode         func(io.Reader) (io.Reader, error)
+	}{
+		{name: "brotli preferred", acceptEncoding: "gzip, deflate, br", encoding: "br", decode: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+		{name: "gzip", acceptEncoding: "gzip", encoding: "gzip", decode: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{name: "by quality", acceptEncoding: "br;q=0.5, gzip", encoding: "gzip", decode: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{name: "wildcard", acceptEncoding: "*", encoding: "br", decode: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+		{name: "unsupported", acceptEncoding: "zstd, br;q=0"},
+		{name: "identity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := get(t, env, "/openapi.yaml", tt.acceptEncoding)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Contains(t, resp.Header.Values("Vary"), "Accept-Encoding")
+			require.Equal(t, tt.encoding, resp.Header.Get("Content-Encoding"))
+			if tt.decode == nil {
+				require.Equal(t, spec, body)
+				return
+			}
+			require.Less(t, len(body), len(spec))
+			r, err := tt.decode(bytes.NewReader(body))
+			require.NoError(t, err)
+			decoded, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, spec, decoded)
+		})
+	}
+
+	t.Run("short responses uncompressed", func(t *testing.T) {
+		resp, body := get(t, env, "/version", "br, gzip")
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.True(t, json.Valid(body))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		resp, body := get(t, env, "/missing", "gzip")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.True(t, json.Valid(body))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithCompression(api.CompressionConfig{Disabled: true}))
+		resp, body := get(t, env, "/openapi.yaml", "br, gzip")
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.Equal(t, spec, body)
+	})
+
+	t.Run("configured encodings and length", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+			api.WithCompression(api.CompressionConfig{Encodings: []string{"gzip"}, MinLength: 10}))
+		resp, _ := get(t, env, "/openapi.yaml", "br")
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		resp, _ = get(t, env, "/version", "br, gzip")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	})
+}
+
+func TestHTTP2(t *testing.T) {
+	env := newTestEnv(t, 1)
+	h2c := new(http.Protocols)
+	h2c.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: h2c}}
+
+	serve := func(t *testing.T, config api.HTTPServerConfig) string {
+		t.Helper()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		server := api.NewHTTPServer("", env.handler, config)
+		go server.Serve(listener)
+		t.Cleanup(func() { server.Close() })
+		return "http://" + listener.Addr().String()
+	}
+
+	t.Run("h2c with prior knowledge", func(t *testing.T) {
+		resp, err := client.Get(serve(t, api.HTTPServerConfig{}) + "/supported")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, 2, resp.ProtoMajor)
+
+		resp, err = http.Get(serve(t, api.HTTPServerConfig{}) + "/supported")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, 1, resp.ProtoMajor, "HTTP/1.1 clients are still served")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := client.Get(serve(t, api.HTTPServerConfig{DisableHTTP2: true}) + "/supported")
+		require.Error(t, err)
+	})
+}
+
+func TestCosts(t *testing.T) {
+	env := newTestEnv(t, 1)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+	start := time.Now().Add(-time.Second)
+
+	payload, req := env.payment(t, testAmount)
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+
+	report, err := env.client.Costs(t.Context(), start, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, report.Entries, 1)
+
+	entry := report.Entries[0]
+	require.Equal(t, testNetwork, entry.Network)
+	require.Equal(t, testToken, entry.Asset)
+	require.Equal(t, 1, entry.Settlements)
+	require.Equal(t, uint64(60_000), entry.GasUsed)
+	require.Equal(t, "0.00006", entry.FeeNative)
+	require.Equal(t, "ETH", entry.FeeCurrency)
+	require.InDelta(t, 0.15, *entry.FeeUsd, 1e-9)
+	require.InDelta(t, 0.01, *entry.AmountUsd, 1e-9)
+
+	_, err = env.client.Costs(t.Context(), time.Now(), start)
+	require.ErrorContains(t, err, "status 400")
+
+	t.Run("stats", func(t *testing.T) {
+		stats, err := env.client.Stats(t.Context(), "minute", start, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		require.Equal(t, "minute", stats.Granularity)
+		var settlements int
+		var volumes []types.SettlementVolume
+		for _, bucket := range stats.Buckets {
+			settlements += bucket.Settlements
+			volumes = append(volumes, bucket.Volumes...)
+		}
+		require.Equal(t, 1, settlements)
+		require.Len(t, volumes, 1)
+		require.Equal(t, strconv.Itoa(testAmount), volumes[0].Amount)
+		require.Equal(t, "0.01", volumes[0].AmountDecimal)
+
+		_, err = env.client.Stats(t.Context(), "week", time.Time{}, time.Time{})
+		require.ErrorContains(t, err, "status 400")
+		_, err = env.client.Stats(t.Context(), "minute", time.Now().AddDate(-1, 0, 0), time.Time{})
+		require.ErrorContains(t, err, "status 400", "too many buckets")
+	})
+
+	t.Run("export", func(t *testing.T) {
+		target := env.client.BaseURL.JoinPath("/admin/export")
+		target.RawQuery = url.Values{"from": {start.Format(time.RFC3339)}, "to": {time.Now().Add(time.Second).Format(time.RFC3339)}}.Encode()
+		resp, err := http.Get(target.String())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+
+		lines, err := csv.NewReader(resp.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, lines, 2)
+		row := make(map[string]string)
+		for i, column := range lines[0] {
+			row[column] = lines[1][i]
+		}
+		require.Equal(t, settled.TxHash, row["tx_hash"])
+		require.Equal(t, testPayTo, row["pay_to"])
+		require.Equal(t, strconv.Itoa(testAmount), row["amount"])
+		require.Equal(t, "0.01", row["amount_decimal"])
+		require.Equal(t, "60000", row["gas_used"])
+		require.Equal(t, "0.00006", row["fee_decimal"])
+
+		target.RawQuery = "format=xlsx"
+		resp, err = http.Get(target.String())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestDashboard(t *testing.T) {
+	env := newTestEnv(t, 1)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	payload, req := env.payment(t, testAmount)
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+
+	page, err := http.Get(env.client.BaseURL.JoinPath("/admin/dashboard").String())
+	require.NoError(t, err)
+	defer page.Body.Close()
+	require.Equal(t, http.StatusOK, page.StatusCode)
+	require.Contains(t, page.Header.Get("Content-Type"), "text/html")
+
+	resp, err := http.Get(env.client.BaseURL.JoinPath("/admin/dashboard/data").String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var dashboard types.Dashboard
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&dashboard))
+	require.Len(t, dashboard.Activity, 60, "one bucket per minute of the last hour")
+	require.Equal(t, []types.DashboardNetwork{{Network: testNetwork, Settled: 1}}, dashboard.Networks)
+	require.Zero(t, dashboard.QueueDepth)
+}
+
+func TestRuntime(t *testing.T) {
+	env := newTestEnv(t, 1)
+	get := func(path string) *http.Response {
+		ref, err := url.Parse(path)
+		require.NoError(t, err)
+		target := env.client.BaseURL.JoinPath(ref.Path)
+		target.RawQuery = ref.RawQuery
+		resp, err := http.Get(target.String())
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		return resp
+	}
+
+	var stats types.RuntimeStats
+	require.NoError(t, json.NewDecoder(get("/admin/runtime").Body).Decode(&stats))
+	require.Positive(t, stats.Goroutines)
+	require.Positive(t, stats.Heap.Alloc)
+	require.NotNil(t, stats.RPCConnections)
+	require.LessOrEqual(t, len(stats.GC.RecentPausesMs), 16)
+
+	goroutines, err := io.ReadAll(get("/admin/debug/pprof/goroutine?debug=1").Body)
+	require.NoError(t, err)
+	require.Contains(t, string(goroutines), "goroutine profile:")
+	index, err := io.ReadAll(get("/admin/debug/pprof/").Body)
+	require.NoError(t, err)
+	require.Contains(t, string(index), "heap")
+
+	var vars map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(get("/admin/debug/vars").Body).Decode(&vars))
+	require.Contains(t, vars, "memstats")
+}
+
+func TestVerifyRejects(t *testing.T) {
+	t.Run("insufficient balance", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		payload, req := env.payment(t, testAmount+1)
+
+		verified, err := env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, verified.IsValid)
+		require.Equal(t, types.ErrInsufficientBalance.Error(), verified.InvalidReason)
+	})
+
+	t.Run("signature of another account", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		payload, req := env.payment(t, testAmount)
+
+		var evmPayload evm.EVMPayload
+		require.NoError(t, json.Unmarshal(payload.Payload, &evmPayload))
+		evmPayload.Authorization.From = evm.GetDomainConfig(testChain, testToken).VerifyingContract
+		payload.Payload, _ = json.Marshal(evmPayload)
+
+		verified, err := env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, verified.IsValid)
+		require.Equal(t, types.ErrInvalidSignature.Error(), verified.InvalidReason)
+	})
+
+	t.Run("RPC serves another chain", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(1, testSigner), 1)
+		payload, req := env.payment(t, testAmount)
+
+		verified, err := env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, verified.IsValid)
+		require.Equal(t, types.ErrRPCAnomaly.Error(), verified.InvalidReason)
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	// expiring signs the payment again with an authorization valid for the duration
+	expiring := func(t *testing.T, env *testEnv, payload *types.PaymentPayload, valid time.Duration) {
+		var evmPayload evm.EVMPayload
+		require.NoError(t, json.Unmarshal(payload.Payload, &evmPayload))
+		evmPayload.Authorization.ValidBefore = big.NewInt(time.Now().Add(valid).Unix())
+		signature, err := evm.SignEip3009(evmPayload.Authorization, evm.GetDomainConfig(testChain, testToken), env.signer)
+		require.NoError(t, err)
+		evmPayload.Signature = signature
+		payload.Payload, err = json.Marshal(evmPayload)
+		require.NoError(t, err)
+	}
+
+	t.Run("authorization within the safety margin", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		payload, req := env.payment(t, testAmount)
+		expiring(t, env, payload, 3*time.Second)
+
+		verified, err := env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, verified.IsValid)
+		require.Equal(t, types.ErrAuthorizationExpired.Error(), verified.InvalidReason)
+
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, settled.Success)
+		require.Equal(t, types.ErrAuthorizationExpired.Error(), settled.Error)
+		expired := waitStatus(t, events, "", settlement.StatusExpired)
+		require.Equal(t, types.ErrAuthorizationExpired.Error(), expired.Error)
+		require.Zero(t, env.chain.Calls("WriteContract"))
+	})
+
+	t.Run("authorization lapses while queued", func(t *testing.T) {
+		fake := clock.NewFake(time.Now())
+		env := newTestEnvWithManager(t, mock.NewEVMSigner(84532, testSigner), 1, store.NewMemory(),
+			[]settlement.Option{settlement.WithDispatcher(settlement.DispatcherConfig{Workers: 1}), settlement.WithClock(fake)})
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		env.chain.SetBalance(env.token, env.payer, big.NewInt(2*testAmount))
+		env.chain.Inject("WriteContract", mock.Fault{Latency: 3 * time.Second, Times: 1})
+
+		// the first settlement takes the only worker
+		first, req := env.payment(t, testAmount)
+		go func() { _, _ = env.client.Settle(context.Background(), first, req) }()
+		waitStatus(t, events, "", settlement.StatusQueued)
+		require.Eventually(t, func() bool { return env.chain.Calls("WriteContract") == 1 }, eventTimeout, 10*time.Millisecond)
+
+		// the second must be broadcast within ten minutes but waits for the worker
+		second, req := env.payment(t, testAmount)
+		expiring(t, env, second, facilitator.DefaultExpiryMargin+10*time.Minute)
+		var settled *types.PaymentSettleResponse
+		var err error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			settled, err = env.client.Settle(t.Context(), second, req)
+		}()
+		// the deadlines of both settlements are set, the second one lapses
+		fake.WaitTimers(2)
+		fake.Advance(10*time.Minute + time.Second)
+		<-done
+		require.NoError(t, err)
+		require.False(t, settled.Success)
+		require.Equal(t, types.ErrAuthorizationExpired.Error(), settled.Error)
+		require.Equal(t, 1, env.chain.Calls("WriteContract"), "the expired settlement was never broadcast")
+	})
+}
+
+func TestGasCeiling(t *testing.T) {
+	gas := facilitator.GasPolicy{MaxGasCostWei: 100_000_000_000_000} // 0.0001 ETH, the mock settles for 60000 gas
+	expensive := big.NewInt(2_000_000_000)
+
+	t.Run("expensive settlements are rejected", func(t *testing.T) {
+		env := newTestEnvWithConfig(t, mock.NewEVMSigner(84532, testSigner), facilitator.NetworkConfig{Network: testNetwork, Gas: gas}, store.NewMemory(), nil)
+		env.chain.SetGasPrice(expensive)
+		payload, req := env.payment(t, testAmount)
+
+		estimate, err := env.client.Estimate(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, estimate.Success)
+		require.Equal(t, types.ErrGasTooExpensive.Error(), estimate.Error)
+		require.Equal(t, "120000000000000", estimate.GasCost)
+
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, settled.Success)
+		require.Equal(t, types.ErrGasTooExpensive.Error(), settled.Error)
+		require.Zero(t, env.chain.Calls("WriteContract"))
+
+		env.chain.SetGasPrice(big.NewInt(1_000_000_000))
+		settled, err = env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, settled.Success, settled.Error)
+	})
+
+	t.Run("deferred settlements wait for fees to drop", func(t *testing.T) {
+		deferred := gas
+		deferred.DeferOverCeiling = true
+		deferred.DeferInterval = time.Minute
+		fake := clock.NewFake(time.Now())
+		env := newTestEnvWithConfig(t, mock.NewEVMSigner(84532, testSigner), facilitator.NetworkConfig{Network: testNetwork, Gas: deferred},
+			store.NewMemory(), []settlement.Option{settlement.WithClock(fake)})
+		env.chain.SetGasPrice(expensive)
+		payload, req := env.payment(t, testAmount)
+
+		var settled *types.PaymentSettleResponse
+		var err error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			settled, err = env.client.Settle(t.Context(), payload, req)
+		}()
+		// the deadline of the authorization and the wait before trying again
+		fake.WaitTimers(2)
+		fake.Advance(time.Minute)
+		fake.WaitTimers(2)
+		require.Zero(t, env.chain.Calls("WriteContract"), "still too expensive")
+
+		env.chain.SetGasPrice(big.NewInt(1_000_000_000))
+		fake.Advance(time.Minute)
+		<-done
+		require.NoError(t, err)
+		require.True(t, settled.Success, settled.Error)
+		require.Equal(t, 1, env.chain.Calls("WriteContract"))
+	})
+}
+
+func TestScheduledSettlement(t *testing.T) {
+	gas := facilitator.GasPolicy{ScheduleBelowGwei: 0.5, DeferInterval: time.Minute} // the mock charges 1 gwei
+	newEnv := func(t *testing.T) (*testEnv, *clock.Fake, <-chan settlement.Event) {
+		fake := clock.NewFake(time.Now())
+		env := newTestEnvWithConfig(t, mock.NewEVMSigner(84532, testSigner), facilitator.NetworkConfig{Network: testNetwork, Gas: gas},
+			store.NewMemory(), []settlement.Option{settlement.WithClock(fake)})
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		t.Cleanup(unsubscribe)
+		return env, fake, events
+	}
+	waitFor := func(t *testing.T, events <-chan settlement.Event, id string, status settlement.Status) settlement.Event {
+		t.Helper()
+		timeout := time.After(eventTimeout)
+		for {
+			select {
+			case evt := <-events:
+				if evt.ID == id && evt.Status == status {
+					return evt
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for settlement %s to become %s", id, status)
+			}
+		}
+	}
+
+	t.Run("settles once the gas price dips", func(t *testing.T) {
+		env, fake, events := newEnv(t)
+		payload, req := env.payment(t, testAmount)
+		notBefore := fake.Now().Add(5 * time.Minute).Truncate(time.Second)
+
+		scheduled, err := env.client.ScheduleSettle(t.Context(), payload, req, notBefore, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, "scheduled", scheduled.Status)
+		require.Equal(t, notBefore.Unix(), scheduled.NotBefore)
+		require.Greater(t, scheduled.NotAfter, scheduled.NotBefore, "the window closes when the authorization expires")
+		waitFor(t, events, scheduled.SettlementID, settlement.StatusScheduled)
+
+		// the window opens, and the settlement waits for the window to close or the next poll
+		fake.WaitTimers(1)
+		fake.Advance(5 * time.Minute)
+		fake.WaitTimers(2)
+		require.Zero(t, env.chain.Calls("WriteContract"))
+
+		env.chain.SetGasPrice(big.NewInt(400_000_000))
+		fake.Advance(time.Minute)
+		submitted := waitFor(t, events, scheduled.SettlementID, settlement.StatusSubmitted)
+		require.NotEmpty(t, submitted.TxHash)
+		require.Equal(t, 1, env.chain.Calls("WriteContract"))
+	})
+
+	t.Run("settles when the window closes", func(t *testing.T) {
+		env, fake, events := newEnv(t)
+		payload, req := env.payment(t, testAmount)
+
+		scheduled, err := env.client.ScheduleSettle(t.Context(), payload, req, time.Time{}, fake.Now().Add(10*time.Minute))
+		require.NoError(t, err)
+		fake.WaitTimers(2)
+		fake.Advance(10 * time.Minute)
+		waitFor(t, events, scheduled.SettlementID, settlement.StatusSubmitted)
+	})
+
+	t.Run("empty windows are rejected", func(t *testing.T) {
+		env, fake, _ := newEnv(t)
+		payload, req := env.payment(t, testAmount)
+
+		_, err := env.client.ScheduleSettle(t.Context(), payload, req, time.Time{}, fake.Now().Add(-time.Minute))
+		require.ErrorContains(t, err, "status 422")
+		_, err = env.client.ScheduleSettle(t.Context(), payload, req, fake.Now().Add(time.Hour), fake.Now())
+		require.ErrorContains(t, err, "must not be before notBefore")
+	})
+
+	t.Run("payments are verified and claimed", func(t *testing.T) {
+		env, fake, _ := newEnv(t)
+		payload, req := env.payment(t, testAmount)
+
+		_, err := env.client.ScheduleSettle(t.Context(), payload, req, fake.Now().Add(time.Minute), time.Time{})
+		require.NoError(t, err)
+		_, err = env.client.ScheduleSettle(t.Context(), payload, req, fake.Now().Add(time.Minute), time.Time{})
+		require.ErrorContains(t, err, types.ErrAuthorizationUsed.Error())
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrAuthorizationUsed.Error(), settled.Error)
+
+		invalid, req := env.payment(t, testAmount)
+		req.MaxAmountRequired = strconv.Itoa(testAmount + 1)
+		_, err = env.client.ScheduleSettle(t.Context(), invalid, req, fake.Now().Add(time.Minute), time.Time{})
+		require.ErrorContains(t, err, "payment rejected")
+	})
+
+	t.Run("waiting settlements are bounded", func(t *testing.T) {
+		fake := clock.NewFake(time.Now())
+		env := newTestEnvWithConfig(t, mock.NewEVMSigner(84532, testSigner), facilitator.NetworkConfig{Network: testNetwork, Gas: gas},
+			store.NewMemory(), []settlement.Option{settlement.WithClock(fake), settlement.WithDispatcher(settlement.DispatcherConfig{MaxScheduled: 1})})
+		first, req := env.payment(t, testAmount)
+		second, _ := env.payment(t, testAmount)
+
+		_, err := env.client.ScheduleSettle(t.Context(), first, req, fake.Now().Add(time.Minute), time.Time{})
+		require.NoError(t, err)
+		_, err = env.client.ScheduleSettle(t.Context(), second, req, fake.Now().Add(time.Minute), time.Time{})
+		require.ErrorContains(t, err, "status 503")
+	})
+
+	t.Run("settlements of a crashed process fail on resume", func(t *testing.T) {
+		records := store.NewMemory()
+		require.NoError(t, records.SaveSettlement(t.Context(), &store.Settlement{ID: "crashed", Network: testNetwork, Status: string(settlement.StatusScheduled)}))
+		env := newTestEnvWithConfig(t, mock.NewEVMSigner(84532, testSigner), facilitator.NetworkConfig{Network: testNetwork}, records, nil)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		require.NoError(t, env.settlements.Resume(t.Context()))
+		failed := waitFor(t, events, "crashed", settlement.StatusFailed)
+		require.Equal(t, settlement.ErrScheduleCanceled.Error(), failed.Error)
+	})
+
+	t.Run("waiting settlements fail on shutdown", func(t *testing.T) {
+		env, _, events := newEnv(t)
+		payload, req := env.payment(t, testAmount)
+
+		scheduled, err := env.client.ScheduleSettle(t.Context(), payload, req, time.Now().Add(time.Minute), time.Time{})
+		require.NoError(t, err)
+		env.settlements.Close()
+		failed := waitFor(t, events, scheduled.SettlementID, settlement.StatusFailed)
+		require.Equal(t, settlement.ErrScheduleCanceled.Error(), failed.Error)
+	})
+}
+
+func TestReplayProtection(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1,
+		api.WithReplayProtection(api.ReplayConfig{Enabled: true}), api.WithClock(fake))
+	settle := func(t *testing.T, body any) (int, []byte) {
+		t.Helper()
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/settle").String(), "application/json", bytes.NewReader(raw))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, data
+	}
+	stamped := func(t *testing.T, nonce string, at time.Time) types.PaymentSettleRequest {
+		payload, req := env.payment(t, testAmount/4)
+		extra := json.RawMessage(`{"requestNonce":"` + nonce + `","requestTimestamp":` + strconv.FormatInt(at.Unix(), 10) + `}`)
+		req.Extra = &extra
+		return types.PaymentSettleRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req}
+	}
+
+	payload, req := env.payment(t, testAmount/4)
+	status, data := settle(t, types.PaymentSettleRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, string(data), "paymentRequirements.extra.requestNonce")
+
+	status, data = settle(t, stamped(t, "n1", time.Now().Add(-time.Hour)))
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, string(data), "is outside the accepted window")
+
+	request := stamped(t, "n1", time.Now())
+	status, data = settle(t, request)
+	require.Equal(t, http.StatusOK, status, string(data))
+	status, _ = settle(t, request)
+	require.Equal(t, http.StatusConflict, status, "the replayed request is rejected before it is settled")
+
+	// version 2 requests carry the stamp in the requirements the payload accepted
+	payload, req = env.payment(t, testAmount/4)
+	requirements := sdk.PaymentRequirements{
+		Scheme:            sdk.SchemeExact,
+		Network:           testNetwork,
+		Asset:             req.Asset,
+		Amount:            req.MaxAmountRequired,
+		PayTo:             req.PayTo,
+		MaxTimeoutSeconds: 60,
+	}
+	accepted := requirements
+	accepted.Extra = map[string]any{"requestNonce": "n2", "requestTimestamp": time.Now().Unix()}
+	v2 := types.PaymentSettleRequestV2{
+		X402Version:         2,
+		PaymentPayload:      sdk.PaymentPayload{X402Version: 2, Payload: wirePayload(t, payload), Accepted: accepted},
+		PaymentRequirements: requirements,
+	}
+	status, data = settle(t, v2)
+	require.Equal(t, http.StatusOK, status, string(data))
+	status, _ = settle(t, v2)
+	require.Equal(t, http.StatusConflict, status)
+
+	// the window moves with the clock of the server, used nonces are forgotten once it passed
+	fake.Advance(time.Hour)
+	status, data = settle(t, stamped(t, "n3", time.Now()))
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, string(data), "is outside the accepted window")
+	status, data = settle(t, stamped(t, "n1", fake.Now()))
+	require.Equal(t, http.StatusOK, status, string(data))
+}
+
+func TestValidation(t *testing.T) {
+	env := newTestEnv(t, 1)
+	payload, req := env.payment(t, testAmount)
+
+	post := func(t *testing.T, body []byte) (int, []byte) {
+		t.Helper()
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/verify").String(), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, data
+	}
+	validationErrors := func(t *testing.T, data []byte) []types.FieldError {
+		t.Helper()
+		var resp types.ValidationErrorResponse
+		require.NoError(t, json.Unmarshal(data, &resp))
+		return resp.Errors
+	}
+
+	t.Run("missing fields are listed", func(t *testing.T) {
+		incomplete := *req
+		incomplete.PayTo, incomplete.MaxAmountRequired = "", "ten"
+		body, _ := json.Marshal(types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: incomplete})
+
+		status, data := post(t, body)
+		require.Equal(t, http.StatusUnprocessableEntity, status)
+		require.Equal(t, []types.FieldError{
+			{Field: "paymentRequirements.payTo", Message: "is required"},
+			{Field: "paymentRequirements.maxAmountRequired", Message: "must be a non-negative integer in atomic units"},
+		}, validationErrors(t, data))
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		status, data := post(t, []byte(`{"x402Version":1,"paymentRequirements":{"amount":"1"}}`))
+		require.Equal(t, http.StatusUnprocessableEntity, status)
+		require.Equal(t, []types.FieldError{{Field: "amount", Message: "is not allowed"}}, validationErrors(t, data))
+	})
+
+	t.Run("mistyped fields are rejected", func(t *testing.T) {
+		status, data := post(t, []byte(`{"x402Version":"1"}`))
+		require.Equal(t, http.StatusUnprocessableEntity, status)
+		require.Equal(t, []types.FieldError{{Field: "x402Version", Message: "must be of type int"}}, validationErrors(t, data))
+	})
+
+	t.Run("oversized bodies are rejected", func(t *testing.T) {
+		status, _ := post(t, []byte(`{"x402Version":1,"paymentRequirements":{"description":"`+strings.Repeat("a", 1<<20)+`"}}`))
+		require.Equal(t, http.StatusRequestEntityTooLarge, status)
+	})
+
+	t.Run("malformed bodies are rejected", func(t *testing.T) {
+		status, _ := post(t, []byte(`{"x402Version":`))
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("unsupported networks list the supported ones", func(t *testing.T) {
+		for _, network := range []string{"eip155:1", "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp"} {
+			other, otherReq := *payload, *req
+			other.Network, otherReq.Network = network, network
+			body, _ := json.Marshal(types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: other, PaymentRequirements: otherReq})
+
+			status, data := post(t, body)
+			require.Equal(t, http.StatusBadRequest, status)
+			var resp types.UnsupportedNetworkResponse
+			require.NoError(t, json.Unmarshal(data, &resp))
+			require.Equal(t, types.ErrorCodeUnsupportedNetwork, resp.Code)
+			require.Equal(t, []string{"eip155:84532"}, resp.SupportedNetworks)
+		}
+
+		// networks are matched by chain name as well
+		byName := *req
+		byName.Network = testChain
+		body, _ := json.Marshal(types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: byName})
+		status, _ := post(t, body)
+		require.Equal(t, http.StatusOK, status)
+	})
+}
+
+func TestContentNegotiation(t *testing.T) {
+	env := newTestEnv(t, 1)
+	payload, req := env.payment(t, testAmount)
+
+	// binary encodings of the JSON data model, as clients in other languages produce them
+	type encoding struct {
+		mediaType string
+		marshal   func(any) ([]byte, error)
+		unmarshal func([]byte, any) error
+	}
+	cborEncoding := encoding{api.MIMEApplicationCBOR, cbor.Marshal, cbor.Unmarshal}
+	msgpackEncoding := encoding{api.MIMEApplicationMsgpack, msgpack.Marshal, msgpack.Unmarshal}
+	encode := func(t *testing.T, enc encoding, v any) []byte {
+		t.Helper()
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		var value map[string]any
+		require.NoError(t, json.Unmarshal(data, &value))
+		body, err := enc.marshal(value)
+		require.NoError(t, err)
+		return body
+	}
+	post := func(t *testing.T, path, contentType, accept string, body []byte) *http.Response {
+		t.Helper()
+		httpReq, err := http.NewRequest(http.MethodPost, env.client.BaseURL.JoinPath(path).String(), bytes.NewReader(body))
+		require.NoError(t, err)
+		httpReq.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			httpReq.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(httpReq)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	// decode decodes a binary response into the type of its JSON representation
+	decode := func(t *testing.T, enc encoding, resp *http.Response, dst any) {
+		t.Helper()
+		require.Equal(t, enc.mediaType, resp.Header.Get("Content-Type"))
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var value map[string]any
+		require.NoError(t, enc.unmarshal(data, &value))
+		data, err = json.Marshal(value)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, dst))
+	}
+
+	for _, enc := range []encoding{cborEncoding, msgpackEncoding} {
+		t.Run(enc.mediaType, func(t *testing.T) {
+			body := encode(t, enc, types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+			resp := post(t, "/verify", enc.mediaType, "", body)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			var verified types.PaymentVerifyResponse
+			decode(t, enc, resp, &verified)
+			require.True(t, verified.IsValid, verified.InvalidReason)
+			require.Equal(t, env.payer, verified.Payer)
+
+			invalid := encode(t, enc, map[string]any{"x402Version": 1, "paymentRequirements": map[string]any{"amount": "1"}})
+			resp = post(t, "/verify", enc.mediaType, "", invalid)
+			require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+			var rejected types.ValidationErrorResponse
+			decode(t, enc, resp, &rejected)
+			require.Equal(t, []types.FieldError{{Field: "amount", Message: "is not allowed"}}, rejected.Errors, "errors are encoded like the request")
+		})
+	}
+
+	t.Run("accept picks the response encoding", func(t *testing.T) {
+		body := encode(t, cborEncoding, types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+		resp := post(t, "/verify", api.MIMEApplicationCBOR, "application/json", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		jsonBody, err := json.Marshal(types.PaymentVerifyRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+		require.NoError(t, err)
+		resp = post(t, "/verify", "application/json", "application/x-msgpack;q=0.9, */*;q=0.1", jsonBody)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var verified types.PaymentVerifyResponse
+		decode(t, msgpackEncoding, resp, &verified)
+		require.True(t, verified.IsValid, verified.InvalidReason)
+	})
+
+	t.Run("settle", func(t *testing.T) {
+		body := encode(t, msgpackEncoding, types.PaymentSettleRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+		resp := post(t, "/settle", api.MIMEApplicationMsgpack, "", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var settled types.PaymentSettleResponse
+		decode(t, msgpackEncoding, resp, &settled)
+		require.True(t, settled.Success, settled.Error)
+		require.NotEmpty(t, settled.TxHash)
+	})
+
+	t.Run("malformed bodies are rejected", func(t *testing.T) {
+		resp := post(t, "/verify", api.MIMEApplicationCBOR, "", []byte{0xff, 0x00})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var rejected map[string]string
+		decode(t, cborEncoding, resp, &rejected)
+		require.Equal(t, "Received malformed request body", rejected["message"])
+	})
+}
+
+// wirePayload encodes the payload of the payment the way x402 clients do.
+func wirePayload(t *testing.T, payload *types.PaymentPayload) map[string]any {
+	t.Helper()
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	require.NoError(t, err)
+	auth := evmPayload.Authorization
+	wire := sdk.ExactEIP3009Payload{
+		Signature: evmPayload.Signature,
+		Authorization: sdk.ExactEIP3009Authorization{
+			From:        auth.From.Hex(),
+			To:          auth.To.Hex(),
+			Value:       auth.Value.String(),
+			ValidAfter:  auth.ValidAfter.String(),
+			ValidBefore: auth.ValidBefore.String(),
+			Nonce:       hexutil.Encode(auth.Nonce[:]),
+		},
+	}
+	return wire.ToMap()
+}
+
+func TestProtocolVersions(t *testing.T) {
+	env := newTestEnv(t, 1)
+	payload, req := env.payment(t, testAmount)
+
+	post := func(t *testing.T, path string, body any) (int, []byte) {
+		t.Helper()
+		encoded, err := json.Marshal(body)
+		require.NoError(t, err)
+		resp, err := http.Post(env.client.BaseURL.JoinPath(path).String(), "application/json", bytes.NewReader(encoded))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, data
+	}
+	requirements := sdk.PaymentRequirements{
+		Scheme:            sdk.SchemeExact,
+		Network:           testNetwork,
+		Asset:             req.Asset,
+		Amount:            req.MaxAmountRequired,
+		PayTo:             req.PayTo,
+		MaxTimeoutSeconds: 60,
+	}
+	v2Payload := sdk.PaymentPayload{
+		X402Version: int(types.X402VersionV2),
+		Payload:     wirePayload(t, payload),
+		Accepted:    requirements,
+	}
+
+	t.Run("version 2 requests get version 2 responses", func(t *testing.T) {
+		status, data := post(t, "/verify", types.PaymentVerifyRequestV2{X402Version: 2, PaymentPayload: v2Payload, PaymentRequirements: requirements})
+		require.Equal(t, http.StatusOK, status, string(data))
+		var verified sdk.VerifyResponse
+		require.NoError(t, json.Unmarshal(data, &verified))
+		require.True(t, verified.IsValid, verified.InvalidReason)
+		require.Equal(t, env.payer, verified.Payer)
+
+		status, data = post(t, "/settle", types.PaymentSettleRequestV2{X402Version: 2, PaymentPayload: v2Payload, PaymentRequirements: requirements})
+		require.Equal(t, http.StatusOK, status, string(data))
+		var settled sdk.SettleResponse
+		require.NoError(t, json.Unmarshal(data, &settled))
+		require.True(t, settled.Success, settled.ErrorReason)
+		require.NotEmpty(t, settled.Transaction)
+		require.Equal(t, sdk.Network(testNetwork), settled.Network)
+	})
+
+	t.Run("legacy version 1 payloads are translated", func(t *testing.T) {
+		env.chain.SetBalance(env.token, env.payer, big.NewInt(testAmount))
+		payload, req := env.payment(t, testAmount)
+		wire, err := json.Marshal(wirePayload(t, payload))
+		require.NoError(t, err)
+		payload.Scheme, payload.Network, payload.Payload = sdk.SchemeExact, testChain, wire
+		req.Scheme, req.Network = sdk.SchemeExact, testChain
+
+		verified, err := env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, verified.IsValid, verified.InvalidReason)
+	})
+
+	t.Run("version 2 requests are validated", func(t *testing.T) {
+		incomplete := requirements
+		incomplete.Amount = ""
+		status, data := post(t, "/verify", types.PaymentVerifyRequestV2{X402Version: 2, PaymentPayload: v2Payload, PaymentRequirements: incomplete})
+		require.Equal(t, http.StatusUnprocessableEntity, status)
+		require.Contains(t, string(data), "paymentRequirements.amount")
+
+		status, _ = post(t, "/verify", map[string]any{"x402Version": 2, "paymentHeader": payload})
+		require.Equal(t, http.StatusUnprocessableEntity, status, "version 1 fields are not allowed")
+	})
+
+	t.Run("unsupported versions are rejected", func(t *testing.T) {
+		status, data := post(t, "/settle", map[string]any{"x402Version": 3})
+		require.Equal(t, http.StatusUnprocessableEntity, status)
+		require.Contains(t, string(data), "is not a supported protocol version")
+	})
+}
+
+func TestRPCFaults(t *testing.T) {
+	t.Run("balance lookup fails", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		env.chain.Inject("GetBalance", mock.Fault{Err: errors.New("connection reset"), Times: 1})
+		payload, req := env.payment(t, testAmount)
+
+		_, err := env.client.Verify(t.Context(), payload, req)
+		require.ErrorContains(t, err, "connection reset")
+
+		// the fault is gone after one call
+		verified, err := env.client.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, verified.IsValid, verified.InvalidReason)
+	})
+
+	t.Run("slow submission exceeds the request deadline", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		env.chain.Inject("WriteContract", mock.Fault{Latency: time.Minute})
+		payload, req := env.payment(t, testAmount)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+		defer cancel()
+		_, err := env.client.Settle(ctx, payload, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("settlement deadline asked for by the request", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		env.chain.Inject("WriteContract", mock.Fault{Latency: time.Minute})
+		env.client.SettleTimeout = 100 * time.Millisecond
+		payload, req := env.payment(t, testAmount)
+
+		_, err := env.client.Settle(t.Context(), payload, req)
+		require.ErrorContains(t, err, "status 504")
+		require.ErrorContains(t, err, types.ErrorCodeTimeout)
+		require.Equal(t, settlement.StatusQueued, (<-events).Status)
+		require.Equal(t, settlement.StatusFailed, (<-events).Status)
+	})
+
+	t.Run("deadlines are capped by the server", func(t *testing.T) {
+		env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithTimeouts(api.TimeoutConfig{
+			Verify:    100 * time.Millisecond,
+			MaxSettle: 100 * time.Millisecond,
+		}))
+		env.chain.Inject("GetBalance", mock.Fault{Latency: time.Minute})
+		env.chain.Inject("WriteContract", mock.Fault{Latency: time.Minute})
+		env.client.SettleTimeout = time.Hour
+		payload, req := env.payment(t, testAmount)
+
+		_, err := env.client.Verify(t.Context(), payload, req)
+		require.ErrorContains(t, err, types.ErrorCodeTimeout)
+		_, err = env.client.Settle(t.Context(), payload, req)
+		require.ErrorContains(t, err, types.ErrorCodeTimeout)
+	})
+
+	t.Run("deadlines too long to represent are capped", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		env.chain.Inject("WriteContract", mock.Fault{Latency: 10 * time.Millisecond})
+		payload, req := env.payment(t, testAmount)
+		body, err := json.Marshal(types.PaymentSettleRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req, TimeoutMs: math.MaxInt64})
+		require.NoError(t, err)
+
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/settle").String(), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+	})
+
+	t.Run("reverted settlement", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		payload, req := env.payment(t, testAmount)
+
+		env.chain.RevertNext("authorization is used")
+		estimate, err := env.client.Estimate(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, estimate.Success)
+		require.Contains(t, estimate.Error, mock.ErrReverted.Error())
+
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, settled.Success, "submission succeeds, the revert shows in the receipt")
+
+		evt := waitStatus(t, events, settled.TxHash, settlement.StatusFailed)
+		require.Equal(t, "transaction reverted", evt.Error)
+		require.Equal(t, int64(testAmount), env.chain.Balance(env.token, env.payer).Int64())
+	})
+
+	t.Run("settlement failing simulation is not broadcast", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		env.chain.Inject("SimulateContract", mock.Fault{Err: &evm.RevertError{Reason: "FiatTokenV2: authorization is used or canceled"}, Times: 1})
+		payload, req := env.payment(t, testAmount)
+
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, settled.Success)
+		require.Equal(t, types.ErrAuthorizationUsed.Error(), settled.Error)
+		require.Empty(t, settled.TxHash)
+		require.Zero(t, env.chain.Calls("WriteContract"))
+		require.Equal(t, int64(testAmount), env.chain.Balance(env.token, env.payer).Int64())
+	})
+
+	t.Run("transfer failing without revert", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		payload, req := env.payment(t, testAmount)
+
+		env.chain.FailSilentlyNext()
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, settled.Success, "submission succeeds, the missing transfer shows in the receipt")
+
+		evt := waitStatus(t, events, settled.TxHash, settlement.StatusFailed)
+		require.Equal(t, types.ErrTransferNotEmitted.Error(), evt.Error)
+		require.Equal(t, int64(testAmount), env.chain.Balance(env.token, env.payer).Int64())
+	})
+
+	t.Run("authorization replay is rejected", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		env.chain.SetBalance(env.token, env.payer, big.NewInt(2*testAmount))
+		payload, req := env.payment(t, testAmount)
+
+		first, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		waitStatus(t, events, first.TxHash, settlement.StatusConfirmed)
+
+		replay, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, replay.Success)
+		require.Equal(t, types.ErrAuthorizationUsed.Error(), replay.Error)
+		require.Empty(t, replay.TxHash, "nothing is broadcast")
+		require.Equal(t, int64(testAmount), env.chain.Balance(env.token, env.payer).Int64())
+	})
+
+	t.Run("reverted authorizations can be settled again", func(t *testing.T) {
+		env := newTestEnv(t, 1)
+		events, unsubscribe := env.settlements.Hub().Subscribe()
+		defer unsubscribe()
+		payload, req := env.payment(t, testAmount)
+
+		env.chain.RevertNext("out of gas")
+		first, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		waitStatus(t, events, first.TxHash, settlement.StatusFailed)
+
+		retry, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, retry.Success, retry.Error)
+		waitStatus(t, events, retry.TxHash, settlement.StatusConfirmed)
+	})
+}
+
+func TestRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	chain := mock.NewEVMSigner(84532, testSigner)
+	chain.SetAutoMine(false)
+
+	records, err := store.OpenFile(path)
+	require.NoError(t, err)
+	env := newTestEnvWithStore(t, chain, 1, records)
+	payload, req := env.payment(t, testAmount)
+
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+
+	// the facilitator stops before the settlement is mined
+	env.settlements.Close()
+	require.NoError(t, records.Close())
+
+	records, err = store.OpenFile(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { records.Close() })
+	restarted := newTestEnvWithStore(t, chain, 1, records)
+	events, unsubscribe := restarted.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	require.NoError(t, restarted.settlements.Resume(t.Context()))
+	chain.Mine(1)
+	waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+
+	chain.SetBalance(env.token, env.payer, big.NewInt(testAmount))
+	verified, err := restarted.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, verified.IsValid)
+	require.Equal(t, types.ErrAuthorizationUsed.Error(), verified.InvalidReason)
+
+	replay, err := restarted.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, replay.Success)
+	require.Equal(t, types.ErrAuthorizationUsed.Error(), replay.Error)
+}
+
+func TestSplitPayment(t *testing.T) {
+	const platform = "0x00000000000000000000000000000000000000b1"
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	chain := mock.NewEVMSigner(84532, testSigner)
+	chain.SetAutoMine(false)
+	config := facilitator.NetworkConfig{Network: testNetwork, Confirmations: 1, Split: facilitator.SplitConfig{Enabled: true}}
+
+	records, err := store.OpenFile(path)
+	require.NoError(t, err)
+	env := newTestEnvWithConfig(t, chain, config, records, nil)
+	evmPayload, err := evm.NewEVMPayload(testChain, testToken, env.payer, testSigner, big.NewInt(testAmount).String(), env.signer)
+	require.NoError(t, err)
+	raw, err := json.Marshal(evmPayload)
+	require.NoError(t, err)
+	payload := &types.PaymentPayload{X402Version: int(types.X402VersionV1), Scheme: string(types.EVM), Network: testNetwork, Payload: raw}
+	req, err := types.BuildPaymentRequirements(testNetwork, testToken, "0.01", testSigner,
+		types.WithSplit(types.SplitRecipient{Address: testPayTo, Bps: 9000}, types.SplitRecipient{Address: platform, Bps: 1000}))
+	require.NoError(t, err)
+
+	// the settlement returns once the collection is submitted, without waiting for it
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.Empty(t, settled.Splits)
+
+	// the facilitator stops before the collection is mined, the successor forwards the shares
+	env.settlements.Close()
+	require.NoError(t, records.Close())
+	records, err = store.OpenFile(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { records.Close() })
+	restarted := newTestEnvWithConfig(t, chain, config, records, nil)
+	events, unsubscribe := restarted.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	require.NoError(t, restarted.settlements.Resume(t.Context()))
+	chain.SetAutoMine(true)
+	chain.Mine(1)
+	waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+	require.Equal(t, big.NewInt(9000), chain.Balance(env.token, testPayTo))
+	require.Equal(t, big.NewInt(1000), chain.Balance(env.token, platform))
+	require.Zero(t, chain.Balance(env.token, testSigner).Sign())
+}
+
+func TestDrain(t *testing.T) {
+	chain := mock.NewEVMSigner(84532, testSigner)
+	chain.SetAutoMine(false)
+	records := store.NewMemory()
+	env := newTestEnvWithStore(t, chain, 1, records)
+	payload, req := env.payment(t, testAmount)
+	left, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, left.Success, left.Error)
+
+	// the settlement isn't mined before the deadline and is left to the successor
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, env.settlements.Drain(ctx), context.DeadlineExceeded)
+	successor := newTestEnvWithStore(t, chain, 1, records)
+	events, unsubscribe := successor.settlements.Hub().Subscribe()
+	defer unsubscribe()
+	require.NoError(t, successor.settlements.Resume(t.Context()))
+
+	// a settlement mined while draining is finished before Drain returns
+	payload, req = successor.payment(t, testAmount)
+	settled, err := successor.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	drained := make(chan error, 1)
+	go func() { drained <- successor.settlements.Drain(t.Context()) }()
+	chain.Mine(1)
+	require.NoError(t, <-drained)
+	confirmed := map[string]bool{}
+	for len(confirmed) < 2 {
+		select {
+		case evt := <-events:
+			if evt.Status == settlement.StatusConfirmed {
+				confirmed[evt.TxHash] = true
+			}
+		case <-time.After(eventTimeout):
+			t.Fatalf("timed out waiting for settlements to be confirmed, got %v", confirmed)
+		}
+	}
+	require.Equal(t, map[string]bool{left.TxHash: true, settled.TxHash: true}, confirmed)
+}
+
+func TestListen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported")
+	}
+	config := api.HTTPServerConfig{ReusePort: true}
+	first, err := api.Listen(t.Context(), "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := api.Listen(t.Context(), first.Addr().String(), config)
+	require.NoError(t, err, "the successor binds the port of the running process")
+	second.Close()
+
+	_, err = api.Listen(t.Context(), first.Addr().String(), api.HTTPServerConfig{})
+	require.Error(t, err)
+}
+
+func TestReorg(t *testing.T) {
+	env := newTestEnv(t, 3)
+	env.chain.SetAutoMine(false)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+	payload, req := env.payment(t, testAmount)
+
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+
+	env.chain.Mine(1)
+	mined := waitStatus(t, events, settled.TxHash, settlement.StatusMined)
+
+	// the block of the settlement is replaced, the transaction is included again later
+	env.chain.Reorg(1)
+	env.chain.Mine(3)
+
+	confirmed := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+	require.Greater(t, confirmed.BlockNumber, mined.BlockNumber)
+	require.Equal(t, int64(testAmount), env.chain.Balance(env.token, testPayTo).Int64())
+}
+
+func TestRecipientRegistration(t *testing.T) {
+	recipients := recipient.New(store.NewMemory(), recipient.Config{})
+	env := newTestEnvOnChain(t, mock.NewEVMSigner(84532, testSigner), 1, api.WithRecipients(recipients))
+	endpoint := env.client.BaseURL.JoinPath("/admin/recipients")
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	register := func(t *testing.T, issuedAt time.Time, signer *ecdsa.PrivateKey) int {
+		t.Helper()
+		sig, err := crypto.Sign(accounts.TextHash([]byte(recipient.Message(testNetwork, address, issuedAt))), signer)
+		require.NoError(t, err)
+		body, _ := json.Marshal(recipient.Registration{Network: testNetwork, Address: address, IssuedAt: issuedAt, Signature: hexutil.Encode(sig)})
+		resp, err := http.Post(endpoint.String(), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, register(t, time.Now(), other), "signed by another key")
+	require.Equal(t, http.StatusCreated, register(t, time.Now(), key))
+
+	resp, err := http.Get(endpoint.String())
+	require.NoError(t, err)
+	var listed []types.Recipient
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed, 1)
+	require.Equal(t, address, listed[0].Address)
+	registered, err := recipients.IsRegistered(t.Context(), testNetwork, strings.ToLower(address))
+	require.NoError(t, err)
+	require.True(t, registered)
+
+	req, err := http.NewRequest(http.MethodDelete, endpoint.JoinPath(testNetwork, address).String(), nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	registered, err = recipients.IsRegistered(t.Context(), testNetwork, address)
+	require.NoError(t, err)
+	require.False(t, registered)
+}
+
+func TestRefunds(t *testing.T) {
+	env := newTestEnv(t, 1)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+	endpoint := env.client.BaseURL.JoinPath("/admin/refunds")
+	refund := func(t *testing.T, body types.RefundRequest) (int, types.Refund) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		resp, err := http.Post(endpoint.String(), "application/json", bytes.NewReader(data))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var created types.Refund
+		if resp.StatusCode == http.StatusCreated {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		}
+		return resp.StatusCode, created
+	}
+
+	payload, req := env.payment(t, testAmount)
+	settled, err := env.client.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	settlementID := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed).ID
+
+	status, _ := refund(t, types.RefundRequest{SettlementID: "unknown", Amount: "1", TxHash: "0x01"})
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = refund(t, types.RefundRequest{SettlementID: settlementID, Amount: "1"})
+	require.Equal(t, http.StatusBadRequest, status, "neither an authorization nor a transaction")
+
+	// the payee authorizes the transfer back to the payer
+	payeeKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payee, err := evm.GetAddrssFromPrivateKey(payeeKey.Serialize())
+	require.NoError(t, err)
+	env.chain.SetBalance(env.token, payee.Hex(), big.NewInt(testAmount))
+	evmPayload, err := evm.NewEVMPayload(testChain, testToken, payee.Hex(), env.payer, "4000", evm.NewRawPrivateSigner(payeeKey.Serialize()))
+	require.NoError(t, err)
+	authorization, err := json.Marshal(evmPayload)
+	require.NoError(t, err)
+	status, authorized := refund(t, types.RefundRequest{
+		SettlementID: settlementID,
+		Amount:       "4000",
+		Reason:       "order cancelled",
+		PaymentPayload: &types.PaymentPayload{
+			X402Version: int(types.X402VersionV1),
+			Scheme:      string(types.EVM),
+			Network:     testNetwork,
+			Payload:     authorization,
+		},
+	})
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, "submitted", authorized.Status)
+	require.Equal(t, payee.Hex(), authorized.From)
+	require.Equal(t, env.payer, authorized.To)
+	confirmed := waitStatus(t, events, authorized.TxHash, settlement.StatusConfirmed)
+	require.Equal(t, settlementID, confirmed.ID)
+	require.Equal(t, authorized.ID, confirmed.RefundID)
+	require.Equal(t, int64(4000), env.chain.Balance(env.token, env.payer).Int64())
+
+	// a transfer the payee sent itself is only tracked
+	txHash, err := env.chain.SendTransaction(t.Context(), env.token, nil)
+	require.NoError(t, err)
+	status, sent := refund(t, types.RefundRequest{SettlementID: settlementID, Amount: "6000", TxHash: txHash})
+	require.Equal(t, http.StatusCreated, status)
+	waitStatus(t, events, txHash, settlement.StatusConfirmed)
+
+	resp, err := http.Get(endpoint.String() + "?settlementId=" + settlementID)
+	require.NoError(t, err)
+	var listed []types.Refund
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed, 2)
+	require.Equal(t, authorized.ID, listed[0].ID)
+
+	resp, err = http.Get(endpoint.JoinPath(sent.ID).String())
+	require.NoError(t, err)
+	var fetched types.Refund
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fetched))
+	resp.Body.Close()
+	require.Equal(t, "confirmed", fetched.Status)
+	require.NotZero(t, fetched.BlockNumber)
+}
+
+func TestSettlementDebug(t *testing.T) {
+	env := newTestEnv(t, 1)
+	events, unsubscribe := env.settlements.Hub().Subscribe()
+	defer unsubscribe()
+	debug := func(t *testing.T, id string) (int, types.SettlementDebug) {
+		t.Helper()
+		resp, err := http.Get(env.client.BaseURL.JoinPath("/admin/settlements", id, "debug").String())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var trail types.SettlementDebug
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&trail))
+		}
+		return resp.StatusCode, trail
+	}
+
+	status, _ := debug(t, "unknown")
+	require.Equal(t, http.StatusNotFound, status)
+
+	t.Run("failed simulation", func(t *testing.T) {
+		env.chain.Inject("SimulateContract", mock.Fault{Err: &evm.RevertError{Reason: "FiatTokenV2: authorization is used or canceled", Data: []byte{0x08, 0xc3, 0x79, 0xa0}}, Times: 1})
+		payload, req := env.payment(t, testAmount)
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, settled.Success)
+		evt := waitStatus(t, events, "", settlement.StatusFailed)
+
+		status, trail := debug(t, evt.ID)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "failed", trail.Status)
+		require.Equal(t, settled.Error, trail.Error)
+		require.Len(t, trail.Diagnostics, 2)
+		require.Equal(t, diagnostics.KindSimulation, trail.Diagnostics[0].Kind)
+		require.Equal(t, "0x08c379a0", trail.Diagnostics[0].Data["revertData"])
+		require.Equal(t, diagnostics.KindError, trail.Diagnostics[1].Kind)
+	})
+
+	t.Run("reverted transaction", func(t *testing.T) {
+		payload, req := env.payment(t, testAmount)
+		env.chain.RevertNext("authorization is used")
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		evt := waitStatus(t, events, settled.TxHash, settlement.StatusFailed)
+
+		_, trail := debug(t, evt.ID)
+		require.Equal(t, settled.TxHash, trail.TxHash)
+		kinds := make([]diagnostics.Kind, len(trail.Diagnostics))
+		for i, entry := range trail.Diagnostics {
+			kinds[i] = entry.Kind
+		}
+		require.Equal(t, []diagnostics.Kind{diagnostics.KindSimulation, diagnostics.KindReceipt}, kinds)
+		require.Equal(t, "false", trail.Diagnostics[1].Data["success"])
+	})
+
+	t.Run("confirmed settlements keep no trail", func(t *testing.T) {
+		payload, req := env.payment(t, testAmount)
+		settled, err := env.client.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		evt := waitStatus(t, events, settled.TxHash, settlement.StatusConfirmed)
+
+		status, trail := debug(t, evt.ID)
+		require.Equal(t, http.StatusOK, status)
+		require.Empty(t, trail.Diagnostics)
+	})
+}
+
+func TestNetworkPause(t *testing.T) {
+	env := newTestEnv(t, 1)
+	admin := func(t *testing.T, network, action string) (int, types.NetworkPause) {
+		t.Helper()
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/admin/networks", network, action).String(), "application/json", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var state types.NetworkPause
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+		}
+		return resp.StatusCode, state
+	}
+	settle := func(t *testing.T) int {
+		t.Helper()
+		payload, req := env.payment(t, testAmount/4)
+		raw, err := json.Marshal(types.PaymentSettleRequest{X402Version: 1, PaymentHeader: *payload, PaymentRequirements: *req})
+		require.NoError(t, err)
+		resp, err := http.Post(env.client.BaseURL.JoinPath("/settle").String(), "application/json", bytes.NewReader(raw))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	status, _ := admin(t, "eip155:1", "pause")
+	require.Equal(t, http.StatusNotFound, status)
+
+	status, paused := admin(t, testNetwork, "pause")
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, paused.Paused)
+	require.NotNil(t, paused.PausedAt)
+	_, again := admin(t, testNetwork, "pause")
+	require.Equal(t, paused.PausedAt.Unix(), again.PausedAt.Unix(), "pausing a paused network keeps the time it was paused")
+
+	require.Equal(t, http.StatusServiceUnavailable, settle(t))
+	payload, req := env.payment(t, testAmount/4)
+	verified, err := env.client.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, "payments are still verified")
+	supported, err := env.client.Supported(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, true, supported.Kinds[0].Extra["paused"])
+
+	status, resumed := admin(t, testNetwork, "resume")
+	require.Equal(t, http.StatusOK, status)
+	require.False(t, resumed.Paused)
+	require.Equal(t, http.StatusOK, settle(t))
+	supported, err = env.client.Supported(t.Context())
+	require.NoError(t, err)
+	require.NotContains(t, supported.Kinds[0].Extra, "paused")
+}
+
+func TestGeneratedClient(t *testing.T) {
+	env := newTestEnv(t, 1)
+	c := gen.NewClient(env.client.BaseURL.String())
+
+	supported, err := c.Supported(t.Context())
+	require.NoError(t, err)
+	require.NotEmpty(t, supported.Kinds)
+	spec, err := c.OpenAPI(t.Context())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(spec, "openapi: 3.1.0\n"))
+
+	// requests built by resource servers from the generated types are accepted as they are
+	payload, req := env.payment(t, testAmount)
+	data, err := json.Marshal(types.PaymentVerifyRequest{X402Version: int(types.X402VersionV1), PaymentHeader: *payload, PaymentRequirements: *req})
+	require.NoError(t, err)
+	var body gen.PaymentVerifyRequest
+	require.NoError(t, json.Unmarshal(data, &body))
+	verified, err := c.Verify(t.Context(), &body)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+
+	var settleBody gen.PaymentSettleRequest
+	require.NoError(t, json.Unmarshal(data, &settleBody))
+	settled, err := c.Settle(t.Context(), &settleBody)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+	require.NotEmpty(t, settled.TxHash)
+
+	_, err = c.SettlementDebug(t.Context(), "unknown")
+	var apiErr *gen.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Equal(t, "Settlement not found", apiErr.Message)
+}
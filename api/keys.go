@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// CreateAPIKey creates an API key
+// @Summary      Create API key
+// @ID           createApiKey
+// @Description  Create an API key granting the scopes, optionally limited to some networks. The key is returned once, only the hash of its secret is stored (localhost or admin API key)
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      apikey.Spec  true  "Key to create"
+// @Success      201   {object}  types.CreatedAPIKey
+// @Failure      400   {object}  echo.HTTPError
+// @Failure      403   {object}  echo.HTTPError
+// @Failure      409   {object}  echo.HTTPError
+// @Failure      500   {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/keys [post]
+func (s *Server) CreateAPIKey(c echo.Context) error {
+	var spec apikey.Spec
+	if err := c.Bind(&spec); err != nil {
+		return err
+	}
+	key, secret, err := s.apiKeys.Create(c.Request().Context(), spec)
+	if err != nil {
+		return apiKeyError(err)
+	}
+	return c.JSON(http.StatusCreated, types.CreatedAPIKey{APIKey: apiKeyResponse(key), Key: secret})
+}
+
+// ListAPIKeys lists the API keys
+// @Summary      List API keys
+// @ID           listApiKeys
+// @Description  List all API keys, including revoked ones, oldest first (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   types.APIKey
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/keys [get]
+func (s *Server) ListAPIKeys(c echo.Context) error {
+	keys, err := s.apiKeys.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	resp := make([]types.APIKey, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, apiKeyResponse(key))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetAPIKey returns an API key
+// @Summary      Get API key
+// @ID           getApiKey
+// @Description  Get an API key, without its secret (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "ID of the key"
+// @Success      200  {object}  types.APIKey
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      404  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/keys/{id} [get]
+func (s *Server) GetAPIKey(c echo.Context) error {
+	key, err := s.apiKeys.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return apiKeyError(err)
+	}
+	return c.JSON(http.StatusOK, apiKeyResponse(key))
+}
+
+// UpdateAPIKey changes the scopes of an API key
+// @Summary      Update API key
+// @ID           updateApiKey
+// @Description  Replace the name, scopes and networks of an API key, its secret stays the same. Other instances apply the change within 30 seconds (localhost or admin API key)
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string       true  "ID of the key"
+// @Param        body  body      apikey.Spec  true  "New settings of the key, its id is ignored"
+// @Success      200   {object}  types.APIKey
+// @Failure      400   {object}  echo.HTTPError
+// @Failure      403   {object}  echo.HTTPError
+// @Failure      404   {object}  echo.HTTPError
+// @Failure      500   {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/keys/{id} [put]
+func (s *Server) UpdateAPIKey(c echo.Context) error {
+	var spec apikey.Spec
+	if err := c.Bind(&spec); err != nil {
+		return err
+	}
+	key, err := s.apiKeys.Update(c.Request().Context(), c.Param("id"), spec)
+	if err != nil {
+		return apiKeyError(err)
+	}
+	return c.JSON(http.StatusOK, apiKeyResponse(key))
+}
+
+// RevokeAPIKey revokes an API key
+// @Summary      Revoke API key
+// @ID           revokeApiKey
+// @Description  Revoke an API key, requests carrying it are rejected afterwards. Other instances apply the revocation within 30 seconds (localhost or admin API key)
+// @Tags         admin
+// @Param        id  path  string  true  "ID of the key"
+// @Success      204
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      404  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/keys/{id} [delete]
+func (s *Server) RevokeAPIKey(c echo.Context) error {
+	if _, err := s.apiKeys.Revoke(c.Request().Context(), c.Param("id")); err != nil {
+		return apiKeyError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// apiKeyError maps errors of the key manager to responses.
+func apiKeyError(err error) error {
+	switch {
+	case errors.Is(err, apikey.ErrInvalidSpec):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, apikey.ErrDuplicateID):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, store.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "API key doesn't exist")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func apiKeyResponse(key *store.APIKey) types.APIKey {
+	return types.APIKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		Networks:  key.Networks,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets on
+const listenFDsStart = 3
+
+// Listen returns the listener the HTTP server accepts connections on. A socket
+// passed by systemd socket activation is taken over, so the socket outlives
+// the process and requests arriving during a restart wait in its backlog.
+// Otherwise addr is bound, with SO_REUSEPORT if configured, so a new process
+// can bind it while the old one finishes its requests.
+func Listen(ctx context.Context, addr string, config HTTPServerConfig) (net.Listener, error) {
+	if l, err := activatedListener(); l != nil || err != nil {
+		return l, err
+	}
+	var lc net.ListenConfig
+	if config.ReusePort {
+		lc.Control = reusePort
+	}
+	return lc.Listen(ctx, "tcp", addr)
+}
+
+// Activated reports whether systemd passed the process a socket to listen on.
+func Activated() bool {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	return err == nil && pid == os.Getpid() && os.Getenv("LISTEN_FDS") != ""
+}
+
+// activatedListener returns the first socket passed by systemd, nil if none is.
+func activatedListener() (net.Listener, error) {
+	if !Activated() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	// the sockets aren't passed on to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(listenFDsStart, "systemd-socket")
+	if file == nil {
+		return nil, errors.New("socket passed by systemd is not open")
+	}
+	defer file.Close()
+	l, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("socket passed by systemd: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package api
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+func reusePort(network, address string, conn syscall.RawConn) error {
+	return fmt.Errorf("reusePort is not supported on %s", runtime.GOOS)
+}
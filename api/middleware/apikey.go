@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+)
+
+// HeaderAPIKey is the header carrying the API keys managed under /admin/keys
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyAuth is a middleware that accepts only requests carrying a valid key
+// of the manager that grants the scope of the route: settle for /settle and
+// /settle/estimate, read-status for the settlement stream and receipts, admin under /admin and
+// verify for the other routes. The ID of the key is the key ID of the request,
+// so keys can belong to tenants, and the key is added to the request context.
+func APIKeyAuth(keys *apikey.Manager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			presented := req.Header.Get(HeaderAPIKey)
+			if presented == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing API key")
+			}
+			key, err := keys.Authenticate(req.Context(), presented)
+			if errors.Is(err, apikey.ErrInvalidKey) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+			} else if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to look up API key").SetInternal(err)
+			}
+			if scope := keyScope(RoutePath(c)); !apikey.HasScope(key, scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key lacks the "+scope+" scope")
+			}
+
+			ctx := context.WithValue(req.Context(), keyIDKey, key.ID)
+			ctx = context.WithValue(ctx, scopesKey, key.Scopes)
+			ctx = apikey.NewContext(ctx, key)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// LocalhostOrAPIKey is a middleware that accepts requests from a loopback
+// address, like LocalhostOnly, and others carrying an API key with the admin
+// scope if keys is not nil.
+func LocalhostOrAPIKey(keys *apikey.Manager) echo.MiddlewareFunc {
+	localhost := LocalhostOnly()
+	if keys == nil {
+		return localhost
+	}
+	keyAuth := APIKeyAuth(keys)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		localNext, keyNext := localhost(next), keyAuth(next)
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(HeaderAPIKey) != "" && !isLoopback(c.Request()) {
+				return keyNext(c)
+			}
+			return localNext(c)
+		}
+	}
+}
+
+// keyScope returns the scope an API key needs for the route.
+func keyScope(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/settle"):
+		return apikey.ScopeSettle
+	case path == "/admin" || strings.HasPrefix(path, "/admin/"):
+		return apikey.ScopeAdmin
+	case path == "/ws/settlements" || strings.HasPrefix(path, "/receipts/"):
+		return apikey.ScopeReadStatus
+	default:
+		return apikey.ScopeVerify
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestLocalhostOrAPIKey(t *testing.T) {
+	keys := apikey.New(store.NewMemory())
+	_, admin, err := keys.Create(t.Context(), apikey.Spec{ID: "ops", Scopes: []string{apikey.ScopeAdmin}})
+	require.NoError(t, err)
+	_, settle, err := keys.Create(t.Context(), apikey.Spec{ID: "shop", Scopes: []string{apikey.ScopeSettle}})
+	require.NoError(t, err)
+
+	e := echo.New()
+	handler := LocalhostOrAPIKey(keys)(func(c echo.Context) error {
+		require.Equal(t, "ops", GetKeyID(c.Request().Context()))
+		return c.NoContent(http.StatusOK)
+	})
+
+	cases := []struct {
+		remoteAddr string
+		key        string
+		status     int
+	}{
+		{"10.0.0.1:1234", admin, http.StatusOK},
+		{"10.0.0.1:1234", "", http.StatusForbidden},
+		{"10.0.0.1:1234", settle, http.StatusForbidden},
+		{"10.0.0.1:1234", admin + "x", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.key != "" {
+			req.Header.Set(HeaderAPIKey, tc.key)
+		}
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.SetPath("/admin/config")
+
+		err := handler(c)
+		if tc.status == http.StatusOK {
+			require.NoError(t, err, tc.key)
+		} else {
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr, tc.key)
+			require.Equal(t, tc.status, httpErr.Code, tc.key)
+		}
+	}
+}
+
+func TestKeyScope(t *testing.T) {
+	for path, scope := range map[string]string{
+		"/verify":           apikey.ScopeVerify,
+		"/settle":           apikey.ScopeSettle,
+		"/settle/estimate":  apikey.ScopeSettle,
+		"/ws/settlements":   apikey.ScopeReadStatus,
+		"/receipts/:txHash": apikey.ScopeReadStatus,
+		"/admin/keys/:id":   apikey.ScopeAdmin,
+	} {
+		require.Equal(t, scope, keyScope(path), path)
+	}
+}
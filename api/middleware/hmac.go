@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/internal/hmacauth"
+	"github.com/gosuda/x402-facilitator/internal/nonce"
+)
+
+const (
+	// defaultHMACMaxSkew is the accepted clock difference if the configuration doesn't say otherwise
+	defaultHMACMaxSkew = 5 * time.Minute
+	// maxSignedBodySize bounds the body read to verify a signature
+	maxSignedBodySize = 1 << 20
+	// maxNonceLength bounds the memory a single remembered nonce takes
+	maxNonceLength = 128
+)
+
+// HMACConfig configures request authentication with shared secrets.
+type HMACConfig struct {
+	// Shared secrets by key ID. Authentication is disabled if empty
+	Secrets map[string]string `mapstructure:"secrets"`
+	// Accepted difference between the request timestamp and the server clock, 0 means five minutes
+	MaxSkew time.Duration `mapstructure:"maxSkew"`
+}
+
+// GetKeyID returns the ID of the key the request was authenticated with,
+// empty if it wasn't authenticated.
+func GetKeyID(ctx context.Context) string {
+	keyID, _ := ctx.Value(keyIDKey).(string)
+	return keyID
+}
+
+// HMACAuth is a middleware that accepts only requests signed with one of the
+// shared secrets, see package hmacauth for the signature format. Nonces are
+// remembered for twice the accepted clock skew, so a signed request can't be
+// replayed while its timestamp is still accepted. Timestamps and nonces are
+// measured with clk.
+func HMACAuth(config HMACConfig, clk clock.Clock) echo.MiddlewareFunc {
+	maxSkew := config.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = defaultHMACMaxSkew
+	}
+	nonces := nonce.NewCache(2 * maxSkew)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			keyID := req.Header.Get(hmacauth.HeaderKeyID)
+			secret, ok := config.Secrets[keyID]
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unknown or missing key ID")
+			}
+			signature, ok := strings.CutPrefix(req.Header.Get(hmacauth.HeaderSignature), hmacauth.SignaturePrefix)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing request signature")
+			}
+
+			timestamp := req.Header.Get(hmacauth.HeaderTimestamp)
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid request timestamp")
+			}
+			if skew := clock.Since(clk, time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Request timestamp is outside the accepted window")
+			}
+			requestNonce := req.Header.Get(hmacauth.HeaderNonce)
+			if requestNonce == "" || len(requestNonce) > maxNonceLength {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid request nonce")
+			}
+
+			body, err := io.ReadAll(io.LimitReader(req.Body, maxSignedBodySize+1))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+			}
+			if len(body) > maxSignedBodySize {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Request body is too large")
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			expected := hmacauth.Signature([]byte(secret), timestamp, requestNonce, req.Method, req.URL.RequestURI(), body)
+			if !hmac.Equal([]byte(signature), []byte(expected)) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid request signature")
+			}
+			// only nonces of valid signatures are remembered, others can't be replayed anyway
+			if !nonces.Add(keyID+"/"+requestNonce, clk.Now()) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Request nonce was already used")
+			}
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), keyIDKey, keyID)))
+			return next(c)
+		}
+	}
+}
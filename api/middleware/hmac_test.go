@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/internal/hmacauth"
+)
+
+func TestHMACAuth(t *testing.T) {
+	e := echo.New()
+	clk := clock.NewFake(time.Now())
+	handler := HMACAuth(HMACConfig{Secrets: map[string]string{"shop": "s3cret"}}, clk)(func(c echo.Context) error {
+		// the body must still be readable after verification
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(body))
+	})
+
+	const body = `{"x402Version":1}`
+	signed := func(keyID, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/settle", strings.NewReader(body))
+		hmacauth.Sign(req, []byte(body), keyID, []byte(secret))
+		return req
+	}
+	serve := func(req *http.Request) (int, string) {
+		rec := httptest.NewRecorder()
+		if err := handler(e.NewContext(req, rec)); err != nil {
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			return httpErr.Code, ""
+		}
+		return rec.Code, rec.Body.String()
+	}
+
+	t.Run("signed requests pass once", func(t *testing.T) {
+		req := signed("shop", "s3cret")
+		replay := req.Clone(req.Context())
+		replay.Body = io.NopCloser(strings.NewReader(body))
+
+		code, got := serve(req)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, body, got)
+
+		code, _ = serve(replay)
+		require.Equal(t, http.StatusUnauthorized, code, "replayed nonce")
+	})
+
+	t.Run("timestamps are measured with the clock", func(t *testing.T) {
+		req := signed("shop", "s3cret")
+		clk.Advance(time.Hour)
+		defer clk.Advance(-time.Hour)
+
+		code, _ := serve(req)
+		require.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("invalid requests are rejected", func(t *testing.T) {
+		cases := map[string]func() *http.Request{
+			"unknown key ID": func() *http.Request { return signed("other", "s3cret") },
+			"wrong secret":   func() *http.Request { return signed("shop", "guess") },
+			"unsigned": func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/settle", strings.NewReader(body))
+			},
+			"tampered body": func() *http.Request {
+				req := signed("shop", "s3cret")
+				req.Body = io.NopCloser(strings.NewReader(`{"x402Version":2}`))
+				return req
+			},
+			"other endpoint": func() *http.Request {
+				req := signed("shop", "s3cret")
+				req.URL.Path = "/verify"
+				return req
+			},
+			"expired timestamp": func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/settle", strings.NewReader(body))
+				timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+				req.Header.Set(hmacauth.HeaderKeyID, "shop")
+				req.Header.Set(hmacauth.HeaderTimestamp, timestamp)
+				req.Header.Set(hmacauth.HeaderNonce, "n1")
+				req.Header.Set(hmacauth.HeaderSignature, hmacauth.SignaturePrefix+hmacauth.Signature([]byte("s3cret"), timestamp, "n1", http.MethodPost, "/settle", []byte(body)))
+				return req
+			},
+		}
+		for name, request := range cases {
+			code, _ := serve(request())
+			require.Equal(t, http.StatusUnauthorized, code, name)
+		}
+	})
+}
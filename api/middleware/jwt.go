@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/internal/jwks"
+)
+
+// Scopes of the payment endpoints. The settle scope includes verifying.
+const (
+	ScopeVerify = "x402:verify"
+	ScopeSettle = "x402:settle"
+)
+
+const (
+	defaultTenantClaim = "sub"
+	defaultScopeClaim  = "scope"
+	defaultJWTLeeway   = 30 * time.Second
+)
+
+// jwtMethods are the accepted signing algorithms, all asymmetric so the
+// facilitator never holds a key able to issue tokens
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWTConfig configures authentication with bearer tokens issued by an
+// identity provider.
+type JWTConfig struct {
+	// URL of the JSON Web Key Set of the provider. Authentication is disabled if empty
+	JWKSURL string `mapstructure:"jwksUrl"`
+	// Required iss claim, not checked if empty
+	Issuer string `mapstructure:"issuer"`
+	// Required aud claim, not checked if empty
+	Audience string `mapstructure:"audience"`
+	// Claim whose value is the key ID of the token, which tenants reference, "sub" if empty
+	TenantClaim string `mapstructure:"tenantClaim"`
+	// Claim holding the scopes, a space separated string or an array, "scope" if empty
+	ScopeClaim string `mapstructure:"scopeClaim"`
+	// How often the key set is fetched again, 0 means hourly
+	RefreshInterval time.Duration `mapstructure:"refreshInterval"`
+	// Accepted clock difference for the exp, nbf and iat claims, 0 means 30 seconds
+	Leeway time.Duration `mapstructure:"leeway"`
+}
+
+// GetScopes returns the scopes of the token the request was authenticated
+// with, nil if it wasn't authenticated with a token.
+func GetScopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey).([]string)
+	return scopes
+}
+
+// JWTAuth is a middleware that accepts only requests carrying a bearer token
+// signed by a key of the provider, issued to the audience and still valid.
+// The tenant claim becomes the key ID of the request, so tenants are mapped
+// to tokens like to HMAC keys. Requests to /settle and /settle/estimate need
+// the settle scope, other endpoints the verify or the settle scope.
+func JWTAuth(config JWTConfig) echo.MiddlewareFunc {
+	keys := jwks.New(config.JWKSURL, config.RefreshInterval)
+	tenantClaim := config.TenantClaim
+	if tenantClaim == "" {
+		tenantClaim = defaultTenantClaim
+	}
+	scopeClaim := config.ScopeClaim
+	if scopeClaim == "" {
+		scopeClaim = defaultScopeClaim
+	}
+	leeway := config.Leeway
+	if leeway == 0 {
+		leeway = defaultJWTLeeway
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods(jwtMethods), jwt.WithoutClaimsValidation())
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			raw, ok := BearerToken(req)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing bearer token")
+			}
+
+			claims := jwt.MapClaims{}
+			_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+				kid, _ := token.Header["kid"].(string)
+				return keys.Key(req.Context(), kid)
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid bearer token")
+			}
+			if err := checkClaims(claims, config, leeway); err != "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, err)
+			}
+
+			keyID, _ := claims[tenantClaim].(string)
+			if keyID == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Bearer token has no "+tenantClaim+" claim")
+			}
+			scopes := claimScopes(claims[scopeClaim])
+			if !slices.Contains(scopes, requiredScope(RoutePath(c))) && !slices.Contains(scopes, ScopeSettle) {
+				return echo.NewHTTPError(http.StatusForbidden, "Bearer token lacks the "+requiredScope(RoutePath(c))+" scope")
+			}
+
+			ctx := context.WithValue(req.Context(), keyIDKey, keyID)
+			ctx = context.WithValue(ctx, scopesKey, scopes)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// BearerToken returns the token of the Authorization header of the request.
+func BearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get(echo.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// checkClaims checks the time, issuer and audience claims, returning the
+// problem or an empty string.
+func checkClaims(claims jwt.MapClaims, config JWTConfig, leeway time.Duration) string {
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), true) {
+		return "Bearer token expired"
+	}
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) || !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return "Bearer token is not valid yet"
+	}
+	if config.Issuer != "" && !claims.VerifyIssuer(config.Issuer, true) {
+		return "Bearer token was issued by another issuer"
+	}
+	if config.Audience != "" && !claims.VerifyAudience(config.Audience, true) {
+		return "Bearer token was issued for another audience"
+	}
+	return ""
+}
+
+// claimScopes reads a scope claim, a space separated string (RFC 8693) or an
+// array of strings as issued by some providers.
+func claimScopes(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		scopes := make([]string, 0, len(v))
+		for _, scope := range v {
+			if s, ok := scope.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+		return scopes
+	default:
+		return nil
+	}
+}
+
+// requiredScope returns the scope a request to the route needs.
+func requiredScope(path string) string {
+	if strings.HasPrefix(path, "/settle") {
+		return ScopeSettle
+	}
+	return ScopeVerify
+}
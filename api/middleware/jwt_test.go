@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTAuth(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+			{"kty": "EC", "kid": "k1", "crv": "P-256", "x": encode(key.X.FillBytes(make([]byte, 32))), "y": encode(key.Y.FillBytes(make([]byte, 32)))},
+		}})
+	}))
+	defer jwks.Close()
+
+	e := echo.New()
+	handler := JWTAuth(JWTConfig{JWKSURL: jwks.URL, Issuer: "https://idp.example/", Audience: "x402"})(func(c echo.Context) error {
+		ctx := c.Request().Context()
+		return c.String(http.StatusOK, GetKeyID(ctx)+" "+strings.Join(GetScopes(ctx), ","))
+	})
+
+	token := func(kid string, claims jwt.MapClaims) string {
+		base := jwt.MapClaims{
+			"iss":   "https://idp.example/",
+			"aud":   "x402",
+			"sub":   "shop",
+			"scope": "x402:settle",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range claims {
+			base[name] = value
+		}
+		signed := jwt.NewWithClaims(jwt.SigningMethodES256, base)
+		signed.Header["kid"] = kid
+		raw, err := signed.SignedString(key)
+		require.NoError(t, err)
+		return raw
+	}
+	serve := func(path, bearer string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if bearer != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath(path)
+		if err := handler(c); err != nil {
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			return httpErr.Code, ""
+		}
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := serve("/settle", token("k1", nil))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "shop x402:settle", body)
+
+	verifyOnly := token("k1", jwt.MapClaims{"scope": []string{"x402:verify"}})
+	code, _ = serve("/verify", verifyOnly)
+	require.Equal(t, http.StatusOK, code)
+	code, _ = serve("/settle", verifyOnly)
+	require.Equal(t, http.StatusForbidden, code, "verify-only tokens can't settle")
+
+	hmacSigned, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "shop"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	cases := map[string]string{
+		"missing token":    "",
+		"unknown key":      token("k2", nil),
+		"encryption key":   token("enc", nil),
+		"expired":          token("k1", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}),
+		"not yet valid":    token("k1", jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}),
+		"other issuer":     token("k1", jwt.MapClaims{"iss": "https://other.example/"}),
+		"other audience":   token("k1", jwt.MapClaims{"aud": []string{"other"}}),
+		"no tenant claim":  token("k1", jwt.MapClaims{"sub": ""}),
+		"symmetric method": hmacSigned,
+		"malformed":        "not.a.token",
+	}
+	for name, bearer := range cases {
+		code, _ := serve("/verify", bearer)
+		require.Equal(t, http.StatusUnauthorized, code, name)
+	}
+}
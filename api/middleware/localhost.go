@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// LocalhostOnly is a middleware that rejects requests not coming from a loopback address
+// The remote address of the connection is used, forwarding headers are ignored on purpose
+func LocalhostOnly() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isLoopback(c.Request()) {
+				return echo.NewHTTPError(http.StatusForbidden, "Only available from localhost")
+			}
+			return next(c)
+		}
+	}
+}
+
+// isLoopback reports whether the connection of the request comes from a loopback address.
+func isLoopback(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalhostOnly(t *testing.T) {
+	e := echo.New()
+	handler := LocalhostOnly()(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	cases := []struct {
+		remoteAddr string
+		forwarded  string
+		allowed    bool
+	}{
+		{"127.0.0.1:1234", "", true},
+		{"[::1]:1234", "", true},
+		{"10.0.0.1:1234", "", false},
+		{"10.0.0.1:1234", "127.0.0.1", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/debug/routes", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwarded != "" {
+			req.Header.Set(echo.HeaderXForwardedFor, tc.forwarded)
+		}
+		rec := httptest.NewRecorder()
+
+		err := handler(e.NewContext(req, rec))
+		if tc.allowed {
+			require.NoError(t, err, tc.remoteAddr)
+		} else {
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr, tc.remoteAddr)
+			require.Equal(t, http.StatusForbidden, httpErr.Code)
+		}
+	}
+}
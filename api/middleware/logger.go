@@ -5,7 +5,8 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
 )
 
 // Logger returns a middleware that logs HTTP requests and responses
@@ -14,34 +15,30 @@ func Logger() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			req := c.Request()
-			requestID := GetRequestID(req.Context())
-
-			// Enhance the request context with the logger
-			ctx := req.Context()
-			ctx = log.With().
-				Str("request_id", requestID).
-				Logger().
-				WithContext(ctx)
-
-			// Update the request with the enhanced context
-			c.SetRequest(req.WithContext(ctx))
+			if GetRequestID(req.Context()) == "" {
+				// without the RequestID middleware, requests are logged with the global logger
+				c.SetRequest(req.WithContext(logging.FromContext(req.Context()).WithContext(req.Context())))
+			}
 
 			// Time the request processing
 			start := time.Now()
 			err := next(c)
+			// the payment metadata of the request is logged with it, e.g. the
+			// request ID and the tenant
+			logger := logging.Ctx(c.Request().Context(), logging.HTTP)
 
 			// Determine log level based on the response status
 			var evt *zerolog.Event
 			if err != nil {
-				evt = log.Ctx(ctx).Error().Err(err)
+				evt = logger.Error().Err(err)
 			} else {
 				statusCode := c.Response().Status
 				if statusCode >= 500 {
-					evt = log.Ctx(ctx).Error()
+					evt = logger.Error()
 				} else if statusCode >= 400 {
-					evt = log.Ctx(ctx).Warn()
+					evt = logger.Warn()
 				} else {
-					evt = log.Ctx(ctx).Info()
+					evt = logger.Info()
 				}
 			}
 
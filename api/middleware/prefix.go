@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// routePrefixKey is the echo context key of the prefix the API is mounted under
+const routePrefixKey = "routePrefix"
+
+// RoutePrefix is a middleware recording the prefix the API is mounted under,
+// so RoutePath can strip it. Programs embedding the API mount it under their
+// own routes, e.g. "/x402".
+func RoutePrefix(prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(routePrefixKey, prefix)
+			return next(c)
+		}
+	}
+}
+
+// RoutePath returns the path of the route matching the request without the
+// prefix the API is mounted under, e.g. "/settle". Scopes and deadlines are
+// looked up by it.
+func RoutePath(c echo.Context) string {
+	prefix, _ := c.Get(routePrefixKey).(string)
+	return strings.TrimPrefix(c.Path(), prefix)
+}
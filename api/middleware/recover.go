@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+)
+
+// PanicReport describes a panic recovered while serving a request.
+type PanicReport struct {
+	// Err wraps the panic value
+	Err error
+	// Stack of the panicking goroutine
+	Stack []byte
+	// RequestID is returned to the client as correlation ID
+	RequestID string
+	Method    string
+	Path      string
+	Time      time.Time
+}
+
+// ErrorReporter forwards recovered panics to an error tracking service. A
+// Sentry reporter, for example, captures report.Err on a hub whose scope is
+// tagged with the request ID and carries the stack as extra data.
+type ErrorReporter interface {
+	ReportPanic(ctx context.Context, report *PanicReport)
+}
+
+// ErrorReporterFunc adapts a function to an ErrorReporter.
+type ErrorReporterFunc func(ctx context.Context, report *PanicReport)
+
+func (f ErrorReporterFunc) ReportPanic(ctx context.Context, report *PanicReport) {
+	f(ctx, report)
+}
+
+// PanicResponse is the body returned for requests that panicked.
+type PanicResponse struct {
+	Message string `json:"message"`
+	// Correlation ID to quote when reporting the error, equal to the X-Request-ID header
+	CorrelationID string `json:"correlationId"`
+}
+
+// Recover is a middleware that turns panics into 500 responses carrying a
+// correlation ID. The panic is logged with its stack trace and passed to the
+// reporter, which may be nil.
+func Recover(reporter ErrorReporter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if r == http.ErrAbortHandler {
+					// the handler aborted the response on purpose, see net/http
+					panic(r)
+				}
+
+				req := c.Request()
+				report := &PanicReport{
+					Err:       panicError(r),
+					Stack:     debug.Stack(),
+					RequestID: GetRequestID(req.Context()),
+					Method:    req.Method,
+					Path:      req.URL.Path,
+					Time:      time.Now(),
+				}
+				logging.Ctx(req.Context(), logging.HTTP).Error().
+					Err(report.Err).
+					Str("stack", string(report.Stack)).
+					Str("method", report.Method).
+					Str("path", report.Path).
+					Msg("Recovered from panic")
+				if reporter != nil {
+					reporter.ReportPanic(req.Context(), report)
+				}
+
+				err = echo.NewHTTPError(http.StatusInternalServerError, PanicResponse{
+					Message:       "Internal server error",
+					CorrelationID: report.RequestID,
+				})
+			}()
+			return next(c)
+		}
+	}
+}
+
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", r)
+}
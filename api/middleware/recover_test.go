@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	errBoom := errors.New("boom")
+	var reports []*PanicReport
+	reporter := ErrorReporterFunc(func(ctx context.Context, report *PanicReport) {
+		reports = append(reports, report)
+	})
+
+	e := echo.New()
+	e.Use(RequestID(), Recover(reporter))
+	e.GET("/panic", func(c echo.Context) error {
+		panic(errBoom)
+	})
+	e.GET("/ok", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	var body PanicResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	require.Equal(t, "req-1", body.CorrelationID)
+
+	require.Len(t, reports, 1)
+	require.ErrorIs(t, reports[0].Err, errBoom)
+	require.Equal(t, "req-1", reports[0].RequestID)
+	require.Equal(t, "/panic", reports[0].Path)
+	require.Contains(t, string(reports[0].Stack), "recover_test.go")
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, reports, 1)
+}
+
+func TestRecoverWithoutReporter(t *testing.T) {
+	e := echo.New()
+	handler := Recover(nil)(func(c echo.Context) error {
+		panic("unexpected")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := handler(e.NewContext(req, httptest.NewRecorder()))
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusInternalServerError, httpErr.Code)
+
+	require.Panics(t, func() {
+		Recover(nil)(func(c echo.Context) error {
+			panic(http.ErrAbortHandler)
+		})(e.NewContext(req, httptest.NewRecorder()))
+	})
+}
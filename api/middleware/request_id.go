@@ -8,19 +8,25 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/paymentctx"
 )
 
-// requestIDKey is the context key for storing the request ID
-var requestIDKey = &struct{}{}
+// contextKey is the type of the context keys of the package. Pointers to
+// distinct zero-size values may be equal, so keys must not be &struct{}{}.
+type contextKey int
+
+const (
+	// keyIDKey is the context key of the key ID a request was authenticated with
+	keyIDKey contextKey = iota
+	// scopesKey is the context key of the scopes a request was authorized with
+	scopesKey
+)
 
 // GetRequestID retrieves the request ID from the context
 // Returns an empty string if no request ID is found
 func GetRequestID(ctx context.Context) string {
-	rid, ok := ctx.Value(requestIDKey).(string)
-	if !ok {
-		return ""
-	}
-	return rid
+	return paymentctx.From(ctx).RequestID
 }
 
 // generateShortID creates a request ID that is shorter than a UUID
@@ -46,7 +52,8 @@ func generateShortID() string {
 // RequestID is a middleware that adds a request ID to each request
 // If the request already has an X-Request-ID header, it will use that value
 // Otherwise, it generates a new request ID
-// The request ID is added to the context and response headers
+// The request ID is added to the payment metadata of the context, and so to
+// its logger, and to the response headers
 func RequestID() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -57,7 +64,7 @@ func RequestID() echo.MiddlewareFunc {
 			}
 
 			// Add request ID to context
-			ctx := context.WithValue(c.Request().Context(), requestIDKey, requestID)
+			ctx := paymentctx.With(c.Request().Context(), paymentctx.Metadata{RequestID: requestID})
 			c.SetRequest(c.Request().WithContext(ctx))
 
 			// Add request ID to response headers
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/tenant"
+)
+
+// Tenant is a middleware that resolves the tenant of the key a request was
+// authenticated with and enforces its rate limit. The tenant is added to the
+// request context and its payment metadata. Requests of keys without a tenant pass
+// unchanged, so it must run after the authentication middleware.
+func Tenant(tenants *tenant.Tenants) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			t, ok := tenants.ByKey(GetKeyID(ctx))
+			if !ok {
+				return next(c)
+			}
+			if !t.Allow() {
+				metrics.TenantRateLimited.WithLabelValues(t.ID).Inc()
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Rate limit of the tenant exceeded")
+			}
+
+			ctx = tenant.NewContext(ctx, t)
+			ctx = paymentctx.With(ctx, paymentctx.Metadata{Tenant: t.ID})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
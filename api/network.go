@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// checkNetwork routes the payment by its network: the payload and the
+// requirements must name networks the facilitator is configured for, by CAIP-2
+// identifier or chain name. Payments on other networks are answered with 400
+// and the networks the caller can pay on.
+func (s *Server) checkNetwork(c echo.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) error {
+	for _, network := range []string{payload.Network, req.Network} {
+		if _, _, ok := s.registry.Lookup(network); !ok {
+			return s.unsupportedNetworkError(c, network)
+		}
+	}
+	return nil
+}
+
+// unsupportedNetworkError lists the configured networks and families the API
+// key of the request may pay on, all of them for requests without a key.
+func (s *Server) unsupportedNetworkError(c echo.Context, network string) error {
+	key := apikey.FromContext(c.Request().Context())
+	supported := []string{}
+	for _, config := range append(s.registry.Networks(), s.registry.Families()...) {
+		if key == nil || apikey.PermitsNetwork(key, config.Network) {
+			supported = append(supported, config.Network)
+		}
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, types.UnsupportedNetworkResponse{
+		Code:              types.ErrorCodeUnsupportedNetwork,
+		Message:           fmt.Sprintf("Network %q is not supported", network),
+		SupportedNetworks: supported,
+	})
+}
+
+// PauseNetwork pauses the settlements of a network
+// @Summary      Pause network
+// @ID           pauseNetwork
+// @Description  Stop settling payments on a network, e.g. during a reorg or an incident of its RPC endpoints. Settle requests are answered with 503 and settlements still queued fail without being broadcast, while payments are still verified. /supported lists the network with "paused" in its extra. The pause lasts until the network is resumed or the instance restarts, and applies to this instance only (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        network  path      string  true  "CAIP-2 identifier or chain name of the network"
+// @Success      200      {object}  types.NetworkPause
+// @Failure      403      {object}  echo.HTTPError
+// @Failure      404      {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/networks/{network}/pause [post]
+func (s *Server) PauseNetwork(c echo.Context) error {
+	_, config, ok := s.registry.Lookup(c.Param("network"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Network not found")
+	}
+	since, err := s.registry.Pause(config.Network)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	logging.Ctx(c.Request().Context(), logging.Settlement).Warn().Str("network", config.Network).Msg("Paused settlements")
+	return c.JSON(http.StatusOK, types.NetworkPause{Network: config.Network, Paused: true, PausedAt: &since})
+}
+
+// ResumeNetwork resumes the settlements of a paused network
+// @Summary      Resume network
+// @ID           resumeNetwork
+// @Description  Settle payments on a paused network again (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        network  path      string  true  "CAIP-2 identifier or chain name of the network"
+// @Success      200      {object}  types.NetworkPause
+// @Failure      403      {object}  echo.HTTPError
+// @Failure      404      {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/networks/{network}/resume [post]
+func (s *Server) ResumeNetwork(c echo.Context) error {
+	_, config, ok := s.registry.Lookup(c.Param("network"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Network not found")
+	}
+	if err := s.registry.Resume(config.Network); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	logging.Ctx(c.Request().Context(), logging.Settlement).Info().Str("network", config.Network).Msg("Resumed settlements")
+	return c.JSON(http.StatusOK, types.NetworkPause{Network: config.Network})
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// openAPIDocument is generated from the Swagger document by
+// internal/openapi/generate, see make generate-api
+//
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// OpenAPI serves the OpenAPI 3.1 document of the API
+// @Summary      OpenAPI document
+// @ID           openAPI
+// @Description  Get the OpenAPI 3.1 document of the API, which the clients under api/gen are generated from
+// @Tags         discovery
+// @Produce      application/yaml
+// @Success      200  {string}  string
+// @Router       /openapi.yaml [get]
+func (s *Server) OpenAPI(c echo.Context) error {
+	return c.Blob(http.StatusOK, "application/yaml", openAPIDocument)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+// Receipt returns the signed receipt of a settlement
+// @Summary      Settlement receipt
+// @ID           receipt
+// @Description  Get the receipt of a settlement signed by the facilitator. The signature is an EIP-712 signature of the signer over the receipt in the domain {name: "x402 facilitator receipt", version: "1"}, of the primary type Receipt, or ReferencedReceipt for settlements with a reference, see package receipt
+// @Tags         payments
+// @Produce      json
+// @Param        txHash  path      string  true  "Hash of the settlement transaction"
+// @Success      200     {object}  types.SettlementReceipt
+// @Failure      404     {object}  echo.HTTPError
+// @Failure      500     {object}  echo.HTTPError
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
+// @Router       /receipts/{txHash} [get]
+func (s *Server) Receipt(c echo.Context) error {
+	ctx := c.Request().Context()
+	receipt, err := s.receipts.Get(ctx, c.Param("txHash"))
+	if key := apikey.FromContext(ctx); err == nil && key != nil && !apikey.PermitsNetwork(key, receipt.Network) {
+		// receipts of other networks are hidden from keys limited to some
+		err = store.ErrNotFound
+	}
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "No receipt was issued for the transaction")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, receipt)
+}
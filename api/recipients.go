@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/recipient"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// RegisterRecipient registers an address to receive payments
+// @Summary      Register recipient
+// @ID           registerRecipient
+// @Description  Register an address to receive payments on networks that only pay registered recipients. The signature proves ownership of the address: it signs the message "x402 facilitator recipient registration\nNetwork: <network>\nAddress: <address>\nIssued At: <issuedAt in RFC 3339, UTC>" with EIP-191 personal_sign (hex) on EVM networks or ed25519 (base58) on Solana (localhost or admin API key)
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      recipient.Registration  true  "Signed registration"
+// @Success      201   {object}  types.Recipient
+// @Failure      400   {object}  echo.HTTPError
+// @Failure      403   {object}  echo.HTTPError
+// @Failure      500   {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/recipients [post]
+func (s *Server) RegisterRecipient(c echo.Context) error {
+	var reg recipient.Registration
+	if err := c.Bind(&reg); err != nil {
+		return err
+	}
+	registered, err := s.recipients.Register(c.Request().Context(), reg)
+	switch {
+	case errors.Is(err, recipient.ErrUnsupportedNetwork), errors.Is(err, recipient.ErrInvalidAddress),
+		errors.Is(err, recipient.ErrInvalidProof), errors.Is(err, recipient.ErrProofExpired):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, recipientResponse(registered))
+}
+
+// ListRecipients lists the registered recipients
+// @Summary      List recipients
+// @ID           listRecipients
+// @Description  List the registered recipients of all networks, including revoked ones, oldest first (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {array}   types.Recipient
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/recipients [get]
+func (s *Server) ListRecipients(c echo.Context) error {
+	recipients, err := s.recipients.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	resp := make([]types.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		resp = append(resp, recipientResponse(r))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RevokeRecipient revokes the registration of a recipient
+// @Summary      Revoke recipient
+// @ID           revokeRecipient
+// @Description  Revoke the registration of an address, payments to it are rejected afterwards. Registering it again needs a message issued after the revocation (localhost or admin API key)
+// @Tags         admin
+// @Param        network  path  string  true  "CAIP-2 identifier of the network"
+// @Param        address  path  string  true  "Registered address"
+// @Success      204
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      404  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/recipients/{network}/{address} [delete]
+func (s *Server) RevokeRecipient(c echo.Context) error {
+	network, err := url.PathUnescape(c.Param("network"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid network")
+	}
+	err = s.recipients.Revoke(c.Request().Context(), network, c.Param("address"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Recipient is not registered")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func recipientResponse(r *store.Recipient) types.Recipient {
+	return types.Recipient{
+		Network:      r.Network,
+		Address:      r.Address,
+		Message:      r.Message,
+		Signature:    r.Signature,
+		RegisteredAt: r.CreatedAt,
+		RevokedAt:    r.RevokedAt,
+	}
+}
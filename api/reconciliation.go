@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Reconciliation reports the discrepancies between the chain and the store
+// @Summary      Reconciliation findings
+// @ID           reconciliation
+// @Description  Get the progress of the indexer per network and the most recent transfers of the signers without a settlement on record and settlements whose transaction wasn't mined, newest first (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  types.Reconciliation
+// @Failure      403  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/reconciliation [get]
+func (s *Server) Reconciliation(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.indexer.Reconciliation())
+}
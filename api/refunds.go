@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// CreateRefund records a refund of a settlement
+// @Summary      Refund settlement
+// @ID           createRefund
+// @Description  Record a refund of a settlement to its payer. Either submit an EIP-3009 authorization of the payee transferring the amount to the payer, which is settled like a payment, or the hash of a refund transaction the payee sent. The refund is tracked until it is confirmed or fails, and its transitions are published as settlement events carrying the refund ID to the websocket stream and webhooks (localhost or admin API key)
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      types.RefundRequest  true  "Refund"
+// @Success      201   {object}  types.Refund
+// @Failure      400   {object}  echo.HTTPError
+// @Failure      403   {object}  echo.HTTPError
+// @Failure      404   {object}  echo.HTTPError
+// @Failure      409   {object}  echo.HTTPError
+// @Failure      500   {object}  echo.HTTPError
+// @Failure      503   {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/refunds [post]
+func (s *Server) CreateRefund(c echo.Context) error {
+	var req types.RefundRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	refund, err := s.settlements.Refund(c.Request().Context(), &req)
+	switch {
+	case errors.Is(err, settlement.ErrInvalidRefund):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Settlement not found")
+	case errors.Is(err, settlement.ErrNotRefundable):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, settlement.ErrStandby):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, refundResponse(refund))
+}
+
+// ListRefunds lists refunds
+// @Summary      List refunds
+// @ID           listRefunds
+// @Description  List the refunds of a settlement, or of all settlements, oldest first (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        settlementId  query     string  false  "Only list the refunds of this settlement"
+// @Success      200           {array}   types.Refund
+// @Failure      403           {object}  echo.HTTPError
+// @Failure      500           {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/refunds [get]
+func (s *Server) ListRefunds(c echo.Context) error {
+	refunds, err := s.settlements.Refunds(c.Request().Context(), c.QueryParam("settlementId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	resp := make([]types.Refund, 0, len(refunds))
+	for _, r := range refunds {
+		resp = append(resp, refundResponse(r))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetRefund returns a refund
+// @Summary      Get refund
+// @ID           getRefund
+// @Description  Get a refund and its progress (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "Refund ID"
+// @Success      200  {object}  types.Refund
+// @Failure      403  {object}  echo.HTTPError
+// @Failure      404  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/refunds/{id} [get]
+func (s *Server) GetRefund(c echo.Context) error {
+	refund, err := s.settlements.GetRefund(c.Request().Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Refund not found")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, refundResponse(refund))
+}
+
+func refundResponse(r *store.Refund) types.Refund {
+	return types.Refund{
+		ID:           r.ID,
+		SettlementID: r.SettlementID,
+		Network:      r.Network,
+		Asset:        r.Asset,
+		Amount:       r.Amount,
+		From:         r.From,
+		To:           r.To,
+		Reason:       r.Reason,
+		Status:       r.Status,
+		Error:        r.Error,
+		TxHash:       r.TxHash,
+		BlockNumber:  r.BlockNumber,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/internal/nonce"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// defaultReplayWindow is the accepted clock difference of settle requests if the configuration doesn't say otherwise
+const defaultReplayWindow = 5 * time.Minute
+
+// maxRequestNonceLength bounds the memory a single remembered nonce takes
+const maxRequestNonceLength = 128
+
+// ReplayConfig configures the replay protection of settle requests. Clients
+// generate a nonce and a timestamp for every settle request and send them in
+// the extra of the payment they accepted, "requestNonce" and
+// "requestTimestamp" (Unix seconds): paymentPayload.accepted.extra in version 2
+// and paymentRequirements.extra in version 1. A request is covered by the
+// signature of its body where requests are signed, see HMACConfig.
+type ReplayConfig struct {
+	// Rejects settle requests without a nonce and timestamp, or with a nonce already used
+	Enabled bool `mapstructure:"enabled"`
+	// Accepted difference between the request timestamp and the server clock, 0 means five minutes.
+	// Nonces are remembered twice as long
+	Window time.Duration `mapstructure:"window"`
+}
+
+// WithReplayProtection rejects settle requests replayed within the window of
+// the config. It has no effect if the protection isn't enabled.
+func WithReplayProtection(config ReplayConfig) Option {
+	return func(s *Server) {
+		if !config.Enabled {
+			return
+		}
+		s.replayWindow = cmp.Or(config.Window, defaultReplayWindow)
+		s.nonces = nonce.NewCache(2 * s.replayWindow)
+	}
+}
+
+// requestStamp is the nonce and timestamp a client sent a settle request with
+type requestStamp struct {
+	// field of the request the stamp was read from, for error messages
+	field     string
+	Nonce     string `json:"requestNonce"`
+	Timestamp int64  `json:"requestTimestamp"`
+}
+
+// stampFrom reads the stamp of the request from an extra, raw JSON or a
+// decoded object. It returns nil if the extra carries neither field.
+func stampFrom(field string, extra any) *requestStamp {
+	raw, ok := extra.(json.RawMessage)
+	if !ok {
+		if extra == nil {
+			return nil
+		}
+		raw, _ = json.Marshal(extra)
+	}
+	stamp := &requestStamp{field: field}
+	if json.Unmarshal(raw, stamp) != nil || (stamp.Nonce == "" && stamp.Timestamp == 0) {
+		return nil
+	}
+	return stamp
+}
+
+// checkReplay rejects settle requests without a stamp, with a timestamp
+// outside the window or with a nonce seen within the window.
+func (s *Server) checkReplay(req *paymentRequest) error {
+	if s.nonces == nil {
+		return nil
+	}
+	stamp := req.stamp
+	if stamp == nil {
+		field := "paymentRequirements.extra"
+		if req.version == types.X402VersionV2 {
+			field = "paymentPayload.accepted.extra"
+		}
+		return validationError(types.FieldError{Field: field + ".requestNonce", Message: "is required"})
+	}
+	if stamp.Nonce == "" {
+		return validationError(types.FieldError{Field: stamp.field + ".requestNonce", Message: "is required"})
+	}
+	if len(stamp.Nonce) > maxRequestNonceLength {
+		return validationError(types.FieldError{Field: stamp.field + ".requestNonce", Message: fmt.Sprintf("must not be longer than %d characters", maxRequestNonceLength)})
+	}
+	if skew := clock.Since(s.clock, time.Unix(stamp.Timestamp, 0)); skew > s.replayWindow || skew < -s.replayWindow {
+		return validationError(types.FieldError{Field: stamp.field + ".requestTimestamp", Message: "is outside the accepted window"})
+	}
+	if !s.nonces.Add(stamp.Nonce, s.clock.Now()) {
+		return echo.NewHTTPError(http.StatusConflict, "Request nonce was already used")
+	}
+	return nil
+}
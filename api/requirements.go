@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/requirement"
+	"github.com/gosuda/x402-facilitator/tenant"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// WithRequirements serves the registration of payment requirements at
+// /requirements and lets verify and settle requests name them by ID.
+func WithRequirements(registry *requirement.Registry) Option {
+	return func(s *Server) {
+		s.requirements = registry
+	}
+}
+
+// RegisterRequirements registers payment requirements
+// @Summary      Register requirements
+// @ID           registerRequirements
+// @Description  Register payment requirements and get their ID, which verify and settle requests can send as requirementsId instead of paymentRequirements. Registering the same requirements again returns the same ID and renews them. IDs are only valid for the tenant that registered them and expire a TTL after the last registration
+// @Tags         payments
+// @Accept       json,application/cbor,application/msgpack
+// @Produce      json,application/cbor,application/msgpack
+// @Param        body  body      types.PaymentRequirements  true  "Requirements to register"
+// @Success      201   {object}  types.RegisteredRequirements
+// @Failure      400   {object}  echo.HTTPError
+// @Failure      413   {object}  echo.HTTPError
+// @Failure      422   {object}  types.ValidationErrorResponse
+// @Failure      500   {object}  echo.HTTPError
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
+// @Router       /requirements [post]
+func (s *Server) RegisterRequirements(c echo.Context) error {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxRequestBodySize))
+	if err != nil {
+		return decodeError(err)
+	}
+	var req types.PaymentRequirements
+	if err := decodePaymentRequest(body, &req); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	id, err := s.requirements.Register(ctx, tenant.ID(ctx), &req)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respond(c, http.StatusCreated, types.RegisteredRequirements{ID: id, PaymentRequirements: req})
+}
+
+// GetRequirements returns registered payment requirements
+// @Summary      Get requirements
+// @ID           getRequirements
+// @Description  Get the payment requirements registered under the ID
+// @Tags         payments
+// @Produce      json,application/cbor,application/msgpack
+// @Param        id   path      string  true  "ID of the requirements"
+// @Success      200  {object}  types.RegisteredRequirements
+// @Failure      404  {object}  echo.HTTPError
+// @Failure      500  {object}  echo.HTTPError
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
+// @Router       /requirements/{id} [get]
+func (s *Server) GetRequirements(c echo.Context) error {
+	ctx := c.Request().Context()
+	req, err := s.requirements.Get(ctx, tenant.ID(ctx), c.Param("id"))
+	switch {
+	case errors.Is(err, requirement.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "No requirements are registered under the ID")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respond(c, http.StatusOK, types.RegisteredRequirements{ID: c.Param("id"), PaymentRequirements: *req})
+}
+
+// registeredRequirements returns the requirements registered under the ID
+// named by a payment request.
+func (s *Server) registeredRequirements(c echo.Context, id string) (*types.PaymentRequirements, error) {
+	if s.requirements == nil {
+		return nil, validationError(types.FieldError{Field: "requirementsId", Message: "is not supported by this facilitator"})
+	}
+	ctx := c.Request().Context()
+	req, err := s.requirements.Get(ctx, tenant.ID(ctx), id)
+	switch {
+	case errors.Is(err, requirement.ErrNotFound):
+		return nil, validationError(types.FieldError{Field: "requirementsId", Message: "is not a registered requirements ID"})
+	case err != nil:
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return req, nil
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"net/http"
+
+	_ "github.com/gosuda/x402-facilitator/api/swagger"
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/swaggo/echo-swagger"
+
+	"github.com/gosuda/x402-facilitator/api/middleware"
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+// The API is split into route groups, each mounted with its own middleware
+// stack on top of the global one:
+//   - payments:  x402 verification and settlement, optionally authenticated
+//   - discovery: public, read-only information about the facilitator
+//   - admin:     operator endpoints and Prometheus metrics under /admin,
+//     reachable from localhost or with an admin API key
+//   - debug:     diagnostics under /debug, reachable from localhost only
+//
+// New subsystems attach their routes to the matching group instead of
+// growing NewServer.
+
+func (s *Server) mountPayments() {
+	s.payments = s.root.Group("")
+	if s.hmacAuth != nil || s.jwtAuth != nil || s.keyAuth != nil {
+		s.payments.Use(s.authenticate)
+	}
+	if s.tenants != nil {
+		s.payments.Use(middleware.Tenant(s.tenants))
+	}
+
+	s.payments.POST("/verify", s.Verify, s.attest, s.negotiate)
+	s.payments.POST("/settle", s.Settle, s.attest, s.negotiate)
+	s.payments.POST("/settle/estimate", s.EstimateSettle, s.negotiate)
+	s.payments.GET("/ws/settlements", s.SettlementStream)
+	if s.receipts != nil {
+		s.payments.GET("/receipts/:txHash", s.Receipt)
+	}
+	if s.requirements != nil {
+		s.payments.POST("/requirements", s.RegisterRequirements, s.negotiate)
+		s.payments.GET("/requirements/:id", s.GetRequirements, s.negotiate)
+	}
+}
+
+// authenticate checks requests carrying an API key with the key
+// authentication, those carrying a bearer token with the token authentication
+// and all others with the HMAC authentication, if configured. Requests are
+// checked with whichever is configured if they carry no credentials it accepts.
+func (s *Server) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	var hmacNext, jwtNext, keyNext echo.HandlerFunc
+	if s.hmacAuth != nil {
+		hmacNext = s.hmacAuth(next)
+	}
+	if s.jwtAuth != nil {
+		jwtNext = s.jwtAuth(next)
+	}
+	if s.keyAuth != nil {
+		keyNext = s.keyAuth(next)
+	}
+	return func(c echo.Context) error {
+		req := c.Request()
+		_, bearer := middleware.BearerToken(req)
+		switch {
+		case keyNext != nil && req.Header.Get(middleware.HeaderAPIKey) != "":
+			return keyNext(c)
+		case jwtNext != nil && bearer:
+			return jwtNext(c)
+		case hmacNext != nil:
+			return hmacNext(c)
+		case jwtNext != nil:
+			return jwtNext(c)
+		default:
+			return keyNext(c)
+		}
+	}
+}
+
+func (s *Server) mountDiscovery() {
+	s.discovery = s.root.Group("")
+
+	s.discovery.GET("/supported", s.Supported)
+	s.discovery.GET("/.well-known/x402", s.WellKnown)
+	s.discovery.GET("/version", s.Version)
+	s.discovery.GET("/openapi.yaml", s.OpenAPI)
+	if !s.noSwagger {
+		s.discovery.GET("/swagger/*", echoSwagger.WrapHandler)
+	}
+}
+
+func (s *Server) mountAdmin() {
+	// Operators call admin endpoints from localhost, or with an admin API key
+	s.admin = s.root.Group("/admin", middleware.LocalhostOrAPIKey(s.apiKeys))
+
+	s.admin.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	s.admin.GET("/costs", s.Costs)
+	s.admin.GET("/stats", s.SettlementStats)
+	s.admin.GET("/export", s.Export)
+	s.admin.GET("/dashboard", s.Dashboard)
+	s.admin.GET("/dashboard/data", s.DashboardData)
+	s.admin.POST("/refunds", s.CreateRefund)
+	s.admin.GET("/refunds", s.ListRefunds)
+	s.admin.GET("/refunds/:id", s.GetRefund)
+	s.admin.GET("/settlements/:id/debug", s.SettlementDebug)
+	s.admin.POST("/networks/:network/pause", s.PauseNetwork)
+	s.admin.POST("/networks/:network/resume", s.ResumeNetwork)
+	s.admin.GET("/runtime", s.Runtime)
+	s.mountProfiling()
+	if s.configDump != nil {
+		s.admin.GET("/config", s.Config)
+	}
+	if s.indexer != nil {
+		s.admin.GET("/reconciliation", s.Reconciliation)
+	}
+	if s.recipients != nil {
+		s.admin.POST("/recipients", s.RegisterRecipient)
+		s.admin.GET("/recipients", s.ListRecipients)
+		s.admin.DELETE("/recipients/:network/:address", s.RevokeRecipient)
+	}
+	if s.apiKeys != nil {
+		s.admin.POST("/keys", s.CreateAPIKey)
+		s.admin.GET("/keys", s.ListAPIKeys)
+		s.admin.GET("/keys/:id", s.GetAPIKey)
+		s.admin.PUT("/keys/:id", s.UpdateAPIKey)
+		s.admin.DELETE("/keys/:id", s.RevokeAPIKey)
+	}
+}
+
+func (s *Server) mountDebug() {
+	s.debug = s.root.Group("/debug", middleware.LocalhostOnly())
+
+	s.debug.GET("/routes", s.ListRoutes)
+}
+
+// AdminGroup returns the route group for operator endpoints.
+func (s *Server) AdminGroup() *echo.Group {
+	return s.admin
+}
+
+// DebugGroup returns the route group for diagnostic endpoints.
+func (s *Server) DebugGroup() *echo.Group {
+	return s.debug
+}
+
+// ListRoutes lists all registered routes
+// @Summary      List routes
+// @ID           listRoutes
+// @Description  List all routes registered on the server (localhost only)
+// @Tags         debug
+// @Produce      json
+// @Success      200  {array}   echo.Route
+// @Failure      403  {object}  echo.HTTPError
+// @Router       /debug/routes [get]
+func (s *Server) ListRoutes(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.Echo.Routes())
+}
+
+// Config dumps the configuration with secrets redacted
+// @Summary      Configuration
+// @ID           config
+// @Description  Get the merged configuration of defaults, file and environment with secrets redacted (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  map[string]any
+// @Failure      403  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/config [get]
+func (s *Server) Config(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.configDump())
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"expvar"
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// recentPauses is the number of GC pauses reported by /admin/runtime
+const recentPauses = 16
+
+// processStart approximates the start of the process for the uptime
+var processStart = time.Now()
+
+// mountProfiling serves the profiles of net/http/pprof under
+// /admin/debug/pprof and the variables of expvar at /admin/debug/vars. The
+// pprof handlers expect to be served under /debug/pprof, so each profile is
+// routed by name instead of by path.
+func (s *Server) mountProfiling() {
+	s.admin.GET("/debug/pprof/", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	s.admin.GET("/debug/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	s.admin.GET("/debug/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	s.admin.GET("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	s.admin.POST("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	s.admin.GET("/debug/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	s.admin.GET("/debug/pprof/:name", func(c echo.Context) error {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+	s.admin.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+}
+
+// Runtime reports the state of the Go runtime
+// @Summary      Runtime diagnostics
+// @ID           runtime
+// @Description  Report the goroutine count, heap statistics, recent GC pauses and open RPC connections by network. CPU, heap, goroutine and other profiles are served by net/http/pprof under /admin/debug/pprof/ and runtime variables by expvar at /admin/debug/vars (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  types.RuntimeStats
+// @Failure      403  {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/runtime [get]
+func (s *Server) Runtime(c echo.Context) error {
+	return c.JSON(http.StatusOK, runtimeStats(time.Now()))
+}
+
+// runtimeStats takes a snapshot of the runtime. Reading the memory statistics
+// stops the world briefly.
+func runtimeStats(now time.Time) types.RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	gc := types.RuntimeGC{
+		Count:          mem.NumGC,
+		NextHeap:       mem.NextGC,
+		PauseTotalMs:   durationMs(mem.PauseTotalNs),
+		RecentPausesMs: []float64{},
+		CPUFraction:    mem.GCCPUFraction,
+	}
+	if mem.LastGC > 0 {
+		last := time.Unix(0, int64(mem.LastGC)).UTC()
+		gc.LastAt = &last
+	}
+	// PauseNs is a circular buffer, the most recent pause is at (NumGC+255)%256
+	for i := range min(mem.NumGC, recentPauses) {
+		gc.RecentPausesMs = append(gc.RecentPausesMs, durationMs(mem.PauseNs[(mem.NumGC-1-i)%uint32(len(mem.PauseNs))]))
+	}
+
+	connections := make(map[string]int)
+	for network, open := range metrics.GaugeValues(metrics.RPCConnections, "network") {
+		connections[network] = int(math.Round(open))
+	}
+	return types.RuntimeStats{
+		GeneratedAt:   now,
+		UptimeSeconds: now.Sub(processStart).Seconds(),
+		GoVersion:     runtime.Version(),
+		Goroutines:    runtime.NumGoroutine(),
+		MaxProcs:      runtime.GOMAXPROCS(0),
+		Heap: types.RuntimeHeap{
+			Alloc:    mem.HeapAlloc,
+			InUse:    mem.HeapInuse,
+			Idle:     mem.HeapIdle,
+			Released: mem.HeapReleased,
+			Sys:      mem.HeapSys,
+			Objects:  mem.HeapObjects,
+			TotalSys: mem.Sys,
+		},
+		GC:             gc,
+		RPCConnections: connections,
+	}
+}
+
+func durationMs(ns uint64) float64 {
+	return float64(ns) / float64(time.Millisecond)
+}
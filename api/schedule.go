@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// scheduleSettle hands the settlement of a settle request with a scheduling
+// window to the manager and answers with 202 and its ID, or like a failed
+// settlement if the payment is rejected.
+func (s *Server) scheduleSettle(ctx context.Context, c echo.Context, req *paymentRequest) error {
+	id, window, err := s.settlements.Schedule(ctx, req.payload, req.requirements, req.window)
+	var rejected *settlement.RejectedError
+	if errors.As(err, &rejected) {
+		// answered like a failed settlement that wasn't scheduled
+		return respond(c, http.StatusOK, settleResponse(req.version, req.payload.Network, &types.PaymentSettleResponse{
+			Success: false,
+			Error:   rejected.Reason,
+			Payer:   rejected.Payer,
+		}, req.reference))
+	} else if errors.Is(err, settlement.ErrEmptyWindow) {
+		return validationError(types.FieldError{Field: "notAfter", Message: "leaves no time to settle, the window is over, opens after the authorization expires or has no end"})
+	} else if err != nil {
+		return settleError(ctx, err)
+	}
+	return respond(c, http.StatusAccepted, &types.ScheduledSettlementResponse{
+		SettlementID: id,
+		Status:       string(settlement.StatusScheduled),
+		NotBefore:    window.NotBefore.Unix(),
+		NotAfter:     window.NotAfter.Unix(),
+		Reference:    req.reference,
+	})
+}
@@ -1,118 +1,562 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/gosuda/x402-facilitator/api/swagger"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
-	echoSwagger "github.com/swaggo/echo-swagger"
 
 	"github.com/gosuda/x402-facilitator/api/middleware"
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/attestation"
+	"github.com/gosuda/x402-facilitator/clock"
 	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/indexer"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/nonce"
+	"github.com/gosuda/x402-facilitator/internal/version"
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/receipt"
+	"github.com/gosuda/x402-facilitator/recipient"
+	"github.com/gosuda/x402-facilitator/requirement"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/tenant"
 	"github.com/gosuda/x402-facilitator/types"
 )
 
 // @title        x402 Facilitator API
 // @version      1.0
 // @description  API server for x402 payment facilitator
-type server struct {
+
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 JWT of an API client, sent as "Bearer <token>", if the facilitator verifies tokens
+
+// @securityDefinitions.apikey  HMAC
+// @in                          header
+// @name                        X-Signature
+// @description                 HMAC-SHA256 signature of the request with a shared secret, "sha256=<hex>", if the facilitator has HMAC keys. The X-Key-Id, X-Timestamp and X-Nonce headers are signed along
+
+// @securityDefinitions.apikey  APIKey
+// @in                          header
+// @name                        X-API-Key
+// @description                 API key created under /admin/keys, if the facilitator manages keys. The key needs the scope of the endpoint
+
+// Server serves the facilitator API. It is an http.Handler, and programs
+// embedding the facilitator can mount its routes on their own echo instance
+// instead, see WithEcho.
+type Server struct {
 	*echo.Echo
-	facilitator facilitator.Facilitator
+	registry    *facilitator.Registry
+	settlements *settlement.Manager
+	priceOracle oracle.PriceOracle
+
+	// authentication of the payments group with HMAC keys, bearer tokens and
+	// managed API keys, none if all are nil
+	hmacAuth echo.MiddlewareFunc
+	jwtAuth  echo.MiddlewareFunc
+	keyAuth  echo.MiddlewareFunc
+	// keys of HMACAuth, kept until the clock is known
+	hmac middleware.HMACConfig
+	// managed API keys, optional
+	apiKeys *apikey.Manager
+	// tenants of the API keys, optional
+	tenants *tenant.Tenants
+	// receives recovered panics, optional
+	errorReporter middleware.ErrorReporter
+	// deadlines of the payment endpoints
+	timeouts TimeoutConfig
+	// cross-origin policy, security headers and compression of all responses
+	cors            CORSConfig
+	securityHeaders SecurityHeadersConfig
+	compression     CompressionConfig
+	// redacted configuration served to operators, optional
+	configDump func() map[string]any
+	// recipients registering to receive payments, optional
+	recipients *recipient.Registry
+	// receipts signed for settlements, optional
+	receipts *receipt.Issuer
+	// signs the responses of verify and settle requests, optional
+	responseKey attestation.Key
+	// requirements registered to be named by ID, optional
+	requirements *requirement.Registry
+	// reconciles the transfers of the signers with the store, optional
+	indexer *indexer.Indexer
+	// nonces of settle requests within the replay window, nil if replays aren't rejected
+	nonces       *nonce.Cache
+	replayWindow time.Duration
+	// time source of the deadlines and the replay window
+	clock clock.Clock
+
+	// set by programs embedding the facilitator, see the options below
+	external     bool // the echo instance is the embedding program's
+	prefix       string
+	middleware   []echo.MiddlewareFunc
+	errorHandler echo.HTTPErrorHandler
+	noSwagger    bool
+
+	// route groups, see routes.go
+	root      *echo.Group // all routes, under the prefix
+	payments  *echo.Group
+	discovery *echo.Group
+	admin     *echo.Group
+	debug     *echo.Group
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// Option configures an optional feature of the server.
+type Option func(*Server)
+
+// WithClock replaces the clock the deadlines of requests, the replay window
+// and the timestamps of signed requests are measured with.
+func WithClock(c clock.Clock) Option {
+	return func(s *Server) {
+		s.clock = c
+	}
+}
+
+// WithHMACAuth requires requests to the payment endpoints to be signed with
+// one of the shared secrets. It has no effect if no secret is configured.
+func WithHMACAuth(config middleware.HMACConfig) Option {
+	return func(s *Server) {
+		s.hmac = config
+	}
+}
+
+// WithJWTAuth accepts requests to the payment endpoints carrying a bearer
+// token of the identity provider, as an alternative to HMAC signatures. It
+// has no effect if no JWKS URL is configured.
+func WithJWTAuth(config middleware.JWTConfig) Option {
+	return func(s *Server) {
+		if config.JWKSURL != "" {
+			s.jwtAuth = middleware.JWTAuth(config)
+		}
+	}
+}
+
+// WithAPIKeys accepts requests carrying a key of the manager in the X-API-Key
+// header, on the payment endpoints as an alternative to HMAC signatures and
+// bearer tokens and on the admin endpoints from other hosts than localhost,
+// and serves the management of the keys under /admin/keys.
+func WithAPIKeys(keys *apikey.Manager) Option {
+	return func(s *Server) {
+		s.apiKeys = keys
+		s.keyAuth = middleware.APIKeyAuth(keys)
+	}
 }
 
-var _ http.Handler = (*server)(nil)
+// WithTenants scopes requests authenticated with the key of a tenant to the
+// tenant, see package tenant. It needs an authentication option to identify keys.
+func WithTenants(tenants *tenant.Tenants) Option {
+	return func(s *Server) {
+		s.tenants = tenants
+	}
+}
 
-func NewServer(facilitator facilitator.Facilitator) *server {
-	s := &server{
-		Echo:        echo.New(),
-		facilitator: facilitator,
+// WithConfigDump serves the configuration returned by dump at /admin/config.
+// The dump must not contain secrets.
+func WithConfigDump(dump func() map[string]any) Option {
+	return func(s *Server) {
+		s.configDump = dump
+	}
+}
+
+// WithReceipts serves the receipts of the issuer at /receipts/{txHash}. The
+// issuer must be the one the settlement manager signs receipts with.
+func WithReceipts(issuer *receipt.Issuer) Option {
+	return func(s *Server) {
+		s.receipts = issuer
+	}
+}
+
+// WithIndexer serves the findings of the indexer under /admin/reconciliation.
+func WithIndexer(indexer *indexer.Indexer) Option {
+	return func(s *Server) {
+		s.indexer = indexer
+	}
+}
+
+// WithRecipients serves the registration of recipients under /admin/recipients.
+// The registry must be the one networks check recipients with.
+func WithRecipients(recipients *recipient.Registry) Option {
+	return func(s *Server) {
+		s.recipients = recipients
+	}
+}
+
+// WithErrorReporter forwards panics recovered while serving requests to the reporter.
+func WithErrorReporter(reporter middleware.ErrorReporter) Option {
+	return func(s *Server) {
+		s.errorReporter = reporter
+	}
+}
+
+// WithEcho mounts the routes on an echo instance of the embedding program
+// instead of a new one. The middleware of the facilitator then only wraps its
+// own routes and their errors are rendered by its error handler, while the
+// instance's middleware and error handler are left alone. Cross-origin
+// preflight requests don't reach the routes of a group, so the instance's
+// middleware has to answer them.
+func WithEcho(e *echo.Echo) Option {
+	return func(s *Server) {
+		s.Echo = e
+		s.external = true
+	}
+}
+
+// WithPrefix mounts all routes under the prefix, e.g. "/x402" serves
+// "/x402/settle". Deadlines and scopes of routes are configured without it.
+func WithPrefix(prefix string) Option {
+	return func(s *Server) {
+		s.prefix = "/" + strings.Trim(prefix, "/")
+		if s.prefix == "/" {
+			s.prefix = ""
+		}
+	}
+}
+
+// WithMiddleware adds middleware to all routes. It runs after the middleware
+// of the facilitator, which assigns request IDs, logs, recovers panics and
+// sets deadlines, and before authentication.
+func WithMiddleware(middleware ...echo.MiddlewareFunc) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// WithErrorHandler renders the errors of the routes with the handler instead
+// of echo's rendering in the encoding negotiated for the request.
+func WithErrorHandler(handler echo.HTTPErrorHandler) Option {
+	return func(s *Server) {
+		s.errorHandler = handler
+	}
+}
+
+// WithoutSwagger doesn't serve the Swagger UI under /swagger. The OpenAPI
+// document is still served at /openapi.yaml.
+func WithoutSwagger() Option {
+	return func(s *Server) {
+		s.noSwagger = true
+	}
+}
+
+// NewServer creates the API server. priceOracle is optional and may be nil,
+// in which case no USD amounts are reported.
+func NewServer(registry *facilitator.Registry, settlements *settlement.Manager, priceOracle oracle.PriceOracle, opts ...Option) *Server {
+	s := &Server{
+		registry:    registry,
+		settlements: settlements,
+		priceOracle: priceOracle,
+		timeouts:    TimeoutConfig{}.withDefaults(),
+		clock:       clock.Real,
+
+		securityHeaders: SecurityHeadersConfig{}.withDefaults(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.hmac.Secrets) > 0 {
+		// created once the clock is known
+		s.hmacAuth = middleware.HMACAuth(s.hmac, s.clock)
+	}
+	if s.Echo == nil {
+		s.Echo = echo.New()
+	}
+	if s.errorHandler == nil {
+		s.errorHandler = s.handleError
 	}
 
-	s.Use(middleware.RequestID())
-	s.Use(middleware.Logger())
-	s.Use(middleware.ErrorWrapper())
-	s.Use(echomiddleware.RecoverWithConfig(echomiddleware.RecoverConfig{
-		DisableErrorHandler: true,
-	}))
-	s.Use(echomiddleware.CORS())
+	stack := []echo.MiddlewareFunc{
+		middleware.RoutePrefix(s.prefix),
+		middleware.RequestID(),
+		middleware.Logger(),
+		middleware.ErrorWrapper(),
+		middleware.Recover(s.errorReporter),
+		echomiddleware.SecureWithConfig(s.securityHeaders.echoConfig()),
+	}
+	if !s.cors.Disabled {
+		stack = append(stack, echomiddleware.CORSWithConfig(s.cors.echoConfig()))
+	}
+	if !s.compression.Disabled {
+		stack = append(stack, newCompressor(s.compression).middleware)
+	}
+	stack = append(stack, s.routeDeadline)
+	stack = append(stack, s.middleware...)
+	if s.external {
+		// errors are rendered before they reach the error handler of the instance
+		s.root = s.Group(s.prefix, append([]echo.MiddlewareFunc{s.renderErrors}, stack...)...)
+	} else {
+		s.HTTPErrorHandler = s.errorHandler
+		s.Use(stack...)
+		s.root = s.Group(s.prefix)
+	}
 
-	s.POST("/verify", s.Verify)
-	s.POST("/settle", s.Settle)
-	s.GET("/supported", s.Supported)
-	s.GET("/swagger/*", echoSwagger.WrapHandler)
+	s.mountPayments()
+	s.mountDiscovery()
+	s.mountAdmin()
+	s.mountDebug()
 
 	return s
 }
 
+// renderErrors renders the errors of the routes with the error handler of the
+// server, like echo does with the error handler of the instance.
+func (s *Server) renderErrors(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := next(c); err != nil {
+			s.errorHandler(err, c)
+		}
+		return nil
+	}
+}
+
 // Settle handles payment settlement requests
 // @Summary      Settle payment
-// @Description  Settle a payment using the facilitator
+// @ID           settle
+// @Description  Settle a payment using the facilitator. Version 2 requests (paymentPayload instead of paymentHeader) are answered with a version 2 settle response. Payments are routed by their network, those on networks that aren't configured are answered with 400 and UNSUPPORTED_NETWORK. Requests with a scheduling window (notBefore or notAfter) are verified, answered with 202, or 200 and a failed settle response if the payment is rejected, and settled in the background within the window, once the gas price of the network falls to its threshold or the window closes. Their outcome is published as settlement events
 // @Tags         payments
-// @Accept       json
-// @Produce      json
+// @Accept       json,application/cbor,application/msgpack
+// @Produce      json,application/cbor,application/msgpack
 // @Param        body  body      types.PaymentSettleRequest  true  "Settlement request"
 // @Success      200   {object}  types.PaymentSettleResponse
-// @Failure      400   {object}  echo.HTTPError
+// @Success      202   {object}  types.ScheduledSettlementResponse
+// @Failure      400   {object}  types.UnsupportedNetworkResponse
+// @Failure      409   {object}  echo.HTTPError
+// @Failure      413   {object}  echo.HTTPError
+// @Failure      422   {object}  types.ValidationErrorResponse
 // @Failure      500   {object}  echo.HTTPError
+// @Failure      503   {object}  echo.HTTPError
+// @Failure      504   {object}  types.ErrorResponse
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
 // @Router       /settle [post]
-func (s *server) Settle(c echo.Context) error {
-	ctx := c.Request().Context()
+func (s *Server) Settle(c echo.Context) error {
+	settleRequest, err := s.bindVersionedRequest(c, true)
+	if err != nil {
+		return err
+	}
+	if err := s.checkReplay(settleRequest); err != nil {
+		return err
+	}
 
-	settleRequest := &types.PaymentSettleRequest{}
-	if err := json.NewDecoder(c.Request().Body).Decode(settleRequest); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Received malformed settlement request")
+	timeout := s.timeouts.requestDeadline(settleRequest.timeoutMs, s.timeouts.Settle)
+	ctx, cancel := clock.WithTimeout(c.Request().Context(), s.clock, timeout)
+	defer cancel()
+	// only tenants can be prioritized, others can't be told apart
+	if t := tenant.FromContext(ctx); t != nil {
+		ctx = settlement.WithPriority(ctx, t.SettlePriority(settleRequest.priority))
+	}
+	if settleRequest.orderingKey != "" {
+		ctx = settlement.WithOrderingKey(ctx, settleRequest.orderingKey)
+	}
+	ctx = paymentctx.With(ctx, paymentctx.Metadata{Reference: settleRequest.reference})
+	if settleRequest.window != (settlement.Window{}) {
+		return s.scheduleSettle(ctx, c, settleRequest)
 	}
 
-	settle, err := s.facilitator.Settle(ctx, &settleRequest.PaymentHeader, &settleRequest.PaymentRequirements)
+	settle, err := s.settlements.Settle(ctx, settleRequest.payload, settleRequest.requirements)
 	if err != nil {
+		return settleError(ctx, err)
+	}
+	return respond(c, http.StatusOK, settleResponse(settleRequest.version, settleRequest.payload.Network, settle, settleRequest.reference))
+}
+
+// settleError returns the HTTP error of a settlement that couldn't be run.
+func settleError(ctx context.Context, err error) error {
+	if timedOut(ctx, err) {
+		return timeoutError()
+	}
+	if errors.Is(err, settlement.ErrQueueFull) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Too many settlements in progress, retry later")
+	}
+	if errors.Is(err, settlement.ErrStandby) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "This instance is a standby, retry at the leader")
+	}
+	if errors.Is(err, facilitator.ErrNetworkPaused) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Settlements on the network are paused, retry later")
+	}
+	if errors.Is(err, facilitator.ErrChainUnavailable) {
+		return chainUnavailableError()
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+}
+
+// EstimateSettle handles settlement dry-run requests
+// @Summary      Estimate settlement
+// @ID           estimateSettle
+// @Description  Simulate a settlement without broadcasting it and estimate its gas cost
+// @Tags         payments
+// @Accept       json,application/cbor,application/msgpack
+// @Produce      json,application/cbor,application/msgpack
+// @Param        body  body      types.PaymentSettleRequest  true  "Settlement request"
+// @Success      200   {object}  types.PaymentEstimateResponse
+// @Failure      400   {object}  types.UnsupportedNetworkResponse
+// @Failure      413   {object}  echo.HTTPError
+// @Failure      422   {object}  types.ValidationErrorResponse
+// @Failure      500   {object}  echo.HTTPError
+// @Failure      501   {object}  echo.HTTPError
+// @Failure      504   {object}  types.ErrorResponse
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
+// @Router       /settle/estimate [post]
+func (s *Server) EstimateSettle(c echo.Context) error {
+	settleRequest, err := s.bindVersionedRequest(c, true)
+	if err != nil {
+		return err
+	}
+
+	timeout := s.timeouts.requestDeadline(settleRequest.timeoutMs, s.timeouts.Estimate)
+	ctx, cancel := clock.WithTimeout(c.Request().Context(), s.clock, timeout)
+	defer cancel()
+
+	estimate, err := s.registry.Estimate(ctx, settleRequest.payload, settleRequest.requirements)
+	switch {
+	case errors.Is(err, facilitator.ErrNotSupported):
+		return echo.NewHTTPError(http.StatusNotImplemented, "Settlement estimation is not supported on this network")
+	case (err != nil || !estimate.Success) && timedOut(ctx, err):
+		// failed simulations are reported in the estimate, unless they ran out of time
+		return timeoutError()
+	case err != nil:
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
-	return c.JSON(http.StatusOK, settle)
+
+	if estimate.Success && s.priceOracle != nil {
+		// USD pricing is best effort, the estimate is still useful without it
+		if usd, err := s.gasCostUsd(ctx, estimate); err != nil {
+			logging.Ctx(ctx, logging.HTTP).Warn().Err(err).Msg("Failed to price gas cost in USD")
+		} else {
+			estimate.GasCostUsd = &usd
+		}
+	}
+	return respond(c, http.StatusOK, estimate)
+}
+
+func (s *Server) gasCostUsd(ctx context.Context, estimate *types.PaymentEstimateResponse) (float64, error) {
+	price, err := s.priceOracle.PriceUSD(ctx, estimate.NativeCurrency)
+	if err != nil {
+		return 0, err
+	}
+	amount, err := strconv.ParseFloat(estimate.GasCostNative, 64)
+	if err != nil {
+		return 0, err
+	}
+	return amount * price, nil
 }
 
 // Verify handles payment verification requests
 // @Summary      Verify payment
-// @Description  Verify a payment using the facilitator
+// @ID           verify
+// @Description  Verify a payment using the facilitator. Version 2 requests (paymentPayload instead of paymentHeader) are answered with a version 2 verify response. Payments are routed by their network, those on networks that aren't configured are answered with 400 and UNSUPPORTED_NETWORK. Networks that verify through RPC outages answer with chainChecksSkipped while the chain can't be reached. Valid results are reused for the same payload and requirements for a few seconds, requests with Cache-Control: no-cache verify against the chain again
 // @Tags         payments
-// @Accept       json
-// @Produce      json
+// @Accept       json,application/cbor,application/msgpack
+// @Produce      json,application/cbor,application/msgpack
 // @Param        body  body      types.PaymentVerifyRequest  true  "Payment verification request"
 // @Success      200   {object}  types.PaymentVerifyResponse
-// @Failure      400   {object}  echo.HTTPError
+// @Failure      400   {object}  types.UnsupportedNetworkResponse
+// @Failure      413   {object}  echo.HTTPError
+// @Failure      422   {object}  types.ValidationErrorResponse
 // @Failure      500   {object}  echo.HTTPError
+// @Failure      503   {object}  types.ErrorResponse
+// @Failure      504   {object}  types.ErrorResponse
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
 // @Router       /verify [post]
-func (s *server) Verify(c echo.Context) error {
-	ctx := c.Request().Context()
+func (s *Server) Verify(c echo.Context) error {
+	requirement, err := s.bindVersionedRequest(c, false)
+	if err != nil {
+		return err
+	}
 
-	// validate payment requirements
-	requirement := &types.PaymentVerifyRequest{}
-	if err := json.NewDecoder(c.Request().Body).Decode(requirement); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Received malformed payment requirements")
+	ctx, cancel := clock.WithTimeout(c.Request().Context(), s.clock, s.timeouts.Verify)
+	defer cancel()
+	ctx = paymentctx.With(ctx, paymentctx.Metadata{Reference: requirement.reference})
+	if noCache(c.Request().Header) {
+		ctx = facilitator.BypassVerifyCache(ctx)
 	}
 
-	verified, err := s.facilitator.Verify(ctx, &requirement.PaymentHeader, &requirement.PaymentRequirements)
+	verified, err := s.settlements.Verify(ctx, requirement.payload, requirement.requirements)
 	if err != nil {
+		if timedOut(ctx, err) {
+			return timeoutError()
+		}
+		if errors.Is(err, facilitator.ErrChainUnavailable) {
+			return chainUnavailableError()
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, verified)
+	return respond(c, http.StatusOK, verifyResponse(requirement.version, verified, requirement.reference))
 }
 
-// Supported returns the list of supported payment kinds
+// noCache reports whether the request asks for a fresh response.
+func noCache(header http.Header) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				return true
+			}
+		}
+	}
+	return header.Get("Pragma") == "no-cache"
+}
+
+// Supported returns the supported payment kinds of the configured networks
 // @Summary      List supported kinds
-// @Description  Get supported payment kinds
+// @ID           supported
+// @Description  Get the supported payment kinds of every configured network and the facilitator signer addresses
 // @Tags         payments
 // @Produce      json
-// @Success      200  {array}   types.SupportedKind
+// @Success      200  {object}  types.SupportedResponse
 // @Failure      404  {object}  echo.HTTPError
 // @Router       /supported [get]
-func (s *server) Supported(c echo.Context) error {
-	kinds := s.facilitator.Supported()
-	if len(kinds) == 0 {
+func (s *Server) Supported(c echo.Context) error {
+	supported := s.registry.Supported()
+	if len(supported.Kinds) == 0 {
 		return echo.NewHTTPError(http.StatusNotFound, "No supported payment kinds found")
 	}
 
-	return c.JSON(http.StatusOK, kinds)
+	return c.JSON(http.StatusOK, supported)
+}
+
+// WellKnown describes the facilitator for discovery
+// @Summary      Describe the facilitator
+// @ID           wellKnown
+// @Description  Get the accepted x402 versions, the supported payment kinds and the signer addresses of every configured network
+// @Tags         payments
+// @Produce      json
+// @Success      200  {object}  types.WellKnownResponse
+// @Router       /.well-known/x402 [get]
+func (s *Server) WellKnown(c echo.Context) error {
+	versions := make([]int, len(types.SupportedX402Versions))
+	for i, version := range types.SupportedX402Versions {
+		versions[i] = int(version)
+	}
+	return c.JSON(http.StatusOK, types.WellKnownResponse{
+		X402Versions: versions,
+		Kinds:        s.registry.Supported().Kinds,
+		Signers:      s.registry.Signers(),
+	})
+}
+
+// Version reports the build of the facilitator
+// @Summary      Build information
+// @ID           version
+// @Description  Get the version, commit and build date of the running facilitator
+// @Tags         discovery
+// @Produce      json
+// @Success      200  {object}  version.Info
+// @Router       /version [get]
+func (s *Server) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, version.Get())
 }
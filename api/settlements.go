@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/tenant"
+)
+
+const (
+	// wsWriteTimeout bounds a single write to a websocket client
+	wsWriteTimeout = 10 * time.Second
+	// wsPongTimeout is how long a client may stay silent before the connection is dropped
+	wsPongTimeout = 60 * time.Second
+	// wsPingInterval must be shorter than wsPongTimeout
+	wsPingInterval = 30 * time.Second
+)
+
+// checkOrigin accepts websocket upgrades of clients that aren't browsers,
+// which send no Origin, of pages served by the facilitator itself and of the
+// origins CORS allows. Browsers don't apply CORS to websockets, so the CORS
+// middleware doesn't protect the stream.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if s.cors.Disabled {
+		return false
+	}
+	if len(s.cors.AllowOrigins) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(s.cors.AllowOrigins, func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(allowed, origin)
+	})
+}
+
+// SettlementStream streams settlement state transitions over a websocket
+// @Summary      Stream settlement updates
+// @ID           settlementStream
+// @Description  Upgrade to a websocket and receive settlement.Event JSON messages for every state transition (queued, submitted, mined, confirmed, failed, expired), and for the transitions of refunds of settlements, which carry the refundId. Clients authenticated as a tenant only receive the settlements of the tenant, and clients with an API key limited to some networks those on the networks. The stream needs authentication, facilitators without it answer 403, and browsers may only connect from the origins CORS allows
+// @Tags         settlements
+// @Param        network  query  string  false  "Only stream settlements on this network"
+// @Param        payer    query  string  false  "Only stream settlements of this payer"
+// @Success      101  {object}  settlement.Event
+// @Failure      400  {object}  echo.HTTPError
+// @Failure      401  {object}  echo.HTTPError
+// @Failure      403  {object}  echo.HTTPError
+// @Security     BearerAuth
+// @Security     HMAC
+// @Security     APIKey
+// @Router       /ws/settlements [get]
+func (s *Server) SettlementStream(c echo.Context) error {
+	if s.hmacAuth == nil && s.jwtAuth == nil && s.keyAuth == nil {
+		// the events name payers and amounts of every settlement
+		return echo.NewHTTPError(http.StatusForbidden, "The settlement stream requires authentication to be configured")
+	}
+	network := c.QueryParam("network")
+	payer := c.QueryParam("payer")
+	tenantID := tenant.ID(c.Request().Context())
+	key := apikey.FromContext(c.Request().Context())
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkOrigin}
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// the upgrader already replied to the client
+		return nil
+	}
+	defer conn.Close()
+
+	events, unsubscribe := s.settlements.Hub().Subscribe()
+	defer unsubscribe()
+
+	// The read loop only handles control frames and detects closed connections
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	logger := logging.Ctx(c.Request().Context(), logging.HTTP)
+	for {
+		select {
+		case <-closed:
+			return nil
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return nil
+			}
+		case evt, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if !matchEvent(evt, tenantID, network, payer) || (key != nil && !apikey.PermitsNetwork(key, evt.Network)) {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(evt); err != nil {
+				logger.Debug().Err(err).Msg("Failed to write settlement event")
+				return nil
+			}
+		}
+	}
+}
+
+func matchEvent(evt settlement.Event, tenantID, network, payer string) bool {
+	if tenantID != "" && evt.Tenant != tenantID {
+		return false
+	}
+	if network != "" && evt.Network != network {
+		return false
+	}
+	if payer != "" && !strings.EqualFold(evt.Payer, payer) {
+		return false
+	}
+	return true
+}
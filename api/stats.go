@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// statsGranularities are the bucket lengths of the statistics by name
+var statsGranularities = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// maxStatsBuckets bounds the buckets of a statistics response
+const maxStatsBuckets = 10_000
+
+// SettlementStats aggregates the settlements of a period by time bucket
+// @Summary      Settlement statistics
+// @ID           settlementStats
+// @Description  Count the settlements created in [from, to) by minute, hour or day with their success rate, mean time to confirmation and settled volume by network and asset, for reports that don't need the raw data of /admin/export. Buckets start at from truncated to the granularity, days at midnight UTC (localhost or admin API key)
+// @Tags         admin
+// @Produce      json
+// @Param        granularity  query     string  false  "minute, hour (default) or day"
+// @Param        from         query     string  false  "Start of the period (RFC 3339), defaults to 24 hours before to"
+// @Param        to           query     string  false  "End of the period (RFC 3339), defaults to now"
+// @Success      200          {object}  types.SettlementStats
+// @Failure      400          {object}  echo.HTTPError
+// @Failure      403          {object}  echo.HTTPError
+// @Failure      500          {object}  echo.HTTPError
+// @Security     APIKey
+// @Router       /admin/stats [get]
+func (s *Server) SettlementStats(c echo.Context) error {
+	granularity := c.QueryParam("granularity")
+	if granularity == "" {
+		granularity = "hour"
+	}
+	bucket, ok := statsGranularities[granularity]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid granularity, expected minute, hour or day")
+	}
+	from, to, err := queryPeriod(c)
+	if err != nil {
+		return err
+	}
+	if to.Sub(from.Truncate(bucket)) > maxStatsBuckets*bucket {
+		return echo.NewHTTPError(http.StatusBadRequest, "The period is too long for the granularity")
+	}
+
+	stats, err := s.settlements.Stats(c.Request().Context(), from, to, bucket, granularity)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, stats)
+}
@@ -15,9 +15,294 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/settle": {
+        "/.well-known/x402": {
+            "get": {
+                "description": "Get the accepted x402 versions, the supported payment kinds and the signer addresses of every configured network",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Describe the facilitator",
+                "operationId": "wellKnown",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.WellKnownResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/config": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get the merged configuration of defaults, file and environment with secrets redacted (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Configuration",
+                "operationId": "config",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/costs": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Sum the gas used and fees paid for the settlements created in [from, to) by network and asset (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Settlement cost report",
+                "operationId": "costs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start of the period (RFC 3339), defaults to 24 hours before to",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period (RFC 3339), defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.CostReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/dashboard": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Serve an HTML page showing settlement throughput, error rates, queue depth and signer balances, refreshed every few seconds (localhost or admin API key)",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Settlement dashboard",
+                "operationId": "dashboard",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/dashboard/data": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Count the settlements of the last hour by minute and by network, and report the settlement queue, signer gas balances and paused networks (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Dashboard data",
+                "operationId": "dashboardData",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Dashboard"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/export": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Stream the settlements created in [from, to) as CSV or Parquet, oldest first: status, payer, recipient, asset, amount, transaction hash, gas used, gas price and fee. Amounts are in atomic units, and in whole units if the decimals of the asset are known (localhost or admin API key)",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.apache.parquet"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Export settlements",
+                "operationId": "exportSettlements",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default) or parquet",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period (RFC 3339), defaults to 24 hours before to",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period (RFC 3339), defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "List all API keys, including revoked ones, oldest first (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List API keys",
+                "operationId": "listApiKeys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.APIKey"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Settle a payment using the facilitator",
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Create an API key granting the scopes, optionally limited to some networks. The key is returned once, only the hash of its secret is stored (localhost or admin API key)",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,25 +310,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "payments"
+                    "admin"
                 ],
-                "summary": "Settle payment",
+                "summary": "Create API key",
+                "operationId": "createApiKey",
                 "parameters": [
                     {
-                        "description": "Settlement request",
+                        "description": "Key to create",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.PaymentSettleRequest"
+                            "$ref": "#/definitions/apikey.Spec"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/types.PaymentSettleResponse"
+                            "$ref": "#/definitions/types.CreatedAPIKey"
                         }
                     },
                     "400": {
@@ -52,6 +338,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/echo.HTTPError"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -61,24 +359,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/supported": {
+        "/admin/keys/{id}": {
             "get": {
-                "description": "Get supported payment kinds",
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get an API key, without its secret (localhost or admin API key)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payments"
+                    "admin"
+                ],
+                "summary": "Get API key",
+                "operationId": "getApiKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "List supported kinds",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.SupportedKind"
-                            }
+                            "$ref": "#/definitions/types.APIKey"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
                         }
                     },
                     "404": {
@@ -86,13 +402,22 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/echo.HTTPError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
                     }
                 }
-            }
-        },
-        "/verify": {
-            "post": {
-                "description": "Verify a payment using the facilitator",
+            },
+            "put": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Replace the name, scopes and networks of an API key, its secret stays the same. Other instances apply the change within 30 seconds (localhost or admin API key)",
                 "consumes": [
                     "application/json"
                 ],
@@ -100,17 +425,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "payments"
+                    "admin"
                 ],
-                "summary": "Verify payment",
+                "summary": "Update API key",
+                "operationId": "updateApiKey",
                 "parameters": [
                     {
-                        "description": "Payment verification request",
+                        "type": "string",
+                        "description": "ID of the key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New settings of the key, its id is ignored",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.PaymentVerifyRequest"
+                            "$ref": "#/definitions/apikey.Spec"
                         }
                     }
                 ],
@@ -118,7 +451,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.PaymentVerifyResponse"
+                            "$ref": "#/definitions/types.APIKey"
                         }
                     },
                     "400": {
@@ -127,163 +460,2566 @@ const docTemplate = `{
                             "$ref": "#/definitions/echo.HTTPError"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/echo.HTTPError"
                         }
-                    }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Revoke an API key, requests carrying it are rejected afterwards. Other instances apply the revocation within 30 seconds (localhost or admin API key)",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke API key",
+                "operationId": "revokeApiKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/networks/{network}/pause": {
+            "post": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Stop settling payments on a network, e.g. during a reorg or an incident of its RPC endpoints. Settle requests are answered with 503 and settlements still queued fail without being broadcast, while payments are still verified. /supported lists the network with \"paused\" in its extra. The pause lasts until the network is resumed or the instance restarts, and applies to this instance only (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Pause network",
+                "operationId": "pauseNetwork",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CAIP-2 identifier or chain name of the network",
+                        "name": "network",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.NetworkPause"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/networks/{network}/resume": {
+            "post": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Settle payments on a paused network again (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Resume network",
+                "operationId": "resumeNetwork",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CAIP-2 identifier or chain name of the network",
+                        "name": "network",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.NetworkPause"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/recipients": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "List the registered recipients of all networks, including revoked ones, oldest first (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List recipients",
+                "operationId": "listRecipients",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Recipient"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Register an address to receive payments on networks that only pay registered recipients. The signature proves ownership of the address: it signs the message \"x402 facilitator recipient registration\\nNetwork: \u003cnetwork\u003e\\nAddress: \u003caddress\u003e\\nIssued At: \u003cissuedAt in RFC 3339, UTC\u003e\" with EIP-191 personal_sign (hex) on EVM networks or ed25519 (base58) on Solana (localhost or admin API key)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Register recipient",
+                "operationId": "registerRecipient",
+                "parameters": [
+                    {
+                        "description": "Signed registration",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/recipient.Registration"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Recipient"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/recipients/{network}/{address}": {
+            "delete": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Revoke the registration of an address, payments to it are rejected afterwards. Registering it again needs a message issued after the revocation (localhost or admin API key)",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke recipient",
+                "operationId": "revokeRecipient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CAIP-2 identifier of the network",
+                        "name": "network",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Registered address",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/reconciliation": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get the progress of the indexer per network and the most recent transfers of the signers without a settlement on record and settlements whose transaction wasn't mined, newest first (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reconciliation findings",
+                "operationId": "reconciliation",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Reconciliation"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/refunds": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "List the refunds of a settlement, or of all settlements, oldest first (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List refunds",
+                "operationId": "listRefunds",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only list the refunds of this settlement",
+                        "name": "settlementId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Refund"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Record a refund of a settlement to its payer. Either submit an EIP-3009 authorization of the payee transferring the amount to the payer, which is settled like a payment, or the hash of a refund transaction the payee sent. The refund is tracked until it is confirmed or fails, and its transitions are published as settlement events carrying the refund ID to the websocket stream and webhooks (localhost or admin API key)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Refund settlement",
+                "operationId": "createRefund",
+                "parameters": [
+                    {
+                        "description": "Refund",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.RefundRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Refund"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/refunds/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get a refund and its progress (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get refund",
+                "operationId": "getRefund",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Refund ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Refund"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/runtime": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Report the goroutine count, heap statistics, recent GC pauses and open RPC connections by network. CPU, heap, goroutine and other profiles are served by net/http/pprof under /admin/debug/pprof/ and runtime variables by expvar at /admin/debug/vars (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Runtime diagnostics",
+                "operationId": "runtime",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.RuntimeStats"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/settlements/{id}/debug": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get a settlement and the diagnostic trail it failed with: the simulation result and revert data, gas estimates, RPC errors, retries and the raw signed transaction. The trail is only kept for failed and expired settlements (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Debug settlement",
+                "operationId": "settlementDebug",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Settlement ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.SettlementDebug"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/stats": {
+            "get": {
+                "security": [
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Count the settlements created in [from, to) by minute, hour or day with their success rate, mean time to confirmation and settled volume by network and asset, for reports that don't need the raw data of /admin/export. Buckets start at from truncated to the granularity, days at midnight UTC (localhost or admin API key)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Settlement statistics",
+                "operationId": "settlementStats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "minute, hour (default) or day",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period (RFC 3339), defaults to 24 hours before to",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period (RFC 3339), defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.SettlementStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/routes": {
+            "get": {
+                "description": "List all routes registered on the server (localhost only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "List routes",
+                "operationId": "listRoutes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/echo.Route"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/openapi.yaml": {
+            "get": {
+                "description": "Get the OpenAPI 3.1 document of the API, which the clients under api/gen are generated from",
+                "produces": [
+                    "application/yaml"
+                ],
+                "tags": [
+                    "discovery"
+                ],
+                "summary": "OpenAPI document",
+                "operationId": "openAPI",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/receipts/{txHash}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get the receipt of a settlement signed by the facilitator. The signature is an EIP-712 signature of the signer over the receipt in the domain {name: \"x402 facilitator receipt\", version: \"1\"}, of the primary type Receipt, or ReferencedReceipt for settlements with a reference, see package receipt",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Settlement receipt",
+                "operationId": "receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hash of the settlement transaction",
+                        "name": "txHash",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.SettlementReceipt"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/requirements": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Register payment requirements and get their ID, which verify and settle requests can send as requirementsId instead of paymentRequirements. Registering the same requirements again returns the same ID and renews them. IDs are only valid for the tenant that registered them and expire a TTL after the last registration",
+                "consumes": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Register requirements",
+                "operationId": "registerRequirements",
+                "parameters": [
+                    {
+                        "description": "Requirements to register",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentRequirements"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.RegisteredRequirements"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/requirements/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Get the payment requirements registered under the ID",
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Get requirements",
+                "operationId": "getRequirements",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the requirements",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.RegisteredRequirements"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/settle": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Settle a payment using the facilitator. Version 2 requests (paymentPayload instead of paymentHeader) are answered with a version 2 settle response. Payments are routed by their network, those on networks that aren't configured are answered with 400 and UNSUPPORTED_NETWORK. Requests with a scheduling window (notBefore or notAfter) are verified, answered with 202, or 200 and a failed settle response if the payment is rejected, and settled in the background within the window, once the gas price of the network falls to its threshold or the window closes. Their outcome is published as settlement events",
+                "consumes": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Settle payment",
+                "operationId": "settle",
+                "parameters": [
+                    {
+                        "description": "Settlement request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentSettleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentSettleResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/types.ScheduledSettlementResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.UnsupportedNetworkResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/settle/estimate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Simulate a settlement without broadcasting it and estimate its gas cost",
+                "consumes": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Estimate settlement",
+                "operationId": "estimateSettle",
+                "parameters": [
+                    {
+                        "description": "Settlement request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentSettleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentEstimateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.UnsupportedNetworkResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/supported": {
+            "get": {
+                "description": "Get the supported payment kinds of every configured network and the facilitator signer addresses",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "List supported kinds",
+                "operationId": "supported",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.SupportedResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/verify": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Verify a payment using the facilitator. Version 2 requests (paymentPayload instead of paymentHeader) are answered with a version 2 verify response. Payments are routed by their network, those on networks that aren't configured are answered with 400 and UNSUPPORTED_NETWORK. Networks that verify through RPC outages answer with chainChecksSkipped while the chain can't be reached. Valid results are reused for the same payload and requirements for a few seconds, requests with Cache-Control: no-cache verify against the chain again",
+                "consumes": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Verify payment",
+                "operationId": "verify",
+                "parameters": [
+                    {
+                        "description": "Payment verification request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentVerifyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.PaymentVerifyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.UnsupportedNetworkResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Get the version, commit and build date of the running facilitator",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "discovery"
+                ],
+                "summary": "Build information",
+                "operationId": "version",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_gosuda_x402-facilitator_internal_version.Info"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws/settlements": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "HMAC": []
+                    },
+                    {
+                        "APIKey": []
+                    }
+                ],
+                "description": "Upgrade to a websocket and receive settlement.Event JSON messages for every state transition (queued, submitted, mined, confirmed, failed, expired), and for the transitions of refunds of settlements, which carry the refundId. Clients authenticated as a tenant only receive the settlements of the tenant, and clients with an API key limited to some networks those on the networks. The stream needs authentication, facilitators without it answer 403, and browsers may only connect from the origins CORS allows",
+                "tags": [
+                    "settlements"
+                ],
+                "summary": "Stream settlement updates",
+                "operationId": "settlementStream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only stream settlements on this network",
+                        "name": "network",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only stream settlements of this payer",
+                        "name": "payer",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/settlement.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/echo.HTTPError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apikey.Spec": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "ID of the key, generated if empty. Lowercase letters, digits and dashes",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Description of the key, e.g. who it was issued to",
+                    "type": "string"
+                },
+                "networks": {
+                    "description": "CAIP-2 identifiers or families like \"eip155:*\" of the networks the key may pay on, all if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scopes": {
+                    "description": "Scopes granted to the key: verify, settle, read-status or admin",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "diagnostics.Entry": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "kind": {
+                    "$ref": "#/definitions/diagnostics.Kind"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "diagnostics.Kind": {
+            "type": "string",
+            "enum": [
+                "simulation",
+                "gas_estimate",
+                "signed_transaction",
+                "retry",
+                "rpc_error",
+                "deferral",
+                "receipt",
+                "error"
+            ],
+            "x-enum-varnames": [
+                "KindSimulation",
+                "KindGasEstimate",
+                "KindSignedTransaction",
+                "KindRetry",
+                "KindRPCError",
+                "KindDeferral",
+                "KindReceipt",
+                "KindError"
+            ]
+        },
+        "echo.HTTPError": {
+            "type": "object",
+            "properties": {
+                "message": {}
+            }
+        },
+        "echo.Route": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_gosuda_x402-facilitator_internal_version.Info": {
+            "type": "object",
+            "properties": {
+                "commit": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "goVersion": {
+                    "type": "string"
+                },
+                "modified": {
+                    "description": "Whether the working tree had uncommitted changes, known for VCS builds only",
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "recipient.Registration": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "issuedAt": {
+                    "description": "When the owner signed the registration message",
+                    "type": "string"
+                },
+                "network": {
+                    "description": "CAIP-2 identifier of the network, e.g. \"eip155:8453\"",
+                    "type": "string"
+                },
+                "signature": {
+                    "description": "Signature of the address over Message: EIP-191 personal_sign in hex on\nEVM networks, ed25519 in base58 on Solana",
+                    "type": "string"
+                }
+            }
+        },
+        "settlement.Event": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount paid in atomic units of the asset",
+                    "type": "string"
+                },
+                "amountUsd": {
+                    "description": "USD value of the payment at submission, present only if a price oracle is configured",
+                    "type": "number"
+                },
+                "asset": {
+                    "description": "Symbol of the paid asset, or its address if the symbol is unknown",
+                    "type": "string"
+                },
+                "blockNumber": {
+                    "description": "Block number the transaction was included in, once mined",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "Error message, if the settlement failed",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Unique ID of the settlement",
+                    "type": "string"
+                },
+                "network": {
+                    "description": "Network the settlement is executed on",
+                    "type": "string"
+                },
+                "payTo": {
+                    "description": "Recipient of the payment",
+                    "type": "string"
+                },
+                "payer": {
+                    "description": "Address of the payer, if known",
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Reference the resource server attached to the settle request, if any",
+                    "type": "string"
+                },
+                "refundId": {
+                    "description": "ID of the refund of the settlement the event is about, absent for events of the settlement itself",
+                    "type": "string"
+                },
+                "requestId": {
+                    "description": "ID of the API request of the settlement, the X-Request-ID header",
+                    "type": "string"
+                },
+                "scheme": {
+                    "description": "Scheme used for the settlement",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "New status of the settlement",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/settlement.Status"
+                        }
+                    ]
+                },
+                "tenant": {
+                    "description": "Tenant whose API key requested the settlement, if any",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "Time of the transition",
+                    "type": "string"
+                },
+                "txHash": {
+                    "description": "Transaction hash, once submitted",
+                    "type": "string"
+                }
+            }
+        },
+        "settlement.Status": {
+            "type": "string",
+            "enum": [
+                "scheduled",
+                "queued",
+                "submitted",
+                "mined",
+                "confirmed",
+                "failed",
+                "expired"
+            ],
+            "x-enum-varnames": [
+                "StatusScheduled",
+                "StatusQueued",
+                "StatusSubmitted",
+                "StatusMined",
+                "StatusConfirmed",
+                "StatusFailed",
+                "StatusExpired"
+            ]
+        },
+        "types.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "networks": {
+                    "description": "Networks the key may pay on, all if absent",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "revokedAt": {
+                    "description": "When the key was revoked, absent while it is valid",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes granted to the key: verify, settle, read-status or admin",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "types.CostReport": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "description": "Costs by network and asset",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.CostReportEntry"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "totalAmountUsd": {
+                    "description": "Sum of the settled payments that could be priced in USD",
+                    "type": "number"
+                },
+                "totalFeeUsd": {
+                    "description": "Sum of the fees that could be priced in USD",
+                    "type": "number"
+                }
+            }
+        },
+        "types.CostReportEntry": {
+            "type": "object",
+            "properties": {
+                "amountUsd": {
+                    "description": "Total value of the successful settlements in USD. Only present if every payment could be priced",
+                    "type": "number"
+                },
+                "asset": {
+                    "description": "Symbol of the paid asset, or its address if the symbol is unknown",
+                    "type": "string"
+                },
+                "fee": {
+                    "description": "Total fees in atomic units of the fee currency",
+                    "type": "string"
+                },
+                "feeCurrency": {
+                    "description": "Symbol of the token fees are paid in",
+                    "type": "string"
+                },
+                "feeNative": {
+                    "description": "Total fees in the fee currency, as a decimal string",
+                    "type": "string"
+                },
+                "feeUsd": {
+                    "description": "Total fees in USD, priced when each transaction was mined. Only present if every fee could be priced",
+                    "type": "number"
+                },
+                "gasUsed": {
+                    "description": "Total gas used",
+                    "type": "integer"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "reverted": {
+                    "description": "Number of settlement transactions that reverted",
+                    "type": "integer"
+                },
+                "settlements": {
+                    "description": "Number of mined settlement transactions, including reverted ones",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.CreatedAPIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "The key, sent in the X-API-Key header. It is not stored and only returned once",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "networks": {
+                    "description": "Networks the key may pay on, all if absent",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "revokedAt": {
+                    "description": "When the key was revoked, absent while it is valid",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes granted to the key: verify, settle, read-status or admin",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "types.Dashboard": {
+            "type": "object",
+            "properties": {
+                "activity": {
+                    "description": "Settlements created in the window, by minute",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.DashboardBucket"
+                    }
+                },
+                "generatedAt": {
+                    "type": "string"
+                },
+                "inFlight": {
+                    "description": "Settlements being submitted by a worker",
+                    "type": "integer"
+                },
+                "networks": {
+                    "description": "Settlements created in the window by network",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.DashboardNetwork"
+                    }
+                },
+                "paused": {
+                    "description": "Networks whose settlements are paused",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.NetworkPause"
+                    }
+                },
+                "queueDepth": {
+                    "description": "Settlements waiting for a worker",
+                    "type": "integer"
+                },
+                "signers": {
+                    "description": "Gas balances of the signers when they were last checked",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.DashboardSigner"
+                    }
+                }
+            }
+        },
+        "types.DashboardBucket": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "pending": {
+                    "description": "Settlements not submitted yet",
+                    "type": "integer"
+                },
+                "settled": {
+                    "description": "Settlements submitted, mined or confirmed",
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.DashboardNetwork": {
+            "type": "object",
+            "properties": {
+                "errorRate": {
+                    "description": "Share of the finished settlements that failed, 0 if none finished",
+                    "type": "number"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "settled": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.DashboardSigner": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "number"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "signer": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "JSON path of the field (e.g. \"paymentRequirements.payTo\")",
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.IndexedNetwork": {
+            "type": "object",
+            "properties": {
+                "block": {
+                    "description": "Last block whose transfers were reconciled, 0 before the first one",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "Error of the last indexing pass, empty if it succeeded",
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.NetworkPause": {
+            "type": "object",
+            "properties": {
+                "network": {
+                    "description": "CAIP-2 identifier of the network",
+                    "type": "string"
+                },
+                "paused": {
+                    "type": "boolean"
+                },
+                "pausedAt": {
+                    "description": "When settlements were paused, unset while they proceed",
+                    "type": "string"
+                }
+            }
+        },
+        "types.PaymentEstimateResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error message of the failed simulation, if any",
+                    "type": "string"
+                },
+                "gasCost": {
+                    "description": "Estimated gas cost in atomic units of the native token",
+                    "type": "string"
+                },
+                "gasCostNative": {
+                    "description": "Estimated gas cost in the native token, as a decimal string",
+                    "type": "string"
+                },
+                "gasCostUsd": {
+                    "description": "Estimated gas cost in USD, present only if a price oracle is configured",
+                    "type": "number"
+                },
+                "gasLimit": {
+                    "description": "Estimated gas limit of the settlement transaction",
+                    "type": "integer"
+                },
+                "gasPrice": {
+                    "description": "Gas price in atomic units of the native token",
+                    "type": "string"
+                },
+                "nativeCurrency": {
+                    "description": "Symbol of the native token the gas is paid in",
+                    "type": "string"
+                },
+                "payer": {
+                    "description": "Address of the payer",
+                    "type": "string"
+                },
+                "success": {
+                    "description": "Whether the simulated settlement succeeded",
+                    "type": "boolean"
+                }
+            }
+        },
+        "types.PaymentPayload": {
+            "type": "object",
+            "properties": {
+                "network": {
+                    "description": "Network ID of the accepted paymentRequirements the client is using to pay",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is E-dependent and may contain authorization and signature data",
+                    "type": "object"
+                },
+                "scheme": {
+                    "description": "Scheme value of the accepted paymentRequirements the client is using to pay",
+                    "type": "string"
+                },
+                "x402Version": {
+                    "description": "Version of the x402 payment protocol",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.PaymentRequirements": {
+            "type": "object",
+            "properties": {
+                "asset": {
+                    "description": "Address of the EIP-3009 compliant ERC20 contract",
+                    "type": "string"
+                },
+                "description": {
+                    "description": "Description of the resource",
+                    "type": "string"
+                },
+                "extra": {
+                    "description": "Extra information about the payment details specific to the scheme",
+                    "type": "object"
+                },
+                "maxAmountRequired": {
+                    "description": "Maximum amount required to pay for the resource in atomic units",
+                    "type": "string"
+                },
+                "maxTimeoutSeconds": {
+                    "description": "Maximum time in seconds for the resource server to respond",
+                    "type": "integer"
+                },
+                "mimeType": {
+                    "description": "MIME type of the resource response",
+                    "type": "string"
+                },
+                "network": {
+                    "description": "Network of the blockchain to send payment on (e.g., \"base-sepolia\")",
+                    "type": "string"
+                },
+                "outputSchema": {
+                    "description": "Output schema of the resource response (optional)",
+                    "type": "object"
+                },
+                "payTo": {
+                    "description": "Address to pay value to",
+                    "type": "string"
+                },
+                "resource": {
+                    "description": "URL of the resource to pay for",
+                    "type": "string"
+                },
+                "scheme": {
+                    "description": "Scheme of the payment protocol to use (e.g., \"exact\")",
+                    "type": "string"
+                }
+            }
+        },
+        "types.PaymentSettleRequest": {
+            "type": "object",
+            "properties": {
+                "notAfter": {
+                    "description": "Unix time by which the settlement is submitted, at the latest when the\nauthorization expires. The window opens at notBefore or right away",
+                    "type": "integer"
+                },
+                "notBefore": {
+                    "description": "Unix time before which the settlement isn't submitted. Requests with a\nscheduling window are answered with 202 and settled in the background",
+                    "type": "integer"
+                },
+                "orderingKey": {
+                    "description": "Settlements of the same tenant and payee with this key are submitted one\nafter the other in the order they arrive, at most 128 characters",
+                    "type": "string"
+                },
+                "paymentHeader": {
+                    "$ref": "#/definitions/types.PaymentPayload"
+                },
+                "paymentRequirements": {
+                    "description": "Requirements of the payment, required unless requirementsId is set",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.PaymentRequirements"
+                        }
+                    ]
+                },
+                "priority": {
+                    "description": "Priority of the settlement when the queue is backed up, capped by the priority of the tenant. The tenant's applies if 0",
+                    "type": "integer"
+                },
+                "reference": {
+                    "description": "Opaque reference of the resource server, e.g. an order ID, returned in the\nresponse and, on settlement, kept with the settlement, its webhook events\nand receipt. At most 256 characters",
+                    "type": "string"
+                },
+                "requirementsId": {
+                    "description": "ID of requirements registered with POST /requirements, instead of paymentRequirements",
+                    "type": "string"
+                },
+                "timeoutMs": {
+                    "description": "Deadline of the settlement in milliseconds, capped by the server maximum. The server default applies if 0",
+                    "type": "integer"
+                },
+                "x402Version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.PaymentSettleResponse": {
+            "type": "object",
+            "properties": {
+                "amountUsd": {
+                    "description": "Value of the settled amount in USD, present only if a price oracle is configured",
+                    "type": "number"
+                },
+                "error": {
+                    "description": "Error message, if any",
+                    "type": "string"
+                },
+                "networkId": {
+                    "description": "Network ID where the transaction was submitted",
+                    "type": "string"
+                },
+                "payer": {
+                    "description": "Address of the payer",
+                    "type": "string"
+                },
+                "receipt": {
+                    "description": "Receipt of the settlement signed by the facilitator, present only if receipts are enabled",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.SettlementReceipt"
+                        }
+                    ]
+                },
+                "reference": {
+                    "description": "Reference of the request, if it had one",
+                    "type": "string"
+                },
+                "splits": {
+                    "description": "Shares of a split payment sent to their recipients",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.SplitTransfer"
+                    }
+                },
+                "success": {
+                    "description": "Whether the payment was successful",
+                    "type": "boolean"
+                },
+                "txHash": {
+                    "description": "Transaction hash of the settled payment",
+                    "type": "string"
+                }
+            }
+        },
+        "types.PaymentVerifyRequest": {
+            "type": "object",
+            "properties": {
+                "paymentHeader": {
+                    "$ref": "#/definitions/types.PaymentPayload"
+                },
+                "paymentRequirements": {
+                    "description": "Requirements of the payment, required unless requirementsId is set",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.PaymentRequirements"
+                        }
+                    ]
+                },
+                "reference": {
+                    "description": "Opaque reference of the resource server, e.g. an order ID, returned in the\nresponse and, on settlement, kept with the settlement, its webhook events\nand receipt. At most 256 characters",
+                    "type": "string"
+                },
+                "requirementsId": {
+                    "description": "ID of requirements registered with POST /requirements, instead of paymentRequirements",
+                    "type": "string"
+                },
+                "x402Version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.PaymentVerifyResponse": {
+            "type": "object",
+            "properties": {
+                "chainChecksSkipped": {
+                    "description": "Whether the chain couldn't be reached, so only the signature and terms of\nthe payment were checked, not the balance of the payer or whether the\nauthorization was used",
+                    "type": "boolean"
+                },
+                "invalidReason": {
+                    "description": "Error message or reason for invalidity, if applicable",
+                    "type": "string"
+                },
+                "isValid": {
+                    "description": "Whether the payment payload is valid",
+                    "type": "boolean"
+                },
+                "payer": {
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Reference of the request, if it had one",
+                    "type": "string"
+                }
+            }
+        },
+        "types.Recipient": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Registration message and the signature of the address over it",
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "registeredAt": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "description": "When the registration was revoked, absent while it is valid",
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.Reconciliation": {
+            "type": "object",
+            "properties": {
+                "findings": {
+                    "description": "Most recent findings, newest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.ReconciliationFinding"
+                    }
+                },
+                "networks": {
+                    "description": "Progress of the indexer by network",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.IndexedNetwork"
+                    }
+                }
+            }
+        },
+        "types.ReconciliationFinding": {
+            "type": "object",
+            "properties": {
+                "block": {
+                    "description": "Block of the transfer, 0 for missing receipts",
+                    "type": "integer"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "detectedAt": {
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "FindingOrphanedTransfer or FindingMissingReceipt",
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "settlementId": {
+                    "description": "Settlement the finding concerns, empty for transfers without one",
+                    "type": "string"
+                },
+                "txHash": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.Refund": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Refunded amount in atomic units of the asset",
+                    "type": "string"
+                },
+                "asset": {
+                    "type": "string"
+                },
+                "blockNumber": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "from": {
+                    "description": "Sender of the refund, absent for transactions sent by the payee",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "settlementId": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "submitted, mined, confirmed or failed",
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "txHash": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.RefundRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Refunded amount in atomic units of the asset",
+                    "type": "string"
+                },
+                "asset": {
+                    "description": "Address of the refunded asset, the asset of the settlement if empty",
+                    "type": "string"
+                },
+                "paymentPayload": {
+                    "description": "Authorization of the transfer back to the payer, mutually exclusive with TxHash",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.PaymentPayload"
+                        }
+                    ]
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "settlementId": {
+                    "description": "ID of the settlement the refund reverses",
+                    "type": "string"
+                },
+                "txHash": {
+                    "description": "Hash of a refund transaction sent by the payee, mutually exclusive with PaymentPayload",
+                    "type": "string"
                 }
             }
-        }
-    },
-    "definitions": {
-        "echo.HTTPError": {
+        },
+        "types.RegisteredRequirements": {
             "type": "object",
             "properties": {
-                "message": {}
+                "id": {
+                    "description": "ID to send as requirementsId of verify and settle requests",
+                    "type": "string"
+                },
+                "paymentRequirements": {
+                    "$ref": "#/definitions/types.PaymentRequirements"
+                }
             }
         },
-        "types.PaymentPayload": {
+        "types.RuntimeGC": {
             "type": "object",
             "properties": {
-                "network": {
-                    "description": "Network ID of the accepted paymentRequirements the client is using to pay",
+                "count": {
+                    "description": "Completed collections",
+                    "type": "integer"
+                },
+                "cpuFraction": {
+                    "description": "Share of the CPU time used by the collector since the process started",
+                    "type": "number"
+                },
+                "lastAt": {
                     "type": "string"
                 },
-                "payload": {
-                    "description": "Payload is E-dependent and may contain authorization and signature data",
+                "nextHeap": {
+                    "description": "Heap size the next collection starts at, in bytes",
+                    "type": "integer"
+                },
+                "pauseTotalMs": {
+                    "description": "Total stop-the-world pause time in milliseconds",
+                    "type": "number"
+                },
+                "recentPausesMs": {
+                    "description": "Pauses of the most recent collections in milliseconds, newest first",
                     "type": "array",
                     "items": {
-                        "type": "integer"
+                        "type": "number"
                     }
+                }
+            }
+        },
+        "types.RuntimeHeap": {
+            "type": "object",
+            "properties": {
+                "alloc": {
+                    "description": "Bytes of allocated heap objects",
+                    "type": "integer"
                 },
-                "scheme": {
-                    "description": "Scheme value of the accepted paymentRequirements the client is using to pay",
+                "idle": {
+                    "description": "Bytes in spans without objects, including those returned to the OS",
+                    "type": "integer"
+                },
+                "inUse": {
+                    "description": "Bytes in spans holding at least one object",
+                    "type": "integer"
+                },
+                "objects": {
+                    "description": "Number of allocated heap objects",
+                    "type": "integer"
+                },
+                "released": {
+                    "description": "Bytes returned to the OS",
+                    "type": "integer"
+                },
+                "sys": {
+                    "description": "Bytes of heap memory obtained from the OS",
+                    "type": "integer"
+                },
+                "totalSys": {
+                    "description": "Total bytes of memory obtained from the OS, not only for the heap",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.RuntimeStats": {
+            "type": "object",
+            "properties": {
+                "gc": {
+                    "$ref": "#/definitions/types.RuntimeGC"
+                },
+                "generatedAt": {
                     "type": "string"
                 },
-                "x402Version": {
-                    "description": "Version of the x402 payment protocol",
+                "goVersion": {
+                    "type": "string"
+                },
+                "goroutines": {
+                    "type": "integer"
+                },
+                "heap": {
+                    "$ref": "#/definitions/types.RuntimeHeap"
+                },
+                "maxProcs": {
+                    "description": "Value of GOMAXPROCS",
                     "type": "integer"
+                },
+                "rpcConnections": {
+                    "description": "Open connections to the RPC endpoints by network",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "uptimeSeconds": {
+                    "description": "Seconds since the process started",
+                    "type": "number"
                 }
             }
         },
-        "types.PaymentRequirements": {
+        "types.ScheduledSettlementResponse": {
+            "type": "object",
+            "properties": {
+                "notAfter": {
+                    "description": "Unix time the window closes, capped by the expiry of the authorization",
+                    "type": "integer"
+                },
+                "notBefore": {
+                    "description": "Unix time the window opens",
+                    "type": "integer"
+                },
+                "reference": {
+                    "description": "Reference of the request, if it had one",
+                    "type": "string"
+                },
+                "settlementId": {
+                    "description": "ID of the settlement",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status of the settlement, \"scheduled\"",
+                    "type": "string"
+                }
+            }
+        },
+        "types.SettlementDebug": {
             "type": "object",
             "properties": {
                 "asset": {
-                    "description": "Address of the EIP-3009 compliant ERC20 contract",
                     "type": "string"
                 },
-                "description": {
-                    "description": "Description of the resource",
+                "blockNumber": {
+                    "type": "integer"
+                },
+                "createdAt": {
                     "type": "string"
                 },
-                "extra": {
-                    "description": "Extra information about the payment details specific to the scheme",
+                "diagnostics": {
+                    "description": "Steps of the settlement in the order they happened, empty unless it failed",
                     "type": "array",
                     "items": {
-                        "type": "integer"
+                        "$ref": "#/definitions/diagnostics.Entry"
                     }
                 },
-                "maxAmountRequired": {
-                    "description": "Maximum amount required to pay for the resource in atomic units",
+                "error": {
                     "type": "string"
                 },
-                "maxTimeoutSeconds": {
-                    "description": "Maximum time in seconds for the resource server to respond",
+                "gasUsed": {
                     "type": "integer"
                 },
-                "mimeType": {
-                    "description": "MIME type of the resource response",
+                "id": {
                     "type": "string"
                 },
                 "network": {
-                    "description": "Network of the blockchain to send payment on (e.g., \"base-sepolia\")",
                     "type": "string"
                 },
-                "outputSchema": {
-                    "description": "Output schema of the resource response (optional)",
-                    "type": "array",
-                    "items": {
-                        "type": "integer"
-                    }
+                "payer": {
+                    "type": "string"
                 },
-                "payTo": {
-                    "description": "Address to pay value to",
+                "reference": {
+                    "description": "Reference the resource server attached to the settle request",
                     "type": "string"
                 },
-                "resource": {
-                    "description": "URL of the resource to pay for",
+                "requestId": {
+                    "description": "ID of the API request of the settlement, the X-Request-ID header",
                     "type": "string"
                 },
+                "reverted": {
+                    "type": "boolean"
+                },
                 "scheme": {
-                    "description": "Scheme of the payment protocol to use (e.g., \"exact\")",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "queued, submitted, mined, confirmed, failed or expired",
+                    "type": "string"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "txHash": {
+                    "type": "string"
+                },
+                "updatedAt": {
                     "type": "string"
                 }
             }
         },
-        "types.PaymentSettleRequest": {
+        "types.SettlementReceipt": {
             "type": "object",
             "properties": {
-                "paymentHeader": {
-                    "$ref": "#/definitions/types.PaymentPayload"
+                "amount": {
+                    "description": "Amount in atomic units of the asset",
+                    "type": "string"
                 },
-                "paymentRequirements": {
-                    "$ref": "#/definitions/types.PaymentRequirements"
+                "asset": {
+                    "description": "Address of the paid asset",
+                    "type": "string"
                 },
-                "x402Version": {
+                "network": {
+                    "description": "CAIP-2 identifier of the network the payment was settled on",
+                    "type": "string"
+                },
+                "payee": {
+                    "description": "Address of the payee",
+                    "type": "string"
+                },
+                "payer": {
+                    "description": "Address of the payer",
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Reference the settle request carried, signed only if present",
+                    "type": "string"
+                },
+                "signature": {
+                    "description": "EIP-712 signature of the signer over the receipt, hex encoded",
+                    "type": "string"
+                },
+                "signer": {
+                    "description": "Address of the facilitator key that signed the receipt",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "Unix time in seconds the settlement was submitted at",
                     "type": "integer"
+                },
+                "txHash": {
+                    "description": "Hash of the settlement transaction",
+                    "type": "string"
                 }
             }
         },
-        "types.PaymentSettleResponse": {
+        "types.SettlementStats": {
             "type": "object",
             "properties": {
-                "error": {
-                    "description": "Error message, if any",
+                "buckets": {
+                    "description": "Buckets of the period in order, including empty ones",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.SettlementStatsBucket"
+                    }
+                },
+                "from": {
                     "type": "string"
                 },
-                "networkId": {
-                    "description": "Network ID where the transaction was submitted",
+                "granularity": {
+                    "description": "Length of the buckets: minute, hour or day",
                     "type": "string"
                 },
-                "success": {
-                    "description": "Whether the payment was successful",
-                    "type": "boolean"
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.SettlementStatsBucket": {
+            "type": "object",
+            "properties": {
+                "avgLatencyMs": {
+                    "description": "Mean time from the settle request to the confirmation of confirmed settlements in milliseconds, absent if none was confirmed",
+                    "type": "number"
                 },
-                "txHash": {
-                    "description": "Transaction hash of the settled payment",
+                "failed": {
+                    "type": "integer"
+                },
+                "pending": {
+                    "description": "Settlements not submitted yet",
+                    "type": "integer"
+                },
+                "settled": {
+                    "description": "Settlements submitted, mined or confirmed",
+                    "type": "integer"
+                },
+                "settlements": {
+                    "description": "Settlements created",
+                    "type": "integer"
+                },
+                "start": {
                     "type": "string"
+                },
+                "successRate": {
+                    "description": "Share of the settled and failed settlements that settled, absent if none finished",
+                    "type": "number"
+                },
+                "volumes": {
+                    "description": "Settled amounts by network and asset",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.SettlementVolume"
+                    }
                 }
             }
         },
-        "types.PaymentVerifyRequest": {
+        "types.SettlementVolume": {
             "type": "object",
             "properties": {
-                "paymentHeader": {
-                    "$ref": "#/definitions/types.PaymentPayload"
+                "amount": {
+                    "description": "Total amount in atomic units of the asset",
+                    "type": "string"
                 },
-                "paymentRequirements": {
-                    "$ref": "#/definitions/types.PaymentRequirements"
+                "amountDecimal": {
+                    "description": "Total amount in whole units, absent if the decimals of the asset are unknown",
+                    "type": "string"
                 },
-                "x402Version": {
+                "amountUsd": {
+                    "description": "Total value in USD at submission. Only present if every payment could be priced",
+                    "type": "number"
+                },
+                "asset": {
+                    "description": "Symbol of the paid asset, or its address if the symbol is unknown",
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "settlements": {
                     "type": "integer"
                 }
             }
         },
-        "types.PaymentVerifyResponse": {
+        "types.SplitTransfer": {
             "type": "object",
             "properties": {
-                "invalidReason": {
-                    "description": "Error message or reason for invalidity, if applicable",
+                "amount": {
+                    "description": "Amount in atomic units of the asset",
                     "type": "string"
                 },
-                "isValid": {
-                    "description": "Whether the payment payload is valid",
-                    "type": "boolean"
+                "recipient": {
+                    "type": "string"
                 },
-                "payer": {
+                "txHash": {
+                    "description": "Transaction of the transfer, the settlement transaction with splitter contracts",
                     "type": "string"
                 }
             }
@@ -291,14 +3027,123 @@ const docTemplate = `{
         "types.SupportedKind": {
             "type": "object",
             "properties": {
+                "extra": {
+                    "description": "Extra information clients need to pay with this kind (e.g. the Solana fee\npayer), the accepted assets under \"assets\"",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
                 "network": {
                     "type": "string"
                 },
                 "scheme": {
                     "type": "string"
+                },
+                "x402Version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.SupportedResponse": {
+            "type": "object",
+            "properties": {
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.SupportedKind"
+                    }
+                },
+                "signers": {
+                    "description": "Addresses of the facilitator signers by CAIP-2 family (e.g. \"eip155:*\")",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "types.UnsupportedNetworkResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "supportedNetworks": {
+                    "description": "CAIP-2 identifiers of the networks payments are accepted on",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "types.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.FieldError"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.WellKnownResponse": {
+            "type": "object",
+            "properties": {
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.SupportedKind"
+                    }
+                },
+                "signers": {
+                    "description": "Addresses of the facilitator signers by CAIP-2 network (e.g. \"eip155:8453\")",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "x402Versions": {
+                    "description": "x402 versions the facilitator accepts",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "APIKey": {
+            "description": "API key created under /admin/keys, if the facilitator manages keys. The key needs the scope of the endpoint",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT of an API client, sent as \"Bearer \u003ctoken\u003e\", if the facilitator verifies tokens",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        },
+        "HMAC": {
+            "description": "HMAC-SHA256 signature of the request with a shared secret, \"sha256=\u003chex\u003e\", if the facilitator has HMAC keys. The X-Key-Id, X-Timestamp and X-Nonce headers are signed along",
+            "type": "apiKey",
+            "name": "X-Signature",
+            "in": "header"
+        }
     }
 }`
 
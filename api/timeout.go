@@ -0,0 +1,137 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/api/middleware"
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// Default deadlines of the endpoints
+const (
+	defaultVerifyTimeout    = 10 * time.Second
+	defaultSettleTimeout    = 30 * time.Second
+	defaultEstimateTimeout  = 10 * time.Second
+	DefaultMaxSettleTimeout = 2 * time.Minute
+	defaultRouteTimeout     = 30 * time.Second
+)
+
+// ownDeadline lists the routes that don't get a route deadline: the payment
+// endpoints apply their own, the settlement stream lives as long as the client
+// and profiles are taken for as many seconds as asked for.
+var ownDeadline = map[string]bool{
+	"/verify":                    true,
+	"/settle":                    true,
+	"/settle/estimate":           true,
+	"/ws/settlements":            true,
+	"/admin/debug/pprof/profile": true,
+	"/admin/debug/pprof/trace":   true,
+	"/admin/debug/pprof/:name":   true,
+}
+
+// TimeoutConfig bounds how long the payment endpoints work on a request.
+// Zero values select the defaults.
+type TimeoutConfig struct {
+	// Deadline of /verify requests
+	Verify time.Duration `mapstructure:"verify"`
+	// Deadline of /settle requests that don't ask for one
+	Settle time.Duration `mapstructure:"settle"`
+	// Deadline of /settle/estimate requests that don't ask for one
+	Estimate time.Duration `mapstructure:"estimate"`
+	// Upper bound of the deadline settle requests may ask for with timeoutMs
+	MaxSettle time.Duration `mapstructure:"maxSettle"`
+	// Deadline of the other routes
+	Default time.Duration `mapstructure:"default"`
+	// Deadlines of individual other routes by path, e.g. "/admin/costs"
+	Routes map[string]time.Duration `mapstructure:"routes"`
+}
+
+func (c TimeoutConfig) withDefaults() TimeoutConfig {
+	return TimeoutConfig{
+		Verify:    cmp.Or(c.Verify, defaultVerifyTimeout),
+		Settle:    cmp.Or(c.Settle, defaultSettleTimeout),
+		Estimate:  cmp.Or(c.Estimate, defaultEstimateTimeout),
+		MaxSettle: cmp.Or(c.MaxSettle, DefaultMaxSettleTimeout),
+		Default:   cmp.Or(c.Default, defaultRouteTimeout),
+		Routes:    c.Routes,
+	}
+}
+
+// OwnDeadline reports whether the route applies its own deadline, which
+// can't be overridden in Routes.
+func OwnDeadline(path string) bool {
+	return ownDeadline[path]
+}
+
+// routeDeadline is a middleware bounding how long the handlers of routes
+// without their own deadline work on a request.
+func (s *Server) routeDeadline(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		path := middleware.RoutePath(c)
+		if ownDeadline[path] {
+			return next(c)
+		}
+		timeout, ok := s.timeouts.Routes[path]
+		if !ok {
+			timeout = s.timeouts.Default
+		}
+		ctx, cancel := clock.WithTimeout(c.Request().Context(), s.clock, timeout)
+		defer cancel()
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		err := next(c)
+		if err != nil && !c.Response().Committed && timedOut(ctx, err) {
+			return timeoutError()
+		}
+		return err
+	}
+}
+
+// WithTimeouts overrides the default deadlines of the payment endpoints.
+func WithTimeouts(config TimeoutConfig) Option {
+	return func(s *Server) {
+		s.timeouts = config.withDefaults()
+	}
+}
+
+// requestDeadline returns the deadline asked for in milliseconds, capped at the maximum,
+// or the fallback if none was asked for.
+func (c TimeoutConfig) requestDeadline(timeoutMs int64, fallback time.Duration) time.Duration {
+	if timeoutMs <= 0 {
+		return fallback
+	}
+	// compared before converting, large values overflow a time.Duration
+	if timeoutMs >= c.MaxSettle.Milliseconds() {
+		return c.MaxSettle
+	}
+	return time.Duration(timeoutMs) * time.Millisecond
+}
+
+// timedOut reports whether err was caused by the deadline of the request passing.
+func timedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// chainUnavailableError answers requests that need the chain of a network whose
+// RPC endpoints can't be reached with 503 and the CHAIN_UNAVAILABLE code.
+func chainUnavailableError() error {
+	return echo.NewHTTPError(http.StatusServiceUnavailable, types.ErrorResponse{
+		Code:    types.ErrorCodeChainUnavailable,
+		Message: "The chain of the network can't be reached, retry later",
+	})
+}
+
+// timeoutError answers requests whose deadline passed with 504 and the TIMEOUT code.
+func timeoutError() error {
+	return echo.NewHTTPError(http.StatusGatewayTimeout, types.ErrorResponse{
+		Code:    types.ErrorCodeTimeout,
+		Message: "Request deadline exceeded",
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// maxRequestBodySize bounds the body of payment requests, x402 payloads are a few kilobytes at most
+const maxRequestBodySize = 64 << 10
+
+// validatable is a request body that checks its own fields
+type validatable interface {
+	Validate() []types.FieldError
+}
+
+// decodePaymentRequest strictly decodes the request body into dst and validates it.
+// Unknown fields and failed checks are answered with 422 and the list of invalid fields.
+func decodePaymentRequest(body []byte, dst validatable) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if decoder.More() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Request body must contain a single JSON object")
+	}
+	if errs := dst.Validate(); len(errs) > 0 {
+		return validationError(errs...)
+	}
+	return nil
+}
+
+// decodeError maps JSON decoding errors to HTTP errors.
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Request body is too large")
+	case errors.As(err, &typeErr):
+		return validationError(types.FieldError{
+			Field:   typeErr.Field,
+			Message: "must be of type " + typeErr.Type.String(),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return validationError(types.FieldError{Field: field, Message: "is not allowed"})
+	case errors.Is(err, io.EOF):
+		return echo.NewHTTPError(http.StatusBadRequest, "Request body is empty")
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Received malformed request body")
+	}
+}
+
+func validationError(errs ...types.FieldError) error {
+	return echo.NewHTTPError(http.StatusUnprocessableEntity, types.ValidationErrorResponse{
+		Message: "Request body failed validation",
+		Errors:  errs,
+	})
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// paymentRequest is a verify or settle request of any supported protocol
+// version, in the representation the facilitators process.
+type paymentRequest struct {
+	version      types.X402Version
+	payload      *types.PaymentPayload
+	requirements *types.PaymentRequirements
+	timeoutMs    int64
+	priority     int
+	orderingKey  string
+	reference    string
+	// scheduling window of a settle request, zero if it carried none
+	window settlement.Window
+	// nonce and timestamp of a settle request, nil if it carried none
+	stamp *requestStamp
+	// ID the requirements were registered under, empty if the request carried them
+	requirementsID string
+}
+
+// bindVersionedRequest binds the request body to the request type of its
+// x402Version. settle selects the settle request types, which accept a deadline,
+// a priority and an ordering key.
+func (s *Server) bindVersionedRequest(c echo.Context, settle bool) (*paymentRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxRequestBodySize))
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	var probe struct {
+		X402Version int `json:"x402Version"`
+	}
+	// malformed bodies are reported by the strict decoding below
+	_ = json.Unmarshal(body, &probe)
+	version := types.X402Version(probe.X402Version)
+	if version > 0 && !slices.Contains(types.SupportedX402Versions, version) {
+		return nil, validationError(types.FieldError{Field: "x402Version", Message: "is not a supported protocol version"})
+	}
+
+	req := &paymentRequest{version: types.X402VersionV1}
+	switch {
+	case version == types.X402VersionV2 && settle:
+		var v2 types.PaymentSettleRequestV2
+		if err := decodePaymentRequest(body, &v2); err != nil {
+			return nil, err
+		}
+		req.version, req.timeoutMs, req.priority, req.requirementsID = version, v2.TimeoutMs, v2.Priority, v2.RequirementsID
+		req.orderingKey, req.reference = v2.OrderingKey, v2.Reference
+		req.window = window(v2.NotBefore, v2.NotAfter)
+		req.stamp = stampFrom("paymentPayload.accepted.extra", v2.PaymentPayload.Accepted.Extra)
+		req.payload, req.requirements = v2.Payment()
+	case version == types.X402VersionV2:
+		var v2 types.PaymentVerifyRequestV2
+		if err := decodePaymentRequest(body, &v2); err != nil {
+			return nil, err
+		}
+		req.version, req.requirementsID, req.reference = version, v2.RequirementsID, v2.Reference
+		req.payload, req.requirements = v2.Payment()
+	case settle:
+		// version 1, or a missing version that validation reports
+		var v1 types.PaymentSettleRequest
+		if err := decodePaymentRequest(body, &v1); err != nil {
+			return nil, err
+		}
+		req.payload, req.requirements, req.timeoutMs, req.priority = &v1.PaymentHeader, &v1.PaymentRequirements, v1.TimeoutMs, v1.Priority
+		req.requirementsID, req.orderingKey, req.reference = v1.RequirementsID, v1.OrderingKey, v1.Reference
+		req.window = window(v1.NotBefore, v1.NotAfter)
+		if v1.PaymentRequirements.Extra != nil {
+			req.stamp = stampFrom("paymentRequirements.extra", *v1.PaymentRequirements.Extra)
+		}
+	default:
+		var v1 types.PaymentVerifyRequest
+		if err := decodePaymentRequest(body, &v1); err != nil {
+			return nil, err
+		}
+		req.payload, req.requirements, req.requirementsID = &v1.PaymentHeader, &v1.PaymentRequirements, v1.RequirementsID
+		req.reference = v1.Reference
+	}
+
+	if req.requirementsID != "" {
+		if req.requirements, err = s.registeredRequirements(c, req.requirementsID); err != nil {
+			return nil, err
+		}
+		c.SetRequest(c.Request().WithContext(facilitator.WithRegisteredRequirements(c.Request().Context())))
+	}
+	if err := s.checkNetwork(c, req.payload, req.requirements); err != nil {
+		return nil, err
+	}
+	s.translateScheme(req.payload, req.requirements)
+	return req, nil
+}
+
+// window returns the scheduling window between the unix times, zero ones unset.
+func window(notBefore, notAfter int64) settlement.Window {
+	var w settlement.Window
+	if notBefore > 0 {
+		w.NotBefore = time.Unix(notBefore, 0)
+	}
+	if notAfter > 0 {
+		w.NotAfter = time.Unix(notAfter, 0)
+	}
+	return w
+}
+
+// translateScheme replaces the x402 scheme name "exact" by the scheme of the
+// network, which is how the facilitators of this server name their scheme.
+func (s *Server) translateScheme(payload *types.PaymentPayload, req *types.PaymentRequirements) {
+	if payload.Scheme == sdk.SchemeExact {
+		if _, config, ok := s.registry.Lookup(payload.Network); ok {
+			payload.Scheme = string(config.Scheme)
+		}
+	}
+	if req.Scheme == sdk.SchemeExact {
+		if _, config, ok := s.registry.Lookup(req.Network); ok {
+			req.Scheme = string(config.Scheme)
+		}
+	}
+}
+
+// verifyResponse returns the verification result in the response type of the
+// request version, with the reference of the request. resp may be cached, so
+// it is copied rather than changed.
+func verifyResponse(version types.X402Version, resp *types.PaymentVerifyResponse, reference string) any {
+	if version != types.X402VersionV2 {
+		withReference := *resp
+		withReference.Reference = reference
+		return &withReference
+	}
+	return verifyResponseV2{
+		VerifyResponse: sdk.VerifyResponse{
+			IsValid:       resp.IsValid,
+			InvalidReason: resp.InvalidReason,
+			Payer:         resp.Payer,
+		},
+		ChainChecksSkipped: resp.ChainChecksSkipped,
+		Reference:          reference,
+	}
+}
+
+// verifyResponseV2 is the version 2 verify response with the extensions of this facilitator.
+type verifyResponseV2 struct {
+	sdk.VerifyResponse
+	ChainChecksSkipped bool   `json:"chainChecksSkipped,omitempty"`
+	Reference          string `json:"reference,omitempty"`
+}
+
+// settleResponse returns the settlement result in the response type of the
+// request version, with the reference of the request. Version 2 responses name
+// the network of the request, which is a CAIP-2 identifier. resp may be shared
+// with the settlement manager, so it is copied rather than changed.
+func settleResponse(version types.X402Version, network string, resp *types.PaymentSettleResponse, reference string) any {
+	if version != types.X402VersionV2 {
+		withReference := *resp
+		withReference.Reference = reference
+		return &withReference
+	}
+	return settleResponseV2{
+		SettleResponse: sdk.SettleResponse{
+			Success:     resp.Success,
+			ErrorReason: resp.Error,
+			Payer:       resp.Payer,
+			Transaction: resp.TxHash,
+			Network:     sdk.Network(network),
+		},
+		Reference: reference,
+	}
+}
+
+// settleResponseV2 is the version 2 settle response with the extensions of this facilitator.
+type settleResponseV2 struct {
+	sdk.SettleResponse
+	Reference string `json:"reference,omitempty"`
+}
@@ -0,0 +1,317 @@
+// Package apikey manages the API keys callers authenticate with, kept in the
+// store. A key grants scopes, the endpoints it may call, and may be limited to
+// some networks. Only the SHA-256 hash of its secret is stored, the key itself
+// is returned once when it is created.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+// Scopes of API keys
+const (
+	// Verify payments
+	ScopeVerify = "verify"
+	// Settle payments and estimate settlements, includes the verify scope
+	ScopeSettle = "settle"
+	// Stream settlement events and read receipts
+	ScopeReadStatus = "read-status"
+	// Call the operator endpoints under /admin
+	ScopeAdmin = "admin"
+)
+
+// Scopes are all scopes keys can be granted.
+var Scopes = []string{ScopeVerify, ScopeSettle, ScopeReadStatus, ScopeAdmin}
+
+var (
+	// ErrInvalidKey is returned for keys that are malformed, unknown, revoked or don't match their secret
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrInvalidSpec is returned for key specs with unknown scopes, invalid networks or an invalid ID
+	ErrInvalidSpec = errors.New("invalid API key spec")
+	// ErrDuplicateID is returned when creating a key with the ID of an existing one
+	ErrDuplicateID = errors.New("API key ID already exists")
+	// ErrRevoked is returned when rotating a revoked key
+	ErrRevoked = errors.New("API key is revoked")
+)
+
+const (
+	// keyPrefix starts every key, so leaked keys are easy to recognize
+	keyPrefix = "x402_"
+	// secretSize is the number of random bytes of a secret
+	secretSize = 32
+	// cacheTTL bounds how long a key is authenticated from memory, and so how
+	// long revocations by other instances take to apply
+	cacheTTL = 30 * time.Second
+)
+
+// Config configures managed API keys.
+type Config struct {
+	// Accepts the keys of the store in the X-API-Key header and serves their
+	// management under /admin/keys
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// idPattern are the IDs operators may choose, e.g. to name keys in tenant configurations
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Spec describes a key to create or the new settings of a key.
+type Spec struct {
+	// ID of the key, generated if empty. Lowercase letters, digits and dashes
+	ID string `json:"id,omitempty"`
+	// Description of the key, e.g. who it was issued to
+	Name string `json:"name"`
+	// Scopes granted to the key: verify, settle, read-status or admin
+	Scopes []string `json:"scopes"`
+	// CAIP-2 identifiers or families like "eip155:*" of the networks the key may pay on, all if empty
+	Networks []string `json:"networks,omitempty"`
+}
+
+func (s Spec) validate() error {
+	if s.ID != "" && !idPattern.MatchString(s.ID) {
+		return fmt.Errorf("%w: id must be lowercase letters, digits and dashes", ErrInvalidSpec)
+	}
+	if len(s.Scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidSpec)
+	}
+	for _, scope := range s.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("%w: unknown scope %q, one of %s", ErrInvalidSpec, scope, strings.Join(Scopes, ", "))
+		}
+	}
+	for _, network := range s.Networks {
+		if !caip.ValidPattern(network) {
+			return fmt.Errorf("%w: %q is not a CAIP-2 network or family", ErrInvalidSpec, network)
+		}
+	}
+	return nil
+}
+
+// Manager creates, authenticates and revokes the keys of the store.
+type Manager struct {
+	records store.Store
+	now     func() time.Time
+
+	mu sync.Mutex
+	// keys recently read from the store, by ID
+	cache map[string]cachedKey
+}
+
+type cachedKey struct {
+	key    *store.APIKey
+	loaded time.Time
+}
+
+// New creates a manager keeping the keys in records.
+func New(records store.Store) *Manager {
+	return &Manager{
+		records: records,
+		now:     time.Now,
+		cache:   make(map[string]cachedKey),
+	}
+}
+
+// Create creates a key and returns its record and the key, which is not
+// stored and can't be retrieved again.
+func (m *Manager) Create(ctx context.Context, spec Spec) (*store.APIKey, string, error) {
+	if err := spec.validate(); err != nil {
+		return nil, "", err
+	}
+	id := spec.ID
+	if id == "" {
+		id = hex.EncodeToString(random(8))
+	} else if _, err := m.records.GetAPIKey(ctx, id); err == nil {
+		return nil, "", ErrDuplicateID
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, "", err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(random(secretSize))
+	key := &store.APIKey{
+		ID:         id,
+		Name:       spec.Name,
+		SecretHash: hash(secret),
+		Scopes:     slices.Compact(slices.Sorted(slices.Values(spec.Scopes))),
+		Networks:   spec.Networks,
+		CreatedAt:  m.now(),
+	}
+	if err := m.records.SaveAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, keyPrefix + id + "_" + secret, nil
+}
+
+// Update replaces the name, scopes and networks of the key, store.ErrNotFound
+// if it doesn't exist. The ID of the spec is ignored and the secret kept.
+func (m *Manager) Update(ctx context.Context, id string, spec Spec) (*store.APIKey, error) {
+	spec.ID = ""
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	key, err := m.records.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	key.Name = spec.Name
+	key.Scopes = slices.Compact(slices.Sorted(slices.Values(spec.Scopes)))
+	key.Networks = spec.Networks
+	if err := m.records.SaveAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+	m.forget(id)
+	return key, nil
+}
+
+// Rotate replaces the secret of the key and returns the new key, which is
+// returned once like on creation. The ID, scopes and networks are kept. The
+// old key is rejected at once, by other instances sharing the store within
+// 30 seconds. Revoked keys can't be rotated.
+func (m *Manager) Rotate(ctx context.Context, id string) (*store.APIKey, string, error) {
+	key, err := m.records.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if key.RevokedAt != nil {
+		return nil, "", ErrRevoked
+	}
+	secret := base64.RawURLEncoding.EncodeToString(random(secretSize))
+	key.SecretHash = hash(secret)
+	if err := m.records.SaveAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	m.forget(id)
+	return key, keyPrefix + id + "_" + secret, nil
+}
+
+// Revoke revokes the key, store.ErrNotFound if it doesn't exist. Other
+// instances sharing the store stop accepting it within 30 seconds.
+func (m *Manager) Revoke(ctx context.Context, id string) (*store.APIKey, error) {
+	key, err := m.records.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := m.now()
+		key.RevokedAt = &now
+		if err := m.records.SaveAPIKey(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	m.forget(id)
+	return key, nil
+}
+
+// Get returns the key with the ID, store.ErrNotFound if it doesn't exist.
+func (m *Manager) Get(ctx context.Context, id string) (*store.APIKey, error) {
+	return m.records.GetAPIKey(ctx, id)
+}
+
+// List returns all keys, including revoked ones, oldest first.
+func (m *Manager) List(ctx context.Context) ([]*store.APIKey, error) {
+	return m.records.ListAPIKeys(ctx)
+}
+
+// Authenticate returns the valid key the caller presented, ErrInvalidKey if
+// it is malformed, unknown, revoked or its secret doesn't match.
+func (m *Manager) Authenticate(ctx context.Context, presented string) (*store.APIKey, error) {
+	rest, ok := strings.CutPrefix(presented, keyPrefix)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	// IDs contain no underscores, secrets may
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrInvalidKey
+	}
+	key, err := m.load(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidKey
+	} else if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(key.SecretHash)) != 1 || key.RevokedAt != nil {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// load returns the key with the ID from the cache or the store.
+func (m *Manager) load(ctx context.Context, id string) (*store.APIKey, error) {
+	now := m.now()
+	m.mu.Lock()
+	cached, ok := m.cache[id]
+	m.mu.Unlock()
+	if ok && now.Sub(cached.loaded) < cacheTTL {
+		return cached.key, nil
+	}
+
+	key, err := m.records.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.cache) > 10_000 {
+		// IDs are only cached once found, this only bounds a store with many keys
+		clear(m.cache)
+	}
+	m.cache[id] = cachedKey{key: key, loaded: now}
+	return key, nil
+}
+
+func (m *Manager) forget(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cache, id)
+}
+
+// HasScope reports whether the key grants the scope. The settle scope
+// includes the verify scope.
+func HasScope(key *store.APIKey, scope string) bool {
+	return slices.Contains(key.Scopes, scope) || (scope == ScopeVerify && slices.Contains(key.Scopes, ScopeSettle))
+}
+
+// PermitsNetwork reports whether the key may pay on the network.
+func PermitsNetwork(key *store.APIKey, network string) bool {
+	return len(key.Networks) == 0 || caip.MatchAny(key.Networks, network)
+}
+
+// contextKey is the context key of the key a request was authenticated with
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the key the request was authenticated with.
+func NewContext(ctx context.Context, key *store.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the key of the context, nil if there is none.
+func FromContext(ctx context.Context) *store.APIKey {
+	key, _ := ctx.Value(contextKey{}).(*store.APIKey)
+	return key
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func random(n int) []byte {
+	b := make([]byte, n)
+	// crypto/rand.Read never fails
+	rand.Read(b)
+	return b
+}
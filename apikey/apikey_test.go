@@ -0,0 +1,124 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestManager(t *testing.T) {
+	records := store.NewMemory()
+	now := time.Unix(1_700_000_000, 0)
+	keys := New(records)
+	keys.now = func() time.Time { return now }
+
+	record, key, err := keys.Create(t.Context(), Spec{ID: "shop", Name: "Shop", Scopes: []string{ScopeSettle, ScopeVerify, ScopeSettle}, Networks: []string{"eip155:*"}})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key, "x402_shop_"))
+	require.Equal(t, []string{ScopeSettle, ScopeVerify}, record.Scopes)
+	require.NotContains(t, record.SecretHash, strings.TrimPrefix(key, "x402_shop_"), "only the hash is stored")
+
+	t.Run("invalid specs are rejected", func(t *testing.T) {
+		for _, spec := range []Spec{
+			{Scopes: nil},
+			{Scopes: []string{"everything"}},
+			{ID: "Shop_1", Scopes: []string{ScopeVerify}},
+			{Scopes: []string{ScopeVerify}, Networks: []string{"base"}},
+		} {
+			_, _, err := keys.Create(t.Context(), spec)
+			require.ErrorIs(t, err, ErrInvalidSpec, "%+v", spec)
+		}
+		_, _, err := keys.Create(t.Context(), Spec{ID: "shop", Scopes: []string{ScopeVerify}})
+		require.ErrorIs(t, err, ErrDuplicateID)
+	})
+
+	t.Run("keys authenticate with their secret", func(t *testing.T) {
+		got, err := keys.Authenticate(t.Context(), key)
+		require.NoError(t, err)
+		require.Equal(t, "shop", got.ID)
+
+		for _, presented := range []string{"", "shop", key + "x", "x402_other_" + strings.TrimPrefix(key, "x402_shop_")} {
+			_, err := keys.Authenticate(t.Context(), presented)
+			require.ErrorIs(t, err, ErrInvalidKey, presented)
+		}
+	})
+
+	t.Run("scopes and networks", func(t *testing.T) {
+		readOnly, _, err := keys.Create(t.Context(), Spec{Scopes: []string{ScopeReadStatus}})
+		require.NoError(t, err)
+		require.Len(t, readOnly.ID, 16)
+
+		require.True(t, HasScope(record, ScopeVerify))
+		require.False(t, HasScope(record, ScopeAdmin))
+		require.False(t, HasScope(readOnly, ScopeVerify))
+		require.True(t, PermitsNetwork(record, "eip155:8453"))
+		require.False(t, PermitsNetwork(record, "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp"))
+		require.True(t, PermitsNetwork(readOnly, "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp"))
+	})
+
+	t.Run("updates apply at once", func(t *testing.T) {
+		updated, err := keys.Update(t.Context(), "shop", Spec{Name: "Shop", Scopes: []string{ScopeVerify}})
+		require.NoError(t, err)
+		require.Empty(t, updated.Networks)
+
+		got, err := keys.Authenticate(t.Context(), key)
+		require.NoError(t, err)
+		require.False(t, HasScope(got, ScopeSettle))
+
+		_, err = keys.Update(t.Context(), "missing", Spec{Scopes: []string{ScopeVerify}})
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("rotated keys authenticate with the new secret only", func(t *testing.T) {
+		rotated, newKey, err := keys.Rotate(t.Context(), "shop")
+		require.NoError(t, err)
+		require.Equal(t, "shop", rotated.ID)
+		require.True(t, strings.HasPrefix(newKey, "x402_shop_"))
+
+		_, err = keys.Authenticate(t.Context(), key)
+		require.ErrorIs(t, err, ErrInvalidKey)
+		got, err := keys.Authenticate(t.Context(), newKey)
+		require.NoError(t, err)
+		require.Equal(t, []string{ScopeVerify}, got.Scopes, "the settings are kept")
+		key = newKey
+
+		_, _, err = keys.Rotate(t.Context(), "missing")
+		require.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("revoked keys are rejected", func(t *testing.T) {
+		revoked, err := keys.Revoke(t.Context(), "shop")
+		require.NoError(t, err)
+		require.Equal(t, now, *revoked.RevokedAt)
+		_, err = keys.Authenticate(t.Context(), key)
+		require.ErrorIs(t, err, ErrInvalidKey)
+
+		listed, err := keys.List(t.Context())
+		require.NoError(t, err)
+		require.Len(t, listed, 2)
+
+		_, _, err = keys.Rotate(t.Context(), "shop")
+		require.ErrorIs(t, err, ErrRevoked)
+	})
+
+	t.Run("revocations by other instances apply once the cache expires", func(t *testing.T) {
+		_, key, err := keys.Create(t.Context(), Spec{ID: "outlet", Scopes: []string{ScopeVerify}})
+		require.NoError(t, err)
+		_, err = keys.Authenticate(t.Context(), key)
+		require.NoError(t, err)
+
+		other := New(records)
+		_, err = other.Revoke(t.Context(), "outlet")
+		require.NoError(t, err)
+		_, err = keys.Authenticate(t.Context(), key)
+		require.NoError(t, err, "cached")
+
+		now = now.Add(cacheTTL)
+		_, err = keys.Authenticate(t.Context(), key)
+		require.ErrorIs(t, err, ErrInvalidKey)
+	})
+}
@@ -0,0 +1,293 @@
+// Package assetlist keeps the accepted assets of networks in sync with a
+// central list, so a fleet of facilitators can be updated without pushing
+// configuration to each. Networks with an asset registry contract accept the
+// tokens it lists, and a signed JSON manifest lists the assets of any number
+// of networks. Both are read at startup and on an interval, and the assets
+// they list replace the configured ones. Networks listed by neither keep
+// their configuration.
+package assetlist
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+const (
+	defaultInterval = 5 * time.Minute
+
+	// fetchTimeout bounds fetching the manifest and reading a single registry
+	fetchTimeout = 30 * time.Second
+	// maxManifestSize bounds the manifests read
+	maxManifestSize = 1 << 20
+)
+
+// Config configures the manifest and how often asset lists are synced. Asset
+// registry contracts are set per network, see facilitator.NetworkConfig.
+type Config struct {
+	// URL of the signed manifest, no manifest is fetched if empty
+	ManifestURL string `mapstructure:"manifestUrl"`
+	// Base64 ed25519 public key manifests must be signed with
+	PublicKey string `mapstructure:"publicKey"`
+	// How often the asset lists are synced, 0 means every 5 minutes
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// Manifest lists the assets of networks.
+type Manifest struct {
+	// Sequence of the manifest, increased with every change. A manifest with
+	// a lower sequence than the one applied is rejected, so an old manifest
+	// can't be served again to bring back assets removed since
+	Sequence uint64 `json:"sequence"`
+	// Assets by CAIP-2 identifier, or family like "eip155:*" for the networks
+	// without an entry of their own
+	Networks map[string][]Asset `json:"networks"`
+}
+
+// Asset is an asset of a manifest, see facilitator.AssetConfig. Tokens the
+// network presets know only need their symbol or address.
+type Asset struct {
+	Symbol         string `json:"symbol"`
+	Address        string `json:"address,omitempty"`
+	Decimals       int    `json:"decimals,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Version        string `json:"version,omitempty"`
+	TransferMethod string `json:"transferMethod,omitempty"`
+	// see facilitator.AssetConfig
+	CheckTransferFee        bool `json:"checkTransferFee,omitempty"`
+	TransferFeeToleranceBps int  `json:"transferFeeToleranceBps,omitempty"`
+}
+
+// SignedManifest is the document served at the manifest URL.
+type SignedManifest struct {
+	// Manifest as JSON, signed as is
+	Manifest json.RawMessage `json:"manifest"`
+	// Base64 ed25519 signature of the bytes of Manifest
+	Signature string `json:"signature"`
+}
+
+// Sign signs the manifest with the private key.
+func Sign(manifest *Manifest, key ed25519.PrivateKey) (*SignedManifest, error) {
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedManifest{
+		Manifest:  raw,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw)),
+	}, nil
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("publicKey must be a base64 ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Open checks the signature of the manifest and decodes it.
+func (m *SignedManifest) Open(key ed25519.PublicKey) (*Manifest, error) {
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(key, m.Manifest, signature) {
+		return nil, errors.New("invalid manifest signature")
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(m.Manifest, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// assets returns the assets the manifest lists for the network, ok is false
+// if it lists none.
+func (m *Manifest) assets(network string) ([]facilitator.AssetConfig, bool) {
+	assets, ok := m.Networks[network]
+	if !ok {
+		for pattern, listed := range m.Networks {
+			if caip.IsFamily(pattern) && caip.Match(pattern, network) {
+				assets, ok = listed, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	configs := make([]facilitator.AssetConfig, len(assets))
+	for i, asset := range assets {
+		configs[i] = facilitator.AssetConfig(asset)
+	}
+	return configs, true
+}
+
+// Syncer replaces the accepted assets of the networks of a registry with the
+// ones their asset registry contracts and the manifest list.
+type Syncer struct {
+	registry  *facilitator.Registry
+	url       string
+	publicKey ed25519.PublicKey
+	interval  time.Duration
+	client    *http.Client
+
+	mu       sync.Mutex
+	manifest *Manifest // last applied, nil until one is
+}
+
+func New(registry *facilitator.Registry, config Config) (*Syncer, error) {
+	s := &Syncer{
+		registry: registry,
+		url:      config.ManifestURL,
+		interval: cmp.Or(config.Interval, defaultInterval),
+		client:   outbound.Client(fetchTimeout),
+	}
+	if s.url != "" {
+		key, err := ParsePublicKey(config.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		s.publicKey = key
+	}
+	return s, nil
+}
+
+// Enabled reports whether there is a manifest or a network with an asset registry to sync.
+func (s *Syncer) Enabled() bool {
+	if s.url != "" {
+		return true
+	}
+	for _, config := range s.registry.Networks() {
+		if config.AssetRegistry != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Run syncs the asset lists every interval until the context is cancelled.
+// The first sync is up to the caller, at startup.
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Sync(ctx); err != nil {
+			logging.For(logging.Assets).Warn().Err(err).Msg("Failed to sync asset lists")
+		}
+	}
+}
+
+// Sync fetches the manifest and reads the asset registries once and applies
+// the asset lists. A network whose list can't be read or applied keeps its
+// assets, the errors of all networks are returned together.
+func (s *Syncer) Sync(ctx context.Context) error {
+	var errs []error
+	manifest, err := s.fetch(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, config := range s.registry.Networks() {
+		f, _, _ := s.registry.Lookup(config.Network)
+		updater, ok := f.(facilitator.AssetUpdater)
+		if !ok {
+			continue
+		}
+		assets, source, err := s.list(ctx, config, updater, manifest)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if source == "" {
+			continue
+		}
+		if err := updater.SetAssets(assets); err != nil {
+			errs = append(errs, fmt.Errorf("network %s: assets of %s: %w", config.Network, source, err))
+			continue
+		}
+		logging.For(logging.Assets).Debug().Str("network", config.Network).Str("source", source).Int("assets", len(assets)).Msg("Synced asset list")
+	}
+	return errors.Join(errs...)
+}
+
+// list returns the assets of the network and where they are listed: its
+// asset registry, which takes precedence, or the manifest. source is empty if
+// neither lists the network.
+func (s *Syncer) list(ctx context.Context, config facilitator.NetworkConfig, updater facilitator.AssetUpdater, manifest *Manifest) (assets []facilitator.AssetConfig, source string, err error) {
+	if config.AssetRegistry != "" {
+		ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		defer cancel()
+		assets, err := updater.RegistryAssets(ctx, config.AssetRegistry)
+		return assets, config.AssetRegistry, err
+	}
+	if manifest == nil {
+		return nil, "", nil
+	}
+	if assets, ok := manifest.assets(config.Network); ok {
+		return assets, "manifest", nil
+	}
+	return nil, "", nil
+}
+
+// fetch returns the manifest to apply: the one served if its signature is
+// valid and it isn't older than the one applied, else the one applied.
+func (s *Syncer) fetch(ctx context.Context) (*Manifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.url == "" {
+		return nil, nil
+	}
+	manifest, err := s.download(ctx)
+	if err != nil {
+		return s.manifest, fmt.Errorf("manifest %s: %w", s.url, err)
+	}
+	if s.manifest != nil && manifest.Sequence < s.manifest.Sequence {
+		return s.manifest, fmt.Errorf("manifest %s: sequence %d is older than the applied %d", s.url, manifest.Sequence, s.manifest.Sequence)
+	}
+	if s.manifest == nil || manifest.Sequence > s.manifest.Sequence {
+		logging.For(logging.Assets).Info().Uint64("sequence", manifest.Sequence).Msg("Applying asset manifest")
+	}
+	s.manifest = manifest
+	return manifest, nil
+}
+
+func (s *Syncer) download(ctx context.Context) (*Manifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	var signed SignedManifest
+	if err := json.Unmarshal(body, &signed); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return signed.Open(s.publicKey)
+}
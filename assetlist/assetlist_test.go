@@ -0,0 +1,101 @@
+package assetlist
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/mock"
+)
+
+const (
+	testSigner = "0x00000000000000000000000000000000000000fa"
+	registry   = "0x00000000000000000000000000000000000000a5"
+	token      = "0x00000000000000000000000000000000000000c0"
+)
+
+func TestSync(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	var served atomic.Pointer[SignedManifest]
+	publish := func(manifest *Manifest, key ed25519.PrivateKey) {
+		signed, err := Sign(manifest, key)
+		require.NoError(t, err)
+		served.Store(signed)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(served.Load())
+	}))
+	defer srv.Close()
+
+	networks := facilitator.NewRegistry()
+	register := func(network string, chainID int64, assetRegistry string) *mock.EVMSigner {
+		chain := mock.NewEVMSigner(chainID, testSigner)
+		config := facilitator.NetworkConfig{Network: network, AssetRegistry: assetRegistry}
+		require.NoError(t, config.Normalize())
+		f, err := facilitator.NewEVMFacilitatorWithSigner(config, chain)
+		require.NoError(t, err)
+		require.NoError(t, networks.Register(config, f))
+		return chain
+	}
+	register("eip155:84532", 84532, "")
+	chain := register("eip155:8453", 8453, registry)
+	// decoded like the tuples of a real call
+	chain.SetResult(registry, "assets", []struct {
+		Token          common.Address
+		Symbol         string
+		Decimals       uint8
+		Name           string
+		Version        string
+		TransferMethod string
+	}{{Token: common.HexToAddress(token), Symbol: "ACME", Decimals: 18, Name: "Acme", Version: "1"}})
+
+	syncer, err := New(networks, Config{ManifestURL: srv.URL, PublicKey: base64.StdEncoding.EncodeToString(public)})
+	require.NoError(t, err)
+	require.True(t, syncer.Enabled())
+	accepts := func(network, asset string) bool {
+		_, _, ok := networks.ResolveAsset(network, asset)
+		return ok
+	}
+
+	publish(&Manifest{Sequence: 2, Networks: map[string][]Asset{
+		"eip155:*": {{Symbol: "EURC", Address: token, Decimals: 6, Name: "EURC", Version: "2"}},
+	}}, private)
+	require.True(t, accepts("eip155:84532", "USDC"), "the presets until the first sync")
+	require.NoError(t, syncer.Sync(t.Context()))
+	require.True(t, accepts("eip155:84532", "EURC"))
+	require.False(t, accepts("eip155:84532", "USDC"), "the manifest replaces the assets")
+	require.True(t, accepts("eip155:8453", "ACME"), "the asset registry takes precedence over the manifest")
+	require.False(t, accepts("eip155:8453", "EURC"))
+
+	t.Run("rejected manifests keep the applied one", func(t *testing.T) {
+		_, other, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		publish(&Manifest{Sequence: 3, Networks: map[string][]Asset{"eip155:84532": {{Symbol: "USDC"}}}}, other)
+		require.ErrorContains(t, syncer.Sync(t.Context()), "invalid manifest signature")
+
+		publish(&Manifest{Sequence: 1, Networks: map[string][]Asset{"eip155:84532": {{Symbol: "USDC"}}}}, private)
+		require.ErrorContains(t, syncer.Sync(t.Context()), "older than the applied 2")
+		require.True(t, accepts("eip155:84532", "EURC"))
+		require.False(t, accepts("eip155:84532", "USDC"))
+	})
+
+	t.Run("invalid lists keep the assets", func(t *testing.T) {
+		publish(&Manifest{Sequence: 4, Networks: map[string][]Asset{"eip155:84532": {{Symbol: "FOO", Address: token}}}}, private)
+		require.ErrorContains(t, syncer.Sync(t.Context()), "name and version of the EIP-712 domain are required")
+		require.True(t, accepts("eip155:84532", "EURC"))
+
+		publish(&Manifest{Sequence: 5, Networks: map[string][]Asset{"eip155:84532": {{Symbol: "USDC"}}}}, private)
+		require.NoError(t, syncer.Sync(t.Context()))
+		require.True(t, accepts("eip155:84532", "USDC"), "symbols of presets are completed")
+	})
+}
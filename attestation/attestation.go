@@ -0,0 +1,125 @@
+// Package attestation signs the responses of the verify and settle endpoints
+// with a key of the facilitator, so resource servers reaching it through
+// networks they don't trust can tell its answers from forged ones. The
+// signature header reads
+//
+//	X-Facilitator-Signature: t=<timestamp>,v1=<signature>
+//
+// where the timestamp is in unix seconds and the signature is the hex encoded
+// EIP-191 personal signature of
+//
+//	x402 facilitator response
+//	<timestamp>
+//	<path>
+//	<keccak256 of the request body>
+//	<keccak256 of the response body>
+//
+// with the path of the endpoint, e.g. "/settle", and the hashes hex encoded
+// with 0x prefix. Binding the request keeps a response from being replayed
+// as the answer to another request. Resource servers check it with Verify
+// against the address the facilitator operator published.
+package attestation
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// HeaderSignature carries the signature of a response
+	HeaderSignature = "X-Facilitator-Signature"
+
+	// DefaultTolerance is how old a signature may be, bounding replays of captured responses
+	DefaultTolerance = 5 * time.Minute
+
+	signatureVersion = "v1"
+)
+
+var (
+	// ErrNoSignature is returned for responses without a signature header
+	ErrNoSignature = errors.New("attestation: no signature")
+	// ErrInvalidSignature is returned for malformed signatures and those of other signers
+	ErrInvalidSignature = errors.New("attestation: invalid signature")
+	// ErrExpiredSignature is returned for signatures made outside the tolerance
+	ErrExpiredSignature = errors.New("attestation: signature timestamp outside the tolerance")
+)
+
+// Key signs responses, e.g. a facilitator.PrivateKeySigner.
+type Key interface {
+	Address() common.Address
+	SignHash(digest []byte) ([]byte, error)
+}
+
+// Hash returns the digest the signature of the response to the request at the
+// path, made at the timestamp, is over.
+func Hash(timestamp int64, path string, request, response []byte) []byte {
+	message := strings.Join([]string{
+		"x402 facilitator response",
+		strconv.FormatInt(timestamp, 10),
+		path,
+		crypto.Keccak256Hash(request).Hex(),
+		crypto.Keccak256Hash(response).Hex(),
+	}, "\n")
+	return accounts.TextHash([]byte(message))
+}
+
+// Sign returns the signature header value of the response to the request at
+// the path, signed by the key at t.
+func Sign(key Key, path string, request, response []byte, t time.Time) (string, error) {
+	sig, err := key.SignHash(Hash(t.Unix(), path, request, response))
+	if err != nil {
+		return "", err
+	}
+	return "t=" + strconv.FormatInt(t.Unix(), 10) + "," + signatureVersion + "=" + hexutil.Encode(sig), nil
+}
+
+// Verify checks that the signature header of the response to the request at
+// the path was made by the signer within DefaultTolerance of now.
+func Verify(signer common.Address, path string, request, response []byte, header string) error {
+	return verify(signer, path, request, response, header, time.Now(), DefaultTolerance)
+}
+
+func verify(signer common.Address, path string, request, response []byte, header string, now time.Time, tolerance time.Duration) error {
+	if header == "" {
+		return ErrNoSignature
+	}
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case signatureVersion:
+			signature = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return ErrInvalidSignature
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.SigToPub(Hash(unix, path, request, response), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != signer {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)).Abs(); skew > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package attestation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+)
+
+func TestVerify(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := facilitator.NewPrivateKeySigner(crypto.FromECDSA(key))
+	require.NoError(t, err)
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	request := []byte(`{"x402Version":1}`)
+	response := []byte(`{"isValid":true}`)
+	now := time.Unix(1_700_000_000, 0)
+	header, err := Sign(signer, "/verify", request, response, now)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(header, "t=1700000000,v1=0x"))
+	require.NoError(t, verify(signer.Address(), "/verify", request, response, header, now.Add(time.Minute), DefaultTolerance))
+
+	// the signature is a plain personal signature of the message
+	sig, err := hexutil.Decode(strings.TrimPrefix(header, "t=1700000000,v1="))
+	require.NoError(t, err)
+	sig[64] -= 27
+	message := "x402 facilitator response\n1700000000\n/verify\n" + crypto.Keccak256Hash(request).Hex() + "\n" + crypto.Keccak256Hash(response).Hex()
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	require.NoError(t, err)
+	require.Equal(t, signer.Address(), crypto.PubkeyToAddress(*pub))
+
+	for name, tc := range map[string]struct {
+		path              string
+		request, response []byte
+		header            string
+		now               time.Time
+		err               error
+	}{
+		"missing":        {"/verify", request, response, "", now, ErrNoSignature},
+		"other path":     {"/settle", request, response, header, now, ErrInvalidSignature},
+		"other request":  {"/verify", []byte(`{"x402Version":2}`), response, header, now, ErrInvalidSignature},
+		"other response": {"/verify", request, []byte(`{"isValid":false}`), header, now, ErrInvalidSignature},
+		"no timestamp":   {"/verify", request, response, strings.Split(header, ",")[1], now, ErrInvalidSignature},
+		"malformed":      {"/verify", request, response, "t=1700000000,v1=0x01", now, ErrInvalidSignature},
+		"replayed late":  {"/verify", request, response, header, now.Add(DefaultTolerance + time.Second), ErrExpiredSignature},
+	} {
+		require.ErrorIs(t, verify(signer.Address(), tc.path, tc.request, tc.response, tc.header, tc.now, DefaultTolerance), tc.err, name)
+	}
+	require.ErrorIs(t, verify(crypto.PubkeyToAddress(other.PublicKey), "/verify", request, response, header, now, DefaultTolerance), ErrInvalidSignature)
+}
@@ -0,0 +1,123 @@
+package balance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// webhookTimeout bounds the delivery of a single alert
+const webhookTimeout = 10 * time.Second
+
+// lowBalanceEvent is the event name of low balance alerts
+const lowBalanceEvent = "signer.low_balance"
+
+// WebhookConfig configures the endpoint low balance alerts are posted to.
+type WebhookConfig struct {
+	// Endpoint receiving the alerts, alerts are disabled if empty
+	URL string `mapstructure:"url"`
+	// Headers sent with every alert, e.g. for authentication
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// Webhook posts low balance alerts as JSON to an HTTP endpoint.
+type Webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhook(config WebhookConfig) *Webhook {
+	return &Webhook{
+		url:     config.URL,
+		headers: config.Headers,
+		client:  outbound.Client(webhookTimeout),
+	}
+}
+
+// webhookAlert is the body of a low balance alert, amounts are in whole units
+type webhookAlert struct {
+	Event     string    `json:"event"`
+	Network   string    `json:"network"`
+	Signer    string    `json:"signer"`
+	Currency  string    `json:"currency"`
+	Balance   string    `json:"balance"`
+	Minimum   string    `json:"minimum"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (w *Webhook) LowBalance(ctx context.Context, low LowBalance) error {
+	body, err := json.Marshal(webhookAlert{
+		Event:     lowBalanceEvent,
+		Network:   low.Network,
+		Signer:    low.Signer,
+		Currency:  low.Currency,
+		Balance:   types.FormatUnits(low.Amount, low.Decimals),
+		Minimum:   types.FormatUnits(low.Minimum, low.Decimals),
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver low balance alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("low balance alert rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// TopUp transfers the top-up amount of the network from a treasury account to
+// low signers. Networks without a top-up amount are left alone.
+type TopUp struct {
+	registry *facilitator.Registry
+	treasury facilitator.TransactionSigner
+}
+
+func NewTopUp(registry *facilitator.Registry, treasury facilitator.TransactionSigner) *TopUp {
+	return &TopUp{registry: registry, treasury: treasury}
+}
+
+func (t *TopUp) LowBalance(ctx context.Context, low LowBalance) error {
+	f, config, ok := t.registry.Lookup(low.Network)
+	if !ok || config.Balance.TopUp <= 0 {
+		return nil
+	}
+	funder, ok := f.(facilitator.GasFunder)
+	if !ok {
+		return fmt.Errorf("network %s: signers can't be topped up", low.Network)
+	}
+
+	amount := toAtomic(config.Balance.TopUp, low.Decimals)
+	txHash, err := funder.FundGas(ctx, t.treasury, low.Signer, amount)
+	if err != nil {
+		return fmt.Errorf("failed to top up signer: %w", err)
+	}
+	logging.For(logging.Balance).Info().
+		Str("network", low.Network).
+		Str("signer", low.Signer).
+		Str("amount", types.FormatUnits(amount, low.Decimals)).
+		Str("tx_hash", txHash).
+		Msg("Submitted signer top-up from treasury")
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package balance watches the gas balance of the signers and runs hooks, such
+// as alerts and top-ups from a treasury account, when it runs low.
+package balance
+
+import (
+	"cmp"
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultRepeat   = time.Hour
+
+	// checkTimeout bounds reading the balances of a single network
+	checkTimeout = 30 * time.Second
+)
+
+// Config configures the balance monitor. The minimum balance and the top-up
+// amount are set per network.
+type Config struct {
+	// How often the balances are checked, 0 means every minute
+	Interval time.Duration `mapstructure:"interval"`
+	// How long a balance has to stay low before the hooks fire again, 0 means one hour
+	Repeat time.Duration `mapstructure:"repeat"`
+	// Name of the signer whose account tops up low signers, no top-ups if empty
+	Treasury string `mapstructure:"treasury"`
+	// Receives an alert for every low balance
+	Webhook WebhookConfig `mapstructure:"webhook"`
+}
+
+// LowBalance describes a signer whose balance dropped below the minimum of its network.
+type LowBalance struct {
+	Network string
+	facilitator.GasBalance
+	// Minimum balance of the network in atomic units
+	Minimum *big.Int
+}
+
+// Hook is run by the monitor when the balance of a signer is low.
+type Hook interface {
+	LowBalance(ctx context.Context, low LowBalance) error
+}
+
+// HookFunc adapts a function to the Hook interface.
+type HookFunc func(ctx context.Context, low LowBalance) error
+
+func (f HookFunc) LowBalance(ctx context.Context, low LowBalance) error {
+	return f(ctx, low)
+}
+
+// Monitor periodically reads the gas balances of the signers of all networks
+// with a minimum balance. When a balance drops below the minimum the hooks
+// run, and they run again every Repeat interval for as long as it stays low.
+type Monitor struct {
+	registry *facilitator.Registry
+	interval time.Duration
+	repeat   time.Duration
+	hooks    []Hook
+
+	mu    sync.Mutex
+	fired map[string]time.Time // by network and signer, while the balance is low
+}
+
+func NewMonitor(registry *facilitator.Registry, config Config, hooks ...Hook) *Monitor {
+	return &Monitor{
+		registry: registry,
+		interval: cmp.Or(config.Interval, defaultInterval),
+		repeat:   cmp.Or(config.Repeat, defaultRepeat),
+		hooks:    hooks,
+		fired:    make(map[string]time.Time),
+	}
+}
+
+// Run checks the balances until the context is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check reads the balances of all monitored networks once and runs the hooks
+// for those that are low.
+func (m *Monitor) Check(ctx context.Context) {
+	for _, config := range m.registry.Networks() {
+		if config.Balance.Minimum <= 0 {
+			continue
+		}
+		f, _, ok := m.registry.Lookup(config.Network)
+		if !ok {
+			continue
+		}
+		reader, ok := f.(facilitator.GasBalanceReader)
+		if !ok {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		balances, err := reader.GasBalances(checkCtx)
+		cancel()
+		if err != nil {
+			logging.For(logging.Balance).Warn().Err(err).Str("network", config.Network).Msg("Failed to check signer balances")
+			continue
+		}
+		for _, balance := range balances {
+			m.check(ctx, LowBalance{
+				Network:    config.Network,
+				GasBalance: balance,
+				Minimum:    toAtomic(config.Balance.Minimum, balance.Decimals),
+			})
+		}
+	}
+}
+
+// check runs the hooks if the balance is low and they didn't run for it recently.
+func (m *Monitor) check(ctx context.Context, low LowBalance) {
+	balance, _ := new(big.Float).Quo(new(big.Float).SetInt(low.Amount), new(big.Float).SetInt(pow10(low.Decimals))).Float64()
+	metrics.SignerGasBalance.WithLabelValues(low.Network, low.Signer, low.Currency).Set(balance)
+
+	key := low.Network + ":" + strings.ToLower(low.Signer)
+	m.mu.Lock()
+	if low.Amount.Cmp(low.Minimum) >= 0 {
+		delete(m.fired, key)
+		m.mu.Unlock()
+		return
+	}
+	if fired, ok := m.fired[key]; ok && time.Since(fired) < m.repeat {
+		m.mu.Unlock()
+		return
+	}
+	m.fired[key] = time.Now()
+	m.mu.Unlock()
+
+	logger := logging.For(logging.Balance).With().Str("network", low.Network).Str("signer", low.Signer).Logger()
+	logger.Warn().
+		Str("balance", types.FormatUnits(low.Amount, low.Decimals)).
+		Str("minimum", types.FormatUnits(low.Minimum, low.Decimals)).
+		Str("currency", low.Currency).
+		Msg("Signer balance is low")
+	for _, hook := range m.hooks {
+		if err := hook.LowBalance(ctx, low); err != nil {
+			logger.Error().Err(err).Msg("Low balance hook failed")
+		}
+	}
+}
+
+// toAtomic converts an amount in whole units into atomic units. The amount is
+// taken as the shortest decimal representing it, so 0.05 is exactly 0.05.
+func toAtomic(amount float64, decimals int) *big.Int {
+	rat, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	rat.Mul(rat, new(big.Rat).SetInt(pow10(decimals)))
+	return new(big.Int).Quo(rat.Num(), rat.Denom())
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,98 @@
+package balance
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/mock"
+)
+
+const (
+	testNetwork = "eip155:84532"
+	testSigner  = "0x00000000000000000000000000000000000000fa"
+)
+
+// fundedFacilitator credits top-ups directly to the mock chain.
+type fundedFacilitator struct {
+	*facilitator.EVMFacilitator
+	chain *mock.EVMSigner
+}
+
+func (f *fundedFacilitator) FundGas(ctx context.Context, from facilitator.TransactionSigner, signer string, amount *big.Int) (string, error) {
+	f.chain.SetBalance("", signer, new(big.Int).Add(f.chain.Balance("", signer), amount))
+	return "0x01", nil
+}
+
+func ether(milli int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(milli), big.NewInt(1e15))
+}
+
+func TestMonitor(t *testing.T) {
+	chain := mock.NewEVMSigner(84532, testSigner)
+	chain.SetBalance("", testSigner, ether(1))
+
+	config := facilitator.NetworkConfig{
+		Network: testNetwork,
+		Balance: facilitator.BalanceConfig{Minimum: 0.01, TopUp: 0.05},
+	}
+	require.NoError(t, config.Normalize())
+	evmFacilitator, err := facilitator.NewEVMFacilitatorWithSigner(config, chain)
+	require.NoError(t, err)
+	registry := facilitator.NewRegistry()
+	require.NoError(t, registry.Register(config, &fundedFacilitator{EVMFacilitator: evmFacilitator, chain: chain}))
+
+	alerts := make(chan webhookAlert, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secret", r.Header.Get("X-Token"))
+		var alert webhookAlert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		alerts <- alert
+	}))
+	defer srv.Close()
+
+	var lows []LowBalance
+	record := HookFunc(func(ctx context.Context, low LowBalance) error {
+		lows = append(lows, low)
+		return nil
+	})
+	treasury, err := facilitator.NewPrivateKeySigner(big.NewInt(1).FillBytes(make([]byte, 32)))
+	require.NoError(t, err)
+
+	monitor := NewMonitor(registry, Config{}, record,
+		NewWebhook(WebhookConfig{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}),
+		NewTopUp(registry, treasury),
+	)
+
+	monitor.Check(t.Context())
+	require.Len(t, lows, 1)
+	require.Equal(t, testNetwork, lows[0].Network)
+	require.Equal(t, ether(1), lows[0].Amount)
+	require.Equal(t, ether(10), lows[0].Minimum)
+	require.Equal(t, "ETH", lows[0].Currency)
+
+	alert := <-alerts
+	require.Equal(t, lowBalanceEvent, alert.Event)
+	require.Equal(t, "0.001", alert.Balance)
+	require.Equal(t, "0.01", alert.Minimum)
+	require.Equal(t, ether(51), chain.Balance("", testSigner), "the treasury tops the signer up")
+
+	// the balance recovered, the next drop fires again
+	monitor.Check(t.Context())
+	require.Len(t, lows, 1)
+	chain.SetBalance("", testSigner, ether(2))
+	monitor.Check(t.Context())
+	require.Len(t, lows, 2)
+	<-alerts
+
+	// hooks don't repeat while the balance stays low
+	chain.SetBalance("", testSigner, ether(2))
+	monitor.Check(t.Context())
+	require.Len(t, lows, 2)
+}
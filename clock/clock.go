@@ -0,0 +1,109 @@
+// Package clock is the time source of the expiry checks, nonce lifetimes,
+// retry backoffs and queue scheduling of the facilitator. Production code uses
+// Real, tests a Fake they move forward themselves, so deadlines and timeouts
+// can be tested without sleeping.
+package clock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Clock tells the time and waits for it to pass.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer sending the time on its channel once d passed
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f in its own goroutine once d passed, unless the
+	// returned timer is stopped before
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event of a Clock, like a time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on, nil for timers of AfterFunc
+	C() <-chan time.Time
+	// Stop prevents the timer from firing and reports whether it stopped it
+	Stop() bool
+}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Since returns the time passed on the clock since t.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep waits for d to pass on the clock, or for ctx to be done, whose error it returns.
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	timer := c.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// WithDeadline is context.WithDeadline with the deadline measured on the
+// clock. The returned context reports context.DeadlineExceeded once the
+// deadline passed, like the contexts of the standard library.
+func WithDeadline(parent context.Context, c Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := c.(realClock); ok {
+		return context.WithDeadline(parent, deadline)
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	timer := c.AfterFunc(deadline.Sub(c.Now()), func() { cancel(context.DeadlineExceeded) })
+	return &deadlineContext{Context: ctx, deadline: deadline}, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// WithTimeout is WithDeadline of the current time of the clock plus timeout.
+func WithTimeout(parent context.Context, c Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return WithDeadline(parent, c, c.Now().Add(timeout))
+}
+
+// deadlineContext is a context canceled by a timer of a clock other than Real.
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c *deadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *deadlineContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Unix(1_700_000_000, 0)
+
+func TestFake(t *testing.T) {
+	fake := NewFake(epoch)
+	require.Equal(t, epoch, fake.Now())
+
+	timer := fake.NewTimer(time.Minute)
+	fired := make(chan struct{})
+	fake.AfterFunc(2*time.Minute, func() { close(fired) })
+	stopped := fake.NewTimer(time.Minute)
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+	require.Equal(t, 2, fake.Timers())
+
+	fake.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("the timer fired early")
+	default:
+	}
+	fake.Advance(time.Second)
+	require.Equal(t, epoch.Add(time.Minute), <-timer.C())
+	require.False(t, timer.Stop(), "the timer already fired")
+
+	fake.Set(epoch.Add(time.Hour))
+	<-fired
+	require.Zero(t, fake.Timers())
+	require.Equal(t, 2*time.Hour, Since(fake, epoch.Add(-time.Hour)))
+
+	// timers of no duration fire right away
+	<-fake.NewTimer(0).C()
+}
+
+func TestSleep(t *testing.T) {
+	fake := NewFake(epoch)
+	done := make(chan error, 1)
+	go func() { done <- Sleep(t.Context(), fake, time.Minute) }()
+	fake.WaitTimers(1)
+	fake.Advance(time.Minute)
+	require.NoError(t, <-done)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	require.ErrorIs(t, Sleep(ctx, fake, time.Minute), context.Canceled)
+	require.Zero(t, fake.Timers(), "the timer is stopped")
+}
+
+func TestWithDeadline(t *testing.T) {
+	fake := NewFake(epoch)
+	ctx, cancel := WithTimeout(t.Context(), fake, time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.Equal(t, epoch.Add(time.Minute), deadline)
+
+	fake.Advance(time.Minute - time.Nanosecond)
+	require.NoError(t, ctx.Err())
+	fake.Advance(time.Nanosecond)
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := WithDeadline(t.Context(), fake, fake.Now().Add(time.Minute))
+		cancel()
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		require.Zero(t, fake.Timers())
+	})
+
+	t.Run("passed", func(t *testing.T) {
+		ctx, cancel := WithDeadline(t.Context(), fake, fake.Now().Add(-time.Minute))
+		defer cancel()
+		<-ctx.Done()
+		require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+	})
+
+	t.Run("real", func(t *testing.T) {
+		ctx, cancel := WithTimeout(t.Context(), Real, time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+	})
+}
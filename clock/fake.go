@@ -0,0 +1,127 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when told to. Timers fire when Advance or
+// Set moves the time past them.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// signaled whenever a timer is added
+	added chan struct{}
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake creates a fake clock showing now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, added: make(chan struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, make(chan time.Time, 1), nil)
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.add(d, nil, fn)
+}
+
+// Advance moves the clock forward by d and fires the timers due.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the clock to now and fires the timers due. Moving it backwards
+// fires nothing.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	f.now = now
+	var due []*fakeTimer
+	f.timers = slices.DeleteFunc(f.timers, func(t *fakeTimer) bool {
+		if t.at.After(now) {
+			return false
+		}
+		due = append(due, t)
+		return true
+	})
+	f.mu.Unlock()
+
+	slices.SortStableFunc(due, func(a, b *fakeTimer) int { return a.at.Compare(b.at) })
+	for _, t := range due {
+		t.fire(now)
+	}
+}
+
+// Timers returns the number of timers waiting to fire.
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// WaitTimers blocks until at least n timers are waiting, so a test can
+// advance the clock once the code under test started waiting for it.
+func (f *Fake) WaitTimers(n int) {
+	for {
+		f.mu.Lock()
+		waiting, added := len(f.timers), f.added
+		f.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		<-added
+	}
+}
+
+func (f *Fake) add(d time.Duration, c chan time.Time, fn func()) *fakeTimer {
+	f.mu.Lock()
+	t := &fakeTimer{clock: f, at: f.now.Add(d), c: c, fn: fn}
+	if d <= 0 {
+		f.mu.Unlock()
+		t.fire(t.at)
+		return t
+	}
+	f.timers = append(f.timers, t)
+	close(f.added)
+	f.added = make(chan struct{})
+	f.mu.Unlock()
+	return t
+}
+
+type fakeTimer struct {
+	clock *Fake
+	at    time.Time
+	c     chan time.Time
+	fn    func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	n := len(t.clock.timers)
+	t.clock.timers = slices.DeleteFunc(t.clock.timers, func(other *fakeTimer) bool { return other == t })
+	return len(t.clock.timers) < n
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	t.c <- now
+}
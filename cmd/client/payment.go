@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/secrets"
+	"github.com/gosuda/x402-facilitator/payment"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+var payCmd = &cobra.Command{
+	Use:   "pay",
+	Short: "Create a payment, verify and settle it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		payload, requirements, err := newPayment(cmd.Context())
+		if err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		verified, err := c.Verify(cmd.Context(), payload, requirements)
+		if err != nil {
+			return fmt.Errorf("failed to verify payment: %w", err)
+		}
+		if !verified.IsValid {
+			return fmt.Errorf("payment is invalid: %s", verified.InvalidReason)
+		}
+		logging.For(logging.Settlement).Info().Str("payer", verified.Payer).Msg("Payment verified")
+
+		settled, err := c.Settle(cmd.Context(), payload, requirements)
+		if err != nil {
+			return fmt.Errorf("failed to settle payment: %w", err)
+		}
+		if !settled.Success {
+			return fmt.Errorf("settlement failed: %s", settled.Error)
+		}
+		logging.For(logging.Settlement).Info().Str("txHash", settled.TxHash).Msg("Payment settled successfully")
+
+		if wait {
+			return waitSettlement(cmd.Context(), network, settled.TxHash)
+		}
+		return nil
+	},
+}
+
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Create a payment and print it as a request body of verify and settle",
+	Long: `Create a payment and print it as a request body of verify and settle.
+
+Signing doesn't contact any server. With --requirements the payment pays the
+payment requirements read from a file, or stdin for "-", e.g. printed by
+requirements or answered by a resource server: their network, asset, amount
+and recipient replace the flags, and the EIP-712 domain of the asset is taken
+from their extra details. Payments can so be signed on an air-gapped machine
+and submitted elsewhere, with verify and settle or as X-PAYMENT header of the
+resource request, see --header.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			payload      *types.PaymentPayload
+			requirements *types.PaymentRequirements
+			err          error
+		)
+		if requirementsFile != "" {
+			requirements, err = readRequirements(requirementsFile)
+			if err != nil {
+				return err
+			}
+			payload, err = newPaymentFor(cmd.Context(), requirements)
+		} else {
+			payload, requirements, err = newPayment(cmd.Context())
+		}
+		if err != nil {
+			return err
+		}
+		if header {
+			encoded, err := payment.EncodeHeader(payload)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(encoded)
+			return err
+		}
+		return printJSON(types.PaymentVerifyRequest{
+			X402Version:         payload.X402Version,
+			PaymentHeader:       *payload,
+			PaymentRequirements: *requirements,
+		})
+	},
+}
+
+var (
+	scheme  string
+	token   string
+	from    string
+	to      string
+	amount  string
+	privkey string
+
+	wait bool
+
+	requirementsFile string
+	header           bool
+)
+
+// addPaymentFlags adds the flags describing a payment to fs
+func addPaymentFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&scheme, "scheme", "s", "evm", "Scheme to use")
+	fs.StringVarP(&token, "token", "t", "USDC", "token contract for sending")
+	fs.StringVarP(&from, "from", "F", "", "Sender address")
+	fs.StringVarP(&to, "to", "T", "", "Recipient address")
+	fs.StringVarP(&amount, "amount", "A", "", "Amount to send")
+	fs.StringVarP(&privkey, "privkey", "P", "", "Sender private key, or a reference like env:SENDER_KEY or file:/path/to/key")
+}
+
+func init() {
+	addPaymentFlags(payCmd.Flags())
+	payCmd.Flags().BoolVar(&wait, "wait", false, "Wait until the settlement is confirmed, see status")
+	addPaymentFlags(signCmd.Flags())
+	signCmd.Flags().StringVar(&requirementsFile, "requirements", "", "File of payment requirements to pay instead of the payment flags, - for stdin")
+	signCmd.Flags().BoolVar(&header, "header", false, "Print the base64 encoded X-PAYMENT header instead of a request body")
+
+	cmd.AddCommand(payCmd, signCmd)
+}
+
+// newPayment creates the payment of the flags, signed with the private key.
+func newPayment(ctx context.Context) (*types.PaymentPayload, *types.PaymentRequirements, error) {
+	if scheme != string(types.EVM) {
+		return nil, nil, fmt.Errorf("can't create payments of scheme %s", scheme)
+	}
+	domain := evm.GetDomainConfig(network, token)
+	if domain == nil {
+		return nil, nil, fmt.Errorf("domain config not found for chain %s and token %s", network, token)
+	}
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid amount: %q", amount)
+	}
+	priv, err := privateKey(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := payment.NewAuthorization(from, to, value, payment.NewWindow(time.Now(), 0))
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := payment.SignAuthorization(scheme, network, auth, domain, evm.NewRawPrivateSigner(priv))
+	if err != nil {
+		return nil, nil, err
+	}
+	requirements := &types.PaymentRequirements{
+		Scheme:            scheme,
+		Network:           network,
+		MaxAmountRequired: amount,
+		PayTo:             to,
+		Asset:             token,
+	}
+	return payload, requirements, nil
+}
+
+// newPaymentFor signs a payment of the requirements with the private key, from
+// its address unless --from is set. The domain of the asset is the name and
+// version of the extra details of the requirements, or the preset of the asset.
+func newPaymentFor(ctx context.Context, req *types.PaymentRequirements) (*types.PaymentPayload, error) {
+	priv, err := privateKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payer := from
+	if payer == "" {
+		addr, err := evm.GetAddrssFromPrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		payer = addr.Hex()
+	}
+	return payment.Sign(req, payer, evm.NewRawPrivateSigner(priv))
+}
+
+// privateKey resolves the private key of the flags.
+func privateKey(ctx context.Context) ([]byte, error) {
+	key, err := secrets.New().Resolve(ctx, privkey)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+	return priv, nil
+}
+
+// readRequirements reads payment requirements from the file, from stdin for "-".
+func readRequirements(file string) (*types.PaymentRequirements, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var req types.PaymentRequirements
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, fmt.Errorf("failed to read requirements: %w", err)
+	}
+	return &req, nil
+}
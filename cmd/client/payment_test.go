@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestNewPaymentFor(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	privkey = hex.EncodeToString(key.Serialize())
+	t.Cleanup(func() { privkey = "" })
+
+	const payTo = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+	req, err := types.BuildPaymentRequirements("eip155:84532", "USDC", "0.01", payTo)
+	require.NoError(t, err)
+
+	payload, err := newPaymentFor(t.Context(), req)
+	require.NoError(t, err)
+	require.Equal(t, req.Network, payload.Network)
+	require.Equal(t, req.Scheme, payload.Scheme)
+
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	require.NoError(t, err)
+	require.Equal(t, payer, evmPayload.Authorization.From)
+	require.Equal(t, common.HexToAddress(payTo), evmPayload.Authorization.To)
+	require.Equal(t, "10000", evmPayload.Authorization.Value.String())
+
+	// the signature recovers to the payer in the domain of the preset
+	domain := evm.GetDomainConfig("base-sepolia", "USDC")
+	digest, err := evm.HashEip3009(evmPayload.Authorization, domain)
+	require.NoError(t, err)
+	sig, err := evm.ParseSignature(evmPayload.Signature)
+	require.NoError(t, err)
+	sig[64] -= 27
+	pub, err := evm.Ecrecover(digest, sig)
+	require.NoError(t, err)
+	require.Equal(t, payer, common.BytesToAddress(evm.Keccak256(pub[1:])[12:]))
+
+	t.Run("unknown assets need their domain", func(t *testing.T) {
+		other := *req
+		other.Asset = "0x00000000000000000000000000000000000000c0"
+		other.Extra = nil
+		_, err := newPaymentFor(t.Context(), &other)
+		require.ErrorContains(t, err, "name and version in extra")
+
+		extra := json.RawMessage(`{"name":"Other","version":"1"}`)
+		other.Extra = &extra
+		_, err = newPaymentFor(t.Context(), &other)
+		require.NoError(t, err)
+	})
+}
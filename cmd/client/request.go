@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [request.json]",
+	Short: "Verify a payment request body, e.g. created by sign, read from a file or stdin",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := readRequest(args)
+		if err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		verified, err := c.Verify(cmd.Context(), &req.PaymentHeader, &req.PaymentRequirements)
+		if err != nil {
+			return err
+		}
+		if err := printJSON(verified); err != nil {
+			return err
+		}
+		if !verified.IsValid {
+			return fmt.Errorf("payment is invalid: %s", verified.InvalidReason)
+		}
+		return nil
+	},
+}
+
+var settleCmd = &cobra.Command{
+	Use:   "settle [request.json]",
+	Short: "Settle a payment request body, e.g. created by sign, read from a file or stdin",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := readRequest(args)
+		if err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		settled, err := c.Settle(cmd.Context(), &req.PaymentHeader, &req.PaymentRequirements)
+		if err != nil {
+			return err
+		}
+		if err := printJSON(settled); err != nil {
+			return err
+		}
+		if !settled.Success {
+			return fmt.Errorf("settlement failed: %s", settled.Error)
+		}
+		if wait {
+			return waitSettlement(cmd.Context(), req.PaymentHeader.Network, settled.TxHash)
+		}
+		return nil
+	},
+}
+
+var supportedCmd = &cobra.Command{
+	Use:   "supported",
+	Short: "List the payment kinds and signers of the facilitator",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		supported, err := c.Supported(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return printJSON(supported)
+	},
+}
+
+func init() {
+	settleCmd.Flags().BoolVar(&wait, "wait", false, "Wait until the settlement is confirmed, see status")
+
+	cmd.AddCommand(verifyCmd, settleCmd, supportedCmd)
+}
+
+// readRequest reads a request body from the file of the arguments, from stdin
+// if there is none or it is "-".
+func readRequest(args []string) (*types.PaymentVerifyRequest, error) {
+	var r io.Reader = os.Stdin
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var req types.PaymentVerifyRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	return &req, nil
+}
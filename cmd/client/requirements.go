@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+var requirementsCmd = &cobra.Command{
+	Use:   "requirements",
+	Short: "Print the payment requirements a resource server responds with",
+	Long: `Print the payment requirements a resource server responds with.
+
+The amount is given in whole units of the asset, e.g. 0.01 USDC, and converted
+with the decimals of the asset. The asset address and the extra details of the
+scheme are taken from the presets of the network.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := []types.RequirementsOption{
+			types.WithResource(resource, description, mimeType),
+		}
+		if maxTimeout > 0 {
+			opts = append(opts, types.WithMaxTimeout(maxTimeout))
+		}
+		requirements, err := types.BuildPaymentRequirements(network, token, amount, to, opts...)
+		if err != nil {
+			return err
+		}
+		return printJSON(requirements)
+	},
+}
+
+var (
+	resource    string
+	description string
+	mimeType    string
+	maxTimeout  int
+)
+
+func init() {
+	fs := requirementsCmd.Flags()
+	fs.StringVarP(&token, "token", "t", "USDC", "Symbol or address of the asset")
+	fs.StringVarP(&to, "to", "T", "", "Recipient address")
+	fs.StringVarP(&amount, "amount", "A", "", "Amount in whole units of the asset, e.g. 0.01")
+	fs.StringVar(&resource, "resource", "", "URL of the resource paid for")
+	fs.StringVar(&description, "description", "", "Description of the resource")
+	fs.StringVar(&mimeType, "mime-type", "", "MIME type of the resource response")
+	fs.IntVar(&maxTimeout, "max-timeout", types.DefaultMaxTimeoutSeconds, "Seconds the resource server has to respond")
+
+	cmd.AddCommand(requirementsCmd)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+// statusPollInterval is how often the chain is asked about a transaction
+const statusPollInterval = 2 * time.Second
+
+var statusCmd = &cobra.Command{
+	Use:   "status <txHash>",
+	Short: "Wait until a settlement transaction is confirmed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return waitSettlement(cmd.Context(), network, args[0])
+	},
+}
+
+var (
+	rpcURL        string
+	confirmations uint64
+	waitTimeout   time.Duration
+)
+
+func init() {
+	fs := cmd.PersistentFlags()
+	fs.StringVar(&rpcURL, "rpc-url", "", "RPC endpoint settlements are followed on, the preset of the network if empty")
+	fs.Uint64Var(&confirmations, "confirmations", 1, "Confirmations a settlement needs")
+	fs.DurationVar(&waitTimeout, "wait-timeout", 5*time.Minute, "How long to wait for confirmation")
+
+	cmd.AddCommand(statusCmd)
+}
+
+// waitSettlement follows the transaction on the chain of the network, a
+// chain name or CAIP-2 identifier.
+func waitSettlement(ctx context.Context, network, txHash string) error {
+	url := rpcURL
+	if url == "" {
+		chain := network
+		if chainID, ok := evm.ParseCAIP2(network); ok {
+			chain = evm.GetChainName(chainID)
+		}
+		info := evm.GetChainInfo(chain)
+		if info == nil {
+			return fmt.Errorf("no RPC preset for network %s, set --rpc-url", network)
+		}
+		url = info.DefaultUrl
+	}
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", url, err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+	return waitConfirmed(ctx, client, common.HexToHash(txHash), confirmations, statusPollInterval, os.Stdout)
+}
+
+// receiptReader is the part of the RPC client confirmations are counted with
+type receiptReader interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*ethTypes.Receipt, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// waitConfirmed polls the chain until the transaction has the confirmations,
+// printing every change. It fails if the transaction reverted. The receipt is
+// fetched on every poll, so a transaction moved by a reorg is counted anew.
+func waitConfirmed(ctx context.Context, chain receiptReader, txHash common.Hash, confirmations uint64, interval time.Duration, out io.Writer) error {
+	var printed string
+	report := func(status string) {
+		if status != printed {
+			fmt.Fprintln(out, status)
+			printed = status
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		receipt, err := chain.TransactionReceipt(ctx, txHash)
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+			report("pending")
+		case err != nil:
+			return fmt.Errorf("failed to get receipt: %w", err)
+		case receipt.Status != ethTypes.ReceiptStatusSuccessful:
+			report(fmt.Sprintf("reverted in block %d", receipt.BlockNumber))
+			return fmt.Errorf("transaction reverted in block %d", receipt.BlockNumber)
+		default:
+			head, err := chain.BlockNumber(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get block number: %w", err)
+			}
+			block := receipt.BlockNumber.Uint64()
+			var depth uint64
+			if head >= block {
+				depth = head - block + 1
+			}
+			report(fmt.Sprintf("mined in block %d, %d/%d confirmations", block, min(depth, confirmations), confirmations))
+			if depth >= confirmations {
+				report(fmt.Sprintf("confirmed in block %d", block))
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
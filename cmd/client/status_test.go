@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChain mines the transaction after the first poll and adds a block on every poll
+type fakeChain struct {
+	head   uint64
+	status uint64
+	polls  int
+}
+
+func (c *fakeChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*ethTypes.Receipt, error) {
+	c.polls++
+	if c.polls == 1 {
+		return nil, ethereum.NotFound
+	}
+	c.head++
+	return &ethTypes.Receipt{Status: c.status, BlockNumber: big.NewInt(10)}, nil
+}
+
+func (c *fakeChain) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.head, nil
+}
+
+func TestWaitConfirmed(t *testing.T) {
+	var out bytes.Buffer
+	chain := &fakeChain{head: 9, status: ethTypes.ReceiptStatusSuccessful}
+	require.NoError(t, waitConfirmed(t.Context(), chain, common.Hash{1}, 3, time.Millisecond, &out))
+	require.Equal(t, `pending
+mined in block 10, 1/3 confirmations
+mined in block 10, 2/3 confirmations
+mined in block 10, 3/3 confirmations
+confirmed in block 10
+`, out.String())
+
+	out.Reset()
+	chain = &fakeChain{head: 9, status: ethTypes.ReceiptStatusFailed}
+	err := waitConfirmed(t.Context(), chain, common.Hash{1}, 3, time.Millisecond, &out)
+	require.ErrorContains(t, err, "transaction reverted in block 10")
+	require.Equal(t, "pending\nreverted in block 10\n", out.String())
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+var apiKeysCmd = &cobra.Command{
+	Use:   "apikeys",
+	Short: "Manage the API keys of the store without the admin API",
+	Long: `Manage the API keys kept in the configured store, like the /admin/keys
+endpoints do, for operators who don't expose them. The commands read the same
+configuration as the server (-c, --env-only). Revocations and rotations apply
+to running servers sharing the store within 30 seconds.`,
+}
+
+var apiKeysCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key and print it once",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAPIKeys(cmd, func(ctx context.Context, keys *apikey.Manager) error {
+			record, key, err := keys.Create(ctx, apikey.Spec{
+				ID:       createID,
+				Name:     createName,
+				Scopes:   createScopes,
+				Networks: createNetworks,
+			})
+			if err != nil {
+				return err
+			}
+			return printCreatedKey(cmd.OutOrStdout(), record, key)
+		})
+	},
+}
+
+var apiKeysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the API keys, including revoked ones",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAPIKeys(cmd, func(ctx context.Context, keys *apikey.Manager) error {
+			records, err := keys.List(ctx)
+			if err != nil {
+				return err
+			}
+			return printAPIKeys(cmd.OutOrStdout(), records)
+		})
+	},
+}
+
+var apiKeysRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAPIKeys(cmd, func(ctx context.Context, keys *apikey.Manager) error {
+			record, err := keys.Revoke(ctx, args[0])
+			if err != nil {
+				return apiKeyCommandError(args[0], err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "revoked %s at %s\n", record.ID, record.RevokedAt.UTC().Format(time.RFC3339))
+			return nil
+		})
+	},
+}
+
+var apiKeysRotateCmd = &cobra.Command{
+	Use:   "rotate <id>",
+	Short: "Replace the secret of an API key and print the new key once",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAPIKeys(cmd, func(ctx context.Context, keys *apikey.Manager) error {
+			record, key, err := keys.Rotate(ctx, args[0])
+			if err != nil {
+				return apiKeyCommandError(args[0], err)
+			}
+			return printCreatedKey(cmd.OutOrStdout(), record, key)
+		})
+	},
+}
+
+var (
+	createID       string
+	createName     string
+	createScopes   []string
+	createNetworks []string
+)
+
+func init() {
+	fs := apiKeysCreateCmd.Flags()
+	fs.StringVar(&createID, "id", "", "ID of the key, lowercase letters, digits and dashes, generated if empty")
+	fs.StringVar(&createName, "name", "", "Description of the key, e.g. who it is issued to")
+	fs.StringSliceVar(&createScopes, "scope", nil, "Scopes granted to the key: "+strings.Join(apikey.Scopes, ", "))
+	fs.StringSliceVar(&createNetworks, "network", nil, "CAIP-2 identifiers or families like eip155:* the key may pay on, all if omitted")
+
+	apiKeysCmd.AddCommand(apiKeysCreateCmd, apiKeysListCmd, apiKeysRevokeCmd, apiKeysRotateCmd)
+	cmd.AddCommand(apiKeysCmd)
+}
+
+// withAPIKeys opens the store of the configuration and calls fn with a
+// manager of its keys. Stores kept in memory are refused, the keys would be
+// lost when the command exits.
+func withAPIKeys(cmd *cobra.Command, fn func(ctx context.Context, keys *apikey.Manager) error) error {
+	config, err := loadKeysConfig(cmd.Context())
+	if err != nil {
+		return err
+	}
+	records, err := store.New(config.Store)
+	if err != nil {
+		return err
+	}
+	if closer, ok := records.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if _, ok := records.(*store.Memory); ok {
+		return errors.New("the store keeps records in memory only, configure a file, sqlite or postgres store in [store]")
+	}
+	if !config.Auth.APIKeys.Enabled {
+		fmt.Fprintln(cmd.ErrOrStderr(), "warning: [auth.apiKeys] is not enabled, the server doesn't accept the keys")
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), keysTimeout)
+	defer cancel()
+	return fn(ctx, apikey.New(records))
+}
+
+// apiKeyCommandError names the key of a failed command.
+func apiKeyCommandError(id string, err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("API key %q not found", id)
+	}
+	return fmt.Errorf("API key %q: %w", id, err)
+}
+
+// printCreatedKey prints a created or rotated key. The key can't be retrieved
+// again, so it is printed on a line of its own for scripts to pick up.
+func printCreatedKey(out io.Writer, record *store.APIKey, key string) error {
+	fmt.Fprintf(out, "id:       %s\n", record.ID)
+	fmt.Fprintf(out, "scopes:   %s\n", strings.Join(record.Scopes, ","))
+	fmt.Fprintf(out, "networks: %s\n", listOrAll(record.Networks))
+	fmt.Fprintln(out, "key, shown once:")
+	_, err := fmt.Fprintln(out, key)
+	return err
+}
+
+// printAPIKeys prints the keys as a table, without their secret hashes.
+func printAPIKeys(out io.Writer, records []*store.APIKey) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tNETWORKS\tCREATED\tREVOKED")
+	for _, key := range records {
+		revoked := "-"
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.UTC().Format(time.RFC3339)
+		}
+		name := key.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, name, strings.Join(key.Scopes, ","),
+			listOrAll(key.Networks), key.CreatedAt.UTC().Format(time.RFC3339), revoked)
+	}
+	return w.Flush()
+}
+
+// listOrAll joins the networks of a key, "all" if it isn't limited to any.
+func listOrAll(networks []string) string {
+	if len(networks) == 0 {
+		return "all"
+	}
+	return strings.Join(networks, ",")
+}
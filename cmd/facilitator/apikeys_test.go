@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestPrintAPIKeys(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	revoked := created.Add(time.Hour)
+	records := []*store.APIKey{
+		{ID: "shop", Name: "Shop", SecretHash: "deadbeef", Scopes: []string{"settle", "verify"}, Networks: []string{"eip155:*"}, CreatedAt: created},
+		{ID: "old", Scopes: []string{"admin"}, CreatedAt: created, RevokedAt: &revoked},
+	}
+
+	var out strings.Builder
+	require.NoError(t, printAPIKeys(&out, records))
+	require.Equal(t, `ID    NAME  SCOPES         NETWORKS  CREATED               REVOKED
+shop  Shop  settle,verify  eip155:*  2026-01-02T03:04:05Z  -
+old   -     admin          all       2026-01-02T03:04:05Z  2026-01-02T04:04:05Z
+`, out.String())
+	require.NotContains(t, out.String(), "deadbeef")
+
+	out.Reset()
+	require.NoError(t, printCreatedKey(&out, records[0], "x402_shop_secret"))
+	require.True(t, strings.HasSuffix(out.String(), "\nx402_shop_secret\n"), "the key is on a line of its own")
+}
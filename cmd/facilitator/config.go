@@ -1,29 +1,385 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/gosuda/x402-facilitator/api"
+	"github.com/gosuda/x402-facilitator/api/middleware"
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/assetlist"
+	"github.com/gosuda/x402-facilitator/attestation"
+	"github.com/gosuda/x402-facilitator/balance"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/hwwallet"
+	"github.com/gosuda/x402-facilitator/indexer"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/internal/secrets"
+	"github.com/gosuda/x402-facilitator/leader"
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/receipt"
+	"github.com/gosuda/x402-facilitator/recipient"
+	"github.com/gosuda/x402-facilitator/requirement"
+	"github.com/gosuda/x402-facilitator/screening"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/tenant"
 	"github.com/gosuda/x402-facilitator/types"
 	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/knadh/koanf/v2"
 )
 
 type Config struct {
-	Scheme     types.Scheme `mapstructure:"scheme"`
-	Network    string       `mapstructure:"network"`
-	Port       int          `mapstructure:"port"`
-	Url        string       `mapstructure:"url"`
-	PrivateKey string       `mapstructure:"privateKey"`
+	Port         int                           `mapstructure:"port"`
+	Signers      map[string]SignerConfig       `mapstructure:"signers"`
+	Networks     []facilitator.NetworkConfig   `mapstructure:"-"`
+	CustomChains []facilitator.CustomChain     `mapstructure:"customChains"`
+	Oracle       oracle.Config                 `mapstructure:"oracle"`
+	Auth         AuthConfig                    `mapstructure:"auth"`
+	Server       api.HTTPServerConfig          `mapstructure:"server"`
+	Timeouts     api.TimeoutConfig             `mapstructure:"timeouts"`
+	CORS         api.CORSConfig                `mapstructure:"cors"`
+	Headers      api.SecurityHeadersConfig     `mapstructure:"headers"`
+	Compression  api.CompressionConfig         `mapstructure:"compression"`
+	Replay       api.ReplayConfig              `mapstructure:"replay"`
+	Dispatcher   settlement.DispatcherConfig   `mapstructure:"dispatcher"`
+	Store        store.Config                  `mapstructure:"store"`
+	Balance      balance.Config                `mapstructure:"balance"`
+	Tenants      map[string]tenant.Config      `mapstructure:"tenants"`
+	Recipients   recipient.Config              `mapstructure:"recipients"`
+	Requirements requirement.Config            `mapstructure:"requirements"`
+	Screening    screening.Config              `mapstructure:"screening"`
+	Indexer      indexer.Config                `mapstructure:"indexer"`
+	Leader       leader.Config                 `mapstructure:"leader"`
+	Receipts     ReceiptsConfig                `mapstructure:"receipts"`
+	Attestation  AttestationConfig             `mapstructure:"attestation"`
+	VerifyCache  facilitator.VerifyCacheConfig `mapstructure:"verifyCache"`
+	Log          logging.Config                `mapstructure:"log"`
+	Outbound     outbound.Config               `mapstructure:"outbound"`
+	AssetList    assetlist.Config              `mapstructure:"assetList"`
+
+	// merged configuration sources, see Redacted
+	raw map[string]any
 }
 
+// AuthConfig configures how callers of the payment endpoints authenticate
+type AuthConfig struct {
+	HMAC    middleware.HMACConfig `mapstructure:"hmac"`
+	JWT     middleware.JWTConfig  `mapstructure:"jwt"`
+	APIKeys apikey.Config         `mapstructure:"apiKeys"`
+}
+
+// ReceiptsConfig enables signed settlement receipts
+type ReceiptsConfig struct {
+	// Name of the signer receipts are signed with, receipts are disabled if empty.
+	// The signer must have a private key
+	Signer string `mapstructure:"signer"`
+}
+
+// AttestationConfig enables signed verify and settle responses
+type AttestationConfig struct {
+	// Name of the signer responses are signed with, responses are unsigned if empty.
+	// The signer must have a private key
+	Signer string `mapstructure:"signer"`
+}
+
+// SignerConfig holds the key of a signer referenced by network configurations
+type SignerConfig struct {
+	PrivateKey string `mapstructure:"privateKey"`
+	// Hardware wallet holding the key instead, EVM networks only
+	Hardware hwwallet.Config `mapstructure:"hardware"`
+}
+
+// envPrefix is the prefix of environment variables overriding the configuration
+const envPrefix = "X402_"
+
+//go:embed defaults.toml
+var defaultConfig []byte
+
+// LoadConfig loads the built-in defaults, the configuration file at path and
+// the X402_ environment variables, each overriding the ones before. The file is
+// skipped if path is empty.
 func LoadConfig(path string) (*Config, error) {
 	var k = koanf.New(".")
 
-	if err := k.Load(file.Provider(path), toml.Parser()); err != nil {
+	if err := k.Load(rawbytes.Provider(defaultConfig), toml.Parser()); err != nil {
+		return nil, fmt.Errorf("invalid defaults: %w", err)
+	}
+	if path != "" {
+		if err := k.Load(file.Provider(path), toml.Parser()); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.Load(env.Provider(envPrefix, ".", envKey(k)), nil); err != nil {
 		return nil, err
 	}
 	var config Config
-	if err := k.Unmarshal("", &config); err != nil {
+	if err := k.UnmarshalWithConf("", &config, unmarshalConf(&config)); err != nil {
+		return nil, err
+	}
+
+	// networks are keyed by their CAIP-2 identifier, e.g. [networks."eip155:8453"]
+	var networks map[string]facilitator.NetworkConfig
+	if err := k.UnmarshalWithConf("networks", &networks, unmarshalConf(&networks)); err != nil {
 		return nil, err
 	}
+	// custom chains add a network section or complete the one of their identifier
+	for _, chain := range config.CustomChains {
+		if networks == nil {
+			networks = make(map[string]facilitator.NetworkConfig)
+		}
+		networks[chain.ID()] = chain.Section(networks[chain.ID()])
+	}
+	// report every malformed network at once, like Validate
+	var errs []error
+	for id, network := range networks {
+		network.Network = id
+		if err := network.Normalize(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		config.Networks = append(config.Networks, network)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	sort.Slice(config.Networks, func(i, j int) bool {
+		return config.Networks[i].Network < config.Networks[j].Network
+	})
+	config.raw = k.Raw()
 	return &config, nil
 }
+
+// unmarshalConf decodes into result like koanf does by default, but with the
+// mapstructure tags and comma separated lists, as set by environment variables.
+func unmarshalConf(result any) koanf.UnmarshalConf {
+	return koanf.UnmarshalConf{
+		// koanf defaults to the "koanf" struct tag
+		Tag: "mapstructure",
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc(),
+			),
+			Result:           result,
+			WeaklyTypedInput: true,
+		},
+	}
+}
+
+// envKey maps environment variables to configuration keys: the prefix is
+// dropped, "__" separates sections and names are case-insensitive, e.g.
+// X402_SIGNERS__DEFAULT__PRIVATEKEY sets signers.default.privateKey. Network
+// identifiers can't contain colons in variable names, the first underscore
+// stands for it: X402_NETWORKS__EIP155_8453__RPCURLS. Keys of earlier sources
+// keep their spelling, so that the variables override them.
+func envKey(k *koanf.Koanf) func(string) string {
+	known := make(map[string]string) // sections and keys by their lower-case path
+	for _, key := range k.Keys() {
+		parts := strings.Split(key, ".")
+		for i := range parts {
+			path := strings.Join(parts[:i+1], ".")
+			known[strings.ToLower(path)] = parts[i]
+		}
+	}
+	return func(name string) string {
+		parts := strings.Split(strings.ToLower(strings.TrimPrefix(name, envPrefix)), "__")
+		if len(parts) > 1 && parts[0] == "networks" && !strings.Contains(parts[1], ":") {
+			parts[1] = strings.Replace(parts[1], "_", ":", 1)
+		}
+		key := make([]string, len(parts))
+		for i := range parts {
+			key[i] = parts[i]
+			if original, ok := known[strings.Join(parts[:i+1], ".")]; ok {
+				key[i] = original
+			}
+		}
+		return strings.Join(key, ".")
+	}
+}
+
+// NewRegistry creates the facilitators of all configured networks, giving up
+// connecting to their RPC endpoints once ctx is done. priceOracle is optional
+// and only required by fiat payment policies.
+func NewRegistry(ctx context.Context, config *Config, priceOracle oracle.PriceOracle, recipients facilitator.RecipientChecker) (*facilitator.Registry, error) {
+	if len(config.Networks) == 0 {
+		return nil, fmt.Errorf("no networks configured")
+	}
+
+	registry := facilitator.NewRegistry()
+	registry.SetPriceOracle(priceOracle)
+	registry.SetRecipients(recipients)
+	screener, err := screening.New(config.Screening)
+	if err != nil {
+		return nil, err
+	}
+	registry.SetScreener(screener)
+	registry.SetVerifyCache(config.VerifyCache)
+	wallets := make(map[string]*hwwallet.Wallet) // by signer name
+	for _, network := range config.Networks {
+		signer, ok := config.Signers[network.Signer]
+		if !ok {
+			return nil, fmt.Errorf("network %s: unknown signer %q", network.Network, network.Signer)
+		}
+		if network.IsFamily() {
+			// facilitators of the networks of a family are created on the first
+			// payment routed to them, they dial lazily
+			err := registry.RegisterFamily(network, func(chain facilitator.NetworkConfig) (facilitator.Facilitator, error) {
+				return newFacilitator(context.Background(), chain, signer, wallets)
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		f, err := newFacilitator(ctx, network, signer, wallets)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(network, f); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// newFacilitator creates the facilitator of the network. Hardware wallets are
+// opened once per signer and shared by the networks referencing it.
+func newFacilitator(ctx context.Context, network facilitator.NetworkConfig, signer SignerConfig, wallets map[string]*hwwallet.Wallet) (facilitator.Facilitator, error) {
+	if signer.Hardware.Wallet == "" {
+		return facilitator.NewFacilitatorWithContext(ctx, network, signer.PrivateKey)
+	}
+	if network.Scheme != types.EVM {
+		return nil, fmt.Errorf("network %s: hardware wallets can only sign for evm networks", network.Network)
+	}
+
+	wallet, ok := wallets[network.Signer]
+	if !ok {
+		var err error
+		wallet, err = hwwallet.Open(signer.Hardware, promptTerminal)
+		if err != nil {
+			return nil, fmt.Errorf("signer %s: %w", network.Signer, err)
+		}
+		wallets[network.Signer] = wallet
+		logging.For(logging.Server).Info().Str("signer", network.Signer).Str("address", wallet.Address().Hex()).Msg("Opened hardware wallet")
+	}
+	return facilitator.NewEVMFacilitatorWithContext(ctx, network, wallet)
+}
+
+// NewBalanceHooks creates the low balance hooks enabled by the configuration.
+// Top-ups need a treasury holding a private key, since nobody is around to
+// confirm them on a hardware wallet.
+func NewBalanceHooks(config *Config, registry *facilitator.Registry) ([]balance.Hook, error) {
+	var hooks []balance.Hook
+	if config.Balance.Webhook.URL != "" {
+		hooks = append(hooks, balance.NewWebhook(config.Balance.Webhook))
+	}
+	if config.Balance.Treasury != "" {
+		treasury, err := privateKeySigner(config, "treasury signer", config.Balance.Treasury)
+		if err != nil {
+			return nil, fmt.Errorf("balance: %w", err)
+		}
+		hooks = append(hooks, balance.NewTopUp(registry, treasury))
+	}
+	return hooks, nil
+}
+
+// NewReceiptIssuer creates the issuer of settlement receipts, nil if receipts
+// are disabled. Like top-ups, receipts are signed unattended and need a
+// signer holding a private key.
+func NewReceiptIssuer(config *Config, records store.Store) (*receipt.Issuer, error) {
+	if config.Receipts.Signer == "" {
+		return nil, nil
+	}
+	key, err := privateKeySigner(config, "signer", config.Receipts.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("receipts: %w", err)
+	}
+	return receipt.NewIssuer(key, records), nil
+}
+
+// NewResponseKey returns the key verify and settle responses are signed with,
+// nil if they aren't signed.
+func NewResponseKey(config *Config) (attestation.Key, error) {
+	if config.Attestation.Signer == "" {
+		return nil, nil
+	}
+	key, err := privateKeySigner(config, "signer", config.Attestation.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("attestation: %w", err)
+	}
+	return key, nil
+}
+
+// privateKeySigner loads the private key of the named signer, role names it in errors.
+func privateKeySigner(config *Config, role, name string) (*facilitator.PrivateKeySigner, error) {
+	signer, ok := config.Signers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s %q", role, name)
+	}
+	if signer.PrivateKey == "" {
+		return nil, fmt.Errorf("%s %q must have a private key", role, name)
+	}
+	privateKey, err := hex.DecodeString(signer.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", role, name, err)
+	}
+	key, err := facilitator.NewPrivateKeySigner(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", role, name, err)
+	}
+	return key, nil
+}
+
+// ResolveSecrets replaces the private keys of the signers that reference a
+// file, an environment variable or a secret manager with the referenced key.
+func ResolveSecrets(ctx context.Context, config *Config, resolver *secrets.Resolver) error {
+	for name, signer := range config.Signers {
+		privateKey, err := resolver.Resolve(ctx, signer.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("signer %s: %w", name, err)
+		}
+		signer.PrivateKey = privateKey
+		config.Signers[name] = signer
+	}
+	return nil
+}
+
+// NewTenants creates the configured tenants. Tenants are told apart by the key
+// a request is signed with, so their keys must be HMAC keys.
+func NewTenants(config *Config) (*tenant.Tenants, error) {
+	for id, t := range config.Tenants {
+		if len(t.Keys) == 0 {
+			return nil, fmt.Errorf("tenant %s: no keys", id)
+		}
+		for _, key := range t.Keys {
+			if _, ok := config.Auth.HMAC.Secrets[key]; !ok {
+				return nil, fmt.Errorf("tenant %s: key %s has no secret in [auth.hmac]", id, key)
+			}
+		}
+	}
+	return tenant.New(config.Tenants)
+}
+
+// promptTerminal asks the operator for a line of input on the terminal.
+func promptTerminal(message string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", message)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	solTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/hwwallet"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/internal/secrets"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// keysTimeout bounds the secret manager and RPC calls of a keys command
+const keysTimeout = time.Minute
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Provision and check the keys of signers",
+}
+
+var keysAddressCmd = &cobra.Command{
+	Use:   "address [signer...]",
+	Short: "Print the addresses of the configured signers on the networks using them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadKeysConfig(cmd.Context())
+		if err != nil {
+			return err
+		}
+		addresses, err := signerAddresses(config, args)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SIGNER\tNETWORK\tADDRESS")
+		for _, a := range addresses {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", a.signer, a.network, a.address)
+		}
+		return w.Flush()
+	},
+}
+
+var keysBalancesCmd = &cobra.Command{
+	Use:   "balances",
+	Short: "Print the gas balances of the signers on all configured networks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadKeysConfig(cmd.Context())
+		if err != nil {
+			return err
+		}
+		registry, err := NewRegistry(cmd.Context(), config, nil, nil)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), keysTimeout)
+		defer cancel()
+		return printBalances(ctx, cmd.OutOrStdout(), registry)
+	},
+}
+
+var (
+	generateScheme string
+	generateOut    string
+	generatePrint  bool
+)
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a private key and print its address",
+	Long: `Generate a private key for networks of the scheme and print its address.
+The key is written to the --out file, which must not exist yet and is only
+readable by its owner, and can be referenced from the configuration with
+privateKey = "file:<path>". It is only printed with --print-key, and never
+to a terminal.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if generateOut == "" && !generatePrint {
+			return errors.New("set --out to write the key to a file, or --print-key to print it")
+		}
+		if generatePrint && isTerminal(os.Stdout) {
+			return errors.New("refusing to print the key to a terminal, redirect the output")
+		}
+		key, address, err := generateKey(types.Scheme(generateScheme))
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if generatePrint {
+			fmt.Fprintln(out, key)
+			fmt.Fprintf(cmd.ErrOrStderr(), "address: %s\n", address)
+			return nil
+		}
+		path, err := writeKeyFile(generateOut, key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "address: %s\n", address)
+		fmt.Fprintf(out, "key written to %s, configure it with:\n", path)
+		fmt.Fprintf(out, "  privateKey = %q\n", "file:"+path)
+		return nil
+	},
+}
+
+func init() {
+	keysGenerateCmd.Flags().StringVar(&generateScheme, "scheme", string(types.EVM), "Scheme of the networks the key signs for: evm, solana or tron")
+	keysGenerateCmd.Flags().StringVarP(&generateOut, "out", "o", "", "File the key is written to")
+	keysGenerateCmd.Flags().BoolVar(&generatePrint, "print-key", false, "Print the key to the standard output instead, which must not be a terminal")
+
+	keysCmd.AddCommand(keysAddressCmd, keysBalancesCmd, keysGenerateCmd)
+	cmd.AddCommand(keysCmd)
+}
+
+// loadKeysConfig loads the configuration like the server does, with the
+// private keys resolved from their secret stores.
+func loadKeysConfig(ctx context.Context) (*Config, error) {
+	path := configPath
+	if envOnly {
+		path = ""
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, keysTimeout)
+	defer cancel()
+	if err := ResolveSecrets(ctx, config, secrets.New()); err != nil {
+		return nil, err
+	}
+	if err := outbound.Setup(config.Outbound); err != nil {
+		return nil, fmt.Errorf("outbound: %w", err)
+	}
+	return config, nil
+}
+
+// signerAddress is the address of a signer on a network
+type signerAddress struct {
+	signer  string
+	network string
+	address string
+}
+
+// signerAddresses derives the addresses of the named signers, all if names is
+// empty, on every network using them. Signers no network uses, like treasuries,
+// are listed with their EVM address. Hardware wallets are opened to read theirs.
+func signerAddresses(config *Config, names []string) ([]signerAddress, error) {
+	for _, name := range names {
+		if _, ok := config.Signers[name]; !ok {
+			return nil, fmt.Errorf("unknown signer %q", name)
+		}
+	}
+	wallets := make(map[string]*hwwallet.Wallet) // by signer name
+	defer func() {
+		for _, wallet := range wallets {
+			wallet.Close()
+		}
+	}()
+
+	var addresses []signerAddress
+	for _, name := range sortedKeys(config.Signers) {
+		if len(names) > 0 && !slices.Contains(names, name) {
+			continue
+		}
+		signer := config.Signers[name]
+		used := false
+		for _, network := range config.Networks {
+			if network.Signer != name {
+				continue
+			}
+			used = true
+			address, err := deriveAddress(network.Scheme, name, signer, wallets)
+			if err != nil {
+				return nil, fmt.Errorf("signer %s on %s: %w", name, network.Network, err)
+			}
+			addresses = append(addresses, signerAddress{signer: name, network: network.Network, address: address})
+		}
+		if !used {
+			address, err := deriveAddress(types.EVM, name, signer, wallets)
+			if err != nil {
+				return nil, fmt.Errorf("signer %s: %w", name, err)
+			}
+			addresses = append(addresses, signerAddress{signer: name, network: "-", address: address})
+		}
+	}
+	return addresses, nil
+}
+
+// deriveAddress returns the address of the signer on networks of the scheme.
+func deriveAddress(scheme types.Scheme, name string, signer SignerConfig, wallets map[string]*hwwallet.Wallet) (string, error) {
+	if signer.Hardware.Wallet != "" {
+		if scheme != types.EVM {
+			return "", fmt.Errorf("hardware wallets can only sign for evm networks")
+		}
+		wallet, ok := wallets[name]
+		if !ok {
+			var err error
+			if wallet, err = hwwallet.Open(signer.Hardware, promptTerminal); err != nil {
+				return "", err
+			}
+			wallets[name] = wallet
+		}
+		return wallet.Address().Hex(), nil
+	}
+
+	key, err := hex.DecodeString(signer.PrivateKey)
+	if err != nil || len(key) == 0 {
+		return "", fmt.Errorf("privateKey must be hex encoded without 0x prefix")
+	}
+	switch scheme {
+	case types.EVM, types.Tron:
+		privateKey, err := crypto.ToECDSA(key)
+		if err != nil {
+			return "", fmt.Errorf("not a secp256k1 private key: %w", err)
+		}
+		return schemeAddress(scheme, privateKey), nil
+	case types.Solana:
+		account, err := solTypes.AccountFromBytes(key)
+		if err != nil {
+			return "", fmt.Errorf("not an ed25519 keypair: %w", err)
+		}
+		return account.PublicKey.ToBase58(), nil
+	default:
+		return "", fmt.Errorf("addresses of %s signers can't be derived", scheme)
+	}
+}
+
+// schemeAddress formats the address of a secp256k1 key for the scheme.
+func schemeAddress(scheme types.Scheme, key *ecdsa.PrivateKey) string {
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	if scheme == types.Tron {
+		return facilitator.TronAddress(address)
+	}
+	return address.Hex()
+}
+
+// printBalances prints the gas balance of every signer of the registry.
+// Networks whose balances can't be read are listed without one.
+func printBalances(ctx context.Context, out io.Writer, registry *facilitator.Registry) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tSIGNER\tBALANCE")
+	var errs []error
+	for _, network := range registry.Networks() {
+		f, _, _ := registry.Lookup(network.Network)
+		reader, ok := f.(facilitator.GasBalanceReader)
+		if !ok {
+			for _, signer := range f.GetSigners() {
+				fmt.Fprintf(w, "%s\t%s\tunavailable\n", network.Network, signer)
+			}
+			continue
+		}
+		balances, err := reader.GasBalances(ctx)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\terror: %v\n", network.Network, err)
+			errs = append(errs, fmt.Errorf("network %s: %w", network.Network, err))
+			continue
+		}
+		if len(balances) == 0 {
+			fmt.Fprintf(w, "%s\t-\tpaid by a third party\n", network.Network)
+		}
+		for _, balance := range balances {
+			fmt.Fprintf(w, "%s\t%s\t%s %s\n", network.Network, balance.Signer, types.FormatUnits(balance.Amount, balance.Decimals), balance.Currency)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// generateKey generates a private key for the scheme and returns it hex
+// encoded, the way signers are configured, with its address.
+func generateKey(scheme types.Scheme) (string, string, error) {
+	switch scheme {
+	case types.EVM, types.Tron:
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return "", "", err
+		}
+		return hex.EncodeToString(crypto.FromECDSA(key)), schemeAddress(scheme, key), nil
+	case types.Solana:
+		account := solTypes.NewAccount()
+		return hex.EncodeToString(account.PrivateKey), account.PublicKey.ToBase58(), nil
+	default:
+		return "", "", fmt.Errorf("keys of scheme %q can't be generated", scheme)
+	}
+}
+
+// writeKeyFile writes the key to a new file only its owner can read and
+// returns its absolute path. Existing files are never overwritten.
+func writeKeyFile(path, key string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create key file: %w", err)
+	}
+	// no trailing newline, file: references resolve to the exact contents
+	if _, err := file.WriteString(key); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write key file: %w", err)
+	}
+	return path, nil
+}
+
+// isTerminal reports whether the file is a terminal.
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestSignerAddresses(t *testing.T) {
+	evmKey, evmAddress, err := generateKey(types.EVM)
+	require.NoError(t, err)
+	solanaKey, solanaAddress, err := generateKey(types.Solana)
+	require.NoError(t, err)
+
+	config := &Config{
+		Signers: map[string]SignerConfig{
+			"default":  {PrivateKey: evmKey},
+			"solana":   {PrivateKey: solanaKey},
+			"treasury": {PrivateKey: evmKey},
+		},
+		Networks: []facilitator.NetworkConfig{
+			{Network: "eip155:8453", Scheme: types.EVM, Signer: "default"},
+			{Network: "solana:mainnet", Scheme: types.Solana, Signer: "solana"},
+			{Network: "tron:mainnet", Scheme: types.Tron, Signer: "default"},
+		},
+	}
+	addresses, err := signerAddresses(config, nil)
+	require.NoError(t, err)
+	require.Len(t, addresses, 4)
+	require.Equal(t, signerAddress{"default", "eip155:8453", evmAddress}, addresses[0])
+	require.Equal(t, "tron:mainnet", addresses[1].network)
+	require.True(t, strings.HasPrefix(addresses[1].address, "T"), "tron addresses are base58 encoded")
+	require.Equal(t, signerAddress{"solana", "solana:mainnet", solanaAddress}, addresses[2])
+	require.Equal(t, signerAddress{"treasury", "-", evmAddress}, addresses[3], "unused signers are listed with their evm address")
+
+	addresses, err = signerAddresses(config, []string{"solana"})
+	require.NoError(t, err)
+	require.Len(t, addresses, 1)
+
+	_, err = signerAddresses(config, []string{"missing"})
+	require.ErrorContains(t, err, `unknown signer "missing"`)
+
+	config.Signers["solana"] = SignerConfig{PrivateKey: evmKey}
+	_, err = signerAddresses(config, nil)
+	require.ErrorContains(t, err, "signer solana on solana:mainnet: not an ed25519 keypair")
+}
+
+func TestWriteKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signer.key")
+	written, err := writeKeyFile(path, "abcd")
+	require.NoError(t, err)
+	require.Equal(t, path, written)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "abcd", string(content))
+
+	_, err = writeKeyFile(path, "ef01")
+	require.ErrorIs(t, err, os.ErrExist, "existing keys are never overwritten")
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "abcd", string(content))
+}
+
+// balanceFacilitator reports fixed gas balances.
+type balanceFacilitator struct {
+	facilitator.Facilitator
+	balances []facilitator.GasBalance
+	err      error
+}
+
+func (f *balanceFacilitator) GasBalances(context.Context) ([]facilitator.GasBalance, error) {
+	return f.balances, f.err
+}
+
+func TestPrintBalances(t *testing.T) {
+	registry := facilitator.NewRegistry()
+	require.NoError(t, registry.Register(facilitator.NetworkConfig{Network: "eip155:8453", Scheme: types.EVM}, &balanceFacilitator{
+		balances: []facilitator.GasBalance{{Signer: "0x01", Amount: big.NewInt(1.5e18), Currency: "ETH", Decimals: 18}},
+	}))
+	require.NoError(t, registry.Register(facilitator.NetworkConfig{Network: "eip155:84532", Scheme: types.EVM}, &balanceFacilitator{
+		err: errors.New("connection refused"),
+	}))
+
+	var out strings.Builder
+	err := printBalances(t.Context(), &out, registry)
+	require.ErrorContains(t, err, "network eip155:84532: connection refused")
+	require.Equal(t, `NETWORK       SIGNER  BALANCE
+eip155:8453   0x01    1.5 ETH
+eip155:84532  -       error: connection refused
+`, out.String())
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,15 +13,28 @@ import (
 	"time"
 
 	"github.com/gosuda/x402-facilitator/api"
-	"github.com/gosuda/x402-facilitator/facilitator"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/assetlist"
+	"github.com/gosuda/x402-facilitator/balance"
+	"github.com/gosuda/x402-facilitator/indexer"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/internal/secrets"
+	"github.com/gosuda/x402-facilitator/internal/version"
+	"github.com/gosuda/x402-facilitator/leader"
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/recipient"
+	"github.com/gosuda/x402-facilitator/requirement"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/tokenmeta"
 	"github.com/spf13/cobra"
 )
 
 var cmd = &cobra.Command{
-	Use:   "x402-facilitator",
-	Short: "Start the facilitator server",
+	Use:     "x402-facilitator",
+	Short:   "Start the facilitator server",
+	Version: version.Get().String(),
 	Run: func(cmd *cobra.Command, args []string) {
 		run()
 	},
@@ -26,42 +42,207 @@ var cmd = &cobra.Command{
 
 var (
 	configPath string
+	envOnly    bool
 )
 
 func init() {
 	cmd.PersistentFlags().StringVarP(&configPath, "config", "c", "config.toml", "Path to the configuration file")
+	cmd.PersistentFlags().BoolVar(&envOnly, "env-only", false, "Configure from the built-in defaults and X402_ environment variables only, without a file")
 }
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		log.Fatal().Err(err).Msg("Failed to execute command")
+		logging.For(logging.Server).Fatal().Err(err).Msg("Failed to execute command")
 	}
 }
 
 func run() {
+	if envOnly {
+		configPath = ""
+	}
 	config, err := LoadConfig(configPath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to load configuration, shutting down...")
+		logging.For(logging.Server).Fatal().Err(err).Msg("Failed to load configuration, shutting down...")
+	}
+	if err := logging.Setup(config.Log, os.Stdout); err != nil {
+		logging.For(logging.Server).Fatal().Err(err).Msg("Invalid log configuration, shutting down...")
+	}
+	// taken once the global logger is configured
+	logger := logging.For(logging.Server)
+
+	// secret managers are asked once, the keys are kept in memory afterwards
+	secretsCtx, cancelSecrets := context.WithTimeout(context.Background(), 30*time.Second)
+	err = ResolveSecrets(secretsCtx, config, secrets.New())
+	cancelSecrets()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to resolve secrets, shutting down...")
+	}
+
+	if err := config.Validate(); err != nil {
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			for _, problem := range invalid.Problems {
+				logger.Error().Msg(problem)
+			}
+		}
+		logger.Fatal().Msg("Invalid configuration, shutting down...")
+	}
+	if err := outbound.Setup(config.Outbound); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid outbound configuration, shutting down...")
+	}
+
+	priceOracle, err := oracle.New(config.Oracle)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to init price oracle, shutting down...")
+	}
+
+	records, err := store.New(config.Store)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to open store, shutting down...")
+	}
+	if closer, ok := records.(io.Closer); ok {
+		defer closer.Close()
+	}
+	recipients := recipient.New(records, config.Recipients)
+
+	// interrupting a startup waiting for slow RPC endpoints shuts down right away
+	startCtx, stopStart := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	registry, err := NewRegistry(startCtx, config, priceOracle, recipients)
+	stopStart()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to init facilitator, shutting down...")
+	}
+
+	// assets configured by their address alone are rejected until their tokens are read
+	tokens := tokenmeta.New(registry, records)
+	unresolved := tokens.Resolve(context.Background())
+	if unresolved != nil {
+		logging.For(logging.Assets).Error().Err(unresolved).Msg("Failed to resolve token metadata")
+	}
+
+	assets, err := assetlist.New(registry, config.AssetList)
+	if err != nil {
+		logging.For(logging.Assets).Fatal().Err(err).Msg("Failed to init asset lists, shutting down...")
+	}
+	if assets.Enabled() {
+		// networks whose list can't be read keep the configured assets
+		if err := assets.Sync(context.Background()); err != nil {
+			logging.For(logging.Assets).Error().Err(err).Msg("Failed to sync asset lists")
+		}
+	}
+
+	balanceHooks, err := NewBalanceHooks(config, registry)
+	if err != nil {
+		logging.For(logging.Balance).Fatal().Err(err).Msg("Failed to init balance hooks, shutting down...")
+	}
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var elector *leader.Elector
+	if config.Leader.Backend != "" {
+		if elector, err = leader.New(config.Leader); err != nil {
+			logging.For(logging.Leader).Fatal().Err(err).Msg("Failed to init leader election, shutting down...")
+		}
+		balanceHooks = leaderOnly(elector, balanceHooks)
+	}
+	go balance.NewMonitor(registry, config.Balance, balanceHooks...).Run(backgroundCtx)
+	if assets.Enabled() {
+		go assets.Run(backgroundCtx)
+	}
+	if unresolved != nil {
+		go tokens.Run(backgroundCtx)
 	}
-	log.Logger = zerolog.New(os.Stdout).With().Timestamp().Caller().Logger()
 
-	facilitator, err := facilitator.NewFacilitator(config.Scheme, config.Network, config.Url, config.PrivateKey)
+	tenants, err := NewTenants(config)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to init facilitator, shutting down...")
+		logger.Fatal().Err(err).Msg("Failed to init tenants, shutting down...")
 	}
 
-	api := api.NewServer(facilitator)
+	receipts, err := NewReceiptIssuer(config, records)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to init receipts, shutting down...")
+	}
+	responseKey, err := NewResponseKey(config)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to init response signing, shutting down...")
+	}
+
+	settlements := settlement.NewManager(registry, records,
+		settlement.WithDispatcher(config.Dispatcher),
+		settlement.WithReceipts(receipts),
+		settlement.WithLeader(elector),
+	)
+	defer settlements.Close()
+	shutdownTimeout := cmp.Or(config.Server.ShutdownTimeout, api.DefaultShutdownTimeout)
+	if elector == nil && config.Server.ReusePort {
+		// the process being upgraded may still be draining its settlements, they
+		// are resumed once it had the time to finish
+		go func() {
+			select {
+			case <-backgroundCtx.Done():
+				return
+			case <-time.After(shutdownTimeout):
+			}
+			if err := settlements.Resume(backgroundCtx); err != nil {
+				logging.For(logging.Settlement).Error().Err(err).Msg("Failed to resume settlements")
+			}
+		}()
+	} else if elector == nil {
+		if err := settlements.Resume(context.Background()); err != nil {
+			logging.For(logging.Settlement).Fatal().Err(err).Msg("Failed to resume settlements, shutting down...")
+		}
+	} else {
+		// every new leader picks up the settlements its predecessor left unfinished
+		elector.OnElected(func(ctx context.Context) {
+			if err := settlements.Resume(ctx); err != nil {
+				logging.For(logging.Settlement).Error().Err(err).Msg("Failed to resume settlements")
+			}
+		})
+		go elector.Run(backgroundCtx)
+	}
+	go settlement.NewWebhooks(tenants).Run(backgroundCtx, settlements.Hub())
+	var transfers *indexer.Indexer
+	if config.Indexer.Enabled {
+		transfers = indexer.New(registry, records, config.Indexer)
+		go transfers.Run(backgroundCtx)
+	}
+
+	opts := []api.Option{
+		api.WithHMACAuth(config.Auth.HMAC),
+		api.WithJWTAuth(config.Auth.JWT),
+		api.WithTenants(tenants),
+		api.WithTimeouts(config.Timeouts),
+		api.WithCORS(config.CORS),
+		api.WithSecurityHeaders(config.Headers),
+		api.WithCompression(config.Compression),
+		api.WithReplayProtection(config.Replay),
+		api.WithConfigDump(config.Redacted),
+		api.WithRecipients(recipients),
+		api.WithIndexer(transfers),
+		api.WithReceipts(receipts),
+		api.WithResponseSigning(responseKey),
+		api.WithRequirements(requirement.New(records, config.Requirements)),
+	}
+	if config.Auth.APIKeys.Enabled {
+		opts = append(opts, api.WithAPIKeys(apikey.New(records)))
+	}
+	handler := api.NewServer(registry, settlements, priceOracle, opts...)
 
 	// Initialize Server
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", config.Port),
-		Handler: api,
+	server := api.NewHTTPServer(fmt.Sprintf(":%d", config.Port), handler, config.Server)
+	activated := api.Activated()
+	listener, err := api.Listen(context.Background(), server.Addr, config.Server)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to listen, shutting down...")
 	}
 
 	go func() {
-		log.Info().Msgf("Starting server on port %d", config.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal().Err(err).Msg("Failed to start server, shutting down...")
+		if activated {
+			logger.Info().Str("addr", listener.Addr().String()).Msg("Starting server on socket passed by systemd")
+		} else {
+			logger.Info().Msgf("Starting server on port %d", config.Port)
+		}
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Fatal().Err(err).Msg("Failed to start server, shutting down...")
 		}
 	}()
 
@@ -71,11 +252,32 @@ func run() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// new connections go to the successor bound to the same port or wait in the
+	// backlog of the socket systemd holds, the requests and settlements in
+	// flight are finished within the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Failed to shutdown server gracefully")
+		logger.Error().Err(err).Msg("Failed to shutdown server gracefully")
+	}
+	if err := settlements.Drain(ctx); err != nil {
+		logging.For(logging.Settlement).Warn().Err(err).Msg("Stopped before all settlements finished, the next start resumes them")
+	}
+	logger.Info().Msg("Server shutdown gracefully")
+}
+
+// leaderOnly runs the balance hooks only on the leader, so standbys don't top
+// up signers or alert a second time.
+func leaderOnly(elector *leader.Elector, hooks []balance.Hook) []balance.Hook {
+	gated := make([]balance.Hook, len(hooks))
+	for i, hook := range hooks {
+		gated[i] = balance.HookFunc(func(ctx context.Context, low balance.LowBalance) error {
+			if !elector.IsLeader() {
+				return nil
+			}
+			return hook.LowBalance(ctx, low)
+		})
 	}
-	log.Info().Msg("Server shutdown gracefully")
+	return gated
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gosuda/x402-facilitator/internal/secrets"
+)
+
+// redacted replaces secrets in the configuration dump
+const redacted = "REDACTED"
+
+// secretKeys are the configuration keys whose values are secrets, in lower case
+var secretKeys = map[string]bool{
+	"privatekey": true,
+	"secret":     true,
+	"secrets":    true,
+	"apikey":     true,
+	"password":   true,
+	"token":      true,
+}
+
+// Redacted returns the merged configuration sources with secrets replaced:
+// private keys, shared secrets and API keys, the headers of webhooks, and
+// credentials, paths and queries of URLs, which often carry API keys.
+// References to secrets, e.g. env:SIGNER_KEY, are kept.
+func (c *Config) Redacted() map[string]any {
+	resolver := secrets.New()
+	redacted, _ := redact(c.raw, 0, resolver).(map[string]any)
+	return redacted
+}
+
+func redact(value any, depth int, resolver *secrets.Resolver) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			lower := strings.ToLower(key)
+			switch {
+			// the security headers of responses are no secret, the headers of webhooks may be
+			case secretKeys[lower], lower == "headers" && depth > 0:
+				out[key] = redactSecret(child, resolver)
+			case strings.HasSuffix(lower, "url") || strings.HasSuffix(lower, "urls"):
+				out[key] = redactURLs(child)
+			default:
+				out[key] = redact(child, depth+1, resolver)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = redact(child, depth+1, resolver)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = redact(child, depth+1, resolver)
+		}
+		return out
+	}
+	return value
+}
+
+// redactSecret redacts a secret, or every value of a map of secrets.
+func redactSecret(value any, resolver *secrets.Resolver) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			out[key] = redactSecret(child, resolver)
+		}
+		return out
+	case string:
+		if v == "" || resolver.IsReference(v) {
+			return v
+		}
+	}
+	return redacted
+}
+
+// redactURLs redacts a URL or a list of them.
+func redactURLs(value any) any {
+	switch v := value.(type) {
+	case string:
+		// lists set by environment variables are comma separated
+		urls := strings.Split(v, ",")
+		for i, u := range urls {
+			urls[i] = redactURL(u)
+		}
+		return strings.Join(urls, ",")
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = redactURLs(child)
+		}
+		return out
+	}
+	return value
+}
+
+func redactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.Path != "" && u.Path != "/" {
+		u.Path = "/" + redacted
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/gosuda/x402-facilitator/api"
+	"github.com/gosuda/x402-facilitator/assetlist"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/hwwallet"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/leader"
+	"github.com/gosuda/x402-facilitator/screening"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration before anything is started, so operators
+// see all problems at once instead of the first failing connection. It
+// returns a *ValidationError if there are any.
+func (c *Config) Validate() error {
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		report("port: %d is not between 1 and 65535", c.Port)
+	}
+
+	for _, name := range sortedKeys(c.Signers) {
+		signer := c.Signers[name]
+		switch {
+		case signer.PrivateKey != "" && signer.Hardware.Wallet != "":
+			report("signers.%s: set either privateKey or hardware, not both", name)
+		case signer.Hardware.Wallet != "":
+			if signer.Hardware.Wallet != hwwallet.Ledger && signer.Hardware.Wallet != hwwallet.Trezor {
+				report("signers.%s.hardware: wallet must be %q or %q, not %q", name, hwwallet.Ledger, hwwallet.Trezor, signer.Hardware.Wallet)
+			}
+		case signer.PrivateKey == "":
+			report("signers.%s: privateKey is empty", name)
+		default:
+			if _, err := hex.DecodeString(signer.PrivateKey); err != nil {
+				report("signers.%s: privateKey must be hex encoded without 0x prefix", name)
+			}
+		}
+	}
+
+	if len(c.Networks) == 0 {
+		report("networks: no networks configured")
+	}
+	networks := make([]string, 0, len(c.Networks))
+	oracleConfigured := c.Oracle.Provider != "" || len(c.Oracle.Fixed) > 0
+	for _, network := range c.Networks {
+		section := fmt.Sprintf("networks.%q", network.Network)
+		networks = append(networks, network.Network)
+
+		if signer, ok := c.Signers[network.Signer]; !ok {
+			report("%s: unknown signer %q", section, network.Signer)
+		} else if err := checkSignerKey(network.Scheme, signer); err != nil {
+			report("%s: signer %s: %v", section, network.Signer, err)
+		}
+		if network.Scheme != types.EVM {
+			// the facilitators of the other schemes neither verify nor settle payments yet
+			report("%s: %s payments aren't supported yet, only evm networks can be served", section, network.Scheme)
+		}
+		for _, rpcURL := range network.RPCURLs {
+			if err := checkURL(rpcURL, "http", "https", "ws", "wss"); err != nil {
+				report("%s: rpcUrls: %v", section, err)
+			}
+		}
+		if network.Scheme != types.EVM && len(network.RPCURLs) == 0 {
+			report("%s: rpcUrls are required, there are no presets for %s networks", section, network.Scheme)
+		}
+		if network.IsFamily() {
+			checkFamily(network, c.Signers[network.Signer], func(format string, args ...any) {
+				report(section+": "+format, args...)
+			})
+		} else if len(network.ChainRPCURLs) > 0 {
+			report("%s: chainRpcUrls are only supported in family sections like %q", section, caip.Family(network.Network))
+		}
+		for i, asset := range network.Assets {
+			if network.Scheme == types.EVM && !network.IsFamily() {
+				asset, _ := network.AssetWithPreset(asset)
+				switch {
+				case asset.Address == "":
+					report("%s: assets[%d]: address is required, there is no preset of %s", section, i, asset.Symbol)
+				case !common.IsHexAddress(asset.Address):
+					report("%s: assets[%d]: %q is not an address", section, i, asset.Address)
+				}
+				switch asset.TransferMethod {
+				case "", types.TransferMethodEIP3009, types.TransferMethodEIP2612:
+				default:
+					report("%s: assets[%d]: transferMethod must be %q or %q", section, i, types.TransferMethodEIP3009, types.TransferMethodEIP2612)
+				}
+				if asset.TransferFeeToleranceBps < 0 || asset.TransferFeeToleranceBps > 10_000 {
+					report("%s: assets[%d]: transferFeeToleranceBps must be between 0 and 10000", section, i)
+				}
+				if asset.CheckTransferFee && asset.TransferMethod == types.TransferMethodEIP2612 {
+					report("%s: assets[%d]: checkTransferFee is only supported with transferMethod %q", section, i, types.TransferMethodEIP3009)
+				}
+			}
+			if asset.Decimals < 0 {
+				report("%s: assets[%d]: decimals must not be negative", section, i)
+			}
+		}
+		if network.AssetRegistry != "" {
+			if network.Scheme != types.EVM {
+				report("%s: assetRegistry is only supported on evm networks", section)
+			} else if !common.IsHexAddress(network.AssetRegistry) {
+				report("%s: assetRegistry %q is not an address", section, network.AssetRegistry)
+			}
+		}
+		if network.Policy.MaxAmountUSD > 0 && !oracleConfigured {
+			report("%s: policy.maxAmountUsd requires a price oracle, set oracle.provider or oracle.fixed", section)
+		}
+		if network.Gas.MaxGasCostUSD > 0 && !oracleConfigured {
+			report("%s: gas.maxGasCostUsd requires a price oracle, set oracle.provider or oracle.fixed", section)
+		}
+		if network.Balance.TopUp > 0 && c.Balance.Treasury == "" {
+			report("%s: balance.topUp requires balance.treasury", section)
+		}
+		if network.AcceptNative && network.Scheme != types.EVM {
+			report("%s: acceptNative is only supported on evm networks", section)
+		}
+		if network.AcceptNative && network.Bundler.URL != "" {
+			report("%s: acceptNative can't be combined with bundler settlement", section)
+		}
+		if network.Split.Enabled && network.Scheme != types.EVM {
+			report("%s: split is only supported on evm networks", section)
+		}
+		if network.Split.Contract != "" && !common.IsHexAddress(network.Split.Contract) {
+			report("%s: split.contract %q is not an address", section, network.Split.Contract)
+		}
+		if network.Split.MaxRecipients < 0 {
+			report("%s: split.maxRecipients must not be negative", section)
+		}
+		if network.Policy.RegisteredRecipients && network.Scheme != types.EVM && network.Scheme != types.Solana {
+			report("%s: policy.registeredRecipients is only supported on evm and solana networks", section)
+		}
+		if network.BatchReads && network.Scheme != types.EVM {
+			report("%s: batchReads is only supported on evm networks", section)
+		}
+		if network.VerifyOnOutage && network.Scheme != types.EVM {
+			report("%s: verifyOnOutage is only supported on evm networks", section)
+		}
+		if (network.NativeCurrency != "" || network.EIP1559) && network.Scheme != types.EVM {
+			report("%s: nativeCurrency and eip1559 are only supported on evm networks", section)
+		}
+		if network.Scheme == types.EVM {
+			if err := checkGasPolicy(network.Gas); err != nil {
+				report("%s: gas: %v", section, err)
+			}
+		}
+		if network.Retry.Attempts < 0 || network.Retry.InitialBackoff < 0 || network.Retry.MaxBackoff < 0 {
+			report("%s: retry attempts and backoffs must not be negative", section)
+		}
+		if network.ExpiryMargin < 0 {
+			report("%s: expiryMargin must not be negative", section)
+		}
+		if network.Limits.MaxConcurrent < 0 || network.Limits.MaxPerBlock < 0 {
+			report("%s: limits must not be negative", section)
+		}
+		if network.Limits.MaxPerBlock > 0 && network.Scheme != types.EVM {
+			report("%s: limits.maxPerBlock is only supported on evm networks", section)
+		}
+		if network.Bundler.URL != "" {
+			if err := checkURL(network.Bundler.URL, "http", "https"); err != nil {
+				report("%s: bundler.url: %v", section, err)
+			}
+			if err := checkURL(network.Bundler.PaymasterURL, "http", "https"); err != nil {
+				report("%s: bundler.paymasterUrl: %v", section, err)
+			}
+		}
+	}
+
+	defined := make(map[string]int)
+	for i, chain := range c.CustomChains {
+		section := fmt.Sprintf("customChains[%d]", i)
+		if chain.ChainID <= 0 {
+			report("%s: chainId must be positive", section)
+			continue
+		}
+		if chainID, ok := caip.EVMChainID(chain.ID()); !ok || chainID.Int64() != chain.ChainID {
+			report("%s: network %q is not the identifier of chain %d", section, chain.Network, chain.ChainID)
+		}
+		if j, ok := defined[chain.ID()]; ok {
+			report("%s: %s is already defined by customChains[%d]", section, chain.ID(), j)
+		}
+		defined[chain.ID()] = i
+		if n := slices.IndexFunc(c.Networks, func(network facilitator.NetworkConfig) bool {
+			return network.Network == chain.ID()
+		}); n >= 0 && len(c.Networks[n].RPCURLs) == 0 {
+			report("%s: rpcUrls are required, custom chains have no presets", section)
+		}
+	}
+
+	if c.AssetList.ManifestURL != "" {
+		if err := checkURL(c.AssetList.ManifestURL, "http", "https"); err != nil {
+			report("assetList: manifestUrl: %v", err)
+		}
+		if _, err := assetlist.ParsePublicKey(c.AssetList.PublicKey); err != nil {
+			report("assetList: %v", err)
+		}
+	}
+	if c.AssetList.Interval < 0 {
+		report("assetList: interval must not be negative")
+	}
+
+	switch strings.ToLower(c.Oracle.Provider) {
+	case "", "coingecko", "chainlink":
+	default:
+		report("oracle: unknown provider %q", c.Oracle.Provider)
+	}
+
+	switch strings.ToLower(c.Screening.Provider) {
+	case "":
+	case screening.ProviderChainalysis, screening.ProviderTRM:
+		if c.Screening.APIKey == "" {
+			report("screening: the %s provider requires an apiKey", c.Screening.Provider)
+		}
+		if c.Screening.URL != "" {
+			if err := checkURL(c.Screening.URL, "http", "https"); err != nil {
+				report("screening: url: %v", err)
+			}
+		}
+	default:
+		report("screening: unknown provider %q", c.Screening.Provider)
+	}
+	if c.Screening.CacheTTL < 0 {
+		report("screening: cacheTtl must not be negative")
+	}
+
+	switch c.Store.Driver {
+	case "", store.DriverMemory, store.DriverFile:
+	case store.DriverSQLite:
+		if c.Store.Path == "" {
+			report("store: the sqlite driver requires a path")
+		}
+	case store.DriverPostgres:
+		// the URL holds the database password, so it isn't repeated
+		if u, err := url.Parse(c.Store.URL); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			report("store: the postgres driver requires a postgres:// url")
+		}
+	default:
+		report("store: unknown driver %q", c.Store.Driver)
+	}
+
+	if c.Balance.Treasury != "" {
+		if signer, ok := c.Signers[c.Balance.Treasury]; !ok {
+			report("balance: unknown treasury signer %q", c.Balance.Treasury)
+		} else if signer.PrivateKey == "" {
+			report("balance: treasury signer %q must have a private key", c.Balance.Treasury)
+		}
+	}
+	if c.Balance.Webhook.URL != "" {
+		if err := checkURL(c.Balance.Webhook.URL, "http", "https"); err != nil {
+			report("balance.webhook.url: %v", err)
+		}
+	}
+	if c.Receipts.Signer != "" {
+		if signer, ok := c.Signers[c.Receipts.Signer]; !ok {
+			report("receipts: unknown signer %q", c.Receipts.Signer)
+		} else if signer.PrivateKey == "" {
+			report("receipts: signer %q must have a private key", c.Receipts.Signer)
+		}
+	}
+	if c.Attestation.Signer != "" {
+		if signer, ok := c.Signers[c.Attestation.Signer]; !ok {
+			report("attestation: unknown signer %q", c.Attestation.Signer)
+		} else if signer.PrivateKey == "" {
+			report("attestation: signer %q must have a private key", c.Attestation.Signer)
+		}
+	}
+	if c.Indexer.Interval < 0 || c.Indexer.Grace < 0 || c.Indexer.Lookback < 0 {
+		report("indexer: interval, grace and lookback must not be negative")
+	}
+
+	switch c.Leader.Backend {
+	case "":
+	case leader.BackendPostgres:
+		if err := checkURL(c.Leader.URL, "postgres", "postgresql"); err != nil {
+			report("leader: url: %v", err)
+		}
+	case leader.BackendRedis:
+		if err := checkURL(c.Leader.URL, "redis", "rediss"); err != nil {
+			report("leader: url: %v", err)
+		}
+	default:
+		report("leader: backend must be %q or %q, not %q", leader.BackendPostgres, leader.BackendRedis, c.Leader.Backend)
+	}
+	if c.Leader.TTL < 0 || c.Leader.Interval < 0 {
+		report("leader: ttl and interval must not be negative")
+	} else if c.Leader.TTL > 0 && c.Leader.Interval >= c.Leader.TTL {
+		report("leader: interval must be shorter than ttl")
+	}
+
+	if c.Auth.JWT.JWKSURL != "" {
+		if err := checkURL(c.Auth.JWT.JWKSURL, "http", "https"); err != nil {
+			report("auth.jwt.jwksUrl: %v", err)
+		}
+	}
+
+	if c.Server.ReadHeaderTimeout < 0 || c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.ShutdownTimeout < 0 || c.Server.MaxHeaderBytes < 0 {
+		report("server: timeouts and maxHeaderBytes must not be negative")
+	}
+	// the write timeout covers the whole handler, settlements would be cut off before their deadline
+	if maxSettle := cmp.Or(c.Timeouts.MaxSettle, api.DefaultMaxSettleTimeout); c.Server.WriteTimeout > 0 && c.Server.WriteTimeout <= maxSettle {
+		report("server: writeTimeout %s must be longer than timeouts.maxSettle %s", c.Server.WriteTimeout, maxSettle)
+	}
+	for _, path := range sortedKeys(c.Timeouts.Routes) {
+		if api.OwnDeadline(path) {
+			report("timeouts.routes: %s has its own deadline", path)
+		} else if c.Timeouts.Routes[path] <= 0 {
+			report("timeouts.routes: deadline of %s must be positive", path)
+		}
+	}
+
+	if c.CORS.AllowCredentials && (len(c.CORS.AllowOrigins) == 0 || slices.Contains(c.CORS.AllowOrigins, "*")) {
+		report("cors: allowCredentials requires explicit allowOrigins")
+	}
+	for _, encoding := range c.Compression.Encodings {
+		if encoding != api.EncodingBrotli && encoding != api.EncodingGzip {
+			report("compression.encodings: unknown encoding %q, supported are br and gzip", encoding)
+		}
+	}
+	if c.Compression.GzipLevel < 0 || c.Compression.GzipLevel > 9 {
+		report("compression: gzipLevel must be between 1 and 9")
+	}
+	if c.Compression.BrotliLevel < 0 || c.Compression.BrotliLevel > 11 {
+		report("compression: brotliLevel must be between 1 and 11")
+	}
+	if c.Compression.MinLength < 0 {
+		report("compression: minLength must not be negative")
+	}
+	if c.Replay.Window < 0 {
+		report("replay: window must not be negative")
+	}
+
+	for _, id := range sortedKeys(c.Tenants) {
+		t := c.Tenants[id]
+		if len(t.Keys) == 0 {
+			report("tenants.%s: no keys", id)
+		}
+		for _, key := range t.Keys {
+			// with token authentication, keys may be the tenant claims of tokens,
+			// and managed keys are only known to the store
+			if _, ok := c.Auth.HMAC.Secrets[key]; !ok && c.Auth.JWT.JWKSURL == "" && !c.Auth.APIKeys.Enabled {
+				report("tenants.%s: key %s has no secret in [auth.hmac]", id, key)
+			}
+		}
+		for _, network := range t.Networks {
+			if !caip.ValidPattern(network) {
+				report("tenants.%s: network %q is not a CAIP-2 identifier or family like \"eip155:*\"", id, network)
+			} else if !slices.ContainsFunc(networks, func(configured string) bool {
+				return caip.Match(network, configured) || caip.Match(configured, network)
+			}) {
+				report("tenants.%s: network %s is not configured", id, network)
+			}
+		}
+		for _, webhook := range t.Webhooks {
+			if err := checkURL(webhook.URL, "http", "https"); err != nil {
+				report("tenants.%s.webhooks: %v", id, err)
+			}
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		report("log: level: %v", err)
+	}
+	if !slices.Contains([]string{"", logging.FormatAuto, logging.FormatJSON, logging.FormatConsole}, c.Log.Format) {
+		report("log: format must be %q, %q or %q, not %q", logging.FormatAuto, logging.FormatJSON, logging.FormatConsole, c.Log.Format)
+	}
+	if c.Log.Sampling.Period < 0 {
+		report("log: sampling.period must not be negative")
+	}
+	for _, subsystem := range sortedKeys(c.Log.Subsystems) {
+		if !slices.Contains(logging.Subsystems, subsystem) {
+			report("log.subsystems: unknown subsystem %q, one of %s", subsystem, strings.Join(logging.Subsystems, ", "))
+		} else if _, err := logging.ParseLevel(c.Log.Subsystems[subsystem]); err != nil {
+			report("log.subsystems: %s: %v", subsystem, err)
+		}
+	}
+
+	if c.Outbound.Proxy != "" {
+		if err := checkURL(c.Outbound.Proxy, outbound.ProxySchemes...); err != nil {
+			report("outbound: proxy: %v", err)
+		}
+	} else if len(c.Outbound.NoProxy) > 0 {
+		report("outbound: noProxy requires proxy, the NO_PROXY environment variable applies to proxies of the environment")
+	}
+	if c.Outbound.CAFile != "" {
+		if _, err := outbound.LoadCAFile(c.Outbound.CAFile); err != nil {
+			report("outbound: %v", err)
+		}
+	}
+	if c.Outbound.DialTimeout < 0 || c.Outbound.TLSHandshakeTimeout < 0 || c.Outbound.ResponseHeaderTimeout < 0 {
+		report("outbound: timeouts must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checkSignerKey checks that the private key of the signer fits the scheme.
+func checkSignerKey(scheme types.Scheme, signer SignerConfig) error {
+	if signer.Hardware.Wallet != "" {
+		if scheme != types.EVM {
+			return fmt.Errorf("hardware wallets can only sign for evm networks")
+		}
+		return nil
+	}
+	key, err := hex.DecodeString(signer.PrivateKey)
+	if err != nil || len(key) == 0 {
+		// reported with the signer
+		return nil
+	}
+	switch scheme {
+	case types.EVM:
+		if _, err := crypto.ToECDSA(key); err != nil {
+			return fmt.Errorf("not a secp256k1 private key: %w", err)
+		}
+	case types.Solana:
+		if len(key) != 64 {
+			return fmt.Errorf("not an ed25519 keypair of 64 bytes")
+		}
+	}
+	return nil
+}
+
+// checkGasPolicy checks the settings of the gas strategy.
+func checkGasPolicy(gas facilitator.GasPolicy) error {
+	switch gas.Strategy {
+	case "", facilitator.GasStrategySuggested, facilitator.GasStrategyPercentile:
+	case facilitator.GasStrategyFixed:
+		if gas.FixedPriceGwei <= 0 {
+			return fmt.Errorf("the fixed strategy requires fixedPriceGwei")
+		}
+	case facilitator.GasStrategyOracle:
+		if gas.OracleField == "" {
+			return fmt.Errorf("the oracle strategy requires oracleField")
+		}
+		if err := checkURL(gas.OracleURL, "http", "https"); err != nil {
+			return fmt.Errorf("oracleUrl: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown strategy %q", gas.Strategy)
+	}
+	if gas.Percentile < 0 || gas.Percentile > 100 {
+		return fmt.Errorf("percentile must be between 0 and 100")
+	}
+	if gas.MaxGasCostUSD < 0 || gas.DeferInterval < 0 || gas.ScheduleBelowGwei < 0 {
+		return fmt.Errorf("maxGasCostUsd, deferInterval and scheduleBelowGwei must not be negative")
+	}
+	if gas.DeferOverCeiling && gas.MaxGasCostWei == 0 && gas.MaxGasCostUSD == 0 {
+		return fmt.Errorf("deferOverCeiling requires maxGasCostWei or maxGasCostUsd")
+	}
+	return nil
+}
+
+// checkURL checks that rawURL is an absolute URL with one of the schemes.
+func checkURL(rawURL string, schemes ...string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%q is not a URL", rawURL)
+	}
+	if !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("%q must be a %s URL", rawURL, strings.Join(schemes, " or "))
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkFamily reports the settings a family section like "eip155:*" can't
+// apply to every network it serves.
+func checkFamily(network facilitator.NetworkConfig, signer SignerConfig, report func(format string, args ...any)) {
+	if len(network.RPCURLs) > 0 {
+		report("rpcUrls differ by network, set them in chainRpcUrls")
+	}
+	if network.ChainID != 0 {
+		report("chainId is taken from the networks served")
+	}
+	for _, id := range sortedKeys(network.ChainRPCURLs) {
+		if _, ok := caip.EVMChainID(id); !ok || !caip.Match(network.Network, id) {
+			report("chainRpcUrls: %q is not a network of %s", id, network.Network)
+		}
+		for _, rpcURL := range network.ChainRPCURLs[id] {
+			if err := checkURL(rpcURL, "http", "https", "ws", "wss"); err != nil {
+				report("chainRpcUrls.%q: %v", id, err)
+			}
+		}
+	}
+	for i, asset := range network.Assets {
+		if asset.Address != "" {
+			report("assets[%d]: addresses differ by network, assets of family sections are symbols of the presets", i)
+		}
+	}
+	if network.AssetRegistry != "" {
+		report("assetRegistry differs by network, family sections take their assets from the manifest")
+	}
+	if network.NativeCurrency != "" {
+		report("nativeCurrency differs by network, define chains without presets in customChains")
+	}
+	if network.Limits != (facilitator.SettlementLimits{}) {
+		report("limits are not supported in family sections")
+	}
+	if network.Bundler.URL != "" {
+		report("bundler settlement is not supported in family sections")
+	}
+	if network.Split.Contract != "" {
+		report("split.contract differs by network, family sections forward the shares with the signer")
+	}
+	if signer.Hardware.Wallet != "" {
+		report("hardware wallets can't sign for family sections")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/internal/secrets"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+// mintABI is the function of the test token crediting any account
+const mintABI = `[{"type":"function","name":"mint","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[]}]`
+
+var contractsDir string
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Deploy the mintable EIP-3009 test token and print its network configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := dialChain(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer c.client.Close()
+
+		bytecode, err := buildToken(contractsDir)
+		if err != nil {
+			return err
+		}
+		token, err := c.deploy(cmd.Context(), bytecode)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(`[networks."eip155:%s"]
+rpcUrls = [%q]
+
+[[networks."eip155:%[1]s".assets]]
+symbol = "USDC"
+address = %q
+decimals = 6
+name = "USD Coin"
+version = "2"
+`, c.chainID, rpcURL, token.Hex())
+		return nil
+	},
+}
+
+func init() {
+	deployCmd.Flags().StringVar(&contractsDir, "contracts", filepath.Join("facilitator", "testdata", "contracts"), "Foundry project of the test token")
+
+	cmd.AddCommand(deployCmd)
+}
+
+// chain sends transactions of the funder to the anvil chain.
+type chain struct {
+	client  *ethclient.Client
+	chainID *big.Int
+	key     []byte
+	from    common.Address
+}
+
+func dialChain(ctx context.Context) (*chain, error) {
+	value, err := secrets.New().Resolve(ctx, funderKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode funder key: %w", err)
+	}
+	from, err := evm.GetAddrssFromPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", rpcURL, err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	return &chain{client: client, chainID: chainID, key: key, from: from}, nil
+}
+
+// buildToken compiles the test token with forge and returns its bytecode.
+func buildToken(dir string) ([]byte, error) {
+	out, err := exec.Command("forge", "build", "--root", dir).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("forge build failed: %w: %s", err, out)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out", "MockUSDC.sol", "MockUSDC.json"))
+	if err != nil {
+		return nil, err
+	}
+	var artifact struct {
+		Bytecode struct {
+			Object string `json:"object"`
+		} `json:"bytecode"`
+	}
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("failed to decode artifact: %w", err)
+	}
+	return hex.DecodeString(strings.TrimPrefix(artifact.Bytecode.Object, "0x"))
+}
+
+func (c *chain) deploy(ctx context.Context, bytecode []byte) (common.Address, error) {
+	nonce, err := c.client.PendingNonceAt(ctx, c.from)
+	if err != nil {
+		return common.Address{}, err
+	}
+	hash, err := c.send(ctx, nil, bytecode, nonce)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return bind.WaitDeployed(ctx, c.client, hash)
+}
+
+// fund mints the amount of the token to every payer.
+func (c *chain) fund(ctx context.Context, token common.Address, payers []common.Address, amount *big.Int) error {
+	mint, err := abi.JSON(strings.NewReader(mintABI))
+	if err != nil {
+		return err
+	}
+	nonce, err := c.client.PendingNonceAt(ctx, c.from)
+	if err != nil {
+		return err
+	}
+
+	var last common.Hash
+	for i, payer := range payers {
+		data, err := mint.Pack("mint", payer, amount)
+		if err != nil {
+			return err
+		}
+		if last, err = c.send(ctx, &token, data, nonce+uint64(i)); err != nil {
+			return fmt.Errorf("failed to mint to %s: %w", payer, err)
+		}
+	}
+	// transactions of an account are mined in nonce order
+	receipt, err := bind.WaitMined(ctx, c.client, last)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != ethTypes.ReceiptStatusSuccessful {
+		return fmt.Errorf("mint transaction %s reverted", last)
+	}
+	return nil
+}
+
+func (c *chain) send(ctx context.Context, to *common.Address, data []byte, nonce uint64) (common.Hash, error) {
+	gasPrice, err := c.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	gas, err := c.client.EstimateGas(ctx, ethereum.CallMsg{From: c.from, To: to, Data: data})
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	tx := ethTypes.NewTx(&ethTypes.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: gas, To: to, Data: data})
+	signed, err := evm.ToGethSigner(evm.NewRawPrivateSigner(c.key), c.chainID)(c.from, tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := c.client.SendTransaction(ctx, signed); err != nil {
+		return common.Hash{}, err
+	}
+	return signed.Hash(), nil
+}
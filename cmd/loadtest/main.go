@@ -0,0 +1,42 @@
+// Command x402-loadtest measures how a facilitator performs under load. It
+// pays with synthetic EIP-3009 authorizations of payers funded on a local
+// anvil chain, verifying and settling them with many concurrent clients, and
+// reports the latencies and errors seen.
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/version"
+)
+
+var cmd = &cobra.Command{
+	Use:           "x402-loadtest",
+	Short:         "Load test x402 facilitators with payments on a local anvil chain",
+	Version:       version.Get().String(),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var (
+	rpcURL string
+	// funderKey pays for deployments and mints, anvil's first development account by default
+	funderKey string
+)
+
+// anvilKey is the first of anvil's default development accounts
+const anvilKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+func init() {
+	fs := cmd.PersistentFlags()
+
+	fs.StringVar(&rpcURL, "rpc", "http://127.0.0.1:8545", "RPC endpoint of the anvil chain")
+	fs.StringVar(&funderKey, "funder", anvilKey, "Private key deploying the token and minting to payers, or a reference like env:FUNDER_KEY")
+}
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		logging.For(logging.Server).Fatal().Err(err).Msg("Failed to execute command")
+	}
+}
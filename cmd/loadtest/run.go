@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+
+	"github.com/gosuda/x402-facilitator/api/client"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+var (
+	url          string
+	token        string
+	tokenName    string
+	tokenVersion string
+	payTo        string
+	amount       int64
+
+	requests    int
+	concurrency int
+	payerCount  int
+	verifyOnly  bool
+
+	hmacKeyID  string
+	hmacSecret string
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Verify and settle payments of funded payers concurrently and report latencies and errors",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !common.IsHexAddress(token) {
+			return fmt.Errorf("--token %q is not an address, deploy one with the deploy command", token)
+		}
+		if !common.IsHexAddress(payTo) {
+			return fmt.Errorf("--pay-to %q is not an address", payTo)
+		}
+		if requests < 1 || concurrency < 1 || payerCount < 1 || amount < 1 {
+			return fmt.Errorf("--requests, --concurrency, --payers and --amount must be positive")
+		}
+		return run(cmd.Context())
+	},
+}
+
+func init() {
+	fs := runCmd.Flags()
+	fs.StringVarP(&url, "url", "u", "http://localhost:9090", "Base URL of the facilitator server")
+	fs.StringVar(&token, "token", "", "Address of the EIP-3009 token, which must have mint(address,uint256)")
+	fs.StringVar(&tokenName, "token-name", "USD Coin", "EIP-712 domain name of the token")
+	fs.StringVar(&tokenVersion, "token-version", "2", "EIP-712 domain version of the token")
+	fs.StringVar(&payTo, "pay-to", "0x00000000000000000000000000000000000000b0", "Recipient of the payments")
+	fs.Int64Var(&amount, "amount", 10_000, "Amount of every payment in the token's smallest unit")
+	fs.IntVarP(&requests, "requests", "r", 1000, "Number of payments")
+	fs.IntVarP(&concurrency, "concurrency", "c", 16, "Number of payments in flight at once")
+	fs.IntVar(&payerCount, "payers", 16, "Number of payers, the facilitator serializes settlements of a payer")
+	fs.BoolVar(&verifyOnly, "verify-only", false, "Only verify payments, settling none")
+	fs.StringVar(&hmacKeyID, "hmac-key-id", "", "Key ID of the shared secret requests are signed with")
+	fs.StringVar(&hmacSecret, "hmac-secret", "", "Shared secret requests are signed with")
+
+	cmd.AddCommand(runCmd)
+}
+
+// payer signs authorizations of a funded account.
+type payer struct {
+	address common.Address
+	signer  types.Signer
+}
+
+func newPayer() (payer, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return payer{}, err
+	}
+	address, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	if err != nil {
+		return payer{}, err
+	}
+	return payer{address: address, signer: evm.NewRawPrivateSigner(key.Serialize())}, nil
+}
+
+// payment creates a payment of a fresh authorization to the recipient.
+func (p payer) payment(network string, domain *evm.DomainConfig) (*types.PaymentPayload, *types.PaymentRequirements, error) {
+	auth := evm.NewAuthorization(p.address.Hex(), payTo, big.NewInt(amount))
+	signature, err := evm.SignEip3009(auth, domain, p.signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	evmPayload, err := json.Marshal(&evm.EVMPayload{Signature: signature, Authorization: auth})
+	if err != nil {
+		return nil, nil, err
+	}
+	payload := &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      string(types.EVM),
+		Network:     network,
+		Payload:     evmPayload,
+	}
+	requirements := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           network,
+		MaxAmountRequired: big.NewInt(amount).String(),
+		PayTo:             payTo,
+		Asset:             domain.VerifyingContract.Hex(),
+	}
+	return payload, requirements, nil
+}
+
+func run(ctx context.Context) error {
+	chain, err := dialChain(ctx)
+	if err != nil {
+		return err
+	}
+	defer chain.client.Close()
+
+	payers := make([]payer, payerCount)
+	addresses := make([]common.Address, payerCount)
+	for i := range payers {
+		if payers[i], err = newPayer(); err != nil {
+			return err
+		}
+		addresses[i] = payers[i].address
+	}
+	// every payer pays for its share of the payments
+	perPayer := new(big.Int).Mul(big.NewInt(amount), big.NewInt(int64((requests+payerCount-1)/payerCount)))
+	logging.For(logging.Balance).Info().Int("payers", payerCount).Str("token", token).Msg("Funding payers")
+	if err := chain.fund(ctx, common.HexToAddress(token), addresses, perPayer); err != nil {
+		return fmt.Errorf("failed to fund payers: %w", err)
+	}
+
+	c, err := client.NewClient(url)
+	if err != nil {
+		return err
+	}
+	c.HTTPClient = &http.Client{Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: concurrency,
+	}}
+	if hmacSecret != "" {
+		c.HMAC = &client.HMACCredentials{KeyID: hmacKeyID, Secret: hmacSecret}
+	}
+
+	network := caip.FromEVM(chain.chainID)
+	domain := evm.NewDomainConfig(tokenName, tokenVersion, chain.chainID, common.HexToAddress(token).Hex())
+	results := newStats()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	logging.For(logging.Server).Info().Int("requests", requests).Int("concurrency", concurrency).Str("network", network).Msg("Starting load test")
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pay(ctx, c, results, payers[i%len(payers)], network, domain)
+			}
+		}()
+	}
+feed:
+	for i := range requests {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results.report(os.Stdout, time.Since(start))
+}
+
+// pay verifies and settles a payment of the payer, recording the outcomes.
+func pay(ctx context.Context, c *client.Client, results *stats, p payer, network string, domain *evm.DomainConfig) {
+	payload, requirements, err := p.payment(network, domain)
+	if err != nil {
+		results.record(opVerify, 0, "signing: "+err.Error())
+		return
+	}
+
+	start := time.Now()
+	verified, err := c.Verify(ctx, payload, requirements)
+	switch {
+	case err != nil:
+		results.record(opVerify, time.Since(start), classify(err))
+		return
+	case !verified.IsValid:
+		results.record(opVerify, time.Since(start), "invalid: "+verified.InvalidReason)
+		return
+	}
+	results.record(opVerify, time.Since(start), "")
+	if verifyOnly {
+		return
+	}
+
+	start = time.Now()
+	settled, err := c.Settle(ctx, payload, requirements)
+	switch {
+	case err != nil:
+		results.record(opSettle, time.Since(start), classify(err))
+	case !settled.Success:
+		results.record(opSettle, time.Since(start), "failed: "+settled.Error)
+	default:
+		results.record(opSettle, time.Since(start), "")
+	}
+}
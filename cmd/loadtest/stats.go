@@ -0,0 +1,119 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	opVerify = "verify"
+	opSettle = "settle"
+)
+
+// statusPattern finds the HTTP status in errors of the API client
+var statusPattern = regexp.MustCompile(`failed: status (\d{3})`)
+
+// stats collects the latencies of successful requests and counts failures by kind.
+type stats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	failures  map[string]map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: make(map[string][]time.Duration),
+		failures:  make(map[string]map[string]int),
+	}
+}
+
+// record adds the outcome of a request of the operation, failure is empty if it succeeded.
+func (s *stats) record(op string, latency time.Duration, failure string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failure == "" {
+		s.latencies[op] = append(s.latencies[op], latency)
+		return
+	}
+	if s.failures[op] == nil {
+		s.failures[op] = make(map[string]int)
+	}
+	s.failures[op][failure]++
+}
+
+// report writes the throughput, the latencies of every operation and the failures.
+func (s *stats) report(w io.Writer, elapsed time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Elapsed %s\n\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintln(tw, "OPERATION\tOK\tFAILED\tRATE\tP50\tP99\tMAX")
+	for _, op := range []string{opVerify, opSettle} {
+		latencies := slices.Clone(s.latencies[op])
+		failed := 0
+		for _, n := range s.failures[op] {
+			failed += n
+		}
+		if len(latencies) == 0 && failed == 0 {
+			continue
+		}
+		slices.Sort(latencies)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f/s\t%s\t%s\t%s\n", op, len(latencies), failed,
+			float64(len(latencies))/elapsed.Seconds(),
+			percentile(latencies, 50), percentile(latencies, 99), percentile(latencies, 100))
+	}
+
+	type failure struct {
+		op, kind string
+		count    int
+	}
+	var failures []failure
+	for _, op := range slices.Sorted(maps.Keys(s.failures)) {
+		for kind, count := range s.failures[op] {
+			failures = append(failures, failure{op, kind, count})
+		}
+	}
+	if len(failures) > 0 {
+		slices.SortFunc(failures, func(a, b failure) int {
+			return cmp.Or(b.count-a.count, cmp.Compare(a.op, b.op), cmp.Compare(a.kind, b.kind))
+		})
+		fmt.Fprintln(tw, "\nOPERATION\tERROR\tCOUNT")
+		for _, f := range failures {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", f.op, f.kind, f.count)
+		}
+	}
+	return tw.Flush()
+}
+
+// percentile returns the latency p percent of the sorted latencies don't
+// exceed, by the nearest rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(len(sorted))*p/100)) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// classify names the kind of a request error, the response status if the
+// server answered, so errors of many requests add up.
+func classify(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return "timeout"
+	}
+	if m := statusPattern.FindStringSubmatch(err.Error()); m != nil {
+		return "HTTP " + m[1]
+	}
+	return "transport error"
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	require.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	require.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	require.Equal(t, time.Millisecond, percentile(latencies[:1], 99))
+	require.Zero(t, percentile(nil, 50))
+}
+
+func TestClassify(t *testing.T) {
+	require.Equal(t, "timeout", classify(fmt.Errorf("post: %w", context.DeadlineExceeded)))
+	require.Equal(t, "HTTP 503", classify(errors.New(`POST /settle failed: status 503, body: {"message":"standby"}`)))
+	require.Equal(t, "transport error", classify(errors.New("connection refused")))
+}
+
+func TestReport(t *testing.T) {
+	s := newStats()
+	for i := 1; i <= 4; i++ {
+		s.record(opVerify, time.Duration(i)*time.Millisecond, "")
+	}
+	s.record(opSettle, 10*time.Millisecond, "")
+	s.record(opVerify, time.Millisecond, "invalid: insufficient_funds")
+	s.record(opSettle, time.Second, "HTTP 503")
+	s.record(opSettle, time.Second, "HTTP 503")
+
+	var out bytes.Buffer
+	require.NoError(t, s.report(&out, 2*time.Second))
+	require.Equal(t, `Elapsed 2s
+
+OPERATION  OK  FAILED  RATE   P50   P99   MAX
+verify     4   1       2.0/s  2ms   4ms   4ms
+settle     1   2       0.5/s  10ms  10ms  10ms
+
+OPERATION  ERROR                        COUNT
+settle     HTTP 503                     2
+verify     invalid: insufficient_funds  1
+`, out.String())
+}
@@ -0,0 +1,185 @@
+// Package conformance runs x402 test vectors against a facilitator over HTTP.
+// A vector derives a request from a valid payment, e.g. by letting it expire or
+// corrupting its signature, and states the responses of /verify and /settle.
+// Scheme backends only provide signed payments, so every backend is held to
+// the same vectors:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, server.URL, backend)
+//	}
+package conformance
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+//go:embed vectors/*.json
+var vectorFiles embed.FS
+
+// Backend creates the payments of a scheme the vectors are derived from.
+type Backend interface {
+	// Scheme selects the vectors of the backend, e.g. "evm"
+	Scheme() string
+	// Payment returns a payment signed by a payer holding enough funds, in the
+	// wire format of x402 clients, and the requirements it pays
+	Payment(t *testing.T, params Params) (*types.PaymentPayload, *types.PaymentRequirements)
+}
+
+// Params shape the payment a vector starts from.
+type Params struct {
+	// Seconds from now the authorization becomes valid, valid since the epoch if 0
+	ValidAfter int64 `json:"validAfter,omitempty"`
+	// Seconds from now the authorization expires, the backend's default if 0
+	ValidBefore int64 `json:"validBefore,omitempty"`
+}
+
+// Vector is a test case.
+type Vector struct {
+	Name string `json:"name"`
+	// Scheme of the backends the vector applies to, all if empty
+	Scheme string `json:"scheme,omitempty"`
+	// Payment the request is derived from
+	Payment Params `json:"payment"`
+	// Whether the payment is settled before the requests of the vector, e.g. to replay it
+	Settled bool `json:"settled,omitempty"`
+	// JSON merge patch (RFC 7396) applied to the version 1 request body
+	Patch json.RawMessage `json:"patch,omitempty"`
+	// Expected responses by endpoint, "verify" and "settle", sent in that order
+	Expect map[string]Expectation `json:"expect"`
+}
+
+// Expectation is the expected response of an endpoint.
+type Expectation struct {
+	Status int `json:"status"`
+	// Fields the response body must hold, other fields are ignored
+	Body map[string]any `json:"body,omitempty"`
+}
+
+// endpoints are the endpoints vectors call, in order
+var endpoints = []string{"verify", "settle"}
+
+// Vectors returns the bundled vectors that apply to the scheme.
+func Vectors(scheme string) ([]Vector, error) {
+	files, err := fs.Glob(vectorFiles, "vectors/*.json")
+	if err != nil {
+		return nil, err
+	}
+	var vectors []Vector
+	for _, file := range files {
+		data, err := vectorFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var fileVectors []Vector
+		if err := json.Unmarshal(data, &fileVectors); err != nil {
+			return nil, fmt.Errorf("%s: %w", path.Base(file), err)
+		}
+		for _, v := range fileVectors {
+			if v.Scheme == "" || v.Scheme == scheme {
+				vectors = append(vectors, v)
+			}
+		}
+	}
+	return vectors, nil
+}
+
+// Run runs the vectors of the backend's scheme against the facilitator at baseURL.
+func Run(t *testing.T, baseURL string, backend Backend) {
+	vectors, err := Vectors(backend.Scheme())
+	require.NoError(t, err)
+	require.NotEmpty(t, vectors, "no vectors for scheme %s", backend.Scheme())
+	base, err := url.Parse(baseURL)
+	require.NoError(t, err)
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			payload, req := backend.Payment(t, v.Payment)
+			body, err := json.Marshal(types.PaymentVerifyRequest{
+				X402Version:         int(types.X402VersionV1),
+				PaymentHeader:       *payload,
+				PaymentRequirements: *req,
+			})
+			require.NoError(t, err)
+			if v.Settled {
+				status, resp := post(t, base.JoinPath("settle"), body)
+				require.Equal(t, http.StatusOK, status, "settling the payment first: %v", resp)
+				require.Equal(t, true, resp["success"], "settling the payment first: %v", resp)
+			}
+			if len(v.Patch) > 0 {
+				body, err = mergePatch(body, v.Patch)
+				require.NoError(t, err)
+			}
+
+			for _, endpoint := range endpoints {
+				expect, ok := v.Expect[endpoint]
+				if !ok {
+					continue
+				}
+				status, resp := post(t, base.JoinPath(endpoint), body)
+				require.Equal(t, expect.Status, status, "%s answered %v", endpoint, resp)
+				for field, value := range expect.Body {
+					require.Equal(t, value, resp[field], "%s: field %s of %v", endpoint, field, resp)
+				}
+			}
+		})
+	}
+}
+
+// post sends the body and decodes the JSON object it is answered with.
+func post(t *testing.T, endpoint *url.URL, body []byte) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.Post(endpoint.String(), "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded), "%s answered %s", endpoint.Path, data)
+	return resp.StatusCode, decoded
+}
+
+// mergePatch applies the JSON merge patch to the document.
+func mergePatch(doc, patch []byte) ([]byte, error) {
+	var target, changes any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	return json.Marshal(merge(target, changes))
+}
+
+// merge implements RFC 7396: objects are merged recursively, null removes a
+// member and every other value replaces the target.
+func merge(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]any)
+	if !ok {
+		object = make(map[string]any)
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = merge(object[key], value)
+	}
+	return object
+}
@@ -0,0 +1,109 @@
+package conformance_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/api"
+	"github.com/gosuda/x402-facilitator/conformance"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	evmNetwork = "eip155:84532"
+	evmChain   = "base-sepolia"
+	evmToken   = "USDC"
+	evmPayTo   = "0x00000000000000000000000000000000000000b0"
+	evmAmount  = 10_000
+)
+
+// evmBackend pays with EIP-3009 authorizations of fresh payers funded on a mock chain.
+type evmBackend struct {
+	chain *mock.EVMSigner
+}
+
+func (b *evmBackend) Scheme() string {
+	return string(types.EVM)
+}
+
+func (b *evmBackend) Payment(t *testing.T, params conformance.Params) (*types.PaymentPayload, *types.PaymentRequirements) {
+	t.Helper()
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	domain := evm.GetDomainConfig(evmChain, evmToken)
+	b.chain.SetBalance(domain.VerifyingContract.Hex(), payer.Hex(), big.NewInt(evmAmount))
+
+	auth := evm.NewAuthorization(payer.Hex(), evmPayTo, big.NewInt(evmAmount))
+	now := time.Now().Unix()
+	if params.ValidAfter != 0 {
+		auth.ValidAfter = big.NewInt(now + params.ValidAfter)
+	}
+	if params.ValidBefore != 0 {
+		auth.ValidBefore = big.NewInt(now + params.ValidBefore)
+	}
+	signature, err := evm.SignEip3009(auth, domain, evm.NewRawPrivateSigner(key.Serialize()))
+	require.NoError(t, err)
+
+	// the string encoded payload of x402 clients
+	wire, err := json.Marshal(sdk.ExactEIP3009Payload{
+		Signature: "0x" + signature,
+		Authorization: sdk.ExactEIP3009Authorization{
+			From:        auth.From.Hex(),
+			To:          auth.To.Hex(),
+			Value:       auth.Value.String(),
+			ValidAfter:  auth.ValidAfter.String(),
+			ValidBefore: auth.ValidBefore.String(),
+			Nonce:       hexutil.Encode(auth.Nonce[:]),
+		},
+	})
+	require.NoError(t, err)
+
+	payload := &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      sdk.SchemeExact,
+		Network:     evmNetwork,
+		Payload:     wire,
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            sdk.SchemeExact,
+		Network:           evmNetwork,
+		MaxAmountRequired: big.NewInt(evmAmount).String(),
+		PayTo:             evmPayTo,
+		Asset:             domain.VerifyingContract.Hex(),
+		Resource:          "https://example.com/resource",
+		MaxTimeoutSeconds: types.DefaultMaxTimeoutSeconds,
+	}
+	return payload, req
+}
+
+func TestEVMConformance(t *testing.T) {
+	chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+	config := facilitator.NetworkConfig{Network: evmNetwork}
+	require.NoError(t, config.Normalize())
+	f, err := facilitator.NewEVMFacilitatorWithSigner(config, chain)
+	require.NoError(t, err)
+
+	registry := facilitator.NewRegistry()
+	require.NoError(t, registry.Register(config, f))
+	settlements := settlement.NewManager(registry, store.NewMemory())
+	t.Cleanup(settlements.Close)
+	srv := httptest.NewServer(api.NewServer(registry, settlements, nil))
+	t.Cleanup(srv.Close)
+
+	conformance.Run(t, srv.URL, &evmBackend{chain: chain})
+}
@@ -0,0 +1,102 @@
+// Package diagnostics collects the diagnostic trail of a settlement in its
+// context: the simulation and its revert data, gas estimates, the signed
+// transaction, RPC errors and retries. The trail of a settlement that fails is
+// stored with it, so support can investigate it after the fact without
+// reproducing it.
+package diagnostics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxEntries bounds a trail, later entries are dropped
+const maxEntries = 100
+
+// Kind classifies the entries of a trail
+type Kind string
+
+const (
+	// KindSimulation is the result of simulating the settlement transaction
+	KindSimulation Kind = "simulation"
+	// KindGasEstimate is the gas price and limit a transaction was sent with
+	KindGasEstimate Kind = "gas_estimate"
+	// KindSignedTransaction is the raw signed transaction that was broadcast
+	KindSignedTransaction Kind = "signed_transaction"
+	// KindRetry is an RPC call retried after a transient error
+	KindRetry Kind = "retry"
+	// KindRPCError is an RPC call that failed for good
+	KindRPCError Kind = "rpc_error"
+	// KindDeferral is a settlement waiting for gas fees to drop below the ceiling of its network
+	KindDeferral Kind = "deferral"
+	// KindReceipt is the outcome of a mined transaction
+	KindReceipt Kind = "receipt"
+	// KindError is the error a settlement failed with
+	KindError Kind = "error"
+)
+
+// Entry is a step of a trail. Data holds its details, e.g. the revert data or
+// the raw transaction, hex encoded.
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Kind    Kind              `json:"kind"`
+	Message string            `json:"message,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+// Trail collects the entries of a settlement. It is safe for concurrent use.
+type Trail struct {
+	mu      sync.Mutex
+	entries []Entry
+	dropped bool
+}
+
+// New returns an empty trail.
+func New() *Trail {
+	return &Trail{}
+}
+
+// Add appends an entry, unless the trail is full.
+func (t *Trail) Add(kind Kind, message string, data map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.entries) >= maxEntries {
+		t.dropped = true
+		return
+	}
+	t.entries = append(t.entries, Entry{Time: time.Now(), Kind: kind, Message: message, Data: data})
+}
+
+// Entries returns the entries in the order they were added.
+func (t *Trail) Entries() []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]Entry, len(t.entries), len(t.entries)+1)
+	copy(entries, t.entries)
+	if t.dropped {
+		entries = append(entries, Entry{Kind: KindError, Message: "trail is full, later entries were dropped"})
+	}
+	return entries
+}
+
+type contextKey struct{}
+
+// With returns a copy of ctx that records to the trail.
+func With(ctx context.Context, trail *Trail) context.Context {
+	return context.WithValue(ctx, contextKey{}, trail)
+}
+
+// From returns the trail of ctx, nil if it has none.
+func From(ctx context.Context) *Trail {
+	trail, _ := ctx.Value(contextKey{}).(*Trail)
+	return trail
+}
+
+// Record adds an entry to the trail of ctx. Contexts without a trail, e.g. of
+// verifications, record nothing.
+func Record(ctx context.Context, kind Kind, message string, data map[string]string) {
+	if trail := From(ctx); trail != nil {
+		trail.Add(kind, message, data)
+	}
+}
@@ -0,0 +1,36 @@
+package diagnostics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	// contexts without a trail record nothing
+	Record(context.Background(), KindError, "ignored", nil)
+
+	trail := New()
+	ctx := With(context.Background(), trail)
+	require.Same(t, trail, From(ctx))
+	Record(ctx, KindSimulation, "reverted", map[string]string{"data": "0x08c379a0"})
+	Record(ctx, KindError, "failed", nil)
+
+	entries := trail.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, KindSimulation, entries[0].Kind)
+	require.Equal(t, "0x08c379a0", entries[0].Data["data"])
+	require.False(t, entries[0].Time.IsZero())
+	require.Equal(t, "failed", entries[1].Message)
+
+	t.Run("full trails drop later entries", func(t *testing.T) {
+		trail := New()
+		for range maxEntries + 5 {
+			trail.Add(KindRetry, "retry", nil)
+		}
+		entries := trail.Entries()
+		require.Len(t, entries, maxEntries+1)
+		require.Equal(t, KindError, entries[maxEntries].Kind)
+	})
+}
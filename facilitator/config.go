@@ -0,0 +1,379 @@
+package facilitator
+
+import (
+	"cmp"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/internal/rpcretry"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+// DefaultSigner is the signer used by networks that don't reference one explicitly
+const DefaultSigner = "default"
+
+// NetworkConfig configures the facilitator of a single network.
+type NetworkConfig struct {
+	// CAIP-2 identifier of the network (e.g. "eip155:8453"), taken from the config section name
+	Network string `mapstructure:"-"`
+	// Scheme of the network, derived from the CAIP-2 namespace if empty
+	Scheme types.Scheme `mapstructure:"scheme"`
+	// RPC endpoints of the network, tried in order
+	RPCURLs []string `mapstructure:"rpcUrls"`
+	// Chain ID of the network, derived from the CAIP-2 reference if empty
+	ChainID int64 `mapstructure:"chainId"`
+	// Name of the signer paying for settlements on this network
+	Signer string `mapstructure:"signer"`
+	// Assets accepted on this network. Network presets are used if empty
+	Assets []AssetConfig `mapstructure:"assets"`
+	// Asset registry contract listing the accepted assets, which replace the
+	// configured ones once read, EVM networks only. See package assetlist
+	AssetRegistry string `mapstructure:"assetRegistry"`
+	// Number of confirmations after which a settlement is reported as confirmed
+	Confirmations uint64 `mapstructure:"confirmations"`
+	// Gas policy for settlement transactions
+	Gas GasPolicy `mapstructure:"gas"`
+	// Limits applied to payments on this network
+	Policy PaymentPolicy `mapstructure:"policy"`
+	// Caps on the settlements submitted on this network
+	Limits SettlementLimits `mapstructure:"limits"`
+	// Settles through an ERC-4337 bundler if its URL is set, EVM networks only
+	Bundler BundlerConfig `mapstructure:"bundler"`
+	// Gas balance of the signers below which the low balance hooks fire
+	Balance BalanceConfig `mapstructure:"balance"`
+	// Reuse of chain reads, EVM networks only
+	Cache CacheConfig `mapstructure:"cache"`
+	// Retries of RPC calls failing for transient reasons, EVM networks only
+	Retry rpcretry.Config `mapstructure:"retry"`
+	// Reads the chain state of a verification in a single JSON-RPC batch, EVM networks only
+	BatchReads bool `mapstructure:"batchReads"`
+	// Accepts payments in the native currency, as transfers pre-signed by the payer, EVM networks only
+	AcceptNative bool `mapstructure:"acceptNative"`
+	// Accepts payments divided between recipients, EVM networks only
+	Split SplitConfig `mapstructure:"split"`
+	// Keeps verifying payments while the RPC endpoints can't be reached, checking
+	// only their signature and terms, and rejects settlements as unavailable
+	// until they recover, EVM networks only
+	VerifyOnOutage bool `mapstructure:"verifyOnOutage"`
+	// Connects to the RPC endpoints on the first call needing them instead of
+	// at startup, and again after failing to, EVM networks only. New blocks
+	// aren't subscribed to, settlements poll for their receipts
+	LazyDial bool `mapstructure:"lazyDial"`
+	// RPC endpoints by CAIP-2 identifier of the chains a family section like
+	// "eip155:*" serves, besides the chains with a preset endpoint
+	ChainRPCURLs map[string][]string `mapstructure:"chainRpcUrls"`
+	// How long an authorization must remain valid to be accepted or broadcast,
+	// DefaultExpiryMargin if 0. Queued settlements expire once less remains
+	ExpiryMargin time.Duration `mapstructure:"expiryMargin"`
+	// Symbol of the native currency, the preset's or "ETH" if empty, EVM networks only
+	NativeCurrency string `mapstructure:"nativeCurrency"`
+	// Submits EIP-1559 dynamic fee transactions instead of legacy ones, EVM
+	// networks only. Their fee cap is the gas price of the gas policy
+	EIP1559 bool `mapstructure:"eip1559"`
+}
+
+// CustomChain defines an EVM chain without network presets, like a private
+// chain or an appchain, so it is served without changes to the presets. The
+// chain is served like a network section of its identifier, and such a
+// section may configure the rest, e.g. its signer or gas policy. Fields the
+// section sets take precedence.
+type CustomChain struct {
+	// Chain ID of the chain
+	ChainID int64 `mapstructure:"chainId"`
+	// CAIP-2 identifier of the chain, "eip155:<chainId>" if empty
+	Network string `mapstructure:"network"`
+	// RPC endpoints of the chain, tried in order
+	RPCURLs []string `mapstructure:"rpcUrls"`
+	// Symbol of the native currency, "ETH" if empty
+	NativeCurrency string `mapstructure:"nativeCurrency"`
+	// Whether the chain accepts EIP-1559 dynamic fee transactions, legacy
+	// transactions are submitted otherwise
+	EIP1559 bool `mapstructure:"eip1559"`
+	// Assets accepted on the chain, there are no presets to fall back to
+	Assets []AssetConfig `mapstructure:"assets"`
+}
+
+// ID returns the CAIP-2 identifier of the chain.
+func (c CustomChain) ID() string {
+	if c.Network != "" {
+		return c.Network
+	}
+	return caip.FromEVM(big.NewInt(c.ChainID))
+}
+
+// Section completes the network section of the chain with the definition,
+// section is the zero value if there is none.
+func (c CustomChain) Section(section NetworkConfig) NetworkConfig {
+	section.Network = c.ID()
+	section.Scheme = cmp.Or(section.Scheme, types.EVM)
+	section.ChainID = cmp.Or(section.ChainID, c.ChainID)
+	if len(section.RPCURLs) == 0 {
+		section.RPCURLs = c.RPCURLs
+	}
+	section.NativeCurrency = cmp.Or(section.NativeCurrency, c.NativeCurrency)
+	section.EIP1559 = section.EIP1559 || c.EIP1559
+	if len(section.Assets) == 0 {
+		section.Assets = c.Assets
+	}
+	return section
+}
+
+// IsFamily reports whether the section configures a family of networks like
+// "eip155:*" instead of a single one.
+func (c NetworkConfig) IsFamily() bool {
+	return caip.IsFamily(c.Network)
+}
+
+// ForChain returns the configuration of a chain served by the family
+// section, with the endpoints of chainRpcUrls or the presets of the chain.
+// ok is false if the chain isn't in the family or has no endpoints. Chains of
+// families dial lazily, they are created on the request routed to them.
+func (c NetworkConfig) ForChain(network string) (NetworkConfig, bool) {
+	network = caip.Normalize(network)
+	if !c.IsFamily() || !caip.Match(c.Network, network) {
+		return NetworkConfig{}, false
+	}
+	chainID, ok := caip.EVMChainID(network)
+	if !ok {
+		return NetworkConfig{}, false
+	}
+	urls := c.ChainRPCURLs[network]
+	if len(urls) == 0 {
+		info := evm.GetChainInfo(evm.GetChainName(chainID))
+		if info == nil || info.DefaultUrl == "" {
+			return NetworkConfig{}, false
+		}
+	}
+	chain := c
+	chain.Network = network
+	chain.ChainID = 0
+	chain.RPCURLs = urls
+	chain.ChainRPCURLs = nil
+	chain.LazyDial = true
+	return chain, true
+}
+
+// DefaultExpiryMargin is the time a settlement transaction is given to be
+// mined before the authorization it carries expires
+const DefaultExpiryMargin = 6 * time.Second
+
+// DefaultGasDeferInterval is how often settlements deferred over the gas
+// ceiling of their network check the cost again
+const DefaultGasDeferInterval = 15 * time.Second
+
+// AssetConfig describes a token accepted for payments. On EVM networks,
+// tokens the network presets know only need their symbol or address, other
+// fields override the preset. Forks of tokens deployed with a non-standard
+// EIP-712 domain set the name and version they sign with. Other tokens may
+// be configured by their address alone, the fields left empty are read from
+// the token contract, see TokenResolver.
+type AssetConfig struct {
+	Symbol string `mapstructure:"symbol"`
+	// Contract of the token, the preset's if empty
+	Address string `mapstructure:"address"`
+	// Decimals of the token, the preset's or the contract's if 0
+	Decimals int `mapstructure:"decimals"`
+	// EIP-712 domain name of the token, the preset's if empty
+	Name string `mapstructure:"name"`
+	// EIP-712 domain version of the token, the preset's if empty
+	Version string `mapstructure:"version"`
+	// How payers authorize transfers of the token on EVM networks: "eip3009"
+	// (default) or "eip2612" for tokens with permit but without transferWithAuthorization
+	TransferMethod string `mapstructure:"transferMethod"`
+	// Simulate the transfer of EVM payments when verifying them and reject
+	// those whose recipient would receive less than authorized, for tokens
+	// taking a fee on transfers. Requires an RPC endpoint serving eth_simulateV1
+	CheckTransferFee bool `mapstructure:"checkTransferFee"`
+	// Fee in basis points of the value the check accepts, 0 rejects any fee
+	TransferFeeToleranceBps int `mapstructure:"transferFeeToleranceBps"`
+}
+
+// GasPolicy controls the gas parameters of settlement transactions.
+type GasPolicy struct {
+	// How the gas price is chosen: suggested (default), fixed, percentile or oracle
+	Strategy string `mapstructure:"strategy"`
+	// Gas price in gwei of the fixed strategy
+	FixedPriceGwei float64 `mapstructure:"fixedPriceGwei"`
+	// Percentile of the priority fees paid in recent blocks the percentile strategy adds to the base fee, 0 means 50
+	Percentile float64 `mapstructure:"percentile"`
+	// Recent blocks the percentile strategy samples, 0 means 20
+	FeeHistoryBlocks uint64 `mapstructure:"feeHistoryBlocks"`
+	// URL of the gas price API of the oracle strategy, answering with JSON
+	OracleURL string `mapstructure:"oracleUrl"`
+	// Dot separated path of the gas price in gwei in the answer of the oracle, e.g. "result.ProposeGasPrice"
+	OracleField string `mapstructure:"oracleField"`
+	// Upper bound of the gas price in gwei, 0 means unbounded
+	MaxGasPriceGwei float64 `mapstructure:"maxGasPriceGwei"`
+	// Multiplier applied to the suggested gas price, 0 means 1
+	PriceMultiplier float64 `mapstructure:"priceMultiplier"`
+	// Fixed gas limit, 0 means the gas limit is estimated
+	GasLimit uint64 `mapstructure:"gasLimit"`
+	// Upper bound of the estimated cost of a settlement in atomic units of the
+	// native currency, e.g. wei, 0 means unbounded
+	MaxGasCostWei uint64 `mapstructure:"maxGasCostWei"`
+	// Upper bound of the estimated cost of a settlement in USD, 0 means unbounded. Requires a price oracle
+	MaxGasCostUSD float64 `mapstructure:"maxGasCostUsd"`
+	// Settlements over a ceiling wait for fees to drop, until their
+	// authorization expires or the request ends, instead of being rejected
+	DeferOverCeiling bool `mapstructure:"deferOverCeiling"`
+	// How often deferred settlements check the cost again, and scheduled
+	// settlements the gas price, DefaultGasDeferInterval if 0
+	DeferInterval time.Duration `mapstructure:"deferInterval"`
+	// Gas price in gwei at or below which scheduled settlements are submitted
+	// within their window, 0 submits them as soon as the window opens
+	ScheduleBelowGwei float64 `mapstructure:"scheduleBelowGwei"`
+}
+
+// hasCeiling reports whether the cost of settlements is bounded.
+func (g GasPolicy) hasCeiling() bool {
+	return g.MaxGasCostWei > 0 || g.MaxGasCostUSD > 0
+}
+
+// ScheduleThreshold returns the gas price in wei scheduled settlements wait
+// for, nil if they don't wait for one.
+func (g GasPolicy) ScheduleThreshold() *big.Int {
+	if g.ScheduleBelowGwei <= 0 {
+		return nil
+	}
+	return gweiToWei(g.ScheduleBelowGwei)
+}
+
+// PaymentPolicy limits the payments a network accepts.
+type PaymentPolicy struct {
+	// Upper bound of the USD value of a single payment, 0 means unbounded. Requires a price oracle
+	MaxAmountUSD float64 `mapstructure:"maxAmountUsd"`
+	// Only pays recipients that registered with a proof of owning their address. Requires a recipient registry
+	RegisteredRecipients bool `mapstructure:"registeredRecipients"`
+}
+
+// SettlementLimits cap the settlements submitted on a network, for chains with
+// low block gas limits or RPC providers rejecting bursts of transactions.
+// Settlements over a cap wait in the queue.
+type SettlementLimits struct {
+	// Settlements submitted concurrently, unbounded if 0
+	MaxConcurrent int `mapstructure:"maxConcurrent"`
+	// Settlements submitted per block, unbounded if 0. Requires a facilitator reporting the chain head
+	MaxPerBlock int `mapstructure:"maxPerBlock"`
+}
+
+// BalanceConfig sets when the native balance signers pay for gas with is low.
+// Amounts are in whole units of the native currency, e.g. ETH.
+type BalanceConfig struct {
+	// Balance below which the low balance hooks fire, the balance isn't monitored if 0
+	Minimum float64 `mapstructure:"minimum"`
+	// Amount the treasury tops a low signer up with, no top-up if 0
+	TopUp float64 `mapstructure:"topUp"`
+}
+
+// BundlerConfig routes settlements through a smart account whose user operations
+// are submitted to an ERC-4337 bundler and sponsored by a paymaster, so the
+// signer doesn't need native gas tokens.
+type BundlerConfig struct {
+	// RPC endpoint of the bundler
+	URL string `mapstructure:"url"`
+	// ERC-7677 paymaster service, the bundler endpoint if empty
+	PaymasterURL string `mapstructure:"paymasterUrl"`
+	// Context passed to the paymaster service, e.g. a sponsorship policy
+	PaymasterContext map[string]any `mapstructure:"paymasterContext"`
+	// EntryPoint v0.7 contract, the canonical deployment if empty
+	EntryPoint string `mapstructure:"entryPoint"`
+	// Deployed smart account owned by the signer, it must implement execute(address,uint256,bytes)
+	Account string `mapstructure:"account"`
+}
+
+// SplitConfig enables requirements dividing a payment between recipients.
+// With a splitter contract the recipients are paid in the settlement
+// transaction, otherwise the signer collects the payment and transfers the
+// shares, returning what it couldn't forward to the payer.
+type SplitConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Splitter contract implementing splitWithAuthorization, sequential transfers of the signer if empty
+	Contract string `mapstructure:"contract"`
+	// Most recipients of a split, DefaultSplitRecipients if 0
+	MaxRecipients int `mapstructure:"maxRecipients"`
+}
+
+// DefaultSplitRecipients is the number of recipients a split may have unless configured
+const DefaultSplitRecipients = 5
+
+// apply adjusts the gas price of the strategy by the multiplier and caps it at the maximum.
+func (p GasPolicy) apply(chosen *big.Int) *big.Int {
+	price := chosen
+	if p.PriceMultiplier > 0 && p.PriceMultiplier != 1 {
+		price, _ = new(big.Float).Mul(new(big.Float).SetInt(chosen), big.NewFloat(p.PriceMultiplier)).Int(nil)
+	}
+	if p.MaxGasPriceGwei > 0 {
+		maxPrice := gweiToWei(p.MaxGasPriceGwei)
+		if price.Cmp(maxPrice) > 0 {
+			price = maxPrice
+		}
+	}
+	return price
+}
+
+// schemeByNamespace maps CAIP-2 namespaces to the scheme serving them
+var schemeByNamespace = map[string]types.Scheme{
+	caip.EIP155: types.EVM,
+	caip.Solana: types.Solana,
+	caip.Sui:    types.Sui,
+	caip.Tron:   types.Tron,
+}
+
+// Normalize fills in the defaults derived from the network identifier.
+func (c *NetworkConfig) Normalize() error {
+	namespace := caip.Namespace(c.Network)
+	if !c.IsFamily() {
+		id, err := caip.Parse(c.Network)
+		if err != nil {
+			return err
+		}
+		namespace = id.Namespace
+	}
+	if c.Scheme == "" {
+		scheme, ok := schemeByNamespace[namespace]
+		if !ok {
+			return fmt.Errorf("network %q: unknown namespace %q, set the scheme explicitly", c.Network, namespace)
+		}
+		c.Scheme = scheme
+	}
+	if c.IsFamily() && c.Scheme != types.EVM {
+		return fmt.Errorf("network %s: family sections are only supported for evm networks", c.Network)
+	}
+	if c.Signer == "" {
+		c.Signer = DefaultSigner
+	}
+	if c.Confirmations == 0 {
+		c.Confirmations = 1
+	}
+	if c.Gas.PriceMultiplier == 0 {
+		c.Gas.PriceMultiplier = 1
+	}
+	if c.Gas.Strategy == "" {
+		c.Gas.Strategy = GasStrategySuggested
+	}
+	if c.Gas.DeferInterval == 0 {
+		c.Gas.DeferInterval = DefaultGasDeferInterval
+	}
+	if c.Bundler.URL != "" {
+		if c.Scheme != types.EVM {
+			return fmt.Errorf("network %s: bundler settlement is only supported on evm networks", c.Network)
+		}
+		if !common.IsHexAddress(c.Bundler.Account) {
+			return fmt.Errorf("network %s: bundler settlement requires the address of a smart account", c.Network)
+		}
+		if c.Bundler.EntryPoint == "" {
+			c.Bundler.EntryPoint = EntryPointV07
+		} else if !common.IsHexAddress(c.Bundler.EntryPoint) {
+			return fmt.Errorf("network %s: invalid entry point %q", c.Network, c.Bundler.EntryPoint)
+		}
+		if c.Bundler.PaymasterURL == "" {
+			c.Bundler.PaymasterURL = c.Bundler.URL
+		}
+	}
+	return nil
+}
@@ -1,94 +1,395 @@
 package facilitator
 
 import (
+	"cmp"
 	"context"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/rpcretry"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
 	"github.com/gosuda/x402-facilitator/scheme/evm"
 	"github.com/gosuda/x402-facilitator/scheme/evm/eip3009"
 	"github.com/gosuda/x402-facilitator/types"
 )
 
 var _ Facilitator = (*EVMFacilitator)(nil)
+var _ ReceiptWaiter = (*EVMFacilitator)(nil)
+var _ Estimator = (*EVMFacilitator)(nil)
+var _ AssetResolver = (*EVMFacilitator)(nil)
+var _ AuthorizationReader = (*EVMFacilitator)(nil)
+var _ GasBalanceReader = (*EVMFacilitator)(nil)
+var _ GasPriceReader = (*EVMFacilitator)(nil)
+var _ GasFunder = (*EVMFacilitator)(nil)
+var _ TransferVerifier = (*EVMFacilitator)(nil)
+var _ SplitForwarder = (*EVMFacilitator)(nil)
+
+// confirmationPollInterval is how often the chain head is polled while waiting for confirmations
+const confirmationPollInterval = 2 * time.Second
+
+// dialTimeout bounds connecting to a single RPC endpoint at startup
+const dialTimeout = 10 * time.Second
 
 type EVMFacilitator struct {
 	scheme    types.Scheme
-	network   string
+	network   string // CAIP-2 identifier
+	chainName string // preset name of the chain (e.g. "base-sepolia"), empty for unknown chains
 	networkID *big.Int
 
-	client  *ethclient.Client
-	signer  types.Signer
-	address common.Address
+	nativeCurrency string
+	gas            GasPolicy
+	// how long an authorization must remain valid to be accepted
+	expiryMargin time.Duration
+	// whether payments are verified by their signature only while the chain can't be reached
+	verifyOnOutage bool
+	// accepted assets, replaced when an asset list is synced, see SetAssets
+	assets atomic.Pointer[evmAssetIndex]
+	// guards unresolved and assetsVersion, the changes of the accepted assets
+	assetsMu sync.Mutex
+	// configured assets that are accepted once their metadata is read, see ResolveTokens
+	unresolved []AssetConfig
+	// incremented whenever an asset list replaces the accepted assets
+	assetsVersion uint64
+
+	signer EVMSigner
+	sanity *rpcSanityChecker
+	// reads the chain state of a verification in one batch, nil if batching is off
+	batcher verifyBatcher
+	// broadcasts native currency payments, nil if they aren't accepted
+	native rawTransactionSender
+	// settles payments divided between recipients, nil if they aren't accepted
+	split *evmSplit
+	// new blocks of the chain, nil if not subscribed to them
+	heads *headWatcher
+	// time source of the expiry checks of authorizations
+	clock clock.Clock
 }
 
-func NewEVMFacilitator(network string, url string, privateKeyHex string) (*EVMFacilitator, error) {
-	if network == "" && url == "" {
-		return nil, fmt.Errorf("network or rpc url must be provided")
-	} else if url == "" {
-		// if url is not provided, use default URL
-		if chainInfo := evm.GetChainInfo(network); chainInfo == nil {
-			return nil, fmt.Errorf("unsupported network name: %s", network)
-		} else {
-			url = chainInfo.DefaultUrl
-		}
-	}
+// evmAssetIndex holds the accepted assets by lower-case symbol and address
+type evmAssetIndex map[string]*evmAsset
+
+// evmAsset is a token accepted for payments
+type evmAsset struct {
+	Symbol   string
+	Decimals int
+	Domain   *evm.DomainConfig
+	// paid with an EIP-2612 permit instead of an EIP-3009 authorization
+	Permit bool
+	// whether verifications simulate transfers to find a fee the token takes
+	CheckTransferFee bool
+	// fee in basis points of the value the check accepts
+	TransferFeeToleranceBps int
+}
+
+// eip3009ABI is the ABI settlements are encoded with
+var eip3009ABI = []byte(eip3009.Eip3009MetaData.ABI)
 
-	client, err := ethclient.Dial(url)
+// NewEVMFacilitator connects to the RPC endpoints of the network and settles with the private key.
+func NewEVMFacilitator(config NetworkConfig, privateKeyHex string) (*EVMFacilitator, error) {
+	return newEVMFacilitator(context.Background(), config, privateKeyHex)
+}
+
+func newEVMFacilitator(ctx context.Context, config NetworkConfig, privateKeyHex string) (*EVMFacilitator, error) {
+	privateKey, err := hex.DecodeString(privateKeyHex)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum client: %w", err)
+		return nil, err
 	}
-	networkId, err := client.NetworkID(context.Background())
+	key, err := NewPrivateKeySigner(privateKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get network ID: %w", err)
+		return nil, err
 	}
-	chainName := evm.GetChainName(networkId)
-	if chainName == "" || chainName != network {
-		return nil, fmt.Errorf("unsupported network: %s", network)
+	return NewEVMFacilitatorWithContext(ctx, config, key)
+}
+
+// NewEVMFacilitatorWithKey connects to the RPC endpoints of the network and
+// settles with transactions signed by the key, e.g. a hardware wallet.
+func NewEVMFacilitatorWithKey(config NetworkConfig, key TransactionSigner) (*EVMFacilitator, error) {
+	return NewEVMFacilitatorWithContext(context.Background(), config, key)
+}
+
+// NewEVMFacilitatorWithContext is NewEVMFacilitatorWithKey connecting to the
+// RPC endpoints until ctx is done. With lazy dialing, the facilitator is
+// created without connecting and the first call needing the chain connects.
+func NewEVMFacilitatorWithContext(ctx context.Context, config NetworkConfig, key TransactionSigner) (*EVMFacilitator, error) {
+	networkID, err := evmChainID(config)
+	if err != nil {
+		return nil, err
 	}
 
-	privateKey, err := hex.DecodeString(privateKeyHex)
+	urls := config.RPCURLs
+	if len(urls) == 0 {
+		// if url is not provided, use default URL
+		chainInfo := evm.GetChainInfo(evm.GetChainName(networkID))
+		if chainInfo == nil || chainInfo.DefaultUrl == "" {
+			return nil, fmt.Errorf("network %s: rpc url must be provided", config.Network)
+		}
+		urls = []string{chainInfo.DefaultUrl}
+	}
+	policy := retryPolicy(config.Network, config.Retry)
+	var client *rpcretry.Client
+	var heads *headWatcher
+	if config.LazyDial {
+		// new blocks aren't subscribed to, settlements poll for their receipts
+		client = rpcretry.NewLazyClient(func(ctx context.Context) (*ethclient.Client, error) {
+			connected, err := dialEVM(ctx, config.Network, urls, networkID)
+			if err != nil {
+				return nil, fmt.Errorf("network %s: %w", config.Network, err)
+			}
+			logging.Ctx(ctx, logging.RPC).Info().Str("network", config.Network).Msg("Connected to RPC endpoint")
+			return connected, nil
+		}, policy)
+	} else {
+		connected, err := dialEVM(ctx, config.Network, urls, networkID)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %w", config.Network, err)
+		}
+		client = rpcretry.NewClient(connected, policy)
+		heads = watchHeads(connected, config.Network)
+	}
+	rpcSigner := newEVMRPCSigner(client, networkID, key, config.Gas)
+	rpcSigner.cache = newReadCache(config.Network, config.Cache)
+	rpcSigner.heads = heads
+	rpcSigner.dynamicFee = config.EIP1559
+	if rpcSigner.strategy, err = newGasStrategy(config.Gas, rpcSigner.client); err != nil {
+		return nil, fmt.Errorf("network %s: %w", config.Network, err)
+	}
+	var signer EVMSigner = rpcSigner
+	if config.Bundler.URL != "" {
+		hashSigner, ok := key.(HashSigner)
+		if !ok {
+			return nil, fmt.Errorf("network %s: bundler settlement requires a signer that can sign user operations", config.Network)
+		}
+		signer, err = NewEVMBundlerSigner(signer, hashSigner, networkID, config.Bundler)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %w", config.Network, err)
+		}
+	}
+	f, err := NewEVMFacilitatorWithSigner(config, signer)
+	if err != nil {
+		return nil, err
+	}
+	f.heads = rpcSigner.heads
+	return f, nil
+}
+
+// NewEVMFacilitatorWithSigner creates a facilitator that accesses the chain only through the signer.
+func NewEVMFacilitatorWithSigner(config NetworkConfig, signer EVMSigner) (*EVMFacilitator, error) {
+	networkID, err := evmChainID(config)
 	if err != nil {
 		return nil, err
 	}
-	signer := evm.NewRawPrivateSigner(privateKey)
-	address, err := evm.GetAddrssFromPrivateKey(privateKey)
+	chainName := evm.GetChainName(networkID)
+	chainInfo := evm.GetChainInfo(chainName)
+
+	configured, unresolved := splitUnresolved(config.Assets, chainInfo)
+	assets := evmAssetIndex{}
+	// the presets are accepted only if no assets are configured
+	if len(configured) > 0 || len(unresolved) == 0 {
+		if assets, err = evmAssets(configured, networkID, chainInfo); err != nil {
+			return nil, fmt.Errorf("network %s: %w", config.Network, err)
+		}
+	}
+	nativeCurrency := "ETH"
+	if chainInfo != nil && chainInfo.NativeCurrency != "" {
+		nativeCurrency = chainInfo.NativeCurrency
+	}
+	nativeCurrency = cmp.Or(config.NativeCurrency, nativeCurrency)
+	var native rawTransactionSender
+	if config.AcceptNative {
+		sender, ok := signer.(rawTransactionSender)
+		if !ok {
+			return nil, fmt.Errorf("network %s: native payments require a signer that can broadcast transactions of payers", config.Network)
+		}
+		native = sender
+	}
+	split, err := newEVMSplit(config.Split)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get address from private key: %w", err)
+		return nil, fmt.Errorf("network %s: %w", config.Network, err)
+	}
+	// signers that can't batch, e.g. bundler signers, read one by one
+	var batcher verifyBatcher
+	if config.BatchReads {
+		batcher, _ = signer.(verifyBatcher)
 	}
 
-	return &EVMFacilitator{
+	f := &EVMFacilitator{
 		scheme:    types.EVM,
-		network:   network,
-		networkID: networkId,
+		network:   config.Network,
+		chainName: chainName,
+		networkID: networkID,
+
+		nativeCurrency: nativeCurrency,
+		gas:            config.Gas,
+		expiryMargin:   cmp.Or(config.ExpiryMargin, DefaultExpiryMargin),
+		verifyOnOutage: config.VerifyOnOutage,
 
-		client:  client,
 		signer:  signer,
-		address: address,
-	}, nil
+		sanity:  newRPCSanityChecker(signer, networkID),
+		batcher: batcher,
+		native:  native,
+		split:   split,
+		clock:   clock.Real,
+
+		unresolved: unresolved,
+	}
+	f.assets.Store(&assets)
+	return f, nil
+}
+
+// SetClock replaces the clock authorizations and permits are checked for expiry
+// with, and the RPC sanity checks are throttled by.
+func (t *EVMFacilitator) SetClock(c clock.Clock) {
+	t.clock = c
+	t.sanity.now = c.Now
+}
+
+// evmChainID returns the configured chain ID, or the one of the CAIP-2 identifier.
+func evmChainID(config NetworkConfig) (*big.Int, error) {
+	if config.ChainID != 0 {
+		return big.NewInt(config.ChainID), nil
+	}
+	chainID, ok := evm.ParseCAIP2(config.Network)
+	if !ok {
+		return nil, fmt.Errorf("network %s: chain ID must be provided", config.Network)
+	}
+	return chainID, nil
+}
+
+// evmAssets indexes the configured assets, falling back to the network presets.
+func evmAssets(configs []AssetConfig, chainID *big.Int, chainInfo *evm.ChainInfo) (evmAssetIndex, error) {
+	assets := make(evmAssetIndex)
+	add := func(asset *evmAsset) {
+		assets[strings.ToLower(asset.Symbol)] = asset
+		assets[strings.ToLower(asset.Domain.VerifyingContract.Hex())] = asset
+	}
+
+	if len(configs) == 0 && chainInfo != nil {
+		for symbol, domain := range chainInfo.TokenContracts {
+			add(&evmAsset{
+				Symbol:   symbol,
+				Decimals: evm.GetTokenDecimals(symbol),
+				Domain:   &domain,
+			})
+		}
+		return assets, nil
+	}
+
+	for _, config := range configs {
+		config, _ = withPreset(config, chainInfo)
+		if !common.IsHexAddress(config.Address) {
+			return nil, fmt.Errorf("asset %s: invalid address %q", config.Symbol, config.Address)
+		}
+		if config.TransferMethod != "" && config.TransferMethod != types.TransferMethodEIP3009 && config.TransferMethod != types.TransferMethodEIP2612 {
+			return nil, fmt.Errorf("asset %s: unknown transfer method %q", config.Symbol, config.TransferMethod)
+		}
+		if config.TransferFeeToleranceBps < 0 || config.TransferFeeToleranceBps > maxTransferFeeBps {
+			return nil, fmt.Errorf("asset %s: transfer fee tolerance %d is not between 0 and %d basis points", config.Symbol, config.TransferFeeToleranceBps, maxTransferFeeBps)
+		}
+		add(&evmAsset{
+			Symbol:                  config.Symbol,
+			Decimals:                config.Decimals,
+			Domain:                  evm.NewDomainConfig(config.Name, config.Version, chainID, config.Address),
+			Permit:                  config.TransferMethod == types.TransferMethodEIP2612,
+			CheckTransferFee:        config.CheckTransferFee,
+			TransferFeeToleranceBps: config.TransferFeeToleranceBps,
+		})
+	}
+	return assets, nil
+}
+
+// withPreset completes the asset with the preset of the token on the chain:
+// an asset configured by symbol only takes the preset contract, and an asset
+// of a preset contract takes the decimals and EIP-712 domain it doesn't
+// override. ok is false if the chain has no preset of the token.
+func withPreset(asset AssetConfig, chainInfo *evm.ChainInfo) (AssetConfig, bool) {
+	if chainInfo == nil {
+		return asset, false
+	}
+	for symbol, domain := range chainInfo.TokenContracts {
+		if asset.Address == "" && !strings.EqualFold(asset.Symbol, symbol) ||
+			asset.Address != "" && !strings.EqualFold(asset.Address, domain.VerifyingContract.Hex()) {
+			continue
+		}
+		asset.Symbol = cmp.Or(asset.Symbol, symbol)
+		asset.Address = domain.VerifyingContract.Hex()
+		asset.Decimals = cmp.Or(asset.Decimals, evm.GetTokenDecimals(symbol))
+		asset.Name = cmp.Or(asset.Name, domain.Name)
+		asset.Version = cmp.Or(asset.Version, domain.Version)
+		return asset, true
+	}
+	return asset, false
+}
+
+// AssetWithPreset completes an asset of the EVM network with the network
+// presets, see AssetConfig. ok is false if there is no preset of the token.
+func (c NetworkConfig) AssetWithPreset(asset AssetConfig) (AssetConfig, bool) {
+	chainID, err := evmChainID(c)
+	if err != nil {
+		return asset, false
+	}
+	return withPreset(asset, evm.GetChainInfo(evm.GetChainName(chainID)))
+}
+
+// isNetwork reports whether the network identifier, either CAIP-2 or the chain name, is served by this facilitator.
+func (t *EVMFacilitator) isNetwork(network string) bool {
+	return network == t.network || (t.chainName != "" && network == t.chainName)
+}
+
+// asset looks up an accepted asset by symbol or contract address.
+func (t *EVMFacilitator) asset(asset string) *evmAsset {
+	return (*t.assets.Load())[strings.ToLower(asset)]
+}
+
+func (t *EVMFacilitator) ResolveAsset(asset string) (string, int, bool) {
+	if t.isNative(asset) {
+		return t.nativeCurrency, evm.NativeDecimals, true
+	}
+	a := t.asset(asset)
+	if a == nil {
+		return "", 0, false
+	}
+	return a.Symbol, a.Decimals, true
 }
 
 // verification steps:
 //   - ✅ verify payload format
 //   - ✅ verify payload version
 //   - ✅ verify usdc address is correct for the chain
-//   - ✅ verify permit signature
+//   - ✅ verify permit signature (EOA, EIP-1271 and ERC-6492 smart wallets)
 //   - ✅ verify deadline
 //   - verify nonce is current
+//   - ✅ verify RPC responses are sane (stable chain ID, monotonic head, plausible balance)
 //   - ✅ verify client has enough funds to cover paymentRequirements.maxAmountRequired
 //   - ✅ verify value in payload is enough to cover paymentRequirements.maxAmountRequired
 //   - check min amount is above some threshold we think is reasonable for covering gas
 //   - verify resource is not already paid for (next version)
 func (t *EVMFacilitator) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
+	if _, err := t.paymentSplit(req); err != nil {
+		return &types.PaymentVerifyResponse{IsValid: false, InvalidReason: err.Error()}, nil
+	}
+	if t.isNative(req.Asset) {
+		return t.verifyNative(ctx, payload, req)
+	}
+	if asset := t.asset(req.Asset); asset != nil && asset.Permit {
+		return t.verifyPermit(ctx, payload, req, asset)
+	}
+
 	// Step 1: Payload format
-	var evmPayload evm.EVMPayload
-	if err := json.Unmarshal([]byte(payload.Payload), &evmPayload); err != nil {
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	if err != nil {
 		return &types.PaymentVerifyResponse{
 			IsValid:       false,
 			InvalidReason: types.ErrInvalidPayloadFormat.Error(),
@@ -105,48 +406,66 @@ func (t *EVMFacilitator) Verify(ctx context.Context, payload *types.PaymentPaylo
 	}
 
 	// Step 3: Network info and Contract info
-	if payload.Network != t.network {
+	if !t.isNetwork(payload.Network) || !t.isNetwork(req.Network) {
 		return &types.PaymentVerifyResponse{
 			IsValid:       false,
 			InvalidReason: types.ErrNetworkMismatch.Error(),
 			Payer:         evmPayload.Authorization.From.String(),
 		}, nil
 	}
-	chainID := evm.GetChainID(payload.Network)
-	if chainID == nil {
+	asset := t.asset(req.Asset)
+	if asset == nil {
 		return &types.PaymentVerifyResponse{
 			IsValid:       false,
-			InvalidReason: types.ErrInvalidNetwork.Error(),
+			InvalidReason: types.ErrTokenMismatch.Error(),
 			Payer:         evmPayload.Authorization.From.String(),
 		}, nil
 	}
-	if chainID.Cmp(t.networkID) != 0 {
-		return &types.PaymentVerifyResponse{
-			IsValid:       false,
-			InvalidReason: types.ErrNetworkIDMismatch.Error(),
-			Payer:         evmPayload.Authorization.From.String(),
-		}, nil
+
+	// Step 4: Read the chain state in one round trip if batching is enabled
+	var reads *verifyReads
+	// set when the chain can't be reached, so only the signature and terms are checked
+	degraded := false
+	if t.batcher != nil {
+		auth := evmPayload.Authorization
+		reads, err = t.batcher.BatchVerifyReads(ctx, asset.Domain.VerifyingContract, auth.From, auth.Nonce)
+		switch {
+		case err == nil:
+			// smart wallet signatures are checked against the code just read
+			ctx = withPrefetchedCode(ctx, auth.From, reads.code)
+		case t.chainDown(ctx, err):
+			reads, degraded = nil, true
+		default:
+			return nil, fmt.Errorf("failed to read chain state: %w", err)
+		}
 	}
-	domainConfig := evm.GetDomainConfig(payload.Network, req.Asset)
-	if domainConfig == nil {
+
+	// Step 5: Verify signature (EIP-712)
+	sig, err := evm.DecodeSignature(evmPayload.Signature)
+	if err != nil {
 		return &types.PaymentVerifyResponse{
 			IsValid:       false,
-			InvalidReason: types.ErrTokenMismatch.Error(),
+			InvalidReason: types.ErrInvalidSignature.Error(),
 			Payer:         evmPayload.Authorization.From.String(),
 		}, nil
 	}
-
-	// Step 4: Verify signature (EIP-712)
-	sig, err := evm.ParseSignature(evmPayload.Signature)
-	if err != nil {
+	valid, err := t.signer.VerifyTypedData(ctx,
+		evmPayload.Authorization.From.Hex(),
+		typedDataDomain(asset.Domain),
+		evm.TransferWithAuthorizationTypes,
+		"TransferWithAuthorization",
+		authorizationMessage(evmPayload.Authorization),
+		sig,
+	)
+	if err != nil && ctx.Err() != nil {
+		// the signature couldn't be checked in time, which says nothing about its validity
 		return nil, err
 	}
-	digest := evmPayload.Authorization.ToMessageHash()
-	pubkey, err := evm.Ecrecover(digest, sig)
-	if err != nil {
-		return nil, err
+	if err != nil && t.chainDown(ctx, err) {
+		// smart wallet signatures are checked by the wallet contract
+		return nil, fmt.Errorf("%w: %w", ErrChainUnavailable, err)
 	}
-	if valid := evm.VerifySignature(pubkey, digest, sig[:64]); !valid {
+	if err != nil || !valid {
 		return &types.PaymentVerifyResponse{
 			IsValid:       false,
 			InvalidReason: types.ErrInvalidSignature.Error(),
@@ -154,21 +473,64 @@ func (t *EVMFacilitator) Verify(ctx context.Context, payload *types.PaymentPaylo
 		}, nil
 	}
 
-	// Step 5: Validate payTo
-
-	// Step 6: Deadline check
+	// Step 6: payTo, deadline and value of the authorization
+	if err := checkAuthorization(evmPayload.Authorization, req, t.clock.Now(), t.expiryMargin); err != nil {
+		return &types.PaymentVerifyResponse{
+			IsValid:       false,
+			InvalidReason: err.Error(),
+			Payer:         evmPayload.Authorization.From.String(),
+		}, nil
+	}
 
-	// Step 7: TODO: Nonce freshness check (optional in v1)
+	if degraded {
+		return signatureOnly(evmPayload.Authorization.From), nil
+	}
 
-	// Step 8: Check ERC20 balance
-	contract, err := eip3009.NewEip3009(domainConfig.VerifyingContract, t.client)
-	if err != nil {
-		return nil, fmt.Errorf("contract bind failed: %w", err)
+	// Step 7: Make sure the RPC provider can be trusted
+	if reads != nil {
+		err = t.sanity.CheckHead(reads.chainID, reads.head)
+	} else {
+		err = t.sanity.Check(ctx)
 	}
-	balance, err := contract.BalanceOf(&bind.CallOpts{Context: ctx}, evmPayload.Authorization.From)
 	if err != nil {
+		if reads == nil && t.chainDown(ctx, err) {
+			return signatureOnly(evmPayload.Authorization.From), nil
+		}
+		return t.rpcAnomaly(ctx, err, evmPayload.Authorization.From)
+	}
+
+	// Step 8: Check that the authorization wasn't used or canceled
+	used := reads != nil && reads.used
+	if reads == nil {
+		used, err = t.authorizationUsed(ctx, asset, evmPayload.Authorization)
+		if err != nil {
+			if t.chainDown(ctx, err) {
+				return signatureOnly(evmPayload.Authorization.From), nil
+			}
+			return nil, err
+		}
+	}
+	if used {
+		return &types.PaymentVerifyResponse{
+			IsValid:       false,
+			InvalidReason: types.ErrAuthorizationUsed.Error(),
+			Payer:         evmPayload.Authorization.From.String(),
+		}, nil
+	}
+
+	// Step 9: Check ERC20 balance
+	var balance *big.Int
+	if reads != nil {
+		balance = reads.balance
+	} else if balance, err = t.signer.GetBalance(ctx, evmPayload.Authorization.From.Hex(), asset.Domain.VerifyingContract.Hex()); err != nil {
+		if t.chainDown(ctx, err) {
+			return signatureOnly(evmPayload.Authorization.From), nil
+		}
 		return nil, fmt.Errorf("failed to get balance: %w", err)
 	}
+	if err := checkBalance(balance); err != nil {
+		return t.rpcAnomaly(ctx, err, evmPayload.Authorization.From)
+	}
 	if balance.Cmp(evmPayload.Authorization.Value) < 0 {
 		return &types.PaymentVerifyResponse{
 			IsValid:       false,
@@ -177,11 +539,25 @@ func (t *EVMFacilitator) Verify(ctx context.Context, payload *types.PaymentPaylo
 		}, nil
 	}
 
-	// Step 9: Check value in permit matches requirement
+	// Step 10: Check that the recipient receives the authorized value
+	if asset.CheckTransferFee {
+		if err := t.checkTransferFee(ctx, asset, evmPayload.Authorization, sig); errors.Is(err, types.ErrFeeOnTransferToken) {
+			return &types.PaymentVerifyResponse{
+				IsValid:       false,
+				InvalidReason: err.Error(),
+				Payer:         evmPayload.Authorization.From.String(),
+			}, nil
+		} else if err != nil {
+			if t.chainDown(ctx, err) {
+				return signatureOnly(evmPayload.Authorization.From), nil
+			}
+			return nil, err
+		}
+	}
 
-	// Step 10: TODO: Check minimum payment threshold (e.g. for gas overhead)
+	// Step 11: TODO: Check minimum payment threshold (e.g. for gas overhead)
 
-	// Step 11: TODO: Check if resource already paid (next version)
+	// Step 12: TODO: Check if resource already paid (next version)
 
 	// ✅ All checks passed
 	return &types.PaymentVerifyResponse{
@@ -190,45 +566,124 @@ func (t *EVMFacilitator) Verify(ctx context.Context, payload *types.PaymentPaylo
 	}, nil
 }
 
+// authorizationUsed reads whether the authorization was used or canceled.
+func (t *EVMFacilitator) authorizationUsed(ctx context.Context, asset *evmAsset, auth *evm.Authorization) (bool, error) {
+	result, err := t.signer.ReadContract(ctx, asset.Domain.VerifyingContract.Hex(), sdk.AuthorizationStateABI, "authorizationState", auth.From, auth.Nonce)
+	if err != nil {
+		return false, fmt.Errorf("failed to get authorization state: %w", err)
+	}
+	used, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected authorizationState result %T", result)
+	}
+	return used, nil
+}
+
+// checkAuthorization checks that the authorization pays at least the required
+// amount to the recipient of the requirements and can still be settled at
+// now, remaining valid for at least margin so the settlement can be mined.
+func checkAuthorization(auth *evm.Authorization, req *types.PaymentRequirements, now time.Time, margin time.Duration) error {
+	if !common.IsHexAddress(req.PayTo) || auth.To != common.HexToAddress(req.PayTo) {
+		return types.ErrRecipientMismatch
+	}
+	required, ok := new(big.Int).SetString(req.MaxAmountRequired, 10)
+	if !ok || auth.Value == nil || auth.Value.Cmp(required) < 0 {
+		return types.ErrValueMismatch
+	}
+	if auth.ValidBefore == nil || auth.ValidBefore.Cmp(big.NewInt(now.Add(margin).Unix())) < 0 {
+		return types.ErrAuthorizationExpired
+	}
+	if auth.ValidAfter != nil && auth.ValidAfter.Cmp(big.NewInt(now.Unix())) > 0 {
+		return types.ErrAuthorizationNotYetValid
+	}
+	return nil
+}
+
+// rpcAnomaly turns a failed sanity check into an invalid verification result.
+// Plain RPC errors are returned as errors.
+func (t *EVMFacilitator) rpcAnomaly(ctx context.Context, err error, payer common.Address) (*types.PaymentVerifyResponse, error) {
+	if !isAnomaly(err) {
+		return nil, err
+	}
+	logging.Ctx(ctx, logging.RPC).Warn().Err(err).Str("network", t.network).Msg("RPC provider returned anomalous data")
+	return &types.PaymentVerifyResponse{
+		IsValid:       false,
+		InvalidReason: types.ErrRPCAnomaly.Error(),
+		Payer:         payer.String(),
+	}, nil
+}
+
 func (t *EVMFacilitator) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
-	var evmPayload evm.EVMPayload
-	if err := json.Unmarshal([]byte(payload.Payload), &evmPayload); err != nil {
+	split, err := t.paymentSplit(req)
+	if err != nil {
+		return &types.PaymentSettleResponse{Success: false, Error: err.Error()}, nil
+	}
+	if t.isNative(req.Asset) {
+		return t.settleNative(ctx, payload, req)
+	}
+	if asset := t.asset(req.Asset); asset != nil && asset.Permit {
+		return t.settlePermit(ctx, payload, req, asset)
+	}
+
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	if err != nil {
 		return &types.PaymentSettleResponse{
 			Success: false,
 			Error:   types.ErrInvalidPayloadFormat.Error(),
 		}, nil
 	}
 
-	networkID := evm.GetChainID(req.Network)
-	if networkID == nil {
+	if !t.isNetwork(payload.Network) || !t.isNetwork(req.Network) {
 		return &types.PaymentSettleResponse{
 			Success: false,
-			Error:   types.ErrInvalidNetwork.Error(),
+			Error:   types.ErrNetworkMismatch.Error(),
 		}, nil
 	}
-
-	domainConfig := evm.GetDomainConfig(payload.Network, req.Asset)
-	if domainConfig == nil {
+	asset := t.asset(req.Asset)
+	if asset == nil {
 		return &types.PaymentSettleResponse{
 			Success: false,
 			Error:   types.ErrTokenMismatch.Error(),
 		}, nil
 	}
-	contract, err := eip3009.NewEip3009(domainConfig.VerifyingContract, t.client)
+	if err := checkAuthorization(evmPayload.Authorization, req, t.clock.Now(), t.expiryMargin); err != nil {
+		return &types.PaymentSettleResponse{
+			Success: false,
+			Error:   err.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	clientSig, err := evm.DecodeSignature(evmPayload.Signature) // client signature
 	if err != nil {
-		return nil, fmt.Errorf("contract bind failed: %w", err)
+		return &types.PaymentSettleResponse{
+			Success: false,
+			Error:   types.ErrInvalidSignature.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
 	}
-	clientSig, err := evm.ParseSignature(evmPayload.Signature) // client signature
+
+	// ERC-6492: the smart wallet of the payer is deployed first, the token then checks the inner signature
+	sigData, err := sdk.ParseERC6492Signature(clientSig)
 	if err != nil {
+		return &types.PaymentSettleResponse{
+			Success: false,
+			Error:   types.ErrInvalidSignature.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	if err := t.deployWallet(ctx, asset, evmPayload.Authorization, sigData); errors.Is(err, types.ErrInvalidSignature) {
+		return &types.PaymentSettleResponse{
+			Success: false,
+			Error:   types.ErrInvalidSignature.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	} else if err != nil {
 		return nil, err
 	}
+	clientSig = sigData.InnerSignature
 
-	tx, err := contract.TransferWithAuthorization(
-		&bind.TransactOpts{
-			Context: ctx,
-			Signer:  evm.ToGethSigner(t.signer, networkID), // facilitator signature
-			From:    t.address,
-		},
+	contract, contractABI, function := asset.Domain.VerifyingContract.Hex(), eip3009ABI, "transferWithAuthorization"
+	args := []any{
 		evmPayload.Authorization.From,
 		evmPayload.Authorization.To,
 		evmPayload.Authorization.Value,
@@ -236,23 +691,394 @@ func (t *EVMFacilitator) Settle(ctx context.Context, payload *types.PaymentPaylo
 		evmPayload.Authorization.ValidBefore,
 		evmPayload.Authorization.Nonce,
 		clientSig,
-	)
+	}
+	if split != nil && t.split.atomic() {
+		// the splitter submits the authorization and pays the recipients
+		contract, contractABI, function = t.split.contract.Hex(), splitABI, "splitWithAuthorization"
+		args = splitterArgs(asset.Domain.VerifyingContract, evmPayload.Authorization, clientSig, split)
+	}
+	// a transaction that would revert is not broadcast, it would only burn gas
+	err = t.signer.SimulateContract(ctx, contract, contractABI, function, args...)
+	var revert *evm.RevertError
+	if errors.As(err, &revert) {
+		reason, code := decodeRevertError(revert)
+		logging.Ctx(ctx, logging.RPC).Info().Str("network", t.network).Str("reason", reason).Str("code", code.Error()).Msg("Settlement simulation reverted")
+		diagnostics.Record(ctx, diagnostics.KindSimulation, revert.Error(), map[string]string{
+			"reason":     reason,
+			"code":       code.Error(),
+			"revertData": "0x" + hex.EncodeToString(revert.Data),
+		})
+		return &types.PaymentSettleResponse{
+			Success: false,
+			Error:   code.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	if err != nil {
+		err = recordRPCError(ctx, fmt.Errorf("failed to simulate settlement: %w", err))
+		if t.chainDown(ctx, err) {
+			// nothing was broadcast, the settlement can be retried once the chain is reachable
+			return nil, fmt.Errorf("%w: %w", ErrChainUnavailable, err)
+		}
+		return nil, err
+	}
+	diagnostics.Record(ctx, diagnostics.KindSimulation, "ok", nil)
+
+	// the signer pays the gas and signs the transaction
+	txHash, err := t.signer.WriteContract(ctx, contract, contractABI, function, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to transfer with authorization %w", err)
 	}
 
-	return &types.PaymentSettleResponse{
+	res := &types.PaymentSettleResponse{
 		Success:   true,
-		TxHash:    tx.Hash().Hex(),
-		NetworkId: fmt.Sprintf("%d", networkID),
+		TxHash:    txHash,
+		NetworkId: t.networkID.String(),
+		Payer:     evmPayload.Authorization.From.String(),
+	}
+	if split != nil && t.split.atomic() {
+		res.Splits = splitTransfers(split, evmPayload.Authorization.Value, txHash)
+	}
+	// the shares of sequential splits are forwarded once the collection is mined, see ForwardSplit
+	return res, nil
+}
+
+// Estimate simulates the settlement transaction by estimating its gas from the
+// facilitator address, without broadcasting it.
+func (t *EVMFacilitator) Estimate(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentEstimateResponse, error) {
+	split, err := t.paymentSplit(req)
+	if err != nil {
+		return &types.PaymentEstimateResponse{Success: false, Error: err.Error()}, nil
+	}
+	if t.isNative(req.Asset) {
+		return t.estimateNative(payload, req)
+	}
+	if asset := t.asset(req.Asset); asset != nil && asset.Permit {
+		return t.estimatePermit(ctx, payload, req, asset)
+	}
+
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	if err != nil {
+		return &types.PaymentEstimateResponse{
+			Success: false,
+			Error:   types.ErrInvalidPayloadFormat.Error(),
+		}, nil
+	}
+
+	if !t.isNetwork(payload.Network) || !t.isNetwork(req.Network) {
+		return &types.PaymentEstimateResponse{
+			Success: false,
+			Error:   types.ErrNetworkMismatch.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	asset := t.asset(req.Asset)
+	if asset == nil {
+		return &types.PaymentEstimateResponse{
+			Success: false,
+			Error:   types.ErrTokenMismatch.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	clientSig, err := evm.DecodeSignature(evmPayload.Signature)
+	if err != nil {
+		return &types.PaymentEstimateResponse{
+			Success: false,
+			Error:   types.ErrInvalidSignature.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	if sigData, err := sdk.ParseERC6492Signature(clientSig); err == nil {
+		// the simulation only succeeds for wallets that are already deployed
+		clientSig = sigData.InnerSignature
+	}
+
+	// estimating gas executes the call, so a reverting settlement fails here
+	var gasLimit uint64
+	if split != nil && t.split.atomic() {
+		gasLimit, err = t.signer.EstimateGas(ctx, t.split.contract.Hex(), splitABI, "splitWithAuthorization",
+			splitterArgs(asset.Domain.VerifyingContract, evmPayload.Authorization, clientSig, split)...)
+	} else {
+		gasLimit, err = t.signer.EstimateGas(ctx,
+			asset.Domain.VerifyingContract.Hex(),
+			eip3009ABI,
+			"transferWithAuthorization",
+			evmPayload.Authorization.From,
+			evmPayload.Authorization.To,
+			evmPayload.Authorization.Value,
+			evmPayload.Authorization.ValidAfter,
+			evmPayload.Authorization.ValidBefore,
+			evmPayload.Authorization.Nonce,
+			clientSig,
+		)
+		if split != nil {
+			// the signer forwards the shares after collecting the payment
+			gasLimit += uint64(len(split.Recipients)) * shareGas
+		}
+	}
+	var revert *evm.RevertError
+	if errors.As(err, &revert) {
+		_, code := decodeRevertError(revert)
+		err = code
+	}
+	if err != nil {
+		return &types.PaymentEstimateResponse{
+			Success: false,
+			Error:   err.Error(),
+			Payer:   evmPayload.Authorization.From.String(),
+		}, nil
+	}
+	if t.gas.GasLimit != 0 {
+		gasLimit = t.gas.GasLimit
+	}
+	gasPrice, err := t.signer.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gasCost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+
+	return &types.PaymentEstimateResponse{
+		Success:        true,
+		GasLimit:       gasLimit,
+		GasPrice:       gasPrice.String(),
+		GasCost:        gasCost.String(),
+		GasCostNative:  types.FormatUnits(gasCost, evm.NativeDecimals),
+		NativeCurrency: t.nativeCurrency,
+		Payer:          evmPayload.Authorization.From.String(),
 	}, nil
 }
 
-func (t *EVMFacilitator) Supported() []*types.SupportedKind {
-	return []*types.SupportedKind{
-		{
-			Scheme:  string(t.scheme),
-			Network: t.network,
-		},
+// GasPrice returns the gas price settlements are submitted with, as chosen by
+// the gas strategy of the network.
+func (t *EVMFacilitator) GasPrice(ctx context.Context) (*big.Int, error) {
+	return t.signer.GasPrice(ctx)
+}
+
+func (t *EVMFacilitator) WaitMined(ctx context.Context, txHash string) (*Receipt, error) {
+	receipt, err := t.signer.WaitForTransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for transaction %s: %w", txHash, err)
+	}
+	result := &Receipt{
+		TxHash:      txHash,
+		BlockNumber: receipt.BlockNumber,
+		Success:     receipt.Status == sdk.TxStatusSuccess,
+	}
+
+	// gas accounting is best effort, the outcome of the settlement is known already
+	full, err := t.signer.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		logging.Ctx(ctx, logging.RPC).Warn().Err(err).Str("tx_hash", txHash).Msg("Failed to get gas used by settlement")
+		return result, nil
+	}
+	result.GasUsed = full.GasUsed
+	if full.EffectiveGasPrice != nil {
+		result.EffectiveGasPrice = full.EffectiveGasPrice
+		result.Fee = new(big.Int).Mul(full.EffectiveGasPrice, new(big.Int).SetUint64(full.GasUsed))
+		result.FeeCurrency = t.nativeCurrency
+		result.FeeDecimals = evm.NativeDecimals
+	}
+	return result, nil
+}
+
+// WaitConfirmed follows the chain head until the transaction has enough confirmations.
+// The receipt is fetched again at that point; if a reorg moved the transaction into
+// another block, the receipt is updated and the confirmations are counted from there.
+func (t *EVMFacilitator) WaitConfirmed(ctx context.Context, receipt *Receipt, confirmations uint64) error {
+	if confirmations <= 1 {
+		return nil
+	}
+
+	ticker := time.NewTicker(confirmationPollInterval)
+	defer ticker.Stop()
+	for {
+		next := t.heads.Next()
+		head, err := t.Head(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get block number: %w", err)
+		}
+		if head >= receipt.BlockNumber+confirmations-1 {
+			current, err := t.WaitMined(ctx, receipt.TxHash)
+			if err != nil {
+				return err
+			}
+			if !current.Success {
+				return fmt.Errorf("transaction %s reverted after reorg", receipt.TxHash)
+			}
+			if current.BlockNumber == receipt.BlockNumber {
+				return nil
+			}
+			logging.For(logging.RPC).Warn().
+				Str("tx_hash", receipt.TxHash).
+				Uint64("block", receipt.BlockNumber).
+				Uint64("new_block", current.BlockNumber).
+				Msg("Settlement transaction was reorganized")
+			receipt.BlockNumber = current.BlockNumber
+			continue
+		}
+
+		if err := waitBlock(ctx, next, ticker.C, stalledHeadInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// Authorization returns the signer and the EIP-3009 nonce of the transfer
+// authorization, the owner and nonce of an EIP-2612 permit, or the payer and
+// account nonce of a native payment.
+func (t *EVMFacilitator) Authorization(payment *types.PaymentPayload) (string, string, bool) {
+	evmPayload, err := evm.ParsePayload(payment.Payload)
+	if err != nil {
+		if permit, _, err := evm.ParsePermitPayload(payment.Payload); err == nil {
+			return permit.Owner.Hex(), "permit:" + permit.Nonce.String(), true
+		}
+		if t.native != nil {
+			return nativeAuthorization(payment)
+		}
+		return "", "", false
+	}
+	return evmPayload.Authorization.From.Hex(), "0x" + hex.EncodeToString(evmPayload.Authorization.Nonce[:]), true
+}
+
+// Expiry returns the validBefore time of the authorization, or the deadline of the permit.
+func (t *EVMFacilitator) Expiry(payment *types.PaymentPayload) (time.Time, bool) {
+	var expiry *big.Int
+	if evmPayload, err := evm.ParsePayload(payment.Payload); err == nil {
+		expiry = evmPayload.Authorization.ValidBefore
+	} else if permit, _, err := evm.ParsePermitPayload(payment.Payload); err == nil {
+		expiry = permit.Deadline
+	}
+	if expiry == nil || !expiry.IsInt64() {
+		return time.Time{}, false
+	}
+	return time.Unix(expiry.Int64(), 0), true
+}
+
+// Amount returns the value of the authorization or permit, or of the native transfer.
+func (t *EVMFacilitator) Amount(payment *types.PaymentPayload) (*big.Int, bool) {
+	if evmPayload, err := evm.ParsePayload(payment.Payload); err == nil {
+		return evmPayload.Authorization.Value, evmPayload.Authorization.Value != nil
+	}
+	if permit, _, err := evm.ParsePermitPayload(payment.Payload); err == nil {
+		return permit.Value, permit.Value != nil
+	}
+	if t.native != nil {
+		if tx, err := evm.ParseNativePayload(payment.Payload); err == nil {
+			return tx.Value(), true
+		}
+	}
+	return nil, false
+}
+
+// GasBalances returns the native balances of the signers. Settlements through a
+// bundler are paid for by the paymaster, so there are none to watch.
+func (t *EVMFacilitator) GasBalances(ctx context.Context) ([]GasBalance, error) {
+	if _, ok := t.signer.(*EVMBundlerSigner); ok {
+		return nil, nil
+	}
+	var balances []GasBalance
+	for _, address := range t.signer.GetAddresses() {
+		amount, err := t.signer.GetBalance(ctx, address, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balance of %s: %w", address, err)
+		}
+		balances = append(balances, GasBalance{
+			Signer:   address,
+			Amount:   amount,
+			Currency: t.nativeCurrency,
+			Decimals: evm.NativeDecimals,
+		})
+	}
+	return balances, nil
+}
+
+// nativeTransferer is implemented by signers that can submit native transfers of other accounts.
+type nativeTransferer interface {
+	TransferNative(ctx context.Context, from TransactionSigner, to string, amount *big.Int) (string, error)
+}
+
+// FundGas transfers native tokens from the account of the key to the signer.
+func (t *EVMFacilitator) FundGas(ctx context.Context, from TransactionSigner, signer string, amount *big.Int) (string, error) {
+	transferer, ok := t.signer.(nativeTransferer)
+	if !ok {
+		return "", fmt.Errorf("network %s: signer can't submit native transfers", t.network)
+	}
+	if !common.IsHexAddress(signer) {
+		return "", fmt.Errorf("invalid signer address %q", signer)
+	}
+	return transferer.TransferNative(ctx, from, signer, amount)
+}
+
+// GetExtra lists the accepted assets, see types.SupportedAsset, and how split
+// payments are settled, see types.SplitSupport.
+func (t *EVMFacilitator) GetExtra() map[string]any {
+	extra := map[string]any{
+		"assets": t.catalog(),
+	}
+	if support := t.splitSupport(); support != nil {
+		extra["split"] = support
+	}
+	return extra
+}
+
+// catalog returns the accepted assets ordered by symbol, the native currency last.
+func (t *EVMFacilitator) catalog() []types.SupportedAsset {
+	var catalog []types.SupportedAsset
+	for key, asset := range *t.assets.Load() {
+		// assets are indexed by symbol and address, list them once
+		if key != strings.ToLower(asset.Domain.VerifyingContract.Hex()) {
+			continue
+		}
+		supported := types.SupportedAsset{
+			Address:        asset.Domain.VerifyingContract.Hex(),
+			Symbol:         asset.Symbol,
+			Decimals:       asset.Decimals,
+			TransferMethod: types.TransferMethodEIP3009,
+			Name:           asset.Domain.Name,
+			Version:        asset.Domain.Version,
+		}
+		if asset.Permit {
+			supported.TransferMethod = types.TransferMethodEIP2612
+			supported.Spender = t.permitSpender().Hex()
+		}
+		catalog = append(catalog, supported)
+	}
+	slices.SortFunc(catalog, func(a, b types.SupportedAsset) int {
+		return strings.Compare(a.Symbol, b.Symbol)
+	})
+	if t.native != nil {
+		catalog = append(catalog, types.SupportedAsset{
+			Address:        evm.NativeAsset,
+			Symbol:         t.nativeCurrency,
+			Decimals:       evm.NativeDecimals,
+			TransferMethod: types.TransferMethodTransaction,
+		})
+	}
+	return catalog
+}
+
+func (t *EVMFacilitator) GetSigners() []string {
+	return t.signer.GetAddresses()
+}
+
+// typedDataDomain converts the asset domain into its EIP-712 representation.
+func typedDataDomain(domain *evm.DomainConfig) sdk.TypedDataDomain {
+	return sdk.TypedDataDomain{
+		Name:              domain.Name,
+		Version:           domain.Version,
+		ChainID:           domain.ChainID,
+		VerifyingContract: domain.VerifyingContract.Hex(),
+	}
+}
+
+// authorizationMessage converts the authorization into its EIP-712 message.
+func authorizationMessage(auth *evm.Authorization) map[string]any {
+	return map[string]any{
+		"from":        auth.From.Hex(),
+		"to":          auth.To.Hex(),
+		"value":       auth.Value,
+		"validAfter":  auth.ValidAfter,
+		"validBefore": auth.ValidBefore,
+		"nonce":       auth.Nonce[:],
 	}
 }
@@ -0,0 +1,401 @@
+//go:build anvil
+
+// Integration tests against a local anvil node. They run with
+//
+//	go test -tags anvil ./facilitator/...
+//
+// and need foundry's forge to compile the test contracts in testdata/contracts.
+// A node is started with anvil unless ANVIL_URL points to a running one, which
+// must serve chain 31337 and fund the first default development account.
+//
+// The facilitator doesn't verify or settle Permit2 payments, so unlike EIP-3009
+// and ERC-6492 payments they have no flow here.
+package facilitator
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	// anvilKey is the first of anvil's default development accounts
+	anvilKey     = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+	anvilChainID = 31337
+	anvilNetwork = "eip155:31337"
+	anvilTimeout = 30 * time.Second
+	anvilPayTo   = "0x00000000000000000000000000000000000000b0"
+	anvilAmount  = 1_000_000
+)
+
+type artifact struct {
+	ABI      json.RawMessage `json:"abi"`
+	Bytecode struct {
+		Object string `json:"object"`
+	} `json:"bytecode"`
+}
+
+// anvilEnv is a local chain with the test contracts deployed.
+type anvilEnv struct {
+	client   *ethclient.Client
+	deployer []byte
+	token    common.Address
+	factory  common.Address
+
+	facilitator *EVMFacilitator
+}
+
+func newAnvilEnv(t *testing.T) *anvilEnv {
+	t.Helper()
+
+	artifacts := forgeBuild(t)
+	url := anvilURL(t)
+	client, err := ethclient.Dial(url)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	deployer, err := hex.DecodeString(anvilKey)
+	require.NoError(t, err)
+	env := &anvilEnv{
+		client:   client,
+		deployer: deployer,
+	}
+	env.token = env.deploy(t, artifacts, "MockUSDC.sol", "MockUSDC")
+	env.factory = env.deploy(t, artifacts, "SmartWallet.sol", "SmartWalletFactory")
+
+	config := NetworkConfig{
+		Network: anvilNetwork,
+		RPCURLs: []string{url},
+		Assets: []AssetConfig{{
+			Symbol:   "USDC",
+			Address:  env.token.Hex(),
+			Decimals: 6,
+			Name:     "USD Coin",
+			Version:  "2",
+		}},
+	}
+	require.NoError(t, config.Normalize())
+	env.facilitator, err = NewEVMFacilitator(config, anvilKey)
+	require.NoError(t, err)
+	return env
+}
+
+// anvilURL returns ANVIL_URL or the URL of a freshly started anvil node.
+func anvilURL(t *testing.T) string {
+	t.Helper()
+	if url := os.Getenv("ANVIL_URL"); url != "" {
+		return url
+	}
+	bin, err := exec.LookPath("anvil")
+	if err != nil {
+		t.Skip("anvil is not installed and ANVIL_URL is not set")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cmd := exec.Command(bin, "--port", fmt.Sprint(port), "--chain-id", fmt.Sprint(anvilChainID), "--silent")
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+	require.Eventually(t, func() bool {
+		client, err := ethclient.Dial(url)
+		if err != nil {
+			return false
+		}
+		defer client.Close()
+		_, err = client.ChainID(t.Context())
+		return err == nil
+	}, anvilTimeout, 100*time.Millisecond, "anvil did not start")
+	return url
+}
+
+// forgeBuild compiles the test contracts and returns the directory of the artifacts.
+func forgeBuild(t *testing.T) string {
+	t.Helper()
+	bin, err := exec.LookPath("forge")
+	if err != nil {
+		t.Skip("forge is not installed")
+	}
+	root, err := filepath.Abs(filepath.Join("testdata", "contracts"))
+	require.NoError(t, err)
+
+	out, err := exec.Command(bin, "build", "--root", root).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return filepath.Join(root, "out")
+}
+
+func loadArtifact(t *testing.T, dir, file, contract string) (*abi.ABI, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, file, contract+".json"))
+	require.NoError(t, err)
+	var a artifact
+	require.NoError(t, json.Unmarshal(data, &a))
+
+	contractABI, err := abi.JSON(strings.NewReader(string(a.ABI)))
+	require.NoError(t, err)
+	bytecode, err := hex.DecodeString(strings.TrimPrefix(a.Bytecode.Object, "0x"))
+	require.NoError(t, err)
+	return &contractABI, bytecode
+}
+
+// deploy deploys a contract without constructor arguments from the deployer account.
+func (e *anvilEnv) deploy(t *testing.T, artifacts, file, contract string) common.Address {
+	t.Helper()
+	_, bytecode := loadArtifact(t, artifacts, file, contract)
+	receipt := e.send(t, nil, bytecode)
+	require.NotEqual(t, common.Address{}, receipt.ContractAddress)
+	return receipt.ContractAddress
+}
+
+// call executes a contract function from the deployer account and waits for it to be mined.
+func (e *anvilEnv) call(t *testing.T, to common.Address, signature string, args ...any) {
+	t.Helper()
+	method := mustMethod(t, signature)
+	data, err := method.Inputs.Pack(args...)
+	require.NoError(t, err)
+	e.send(t, &to, append(method.ID, data...))
+}
+
+// read executes a view function and returns its single result.
+func (e *anvilEnv) read(t *testing.T, to common.Address, signature string, output string, args ...any) any {
+	t.Helper()
+	method := mustMethod(t, signature)
+	data, err := method.Inputs.Pack(args...)
+	require.NoError(t, err)
+	result, err := e.client.CallContract(t.Context(), ethereum.CallMsg{To: &to, Data: append(method.ID, data...)}, nil)
+	require.NoError(t, err)
+
+	outType, err := abi.NewType(output, "", nil)
+	require.NoError(t, err)
+	values, err := abi.Arguments{{Type: outType}}.Unpack(result)
+	require.NoError(t, err)
+	return values[0]
+}
+
+func (e *anvilEnv) send(t *testing.T, to *common.Address, data []byte) *ethTypes.Receipt {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), anvilTimeout)
+	defer cancel()
+
+	from, err := evm.GetAddrssFromPrivateKey(e.deployer)
+	require.NoError(t, err)
+	nonce, err := e.client.PendingNonceAt(ctx, from)
+	require.NoError(t, err)
+	gasPrice, err := e.client.SuggestGasPrice(ctx)
+	require.NoError(t, err)
+	gas, err := e.client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: to, Data: data})
+	require.NoError(t, err)
+
+	tx := ethTypes.NewTx(&ethTypes.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: gas, To: to, Data: data})
+	signed, err := evm.ToGethSigner(evm.NewRawPrivateSigner(e.deployer), big.NewInt(anvilChainID))(from, tx)
+	require.NoError(t, err)
+	require.NoError(t, e.client.SendTransaction(ctx, signed))
+
+	receipt, err := bind.WaitMined(ctx, e.client, signed.Hash())
+	require.NoError(t, err)
+	require.Equal(t, ethTypes.ReceiptStatusSuccessful, receipt.Status)
+	return receipt
+}
+
+func (e *anvilEnv) balanceOf(t *testing.T, holder common.Address) *big.Int {
+	t.Helper()
+	return e.read(t, e.token, "balanceOf(address)", "uint256", holder).(*big.Int)
+}
+
+// payment builds the payment of an authorization signed with the signature.
+func (e *anvilEnv) payment(t *testing.T, auth *evm.Authorization, signature []byte) (*types.PaymentPayload, *types.PaymentRequirements) {
+	t.Helper()
+	evmPayloadJson, err := json.Marshal(&evm.EVMPayload{
+		Signature:     hex.EncodeToString(signature),
+		Authorization: auth,
+	})
+	require.NoError(t, err)
+
+	payload := &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      string(types.EVM),
+		Network:     anvilNetwork,
+		Payload:     evmPayloadJson,
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           anvilNetwork,
+		MaxAmountRequired: auth.Value.String(),
+		PayTo:             anvilPayTo,
+		Asset:             e.token.Hex(),
+	}
+	return payload, req
+}
+
+// domain returns the EIP-712 domain of the deployed token.
+func (e *anvilEnv) domain() *evm.DomainConfig {
+	return evm.NewDomainConfig("USD Coin", "2", big.NewInt(anvilChainID), e.token.Hex())
+}
+
+// settle verifies and settles the payment and waits for the settlement to be mined.
+func (e *anvilEnv) settle(t *testing.T, payload *types.PaymentPayload, req *types.PaymentRequirements) *Receipt {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), anvilTimeout)
+	defer cancel()
+
+	verified, err := e.facilitator.Verify(ctx, payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+
+	settled, err := e.facilitator.Settle(ctx, payload, req)
+	require.NoError(t, err)
+	require.True(t, settled.Success, settled.Error)
+
+	receipt, err := e.facilitator.WaitMined(ctx, settled.TxHash)
+	require.NoError(t, err)
+	return receipt
+}
+
+func newPayerKey(t *testing.T) ([]byte, common.Address) {
+	t.Helper()
+	privKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	address, err := evm.GetAddrssFromPrivateKey(privKey.Serialize())
+	require.NoError(t, err)
+	return privKey.Serialize(), address
+}
+
+func mustMethod(t *testing.T, signature string) abi.Method {
+	t.Helper()
+	name, params, ok := strings.Cut(strings.TrimSuffix(signature, ")"), "(")
+	require.True(t, ok, signature)
+
+	var inputs abi.Arguments
+	if params != "" {
+		for _, param := range strings.Split(params, ",") {
+			typ, err := abi.NewType(param, "", nil)
+			require.NoError(t, err)
+			inputs = append(inputs, abi.Argument{Type: typ})
+		}
+	}
+	return abi.NewMethod(name, name, abi.Function, "", false, false, inputs, nil)
+}
+
+// sign signs the authorization in the domain of the test token with the key.
+func (e *anvilEnv) sign(key []byte, auth *evm.Authorization) ([]byte, error) {
+	digest, err := evm.HashEip3009(auth, e.domain())
+	if err != nil {
+		return nil, err
+	}
+	return evm.NewRawPrivateSigner(key)(digest)
+}
+
+func TestAnvilEIP3009(t *testing.T) {
+	env := newAnvilEnv(t)
+	payerKey, payer := newPayerKey(t)
+	env.call(t, env.token, "mint(address,uint256)", payer, big.NewInt(anvilAmount))
+
+	auth := evm.NewAuthorization(payer.Hex(), anvilPayTo, big.NewInt(anvilAmount))
+	signature, err := env.sign(payerKey, auth)
+	require.NoError(t, err)
+	payload, req := env.payment(t, auth, signature)
+
+	receipt := env.settle(t, payload, req)
+	require.True(t, receipt.Success)
+	require.Zero(t, env.balanceOf(t, payer).Sign())
+	require.Equal(t, int64(anvilAmount), env.balanceOf(t, common.HexToAddress(anvilPayTo)).Int64())
+
+	t.Run("replayed authorization is rejected on chain", func(t *testing.T) {
+		env.call(t, env.token, "mint(address,uint256)", payer, big.NewInt(anvilAmount))
+
+		estimate, err := env.facilitator.Estimate(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, estimate.Success)
+		require.Contains(t, estimate.Error, "authorization is used")
+	})
+
+	t.Run("signature of another account is rejected", func(t *testing.T) {
+		otherKey, _ := newPayerKey(t)
+		auth := evm.NewAuthorization(payer.Hex(), anvilPayTo, big.NewInt(anvilAmount))
+		signature, err := env.sign(otherKey, auth)
+		require.NoError(t, err)
+		payload, req := env.payment(t, auth, signature)
+
+		verified, err := env.facilitator.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, verified.IsValid)
+		require.Equal(t, types.ErrInvalidSignature.Error(), verified.InvalidReason)
+	})
+}
+
+func TestAnvilERC6492(t *testing.T) {
+	env := newAnvilEnv(t)
+	ownerKey, owner := newPayerKey(t)
+	salt := [32]byte{1}
+
+	// the wallet is counterfactual: it receives funds before it is deployed
+	wallet := env.read(t, env.factory, "walletAddress(address,bytes32)", "address", owner, salt).(common.Address)
+	env.call(t, env.token, "mint(address,uint256)", wallet, big.NewInt(anvilAmount))
+	code, err := env.client.CodeAt(t.Context(), wallet, nil)
+	require.NoError(t, err)
+	require.Empty(t, code)
+
+	auth := evm.NewAuthorization(wallet.Hex(), anvilPayTo, big.NewInt(anvilAmount))
+	inner, err := env.sign(ownerKey, auth)
+	require.NoError(t, err)
+
+	deploy := mustMethod(t, "deploy(address,bytes32)")
+	deployArgs, err := deploy.Inputs.Pack(owner, salt)
+	require.NoError(t, err)
+	signature := wrapERC6492(t, env.factory, append(deploy.ID, deployArgs...), inner)
+	payload, req := env.payment(t, auth, signature)
+
+	receipt := env.settle(t, payload, req)
+	require.True(t, receipt.Success)
+
+	code, err = env.client.CodeAt(t.Context(), wallet, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, code, "the wallet is deployed on settlement")
+	require.Zero(t, env.balanceOf(t, wallet).Sign())
+	require.Equal(t, int64(anvilAmount), env.balanceOf(t, common.HexToAddress(anvilPayTo)).Int64())
+
+	t.Run("deployed wallet signs with EIP-1271", func(t *testing.T) {
+		env.call(t, env.token, "mint(address,uint256)", wallet, big.NewInt(anvilAmount))
+		auth := evm.NewAuthorization(wallet.Hex(), anvilPayTo, big.NewInt(anvilAmount))
+		signature, err := env.sign(ownerKey, auth)
+		require.NoError(t, err)
+
+		// the wallet only accepts 65 byte signatures of its owner
+		payload, req := env.payment(t, auth, append(signature, 0))
+		verified, err := env.facilitator.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, verified.IsValid)
+
+		payload, req = env.payment(t, auth, signature)
+		receipt := env.settle(t, payload, req)
+		require.True(t, receipt.Success)
+	})
+}
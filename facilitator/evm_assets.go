@@ -0,0 +1,103 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+var _ AssetUpdater = (*EVMFacilitator)(nil)
+
+// assetRegistryABI declares the function asset registry contracts list the
+// accepted tokens with. An empty transfer method means "eip3009".
+var assetRegistryABI = []byte(`[
+	{"type":"function","name":"assets","stateMutability":"view","inputs":[],"outputs":[
+		{"name":"","type":"tuple[]","components":[
+			{"name":"token","type":"address"},
+			{"name":"symbol","type":"string"},
+			{"name":"decimals","type":"uint8"},
+			{"name":"name","type":"string"},
+			{"name":"version","type":"string"},
+			{"name":"transferMethod","type":"string"}
+		]}
+	]}
+]`)
+
+// registryAsset is an entry of the asset list of a registry contract
+type registryAsset struct {
+	Token          common.Address
+	Symbol         string
+	Decimals       uint8
+	Name           string
+	Version        string
+	TransferMethod string
+}
+
+// SetAssets replaces the accepted assets. Payments in assets that were removed
+// are rejected from then on, settlements already submitted are followed to the end.
+func (t *EVMFacilitator) SetAssets(configs []AssetConfig) error {
+	if len(configs) == 0 {
+		return errors.New("no assets listed")
+	}
+	chainInfo := evm.GetChainInfo(t.chainName)
+	for _, config := range configs {
+		// listed assets aren't validated like the configuration
+		if config, _ := withPreset(config, chainInfo); config.Name == "" || config.Version == "" {
+			return fmt.Errorf("network %s: asset %s: name and version of the EIP-712 domain are required", t.network, config.Symbol)
+		}
+	}
+	assets, err := evmAssets(configs, t.networkID, chainInfo)
+	if err != nil {
+		return fmt.Errorf("network %s: %w", t.network, err)
+	}
+	t.assetsMu.Lock()
+	defer t.assetsMu.Unlock()
+	// the list replaces the configured assets, including those not resolved yet
+	t.unresolved = nil
+	t.assetsVersion++
+	t.assets.Store(&assets)
+	return nil
+}
+
+// RegistryAssets reads the tokens listed by the asset registry contract.
+func (t *EVMFacilitator) RegistryAssets(ctx context.Context, contract string) ([]AssetConfig, error) {
+	if !common.IsHexAddress(contract) {
+		return nil, fmt.Errorf("invalid asset registry address %q", contract)
+	}
+	result, err := t.signer.ReadContract(ctx, contract, assetRegistryABI, "assets")
+	if err != nil {
+		return nil, fmt.Errorf("network %s: asset registry %s: %w", t.network, contract, err)
+	}
+	listed, err := registryAssets(result)
+	if err != nil {
+		return nil, fmt.Errorf("network %s: asset registry %s: %w", t.network, contract, err)
+	}
+	configs := make([]AssetConfig, 0, len(listed))
+	for _, asset := range listed {
+		configs = append(configs, AssetConfig{
+			Symbol:         asset.Symbol,
+			Address:        asset.Token.Hex(),
+			Decimals:       int(asset.Decimals),
+			Name:           asset.Name,
+			Version:        asset.Version,
+			TransferMethod: asset.TransferMethod,
+		})
+	}
+	return configs, nil
+}
+
+// registryAssets converts the tuples the registry returned, ConvertType panics
+// on results of another shape.
+func registryAssets(result any) (listed []registryAsset, err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("unexpected result %T", result)
+		}
+	}()
+	return *abi.ConvertType(result, new([]registryAsset)).(*[]registryAsset), nil
+}
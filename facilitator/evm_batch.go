@@ -0,0 +1,120 @@
+package facilitator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+)
+
+var _ verifyBatcher = (*EVMRPCSigner)(nil)
+
+// verifyReads is the chain state verifying an authorization reads.
+type verifyReads struct {
+	chainID *big.Int
+	head    *ethTypes.Header
+	// token balance of the payer
+	balance *big.Int
+	// whether the authorization was used or canceled
+	used bool
+	// code of the payer, empty for externally owned accounts
+	code []byte
+}
+
+// verifyBatcher is implemented by signers that can read the chain state of a
+// verification in a single JSON-RPC batch instead of one round trip per read.
+type verifyBatcher interface {
+	BatchVerifyReads(ctx context.Context, token, payer common.Address, nonce [32]byte) (*verifyReads, error)
+}
+
+// BatchVerifyReads reads the chain ID, the latest header, the token balance and
+// the authorization state of the payer, and the code of the payer in one batch.
+func (s *EVMRPCSigner) BatchVerifyReads(ctx context.Context, token, payer common.Address, nonce [32]byte) (*verifyReads, error) {
+	balanceABI, balanceCall, err := packCall(sdk.ERC20BalanceOfABI, "balanceOf", payer)
+	if err != nil {
+		return nil, err
+	}
+	stateABI, stateCall, err := packCall(sdk.AuthorizationStateABI, "authorizationState", payer, nonce)
+	if err != nil {
+		return nil, err
+	}
+	call := func(data []byte) map[string]any {
+		return map[string]any{"to": token, "data": hexutil.Bytes(data)}
+	}
+
+	var (
+		chainID    hexutil.Big
+		head       *ethTypes.Header
+		balanceOut hexutil.Bytes
+		stateOut   hexutil.Bytes
+		code       hexutil.Bytes
+	)
+	batch := []rpc.BatchElem{
+		{Method: "eth_chainId", Result: &chainID},
+		{Method: "eth_getBlockByNumber", Args: []any{"latest", false}, Result: &head},
+		{Method: "eth_call", Args: []any{call(balanceCall), "latest"}, Result: &balanceOut},
+		{Method: "eth_call", Args: []any{call(stateCall), "latest"}, Result: &stateOut},
+		{Method: "eth_getCode", Args: []any{payer, "latest"}, Result: &code},
+	}
+	if err := s.client.BatchCallContext(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to send batch: %w", err)
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("failed to call %s: %w", elem.Method, elem.Error)
+		}
+	}
+	if head == nil {
+		return nil, fmt.Errorf("latest block not found")
+	}
+
+	balance, err := balanceABI.Unpack("balanceOf", balanceOut)
+	if err != nil || len(balance) != 1 {
+		return nil, fmt.Errorf("failed to unpack balanceOf result: %w", err)
+	}
+	state, err := stateABI.Unpack("authorizationState", stateOut)
+	if err != nil || len(state) != 1 {
+		return nil, fmt.Errorf("failed to unpack authorizationState result: %w", err)
+	}
+	reads := &verifyReads{
+		chainID: (*big.Int)(&chainID),
+		head:    head,
+		code:    code,
+	}
+	var ok bool
+	if reads.balance, ok = balance[0].(*big.Int); !ok {
+		return nil, fmt.Errorf("unexpected balanceOf result %T", balance[0])
+	}
+	if reads.used, ok = state[0].(bool); !ok {
+		return nil, fmt.Errorf("unexpected authorizationState result %T", state[0])
+	}
+	return reads, nil
+}
+
+// prefetchedCodeKey is the context key of code already read for a verification
+type prefetchedCodeKey struct{}
+
+type prefetchedCode struct {
+	address common.Address
+	code    []byte
+}
+
+// withPrefetchedCode lets GetCode answer for the address without a read.
+func withPrefetchedCode(ctx context.Context, address common.Address, code []byte) context.Context {
+	return context.WithValue(ctx, prefetchedCodeKey{}, prefetchedCode{address: address, code: code})
+}
+
+// codeFromContext returns the code of the address if it was prefetched.
+func codeFromContext(ctx context.Context, address common.Address) ([]byte, bool) {
+	prefetched, ok := ctx.Value(prefetchedCodeKey{}).(prefetchedCode)
+	if !ok || prefetched.address != address {
+		return nil, false
+	}
+	return prefetched.code, true
+}
@@ -0,0 +1,92 @@
+package facilitator
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+)
+
+func TestBatchVerifyReads(t *testing.T) {
+	token := common.HexToAddress("0x036CbD53842c5426634e7929541eC2318f3dCF7e")
+	payer := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	nonce := [32]byte{1}
+	_, balanceCall, err := packCall(sdk.ERC20BalanceOfABI, "balanceOf", payer)
+	require.NoError(t, err)
+	head, err := json.Marshal(&ethTypes.Header{Number: big.NewInt(100), Time: 1_700_000_000, Difficulty: big.NewInt(0)})
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var calls []struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&calls), "reads are sent as a batch")
+
+		answers := make([]map[string]any, len(calls))
+		for i, call := range calls {
+			var result any
+			switch call.Method {
+			case "eth_chainId":
+				result = "0x14a34"
+			case "eth_getBlockByNumber":
+				result = json.RawMessage(head)
+			case "eth_call":
+				var msg struct {
+					To   common.Address `json:"to"`
+					Data hexutil.Bytes  `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(call.Params[0], &msg))
+				require.Equal(t, token, msg.To)
+				if bytes.Equal(msg.Data, balanceCall) {
+					result = hexutil.Encode(common.LeftPadBytes(big.NewInt(5_000).Bytes(), 32))
+				} else {
+					result = hexutil.Encode(common.LeftPadBytes([]byte{1}, 32))
+				}
+			case "eth_getCode":
+				result = "0x6001"
+			}
+			answers[i] = map[string]any{"jsonrpc": "2.0", "id": call.ID, "result": result}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(answers))
+	}))
+	defer srv.Close()
+
+	client, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+	defer client.Close()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := NewEVMRPCSigner(client, big.NewInt(84532), crypto.FromECDSA(key), GasPolicy{})
+	require.NoError(t, err)
+
+	reads, err := signer.BatchVerifyReads(t.Context(), token, payer, nonce)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), requests.Load(), "all reads take one round trip")
+	require.Equal(t, int64(84532), reads.chainID.Int64())
+	require.Equal(t, uint64(100), reads.head.Number.Uint64())
+	require.Equal(t, int64(5_000), reads.balance.Int64())
+	require.True(t, reads.used)
+	require.Equal(t, []byte{0x60, 0x01}, reads.code)
+
+	code, err := signer.GetCode(withPrefetchedCode(t.Context(), payer, reads.code), payer.Hex())
+	require.NoError(t, err)
+	require.Equal(t, reads.code, code)
+	require.Equal(t, int32(1), requests.Load(), "prefetched code isn't read again")
+}
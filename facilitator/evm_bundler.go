@@ -0,0 +1,413 @@
+package facilitator
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+// EntryPointV07 is the canonical address of the ERC-4337 v0.7 EntryPoint
+const EntryPointV07 = "0x0000000071727De22E5E9d8BAf0edAc6f37da032"
+
+// userOperationPollInterval is how often the bundler is asked whether a user operation was included
+const userOperationPollInterval = time.Second
+
+// dummySignature recovers to an address without reverting, bundlers simulate
+// user operations carrying it to estimate their gas before they are signed
+var dummySignature = hexutil.MustDecode("0xfffffffffffffffffffffffffffffff0000000000000000000000000000000007aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1c")
+
+// entryPointABIJSON is the part of the v0.7 EntryPoint used to build user operations and read their outcome
+var entryPointABIJSON = []byte(`[
+	{"name":"getNonce","type":"function","stateMutability":"view","inputs":[{"name":"sender","type":"address"},{"name":"key","type":"uint192"}],"outputs":[{"name":"nonce","type":"uint256"}]},
+	{"name":"UserOperationEvent","type":"event","inputs":[
+		{"name":"userOpHash","type":"bytes32","indexed":true},
+		{"name":"sender","type":"address","indexed":true},
+		{"name":"paymaster","type":"address","indexed":true},
+		{"name":"nonce","type":"uint256","indexed":false},
+		{"name":"success","type":"bool","indexed":false},
+		{"name":"actualGasCost","type":"uint256","indexed":false},
+		{"name":"actualGasUsed","type":"uint256","indexed":false}
+	]}
+]`)
+
+var (
+	entryPointABI   = evm.MustParseABI(string(entryPointABIJSON))
+	smartAccountABI = evm.MustParseABI(`[
+		{"name":"execute","type":"function","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address"},{"name":"value","type":"uint256"},{"name":"func","type":"bytes"}],"outputs":[]}
+	]`)
+
+	// userOpHashArgs encode the packed user operation of the v0.7 EntryPoint
+	userOpHashArgs = abi.Arguments{
+		{Type: mustABIType("address")}, // sender
+		{Type: mustABIType("uint256")}, // nonce
+		{Type: mustABIType("bytes32")}, // hash of initCode
+		{Type: mustABIType("bytes32")}, // hash of callData
+		{Type: mustABIType("bytes32")}, // accountGasLimits
+		{Type: mustABIType("uint256")}, // preVerificationGas
+		{Type: mustABIType("bytes32")}, // gasFees
+		{Type: mustABIType("bytes32")}, // hash of paymasterAndData
+	}
+	userOpDomainArgs = abi.Arguments{
+		{Type: mustABIType("bytes32")}, // hash of the packed user operation
+		{Type: mustABIType("address")}, // entry point
+		{Type: mustABIType("uint256")}, // chain ID
+	}
+)
+
+// HashSigner signs arbitrary digests. Smart accounts accept user operations
+// signed by their owner, so bundler settlement needs a key implementing it.
+type HashSigner interface {
+	SignHash(digest []byte) ([]byte, error)
+}
+
+var _ EVMSigner = (*EVMBundlerSigner)(nil)
+var _ HashSigner = (*PrivateKeySigner)(nil)
+
+// EVMBundlerSigner settles through an ERC-4337 smart account. Every call is
+// wrapped into a user operation that a paymaster sponsors and a bundler
+// includes on chain, so the signer key needs no native gas tokens. Reads,
+// receipts and gas estimates go through the wrapped signer.
+//
+// Submitting a call blocks until the bundler included the user operation,
+// which is when the hash of the bundle transaction is known.
+type EVMBundlerSigner struct {
+	EVMSigner
+
+	bundler    *rpc.Client
+	paymaster  *rpc.Client
+	context    map[string]any
+	entryPoint common.Address
+	account    common.Address
+	chainID    *big.Int
+	key        HashSigner
+}
+
+// NewEVMBundlerSigner connects to the bundler and paymaster of the configuration.
+// signer serves reads and receipts, key owns the smart account.
+func NewEVMBundlerSigner(signer EVMSigner, key HashSigner, chainID *big.Int, config BundlerConfig) (*EVMBundlerSigner, error) {
+	bundler, err := rpc.DialOptions(context.Background(), config.URL, outbound.RPCOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bundler %s: %w", config.URL, err)
+	}
+	paymaster := bundler
+	if config.PaymasterURL != "" && config.PaymasterURL != config.URL {
+		paymaster, err = rpc.DialOptions(context.Background(), config.PaymasterURL, outbound.RPCOptions()...)
+		if err != nil {
+			bundler.Close()
+			return nil, fmt.Errorf("failed to connect to paymaster %s: %w", config.PaymasterURL, err)
+		}
+	}
+	return &EVMBundlerSigner{
+		EVMSigner:  signer,
+		bundler:    bundler,
+		paymaster:  paymaster,
+		context:    config.PaymasterContext,
+		entryPoint: common.HexToAddress(config.EntryPoint),
+		account:    common.HexToAddress(config.Account),
+		chainID:    chainID,
+		key:        key,
+	}, nil
+}
+
+// GetAddresses returns the smart account, which is the sender of settlements.
+func (s *EVMBundlerSigner) GetAddresses() []string {
+	return []string{s.account.Hex()}
+}
+
+func (s *EVMBundlerSigner) WriteContract(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) (string, error) {
+	_, data, err := packCall(abiJSON, functionName, args...)
+	if err != nil {
+		return "", err
+	}
+	return s.SendTransaction(ctx, address, data)
+}
+
+// SendTransaction executes the call from the smart account through a sponsored
+// user operation and returns the hash of the bundle transaction including it.
+func (s *EVMBundlerSigner) SendTransaction(ctx context.Context, to string, data []byte) (string, error) {
+	callData, err := smartAccountABI.Pack("execute", common.HexToAddress(to), new(big.Int), data)
+	if err != nil {
+		return "", err
+	}
+	op, err := s.prepare(ctx, callData)
+	if err != nil {
+		return "", err
+	}
+
+	hash := op.hash(s.entryPoint, s.chainID)
+	signature, err := s.key.SignHash(accounts.TextHash(hash[:]))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign user operation: %w", err)
+	}
+	op.Signature = signature
+
+	var opHash common.Hash
+	if err := s.bundler.CallContext(ctx, &opHash, "eth_sendUserOperation", op, s.entryPoint); err != nil {
+		return "", fmt.Errorf("failed to send user operation: %w", err)
+	}
+	logging.Ctx(ctx, logging.RPC).Debug().Str("user_op_hash", opHash.Hex()).Msg("Sent user operation to bundler")
+	return s.waitIncluded(ctx, opHash)
+}
+
+// prepare builds the user operation of the call with its gas limits and paymaster data.
+func (s *EVMBundlerSigner) prepare(ctx context.Context, callData []byte) (*userOperation, error) {
+	// a random nonce key lets concurrent settlements use independent nonce sequences
+	var key [24]byte
+	rand.Read(key[:])
+	nonceKey := new(big.Int).SetBytes(key[:])
+	result, err := s.EVMSigner.ReadContract(ctx, s.entryPoint.Hex(), entryPointABIJSON, "getNonce", s.account, nonceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce of %s: %w", s.account, err)
+	}
+	nonce, ok := result.(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected getNonce result %T", result)
+	}
+	gasPrice, err := s.EVMSigner.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &userOperation{
+		Sender:               s.account,
+		Nonce:                (*hexutil.Big)(nonce),
+		CallData:             callData,
+		CallGasLimit:         new(hexutil.Big),
+		VerificationGasLimit: new(hexutil.Big),
+		PreVerificationGas:   new(hexutil.Big),
+		MaxFeePerGas:         (*hexutil.Big)(gasPrice),
+		MaxPriorityFeePerGas: (*hexutil.Big)(gasPrice),
+		Signature:            dummySignature,
+	}
+	chainID := (*hexutil.Big)(s.chainID)
+
+	// ERC-7677: stub paymaster data makes the gas estimation realistic, the final data commits to the gas limits
+	var stub paymasterData
+	if err := s.paymaster.CallContext(ctx, &stub, "pm_getPaymasterStubData", op, s.entryPoint, chainID, s.context); err != nil {
+		return nil, fmt.Errorf("failed to get paymaster stub data: %w", err)
+	}
+	stub.apply(op)
+
+	var gas userOperationGas
+	if err := s.bundler.CallContext(ctx, &gas, "eth_estimateUserOperationGas", op, s.entryPoint); err != nil {
+		return nil, fmt.Errorf("failed to estimate user operation gas: %w", err)
+	}
+	if err := gas.apply(op); err != nil {
+		return nil, err
+	}
+
+	if !stub.IsFinal {
+		var final paymasterData
+		if err := s.paymaster.CallContext(ctx, &final, "pm_getPaymasterData", op, s.entryPoint, chainID, s.context); err != nil {
+			return nil, fmt.Errorf("failed to get paymaster data: %w", err)
+		}
+		final.apply(op)
+	}
+	return op, nil
+}
+
+// waitIncluded polls the bundler until the user operation is included and returns the bundle transaction hash.
+func (s *EVMBundlerSigner) waitIncluded(ctx context.Context, opHash common.Hash) (string, error) {
+	ticker := time.NewTicker(userOperationPollInterval)
+	defer ticker.Stop()
+	for {
+		var receipt *userOperationReceipt
+		if err := s.bundler.CallContext(ctx, &receipt, "eth_getUserOperationReceipt", opHash); err != nil {
+			return "", fmt.Errorf("failed to get receipt of user operation %s: %w", opHash, err)
+		}
+		if receipt != nil {
+			return receipt.Receipt.TransactionHash.Hex(), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("waiting for inclusion of user operation %s: %w", opHash, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForTransactionReceipt waits for the bundle transaction. Its status is the
+// outcome of the user operations of the smart account, not of the bundle.
+func (s *EVMBundlerSigner) WaitForTransactionReceipt(ctx context.Context, txHash string) (*sdk.TransactionReceipt, error) {
+	if _, err := s.EVMSigner.WaitForTransactionReceipt(ctx, txHash); err != nil {
+		return nil, err
+	}
+	receipt, err := s.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return &sdk.TransactionReceipt{
+		Status:      receipt.Status,
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		TxHash:      receipt.TxHash.Hex(),
+	}, nil
+}
+
+// TransactionReceipt returns the receipt of the bundle transaction reduced to the
+// user operations of the smart account: it failed if any of them failed, and the
+// gas is what the EntryPoint charged for them rather than the gas of the bundle.
+func (s *EVMBundlerSigner) TransactionReceipt(ctx context.Context, txHash string) (*ethTypes.Receipt, error) {
+	bundle, err := s.EVMSigner.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := *bundle
+	receipt.Status = ethTypes.ReceiptStatusSuccessful
+	gasUsed, gasCost := new(big.Int), new(big.Int)
+	found := false
+	event := entryPointABI.Events["UserOperationEvent"]
+	for _, l := range bundle.Logs {
+		if l.Address != s.entryPoint || len(l.Topics) != 4 || l.Topics[0] != event.ID || common.BytesToAddress(l.Topics[2][:]) != s.account {
+			continue
+		}
+		values, err := event.Inputs.NonIndexed().Unpack(l.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpack user operation event: %w", err)
+		}
+		found = true
+		if !values[1].(bool) {
+			receipt.Status = ethTypes.ReceiptStatusFailed
+		}
+		gasCost.Add(gasCost, values[2].(*big.Int))
+		gasUsed.Add(gasUsed, values[3].(*big.Int))
+	}
+	if !found {
+		return nil, fmt.Errorf("transaction %s includes no user operation of %s", txHash, s.account)
+	}
+
+	receipt.GasUsed = gasUsed.Uint64()
+	receipt.EffectiveGasPrice = nil
+	if gasUsed.Sign() > 0 {
+		receipt.EffectiveGasPrice = new(big.Int).Quo(gasCost, gasUsed)
+	}
+	return &receipt, nil
+}
+
+// userOperation is the v0.7 user operation in its JSON-RPC representation.
+type userOperation struct {
+	Sender                        common.Address  `json:"sender"`
+	Nonce                         *hexutil.Big    `json:"nonce"`
+	CallData                      hexutil.Bytes   `json:"callData"`
+	CallGasLimit                  *hexutil.Big    `json:"callGasLimit"`
+	VerificationGasLimit          *hexutil.Big    `json:"verificationGasLimit"`
+	PreVerificationGas            *hexutil.Big    `json:"preVerificationGas"`
+	MaxFeePerGas                  *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas          *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Paymaster                     *common.Address `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit,omitempty"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData,omitempty"`
+	Signature                     hexutil.Bytes   `json:"signature"`
+}
+
+// hash returns the hash the EntryPoint lets the smart account validate the signature over.
+func (op *userOperation) hash(entryPoint common.Address, chainID *big.Int) common.Hash {
+	var paymasterAndData []byte
+	if op.Paymaster != nil {
+		paymasterAndData = append(paymasterAndData, op.Paymaster.Bytes()...)
+		paymasterAndData = append(paymasterAndData, packUint128s(op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit)...)
+		paymasterAndData = append(paymasterAndData, op.PaymasterData...)
+	}
+	packed, _ := userOpHashArgs.Pack(
+		op.Sender,
+		op.Nonce.ToInt(),
+		crypto.Keccak256Hash(nil), // the smart account is deployed, initCode is empty
+		crypto.Keccak256Hash(op.CallData),
+		[32]byte(packUint128s(op.VerificationGasLimit, op.CallGasLimit)),
+		op.PreVerificationGas.ToInt(),
+		[32]byte(packUint128s(op.MaxPriorityFeePerGas, op.MaxFeePerGas)),
+		crypto.Keccak256Hash(paymasterAndData),
+	)
+	encoded, _ := userOpDomainArgs.Pack(crypto.Keccak256Hash(packed), entryPoint, chainID)
+	return crypto.Keccak256Hash(encoded)
+}
+
+// packUint128s concatenates two values as 16 byte big endian integers.
+func packUint128s(high, low *hexutil.Big) []byte {
+	packed := make([]byte, 32)
+	if high != nil {
+		high.ToInt().FillBytes(packed[:16])
+	}
+	if low != nil {
+		low.ToInt().FillBytes(packed[16:])
+	}
+	return packed
+}
+
+// userOperationGas is the result of eth_estimateUserOperationGas.
+type userOperationGas struct {
+	PreVerificationGas            *hexutil.Big `json:"preVerificationGas"`
+	VerificationGasLimit          *hexutil.Big `json:"verificationGasLimit"`
+	CallGasLimit                  *hexutil.Big `json:"callGasLimit"`
+	PaymasterVerificationGasLimit *hexutil.Big `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       *hexutil.Big `json:"paymasterPostOpGasLimit"`
+}
+
+func (g *userOperationGas) apply(op *userOperation) error {
+	if g.PreVerificationGas == nil || g.VerificationGasLimit == nil || g.CallGasLimit == nil {
+		return fmt.Errorf("bundler returned incomplete gas estimate")
+	}
+	op.PreVerificationGas = g.PreVerificationGas
+	op.VerificationGasLimit = g.VerificationGasLimit
+	op.CallGasLimit = g.CallGasLimit
+	if g.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = g.PaymasterVerificationGasLimit
+	}
+	if g.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = g.PaymasterPostOpGasLimit
+	}
+	return nil
+}
+
+// paymasterData is the result of the ERC-7677 paymaster methods.
+type paymasterData struct {
+	Paymaster                     *common.Address `json:"paymaster"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit"`
+	// IsFinal tells that the stub data can be used as is
+	IsFinal bool `json:"isFinal"`
+}
+
+func (p *paymasterData) apply(op *userOperation) {
+	op.Paymaster = p.Paymaster
+	op.PaymasterData = p.PaymasterData
+	if p.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = p.PaymasterVerificationGasLimit
+	}
+	if p.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = p.PaymasterPostOpGasLimit
+	}
+}
+
+// userOperationReceipt is the part of the eth_getUserOperationReceipt result used to find the bundle.
+type userOperationReceipt struct {
+	Receipt struct {
+		TransactionHash common.Hash `json:"transactionHash"`
+	} `json:"receipt"`
+}
+
+func mustABIType(name string) abi.Type {
+	t, err := abi.NewType(name, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -0,0 +1,195 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+)
+
+var (
+	testSmartAccount = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	testPaymaster    = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+)
+
+// bundledChain adds the EntryPoint to the mock chain: nonces and the events of included user operations
+type bundledChain struct {
+	*mock.EVMSigner
+
+	mu     sync.Mutex
+	events map[common.Hash][]*ethTypes.Log // by bundle transaction
+}
+
+func (c *bundledChain) ReadContract(ctx context.Context, address string, abi []byte, functionName string, args ...any) (any, error) {
+	if functionName == "getNonce" {
+		return new(big.Int).Lsh(args[1].(*big.Int), 64), nil
+	}
+	return c.EVMSigner.ReadContract(ctx, address, abi, functionName, args...)
+}
+
+func (c *bundledChain) TransactionReceipt(ctx context.Context, txHash string) (*ethTypes.Receipt, error) {
+	receipt, err := c.EVMSigner.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	receipt.Logs = c.events[common.HexToHash(txHash)]
+	return receipt, nil
+}
+
+// fakeBundler serves the bundler and ERC-7677 paymaster methods, it includes every user operation in its own bundle
+type fakeBundler struct {
+	t       *testing.T
+	chain   *bundledChain
+	owner   common.Address
+	chainID *big.Int
+	fail    bool
+
+	receipts map[common.Hash]common.Hash // bundle transaction by user operation
+}
+
+type fakePaymaster struct {
+	stubs int
+}
+
+func (p *fakePaymaster) GetPaymasterStubData(op userOperation, entryPoint common.Address, chainID hexutil.Big, ctx map[string]any) (*paymasterData, error) {
+	p.stubs++
+	if ctx["policy"] != "x402" {
+		return nil, errors.New("unknown sponsorship policy")
+	}
+	return &paymasterData{
+		Paymaster:               &testPaymaster,
+		PaymasterData:           []byte("stub"),
+		PaymasterPostOpGasLimit: (*hexutil.Big)(big.NewInt(10_000)),
+	}, nil
+}
+
+func (p *fakePaymaster) GetPaymasterData(op userOperation, entryPoint common.Address, chainID hexutil.Big, ctx map[string]any) (*paymasterData, error) {
+	if op.CallGasLimit.ToInt().Sign() == 0 {
+		return nil, errors.New("paymaster data must commit to the estimated gas")
+	}
+	return &paymasterData{Paymaster: &testPaymaster, PaymasterData: []byte("final")}, nil
+}
+
+func (b *fakeBundler) EstimateUserOperationGas(op userOperation, entryPoint common.Address) (*userOperationGas, error) {
+	if string(op.PaymasterData) != "stub" {
+		return nil, errors.New("expected stub paymaster data")
+	}
+	return &userOperationGas{
+		PreVerificationGas:            (*hexutil.Big)(big.NewInt(50_000)),
+		VerificationGasLimit:          (*hexutil.Big)(big.NewInt(100_000)),
+		CallGasLimit:                  (*hexutil.Big)(big.NewInt(80_000)),
+		PaymasterVerificationGasLimit: (*hexutil.Big)(big.NewInt(30_000)),
+	}, nil
+}
+
+func (b *fakeBundler) SendUserOperation(op userOperation, entryPoint common.Address) (common.Hash, error) {
+	if string(op.PaymasterData) != "final" || op.PaymasterPostOpGasLimit.ToInt().Int64() != 10_000 {
+		return common.Hash{}, errors.New("unexpected paymaster data")
+	}
+	hash := op.hash(entryPoint, b.chainID)
+	pub, err := crypto.SigToPub(accounts.TextHash(hash[:]), append(op.Signature[:64:64], op.Signature[64]-27))
+	if err != nil || crypto.PubkeyToAddress(*pub) != b.owner {
+		return common.Hash{}, errors.New("AA24 signature error")
+	}
+
+	txHash, err := b.chain.SendTransaction(context.Background(), entryPoint.Hex(), nil)
+	require.NoError(b.t, err)
+	data, err := entryPointABI.Events["UserOperationEvent"].Inputs.NonIndexed().Pack(op.Nonce.ToInt(), !b.fail, big.NewInt(2_000_000), big.NewInt(200_000))
+	require.NoError(b.t, err)
+
+	b.chain.mu.Lock()
+	b.chain.events[common.HexToHash(txHash)] = []*ethTypes.Log{{
+		Address: entryPoint,
+		Topics:  []common.Hash{entryPointABI.Events["UserOperationEvent"].ID, hash, common.BytesToHash(op.Sender[:]), common.BytesToHash(testPaymaster[:])},
+		Data:    data,
+	}}
+	b.chain.mu.Unlock()
+	b.receipts[hash] = common.HexToHash(txHash)
+	return hash, nil
+}
+
+func (b *fakeBundler) GetUserOperationReceipt(hash common.Hash) (*userOperationReceipt, error) {
+	txHash, ok := b.receipts[hash]
+	if !ok {
+		return nil, nil
+	}
+	receipt := &userOperationReceipt{}
+	receipt.Receipt.TransactionHash = txHash
+	return receipt, nil
+}
+
+func TestEVMBundlerSigner(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	key, err := NewPrivateKeySigner(privKey.Serialize())
+	require.NoError(t, err)
+
+	chainID := big.NewInt(84532)
+	chain := &bundledChain{EVMSigner: mock.NewEVMSigner(chainID.Int64(), key.Address().Hex()), events: make(map[common.Hash][]*ethTypes.Log)}
+	bundler := &fakeBundler{t: t, chain: chain, owner: key.Address(), chainID: chainID, receipts: make(map[common.Hash]common.Hash)}
+	paymaster := &fakePaymaster{}
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", bundler))
+	require.NoError(t, server.RegisterName("pm", paymaster))
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+
+	config := NetworkConfig{
+		Network: "eip155:84532",
+		Bundler: BundlerConfig{URL: srv.URL, Account: testSmartAccount.Hex(), PaymasterContext: map[string]any{"policy": "x402"}},
+	}
+	require.NoError(t, config.Normalize())
+	require.Equal(t, EntryPointV07, config.Bundler.EntryPoint)
+	signer, err := NewEVMBundlerSigner(chain, key, chainID, config.Bundler)
+	require.NoError(t, err)
+	require.Equal(t, []string{testSmartAccount.Hex()}, signer.GetAddresses())
+
+	t.Run("calls are settled through sponsored user operations", func(t *testing.T) {
+		txHash, err := signer.WriteContract(t.Context(), testSmartAccount.Hex(), eip3009ABI, "transferWithAuthorization",
+			key.Address(), testPaymaster, big.NewInt(1), big.NewInt(0), big.NewInt(1), [32]byte{1}, []byte{})
+		require.NoError(t, err)
+		require.Equal(t, 1, paymaster.stubs)
+
+		receipt, err := signer.WaitForTransactionReceipt(t.Context(), txHash)
+		require.NoError(t, err)
+		require.Equal(t, ethTypes.ReceiptStatusSuccessful, receipt.Status)
+
+		full, err := signer.TransactionReceipt(t.Context(), txHash)
+		require.NoError(t, err)
+		require.Equal(t, uint64(200_000), full.GasUsed, "gas charged for the user operation, not the bundle")
+		require.Equal(t, big.NewInt(10), full.EffectiveGasPrice)
+	})
+
+	t.Run("failed user operations fail the settlement", func(t *testing.T) {
+		bundler.fail = true
+		txHash, err := signer.SendTransaction(t.Context(), testSmartAccount.Hex(), []byte{0x01})
+		require.NoError(t, err)
+
+		receipt, err := signer.WaitForTransactionReceipt(t.Context(), txHash)
+		require.NoError(t, err)
+		require.Equal(t, ethTypes.ReceiptStatusFailed, receipt.Status)
+	})
+
+	t.Run("bundles without user operations of the account are rejected", func(t *testing.T) {
+		txHash, err := chain.SendTransaction(t.Context(), testSmartAccount.Hex(), nil)
+		require.NoError(t, err)
+		_, err = signer.TransactionReceipt(t.Context(), txHash)
+		require.Error(t, err)
+	})
+}
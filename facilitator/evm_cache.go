@@ -0,0 +1,161 @@
+package facilitator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+// defaultCacheTTL is how long chain reads are reused if the configuration doesn't say otherwise
+const defaultCacheTTL = time.Hour
+
+// maxCacheEntries bounds the entries of a cache, expired entries are dropped when it is reached
+const maxCacheEntries = 10_000
+
+// CacheConfig sets how long chain reads that can't change, or only change once,
+// are reused. Reads whose result may still change, e.g. balances or unused
+// authorizations, always go to the chain.
+type CacheConfig struct {
+	// Turns caching off
+	Disabled bool `mapstructure:"disabled"`
+	// Token metadata: decimals, name, version and EIP-712 domain. 0 means one hour
+	Metadata time.Duration `mapstructure:"metadata"`
+	// Authorizations found to be used, 0 means one hour
+	Authorizations time.Duration `mapstructure:"authorizations"`
+	// Code of deployed contracts, e.g. smart wallets, 0 means one hour
+	Code time.Duration `mapstructure:"code"`
+}
+
+// Kinds of cached reads, the kind label of the cache metrics
+const (
+	cacheMetadata      = "metadata"
+	cacheAuthorization = "authorization"
+	cacheCode          = "code"
+	// results of whole verifications, see verifyCache
+	cacheVerify = "verify"
+)
+
+// cachedReads maps the contract functions whose results are cached to their kind
+var cachedReads = map[string]string{
+	"decimals":           cacheMetadata,
+	"name":               cacheMetadata,
+	"symbol":             cacheMetadata,
+	"version":            cacheMetadata,
+	"eip712Domain":       cacheMetadata,
+	"DOMAIN_SEPARATOR":   cacheMetadata,
+	"authorizationState": cacheAuthorization,
+}
+
+// readCache reuses chain reads for a TTL per entry. Concurrent misses of the
+// same key share a single read.
+type readCache struct {
+	network string
+	ttls    map[string]time.Duration // by kind
+
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	inflight map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+type cacheCall struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// newReadCache creates the cache of a network, nil if caching is disabled.
+func newReadCache(network string, config CacheConfig) *readCache {
+	if config.Disabled {
+		return nil
+	}
+	ttl := func(d time.Duration) time.Duration {
+		if d == 0 {
+			return defaultCacheTTL
+		}
+		return d
+	}
+	return &readCache{
+		network: network,
+		ttls: map[string]time.Duration{
+			cacheMetadata:      ttl(config.Metadata),
+			cacheAuthorization: ttl(config.Authorizations),
+			cacheCode:          ttl(config.Code),
+		},
+		entries:  make(map[string]cacheEntry),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// get returns the cached value of the key or loads it. Loaded values are
+// only cached if keep accepts them. A caller waiting for the read of another
+// one loads the value itself if that read fails, e.g. because its request was
+// canceled.
+func (c *readCache) get(ctx context.Context, kind, key string, keep func(value any) bool, load func() (any, error)) (any, error) {
+	key = kind + "/" + key
+	now := time.Now()
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+		c.mu.Unlock()
+		metrics.ChainCacheLookups.WithLabelValues(c.network, kind, "hit").Inc()
+		return entry.value, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+		if call.err == nil {
+			metrics.ChainCacheLookups.WithLabelValues(c.network, kind, "shared").Inc()
+			return call.value, nil
+		}
+		metrics.ChainCacheLookups.WithLabelValues(c.network, kind, "miss").Inc()
+		return load()
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+	metrics.ChainCacheLookups.WithLabelValues(c.network, kind, "miss").Inc()
+
+	call.value, call.err = load()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil && keep(call.value) {
+		if len(c.entries) >= maxCacheEntries {
+			c.evict(now)
+		}
+		c.entries[key] = cacheEntry{value: call.value, expires: now.Add(c.ttls[kind])}
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// evict drops the expired entries, or all of them if none expired. The caller must hold the lock.
+func (c *readCache) evict(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= maxCacheEntries {
+		clear(c.entries)
+	}
+}
+
+// readKey identifies a contract read by contract, function and arguments.
+func readKey(address, functionName string, args []any) string {
+	return fmt.Sprintf("%s/%s/%v", strings.ToLower(address), functionName, args)
+}
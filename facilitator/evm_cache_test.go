@@ -0,0 +1,88 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCache(t *testing.T) {
+	keepAll := func(any) bool { return true }
+
+	t.Run("values are reused until they expire", func(t *testing.T) {
+		cache := newReadCache("eip155:84532", CacheConfig{Metadata: 50 * time.Millisecond})
+		var loads atomic.Int32
+		load := func() (any, error) { return uint8(loads.Add(1)), nil }
+
+		for range 3 {
+			value, err := cache.get(t.Context(), cacheMetadata, "decimals", keepAll, load)
+			require.NoError(t, err)
+			require.Equal(t, uint8(1), value)
+		}
+		time.Sleep(60 * time.Millisecond)
+		value, err := cache.get(t.Context(), cacheMetadata, "decimals", keepAll, load)
+		require.NoError(t, err)
+		require.Equal(t, uint8(2), value)
+	})
+
+	t.Run("rejected values and errors are not cached", func(t *testing.T) {
+		cache := newReadCache("eip155:84532", CacheConfig{})
+		used := false
+		load := func() (any, error) { return used, nil }
+		keepUsed := func(v any) bool { return v == true }
+
+		value, err := cache.get(t.Context(), cacheAuthorization, "nonce", keepUsed, load)
+		require.NoError(t, err)
+		require.Equal(t, false, value)
+		used = true
+		value, err = cache.get(t.Context(), cacheAuthorization, "nonce", keepUsed, load)
+		require.NoError(t, err)
+		require.Equal(t, true, value)
+		used = false
+		value, err = cache.get(t.Context(), cacheAuthorization, "nonce", keepUsed, load)
+		require.NoError(t, err)
+		require.Equal(t, true, value, "used authorizations stay used")
+
+		rpcErr := errors.New("rpc down")
+		_, err = cache.get(t.Context(), cacheCode, "wallet", keepAll, func() (any, error) { return nil, rpcErr })
+		require.ErrorIs(t, err, rpcErr)
+		value, err = cache.get(t.Context(), cacheCode, "wallet", keepAll, func() (any, error) { return []byte{1}, nil })
+		require.NoError(t, err)
+		require.Equal(t, []byte{1}, value)
+	})
+
+	t.Run("concurrent misses share a read", func(t *testing.T) {
+		cache := newReadCache("eip155:84532", CacheConfig{})
+		var loads atomic.Int32
+		release := make(chan struct{})
+		load := func() (any, error) {
+			loads.Add(1)
+			<-release
+			return "USD Coin", nil
+		}
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := cache.get(context.Background(), cacheMetadata, "name", keepAll, load)
+				require.NoError(t, err)
+				require.Equal(t, "USD Coin", value)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		require.Equal(t, int32(1), loads.Load())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		require.Nil(t, newReadCache("eip155:84532", CacheConfig{Disabled: true}))
+	})
+}
@@ -0,0 +1,131 @@
+package facilitator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// eip1271Magic is returned by isValidSignature of wallets accepting a signature
+var eip1271Magic = []byte{0x16, 0x26, 0xba, 0x7e}
+
+var eip1271ABI = []byte(`[
+	{"type":"function","name":"isValidSignature","stateMutability":"view","inputs":[
+		{"name":"hash","type":"bytes32"},{"name":"signature","type":"bytes"}
+	],"outputs":[{"name":"","type":"bytes4"}]}
+]`)
+
+// DeploymentSimulator is implemented by signers that can simulate the
+// deployment of the counterfactual smart wallet of an ERC-6492 signature.
+// Settlements only deploy wallets that pass the simulation, the factory call
+// is chosen by the payer but sent by the facilitator.
+type DeploymentSimulator interface {
+	// SimulateDeployment executes the factory call from the signer address
+	// and then asks the wallet whether it accepts the signature of the hash,
+	// against the latest block without broadcasting anything. It reports false
+	// if the factory call reverts, leaves no code at the wallet or the wallet
+	// rejects the signature
+	SimulateDeployment(ctx context.Context, factory string, calldata []byte, wallet string, hash [32]byte, signature []byte) (bool, error)
+}
+
+var _ DeploymentSimulator = (*EVMRPCSigner)(nil)
+
+// SimulateDeployment simulates both calls in one block with eth_simulateV1.
+// Calls to an address without code succeed with no return data, so a wallet
+// the factory didn't deploy can't return the EIP-1271 magic value.
+func (s *EVMRPCSigner) SimulateDeployment(ctx context.Context, factory string, calldata []byte, wallet string, hash [32]byte, signature []byte) (bool, error) {
+	_, check, err := packCall(eip1271ABI, "isValidSignature", hash, signature)
+	if err != nil {
+		return false, err
+	}
+	from := s.key.Address()
+	calls := []any{
+		map[string]any{"from": from, "to": common.HexToAddress(factory), "data": hexutil.Bytes(calldata)},
+		map[string]any{"from": from, "to": common.HexToAddress(wallet), "data": hexutil.Bytes(check)},
+	}
+	var blocks []simulatedBlock
+	if err := s.client.CallContext(ctx, &blocks, "eth_simulateV1", map[string]any{
+		"blockStateCalls": []any{map[string]any{"calls": calls}},
+	}, "latest"); err != nil {
+		return false, err
+	}
+	if len(blocks) != 1 || len(blocks[0].Calls) != len(calls) {
+		return false, errors.New("eth_simulateV1 returned no call results")
+	}
+	deploy, validate := blocks[0].Calls[0], blocks[0].Calls[1]
+	if deploy.Status != 1 || validate.Status != 1 || len(validate.ReturnData) < len(eip1271Magic) {
+		return false, nil
+	}
+	return bytes.Equal(validate.ReturnData[:len(eip1271Magic)], eip1271Magic), nil
+}
+
+// deployWallet deploys the counterfactual smart wallet of an ERC-6492 signature
+// through its factory, unless the wallet exists already. The factory and its
+// calldata come from the payer but the deployment is sent by the facilitator
+// signer, so it is only sent once a simulation from the signer shows that it
+// deploys a wallet at the payer address accepting the signature, and the
+// deployed wallet is checked again before the authorization is submitted.
+// Signatures failing either check, or that the signer can't simulate, return
+// types.ErrInvalidSignature.
+func (t *EVMFacilitator) deployWallet(ctx context.Context, asset *evmAsset, auth *evm.Authorization, sigData *sdk.ERC6492SignatureData) error {
+	if sigData.Factory == ([20]byte{}) || len(sigData.FactoryCalldata) == 0 {
+		return nil
+	}
+	wallet := auth.From
+	code, err := t.signer.GetCode(ctx, wallet.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get code of %s: %w", wallet, err)
+	}
+	if len(code) > 0 {
+		return nil
+	}
+
+	digest, err := evm.HashTypedData(evm.NewTypedData(typedDataDomain(asset.Domain), evm.TransferWithAuthorizationTypes, "TransferWithAuthorization", authorizationMessage(auth)))
+	if err != nil {
+		return err
+	}
+	simulator, ok := t.signer.(DeploymentSimulator)
+	if !ok {
+		logging.Ctx(ctx, logging.RPC).Info().Str("wallet", wallet.Hex()).Msg("Signer can't simulate smart wallet deployments")
+		return types.ErrInvalidSignature
+	}
+	factory := common.Address(sigData.Factory).Hex()
+	valid, err := simulator.SimulateDeployment(ctx, factory, sigData.FactoryCalldata, wallet.Hex(), [32]byte(digest), sigData.InnerSignature)
+	if err != nil {
+		return fmt.Errorf("failed to simulate deployment of smart wallet %s: %w", wallet, err)
+	}
+	if !valid {
+		logging.Ctx(ctx, logging.RPC).Info().Str("wallet", wallet.Hex()).Str("factory", factory).Msg("Simulated smart wallet deployment doesn't accept the signature")
+		return types.ErrInvalidSignature
+	}
+
+	txHash, err := t.signer.SendTransaction(ctx, factory, sigData.FactoryCalldata)
+	if err != nil {
+		return fmt.Errorf("failed to deploy smart wallet %s: %w", wallet, err)
+	}
+	receipt, err := t.signer.WaitForTransactionReceipt(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to wait for deployment of smart wallet %s: %w", wallet, err)
+	}
+	if receipt.Status != sdk.TxStatusSuccess {
+		return fmt.Errorf("deployment of smart wallet %s reverted", wallet)
+	}
+	logging.Ctx(ctx, logging.RPC).Info().Str("wallet", wallet.Hex()).Str("tx_hash", txHash).Msg("Deployed smart wallet of payer")
+
+	// the chain may have changed since the simulation
+	valid, err = sdk.VerifyEIP1271Signature(ctx, t.signer, wallet.Hex(), [32]byte(digest), sigData.InnerSignature)
+	if err != nil || !valid {
+		logging.Ctx(ctx, logging.RPC).Warn().Err(err).Str("wallet", wallet.Hex()).Msg("Deployed smart wallet doesn't accept the signature")
+		return types.ErrInvalidSignature
+	}
+	return nil
+}
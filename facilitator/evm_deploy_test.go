@@ -0,0 +1,128 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// erc6492Magic is the suffix of ERC-6492 wrapped signatures
+var erc6492Magic = common.Hex2Bytes("6492649264926492649264926492649264926492649264926492649264926492")
+
+// simulatingSigner answers deployment simulations with a fixed result.
+type simulatingSigner struct {
+	*mock.EVMSigner
+	valid     bool
+	simulated int
+}
+
+func (s *simulatingSigner) SimulateDeployment(context.Context, string, []byte, string, [32]byte, []byte) (bool, error) {
+	s.simulated++
+	return s.valid, nil
+}
+
+func TestEVMSettleUndeployedWallet(t *testing.T) {
+	const (
+		usdc     = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+		payTo    = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+		attacker = "0x00000000000000000000000000000000000000aa"
+	)
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+
+	auth := evm.NewAuthorization(payer.Hex(), payTo, big.NewInt(10_000))
+	inner, err := evm.SignEip3009(auth, evm.NewDomainConfig("USDC", "2", big.NewInt(84532), usdc), evm.NewRawPrivateSigner(key.Serialize()))
+	require.NoError(t, err)
+	innerSig, err := evm.DecodeSignature(inner)
+	require.NoError(t, err)
+
+	// the "factory" call makes the facilitator transfer its own tokens
+	_, drain, err := packCall(splitABI, "transfer", common.HexToAddress(attacker), big.NewInt(1_000_000))
+	require.NoError(t, err)
+	signature := wrapERC6492(t, common.HexToAddress(usdc), drain, innerSig)
+	evmPayload, err := json.Marshal(&evm.EVMPayload{Signature: "0x" + common.Bytes2Hex(signature), Authorization: auth})
+	require.NoError(t, err)
+	payload := &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      string(types.EVM),
+		Network:     "eip155:84532",
+		Payload:     evmPayload,
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           "eip155:84532",
+		MaxAmountRequired: "10000",
+		PayTo:             payTo,
+		Asset:             usdc,
+	}
+
+	newFacilitator := func(t *testing.T, signer EVMSigner) *EVMFacilitator {
+		config := NetworkConfig{Network: "eip155:84532"}
+		require.NoError(t, config.Normalize())
+		f, err := NewEVMFacilitatorWithSigner(config, signer)
+		require.NoError(t, err)
+		return f
+	}
+	newChain := func() *mock.EVMSigner {
+		chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+		chain.SetBalance(usdc, payer.Hex(), big.NewInt(10_000))
+		return chain
+	}
+
+	t.Run("signer without simulation", func(t *testing.T) {
+		chain := newChain()
+		res, err := newFacilitator(t, chain).Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Equal(t, types.ErrInvalidSignature.Error(), res.Error)
+		require.Zero(t, chain.Calls("SendTransaction"))
+		require.Zero(t, chain.Calls("WriteContract"))
+	})
+
+	t.Run("failed simulation", func(t *testing.T) {
+		signer := &simulatingSigner{EVMSigner: newChain()}
+		res, err := newFacilitator(t, signer).Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Equal(t, types.ErrInvalidSignature.Error(), res.Error)
+		require.Equal(t, 1, signer.simulated)
+		require.Zero(t, signer.Calls("SendTransaction"))
+		require.Zero(t, signer.Calls("WriteContract"))
+	})
+
+	t.Run("deployment without wallet code", func(t *testing.T) {
+		// the simulation passes but the deployed wallet doesn't accept the signature
+		signer := &simulatingSigner{EVMSigner: newChain(), valid: true}
+		res, err := newFacilitator(t, signer).Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Equal(t, types.ErrInvalidSignature.Error(), res.Error)
+		require.Equal(t, 1, signer.Calls("SendTransaction"))
+		require.Zero(t, signer.Calls("WriteContract"), "the authorization isn't submitted")
+	})
+}
+
+// wrapERC6492 wraps the signature with the deployment data of a counterfactual wallet.
+func wrapERC6492(t *testing.T, factory common.Address, calldata, signature []byte) []byte {
+	t.Helper()
+	addressType, err := abi.NewType("address", "", nil)
+	require.NoError(t, err)
+	bytesType, err := abi.NewType("bytes", "", nil)
+	require.NoError(t, err)
+
+	wrapped, err := abi.Arguments{{Type: addressType}, {Type: bytesType}, {Type: bytesType}}.Pack(factory, calldata, signature)
+	require.NoError(t, err)
+	return append(wrapped, erc6492Magic...)
+}
@@ -0,0 +1,209 @@
+package facilitator
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+)
+
+// Gas strategies of the gas policy
+const (
+	// GasStrategySuggested uses the gas price suggested by the node
+	GasStrategySuggested = "suggested"
+	// GasStrategyFixed always uses the configured gas price
+	GasStrategyFixed = "fixed"
+	// GasStrategyPercentile adds a percentile of the priority fees paid in recent blocks to the next base fee
+	GasStrategyPercentile = "percentile"
+	// GasStrategyOracle reads the gas price from an external HTTP API
+	GasStrategyOracle = "oracle"
+)
+
+const (
+	defaultGasPercentile    = 50
+	defaultFeeHistoryBlocks = 20
+	gasOracleTimeout        = 10 * time.Second
+	// gasOracleTTL is how long an answer of the gas oracle is reused, about a block on most chains
+	gasOracleTTL = 10 * time.Second
+)
+
+// GasStrategy chooses the gas price of settlement transactions. The price it
+// returns is still adjusted by the multiplier and bound of the gas policy.
+type GasStrategy interface {
+	GasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// gasFeeReader is the part of the RPC client the built-in strategies read fees with.
+type gasFeeReader interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// newGasStrategy creates the strategy the policy configures.
+func newGasStrategy(policy GasPolicy, client gasFeeReader) (GasStrategy, error) {
+	switch policy.Strategy {
+	case "", GasStrategySuggested:
+		return suggestedGas{client: client}, nil
+	case GasStrategyFixed:
+		if policy.FixedPriceGwei <= 0 {
+			return nil, fmt.Errorf("the fixed gas strategy requires fixedPriceGwei")
+		}
+		return fixedGas{price: gweiToWei(policy.FixedPriceGwei)}, nil
+	case GasStrategyPercentile:
+		percentile := cmp.Or(policy.Percentile, defaultGasPercentile)
+		if percentile < 0 || percentile > 100 {
+			return nil, fmt.Errorf("gas percentile %v is not between 0 and 100", percentile)
+		}
+		return &percentileGas{client: client, percentile: percentile, blocks: cmp.Or(policy.FeeHistoryBlocks, defaultFeeHistoryBlocks)}, nil
+	case GasStrategyOracle:
+		if policy.OracleURL == "" || policy.OracleField == "" {
+			return nil, fmt.Errorf("the oracle gas strategy requires oracleUrl and oracleField")
+		}
+		return &oracleGas{
+			url:    policy.OracleURL,
+			path:   strings.Split(policy.OracleField, "."),
+			client: outbound.Client(gasOracleTimeout),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown gas strategy %q", policy.Strategy)
+	}
+}
+
+type suggestedGas struct {
+	client gasFeeReader
+}
+
+func (g suggestedGas) GasPrice(ctx context.Context) (*big.Int, error) {
+	return g.client.SuggestGasPrice(ctx)
+}
+
+type fixedGas struct {
+	price *big.Int
+}
+
+func (g fixedGas) GasPrice(context.Context) (*big.Int, error) {
+	return new(big.Int).Set(g.price), nil
+}
+
+// percentileGas prices transactions at the base fee of the next block plus the
+// median over recent blocks of the priority fee percentile paid in each.
+// Higher percentiles are mined faster at a higher cost.
+type percentileGas struct {
+	client     gasFeeReader
+	percentile float64
+	blocks     uint64
+}
+
+func (g *percentileGas) GasPrice(ctx context.Context) (*big.Int, error) {
+	history, err := g.client.FeeHistory(ctx, g.blocks, nil, []float64{g.percentile})
+	if err != nil {
+		return nil, err
+	}
+	if len(history.BaseFee) == 0 {
+		return nil, fmt.Errorf("fee history has no base fees, the network may not support EIP-1559")
+	}
+	// the last base fee is the one of the next block
+	price := new(big.Int).Set(history.BaseFee[len(history.BaseFee)-1])
+
+	tips := make([]*big.Int, 0, len(history.Reward))
+	for _, rewards := range history.Reward {
+		if len(rewards) > 0 && rewards[0] != nil {
+			tips = append(tips, rewards[0])
+		}
+	}
+	if len(tips) > 0 {
+		slices.SortFunc(tips, func(a, b *big.Int) int { return a.Cmp(b) })
+		price.Add(price, tips[len(tips)/2])
+	}
+	return price, nil
+}
+
+// oracleGas reads the gas price in gwei from a field of the JSON answer of an
+// HTTP API, e.g. a gas tracker. Answers are reused for a few seconds.
+type oracleGas struct {
+	url    string
+	path   []string
+	client *http.Client
+
+	mu      sync.Mutex
+	price   *big.Int
+	fetched time.Time
+}
+
+func (g *oracleGas) GasPrice(ctx context.Context) (*big.Int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.price != nil && time.Since(g.fetched) < gasOracleTTL {
+		return new(big.Int).Set(g.price), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gas oracle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gas oracle returned status %d", resp.StatusCode)
+	}
+
+	var answer any
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("failed to decode gas oracle answer: %w", err)
+	}
+	gwei, err := jsonNumber(answer, g.path)
+	if err != nil {
+		return nil, fmt.Errorf("gas oracle answer: %w", err)
+	}
+	if gwei <= 0 {
+		return nil, fmt.Errorf("gas oracle answered with gas price %v", gwei)
+	}
+	g.price, g.fetched = gweiToWei(gwei), time.Now()
+	return new(big.Int).Set(g.price), nil
+}
+
+// jsonNumber returns the number at the path of a decoded JSON value. Numbers
+// encoded as strings are accepted, gas trackers commonly answer with them.
+func jsonNumber(value any, path []string) (float64, error) {
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return 0, fmt.Errorf("%s is not in an object", key)
+		}
+		if value, ok = object[key]; !ok {
+			return 0, fmt.Errorf("no field %s", key)
+		}
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return number, nil
+	default:
+		return 0, fmt.Errorf("%s is not a number", strings.Join(path, "."))
+	}
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return wei
+}
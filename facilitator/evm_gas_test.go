@@ -0,0 +1,62 @@
+package facilitator
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFees serves fixed gas fees.
+type fakeFees struct {
+	suggested *big.Int
+	history   *ethereum.FeeHistory
+}
+
+func (f *fakeFees) SuggestGasPrice(context.Context) (*big.Int, error) {
+	return f.suggested, nil
+}
+
+func (f *fakeFees) FeeHistory(context.Context, uint64, *big.Int, []float64) (*ethereum.FeeHistory, error) {
+	return f.history, nil
+}
+
+func TestGasStrategies(t *testing.T) {
+	fees := &fakeFees{
+		suggested: big.NewInt(3e9),
+		history: &ethereum.FeeHistory{
+			BaseFee: []*big.Int{big.NewInt(90), big.NewInt(100), big.NewInt(110)},
+			Reward:  [][]*big.Int{{big.NewInt(5)}, {big.NewInt(1)}, {big.NewInt(9)}},
+		},
+	}
+	price := func(policy GasPolicy) *big.Int {
+		strategy, err := newGasStrategy(policy, fees)
+		require.NoError(t, err)
+		price, err := strategy.GasPrice(t.Context())
+		require.NoError(t, err)
+		return price
+	}
+
+	require.Equal(t, big.NewInt(3e9), price(GasPolicy{}))
+	require.Equal(t, big.NewInt(1_500_000_000), price(GasPolicy{Strategy: GasStrategyFixed, FixedPriceGwei: 1.5}))
+	// next base fee plus the median tip
+	require.Equal(t, big.NewInt(115), price(GasPolicy{Strategy: GasStrategyPercentile}))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"1","result":{"SafeGasPrice":"0.8","ProposeGasPrice":"1.25"}}`))
+	}))
+	defer srv.Close()
+	require.Equal(t, big.NewInt(1_250_000_000), price(GasPolicy{Strategy: GasStrategyOracle, OracleURL: srv.URL, OracleField: "result.ProposeGasPrice"}))
+
+	strategy, err := newGasStrategy(GasPolicy{Strategy: GasStrategyOracle, OracleURL: srv.URL, OracleField: "result.FastGasPrice"}, fees)
+	require.NoError(t, err)
+	_, err = strategy.GasPrice(t.Context())
+	require.ErrorContains(t, err, "no field FastGasPrice")
+
+	_, err = newGasStrategy(GasPolicy{Strategy: "fastest"}, fees)
+	require.ErrorContains(t, err, `unknown gas strategy "fastest"`)
+}
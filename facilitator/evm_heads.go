@@ -0,0 +1,184 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+var _ HeadNotifier = (*EVMFacilitator)(nil)
+
+const (
+	// resubscribeBackoff is the longest wait between attempts to subscribe again
+	resubscribeBackoff = 30 * time.Second
+	// receiptPollInterval is how often receipts are polled without a subscription
+	receiptPollInterval = time.Second
+	// stalledHeadInterval is how often receipts are polled while subscribed, in
+	// case the subscription stops delivering heads without failing
+	stalledHeadInterval = 15 * time.Second
+)
+
+// headWatcher follows the new blocks of a chain over a subscription of an RPC
+// endpoint that supports them, e.g. a websocket one. A failed subscription is
+// renewed with backoff, in the meantime the watcher isn't live and callers poll.
+type headWatcher struct {
+	network string
+	sub     event.Subscription
+
+	mu   sync.Mutex
+	live bool
+	head uint64
+	// closed on the next head or when the subscription fails
+	next chan struct{}
+}
+
+// watchHeads subscribes to the new blocks of the client, nil if its endpoint
+// doesn't support subscriptions.
+func watchHeads(client *ethclient.Client, network string) *headWatcher {
+	if !client.Client().SupportsSubscriptions() {
+		return nil
+	}
+	w := &headWatcher{network: network, next: make(chan struct{})}
+	headers := make(chan *ethTypes.Header)
+	w.sub = event.ResubscribeErr(resubscribeBackoff, func(ctx context.Context, lastErr error) (event.Subscription, error) {
+		if lastErr != nil {
+			w.setLive(false)
+			logging.For(logging.RPC).Warn().Err(lastErr).Str("network", network).Msg("Head subscription failed, polling until it is renewed")
+		}
+		sub, err := client.SubscribeNewHead(ctx, headers)
+		if err != nil {
+			return nil, err
+		}
+		w.setLive(true)
+		return sub, nil
+	})
+	go func() {
+		for {
+			select {
+			case header := <-headers:
+				w.newHead(header.Number.Uint64())
+			case <-w.sub.Err():
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// Close ends the subscription.
+func (w *headWatcher) Close() {
+	if w != nil {
+		w.sub.Unsubscribe()
+		w.setLive(false)
+	}
+}
+
+func (w *headWatcher) setLive(live bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.live == live {
+		return
+	}
+	w.live = live
+	if live {
+		metrics.HeadSubscriptions.WithLabelValues(w.network).Set(1)
+	} else {
+		metrics.HeadSubscriptions.WithLabelValues(w.network).Set(0)
+		// waiters fall back to polling
+		w.wake()
+	}
+}
+
+func (w *headWatcher) newHead(number uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if number > w.head {
+		w.head = number
+		w.wake()
+	}
+}
+
+// wake closes the channel of the waiters, the caller holds the lock.
+func (w *headWatcher) wake() {
+	close(w.next)
+	w.next = make(chan struct{})
+}
+
+// Head returns the latest block the subscription delivered, false if it isn't
+// live or hasn't delivered any yet.
+func (w *headWatcher) Head() (uint64, bool) {
+	if w == nil {
+		return 0, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.head, w.live && w.head > 0
+}
+
+// Next returns a channel that is closed once a newer block arrives or the
+// subscription fails, nil while it isn't live.
+func (w *headWatcher) Next() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.live {
+		return nil
+	}
+	return w.next
+}
+
+// waitBlock returns once next is closed, or once poll ticks if next is nil.
+// While next isn't nil it also returns after stalled, so a subscription that
+// silently stops delivering heads doesn't hold the caller forever.
+func waitBlock(ctx context.Context, next <-chan struct{}, poll <-chan time.Time, stalled time.Duration) error {
+	if next != nil {
+		timer := time.NewTimer(stalled)
+		defer timer.Stop()
+		poll = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-next:
+	case <-poll:
+	}
+	return nil
+}
+
+// waitReceipt fetches the receipt of the transaction on every new block until
+// it is mined, polling while the subscription isn't live.
+func (s *EVMRPCSigner) waitReceipt(ctx context.Context, hash string) (*ethTypes.Receipt, error) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+	for {
+		// taken before reading the receipt, so a block mined meanwhile isn't missed
+		next := s.heads.Next()
+		receipt, err := s.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			logging.Ctx(ctx, logging.RPC).Debug().Err(err).Str("tx_hash", hash).Msg("Failed to get receipt")
+		}
+		if err := waitBlock(ctx, next, ticker.C, stalledHeadInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NewHead returns a channel that is closed once a new block arrives, nil if the
+// facilitator isn't subscribed to them.
+func (t *EVMFacilitator) NewHead() <-chan struct{} {
+	return t.heads.Next()
+}
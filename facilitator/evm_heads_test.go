@@ -0,0 +1,165 @@
+package facilitator
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// headChain serves new heads to subscribers and the receipt of a transaction
+// once it is mined.
+type headChain struct {
+	heads    chan *ethTypes.Header
+	mined    atomic.Bool
+	receipts atomic.Int32
+}
+
+func (c *headChain) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	go func() {
+		for {
+			select {
+			case header := <-c.heads:
+				_ = notifier.Notify(sub.ID, header)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (c *headChain) GetTransactionReceipt(hash common.Hash) (*ethTypes.Receipt, error) {
+	c.receipts.Add(1)
+	if !c.mined.Load() {
+		return nil, nil
+	}
+	return &ethTypes.Receipt{
+		Status:      ethTypes.ReceiptStatusSuccessful,
+		TxHash:      hash,
+		BlockNumber: big.NewInt(6),
+		Logs:        []*ethTypes.Log{},
+	}, nil
+}
+
+func TestHeadWatcher(t *testing.T) {
+	chain := &headChain{heads: make(chan *ethTypes.Header)}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	srv := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer srv.Close()
+
+	client, err := ethclient.Dial("ws" + strings.TrimPrefix(srv.URL, "http"))
+	require.NoError(t, err)
+	defer client.Close()
+
+	watcher := watchHeads(client, "eip155:84532")
+	require.NotNil(t, watcher)
+	defer watcher.Close()
+	require.Eventually(t, func() bool { return watcher.Next() != nil }, 5*time.Second, 10*time.Millisecond)
+	_, ok := watcher.Head()
+	require.False(t, ok, "no head was delivered yet")
+
+	next := watcher.Next()
+	chain.heads <- &ethTypes.Header{Number: big.NewInt(5), Difficulty: big.NewInt(0)}
+	select {
+	case <-next:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the new head wasn't notified")
+	}
+	head, ok := watcher.Head()
+	require.True(t, ok)
+	require.Equal(t, uint64(5), head)
+
+	t.Run("receipts are read on new heads", func(t *testing.T) {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		signer, err := NewEVMRPCSigner(client, big.NewInt(84532), crypto.FromECDSA(key), GasPolicy{})
+		require.NoError(t, err)
+		signer.heads = watcher
+
+		type result struct {
+			blockNumber uint64
+			err         error
+		}
+		done := make(chan result, 1)
+		go func() {
+			receipt, err := signer.WaitForTransactionReceipt(t.Context(), "0x01")
+			if err != nil {
+				done <- result{err: err}
+				return
+			}
+			done <- result{blockNumber: receipt.BlockNumber}
+		}()
+		require.Eventually(t, func() bool { return chain.receipts.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(receiptPollInterval + 100*time.Millisecond)
+		require.Equal(t, int32(1), chain.receipts.Load(), "receipts aren't polled while subscribed")
+
+		chain.mined.Store(true)
+		chain.heads <- &ethTypes.Header{Number: big.NewInt(6), Difficulty: big.NewInt(0)}
+		select {
+		case r := <-done:
+			require.NoError(t, r.err)
+			require.Equal(t, uint64(6), r.blockNumber)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the receipt wasn't read on the new head")
+		}
+		require.Equal(t, int32(2), chain.receipts.Load())
+	})
+
+	t.Run("a failed subscription isn't live", func(t *testing.T) {
+		next := watcher.Next()
+		server.Stop()
+		select {
+		case <-next:
+		case <-time.After(5 * time.Second):
+			t.Fatal("waiters weren't woken")
+		}
+		require.Nil(t, watcher.Next(), "callers poll until the subscription is renewed")
+		_, ok := watcher.Head()
+		require.False(t, ok)
+	})
+}
+
+func TestWatchHeadsHTTP(t *testing.T) {
+	srv := httptest.NewServer(rpc.NewServer())
+	defer srv.Close()
+	client, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+	defer client.Close()
+
+	watcher := watchHeads(client, "eip155:84532")
+	require.Nil(t, watcher, "HTTP endpoints can't be subscribed to")
+	require.Nil(t, watcher.Next())
+}
+
+func TestWaitBlockStalledSubscription(t *testing.T) {
+	// a live subscription that never delivers a head
+	next := make(chan struct{})
+	poll := make(chan time.Time)
+
+	done := make(chan error, 1)
+	go func() { done <- waitBlock(t.Context(), next, poll, 50*time.Millisecond) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("a stalled subscription held the waiter")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	require.ErrorIs(t, waitBlock(ctx, next, poll, time.Hour), context.Canceled)
+}
@@ -0,0 +1,108 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var _ TransferIndexer = (*EVMFacilitator)(nil)
+var _ blockReceiptReader = (*EVMRPCSigner)(nil)
+
+// blockReceipt is the part of a transaction receipt the indexer reads.
+type blockReceipt struct {
+	TxHash common.Hash    `json:"transactionHash"`
+	From   common.Address `json:"from"`
+	Logs   []struct {
+		Address common.Address `json:"address"`
+		Topics  []common.Hash  `json:"topics"`
+		Data    hexutil.Bytes  `json:"data"`
+	} `json:"logs"`
+}
+
+// blockReceiptReader is implemented by signers that can read all receipts of a
+// block in one call, which nodes serve as eth_getBlockReceipts.
+type blockReceiptReader interface {
+	blockReceipts(ctx context.Context, number uint64) ([]blockReceipt, error)
+}
+
+func (s *EVMRPCSigner) blockReceipts(ctx context.Context, number uint64) ([]blockReceipt, error) {
+	var receipts []blockReceipt
+	if err := s.client.CallContext(ctx, &receipts, "eth_getBlockReceipts", hexutil.EncodeUint64(number)); err != nil {
+		return nil, fmt.Errorf("failed to get receipts of block %d: %w", number, err)
+	}
+	return receipts, nil
+}
+
+// Head returns the latest block the head subscription delivered, or reads it
+// from the chain while there is no live subscription.
+func (t *EVMFacilitator) Head(ctx context.Context) (uint64, error) {
+	if head, ok := t.heads.Head(); ok {
+		return head, nil
+	}
+	return t.signer.BlockNumber(ctx)
+}
+
+// BlockTransfers reads the receipts of the block. Signers settling through a
+// bundler don't send the settlement transactions, so they can't be indexed.
+func (t *EVMFacilitator) BlockTransfers(ctx context.Context, number uint64) (*BlockTransfers, error) {
+	reader, ok := t.signer.(blockReceiptReader)
+	if !ok {
+		return nil, ErrIndexingUnsupported
+	}
+	header, err := t.signer.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get header of block %d: %w", number, err)
+	}
+	receipts, err := reader.blockReceipts(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := make(map[common.Address]bool)
+	for _, address := range t.signer.GetAddresses() {
+		signers[common.HexToAddress(address)] = true
+	}
+	block := &BlockTransfers{
+		Number: number,
+		Time:   time.Unix(int64(header.Time), 0),
+	}
+	for _, receipt := range receipts {
+		sent := signers[receipt.From]
+		if sent {
+			block.Transactions = append(block.Transactions, receipt.TxHash.Hex())
+		}
+		for _, l := range receipt.Logs {
+			if len(l.Topics) != 3 || l.Topics[0] != transferTopic || len(l.Data) != 32 || t.asset(l.Address.Hex()) == nil {
+				continue
+			}
+			from, to := common.BytesToAddress(l.Topics[1][:]), common.BytesToAddress(l.Topics[2][:])
+			if !sent && !signers[from] && !signers[to] {
+				continue
+			}
+			block.Transfers = append(block.Transfers, Transfer{
+				TxHash: receipt.TxHash.Hex(),
+				Sender: receipt.From.Hex(),
+				Token:  l.Address.Hex(),
+				From:   from.Hex(),
+				To:     to.Hex(),
+				Amount: new(big.Int).SetBytes(l.Data),
+			})
+		}
+	}
+	return block, nil
+}
+
+func (t *EVMFacilitator) HasReceipt(ctx context.Context, txHash string) (bool, error) {
+	_, err := t.signer.TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,84 @@
+package facilitator
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockTransfers(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	usdc := common.HexToAddress("0x036CbD53842c5426634e7929541eC2318f3dCF7e")
+	payer, payee := common.HexToAddress("0xa1"), common.HexToAddress("0xb2")
+
+	transferLog := func(token, from, to common.Address) map[string]any {
+		return map[string]any{
+			"address": token,
+			"topics":  []common.Hash{transferTopic, common.BytesToHash(from[:]), common.BytesToHash(to[:])},
+			"data":    hexutil.Encode(common.LeftPadBytes(big.NewInt(10_000).Bytes(), 32)),
+		}
+	}
+	receipts := []map[string]any{
+		// a settlement
+		{"transactionHash": common.HexToHash("0x01"), "from": signer, "logs": []any{transferLog(usdc, payer, payee)}},
+		// a transfer of others
+		{"transactionHash": common.HexToHash("0x02"), "from": payer, "logs": []any{transferLog(usdc, payer, payee)}},
+		// a transfer out of the signer account
+		{"transactionHash": common.HexToHash("0x03"), "from": payer, "logs": []any{transferLog(usdc, signer, payee)}},
+		// a transfer of a token that isn't accepted
+		{"transactionHash": common.HexToHash("0x04"), "from": signer, "logs": []any{transferLog(common.HexToAddress("0xdead"), payer, payee)}},
+	}
+	head, err := json.Marshal(&ethTypes.Header{Number: big.NewInt(7), Time: 1_700_000_000, Difficulty: big.NewInt(0)})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		var result any
+		switch call.Method {
+		case "eth_getBlockReceipts":
+			result = receipts
+		case "eth_getBlockByNumber":
+			result = json.RawMessage(head)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": call.ID, "result": result}))
+	}))
+	defer srv.Close()
+
+	client, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+	defer client.Close()
+	rpcSigner, err := NewEVMRPCSigner(client, big.NewInt(84532), crypto.FromECDSA(key), GasPolicy{})
+	require.NoError(t, err)
+	config := NetworkConfig{Network: "eip155:84532"}
+	require.NoError(t, config.Normalize())
+	f, err := NewEVMFacilitatorWithSigner(config, rpcSigner)
+	require.NoError(t, err)
+
+	block, err := f.BlockTransfers(t.Context(), 7)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), block.Number)
+	require.Equal(t, int64(1_700_000_000), block.Time.Unix())
+	require.Equal(t, []string{common.HexToHash("0x01").Hex(), common.HexToHash("0x04").Hex()}, block.Transactions)
+	require.Len(t, block.Transfers, 2)
+	require.Equal(t, common.HexToHash("0x01").Hex(), block.Transfers[0].TxHash)
+	require.Equal(t, signer.Hex(), block.Transfers[0].Sender)
+	require.Equal(t, payee.Hex(), block.Transfers[0].To)
+	require.Equal(t, int64(10_000), block.Transfers[0].Amount.Int64())
+	require.Equal(t, signer.Hex(), block.Transfers[1].From)
+}
@@ -0,0 +1,195 @@
+package facilitator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+var _ rawTransactionSender = (*EVMRPCSigner)(nil)
+
+// rawTransactionSender is implemented by signers that can broadcast transactions
+// signed by other accounts, which native currency payments are.
+type rawTransactionSender interface {
+	// PendingNonceAt returns the next nonce of the account, counting its pending transactions
+	PendingNonceAt(ctx context.Context, address string) (uint64, error)
+	// SendRawTransaction broadcasts the signed transaction and returns its hash
+	SendRawTransaction(ctx context.Context, tx *ethTypes.Transaction) (string, error)
+}
+
+// isNative reports whether the asset is the native currency and native payments are accepted.
+// Configured tokens take precedence over the native currency symbol.
+func (t *EVMFacilitator) isNative(asset string) bool {
+	if t.native == nil || t.asset(asset) != nil {
+		return false
+	}
+	return evm.IsNativeAsset(asset) || strings.EqualFold(asset, t.nativeCurrency)
+}
+
+// nativeTransfer decodes the transaction of a native payment and checks that
+// it pays the requirements: a plain transfer of the exact amount to the
+// recipient on this chain. The payer is empty if the signature is invalid.
+func (t *EVMFacilitator) nativeTransfer(payload *types.PaymentPayload, req *types.PaymentRequirements) (*ethTypes.Transaction, string, error) {
+	tx, err := evm.ParseNativePayload(payload.Payload)
+	if err != nil {
+		return nil, "", types.ErrInvalidPayloadFormat
+	}
+	// transactions without chain ID could be replayed on other chains
+	if tx.ChainId().Cmp(t.networkID) != 0 {
+		return nil, "", types.ErrNetworkIDMismatch
+	}
+	sender, err := evm.NativeSender(tx)
+	if err != nil {
+		return nil, "", types.ErrInvalidSignature
+	}
+	payer := sender.String()
+
+	if payload.Scheme != string(t.scheme) || req.Scheme != string(t.scheme) {
+		return nil, payer, types.ErrIncompatibleScheme
+	}
+	if !t.isNetwork(payload.Network) || !t.isNetwork(req.Network) {
+		return nil, payer, types.ErrNetworkMismatch
+	}
+	if !common.IsHexAddress(req.PayTo) || tx.To() == nil || *tx.To() != common.HexToAddress(req.PayTo) {
+		return nil, payer, types.ErrRecipientMismatch
+	}
+	if len(tx.Data()) > 0 {
+		return nil, payer, types.ErrInvalidPayloadFormat
+	}
+	amount, ok := new(big.Int).SetString(req.MaxAmountRequired, 10)
+	if !ok || tx.Value().Cmp(amount) != 0 {
+		return nil, payer, types.ErrValueMismatch
+	}
+	return tx, payer, nil
+}
+
+// verifyNative checks the transaction of a native payment and that it can be
+// included next: its nonce is the next one of the payer, whose balance covers
+// the value and the gas.
+func (t *EVMFacilitator) verifyNative(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
+	invalid := func(reason error, payer string) (*types.PaymentVerifyResponse, error) {
+		return &types.PaymentVerifyResponse{IsValid: false, InvalidReason: reason.Error(), Payer: payer}, nil
+	}
+	tx, payer, err := t.nativeTransfer(payload, req)
+	if err != nil {
+		return invalid(err, payer)
+	}
+
+	if err := t.sanity.Check(ctx); err != nil {
+		return t.rpcAnomaly(ctx, err, common.HexToAddress(payer))
+	}
+	nonce, err := t.native.PendingNonceAt(ctx, payer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nonce: %w", err)
+	}
+	if tx.Nonce() < nonce {
+		return invalid(types.ErrAuthorizationUsed, payer)
+	}
+	if tx.Nonce() > nonce {
+		// the transaction would wait in the pool for the ones before it
+		return invalid(types.ErrNonceTooHigh, payer)
+	}
+	balance, err := t.signer.GetBalance(ctx, payer, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	if err := checkBalance(balance); err != nil {
+		return t.rpcAnomaly(ctx, err, common.HexToAddress(payer))
+	}
+	if balance.Cmp(tx.Cost()) < 0 {
+		return invalid(types.ErrInsufficientBalance, payer)
+	}
+
+	return &types.PaymentVerifyResponse{IsValid: true, Payer: payer}, nil
+}
+
+// settleNative broadcasts the transaction of a native payment. The payer pays
+// for its gas, the signers of the facilitator spend nothing.
+func (t *EVMFacilitator) settleNative(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
+	tx, payer, err := t.nativeTransfer(payload, req)
+	if err != nil {
+		return &types.PaymentSettleResponse{Success: false, Error: err.Error(), Payer: payer}, nil
+	}
+	txHash, err := t.native.SendRawTransaction(ctx, tx)
+	if err != nil {
+		if code := rejectedTransaction(err); code != nil {
+			return &types.PaymentSettleResponse{Success: false, Error: code.Error(), Payer: payer}, nil
+		}
+		return nil, fmt.Errorf("failed to broadcast payment transaction: %w", err)
+	}
+	return &types.PaymentSettleResponse{
+		Success:   true,
+		TxHash:    txHash,
+		NetworkId: t.networkID.String(),
+		Payer:     payer,
+	}, nil
+}
+
+// estimateNative reports the most the transaction of a native payment can
+// cost its payer in gas, nothing is simulated.
+func (t *EVMFacilitator) estimateNative(payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentEstimateResponse, error) {
+	tx, payer, err := t.nativeTransfer(payload, req)
+	if err != nil {
+		return &types.PaymentEstimateResponse{Success: false, Error: err.Error(), Payer: payer}, nil
+	}
+	gasCost := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	return &types.PaymentEstimateResponse{
+		Success:        true,
+		GasLimit:       tx.Gas(),
+		GasPrice:       tx.GasFeeCap().String(),
+		GasCost:        gasCost.String(),
+		GasCostNative:  types.FormatUnits(gasCost, evm.NativeDecimals),
+		NativeCurrency: t.nativeCurrency,
+		Payer:          payer,
+	}, nil
+}
+
+// nativeAuthorization identifies a native payment by its payer and account nonce,
+// only one transaction of the payer can use a nonce.
+func nativeAuthorization(payment *types.PaymentPayload) (string, string, bool) {
+	tx, err := evm.ParseNativePayload(payment.Payload)
+	if err != nil {
+		return "", "", false
+	}
+	sender, err := evm.NativeSender(tx)
+	if err != nil {
+		return "", "", false
+	}
+	return sender.Hex(), strconv.FormatUint(tx.Nonce(), 10), true
+}
+
+// rejectedTransaction maps the errors of nodes refusing a payment transaction
+// to error codes, nil for other errors.
+func rejectedTransaction(err error) error {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "nonce too low"), strings.Contains(msg, "already known"):
+		return types.ErrAuthorizationUsed
+	case strings.Contains(msg, "nonce too high"):
+		return types.ErrNonceTooHigh
+	case strings.Contains(msg, "insufficient funds"):
+		return types.ErrInsufficientBalance
+	}
+	return nil
+}
+
+// PendingNonceAt returns the next nonce of the account, counting its pending transactions.
+func (s *EVMRPCSigner) PendingNonceAt(ctx context.Context, address string) (uint64, error) {
+	return s.client.PendingNonceAt(ctx, common.HexToAddress(address))
+}
+
+// SendRawTransaction broadcasts a transaction signed by another account, e.g. a payer.
+func (s *EVMRPCSigner) SendRawTransaction(ctx context.Context, tx *ethTypes.Transaction) (string, error) {
+	if err := s.client.SendTransaction(ctx, tx); err != nil {
+		return "", err
+	}
+	return tx.Hash().Hex(), nil
+}
@@ -0,0 +1,32 @@
+package facilitator
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/rpcretry"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// chainDown reports whether err shows that the RPC endpoints can't be reached,
+// after the retries of the call were exhausted, on a network that keeps
+// verifying through outages. Other failures are reported as they are.
+func (t *EVMFacilitator) chainDown(ctx context.Context, err error) bool {
+	if !t.verifyOnOutage || ctx.Err() != nil || !rpcretry.Retriable(err) {
+		return false
+	}
+	logging.Ctx(ctx, logging.RPC).Warn().Err(err).Str("network", t.network).Msg("RPC endpoints are unreachable")
+	return true
+}
+
+// signatureOnly is the result of a verification that checked the signature
+// and terms of the payment but couldn't read the chain.
+func signatureOnly(payer common.Address) *types.PaymentVerifyResponse {
+	return &types.PaymentVerifyResponse{
+		IsValid:            true,
+		Payer:              payer.String(),
+		ChainChecksSkipped: true,
+	}
+}
@@ -0,0 +1,91 @@
+package facilitator
+
+import (
+	"encoding/json"
+	"math/big"
+	"syscall"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestEVMVerifyOnOutage(t *testing.T) {
+	const (
+		usdc  = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+		payTo = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+	)
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+
+	auth := evm.NewAuthorization(payer.Hex(), payTo, big.NewInt(10_000))
+	signature, err := evm.SignEip3009(auth, evm.NewDomainConfig("USDC", "2", big.NewInt(84532), usdc), evm.NewRawPrivateSigner(key.Serialize()))
+	require.NoError(t, err)
+	evmPayload, err := json.Marshal(&evm.EVMPayload{Signature: signature, Authorization: auth})
+	require.NoError(t, err)
+	payload := &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      string(types.EVM),
+		Network:     "eip155:84532",
+		Payload:     evmPayload,
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           "eip155:84532",
+		MaxAmountRequired: "10000",
+		PayTo:             payTo,
+		Asset:             usdc,
+	}
+	down := mock.Fault{Err: syscall.ECONNREFUSED}
+
+	newFacilitator := func(t *testing.T, verifyOnOutage bool) (*EVMFacilitator, *mock.EVMSigner) {
+		chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+		chain.SetBalance(usdc, payer.Hex(), big.NewInt(10_000))
+		config := NetworkConfig{Network: "eip155:84532", VerifyOnOutage: verifyOnOutage}
+		require.NoError(t, config.Normalize())
+		f, err := NewEVMFacilitatorWithSigner(config, chain)
+		require.NoError(t, err)
+		return f, chain
+	}
+
+	t.Run("verify checks the signature only", func(t *testing.T) {
+		f, chain := newFacilitator(t, true)
+		chain.Inject("GetBalance", down)
+
+		res, err := f.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, res.IsValid, res.InvalidReason)
+		require.True(t, res.ChainChecksSkipped)
+		require.Equal(t, payer.String(), res.Payer)
+
+		tampered := *req
+		tampered.PayTo = "0x00000000000000000000000000000000000000aa"
+		res, err = f.Verify(t.Context(), payload, &tampered)
+		require.NoError(t, err)
+		require.False(t, res.IsValid, "the terms are still checked")
+	})
+
+	t.Run("settle is rejected as unavailable", func(t *testing.T) {
+		f, chain := newFacilitator(t, true)
+		chain.Inject("SimulateContract", down)
+
+		_, err := f.Settle(t.Context(), payload, req)
+		require.ErrorIs(t, err, ErrChainUnavailable)
+		require.Zero(t, chain.Calls("WriteContract"))
+	})
+
+	t.Run("outages fail verification unless enabled", func(t *testing.T) {
+		f, chain := newFacilitator(t, false)
+		chain.Inject("GetBalance", down)
+
+		_, err := f.Verify(t.Context(), payload, req)
+		require.ErrorIs(t, err, syscall.ECONNREFUSED)
+		require.NotErrorIs(t, err, ErrChainUnavailable)
+	})
+}
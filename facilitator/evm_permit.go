@@ -0,0 +1,330 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// permitABI declares the EIP-2612 and ERC-20 functions permit payments are settled with
+var permitABI = []byte(`[
+	{"type":"function","name":"nonces","stateMutability":"view","inputs":[
+		{"name":"owner","type":"address"}
+	],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[
+		{"name":"owner","type":"address"},
+		{"name":"spender","type":"address"}
+	],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"permit","stateMutability":"nonpayable","inputs":[
+		{"name":"owner","type":"address"},
+		{"name":"spender","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"deadline","type":"uint256"},
+		{"name":"v","type":"uint8"},
+		{"name":"r","type":"bytes32"},
+		{"name":"s","type":"bytes32"}
+	],"outputs":[]},
+	{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[
+		{"name":"from","type":"address"},
+		{"name":"to","type":"address"},
+		{"name":"value","type":"uint256"}
+	],"outputs":[{"name":"","type":"bool"}]}
+]`)
+
+// transferFromGas is the gas a transferFrom is estimated at while it can't be
+// simulated, because the permit it depends on isn't mined yet
+const transferFromGas = 65_000
+
+// permitTimeout bounds how long a settlement waits for its submitted permit to
+// be mined and submits the transfer, regardless of the deadline of the request
+const permitTimeout = 2 * time.Minute
+
+type registeredRequirementsKey struct{}
+
+// WithRegisteredRequirements returns a copy of ctx whose payments are checked
+// against requirements the resource server registered, rather than ones sent
+// along with the payment. Permits don't name the recipient, so anyone holding
+// a permit payload could settle it to a recipient of their own: permit
+// payments are only accepted for registered requirements.
+func WithRegisteredRequirements(ctx context.Context) context.Context {
+	return context.WithValue(ctx, registeredRequirementsKey{}, true)
+}
+
+func hasRegisteredRequirements(ctx context.Context) bool {
+	registered, _ := ctx.Value(registeredRequirementsKey{}).(bool)
+	return registered
+}
+
+// permitSpender returns the address permits must approve: the signer, which
+// submits the transferFrom of the payment.
+func (t *EVMFacilitator) permitSpender() common.Address {
+	addresses := t.signer.GetAddresses()
+	if len(addresses) == 0 {
+		return common.Address{}
+	}
+	return common.HexToAddress(addresses[0])
+}
+
+// permitTransfer decodes the permit of a payment in an EIP-2612 token and
+// checks that it pays the requirements: the facilitator signer may spend at
+// least the required amount until after the expiry margin. Permits don't name
+// the recipient, the value is transferred to the recipient of the requirements,
+// which must be registered ones, see WithRegisteredRequirements.
+func (t *EVMFacilitator) permitTransfer(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements, now time.Time) (*evm.Permit, []byte, string, error) {
+	permit, signature, err := evm.ParsePermitPayload(payload.Payload)
+	if err != nil {
+		return nil, nil, "", types.ErrInvalidPayloadFormat
+	}
+	payer := permit.Owner.String()
+
+	if payload.Scheme != string(t.scheme) || req.Scheme != string(t.scheme) {
+		return nil, nil, payer, types.ErrIncompatibleScheme
+	}
+	if !t.isNetwork(payload.Network) || !t.isNetwork(req.Network) {
+		return nil, nil, payer, types.ErrNetworkMismatch
+	}
+	if !common.IsHexAddress(req.PayTo) {
+		return nil, nil, payer, types.ErrRecipientMismatch
+	}
+	if !hasRegisteredRequirements(ctx) {
+		return nil, nil, payer, types.ErrUnregisteredRequirements
+	}
+	if permit.Spender != t.permitSpender() {
+		return nil, nil, payer, types.ErrSpenderMismatch
+	}
+	required, ok := new(big.Int).SetString(req.MaxAmountRequired, 10)
+	if !ok || permit.Value.Cmp(required) < 0 {
+		return nil, nil, payer, types.ErrValueMismatch
+	}
+	if permit.Deadline.Cmp(big.NewInt(now.Add(t.expiryMargin).Unix())) < 0 {
+		return nil, nil, payer, types.ErrAuthorizationExpired
+	}
+	// tokens only accept permits of externally owned accounts
+	sig, err := evm.ParseSignature(signature)
+	if err != nil {
+		return nil, nil, payer, types.ErrInvalidSignature
+	}
+	return permit, sig, payer, nil
+}
+
+// verifyPermit checks the permit of a payment in an EIP-2612 token: its
+// signature, that its nonce is the next one of the owner and that the owner
+// holds the permitted value.
+func (t *EVMFacilitator) verifyPermit(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements, asset *evmAsset) (*types.PaymentVerifyResponse, error) {
+	invalid := func(reason error, payer string) (*types.PaymentVerifyResponse, error) {
+		return &types.PaymentVerifyResponse{IsValid: false, InvalidReason: reason.Error(), Payer: payer}, nil
+	}
+	permit, sig, payer, err := t.permitTransfer(ctx, payload, req, t.clock.Now())
+	if err != nil {
+		return invalid(err, payer)
+	}
+	valid, err := t.signer.VerifyTypedData(ctx, payer, typedDataDomain(asset.Domain), evm.PermitTypes, "Permit", permit.Message(), sig)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if err != nil || !valid {
+		return invalid(types.ErrInvalidSignature, payer)
+	}
+
+	if err := t.sanity.Check(ctx); err != nil {
+		return t.rpcAnomaly(ctx, err, permit.Owner)
+	}
+	nonce, err := t.readUint(ctx, asset, "nonces", permit.Owner)
+	if err != nil {
+		return nil, err
+	}
+	switch permit.Nonce.Cmp(nonce) {
+	case -1:
+		return invalid(types.ErrAuthorizationUsed, payer)
+	case 1:
+		// the token rejects permits out of order
+		return invalid(types.ErrNonceTooHigh, payer)
+	}
+	balance, err := t.signer.GetBalance(ctx, payer, asset.Domain.VerifyingContract.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	if err := checkBalance(balance); err != nil {
+		return t.rpcAnomaly(ctx, err, permit.Owner)
+	}
+	if balance.Cmp(permit.Value) < 0 {
+		return invalid(types.ErrInsufficientBalance, payer)
+	}
+
+	return &types.PaymentVerifyResponse{IsValid: true, Payer: payer}, nil
+}
+
+// settlePermit submits the permit and, once it is mined, transfers the
+// permitted value from the owner to the recipient. The hash of the transfer is
+// returned. The permit must still be unused: a standing allowance of the
+// signer never pays for a payload on its own, else a payload settled before
+// could be settled again once the record of it is lost.
+//
+// Once the permit is submitted, the settlement goes on without the deadline of
+// the request, up to permitTimeout, so that a permit isn't left mined without
+// its transfer.
+func (t *EVMFacilitator) settlePermit(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements, asset *evmAsset) (*types.PaymentSettleResponse, error) {
+	failed := func(reason error, payer string) (*types.PaymentSettleResponse, error) {
+		return &types.PaymentSettleResponse{Success: false, Error: reason.Error(), Payer: payer}, nil
+	}
+	permit, sig, payer, err := t.permitTransfer(ctx, payload, req, t.clock.Now())
+	if err != nil {
+		return failed(err, payer)
+	}
+	token := asset.Domain.VerifyingContract.Hex()
+
+	nonce, err := t.readUint(ctx, asset, "nonces", permit.Owner)
+	if err != nil {
+		return nil, err
+	}
+	switch permit.Nonce.Cmp(nonce) {
+	case -1:
+		return failed(types.ErrAuthorizationUsed, payer)
+	case 1:
+		return failed(types.ErrNonceTooHigh, payer)
+	}
+	args := permitArgs(permit, sig)
+	code, err := t.simulate(ctx, token, "permit", args...)
+	if err != nil {
+		return nil, err
+	}
+	if code != nil {
+		return failed(code, payer)
+	}
+	txHash, err := t.signer.WriteContract(ctx, token, permitABI, "permit", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit permit: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), permitTimeout)
+	defer cancel()
+	receipt, err := t.signer.WaitForTransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for permit %s: %w", txHash, err)
+	}
+	if receipt.Status != sdk.TxStatusSuccess {
+		return failed(types.ErrTransactionReverted, payer)
+	}
+	logging.Ctx(ctx, logging.RPC).Info().Str("network", t.network).Str("tx_hash", txHash).Str("owner", payer).Msg("Submitted permit of payer")
+
+	args = []any{permit.Owner, common.HexToAddress(req.PayTo), permit.Value}
+	code, err = t.simulate(ctx, token, "transferFrom", args...)
+	if err != nil {
+		return nil, err
+	}
+	if code != nil {
+		return failed(code, payer)
+	}
+	txHash, err = t.signer.WriteContract(ctx, token, permitABI, "transferFrom", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer permitted value: %w", err)
+	}
+	return &types.PaymentSettleResponse{
+		Success:   true,
+		TxHash:    txHash,
+		NetworkId: t.networkID.String(),
+		Payer:     payer,
+	}, nil
+}
+
+// simulate executes a call of the permit ABI without broadcasting it. A
+// reverting call returns the error code of its revert, a failed simulation err.
+func (t *EVMFacilitator) simulate(ctx context.Context, token, function string, args ...any) (code, err error) {
+	err = t.signer.SimulateContract(ctx, token, permitABI, function, args...)
+	var revert *evm.RevertError
+	if errors.As(err, &revert) {
+		var reason string
+		reason, code = decodeRevertError(revert)
+		logging.Ctx(ctx, logging.RPC).Info().Str("network", t.network).Str("function", function).Str("reason", reason).Str("code", code.Error()).Msg("Settlement simulation reverted")
+		diagnostics.Record(ctx, diagnostics.KindSimulation, revert.Error(), map[string]string{
+			"function":   function,
+			"reason":     reason,
+			"code":       code.Error(),
+			"revertData": "0x" + hex.EncodeToString(revert.Data),
+		})
+		return code, nil
+	}
+	if err != nil {
+		err = recordRPCError(ctx, fmt.Errorf("failed to simulate %s: %w", function, err))
+		if t.chainDown(ctx, err) {
+			return nil, fmt.Errorf("%w: %w", ErrChainUnavailable, err)
+		}
+		return nil, err
+	}
+	diagnostics.Record(ctx, diagnostics.KindSimulation, "ok", map[string]string{"function": function})
+	return nil, nil
+}
+
+// estimatePermit estimates the gas of the permit and the transfer. The
+// transfer can't be simulated before the permit is mined, it is counted at
+// transferFromGas.
+func (t *EVMFacilitator) estimatePermit(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements, asset *evmAsset) (*types.PaymentEstimateResponse, error) {
+	failed := func(reason error, payer string) (*types.PaymentEstimateResponse, error) {
+		return &types.PaymentEstimateResponse{Success: false, Error: reason.Error(), Payer: payer}, nil
+	}
+	permit, sig, payer, err := t.permitTransfer(ctx, payload, req, t.clock.Now())
+	if err != nil {
+		return failed(err, payer)
+	}
+	token := asset.Domain.VerifyingContract.Hex()
+
+	gasLimit, err := t.signer.EstimateGas(ctx, token, permitABI, "permit", permitArgs(permit, sig)...)
+	var revert *evm.RevertError
+	if errors.As(err, &revert) {
+		_, code := decodeRevertError(revert)
+		err = code
+	}
+	if err != nil {
+		return failed(err, payer)
+	}
+	gasLimit += transferFromGas
+	if t.gas.GasLimit != 0 {
+		gasLimit = t.gas.GasLimit
+	}
+	gasPrice, err := t.signer.GasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gasCost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit))
+
+	return &types.PaymentEstimateResponse{
+		Success:        true,
+		GasLimit:       gasLimit,
+		GasPrice:       gasPrice.String(),
+		GasCost:        gasCost.String(),
+		GasCostNative:  types.FormatUnits(gasCost, evm.NativeDecimals),
+		NativeCurrency: t.nativeCurrency,
+		Payer:          payer,
+	}, nil
+}
+
+// readUint calls a view function of the permit ABI returning a uint256.
+func (t *EVMFacilitator) readUint(ctx context.Context, asset *evmAsset, function string, args ...any) (*big.Int, error) {
+	result, err := t.signer.ReadContract(ctx, asset.Domain.VerifyingContract.Hex(), permitABI, function, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", function, err)
+	}
+	value, ok := result.(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %s result %T", function, result)
+	}
+	return value, nil
+}
+
+// permitArgs returns the arguments of the permit call, the signature split into v, r and s.
+func permitArgs(permit *evm.Permit, sig []byte) []any {
+	var r, s [32]byte
+	copy(r[:], sig[:32])
+	copy(s[:], sig[32:64])
+	return []any{permit.Owner, permit.Spender, permit.Value, permit.Deadline, sig[64], r, s}
+}
@@ -0,0 +1,187 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestEVMPermit(t *testing.T) {
+	const (
+		token  = "0x00000000000000000000000000000000000000c0"
+		payTo  = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+		signer = "0x00000000000000000000000000000000000000fa"
+	)
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	owner, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	domain := evm.NewDomainConfig("Permit Token", "1", big.NewInt(84532), token)
+
+	payment := func(t *testing.T, spender string, nonce int64) *types.PaymentPayload {
+		permit := &evm.Permit{
+			Owner:    owner,
+			Spender:  common.HexToAddress(spender),
+			Value:    big.NewInt(10_000),
+			Nonce:    big.NewInt(nonce),
+			Deadline: big.NewInt(time.Now().Add(time.Hour).Unix()),
+		}
+		payload, err := evm.NewPermitPayload(permit, domain, evm.NewRawPrivateSigner(key.Serialize()))
+		require.NoError(t, err)
+		raw, err := json.Marshal(payload)
+		require.NoError(t, err)
+		return &types.PaymentPayload{
+			X402Version: int(types.X402VersionV1),
+			Scheme:      string(types.EVM),
+			Network:     "eip155:84532",
+			Payload:     raw,
+		}
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           "eip155:84532",
+		MaxAmountRequired: "10000",
+		PayTo:             payTo,
+		Asset:             "PRM",
+	}
+	// permits are only paid to registered requirements
+	registered := func(t *testing.T) context.Context {
+		return WithRegisteredRequirements(t.Context())
+	}
+	newFacilitator := func(t *testing.T) (*EVMFacilitator, *mock.EVMSigner) {
+		chain := mock.NewEVMSigner(84532, signer)
+		chain.SetBalance(token, owner.Hex(), big.NewInt(10_000))
+		config := NetworkConfig{Network: "eip155:84532", Assets: []AssetConfig{{
+			Symbol:         "PRM",
+			Address:        token,
+			Decimals:       6,
+			Name:           "Permit Token",
+			Version:        "1",
+			TransferMethod: types.TransferMethodEIP2612,
+		}}}
+		require.NoError(t, config.Normalize())
+		f, err := NewEVMFacilitatorWithSigner(config, chain)
+		require.NoError(t, err)
+		return f, chain
+	}
+
+	t.Run("listed with the spender", func(t *testing.T) {
+		f, _ := newFacilitator(t)
+		catalog := f.catalog()
+		require.Len(t, catalog, 1)
+		require.Equal(t, types.TransferMethodEIP2612, catalog[0].TransferMethod)
+		require.Equal(t, common.HexToAddress(signer).Hex(), catalog[0].Spender)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		f, _ := newFacilitator(t)
+		res, err := f.Verify(registered(t), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.True(t, res.IsValid, res.InvalidReason)
+		require.Equal(t, owner.String(), res.Payer)
+
+		res, err = f.Verify(registered(t), payment(t, "0x00000000000000000000000000000000000000aa", 0), req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrSpenderMismatch.Error(), res.InvalidReason)
+
+		res, err = f.Verify(registered(t), payment(t, signer, 1), req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrNonceTooHigh.Error(), res.InvalidReason)
+
+		other := *req
+		other.Asset = token
+		other.MaxAmountRequired = "10001"
+		res, err = f.Verify(registered(t), payment(t, signer, 0), &other)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrValueMismatch.Error(), res.InvalidReason)
+	})
+
+	t.Run("requirements sent along are rejected", func(t *testing.T) {
+		f, chain := newFacilitator(t)
+		res, err := f.Verify(t.Context(), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrUnregisteredRequirements.Error(), res.InvalidReason)
+
+		settled, err := f.Settle(t.Context(), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrUnregisteredRequirements.Error(), settled.Error)
+		require.Zero(t, chain.Calls("WriteContract"))
+	})
+
+	t.Run("settle permits and transfers", func(t *testing.T) {
+		f, chain := newFacilitator(t)
+		payload := payment(t, signer, 0)
+		res, err := f.Settle(registered(t), payload, req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Equal(t, 2, chain.Calls("WriteContract"), "the permit and the transfer")
+		require.Zero(t, chain.Balance(token, owner.Hex()).Sign())
+		require.Equal(t, big.NewInt(10_000), chain.Balance(token, payTo))
+		require.NoError(t, f.VerifyTransfer(t.Context(), res.TxHash, payload, req))
+
+		verified, err := f.Verify(registered(t), payload, req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrAuthorizationUsed.Error(), verified.InvalidReason)
+
+		payer, nonce, ok := f.Authorization(payload)
+		require.True(t, ok)
+		require.Equal(t, owner.Hex(), payer)
+		require.Equal(t, "permit:0", nonce)
+	})
+
+	t.Run("used permits aren't settled with a standing allowance", func(t *testing.T) {
+		f, chain := newFacilitator(t)
+		// the permit of a payload settled before, whose record is lost
+		_, err := chain.WriteContract(t.Context(), token, permitABI, "permit", owner, common.HexToAddress(signer), big.NewInt(20_000))
+		require.NoError(t, err)
+
+		res, err := f.Settle(registered(t), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrAuthorizationUsed.Error(), res.Error)
+		require.Equal(t, 1, chain.Calls("WriteContract"))
+		require.Zero(t, chain.Balance(token, payTo).Sign())
+	})
+
+	t.Run("unused permits are submitted despite a standing allowance", func(t *testing.T) {
+		f, chain := newFacilitator(t)
+		chain.SetAllowance(token, owner, common.HexToAddress(signer), big.NewInt(10_000))
+
+		res, err := f.Settle(registered(t), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Equal(t, 2, chain.Calls("WriteContract"), "the permit and the transfer")
+		verified, err := f.Verify(registered(t), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.Equal(t, types.ErrAuthorizationUsed.Error(), verified.InvalidReason)
+	})
+
+	t.Run("transfers after the request deadline once the permit is submitted", func(t *testing.T) {
+		f, chain := newFacilitator(t)
+		chain.Inject("WaitForTransactionReceipt", mock.Fault{Latency: 50 * time.Millisecond, Times: 1})
+		ctx, cancel := context.WithTimeout(registered(t), 10*time.Millisecond)
+		defer cancel()
+
+		res, err := f.Settle(ctx, payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Equal(t, big.NewInt(10_000), chain.Balance(token, payTo))
+	})
+
+	t.Run("estimate", func(t *testing.T) {
+		f, _ := newFacilitator(t)
+		res, err := f.Estimate(registered(t), payment(t, signer, 0), req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Greater(t, res.GasLimit, uint64(transferFromGas))
+	})
+}
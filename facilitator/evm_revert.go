@@ -0,0 +1,101 @@
+package facilitator
+
+import (
+	"strings"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// customErrorsABI declares the custom errors of ERC-3009 tokens, Permit2,
+// ERC-6093 and OpenZeppelin EIP-2612 tokens that settlements commonly revert with
+var customErrorsABI = evm.MustParseABI(`[
+	{"type":"error","name":"AuthorizationAlreadyUsed","inputs":[{"name":"authorizer","type":"address"},{"name":"nonce","type":"bytes32"}]},
+	{"type":"error","name":"AuthorizationNotYetValid","inputs":[{"name":"validAfter","type":"uint256"}]},
+	{"type":"error","name":"AuthorizationExpired","inputs":[{"name":"validBefore","type":"uint256"}]},
+	{"type":"error","name":"InvalidSignature","inputs":[]},
+	{"type":"error","name":"InvalidSigner","inputs":[]},
+	{"type":"error","name":"InvalidContractSignature","inputs":[]},
+	{"type":"error","name":"InvalidSignatureLength","inputs":[]},
+	{"type":"error","name":"InvalidNonce","inputs":[]},
+	{"type":"error","name":"SignatureExpired","inputs":[{"name":"signatureDeadline","type":"uint256"}]},
+	{"type":"error","name":"AllowanceExpired","inputs":[{"name":"deadline","type":"uint256"}]},
+	{"type":"error","name":"InsufficientAllowance","inputs":[{"name":"amount","type":"uint256"}]},
+	{"type":"error","name":"ERC20InsufficientBalance","inputs":[{"name":"sender","type":"address"},{"name":"balance","type":"uint256"},{"name":"needed","type":"uint256"}]},
+	{"type":"error","name":"ERC20InsufficientAllowance","inputs":[{"name":"spender","type":"address"},{"name":"allowance","type":"uint256"},{"name":"needed","type":"uint256"}]},
+	{"type":"error","name":"ERC2612ExpiredSignature","inputs":[{"name":"deadline","type":"uint256"}]},
+	{"type":"error","name":"ERC2612InvalidSigner","inputs":[{"name":"signer","type":"address"},{"name":"owner","type":"address"}]}
+]`)
+
+// customErrorCodes maps the custom errors to the error codes returned to clients
+var customErrorCodes = map[string]error{
+	"AuthorizationAlreadyUsed":   types.ErrAuthorizationUsed,
+	"AuthorizationNotYetValid":   types.ErrAuthorizationNotYetValid,
+	"AuthorizationExpired":       types.ErrAuthorizationExpired,
+	"InvalidSignature":           types.ErrInvalidSignature,
+	"InvalidSigner":              types.ErrInvalidSignature,
+	"InvalidContractSignature":   types.ErrInvalidSignature,
+	"InvalidSignatureLength":     types.ErrInvalidSignature,
+	"InvalidNonce":               types.ErrAuthorizationUsed,
+	"SignatureExpired":           types.ErrAuthorizationExpired,
+	"AllowanceExpired":           types.ErrAuthorizationExpired,
+	"InsufficientAllowance":      types.ErrInsufficientAllowance,
+	"ERC20InsufficientBalance":   types.ErrInsufficientBalance,
+	"ERC20InsufficientAllowance": types.ErrInsufficientAllowance,
+	"ERC2612ExpiredSignature":    types.ErrAuthorizationExpired,
+	"ERC2612InvalidSigner":       types.ErrInvalidSignature,
+}
+
+// reasonCodes maps parts of Error(string) reasons of common token
+// implementations, e.g. FiatTokenV2 and OpenZeppelin, to error codes
+var reasonCodes = []struct {
+	reason string
+	code   error
+}{
+	{"authorization is used", types.ErrAuthorizationUsed},
+	{"authorization already used", types.ErrAuthorizationUsed},
+	{"authorization is not yet valid", types.ErrAuthorizationNotYetValid},
+	{"authorization is expired", types.ErrAuthorizationExpired},
+	{"invalid signature", types.ErrInvalidSignature},
+	{"transfer amount exceeds balance", types.ErrInsufficientBalance},
+	{"insufficient balance", types.ErrInsufficientBalance},
+	{"transfer amount exceeds allowance", types.ErrInsufficientAllowance},
+	{"insufficient allowance", types.ErrInsufficientAllowance},
+	{"expired deadline", types.ErrAuthorizationExpired},
+}
+
+// DecodeRevert decodes the revert data of an EVM call into a readable reason
+// and the error code returned to clients. It understands Error(string),
+// Panic(uint256) and the custom errors in customErrorsABI; anything else
+// decodes to an empty reason and ErrTransactionReverted.
+func DecodeRevert(data []byte) (string, error) {
+	if reason := evm.DecodeRevert(data); reason != "" {
+		return reason, reasonCode(reason)
+	}
+	if len(data) >= 4 {
+		if customErr, err := customErrorsABI.ErrorByID([4]byte(data[:4])); err == nil {
+			return customErr.Name, customErrorCodes[customErr.Name]
+		}
+	}
+	return "", types.ErrTransactionReverted
+}
+
+// decodeRevertError returns the reason and error code of a reverted call,
+// nodes that don't return revert data only give the reason.
+func decodeRevertError(revert *evm.RevertError) (string, error) {
+	if len(revert.Data) > 0 {
+		return DecodeRevert(revert.Data)
+	}
+	return revert.Reason, reasonCode(revert.Reason)
+}
+
+// reasonCode maps an Error(string) reason to an error code.
+func reasonCode(reason string) error {
+	reason = strings.ToLower(reason)
+	for _, rc := range reasonCodes {
+		if strings.Contains(reason, rc.reason) {
+			return rc.code
+		}
+	}
+	return types.ErrTransactionReverted
+}
@@ -0,0 +1,62 @@
+package facilitator
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestDecodeRevert(t *testing.T) {
+	errorString := func(reason string) []byte {
+		args := abi.Arguments{{Type: mustABIType("string")}}
+		packed, err := args.Pack(reason)
+		require.NoError(t, err)
+		return append([]byte{0x08, 0xc3, 0x79, 0xa0}, packed...)
+	}
+	customError := func(name string, values ...any) []byte {
+		customErr := customErrorsABI.Errors[name]
+		packed, err := customErr.Inputs.Pack(values...)
+		require.NoError(t, err)
+		return append(customErr.ID[:4], packed...)
+	}
+	panicData := append([]byte{0x4e, 0x48, 0x7b, 0x71}, common.LeftPadBytes([]byte{0x11}, 32)...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		reason string
+		code   error
+	}{
+		{"FiatTokenV2 used", errorString("FiatTokenV2: authorization is used or canceled"), "FiatTokenV2: authorization is used or canceled", types.ErrAuthorizationUsed},
+		{"FiatTokenV2 expired", errorString("FiatTokenV2: authorization is expired"), "FiatTokenV2: authorization is expired", types.ErrAuthorizationExpired},
+		{"OpenZeppelin balance", errorString("ERC20: transfer amount exceeds balance"), "ERC20: transfer amount exceeds balance", types.ErrInsufficientBalance},
+		{"unknown reason", errorString("paused"), "paused", types.ErrTransactionReverted},
+		{"panic", panicData, "arithmetic underflow or overflow", types.ErrTransactionReverted},
+		{"ERC-3009 custom error", customError("AuthorizationAlreadyUsed", common.HexToAddress("0x01"), [32]byte{1}), "AuthorizationAlreadyUsed", types.ErrAuthorizationUsed},
+		{"Permit2 nonce", customError("InvalidNonce"), "InvalidNonce", types.ErrAuthorizationUsed},
+		{"Permit2 deadline", customError("SignatureExpired", big.NewInt(1)), "SignatureExpired", types.ErrAuthorizationExpired},
+		{"ERC-6093 balance", customError("ERC20InsufficientBalance", common.HexToAddress("0x01"), big.NewInt(1), big.NewInt(2)), "ERC20InsufficientBalance", types.ErrInsufficientBalance},
+		{"EIP-2612 deadline", errorString("ERC20Permit: expired deadline"), "ERC20Permit: expired deadline", types.ErrAuthorizationExpired},
+		{"EIP-2612 signer", customError("ERC2612InvalidSigner", common.HexToAddress("0x01"), common.HexToAddress("0x02")), "ERC2612InvalidSigner", types.ErrInvalidSignature},
+		{"unknown custom error", []byte{0x12, 0x34, 0x56, 0x78}, "", types.ErrTransactionReverted},
+		{"no data", nil, "", types.ErrTransactionReverted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, code := DecodeRevert(tt.data)
+			require.Equal(t, tt.reason, reason)
+			require.Equal(t, tt.code, code)
+		})
+	}
+
+	// nodes that don't return revert data
+	reason, code := decodeRevertError(&evm.RevertError{Reason: "EIP3009: authorization already used"})
+	require.Equal(t, "EIP3009: authorization already used", reason)
+	require.Equal(t, types.ErrAuthorizationUsed, code)
+}
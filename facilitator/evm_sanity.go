@@ -0,0 +1,128 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	// sanityCheckInterval throttles the chain-level checks, so verify bursts don't multiply RPC calls
+	sanityCheckInterval = 15 * time.Second
+	// maxBlockClockSkew is how far a block timestamp may be ahead of the local clock
+	maxBlockClockSkew = 5 * time.Minute
+)
+
+// maxSaneBalance is an upper bound no real token balance reaches (2^128 atomic units).
+// Larger values indicate a broken or malicious RPC provider.
+var maxSaneBalance = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// sanityBackend is the subset of the RPC client used by the sanity checker
+type sanityBackend interface {
+	GetChainID(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error)
+}
+
+// rpcSanityChecker validates state reported by the RPC provider before it is
+// trusted for verification: the chain ID must stay the one seen at startup and
+// the chain head must move forward in both height and time.
+type rpcSanityChecker struct {
+	backend sanityBackend
+	chainID *big.Int
+	now     func() time.Time
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastErr   error
+	headNum   uint64
+	headTime  uint64
+}
+
+func newRPCSanityChecker(backend sanityBackend, chainID *big.Int) *rpcSanityChecker {
+	return &rpcSanityChecker{
+		backend: backend,
+		chainID: chainID,
+		now:     time.Now,
+	}
+}
+
+// Check runs the chain-level checks, at most once per sanityCheckInterval.
+// Anomalies are reported as errors wrapping types.ErrRPCAnomaly.
+func (c *rpcSanityChecker) Check(ctx context.Context) error {
+	return c.throttled(func(now time.Time) error {
+		chainID, err := c.backend.GetChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		head, err := c.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get latest block header: %w", err)
+		}
+		return c.check(chainID, head, now)
+	})
+}
+
+// CheckHead is Check with the chain ID and the latest header already read,
+// e.g. in a batch with other reads.
+func (c *rpcSanityChecker) CheckHead(chainID *big.Int, head *ethTypes.Header) error {
+	return c.throttled(func(now time.Time) error {
+		return c.check(chainID, head, now)
+	})
+}
+
+// throttled runs the check unless one ran within sanityCheckInterval, whose result it returns.
+func (c *rpcSanityChecker) throttled(check func(now time.Time) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if !c.lastCheck.IsZero() && now.Sub(c.lastCheck) < sanityCheckInterval {
+		return c.lastErr
+	}
+
+	err := check(now)
+	if err != nil && !isAnomaly(err) {
+		// plain RPC failures are not cached, the next call retries
+		return err
+	}
+	c.lastCheck, c.lastErr = now, err
+	return err
+}
+
+func (c *rpcSanityChecker) check(chainID *big.Int, head *ethTypes.Header, now time.Time) error {
+	if chainID.Cmp(c.chainID) != 0 {
+		return fmt.Errorf("%w: chain ID changed from %s to %s", types.ErrRPCAnomaly, c.chainID, chainID)
+	}
+
+	num, ts := head.Number.Uint64(), head.Time
+	if num < c.headNum {
+		return fmt.Errorf("%w: block number went backwards from %d to %d", types.ErrRPCAnomaly, c.headNum, num)
+	}
+	if ts < c.headTime {
+		return fmt.Errorf("%w: block timestamp went backwards from %d to %d", types.ErrRPCAnomaly, c.headTime, ts)
+	}
+	if time.Unix(int64(ts), 0).After(now.Add(maxBlockClockSkew)) {
+		return fmt.Errorf("%w: block timestamp %d is in the future", types.ErrRPCAnomaly, ts)
+	}
+	c.headNum, c.headTime = num, ts
+	return nil
+}
+
+// checkBalance rejects token balances no real account can hold.
+func checkBalance(balance *big.Int) error {
+	if balance.Sign() < 0 || balance.Cmp(maxSaneBalance) > 0 {
+		return fmt.Errorf("%w: implausible balance %s", types.ErrRPCAnomaly, balance)
+	}
+	return nil
+}
+
+func isAnomaly(err error) bool {
+	return errors.Is(err, types.ErrRPCAnomaly)
+}
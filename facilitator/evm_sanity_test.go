@@ -0,0 +1,78 @@
+package facilitator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+type fakeSanityBackend struct {
+	chainID *big.Int
+	head    *ethTypes.Header
+	calls   int
+}
+
+func (b *fakeSanityBackend) GetChainID(ctx context.Context) (*big.Int, error) {
+	b.calls++
+	return b.chainID, nil
+}
+
+func (b *fakeSanityBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	return b.head, nil
+}
+
+func newTestSanityChecker(backend *fakeSanityBackend, now *time.Time) *rpcSanityChecker {
+	checker := newRPCSanityChecker(backend, big.NewInt(84532))
+	checker.now = func() time.Time { return *now }
+	return checker
+}
+
+func TestRPCSanityChecker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	backend := &fakeSanityBackend{
+		chainID: big.NewInt(84532),
+		head:    &ethTypes.Header{Number: big.NewInt(100), Time: uint64(now.Unix())},
+	}
+	checker := newTestSanityChecker(backend, &now)
+
+	t.Run("healthy provider passes", func(t *testing.T) {
+		require.NoError(t, checker.Check(t.Context()))
+	})
+
+	t.Run("checks are throttled", func(t *testing.T) {
+		calls := backend.calls
+		require.NoError(t, checker.Check(t.Context()))
+		require.Equal(t, calls, backend.calls)
+	})
+
+	t.Run("block number going backwards is an anomaly", func(t *testing.T) {
+		now = now.Add(sanityCheckInterval)
+		backend.head = &ethTypes.Header{Number: big.NewInt(99), Time: uint64(now.Unix())}
+		require.ErrorIs(t, checker.Check(t.Context()), types.ErrRPCAnomaly)
+	})
+
+	t.Run("changed chain ID is an anomaly", func(t *testing.T) {
+		now = now.Add(sanityCheckInterval)
+		backend.head = &ethTypes.Header{Number: big.NewInt(101), Time: uint64(now.Unix())}
+		backend.chainID = big.NewInt(1)
+		require.ErrorIs(t, checker.Check(t.Context()), types.ErrRPCAnomaly)
+	})
+
+	t.Run("future block timestamp is an anomaly", func(t *testing.T) {
+		now = now.Add(sanityCheckInterval)
+		backend.chainID = big.NewInt(84532)
+		backend.head = &ethTypes.Header{Number: big.NewInt(102), Time: uint64(now.Add(time.Hour).Unix())}
+		require.ErrorIs(t, checker.Check(t.Context()), types.ErrRPCAnomaly)
+	})
+}
+
+func TestCheckBalance(t *testing.T) {
+	require.NoError(t, checkBalance(big.NewInt(1_000_000)))
+	require.ErrorIs(t, checkBalance(new(big.Int).Lsh(big.NewInt(1), 200)), types.ErrRPCAnomaly)
+}
@@ -0,0 +1,548 @@
+package facilitator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/rpcretry"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// EVMSigner gives the EVM facilitator access to the chain. On top of the x402 SDK
+// signer it exposes the gas and chain head queries used for estimation, RPC
+// sanity checks and confirmation tracking.
+type EVMSigner interface {
+	sdk.FacilitatorEvmSigner
+
+	// EstimateGas simulates a contract call from the signer address and returns the gas it uses
+	EstimateGas(ctx context.Context, address string, abi []byte, functionName string, args ...any) (uint64, error)
+	// SimulateContract executes a contract call from the signer address against the
+	// latest block without broadcasting it. A reverting call returns an *evm.RevertError
+	SimulateContract(ctx context.Context, address string, abi []byte, functionName string, args ...any) error
+	// GasPrice returns the gas price transactions of the signer are submitted with
+	GasPrice(ctx context.Context) (*big.Int, error)
+	// BlockNumber returns the number of the latest block
+	BlockNumber(ctx context.Context) (uint64, error)
+	// HeaderByNumber returns a block header, the latest one if number is nil
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error)
+	// TransactionReceipt returns the full receipt of a mined transaction
+	TransactionReceipt(ctx context.Context, txHash string) (*ethTypes.Receipt, error)
+}
+
+// TransactionSigner holds the account paying for settlements and signs its transactions.
+type TransactionSigner interface {
+	Address() common.Address
+	// SignTx signs the transaction for the chain. Signers may block until the
+	// transaction is confirmed by an operator, honoring context cancellation.
+	SignTx(ctx context.Context, tx *ethTypes.Transaction, chainID *big.Int) (*ethTypes.Transaction, error)
+}
+
+var _ EVMSigner = (*EVMRPCSigner)(nil)
+var _ TransactionSigner = (*PrivateKeySigner)(nil)
+
+// EVMRPCSigner signs settlement transactions with a TransactionSigner and
+// submits them through an RPC endpoint, applying the network gas policy.
+// Calls failing for transient reasons are retried.
+type EVMRPCSigner struct {
+	client  *rpcretry.Client
+	chainID *big.Int
+	gas     GasPolicy
+	key     TransactionSigner
+	// chooses the gas price before the gas policy adjusts it
+	strategy GasStrategy
+
+	// sendLock serializes nonce assignment, signing and submission. It is
+	// held while a hardware wallet waits for confirmation, at most its
+	// confirmTimeout, so waiting senders give up once their ctx is done
+	sendLock chan struct{}
+	// cache of reads that can't change, nil if disabled
+	cache *readCache
+	// new blocks of the chain, nil if the endpoint doesn't support subscriptions
+	heads *headWatcher
+	// whether EIP-1559 dynamic fee transactions are submitted instead of legacy ones
+	dynamicFee bool
+}
+
+func NewEVMRPCSigner(client *ethclient.Client, chainID *big.Int, privateKey []byte, gas GasPolicy) (*EVMRPCSigner, error) {
+	key, err := NewPrivateKeySigner(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewEVMRPCSignerWithKey(client, chainID, key, gas), nil
+}
+
+// NewEVMRPCSignerWithKey creates a signer whose transactions are signed by the key.
+func NewEVMRPCSignerWithKey(client *ethclient.Client, chainID *big.Int, key TransactionSigner, gas GasPolicy) *EVMRPCSigner {
+	return newEVMRPCSigner(rpcretry.NewClient(client, rpcretry.NewPolicy(rpcretry.Config{})), chainID, key, gas)
+}
+
+func newEVMRPCSigner(client *rpcretry.Client, chainID *big.Int, key TransactionSigner, gas GasPolicy) *EVMRPCSigner {
+	return &EVMRPCSigner{
+		client:   client,
+		chainID:  chainID,
+		gas:      gas,
+		key:      key,
+		strategy: suggestedGas{client: client},
+		sendLock: make(chan struct{}, 1),
+	}
+}
+
+// lockSend takes the send lock, unless ctx is done first.
+func (s *EVMRPCSigner) lockSend(ctx context.Context) error {
+	select {
+	case s.sendLock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the transactions of the signer: %w", ctx.Err())
+	}
+}
+
+func (s *EVMRPCSigner) unlockSend() {
+	<-s.sendLock
+}
+
+// SetGasStrategy replaces the strategy choosing the gas price, the node's
+// suggestion by default.
+func (s *EVMRPCSigner) SetGasStrategy(strategy GasStrategy) {
+	s.strategy = strategy
+}
+
+// SetDynamicFee makes the signer submit EIP-1559 dynamic fee transactions,
+// whose fee cap is the gas price, instead of legacy transactions.
+func (s *EVMRPCSigner) SetDynamicFee(enabled bool) {
+	s.dynamicFee = enabled
+}
+
+// newTx creates a legacy transaction paying the gas price or, with dynamic
+// fees, a transaction paying at most the gas price with the priority fee the
+// node suggests.
+func (s *EVMRPCSigner) newTx(ctx context.Context, nonce uint64, gasPrice *big.Int, gasLimit uint64, to common.Address, value *big.Int, data []byte) (*ethTypes.Transaction, error) {
+	if !s.dynamicFee {
+		return ethTypes.NewTx(&ethTypes.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: gasPrice,
+			Gas:      gasLimit,
+			To:       &to,
+			Value:    value,
+			Data:     data,
+		}), nil
+	}
+	tip, err := s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get priority fee: %w", err)
+	}
+	if tip.Cmp(gasPrice) > 0 {
+		tip = gasPrice
+	}
+	return ethTypes.NewTx(&ethTypes.DynamicFeeTx{
+		ChainID:   s.chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: gasPrice,
+		Gas:       gasLimit,
+		To:        &to,
+		Value:     value,
+		Data:      data,
+	}), nil
+}
+
+// PrivateKeySigner signs transactions with a private key held in memory.
+type PrivateKeySigner struct {
+	signer  types.Signer
+	address common.Address
+}
+
+func NewPrivateKeySigner(privateKey []byte) (*PrivateKeySigner, error) {
+	address, err := evm.GetAddrssFromPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get address from private key: %w", err)
+	}
+	return &PrivateKeySigner{
+		signer:  evm.NewRawPrivateSigner(privateKey),
+		address: address,
+	}, nil
+}
+
+func (k *PrivateKeySigner) Address() common.Address {
+	return k.address
+}
+
+// SignHash signs the digest, the recovery ID is 27 or 28 as expected by contracts.
+func (k *PrivateKeySigner) SignHash(digest []byte) ([]byte, error) {
+	sig, err := k.signer(digest)
+	if err != nil {
+		return nil, err
+	}
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return sig, nil
+}
+
+func (k *PrivateKeySigner) SignTx(_ context.Context, tx *ethTypes.Transaction, chainID *big.Int) (*ethTypes.Transaction, error) {
+	return evm.ToGethSigner(k.signer, chainID)(k.address, tx)
+}
+
+func (s *EVMRPCSigner) GetAddresses() []string {
+	return []string{s.key.Address().Hex()}
+}
+
+// ReadContract calls a view function. Token metadata and used authorizations
+// are cached, since they don't change anymore.
+func (s *EVMRPCSigner) ReadContract(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) (any, error) {
+	kind, ok := cachedReads[functionName]
+	if !ok || s.cache == nil {
+		return s.readContract(ctx, address, abiJSON, functionName, args...)
+	}
+	keep := func(any) bool { return true }
+	if kind == cacheAuthorization {
+		// an unused authorization may be used any moment
+		keep = func(used any) bool { return used == true }
+	}
+	return s.cache.get(ctx, kind, readKey(address, functionName, args), keep, func() (any, error) {
+		return s.readContract(ctx, address, abiJSON, functionName, args...)
+	})
+}
+
+func (s *EVMRPCSigner) readContract(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) (any, error) {
+	contractABI, data, err := packCall(abiJSON, functionName, args...)
+	if err != nil {
+		return nil, err
+	}
+	to := common.HexToAddress(address)
+	output, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", functionName, err)
+	}
+	results, err := contractABI.Unpack(functionName, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s result: %w", functionName, err)
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+// VerifyTypedData checks that the EIP-712 signature was created by the address.
+// Besides EOA signatures, EIP-1271 signatures of deployed smart wallets and
+// ERC-6492 signatures of wallets that are deployed on settlement are accepted
+// if the simulated deployment produces a wallet accepting the signature.
+func (s *EVMRPCSigner) VerifyTypedData(ctx context.Context, address string, domain sdk.TypedDataDomain, typeDefs map[string][]sdk.TypedDataField, primaryType string, message map[string]any, signature []byte) (bool, error) {
+	digest, err := evm.HashTypedData(evm.NewTypedData(domain, typeDefs, primaryType, message))
+	if err != nil {
+		return false, err
+	}
+	valid, sigData, err := sdk.VerifyUniversalSignature(ctx, s, address, [32]byte(digest), signature, true)
+	if err == nil && valid && sigData.Factory != ([20]byte{}) && len(sigData.FactoryCalldata) > 0 {
+		// the universal verification accepts undeployed wallets without checking the signature
+		code, err := s.GetCode(ctx, address)
+		if err != nil || len(code) > 0 {
+			return valid, err
+		}
+		return s.SimulateDeployment(ctx, common.Address(sigData.Factory).Hex(), sigData.FactoryCalldata, address, [32]byte(digest), sigData.InnerSignature)
+	}
+	if err != nil || valid || len(signature) != 65 {
+		return valid, err
+	}
+
+	// 65 byte signatures are checked as EOA signatures only, but deployed wallets may produce them as well
+	code, err := s.GetCode(ctx, address)
+	if err != nil || len(code) == 0 {
+		return false, err
+	}
+	return sdk.VerifyEIP1271Signature(ctx, s, address, [32]byte(digest), signature)
+}
+
+func (s *EVMRPCSigner) WriteContract(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) (string, error) {
+	_, data, err := packCall(abiJSON, functionName, args...)
+	if err != nil {
+		return "", err
+	}
+	return s.SendTransaction(ctx, address, data)
+}
+
+// SendTransaction signs and broadcasts a transaction calling the address with
+// the calldata. The gas, the signed transaction and failed RPC calls are
+// recorded in the diagnostic trail of ctx.
+func (s *EVMRPCSigner) SendTransaction(ctx context.Context, to string, data []byte) (string, error) {
+	toAddress := common.HexToAddress(to)
+	from := s.key.Address()
+
+	if err := s.lockSend(ctx); err != nil {
+		return "", err
+	}
+	defer s.unlockSend()
+
+	nonce, err := s.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return "", recordRPCError(ctx, fmt.Errorf("failed to get nonce: %w", err))
+	}
+	gasPrice, err := s.GasPrice(ctx)
+	if err != nil {
+		return "", recordRPCError(ctx, err)
+	}
+	gasLimit, estimated := s.gas.GasLimit, false
+	if gasLimit == 0 {
+		gasLimit, err = s.client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &toAddress, Data: data})
+		if err != nil {
+			return "", recordRPCError(ctx, fmt.Errorf("failed to estimate gas: %w", err))
+		}
+		estimated = true
+	}
+	diagnostics.Record(ctx, diagnostics.KindGasEstimate, "", map[string]string{
+		"gasPrice":  gasPrice.String(),
+		"gasLimit":  strconv.FormatUint(gasLimit, 10),
+		"estimated": strconv.FormatBool(estimated),
+		"nonce":     strconv.FormatUint(nonce, 10),
+	})
+
+	tx, err := s.newTx(ctx, nonce, gasPrice, gasLimit, toAddress, nil, data)
+	if err != nil {
+		return "", recordRPCError(ctx, err)
+	}
+	signed, err := s.key.SignTx(ctx, tx, s.chainID)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	if raw, err := signed.MarshalBinary(); err == nil {
+		diagnostics.Record(ctx, diagnostics.KindSignedTransaction, "", map[string]string{
+			"hash": signed.Hash().Hex(),
+			"raw":  hexutil.Encode(raw),
+		})
+	}
+	if err := s.client.SendTransaction(ctx, signed); err != nil {
+		return "", recordRPCError(ctx, fmt.Errorf("failed to send transaction: %w", err))
+	}
+	return signed.Hash().Hex(), nil
+}
+
+// recordRPCError records the error of an RPC call in the diagnostic trail of
+// ctx and returns it.
+func recordRPCError(ctx context.Context, err error) error {
+	diagnostics.Record(ctx, diagnostics.KindRPCError, err.Error(), nil)
+	return err
+}
+
+// TransferNative signs a transfer of native tokens from the account of the key
+// and broadcasts it. The key doesn't need to be the key of the signer.
+func (s *EVMRPCSigner) TransferNative(ctx context.Context, from TransactionSigner, to string, amount *big.Int) (string, error) {
+	toAddress := common.HexToAddress(to)
+	fromAddress := from.Address()
+
+	if err := s.lockSend(ctx); err != nil {
+		return "", err
+	}
+	defer s.unlockSend()
+
+	nonce, err := s.client.PendingNonceAt(ctx, fromAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to get nonce: %w", err)
+	}
+	gasPrice, err := s.GasPrice(ctx)
+	if err != nil {
+		return "", err
+	}
+	gasLimit, err := s.client.EstimateGas(ctx, ethereum.CallMsg{From: fromAddress, To: &toAddress, Value: amount})
+	if err != nil {
+		return "", fmt.Errorf("failed to estimate gas: %w", err)
+	}
+
+	tx, err := s.newTx(ctx, nonce, gasPrice, gasLimit, toAddress, amount, nil)
+	if err != nil {
+		return "", err
+	}
+	signed, err := from.SignTx(ctx, tx, s.chainID)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	if err := s.client.SendTransaction(ctx, signed); err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return signed.Hash().Hex(), nil
+}
+
+// WaitForTransactionReceipt waits until the transaction is mined, looking for
+// its receipt on every new block if subscribed to them and polling otherwise.
+func (s *EVMRPCSigner) WaitForTransactionReceipt(ctx context.Context, txHash string) (*sdk.TransactionReceipt, error) {
+	var receipt *ethTypes.Receipt
+	var err error
+	if s.heads != nil {
+		receipt, err = s.waitReceipt(ctx, txHash)
+	} else {
+		receipt, err = bind.WaitMined(ctx, s.client, common.HexToHash(txHash))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sdk.TransactionReceipt{
+		Status:      receipt.Status,
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		TxHash:      receipt.TxHash.Hex(),
+	}, nil
+}
+
+// GetBalance returns the token balance of the address, or its native balance if tokenAddress is empty.
+func (s *EVMRPCSigner) GetBalance(ctx context.Context, address string, tokenAddress string) (*big.Int, error) {
+	if tokenAddress == "" {
+		return s.client.BalanceAt(ctx, common.HexToAddress(address), nil)
+	}
+	result, err := s.ReadContract(ctx, tokenAddress, sdk.ERC20BalanceOfABI, "balanceOf", common.HexToAddress(address))
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := result.(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected balanceOf result %T", result)
+	}
+	return balance, nil
+}
+
+func (s *EVMRPCSigner) GetChainID(ctx context.Context) (*big.Int, error) {
+	return s.client.ChainID(ctx)
+}
+
+// GetCode returns the code of the address. Code of deployed contracts is cached,
+// addresses without code may be deployed to any moment.
+func (s *EVMRPCSigner) GetCode(ctx context.Context, address string) ([]byte, error) {
+	if code, ok := codeFromContext(ctx, common.HexToAddress(address)); ok {
+		return code, nil
+	}
+	if s.cache == nil {
+		return s.client.CodeAt(ctx, common.HexToAddress(address), nil)
+	}
+	code, err := s.cache.get(ctx, cacheCode, strings.ToLower(address), func(code any) bool {
+		return len(code.([]byte)) > 0
+	}, func() (any, error) {
+		return s.client.CodeAt(ctx, common.HexToAddress(address), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code.([]byte), nil
+}
+
+func (s *EVMRPCSigner) EstimateGas(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) (uint64, error) {
+	_, data, err := packCall(abiJSON, functionName, args...)
+	if err != nil {
+		return 0, err
+	}
+	to := common.HexToAddress(address)
+	gas, err := s.client.EstimateGas(ctx, ethereum.CallMsg{From: s.key.Address(), To: &to, Data: data})
+	if err != nil {
+		return 0, evm.AsRevertError(err)
+	}
+	return gas, nil
+}
+
+func (s *EVMRPCSigner) SimulateContract(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) error {
+	_, data, err := packCall(abiJSON, functionName, args...)
+	if err != nil {
+		return err
+	}
+	to := common.HexToAddress(address)
+	if _, err := s.client.CallContract(ctx, ethereum.CallMsg{From: s.key.Address(), To: &to, Data: data}, nil); err != nil {
+		return evm.AsRevertError(err)
+	}
+	return nil
+}
+
+// GasPrice returns the gas price chosen by the gas strategy adjusted by the gas policy.
+func (s *EVMRPCSigner) GasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := s.strategy.GasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+	return s.gas.apply(price), nil
+}
+
+func (s *EVMRPCSigner) TransactionReceipt(ctx context.Context, txHash string) (*ethTypes.Receipt, error) {
+	return s.client.TransactionReceipt(ctx, common.HexToHash(txHash))
+}
+
+func (s *EVMRPCSigner) BlockNumber(ctx context.Context) (uint64, error) {
+	return s.client.BlockNumber(ctx)
+}
+
+func (s *EVMRPCSigner) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	return s.client.HeaderByNumber(ctx, number)
+}
+
+// packCall parses the ABI and encodes the call of the function.
+func packCall(abiJSON []byte, functionName string, args ...any) (*abi.ABI, []byte, error) {
+	contractABI, err := abi.JSON(bytes.NewReader(abiJSON))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse abi: %w", err)
+	}
+	data, err := contractABI.Pack(functionName, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pack %s: %w", functionName, err)
+	}
+	return &contractABI, data, nil
+}
+
+// retryPolicy creates the retry policy of the RPC calls of a network, counting
+// and logging the retries and recording them in the diagnostic trail of the call.
+func retryPolicy(network string, config rpcretry.Config) *rpcretry.Policy {
+	policy := rpcretry.NewPolicy(config)
+	policy.OnRetry = func(ctx context.Context, attempt int, err error) {
+		metrics.RPCRetries.WithLabelValues(network).Inc()
+		logging.For(logging.RPC).Debug().Err(err).Str("network", network).Int("attempt", attempt).Msg("Retrying RPC call")
+		diagnostics.Record(ctx, diagnostics.KindRetry, err.Error(), map[string]string{"attempt": strconv.Itoa(attempt)})
+	}
+	return policy
+}
+
+// dialEVM connects to the first RPC endpoint that serves the expected chain,
+// giving up on the remaining ones once ctx is done.
+func dialEVM(ctx context.Context, network string, urls []string, chainID *big.Int) (*ethclient.Client, error) {
+	var errs []error
+	for _, url := range urls {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		rpcClient, err := rpc.DialOptions(dialCtx, url, rpcClientOptions(network)...)
+		if err != nil {
+			cancel()
+			errs = append(errs, fmt.Errorf("failed to connect to %s: %w", url, err))
+			continue
+		}
+
+		client := ethclient.NewClient(rpcClient)
+		remoteID, err := client.ChainID(dialCtx)
+		cancel()
+		if err != nil {
+			client.Close()
+			errs = append(errs, fmt.Errorf("failed to get chain ID from %s: %w", url, err))
+			continue
+		}
+		if remoteID.Cmp(chainID) != 0 {
+			client.Close()
+			errs = append(errs, fmt.Errorf("%s serves chain %s, expected %s", url, remoteID, chainID))
+			continue
+		}
+		return client, nil
+	}
+	return nil, errors.Join(errs...)
+}
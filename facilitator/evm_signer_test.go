@@ -0,0 +1,174 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/internal/rpcretry"
+)
+
+// rateLimited is the error of a rate limited RPC call
+type rateLimited struct{}
+
+func (rateLimited) Error() string  { return "too many requests" }
+func (rateLimited) ErrorCode() int { return -32005 }
+
+// sendChain accepts transactions, rate limiting the first broadcast.
+type sendChain struct {
+	sends        atomic.Int32
+	failEstimate atomic.Bool
+	raw          atomic.Value
+}
+
+func (c *sendChain) GetTransactionCount(common.Address, string) hexutil.Uint64 {
+	return 7
+}
+
+func (c *sendChain) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(2e9))
+}
+
+func (c *sendChain) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1e9))
+}
+
+func (c *sendChain) EstimateGas(map[string]any, *string) (hexutil.Uint64, error) {
+	if c.failEstimate.Load() {
+		return 0, errors.New("execution reverted")
+	}
+	return 60_000, nil
+}
+
+func (c *sendChain) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	if c.sends.Add(1) == 1 {
+		return common.Hash{}, rateLimited{}
+	}
+	c.raw.Store(raw.String())
+	tx := new(ethTypes.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+func TestSendTransactionDiagnostics(t *testing.T) {
+	chain := &sendChain{}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	client, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+	defer client.Close()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := NewEVMRPCSigner(client, big.NewInt(84532), crypto.FromECDSA(key), GasPolicy{})
+	require.NoError(t, err)
+	signer.client = rpcretry.NewClient(client, retryPolicy("eip155:84532", rpcretry.Config{InitialBackoff: time.Millisecond}))
+
+	trail := diagnostics.New()
+	ctx := diagnostics.With(t.Context(), trail)
+	txHash, err := signer.SendTransaction(ctx, "0x036CbD53842c5426634e7929541eC2318f3dCF7e", []byte{0x01})
+	require.NoError(t, err)
+
+	entries := trail.Entries()
+	require.Len(t, entries, 3)
+	require.Equal(t, diagnostics.KindGasEstimate, entries[0].Kind)
+	require.Equal(t, map[string]string{"gasPrice": "2000000000", "gasLimit": "60000", "estimated": "true", "nonce": "7"}, entries[0].Data)
+	require.Equal(t, diagnostics.KindSignedTransaction, entries[1].Kind)
+	require.Equal(t, txHash, entries[1].Data["hash"])
+	require.Equal(t, chain.raw.Load(), entries[1].Data["raw"], "the broadcast transaction is recorded")
+	require.Equal(t, diagnostics.KindRetry, entries[2].Kind)
+	require.Equal(t, "1", entries[2].Data["attempt"])
+
+	t.Run("failed calls are recorded", func(t *testing.T) {
+		chain.failEstimate.Store(true)
+		trail := diagnostics.New()
+		_, err := signer.SendTransaction(diagnostics.With(t.Context(), trail), "0x036CbD53842c5426634e7929541eC2318f3dCF7e", []byte{0x01})
+		require.Error(t, err)
+		entries := trail.Entries()
+		require.Len(t, entries, 1)
+		require.Equal(t, diagnostics.KindRPCError, entries[0].Kind)
+		require.Contains(t, entries[0].Message, "failed to estimate gas")
+		chain.failEstimate.Store(false)
+	})
+
+	t.Run("dynamic fee transactions", func(t *testing.T) {
+		signer.SetDynamicFee(true)
+		defer signer.SetDynamicFee(false)
+		_, err := signer.SendTransaction(t.Context(), "0x036CbD53842c5426634e7929541eC2318f3dCF7e", []byte{0x01})
+		require.NoError(t, err)
+		tx := new(ethTypes.Transaction)
+		require.NoError(t, tx.UnmarshalBinary(hexutil.MustDecode(chain.raw.Load().(string))))
+		require.Equal(t, uint8(ethTypes.DynamicFeeTxType), tx.Type())
+		require.Equal(t, big.NewInt(2e9), tx.GasFeeCap(), "capped at the gas price")
+		require.Equal(t, big.NewInt(1e9), tx.GasTipCap())
+		require.Equal(t, big.NewInt(84532), tx.ChainId())
+	})
+}
+
+// dialChain serves the chain ID and head of Base Sepolia, counting the calls.
+type dialChain struct {
+	calls atomic.Int32
+}
+
+func (c *dialChain) ChainId() *hexutil.Big {
+	c.calls.Add(1)
+	return (*hexutil.Big)(big.NewInt(84532))
+}
+
+func (c *dialChain) BlockNumber() hexutil.Uint64 {
+	c.calls.Add(1)
+	return 42
+}
+
+func TestNewEVMFacilitatorWithContext(t *testing.T) {
+	chain := &dialChain{}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", chain))
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := NewPrivateKeySigner(crypto.FromECDSA(key))
+	require.NoError(t, err)
+	config := NetworkConfig{Network: "eip155:84532", RPCURLs: []string{srv.URL}}
+	require.NoError(t, config.Normalize())
+
+	t.Run("dialing stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, err := NewEVMFacilitatorWithContext(ctx, config, signer)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Zero(t, chain.calls.Load())
+	})
+
+	t.Run("lazy dialing connects on the first call", func(t *testing.T) {
+		lazy := config
+		lazy.LazyDial = true
+		f, err := NewEVMFacilitatorWithContext(t.Context(), lazy, signer)
+		require.NoError(t, err)
+		require.Zero(t, chain.calls.Load(), "created without connecting")
+
+		head, err := f.signer.BlockNumber(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, uint64(42), head)
+		require.Equal(t, int32(2), chain.calls.Load(), "the chain ID is checked before the call")
+	})
+}
@@ -0,0 +1,348 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// splitABI declares the ERC-20 transfer shares are forwarded with and the
+// function of splitter contracts. splitWithAuthorization submits the
+// transferWithAuthorization of the payer to the splitter and pays the amounts
+// to the recipients, reverting as a whole if any transfer fails.
+var splitABI = []byte(`[
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[
+		{"name":"to","type":"address"},
+		{"name":"value","type":"uint256"}
+	],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"splitWithAuthorization","stateMutability":"nonpayable","inputs":[
+		{"name":"token","type":"address"},
+		{"name":"from","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"validAfter","type":"uint256"},
+		{"name":"validBefore","type":"uint256"},
+		{"name":"nonce","type":"bytes32"},
+		{"name":"signature","type":"bytes"},
+		{"name":"recipients","type":"address[]"},
+		{"name":"amounts","type":"uint256[]"}
+	],"outputs":[]}
+]`)
+
+// shareGas is the gas the transfer of a share is estimated at while it can't
+// be simulated, because the collection of the payment isn't mined yet
+const shareGas = 65_000
+
+// evmSplit settles payments divided between recipients
+type evmSplit struct {
+	// splitter contract, zero if the signer forwards the shares
+	contract      common.Address
+	maxRecipients int
+}
+
+func newEVMSplit(config SplitConfig) (*evmSplit, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	split := &evmSplit{maxRecipients: config.MaxRecipients}
+	if split.maxRecipients <= 0 {
+		split.maxRecipients = DefaultSplitRecipients
+	}
+	if config.Contract != "" {
+		if !common.IsHexAddress(config.Contract) {
+			return nil, fmt.Errorf("split: invalid contract address %q", config.Contract)
+		}
+		split.contract = common.HexToAddress(config.Contract)
+	}
+	return split, nil
+}
+
+// atomic reports whether a splitter contract pays the recipients.
+func (s *evmSplit) atomic() bool {
+	return s.contract != (common.Address{})
+}
+
+// splitCollector returns the address authorizations of split payments pay,
+// the splitter contract or the signer forwarding the shares.
+func (t *EVMFacilitator) splitCollector() common.Address {
+	if t.split.atomic() {
+		return t.split.contract
+	}
+	return t.permitSpender()
+}
+
+// splitSupport describes the split payments of the network, nil if it doesn't accept them.
+func (t *EVMFacilitator) splitSupport() *types.SplitSupport {
+	if t.split == nil {
+		return nil
+	}
+	method := types.SplitMethodSequential
+	if t.split.atomic() {
+		method = types.SplitMethodContract
+	}
+	return &types.SplitSupport{
+		Method:        method,
+		Collector:     t.splitCollector().Hex(),
+		MaxRecipients: t.split.maxRecipients,
+	}
+}
+
+// paymentSplit returns the split of the requirements, nil if they don't
+// divide the payment. Splits are accepted for EIP-3009 tokens of networks
+// configured for them, paying the collector of the network.
+func (t *EVMFacilitator) paymentSplit(req *types.PaymentRequirements) (*types.Split, error) {
+	split, err := types.SplitOf(req)
+	if err != nil {
+		return nil, types.ErrInvalidSplit
+	}
+	if split == nil {
+		return nil, nil
+	}
+	if t.split == nil || t.isNative(req.Asset) {
+		return nil, types.ErrSplitNotSupported
+	}
+	if asset := t.asset(req.Asset); asset != nil && asset.Permit {
+		return nil, types.ErrSplitNotSupported
+	}
+	if len(split.Recipients) == 0 || len(split.Recipients) > t.split.maxRecipients {
+		return nil, types.ErrInvalidSplit
+	}
+	total := 0
+	seen := make(map[common.Address]bool, len(split.Recipients))
+	for _, recipient := range split.Recipients {
+		address := common.HexToAddress(recipient.Address)
+		if !common.IsHexAddress(recipient.Address) || address == (common.Address{}) || seen[address] || recipient.Bps <= 0 {
+			return nil, types.ErrInvalidSplit
+		}
+		seen[address] = true
+		total += recipient.Bps
+	}
+	if total != types.SplitTotalBps {
+		return nil, types.ErrInvalidSplit
+	}
+	if !common.IsHexAddress(req.PayTo) || common.HexToAddress(req.PayTo) != t.splitCollector() {
+		return nil, types.ErrRecipientMismatch
+	}
+	return split, nil
+}
+
+// splitterArgs returns the arguments of splitWithAuthorization paying the
+// authorization of the payer to the recipients of the split.
+func splitterArgs(token common.Address, auth *evm.Authorization, signature []byte, split *types.Split) []any {
+	recipients := make([]common.Address, len(split.Recipients))
+	for i, recipient := range split.Recipients {
+		recipients[i] = common.HexToAddress(recipient.Address)
+	}
+	return []any{
+		token,
+		auth.From,
+		auth.Value,
+		auth.ValidAfter,
+		auth.ValidBefore,
+		auth.Nonce,
+		signature,
+		recipients,
+		split.Amounts(auth.Value),
+	}
+}
+
+// splitTransfers lists the shares of the split paid in the transaction.
+func splitTransfers(split *types.Split, value *big.Int, txHash string) []types.SplitTransfer {
+	amounts := split.Amounts(value)
+	transfers := make([]types.SplitTransfer, len(split.Recipients))
+	for i, recipient := range split.Recipients {
+		transfers[i] = types.SplitTransfer{
+			Recipient: common.HexToAddress(recipient.Address).Hex(),
+			Amount:    amounts[i].String(),
+			TxHash:    txHash,
+		}
+	}
+	return transfers
+}
+
+// PendingSplit returns the forwarding of the shares of a split payment the
+// signer collected in the settlement res, nil if the settlement paid the
+// recipients itself or failed.
+func (t *EVMFacilitator) PendingSplit(payload *types.PaymentPayload, req *types.PaymentRequirements, res *types.PaymentSettleResponse) *SplitForward {
+	if res == nil || !res.Success || t.split == nil || t.split.atomic() {
+		return nil
+	}
+	split, err := t.paymentSplit(req)
+	if err != nil || split == nil {
+		return nil
+	}
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	if err != nil {
+		return nil
+	}
+	asset := t.asset(req.Asset)
+	if asset == nil {
+		return nil
+	}
+	auth := evmPayload.Authorization
+	forward := &SplitForward{
+		Network:       t.network,
+		Token:         asset.Domain.VerifyingContract.Hex(),
+		Payer:         auth.From.Hex(),
+		Amount:        auth.Value.String(),
+		CollectTxHash: res.TxHash,
+		Status:        SplitPending,
+	}
+	amounts := split.Amounts(auth.Value)
+	for i, recipient := range split.Recipients {
+		forward.Shares = append(forward.Shares, SplitShare{
+			Recipient: common.HexToAddress(recipient.Address).Hex(),
+			Amount:    amounts[i].String(),
+		})
+	}
+	return forward
+}
+
+// ForwardSplit carries the forwarding of a split payment on from where it
+// stopped. Once the collection is mined, every share is simulated before the
+// first is sent, then they are sent one after the other, each waiting for the
+// one before. If a share reverts, what wasn't forwarded is returned to the
+// payer and the forwarding ends as SplitRefunded. A transfer interrupted
+// while it was sent may or may not be on chain, so the forwarding is
+// abandoned rather than paying twice.
+func (t *EVMFacilitator) ForwardSplit(ctx context.Context, forward *SplitForward, hooks SplitHooks) error {
+	if forward.Status != SplitPending {
+		return nil
+	}
+	receipt, err := t.signer.WaitForTransactionReceipt(ctx, forward.CollectTxHash)
+	if err != nil {
+		return fmt.Errorf("failed to wait for split payment %s: %w", forward.CollectTxHash, err)
+	}
+	if receipt.Status != sdk.TxStatusSuccess {
+		// nothing was collected
+		forward.Status, forward.Error = SplitAbandoned, types.ErrTransactionReverted.Error()
+		return hooks.save(ctx, forward)
+	}
+	if forward.Refund != nil {
+		return t.sendRefund(ctx, forward, hooks)
+	}
+
+	if !forward.started() {
+		for _, share := range forward.Shares {
+			amount, _ := new(big.Int).SetString(share.Amount, 10)
+			code, err := t.simulateShare(ctx, forward.Token, common.HexToAddress(share.Recipient), amount)
+			if err != nil {
+				return fmt.Errorf("failed to simulate share of %s: %w", share.Recipient, err)
+			}
+			if code != nil {
+				return t.returnSplit(ctx, forward, hooks, fmt.Errorf("share of %s: %w", share.Recipient, code))
+			}
+		}
+	}
+
+	for i := range forward.Shares {
+		share := &forward.Shares[i]
+		if share.Paid {
+			continue
+		}
+		if err := t.sendShare(ctx, forward, share, hooks); err != nil || forward.Status != SplitPending {
+			return err
+		}
+		receipt, err := t.signer.WaitForTransactionReceipt(ctx, share.TxHash)
+		if err != nil {
+			return fmt.Errorf("failed to wait for share %s of split payment %s: %w", share.TxHash, forward.CollectTxHash, err)
+		}
+		if receipt.Status != sdk.TxStatusSuccess {
+			return t.returnSplit(ctx, forward, hooks, fmt.Errorf("share of %s: %w", share.Recipient, types.ErrTransactionReverted))
+		}
+		share.Paid = true
+		if err := hooks.save(ctx, forward); err != nil {
+			// the share is only waited for again
+			logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("network", t.network).Str("tx_hash", share.TxHash).Msg("Failed to record paid split share")
+		}
+	}
+	forward.Status = SplitForwarded
+	return hooks.save(ctx, forward)
+}
+
+// sendShare transfers the share unless it was sent before. The share is
+// recorded as being sent first, so a forwarding interrupted before its
+// transaction hash was recorded is abandoned instead of sending it again.
+func (t *EVMFacilitator) sendShare(ctx context.Context, forward *SplitForward, share *SplitShare, hooks SplitHooks) error {
+	if share.TxHash != "" {
+		return nil
+	}
+	if share.Sending {
+		logging.Ctx(ctx, logging.Settlement).Error().Str("network", t.network).Str("tx_hash", forward.CollectTxHash).Str("recipient", share.Recipient).Msg("Lost track of split share, the rest stays with the signer")
+		forward.Status, forward.Error = SplitAbandoned, fmt.Sprintf("interrupted while sending the share of %s", share.Recipient)
+		return hooks.save(ctx, forward)
+	}
+	share.Sending = true
+	if err := hooks.save(ctx, forward); err != nil {
+		share.Sending = false
+		return fmt.Errorf("failed to record split share: %w", err)
+	}
+
+	amount, _ := new(big.Int).SetString(share.Amount, 10)
+	var txHash string
+	var err error
+	if sendErr := hooks.send(ctx, func() {
+		txHash, err = t.signer.WriteContract(ctx, forward.Token, splitABI, "transfer", common.HexToAddress(share.Recipient), amount)
+	}); sendErr != nil {
+		err = sendErr
+	}
+	share.Sending = false
+	if err != nil {
+		// nothing was sent, the share is sent again by the next try
+		if saveErr := hooks.save(ctx, forward); saveErr != nil {
+			logging.Ctx(ctx, logging.Settlement).Warn().Err(saveErr).Str("network", t.network).Msg("Failed to record unsent split share")
+		}
+		return fmt.Errorf("failed to send share of %s: %w", share.Recipient, err)
+	}
+	share.TxHash = txHash
+	if err := hooks.save(ctx, forward); err != nil {
+		logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("network", t.network).Str("tx_hash", txHash).Msg("Failed to record sent split share")
+	}
+	return nil
+}
+
+// simulateShare simulates the transfer of a share by the signer. A reverting
+// transfer returns the error code of its revert, a failed simulation err.
+func (t *EVMFacilitator) simulateShare(ctx context.Context, token string, to common.Address, amount *big.Int) (code, err error) {
+	err = t.signer.SimulateContract(ctx, token, splitABI, "transfer", to, amount)
+	var revert *evm.RevertError
+	if errors.As(err, &revert) {
+		reason, code := decodeRevertError(revert)
+		logging.Ctx(ctx, logging.RPC).Info().Str("network", t.network).Str("recipient", to.Hex()).Str("reason", reason).Msg("Split share simulation reverted")
+		return code, nil
+	}
+	return nil, err
+}
+
+// returnSplit starts returning the remainder of a split payment the signer
+// couldn't forward to the payer.
+func (t *EVMFacilitator) returnSplit(ctx context.Context, forward *SplitForward, hooks SplitHooks, reason error) error {
+	remainder, _ := new(big.Int).SetString(forward.Amount, 10)
+	for _, share := range forward.Shares {
+		if share.Paid {
+			amount, _ := new(big.Int).SetString(share.Amount, 10)
+			remainder.Sub(remainder, amount)
+		}
+	}
+	logging.Ctx(ctx, logging.Settlement).Warn().Err(reason).Str("network", t.network).Str("tx_hash", forward.CollectTxHash).Str("remainder", remainder.String()).Msg("Split payment failed, returning the remainder to the payer")
+	forward.Error = reason.Error()
+	forward.Refund = &SplitShare{Recipient: forward.Payer, Amount: remainder.String()}
+	return t.sendRefund(ctx, forward, hooks)
+}
+
+// sendRefund sends the return of the remainder to the payer, unless it was
+// sent before, and ends the forwarding as SplitRefunded.
+func (t *EVMFacilitator) sendRefund(ctx context.Context, forward *SplitForward, hooks SplitHooks) error {
+	if err := t.sendShare(ctx, forward, forward.Refund, hooks); err != nil || forward.Status != SplitPending {
+		return err
+	}
+	forward.Status = SplitRefunded
+	return hooks.save(ctx, forward)
+}
@@ -0,0 +1,216 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestEVMSplit(t *testing.T) {
+	const (
+		usdc     = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+		signer   = "0x00000000000000000000000000000000000000fa"
+		splitter = "0x00000000000000000000000000000000000000e5"
+		payee    = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+		platform = "0x00000000000000000000000000000000000000b0"
+	)
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+
+	payment := func(t *testing.T, collector string) *types.PaymentPayload {
+		auth := evm.NewAuthorization(payer.Hex(), collector, big.NewInt(10_001))
+		signature, err := evm.SignEip3009(auth, evm.NewDomainConfig("USDC", "2", big.NewInt(84532), usdc), evm.NewRawPrivateSigner(key.Serialize()))
+		require.NoError(t, err)
+		raw, err := json.Marshal(&evm.EVMPayload{Signature: signature, Authorization: auth})
+		require.NoError(t, err)
+		return &types.PaymentPayload{
+			X402Version: int(types.X402VersionV1),
+			Scheme:      string(types.EVM),
+			Network:     "eip155:84532",
+			Payload:     raw,
+		}
+	}
+	requirements := func(t *testing.T, collector string, recipients ...types.SplitRecipient) *types.PaymentRequirements {
+		req, err := types.BuildPaymentRequirements("eip155:84532", "USDC", "0.010001", collector, types.WithSplit(recipients...))
+		require.NoError(t, err)
+		return req
+	}
+	shares := []types.SplitRecipient{{Address: payee, Bps: 9000}, {Address: platform, Bps: 1000}}
+	newFacilitator := func(t *testing.T, split SplitConfig) (*EVMFacilitator, *mock.EVMSigner) {
+		chain := mock.NewEVMSigner(84532, signer)
+		chain.SetBalance(usdc, payer.Hex(), big.NewInt(10_001))
+		config := NetworkConfig{Network: "eip155:84532", Split: split}
+		require.NoError(t, config.Normalize())
+		f, err := NewEVMFacilitatorWithSigner(config, chain)
+		require.NoError(t, err)
+		return f, chain
+	}
+
+	t.Run("amounts", func(t *testing.T) {
+		split := &types.Split{Recipients: shares}
+		require.Equal(t, []*big.Int{big.NewInt(9001), big.NewInt(1000)}, split.Amounts(big.NewInt(10_001)))
+	})
+
+	t.Run("listed in the extra", func(t *testing.T) {
+		f, _ := newFacilitator(t, SplitConfig{Enabled: true})
+		require.Equal(t, &types.SplitSupport{
+			Method:        types.SplitMethodSequential,
+			Collector:     signer,
+			MaxRecipients: DefaultSplitRecipients,
+		}, f.GetExtra()["split"])
+
+		f, _ = newFacilitator(t, SplitConfig{})
+		require.NotContains(t, f.GetExtra(), "split")
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		f, _ := newFacilitator(t, SplitConfig{Enabled: true, MaxRecipients: 2})
+		res, err := f.Verify(t.Context(), payment(t, signer), requirements(t, signer, shares...))
+		require.NoError(t, err)
+		require.True(t, res.IsValid, res.InvalidReason)
+
+		for name, tc := range map[string]struct {
+			req  *types.PaymentRequirements
+			code error
+		}{
+			"shares below the total": {requirements(t, signer, types.SplitRecipient{Address: payee, Bps: 9000}), types.ErrInvalidSplit},
+			"duplicate recipients":   {requirements(t, signer, types.SplitRecipient{Address: payee, Bps: 5000}, types.SplitRecipient{Address: payee, Bps: 5000}), types.ErrInvalidSplit},
+			"too many recipients": {requirements(t, signer, types.SplitRecipient{Address: payee, Bps: 5000}, types.SplitRecipient{Address: platform, Bps: 4000},
+				types.SplitRecipient{Address: splitter, Bps: 1000}), types.ErrInvalidSplit},
+			"not paying the collector": {requirements(t, payee, shares...), types.ErrRecipientMismatch},
+		} {
+			res, err := f.Verify(t.Context(), payment(t, signer), tc.req)
+			require.NoError(t, err)
+			require.Equal(t, tc.code.Error(), res.InvalidReason, name)
+		}
+
+		f, _ = newFacilitator(t, SplitConfig{})
+		res, err = f.Verify(t.Context(), payment(t, signer), requirements(t, signer, shares...))
+		require.NoError(t, err)
+		require.Equal(t, types.ErrSplitNotSupported.Error(), res.InvalidReason)
+	})
+
+	settle := func(t *testing.T, f *EVMFacilitator) *SplitForward {
+		payload, req := payment(t, signer), requirements(t, signer, shares...)
+		res, err := f.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Empty(t, res.Splits, "the shares are forwarded after the settlement returned")
+		forward := f.PendingSplit(payload, req, res)
+		require.NotNil(t, forward)
+		require.Equal(t, res.TxHash, forward.CollectTxHash)
+		return forward
+	}
+
+	t.Run("sequential transfers", func(t *testing.T) {
+		f, chain := newFacilitator(t, SplitConfig{Enabled: true})
+		forward := settle(t, f)
+		require.Equal(t, 1, chain.Calls("WriteContract"), "only the collection")
+
+		var saved int
+		require.NoError(t, f.ForwardSplit(t.Context(), forward, SplitHooks{Save: func(ctx context.Context, forward *SplitForward) error {
+			saved++
+			return nil
+		}}))
+		require.Equal(t, SplitForwarded, forward.Status)
+		require.Equal(t, "9001", forward.Shares[0].Amount)
+		require.True(t, forward.Shares[1].Paid)
+		require.Equal(t, big.NewInt(9001), chain.Balance(usdc, payee))
+		require.Equal(t, big.NewInt(1000), chain.Balance(usdc, platform))
+		require.Zero(t, chain.Balance(usdc, signer).Sign())
+		require.Equal(t, 3, chain.Calls("WriteContract"), "the collection and two shares")
+		require.Equal(t, 7, saved, "before and after sending every share, once it is paid, and the end")
+
+		// a finished forwarding is left alone
+		require.NoError(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.Equal(t, 3, chain.Calls("WriteContract"))
+	})
+
+	t.Run("sequential transfers return the payment if a share can't be paid", func(t *testing.T) {
+		f, chain := newFacilitator(t, SplitConfig{Enabled: true})
+		chain.Freeze(usdc, platform)
+		forward := settle(t, f)
+		require.NoError(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.Equal(t, SplitRefunded, forward.Status)
+		require.Contains(t, forward.Error, common.HexToAddress(platform).Hex())
+		require.Equal(t, "10001", forward.Refund.Amount)
+		require.NotEmpty(t, forward.Refund.TxHash)
+		require.Equal(t, big.NewInt(10_001), chain.Balance(usdc, payer.Hex()))
+		require.Zero(t, chain.Balance(usdc, payee).Sign())
+	})
+
+	t.Run("sequential transfers are retried after RPC errors", func(t *testing.T) {
+		f, chain := newFacilitator(t, SplitConfig{Enabled: true})
+		forward := settle(t, f)
+		chain.Inject("SimulateContract", mock.Fault{Err: errors.New("connection refused"), Times: 1})
+		require.Error(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.Equal(t, SplitPending, forward.Status, "an unreachable RPC endpoint doesn't return the payment")
+		require.Nil(t, forward.Refund)
+
+		chain.Inject("WaitForTransactionReceipt", mock.Fault{Err: errors.New("connection refused"), Times: 2})
+		require.Error(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.Error(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.NoError(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.Equal(t, SplitForwarded, forward.Status)
+		require.Equal(t, 3, chain.Calls("WriteContract"), "no share is sent twice")
+		require.Equal(t, big.NewInt(9001), chain.Balance(usdc, payee))
+	})
+
+	t.Run("sequential transfers interrupted while sending are abandoned", func(t *testing.T) {
+		f, chain := newFacilitator(t, SplitConfig{Enabled: true})
+		forward := settle(t, f)
+		forward.Shares[0].Sending = true
+		require.NoError(t, f.ForwardSplit(t.Context(), forward, SplitHooks{}))
+		require.Equal(t, SplitAbandoned, forward.Status)
+		require.Equal(t, 1, chain.Calls("WriteContract"))
+	})
+
+	t.Run("unsaved transfers are not sent", func(t *testing.T) {
+		f, chain := newFacilitator(t, SplitConfig{Enabled: true})
+		forward := settle(t, f)
+		require.Error(t, f.ForwardSplit(t.Context(), forward, SplitHooks{Save: func(ctx context.Context, forward *SplitForward) error {
+			return errors.New("disk full")
+		}}))
+		require.False(t, forward.Shares[0].Sending)
+		require.Equal(t, 1, chain.Calls("WriteContract"))
+	})
+
+	t.Run("splitter contract", func(t *testing.T) {
+		f, chain := newFacilitator(t, SplitConfig{Enabled: true, Contract: splitter})
+		require.Equal(t, types.SplitMethodContract, f.splitSupport().Method)
+		payload, req := payment(t, splitter), requirements(t, splitter, shares...)
+
+		estimate, err := f.Estimate(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, estimate.Success, estimate.Error)
+
+		res, err := f.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.Equal(t, 1, chain.Calls("WriteContract"))
+		require.Equal(t, res.TxHash, res.Splits[1].TxHash)
+		require.Equal(t, big.NewInt(9001), chain.Balance(usdc, payee))
+		require.Equal(t, big.NewInt(1000), chain.Balance(usdc, platform))
+		require.NoError(t, f.VerifyTransfer(t.Context(), res.TxHash, payload, req))
+
+		// a frozen recipient reverts the whole split, nothing is broadcast
+		f, chain = newFacilitator(t, SplitConfig{Enabled: true, Contract: splitter})
+		chain.Freeze(usdc, platform)
+		res, err = f.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Zero(t, chain.Calls("WriteContract"))
+	})
+}
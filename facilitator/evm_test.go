@@ -4,8 +4,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/internal/mock"
 	"github.com/gosuda/x402-facilitator/scheme/evm"
 	"github.com/gosuda/x402-facilitator/types"
 	"github.com/stretchr/testify/require"
@@ -17,9 +22,17 @@ const (
 	Token      = "USDC"
 )
 
-func TestEVMVerify(t *testing.T) {
-	facilitator, err := NewEVMFacilitator(Network, "", PrivateKey)
+func newTestEVMFacilitator(t *testing.T) *EVMFacilitator {
+	config := NetworkConfig{Network: "eip155:84532"}
+	require.NoError(t, config.Normalize())
+
+	facilitator, err := NewEVMFacilitator(config, PrivateKey)
 	require.NoError(t, err)
+	return facilitator
+}
+
+func TestEVMVerify(t *testing.T) {
+	facilitator := newTestEVMFacilitator(t)
 
 	privKey, err := hex.DecodeString("")
 	require.NoError(t, err)
@@ -50,8 +63,7 @@ func TestEVMVerify(t *testing.T) {
 }
 
 func TestEVMSettle(t *testing.T) {
-	facilitator, err := NewEVMFacilitator(Network, "", PrivateKey)
-	require.NoError(t, err)
+	facilitator := newTestEVMFacilitator(t)
 
 	privKey, err := hex.DecodeString("")
 	require.NoError(t, err)
@@ -81,3 +93,124 @@ func TestEVMSettle(t *testing.T) {
 	require.NoError(t, err)
 	fmt.Println(string(jsonRes))
 }
+
+func TestEVMDomainOverride(t *testing.T) {
+	const usdc = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+	chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+	// the preset domain name of USDC on Base Sepolia is "USDC", the token is configured by symbol only
+	config := NetworkConfig{Network: "eip155:84532", Assets: []AssetConfig{{Symbol: "USDC", Name: "USD Coin"}}}
+	require.NoError(t, config.Normalize())
+	f, err := NewEVMFacilitatorWithSigner(config, chain)
+	require.NoError(t, err)
+
+	asset := f.asset("USDC")
+	require.NotNil(t, asset)
+	require.Equal(t, usdc, asset.Domain.VerifyingContract.Hex())
+	require.Equal(t, 6, asset.Decimals)
+	require.Equal(t, "USD Coin", asset.Domain.Name)
+	require.Equal(t, "2", asset.Domain.Version)
+
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	chain.SetBalance(usdc, payer.Hex(), big.NewInt(10_000))
+	verify := func(name string) *types.PaymentVerifyResponse {
+		auth := evm.NewAuthorization(payer.Hex(), "0x209693Bc6afc0C5328bA36FaF03C514EF312287C", big.NewInt(10_000))
+		signature, err := evm.SignEip3009(auth, evm.NewDomainConfig(name, "2", big.NewInt(84532), usdc), evm.NewRawPrivateSigner(key.Serialize()))
+		require.NoError(t, err)
+		evmPayload, err := json.Marshal(&evm.EVMPayload{Signature: signature, Authorization: auth})
+		require.NoError(t, err)
+
+		res, err := f.Verify(t.Context(), &types.PaymentPayload{
+			X402Version: int(types.X402VersionV1),
+			Scheme:      string(types.EVM),
+			Network:     "eip155:84532",
+			Payload:     evmPayload,
+		}, &types.PaymentRequirements{
+			Scheme:            string(types.EVM),
+			Network:           "eip155:84532",
+			MaxAmountRequired: "10000",
+			PayTo:             "0x209693Bc6afc0C5328bA36FaF03C514EF312287C",
+			Asset:             usdc,
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	res := verify("USD Coin")
+	require.True(t, res.IsValid, res.InvalidReason)
+	res = verify("USDC")
+	require.False(t, res.IsValid)
+	require.Equal(t, types.ErrInvalidSignature.Error(), res.InvalidReason)
+}
+
+func TestEVMExpiryClock(t *testing.T) {
+	const usdc = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+	chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+	config := NetworkConfig{Network: "eip155:84532"}
+	require.NoError(t, config.Normalize())
+	f, err := NewEVMFacilitatorWithSigner(config, chain)
+	require.NoError(t, err)
+	fake := clock.NewFake(time.Now())
+	f.SetClock(fake)
+
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	chain.SetBalance(usdc, payer.Hex(), big.NewInt(10_000))
+	// valid for an hour
+	auth := evm.NewAuthorization(payer.Hex(), "0x209693Bc6afc0C5328bA36FaF03C514EF312287C", big.NewInt(10_000))
+	signature, err := evm.SignEip3009(auth, evm.NewDomainConfig("USDC", "2", big.NewInt(84532), usdc), evm.NewRawPrivateSigner(key.Serialize()))
+	require.NoError(t, err)
+	evmPayload, err := json.Marshal(&evm.EVMPayload{Signature: signature, Authorization: auth})
+	require.NoError(t, err)
+	payload := &types.PaymentPayload{X402Version: int(types.X402VersionV1), Scheme: string(types.EVM), Network: "eip155:84532", Payload: evmPayload}
+	req := &types.PaymentRequirements{
+		Scheme:            string(types.EVM),
+		Network:           "eip155:84532",
+		MaxAmountRequired: "10000",
+		PayTo:             "0x209693Bc6afc0C5328bA36FaF03C514EF312287C",
+		Asset:             usdc,
+	}
+
+	res, err := f.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, res.IsValid, res.InvalidReason)
+
+	// within the expiry margin of the end of the hour
+	fake.Advance(time.Hour - DefaultExpiryMargin + time.Second)
+	res, err = f.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, res.IsValid)
+	require.Equal(t, types.ErrAuthorizationExpired.Error(), res.InvalidReason)
+	settled, err := f.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.False(t, settled.Success)
+	require.Zero(t, chain.Calls("WriteContract"))
+}
+
+func TestEVMCustomChain(t *testing.T) {
+	const token = "0x00000000000000000000000000000000000000c1"
+	chain := CustomChain{ChainID: 424242, NativeCurrency: "APP", Assets: []AssetConfig{
+		{Symbol: "TKN", Address: token, Decimals: 6, Name: "Token", Version: "1"},
+	}}
+	config := chain.Section(NetworkConfig{})
+	require.NoError(t, config.Normalize())
+	require.Equal(t, "eip155:424242", config.Network)
+
+	f, err := NewEVMFacilitatorWithSigner(config, mock.NewEVMSigner(424242, "0x00000000000000000000000000000000000000fa"))
+	require.NoError(t, err)
+	require.Equal(t, "APP", f.nativeCurrency)
+	catalog := f.catalog()
+	require.Len(t, catalog, 1)
+	require.Equal(t, "TKN", catalog[0].Symbol)
+
+	// the configured native currency overrides the preset
+	preset := NetworkConfig{Network: "eip155:84532", NativeCurrency: "SEP"}
+	require.NoError(t, preset.Normalize())
+	f, err = NewEVMFacilitatorWithSigner(preset, mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa"))
+	require.NoError(t, err)
+	require.Equal(t, "SEP", f.nativeCurrency)
+}
@@ -0,0 +1,177 @@
+package facilitator
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+var _ TokenResolver = (*EVMFacilitator)(nil)
+
+// tokenMetadataABI declares the ERC-20 metadata functions and the EIP-5267
+// domain of tokens, both read-only.
+var tokenMetadataABI = []byte(`[
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"version","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"eip712Domain","stateMutability":"view","inputs":[],"outputs":[
+		{"name":"fields","type":"bytes1"},
+		{"name":"name","type":"string"},
+		{"name":"version","type":"string"},
+		{"name":"chainId","type":"uint256"},
+		{"name":"verifyingContract","type":"address"},
+		{"name":"salt","type":"bytes32"},
+		{"name":"extensions","type":"uint256[]"}
+	]}
+]`)
+
+// splitUnresolved separates the configured assets whose metadata must be read
+// from the chain, those without a symbol, name or version after the presets.
+func splitUnresolved(configs []AssetConfig, chainInfo *evm.ChainInfo) (configured, unresolved []AssetConfig) {
+	for _, config := range configs {
+		if full, _ := withPreset(config, chainInfo); full.Symbol == "" || full.Name == "" || full.Version == "" {
+			unresolved = append(unresolved, config)
+		} else {
+			configured = append(configured, config)
+		}
+	}
+	return configured, unresolved
+}
+
+// ResolveTokens completes the assets configured by their address with the
+// metadata of their tokens and accepts them. The metadata is read from the
+// cache, if not nil, and otherwise from the token contracts and then cached.
+// Fields the configuration sets are kept. Replacing the assets with SetAssets
+// drops the assets still unresolved.
+func (t *EVMFacilitator) ResolveTokens(ctx context.Context, cache TokenCache) error {
+	t.assetsMu.Lock()
+	pending, version := t.unresolved, t.assetsVersion
+	t.assetsMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var resolved, failed []AssetConfig
+	var errs []error
+	for _, config := range pending {
+		token, err := t.token(ctx, config.Address, cache)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("network %s: asset %s: %w", t.network, config.Address, err))
+			failed = append(failed, config)
+			continue
+		}
+		config.Symbol = cmp.Or(config.Symbol, token.Symbol)
+		config.Decimals = cmp.Or(config.Decimals, token.Decimals)
+		config.Name = cmp.Or(config.Name, token.Name)
+		config.Version = cmp.Or(config.Version, token.Version)
+		resolved = append(resolved, config)
+	}
+	if len(resolved) == 0 {
+		return errors.Join(errs...)
+	}
+	added, err := evmAssets(resolved, t.networkID, evm.GetChainInfo(t.chainName))
+	if err != nil {
+		return fmt.Errorf("network %s: %w", t.network, err)
+	}
+
+	t.assetsMu.Lock()
+	defer t.assetsMu.Unlock()
+	if t.assetsVersion != version {
+		// an asset list replaced the configured assets meanwhile
+		return nil
+	}
+	assets := maps.Clone(*t.assets.Load())
+	maps.Copy(assets, added)
+	t.assets.Store(&assets)
+	t.unresolved = failed
+	for _, config := range resolved {
+		logging.Ctx(ctx, logging.Assets).Info().Str("network", t.network).Str("asset", config.Address).Str("symbol", config.Symbol).Msg("Resolved token metadata")
+	}
+	return errors.Join(errs...)
+}
+
+// token returns the metadata of the token from the cache or its contract.
+// Failing caches are logged and bypassed.
+func (t *EVMFacilitator) token(ctx context.Context, address string, cache TokenCache) (*TokenMetadata, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	address = common.HexToAddress(address).Hex()
+	if cache != nil {
+		token, err := cache.Token(ctx, t.network, address)
+		if err != nil {
+			logging.Ctx(ctx, logging.Assets).Warn().Err(err).Str("network", t.network).Str("asset", address).Msg("Failed to read cached token metadata")
+		} else if token != nil {
+			return token, nil
+		}
+	}
+	token, err := t.TokenMetadata(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if err := cache.SaveToken(ctx, t.network, address, token); err != nil {
+			logging.Ctx(ctx, logging.Assets).Warn().Err(err).Str("network", t.network).Str("asset", address).Msg("Failed to cache token metadata")
+		}
+	}
+	return token, nil
+}
+
+// TokenMetadata reads the metadata of the token from its contract. The
+// EIP-712 domain is read with eip712Domain (EIP-5267) and, for tokens
+// without, with name and version. Tokens reporting no version can't be
+// resolved, their version must be configured.
+func (t *EVMFacilitator) TokenMetadata(ctx context.Context, address string) (*TokenMetadata, error) {
+	symbol, err := t.readString(ctx, address, "symbol")
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.signer.ReadContract(ctx, address, tokenMetadataABI, "decimals")
+	if err != nil {
+		return nil, fmt.Errorf("decimals: %w", err)
+	}
+	decimals, ok := result.(uint8)
+	if !ok {
+		return nil, fmt.Errorf("decimals: unexpected result %T", result)
+	}
+	token := &TokenMetadata{Symbol: symbol, Decimals: int(decimals)}
+
+	if result, err := t.signer.ReadContract(ctx, address, tokenMetadataABI, "eip712Domain"); err == nil {
+		if fields, ok := result.([]any); ok && len(fields) > 2 {
+			token.Name, _ = fields[1].(string)
+			token.Version, _ = fields[2].(string)
+		}
+	}
+	if token.Name == "" {
+		if token.Name, err = t.readString(ctx, address, "name"); err != nil {
+			return nil, err
+		}
+	}
+	if token.Version == "" {
+		if token.Version, err = t.readString(ctx, address, "version"); err != nil {
+			return nil, fmt.Errorf("%w, the version of its EIP-712 domain must be configured", err)
+		}
+	}
+	return token, nil
+}
+
+// readString calls a function of the token returning a non-empty string.
+func (t *EVMFacilitator) readString(ctx context.Context, address, function string) (string, error) {
+	result, err := t.signer.ReadContract(ctx, address, tokenMetadataABI, function)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", function, err)
+	}
+	value, ok := result.(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%s: no value", function)
+	}
+	return value, nil
+}
@@ -0,0 +1,111 @@
+package facilitator
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+)
+
+// tokenCache is a TokenCache in memory
+type tokenCache map[string]*TokenMetadata
+
+func (c tokenCache) Token(ctx context.Context, network, address string) (*TokenMetadata, error) {
+	return c[network+":"+address], nil
+}
+
+func (c tokenCache) SaveToken(ctx context.Context, network, address string, token *TokenMetadata) error {
+	c[network+":"+address] = token
+	return nil
+}
+
+func TestEVMResolveTokens(t *testing.T) {
+	const (
+		// reports its EIP-712 domain with eip712Domain
+		domainToken = "0x00000000000000000000000000000000000000d1"
+		// reports it with name and version
+		legacyToken = "0x00000000000000000000000000000000000000d2"
+		// reports no version
+		versionless = "0x00000000000000000000000000000000000000d3"
+	)
+	chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+	chain.SetResult(domainToken, "symbol", "DOM")
+	chain.SetResult(domainToken, "decimals", uint8(18))
+	chain.SetResult(domainToken, "eip712Domain", []any{
+		[1]byte{0x0f}, "Domain Token", "2", big.NewInt(84532), common.HexToAddress(domainToken), [32]byte{}, []*big.Int{},
+	})
+	chain.SetResult(legacyToken, "symbol", "LEG")
+	chain.SetResult(legacyToken, "decimals", uint8(6))
+	chain.SetResult(legacyToken, "name", "Legacy Token")
+	chain.SetResult(legacyToken, "version", "1")
+	chain.SetResult(versionless, "symbol", "NOV")
+	chain.SetResult(versionless, "decimals", uint8(6))
+	chain.SetResult(versionless, "name", "Versionless Token")
+
+	config := NetworkConfig{Network: "eip155:84532", Assets: []AssetConfig{
+		{Address: domainToken},
+		// fields the configuration sets are kept
+		{Address: legacyToken, Symbol: "LGC"},
+		{Address: versionless},
+	}}
+	require.NoError(t, config.Normalize())
+	newFacilitator := func(t *testing.T) *EVMFacilitator {
+		f, err := NewEVMFacilitatorWithSigner(config, chain)
+		require.NoError(t, err)
+		return f
+	}
+
+	f := newFacilitator(t)
+	// neither the unresolved assets nor the presets are accepted
+	require.Empty(t, f.catalog())
+	_, _, ok := f.ResolveAsset(domainToken)
+	require.False(t, ok)
+
+	cache := tokenCache{}
+	err := f.ResolveTokens(t.Context(), cache)
+	require.ErrorContains(t, err, "asset "+versionless+": version: ")
+	require.Len(t, f.catalog(), 2)
+	symbol, decimals, ok := f.ResolveAsset(domainToken)
+	require.True(t, ok)
+	require.Equal(t, "DOM", symbol)
+	require.Equal(t, 18, decimals)
+	require.Equal(t, "Domain Token", f.asset("DOM").Domain.Name)
+	require.Equal(t, "2", f.asset("DOM").Domain.Version)
+	legacy := f.asset(legacyToken)
+	require.Equal(t, "LGC", legacy.Symbol)
+	require.Equal(t, "Legacy Token", legacy.Domain.Name)
+	require.Equal(t, &TokenMetadata{Symbol: "LEG", Decimals: 6, Name: "Legacy Token", Version: "1"}, cache["eip155:84532:"+common.HexToAddress(legacyToken).Hex()])
+	require.Len(t, cache, 2)
+
+	t.Run("failed tokens are tried again", func(t *testing.T) {
+		chain.SetResult(versionless, "version", "1")
+		defer chain.SetResult(versionless, "version", "")
+		require.NoError(t, f.ResolveTokens(t.Context(), cache))
+		_, _, ok := f.ResolveAsset("NOV")
+		require.True(t, ok)
+		require.Len(t, f.catalog(), 3)
+	})
+
+	t.Run("cached tokens aren't read again", func(t *testing.T) {
+		f := newFacilitator(t)
+		cache[f.network+":"+common.HexToAddress(versionless).Hex()] = &TokenMetadata{Symbol: "NOV", Decimals: 6, Name: "Versionless Token", Version: "3"}
+		reads := chain.Calls("ReadContract")
+		require.NoError(t, f.ResolveTokens(t.Context(), cache))
+		require.Equal(t, reads, chain.Calls("ReadContract"))
+		require.Equal(t, "3", f.asset(versionless).Domain.Version)
+	})
+
+	t.Run("asset lists drop the unresolved assets", func(t *testing.T) {
+		f := newFacilitator(t)
+		require.NoError(t, f.SetAssets([]AssetConfig{{Symbol: "USDC"}}))
+		require.NoError(t, f.ResolveTokens(t.Context(), nil))
+		catalog := f.catalog()
+		require.Len(t, catalog, 1)
+		require.True(t, strings.EqualFold("USDC", catalog[0].Symbol))
+	})
+}
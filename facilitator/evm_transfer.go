@@ -0,0 +1,85 @@
+package facilitator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// transferEventABI declares the ERC-20 Transfer event
+var transferEventABI = evm.MustParseABI(`[
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[
+		{"name":"from","type":"address","indexed":true},
+		{"name":"to","type":"address","indexed":true},
+		{"name":"value","type":"uint256","indexed":false}
+	]}
+]`)
+
+// transferTopic is the topic of ERC-20 Transfer events
+var transferTopic = transferEventABI.Events["Transfer"].ID
+
+// VerifyTransfer checks that the settlement transaction emitted the Transfer
+// event of the authorized transfer. A successful status alone isn't enough:
+// some tokens return false instead of reverting when a transfer fails. Native
+// payments have no event to check.
+func (t *EVMFacilitator) VerifyTransfer(ctx context.Context, txHash string, payload *types.PaymentPayload, req *types.PaymentRequirements) error {
+	if t.isNative(req.Asset) {
+		// the checked transaction of the payer moves the value if it succeeds
+		return nil
+	}
+	asset := t.asset(req.Asset)
+	if asset == nil {
+		return types.ErrTokenMismatch
+	}
+	var from, to common.Address
+	var value *big.Int
+	if asset.Permit {
+		permit, _, err := evm.ParsePermitPayload(payload.Payload)
+		if err != nil || !common.IsHexAddress(req.PayTo) {
+			return types.ErrInvalidPayloadFormat
+		}
+		from, to, value = permit.Owner, common.HexToAddress(req.PayTo), permit.Value
+	} else {
+		evmPayload, err := evm.ParsePayload(payload.Payload)
+		if err != nil {
+			return types.ErrInvalidPayloadFormat
+		}
+		auth := evmPayload.Authorization
+		from, to, value = auth.From, auth.To, auth.Value
+	}
+	receipt, err := t.signer.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to get receipt of %s: %w", txHash, err)
+	}
+
+	if hasTransfer(receipt.Logs, asset.Domain.VerifyingContract, from, to, value) {
+		return nil
+	}
+	// tokens taking a tolerated fee credit the recipient with less
+	if asset.TransferFeeToleranceBps > 0 && receivedAmount(receipt.Logs, asset.Domain.VerifyingContract, to).Cmp(asset.minReceived(value)) >= 0 {
+		return nil
+	}
+	return types.ErrTransferNotEmitted
+}
+
+// hasTransfer reports whether the logs contain a Transfer event of the token
+// moving value from one address to the other.
+func hasTransfer(logs []*ethTypes.Log, token, from, to common.Address, value *big.Int) bool {
+	for _, l := range logs {
+		if l.Address != token || len(l.Topics) != 3 || l.Topics[0] != transferTopic || len(l.Data) != 32 {
+			continue
+		}
+		if common.BytesToAddress(l.Topics[1][:]) == from &&
+			common.BytesToAddress(l.Topics[2][:]) == to &&
+			new(big.Int).SetBytes(l.Data).Cmp(value) == 0 {
+			return true
+		}
+	}
+	return false
+}
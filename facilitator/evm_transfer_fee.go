@@ -0,0 +1,141 @@
+package facilitator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// maxTransferFeeBps is the largest fee tolerance, the whole amount
+const maxTransferFeeBps = 10_000
+
+// LogSimulator is implemented by signers that can simulate a contract call and
+// return the events it emits. Verifications use it to find tokens that take a
+// fee on transfers, see AssetConfig.CheckTransferFee.
+type LogSimulator interface {
+	// SimulateLogs executes a contract call from the signer address against the
+	// latest block without broadcasting it and returns its logs. A reverting
+	// call returns an *evm.RevertError
+	SimulateLogs(ctx context.Context, address string, abi []byte, functionName string, args ...any) ([]*ethTypes.Log, error)
+}
+
+var _ LogSimulator = (*EVMRPCSigner)(nil)
+
+// simulatedBlock is a block of the result of eth_simulateV1
+type simulatedBlock struct {
+	Calls []struct {
+		Status     hexutil.Uint64 `json:"status"`
+		ReturnData hexutil.Bytes  `json:"returnData"`
+		Logs       []struct {
+			Address common.Address `json:"address"`
+			Topics  []common.Hash  `json:"topics"`
+			Data    hexutil.Bytes  `json:"data"`
+		} `json:"logs"`
+		Error *struct {
+			Message string `json:"message"`
+			Data    string `json:"data"`
+		} `json:"error"`
+	} `json:"calls"`
+}
+
+// SimulateLogs simulates the call with eth_simulateV1. Nodes of older client
+// versions answer with a method not found error.
+func (s *EVMRPCSigner) SimulateLogs(ctx context.Context, address string, abiJSON []byte, functionName string, args ...any) ([]*ethTypes.Log, error) {
+	_, data, err := packCall(abiJSON, functionName, args...)
+	if err != nil {
+		return nil, err
+	}
+	call := map[string]any{
+		"from": s.key.Address(),
+		"to":   common.HexToAddress(address),
+		"data": hexutil.Bytes(data),
+	}
+	var blocks []simulatedBlock
+	if err := s.client.CallContext(ctx, &blocks, "eth_simulateV1", map[string]any{
+		"blockStateCalls": []any{map[string]any{"calls": []any{call}}},
+	}, "latest"); err != nil {
+		return nil, evm.AsRevertError(err)
+	}
+	if len(blocks) != 1 || len(blocks[0].Calls) != 1 {
+		return nil, errors.New("eth_simulateV1 returned no call result")
+	}
+	result := blocks[0].Calls[0]
+	if result.Status != 1 {
+		revert := &evm.RevertError{}
+		if result.Error != nil {
+			revert.Data, _ = hexutil.Decode(result.Error.Data)
+			revert.Reason = evm.DecodeRevert(revert.Data)
+		}
+		return nil, revert
+	}
+	logs := make([]*ethTypes.Log, len(result.Logs))
+	for i, l := range result.Logs {
+		logs[i] = &ethTypes.Log{Address: l.Address, Topics: l.Topics, Data: l.Data}
+	}
+	return logs, nil
+}
+
+// checkTransferFee simulates the transfer of the authorization and rejects
+// it if the recipient would receive less than authorized, beyond the fee the
+// asset tolerates. Payers of such tokens would otherwise underpay resource
+// servers, who learn of it only when they reconcile their balance. Transfers
+// that can't be simulated, e.g. from a smart wallet that isn't deployed yet,
+// pass: the simulation before the settlement stops them if they revert.
+func (t *EVMFacilitator) checkTransferFee(ctx context.Context, asset *evmAsset, auth *evm.Authorization, signature []byte) error {
+	simulator, ok := t.signer.(LogSimulator)
+	if !ok {
+		return nil
+	}
+	sigData, err := sdk.ParseERC6492Signature(signature)
+	if err != nil || sigData.Factory != [20]byte{} {
+		return nil
+	}
+	logs, err := simulator.SimulateLogs(ctx, asset.Domain.VerifyingContract.Hex(), eip3009ABI, "transferWithAuthorization",
+		auth.From, auth.To, auth.Value, auth.ValidAfter, auth.ValidBefore, auth.Nonce, sigData.InnerSignature)
+	var revert *evm.RevertError
+	if errors.As(err, &revert) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to simulate transfer: %w", err)
+	}
+	received := receivedAmount(logs, asset.Domain.VerifyingContract, auth.To)
+	if received.Cmp(asset.minReceived(auth.Value)) < 0 {
+		logging.Ctx(ctx, logging.RPC).Info().Str("network", t.network).Str("asset", asset.Symbol).
+			Str("value", auth.Value.String()).Str("received", received.String()).Msg("Token takes a fee on transfers")
+		return types.ErrFeeOnTransferToken
+	}
+	return nil
+}
+
+// minReceived returns the least amount a transfer of value must credit the
+// recipient with, value less the fee the asset tolerates.
+func (a *evmAsset) minReceived(value *big.Int) *big.Int {
+	fee := new(big.Int).Mul(value, big.NewInt(int64(a.TransferFeeToleranceBps)))
+	fee.Quo(fee, big.NewInt(maxTransferFeeBps))
+	return fee.Sub(value, fee)
+}
+
+// receivedAmount sums the Transfer events of the token crediting the address.
+func receivedAmount(logs []*ethTypes.Log, token, to common.Address) *big.Int {
+	received := new(big.Int)
+	for _, l := range logs {
+		if l.Address != token || len(l.Topics) != 3 || l.Topics[0] != transferTopic || len(l.Data) != 32 {
+			continue
+		}
+		if common.BytesToAddress(l.Topics[2][:]) == to {
+			received.Add(received, new(big.Int).SetBytes(l.Data))
+		}
+	}
+	return received
+}
@@ -0,0 +1,95 @@
+package facilitator
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestEVMTransferFee(t *testing.T) {
+	const (
+		usdc   = "0x036CbD53842c5426634e7929541eC2318f3dCF7e"
+		signer = "0x00000000000000000000000000000000000000fa"
+		payTo  = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+	)
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+
+	payload := func(t *testing.T) *types.PaymentPayload {
+		auth := evm.NewAuthorization(payer.Hex(), payTo, big.NewInt(10_000))
+		signature, err := evm.SignEip3009(auth, evm.NewDomainConfig("USDC", "2", big.NewInt(84532), usdc), evm.NewRawPrivateSigner(key.Serialize()))
+		require.NoError(t, err)
+		raw, err := json.Marshal(&evm.EVMPayload{Signature: signature, Authorization: auth})
+		require.NoError(t, err)
+		return &types.PaymentPayload{
+			X402Version: int(types.X402VersionV1),
+			Scheme:      string(types.EVM),
+			Network:     "eip155:84532",
+			Payload:     raw,
+		}
+	}
+	req, err := types.BuildPaymentRequirements("eip155:84532", "USDC", "0.01", payTo)
+	require.NoError(t, err)
+	newFacilitator := func(t *testing.T, feeBps int64, asset AssetConfig) (*EVMFacilitator, *mock.EVMSigner) {
+		chain := mock.NewEVMSigner(84532, signer)
+		chain.SetBalance(usdc, payer.Hex(), big.NewInt(10_000))
+		chain.SetTransferFee(usdc, feeBps)
+		asset.Symbol = "USDC"
+		config := NetworkConfig{Network: "eip155:84532", Assets: []AssetConfig{asset}}
+		require.NoError(t, config.Normalize())
+		f, err := NewEVMFacilitatorWithSigner(config, chain)
+		require.NoError(t, err)
+		return f, chain
+	}
+
+	for name, tc := range map[string]struct {
+		feeBps int64
+		asset  AssetConfig
+		reason string
+	}{
+		"token without fee":    {0, AssetConfig{CheckTransferFee: true}, ""},
+		"fee rejected":         {100, AssetConfig{CheckTransferFee: true}, types.ErrFeeOnTransferToken.Error()},
+		"fee within tolerance": {100, AssetConfig{CheckTransferFee: true, TransferFeeToleranceBps: 100}, ""},
+		"fee over tolerance":   {200, AssetConfig{CheckTransferFee: true, TransferFeeToleranceBps: 100}, types.ErrFeeOnTransferToken.Error()},
+		"check disabled":       {100, AssetConfig{}, ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, chain := newFacilitator(t, tc.feeBps, tc.asset)
+			res, err := f.Verify(t.Context(), payload(t), req)
+			require.NoError(t, err)
+			require.Equal(t, tc.reason == "", res.IsValid, res.InvalidReason)
+			require.Equal(t, tc.reason, res.InvalidReason)
+			simulations := 0
+			if tc.asset.CheckTransferFee {
+				simulations = 1
+			}
+			require.Equal(t, simulations, chain.Calls("SimulateLogs"))
+		})
+	}
+
+	t.Run("settled within tolerance", func(t *testing.T) {
+		f, chain := newFacilitator(t, 100, AssetConfig{CheckTransferFee: true, TransferFeeToleranceBps: 100})
+		payment := payload(t)
+		res, err := f.Settle(t.Context(), payment, req)
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		require.NoError(t, f.VerifyTransfer(t.Context(), res.TxHash, payment, req))
+		require.Equal(t, int64(9_900), chain.Balance(usdc, payTo).Int64())
+	})
+
+	t.Run("tolerance out of range", func(t *testing.T) {
+		config := NetworkConfig{Network: "eip155:84532", Assets: []AssetConfig{{Symbol: "USDC", TransferFeeToleranceBps: 10_001}}}
+		require.NoError(t, config.Normalize())
+		_, err := NewEVMFacilitatorWithSigner(config, mock.NewEVMSigner(84532, signer))
+		require.ErrorContains(t, err, "transfer fee tolerance")
+	})
+}
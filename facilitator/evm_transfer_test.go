@@ -0,0 +1,43 @@
+package facilitator
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasTransfer(t *testing.T) {
+	token := common.HexToAddress("0x036CbD53842c5426634e7929541eC2318f3dCF7e")
+	from := common.HexToAddress("0x01")
+	to := common.HexToAddress("0x02")
+	transfer := func(token, from, to common.Address, value int64) *ethTypes.Log {
+		return &ethTypes.Log{
+			Address: token,
+			Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:    common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+		}
+	}
+
+	tests := []struct {
+		name string
+		logs []*ethTypes.Log
+		want bool
+	}{
+		{"matching transfer", []*ethTypes.Log{transfer(token, from, to, 1000)}, true},
+		{"among other logs", []*ethTypes.Log{transfer(token, to, from, 5), {Address: token}, transfer(token, from, to, 1000)}, true},
+		{"no logs", nil, false},
+		{"other token", []*ethTypes.Log{transfer(common.HexToAddress("0x03"), from, to, 1000)}, false},
+		{"other recipient", []*ethTypes.Log{transfer(token, from, common.HexToAddress("0x03"), 1000)}, false},
+		{"other payer", []*ethTypes.Log{transfer(token, common.HexToAddress("0x03"), to, 1000)}, false},
+		{"other amount", []*ethTypes.Log{transfer(token, from, to, 999)}, false},
+		{"approval topic", []*ethTypes.Log{{Address: token, Topics: []common.Hash{{1}, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}, Data: common.LeftPadBytes(big.NewInt(1000).Bytes(), 32)}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, hasTransfer(tt.logs, token, from, to, big.NewInt(1000)))
+		})
+	}
+}
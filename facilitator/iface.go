@@ -2,7 +2,10 @@ package facilitator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"time"
 
 	"github.com/gosuda/x402-facilitator/types"
 )
@@ -10,20 +13,300 @@ import (
 type Facilitator interface {
 	Verify(ctx context.Context, payment *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error)
 	Settle(ctx context.Context, payment *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error)
-	Supported() []*types.SupportedKind
+	// GetExtra returns the scheme specific information advertised in the supported kinds
+	GetExtra() map[string]any
+	// GetSigners returns the addresses the facilitator signs settlements with
+	GetSigners() []string
 }
 
-func NewFacilitator(scheme types.Scheme, network, rpcUrl string, privateKeyHex string) (Facilitator, error) {
-	switch scheme {
+// Estimator is implemented by facilitators that can simulate a settlement
+// and estimate its cost without broadcasting it.
+type Estimator interface {
+	Estimate(ctx context.Context, payment *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentEstimateResponse, error)
+}
+
+// AssetResolver is implemented by facilitators that know the tokens they accept.
+type AssetResolver interface {
+	// ResolveAsset returns the symbol and decimals of an accepted asset given by symbol or address
+	ResolveAsset(asset string) (symbol string, decimals int, ok bool)
+}
+
+// AssetUpdater is implemented by facilitators whose accepted assets can be
+// replaced while they serve, see package assetlist.
+type AssetUpdater interface {
+	// SetAssets replaces the accepted assets, which are completed with the
+	// network presets like the configured ones
+	SetAssets(assets []AssetConfig) error
+	// RegistryAssets reads the assets listed by an asset registry contract
+	RegistryAssets(ctx context.Context, contract string) ([]AssetConfig, error)
+}
+
+// TokenResolver is implemented by facilitators that accept assets configured
+// by their address alone, once the metadata of the token is read from the
+// chain.
+type TokenResolver interface {
+	// ResolveTokens reads the metadata of the assets that lack it, from the
+	// cache if it holds them, and accepts them. Assets whose metadata can't be
+	// read are tried again by the next call
+	ResolveTokens(ctx context.Context, cache TokenCache) error
+}
+
+// TokenCache keeps the token metadata read from the chain, so it is read once.
+type TokenCache interface {
+	// Token returns the metadata of the token on the network, nil if none is kept
+	Token(ctx context.Context, network, address string) (*TokenMetadata, error)
+	// SaveToken keeps the metadata of the token on the network
+	SaveToken(ctx context.Context, network, address string, token *TokenMetadata) error
+}
+
+// TokenMetadata describes a token as its contract reports it.
+type TokenMetadata struct {
+	Symbol   string
+	Decimals int
+	// Name and Version of the EIP-712 domain the token verifies signatures with
+	Name    string
+	Version string
+}
+
+// AuthorizationReader is implemented by facilitators whose payments carry a
+// single-use authorization, it identifies the authorization without settling it.
+type AuthorizationReader interface {
+	// Authorization returns the payer and the nonce of the authorization, ok is false if the payload is malformed
+	Authorization(payment *types.PaymentPayload) (payer, nonce string, ok bool)
+}
+
+// ExpiryReader is implemented by facilitators whose payment authorizations expire.
+type ExpiryReader interface {
+	// Expiry returns the time the authorization expires at, ok is false if it doesn't or the payload is malformed
+	Expiry(payment *types.PaymentPayload) (expiresAt time.Time, ok bool)
+}
+
+// AmountReader is implemented by facilitators whose payment authorizations name
+// the amount they transfer, which may be more than the required one.
+type AmountReader interface {
+	// Amount returns the atomic units the authorization transfers, ok is false if the payload is malformed
+	Amount(payment *types.PaymentPayload) (amount *big.Int, ok bool)
+}
+
+// ReceiptWaiter is implemented by facilitators that can follow a submitted
+// settlement transaction until it is mined and confirmed.
+type ReceiptWaiter interface {
+	// WaitMined blocks until the transaction is included in a block
+	WaitMined(ctx context.Context, txHash string) (*Receipt, error)
+	// WaitConfirmed blocks until the mined transaction has the given number of confirmations.
+	// The receipt is updated if a reorg moved the transaction into another block.
+	WaitConfirmed(ctx context.Context, receipt *Receipt, confirmations uint64) error
+}
+
+// TransferVerifier is implemented by facilitators that can check that a mined
+// settlement moved the payment, for tokens that fail without reverting.
+type TransferVerifier interface {
+	// VerifyTransfer returns types.ErrTransferNotEmitted if the successful
+	// transaction didn't transfer the paid amount from the payer to the recipient
+	VerifyTransfer(ctx context.Context, txHash string, payload *types.PaymentPayload, req *types.PaymentRequirements) error
+}
+
+// SplitForwarder is implemented by facilitators whose signer collects split
+// payments and forwards the shares to their recipients after the settlement
+// returned, see types.SplitMethodSequential.
+type SplitForwarder interface {
+	// PendingSplit returns the forwarding the settlement res of the payment
+	// needs, nil if it needs none
+	PendingSplit(payment *types.PaymentPayload, req *types.PaymentRequirements, res *types.PaymentSettleResponse) *SplitForward
+	// ForwardSplit carries the forwarding on from where it stopped, passing
+	// its progress to the hooks, until it ends with a final status. An error
+	// means it stopped early and is to be tried again
+	ForwardSplit(ctx context.Context, forward *SplitForward, hooks SplitHooks) error
+}
+
+// Statuses of a SplitForward
+const (
+	// SplitPending means the shares are still being forwarded
+	SplitPending = "pending"
+	// SplitForwarded means every share was paid
+	SplitForwarded = "forwarded"
+	// SplitRefunded means a share couldn't be paid and what wasn't forwarded was returned to the payer
+	SplitRefunded = "refunded"
+	// SplitAbandoned means nothing was collected, or the outcome of a transfer is unknown and the rest stays with the signer
+	SplitAbandoned = "abandoned"
+)
+
+// SplitForward is the forwarding of a split payment the signer collected.
+type SplitForward struct {
+	Network string
+	// Token contract of the payment
+	Token string
+	Payer string
+	// Amount collected in atomic units of the token
+	Amount string
+	// Transaction collecting the payment from the payer
+	CollectTxHash string
+	Shares        []SplitShare
+	// Transfer returning what wasn't forwarded to the payer, nil unless a share failed
+	Refund *SplitShare
+	Status string
+	// Why the shares weren't all paid
+	Error string
+}
+
+// started reports whether a transfer of the forwarding may have been sent.
+func (f *SplitForward) started() bool {
+	for _, share := range f.Shares {
+		if share.Sending || share.TxHash != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitShare is a transfer of a SplitForward.
+type SplitShare struct {
+	Recipient string
+	// Amount in atomic units of the token
+	Amount string
+	TxHash string
+	// Set while the transfer is sent, before its transaction hash is known
+	Sending bool
+	// Whether the transfer was mined successfully
+	Paid bool
+}
+
+// SplitHooks let the caller of ForwardSplit order and persist the forwarding.
+// Unset hooks send right away and keep the progress in memory only.
+type SplitHooks struct {
+	// Send runs fn, which sends a transaction of the signer, in turn with the
+	// other transactions of the signer
+	Send func(ctx context.Context, fn func()) error
+	// Save persists the progress of the forwarding. It is called before every
+	// transfer is sent, a failure keeps the transfer from being sent
+	Save func(ctx context.Context, forward *SplitForward) error
+}
+
+func (h SplitHooks) send(ctx context.Context, fn func()) error {
+	if h.Send == nil {
+		fn()
+		return nil
+	}
+	return h.Send(ctx, fn)
+}
+
+func (h SplitHooks) save(ctx context.Context, forward *SplitForward) error {
+	if h.Save == nil {
+		return nil
+	}
+	return h.Save(ctx, forward)
+}
+
+// GasBalanceReader is implemented by facilitators whose signers pay for the gas
+// of settlements in the native currency of the network.
+type GasBalanceReader interface {
+	// GasBalances returns the native balance of every signer, none if a third party pays for gas
+	GasBalances(ctx context.Context) ([]GasBalance, error)
+}
+
+// GasPriceReader is implemented by facilitators that pay for settlements at a
+// gas price in the native currency of the network.
+type GasPriceReader interface {
+	// GasPrice returns the price in atomic units per gas settlements are submitted with
+	GasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// GasFunder is implemented by facilitators that can top up the gas balance of
+// their signers from another account.
+type GasFunder interface {
+	// FundGas transfers the amount in atomic units of the native currency from
+	// the account of the key to the signer and returns the transaction hash
+	FundGas(ctx context.Context, from TransactionSigner, signer string, amount *big.Int) (string, error)
+}
+
+// ErrIndexingUnsupported is returned by TransferIndexer implementations that
+// can't index the chain with their current configuration.
+var ErrIndexingUnsupported = errors.New("transfer indexing is not supported by this signer")
+
+// TransferIndexer is implemented by facilitators that can list the token
+// transfers of their signers on chain, to reconcile them with the settlements
+// on record.
+type TransferIndexer interface {
+	// Head returns the number of the latest block
+	Head(ctx context.Context) (uint64, error)
+	// BlockTransfers returns the transactions the signers sent in the block and
+	// the transfers of accepted tokens they made or that involve a signer
+	BlockTransfers(ctx context.Context, number uint64) (*BlockTransfers, error)
+	// HasReceipt reports whether the transaction was mined
+	HasReceipt(ctx context.Context, txHash string) (bool, error)
+}
+
+// HeadNotifier is implemented by facilitators that can be subscribed to the
+// new blocks of their chain, e.g. over a websocket RPC endpoint, so callers
+// waiting for blocks needn't poll.
+type HeadNotifier interface {
+	// NewHead returns a channel that is closed once a new block arrives or the
+	// subscription fails, nil while there is no live subscription
+	NewHead() <-chan struct{}
+}
+
+// BlockTransfers are the transactions and token transfers of the signers of a facilitator in a block.
+type BlockTransfers struct {
+	Number uint64
+	Time   time.Time
+	// Hashes of the transactions the signers sent
+	Transactions []string
+	Transfers    []Transfer
+}
+
+// Transfer is a token transfer on chain.
+type Transfer struct {
+	TxHash string
+	// Account that sent the transaction
+	Sender string
+	Token  string
+	From   string
+	To     string
+	// Amount in atomic units of the token
+	Amount *big.Int
+}
+
+// GasBalance is the native balance a signer pays for gas with.
+type GasBalance struct {
+	Signer string
+	// Balance in atomic units of Currency
+	Amount   *big.Int
+	Currency string
+	Decimals int
+}
+
+// Receipt is the scheme-independent outcome of a mined settlement transaction.
+type Receipt struct {
+	TxHash      string
+	BlockNumber uint64
+	Success     bool
+
+	// Gas used by the transaction and the price it was paid at, zero if unknown
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+	// Fee paid by the facilitator in atomic units of FeeCurrency, nil if unknown
+	Fee         *big.Int
+	FeeCurrency string
+	FeeDecimals int
+}
+
+func NewFacilitator(config NetworkConfig, privateKeyHex string) (Facilitator, error) {
+	return NewFacilitatorWithContext(context.Background(), config, privateKeyHex)
+}
+
+// NewFacilitatorWithContext creates the facilitator of the network, giving up
+// connecting to EVM networks once ctx is done.
+func NewFacilitatorWithContext(ctx context.Context, config NetworkConfig, privateKeyHex string) (Facilitator, error) {
+	switch config.Scheme {
 	case types.EVM:
-		return NewEVMFacilitator(network, rpcUrl, privateKeyHex)
+		return newEVMFacilitator(ctx, config, privateKeyHex)
 	case types.Solana:
-		return NewSolanaFacilitator(network, rpcUrl, privateKeyHex)
+		return NewSolanaFacilitator(config, privateKeyHex)
 	case types.Sui:
-		return NewSuiFacilitator(network, rpcUrl, privateKeyHex)
+		return NewSuiFacilitator(config, privateKeyHex)
 	case types.Tron:
-		return NewTronFacilitator(network, rpcUrl, privateKeyHex)
+		return NewTronFacilitator(config, privateKeyHex)
 	default:
-		return nil, fmt.Errorf("unsupporsed scheme: %s", scheme)
+		return nil, fmt.Errorf("unsupporsed scheme: %s", config.Scheme)
 	}
 }
@@ -0,0 +1,34 @@
+package facilitator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gosuda/x402-facilitator/paymentctx"
+)
+
+// Memos tracing settlement transactions to their API requests
+const (
+	requestMemoPrefix = "x402:"
+	// request IDs are sent by clients, longer ones are cut
+	maxMemoRequestID = 64
+)
+
+// requestMemo returns the memo tracing a transaction to the API request of the
+// context, "x402:<request ID>", or nil without a request ID. Request IDs are
+// reduced to printable ASCII, as memos are public and shown by explorers.
+func requestMemo(ctx context.Context) []byte {
+	requestID := strings.Map(func(r rune) rune {
+		if r < 0x21 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, paymentctx.From(ctx).RequestID)
+	if requestID == "" {
+		return nil
+	}
+	if len(requestID) > maxMemoRequestID {
+		requestID = requestID[:maxMemoRequestID]
+	}
+	return []byte(requestMemoPrefix + requestID)
+}
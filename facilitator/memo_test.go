@@ -0,0 +1,22 @@
+package facilitator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/paymentctx"
+)
+
+func TestRequestMemo(t *testing.T) {
+	memo := func(requestID string) []byte {
+		return requestMemo(paymentctx.With(t.Context(), paymentctx.Metadata{RequestID: requestID}))
+	}
+	require.Nil(t, requestMemo(t.Context()))
+	require.Equal(t, []byte("x402:6e81_I2ixXbb6"), memo("6e81_I2ixXbb6"))
+	require.Equal(t, []byte("x402:order-42"), memo("order\t-42\n"), "whitespace and control characters are dropped")
+	require.Equal(t, []byte("x402:caf"), memo("café"))
+	require.Nil(t, memo(" \t"))
+	require.Len(t, memo(strings.Repeat("a", 100)), len("x402:")+64)
+}
@@ -0,0 +1,70 @@
+package facilitator
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+// ErrNetworkPaused is returned for settlements on a network whose settlements
+// an operator paused, see Registry.Pause
+var ErrNetworkPaused = errors.New("settlements on the network are paused")
+
+// Pause stops settlements on the network, e.g. during a reorg or an incident
+// of its RPC endpoints, until Resume is called. Payments are still verified.
+// The pause is kept in memory, a restart resumes the network. It returns when
+// the network was paused, earlier if it already was.
+func (r *Registry) Pause(network string) (time.Time, error) {
+	entry, ok := r.lookup(network)
+	if !ok {
+		return time.Time{}, fmt.Errorf("network %s is not configured", network)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if since, paused := r.paused[entry.config.Network]; paused {
+		return since, nil
+	}
+	if r.paused == nil {
+		r.paused = make(map[string]time.Time)
+	}
+	since := time.Now()
+	r.paused[entry.config.Network] = since
+	metrics.NetworkPaused.WithLabelValues(entry.config.Network).Set(1)
+	return since, nil
+}
+
+// Resume lets settlements on a paused network proceed again.
+func (r *Registry) Resume(network string) error {
+	entry, ok := r.lookup(network)
+	if !ok {
+		return fmt.Errorf("network %s is not configured", network)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.paused, entry.config.Network)
+	metrics.NetworkPaused.WithLabelValues(entry.config.Network).Set(0)
+	return nil
+}
+
+// Paused reports whether settlements on the network are paused, and since when.
+func (r *Registry) Paused(network string) (time.Time, bool) {
+	entry, ok := r.lookup(network)
+	if !ok {
+		return time.Time{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	since, paused := r.paused[entry.config.Network]
+	return since, paused
+}
+
+// PausedNetworks returns when the settlements of each paused network were
+// paused, by CAIP-2 identifier.
+func (r *Registry) PausedNetworks() map[string]time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return maps.Clone(r.paused)
+}
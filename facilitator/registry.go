@@ -0,0 +1,667 @@
+package facilitator
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/apikey"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/screening"
+	"github.com/gosuda/x402-facilitator/tenant"
+	"github.com/gosuda/x402-facilitator/types"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+// ErrNotSupported is returned when the facilitator of a network doesn't support an operation
+var ErrNotSupported = errors.New("operation not supported by facilitator")
+
+// ErrChainUnavailable is returned for settlements, and verifications that
+// can't do without the chain, while the RPC endpoints of a network that
+// verifies through outages can't be reached
+var ErrChainUnavailable = errors.New("RPC endpoints of the network are unreachable")
+
+var _ Estimator = (*Registry)(nil)
+
+// Registry holds the facilitators of all configured networks and routes
+// every payment to the facilitator of its network. Networks without a section
+// of their own are served by a family section like "eip155:*" matching them,
+// whose facilitator of the network is created on the first payment routed to it.
+type Registry struct {
+	// guards entries and networks, which grow as families serve new networks, and paused
+	mu          sync.RWMutex
+	entries     map[string]*registryEntry // by CAIP-2 network
+	networks    []string                  // in registration order
+	families    []*registryFamily         // in registration order
+	priceOracle oracle.PriceOracle        // optional, prices payments for fiat policies
+	recipients  RecipientChecker          // optional, checks recipients of registered recipient policies
+	screener    screening.Screener        // optional, screens payers and recipients
+	verifyCache *verifyCache              // nil if verify results aren't reused
+	stats       *registryStats
+	paused      map[string]time.Time // when settlements were paused by CAIP-2 network, see Pause
+}
+
+// FacilitatorFactory creates the facilitator of a network served by a family
+// section, given the configuration returned by NetworkConfig.ForChain.
+type FacilitatorFactory func(config NetworkConfig) (Facilitator, error)
+
+type registryFamily struct {
+	config  NetworkConfig
+	factory FacilitatorFactory
+}
+
+// RecipientChecker knows the recipients registered to receive payments, see package recipient.
+type RecipientChecker interface {
+	// IsRegistered reports whether the address has a valid registration on the network
+	IsRegistered(ctx context.Context, network, address string) (bool, error)
+}
+
+type registryEntry struct {
+	config      NetworkConfig
+	facilitator Facilitator
+	// pattern of the family section serving the network, empty for networks with a section of their own
+	family string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries:     make(map[string]*registryEntry),
+		verifyCache: newVerifyCache(VerifyCacheConfig{}),
+		stats:       newRegistryStats(),
+	}
+}
+
+// Register adds the facilitator serving the configured network. Its section
+// takes precedence over family sections matching the network.
+func (r *Registry) Register(config NetworkConfig, facilitator Facilitator) error {
+	if config.IsFamily() {
+		return fmt.Errorf("network %s: family sections are registered with RegisterFamily", config.Network)
+	}
+	if err := r.checkConfig(config); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[config.Network]; ok {
+		return fmt.Errorf("network %s is already registered", config.Network)
+	}
+	r.entries[config.Network] = &registryEntry{
+		config:      config,
+		facilitator: facilitator,
+	}
+	r.networks = append(r.networks, config.Network)
+	return nil
+}
+
+// RegisterFamily adds a family section like "eip155:*", serving the networks
+// of the family that have no section of their own and RPC endpoints, see
+// NetworkConfig.ForChain. The factory creates the facilitator of such a
+// network when the first payment is routed to it. Families registered
+// earlier take precedence over later ones matching the same networks.
+func (r *Registry) RegisterFamily(config NetworkConfig, factory FacilitatorFactory) error {
+	if !config.IsFamily() {
+		return fmt.Errorf("network %s is not a family like %q", config.Network, caip.Family(config.Network))
+	}
+	if err := r.checkConfig(config); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, family := range r.families {
+		if family.config.Network == config.Network {
+			return fmt.Errorf("network %s is already registered", config.Network)
+		}
+	}
+	r.families = append(r.families, &registryFamily{config: config, factory: factory})
+	return nil
+}
+
+// checkConfig checks that the policies of the network can be enforced.
+func (r *Registry) checkConfig(config NetworkConfig) error {
+	if config.Policy.MaxAmountUSD > 0 && r.priceOracle == nil {
+		return fmt.Errorf("network %s: maxAmountUsd requires a price oracle", config.Network)
+	}
+	if config.Gas.MaxGasCostUSD > 0 && r.priceOracle == nil {
+		return fmt.Errorf("network %s: maxGasCostUsd requires a price oracle", config.Network)
+	}
+	if config.Policy.RegisteredRecipients && r.recipients == nil {
+		return fmt.Errorf("network %s: registeredRecipients requires a recipient registry", config.Network)
+	}
+	return nil
+}
+
+// SetPriceOracle sets the oracle payments are valued with. Networks with a fiat
+// payment policy can only be registered once an oracle is set.
+func (r *Registry) SetPriceOracle(priceOracle oracle.PriceOracle) {
+	r.priceOracle = priceOracle
+}
+
+// SetRecipients sets the registry of recipients. Networks only paying registered
+// recipients can only be registered once it is set.
+func (r *Registry) SetRecipients(recipients RecipientChecker) {
+	r.recipients = recipients
+}
+
+// SetScreener sets the screening of the payers and recipients of payments,
+// whose sanctioned addresses are rejected.
+func (r *Registry) SetScreener(screener screening.Screener) {
+	r.screener = screener
+}
+
+// SetVerifyCache sets how long verify results are reused, 10 seconds by default.
+func (r *Registry) SetVerifyCache(config VerifyCacheConfig) {
+	r.verifyCache = newVerifyCache(config)
+}
+
+// PriceOracle returns the oracle payments are valued with, nil if none is set.
+func (r *Registry) PriceOracle() oracle.PriceOracle {
+	return r.priceOracle
+}
+
+// Lookup returns the facilitator and configuration of a network.
+// The network may be given as CAIP-2 identifier or as a known chain name.
+func (r *Registry) Lookup(network string) (Facilitator, NetworkConfig, bool) {
+	entry, ok := r.lookup(network)
+	if !ok {
+		return nil, NetworkConfig{}, false
+	}
+	return entry.facilitator, entry.config, true
+}
+
+// Route returns the section serving the network: its own CAIP-2 identifier,
+// or the pattern of the family section serving it.
+func (r *Registry) Route(network string) (string, bool) {
+	entry, ok := r.lookup(network)
+	if !ok {
+		return "", false
+	}
+	return cmp.Or(entry.family, entry.config.Network), true
+}
+
+func (r *Registry) lookup(network string) (*registryEntry, bool) {
+	network = caip.Normalize(network)
+	r.mu.RLock()
+	entry, ok := r.entries[network]
+	families := len(r.families)
+	r.mu.RUnlock()
+	if ok || families == 0 {
+		return entry, ok
+	}
+	return r.serveFromFamily(network)
+}
+
+// serveFromFamily creates the facilitator of the network with the first
+// family section that can serve it and registers it.
+func (r *Registry) serveFromFamily(network string) (*registryEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.entries[network]; ok {
+		// created by a concurrent lookup
+		return entry, true
+	}
+	logger := logging.For(logging.RPC).With().Str("network", network).Logger()
+	for _, family := range r.families {
+		config, ok := family.config.ForChain(network)
+		if !ok {
+			continue
+		}
+		f, err := family.factory(config)
+		if err != nil {
+			logger.Warn().Err(err).Str("family", family.config.Network).Msg("Failed to create facilitator of family section")
+			continue
+		}
+		entry := &registryEntry{config: config, facilitator: f, family: family.config.Network}
+		r.entries[network] = entry
+		r.networks = append(r.networks, network)
+		logger.Info().Str("family", family.config.Network).Msg("Routing network to family section")
+		return entry, true
+	}
+	logger.Debug().Msg("No section serves network")
+	return nil, false
+}
+
+// SettleBy returns the last time the payment can be broadcast, its expiry
+// less the expiry margin of the network. ok is false if it doesn't expire.
+func (r *Registry) SettleBy(payload *types.PaymentPayload) (time.Time, bool) {
+	f, config, ok := r.Lookup(payload.Network)
+	if !ok {
+		return time.Time{}, false
+	}
+	reader, ok := f.(ExpiryReader)
+	if !ok {
+		return time.Time{}, false
+	}
+	expiresAt, ok := reader.Expiry(payload)
+	if !ok {
+		return time.Time{}, false
+	}
+	return expiresAt.Add(-cmp.Or(config.ExpiryMargin, DefaultExpiryMargin)), true
+}
+
+// Networks returns the configuration of every registered network, including
+// the networks family sections served so far.
+func (r *Registry) Networks() []NetworkConfig {
+	entries := r.snapshot()
+	configs := make([]NetworkConfig, 0, len(entries))
+	for _, entry := range entries {
+		configs = append(configs, entry.config)
+	}
+	return configs
+}
+
+// Families returns the configuration of every registered family section.
+func (r *Registry) Families() []NetworkConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	configs := make([]NetworkConfig, 0, len(r.families))
+	for _, family := range r.families {
+		configs = append(configs, family.config)
+	}
+	return configs
+}
+
+// snapshot returns the entries of the registered networks in registration order.
+func (r *Registry) snapshot() []*registryEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]*registryEntry, 0, len(r.networks))
+	for _, network := range r.networks {
+		entries = append(entries, r.entries[network])
+	}
+	return entries
+}
+
+func (r *Registry) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (resp *types.PaymentVerifyResponse, err error) {
+	facilitator, config, ok := r.Lookup(payload.Network)
+	defer func(start time.Time) {
+		r.stats.record(opVerify, config.Network, start, verifyRejection(resp), err)
+	}(time.Now())
+	if !ok {
+		return &types.PaymentVerifyResponse{
+			IsValid:       false,
+			InvalidReason: types.ErrInvalidNetwork.Error(),
+		}, nil
+	}
+	if err := r.checkPolicy(ctx, config, payload, req); err != nil {
+		return &types.PaymentVerifyResponse{
+			IsValid:       false,
+			InvalidReason: err.Error(),
+		}, nil
+	}
+	resp, err = r.verifyCached(ctx, facilitator, payload, req)
+	if err != nil || !resp.IsValid {
+		return resp, err
+	}
+	if err := r.screen(ctx, config.Network, resp.Payer, req); err != nil {
+		return &types.PaymentVerifyResponse{
+			IsValid:       false,
+			InvalidReason: err.Error(),
+			Payer:         resp.Payer,
+		}, nil
+	}
+	return resp, nil
+}
+
+// verifyCached verifies the payment with the facilitator, or returns the
+// result of the same verification made within the TTL of the cache.
+func (r *Registry) verifyCached(ctx context.Context, facilitator Facilitator, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
+	key := ""
+	if r.verifyCache != nil {
+		key = verifyKey(payload, req)
+	}
+	if key == "" {
+		return checkVerified(facilitator.Verify(ctx, payload, req))
+	}
+	if !bypassesCache(ctx) {
+		if resp, ok := r.verifyCache.get(payload.Network, key, time.Now()); ok {
+			return resp, nil
+		}
+	}
+	resp, err := checkVerified(facilitator.Verify(ctx, payload, req))
+	if err == nil {
+		r.verifyCache.put(key, resp, time.Now())
+	}
+	return resp, err
+}
+
+// checkVerified turns a verification without response into ErrNotSupported,
+// facilitators of schemes that aren't implemented may return neither.
+func checkVerified(resp *types.PaymentVerifyResponse, err error) (*types.PaymentVerifyResponse, error) {
+	if err == nil && resp == nil {
+		return nil, ErrNotSupported
+	}
+	return resp, err
+}
+
+func (r *Registry) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (resp *types.PaymentSettleResponse, err error) {
+	facilitator, config, ok := r.Lookup(payload.Network)
+	defer func(start time.Time) {
+		r.stats.record(opSettle, config.Network, start, settleRejection(resp), err)
+	}(time.Now())
+	if !ok {
+		return &types.PaymentSettleResponse{
+			Success: false,
+			Error:   types.ErrInvalidNetwork.Error(),
+		}, nil
+	}
+	if _, paused := r.Paused(config.Network); paused {
+		return nil, ErrNetworkPaused
+	}
+	if err := r.checkPolicy(ctx, config, payload, req); err != nil {
+		return &types.PaymentSettleResponse{
+			Success:   false,
+			Error:     err.Error(),
+			NetworkId: payload.Network,
+		}, nil
+	}
+	payer := ""
+	if reader, ok := facilitator.(AuthorizationReader); ok {
+		payer, _, _ = reader.Authorization(payload)
+	}
+	if err := r.screen(ctx, config.Network, payer, req); err != nil {
+		return &types.PaymentSettleResponse{
+			Success:   false,
+			Error:     err.Error(),
+			Payer:     payer,
+			NetworkId: payload.Network,
+		}, nil
+	}
+	if reason, err := r.checkGasCost(ctx, facilitator, config, payload, req); err != nil {
+		return nil, err
+	} else if reason != nil {
+		return &types.PaymentSettleResponse{
+			Success:   false,
+			Error:     reason.Error(),
+			Payer:     payer,
+			NetworkId: payload.Network,
+		}, nil
+	}
+	if r.verifyCache != nil {
+		// once settled, the authorization of the payment is used
+		r.verifyCache.forget(verifyKey(payload, req))
+	}
+	resp, err = facilitator.Settle(ctx, payload, req)
+	if err == nil && resp == nil {
+		return nil, ErrNotSupported
+	}
+	return resp, err
+}
+
+// checkGasCost estimates the settlement and returns the reason to reject it,
+// types.ErrGasTooExpensive if it costs more than the gas ceiling of the
+// network. Settlements that can't be estimated, or fail to, are left to the
+// facilitator.
+func (r *Registry) checkGasCost(ctx context.Context, facilitator Facilitator, config NetworkConfig, payload *types.PaymentPayload, req *types.PaymentRequirements) (reason, err error) {
+	if !config.Gas.hasCeiling() {
+		return nil, nil
+	}
+	estimator, ok := facilitator.(Estimator)
+	if !ok {
+		return nil, nil
+	}
+	estimate, err := estimator.Estimate(ctx, payload, req)
+	if err != nil || !estimate.Success {
+		return nil, err
+	}
+	return r.gasCeiling(ctx, config, estimate), nil
+}
+
+// gasCeiling returns types.ErrGasTooExpensive if the estimated cost exceeds a
+// ceiling of the network, types.ErrPriceUnavailable if the USD ceiling can't
+// be checked.
+func (r *Registry) gasCeiling(ctx context.Context, config NetworkConfig, estimate *types.PaymentEstimateResponse) error {
+	cost, ok := new(big.Int).SetString(estimate.GasCost, 10)
+	if !ok {
+		return nil
+	}
+	if config.Gas.MaxGasCostWei > 0 && cost.Cmp(new(big.Int).SetUint64(config.Gas.MaxGasCostWei)) > 0 {
+		return types.ErrGasTooExpensive
+	}
+	if config.Gas.MaxGasCostUSD <= 0 {
+		return nil
+	}
+	native, err := strconv.ParseFloat(estimate.GasCostNative, 64)
+	if err != nil {
+		return nil
+	}
+	price, err := r.priceOracle.PriceUSD(ctx, estimate.NativeCurrency)
+	if err != nil {
+		logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("network", config.Network).Msg("Failed to price gas cost")
+		return types.ErrPriceUnavailable
+	}
+	if native*price > config.Gas.MaxGasCostUSD {
+		return types.ErrGasTooExpensive
+	}
+	return nil
+}
+
+// checkPolicy enforces the networks of the API key and the allowlists of the
+// tenant of the request, the registration of the recipients and the fiat
+// ceiling of the network. Payments that can't be checked are rejected, unknown
+// assets are left to the facilitator to reject.
+func (r *Registry) checkPolicy(ctx context.Context, config NetworkConfig, payload *types.PaymentPayload, req *types.PaymentRequirements) error {
+	if key := apikey.FromContext(ctx); key != nil && !apikey.PermitsNetwork(key, config.Network) {
+		return types.ErrNetworkNotAllowed
+	}
+	recipients := paymentRecipients(req)
+	if t := tenant.FromContext(ctx); t != nil {
+		symbol, _, _ := r.ResolveAsset(config.Network, req.Asset)
+		for _, recipient := range recipients {
+			if err := t.Permits(config.Network, req.Asset, symbol, recipient); err != nil {
+				return err
+			}
+		}
+	}
+	if config.Policy.RegisteredRecipients {
+		for _, recipient := range recipients {
+			registered, err := r.recipients.IsRegistered(ctx, config.Network, recipient)
+			if err != nil {
+				logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("network", config.Network).Msg("Failed to look up recipient")
+				return types.ErrRecipientUnavailable
+			}
+			if !registered {
+				return types.ErrRecipientNotRegistered
+			}
+		}
+	}
+	if config.Policy.MaxAmountUSD <= 0 {
+		return nil
+	}
+	value, err := r.ValueUSD(ctx, config.Network, payload, req)
+	if errors.Is(err, ErrNotSupported) {
+		return nil
+	} else if err != nil {
+		logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("network", config.Network).Msg("Failed to price payment")
+		return types.ErrPriceUnavailable
+	}
+	if value.USD > config.Policy.MaxAmountUSD {
+		return types.ErrAmountExceedsLimit
+	}
+	return nil
+}
+
+// paymentRecipients returns the addresses a payment pays. The recipients of a
+// split are paid, not the collector they are paid through.
+func paymentRecipients(req *types.PaymentRequirements) []string {
+	if split, err := types.SplitOf(req); err == nil && split != nil {
+		recipients := make([]string, 0, len(split.Recipients))
+		for _, recipient := range split.Recipients {
+			recipients = append(recipients, recipient.Address)
+		}
+		return recipients
+	}
+	return []string{req.PayTo}
+}
+
+// screen rejects payments whose payer or recipients the screener blocks. The
+// payer may be empty if it isn't known. Payments that can't be screened are
+// rejected too.
+func (r *Registry) screen(ctx context.Context, network, payer string, req *types.PaymentRequirements) error {
+	if r.screener == nil {
+		return nil
+	}
+	addresses := paymentRecipients(req)
+	if payer != "" {
+		addresses = append([]string{payer}, addresses...)
+	}
+	for _, address := range addresses {
+		result, err := r.screener.Screen(ctx, network, address)
+		if err != nil {
+			logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("network", network).Str("address", address).Msg("Failed to screen address")
+			return types.ErrScreeningUnavailable
+		}
+		if result.Blocked {
+			logging.Ctx(ctx, logging.Settlement).Warn().Str("network", network).Str("address", address).Str("reason", result.Reason).Msg("Rejected payment of a blocked address")
+			return types.ErrComplianceRejected
+		}
+	}
+	return nil
+}
+
+// PaymentValue is the fiat value of a payment.
+type PaymentValue struct {
+	// Symbol of the paid asset
+	Symbol string
+	// Value of the paid amount in USD
+	USD float64
+}
+
+// ValueUSD prices the amount the payment transfers, which is the value of its
+// authorization if the facilitator can read it and the amount required by the
+// payment requirements otherwise. It returns ErrNotSupported if no oracle is
+// set or the facilitator can't resolve the asset.
+func (r *Registry) ValueUSD(ctx context.Context, network string, payload *types.PaymentPayload, req *types.PaymentRequirements) (*PaymentValue, error) {
+	if r.priceOracle == nil {
+		return nil, ErrNotSupported
+	}
+	symbol, decimals, ok := r.ResolveAsset(network, req.Asset)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	amount, ok := r.paidAmount(network, payload)
+	if !ok {
+		if amount, ok = new(big.Int).SetString(req.MaxAmountRequired, 10); !ok {
+			return nil, fmt.Errorf("invalid amount %q", req.MaxAmountRequired)
+		}
+	}
+	usd, err := oracle.ValueUSD(ctx, r.priceOracle, symbol, amount, decimals)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentValue{Symbol: symbol, USD: usd}, nil
+}
+
+// paidAmount returns the amount the authorization of the payment transfers,
+// false if the facilitator of the network can't read it.
+func (r *Registry) paidAmount(network string, payload *types.PaymentPayload) (*big.Int, bool) {
+	facilitator, _, ok := r.Lookup(network)
+	if !ok {
+		return nil, false
+	}
+	reader, ok := facilitator.(AmountReader)
+	if !ok {
+		return nil, false
+	}
+	return reader.Amount(payload)
+}
+
+// ResolveAsset returns the symbol and decimals of an asset accepted on the network.
+func (r *Registry) ResolveAsset(network, asset string) (string, int, bool) {
+	facilitator, _, ok := r.Lookup(network)
+	if !ok {
+		return "", 0, false
+	}
+	resolver, ok := facilitator.(AssetResolver)
+	if !ok {
+		return "", 0, false
+	}
+	return resolver.ResolveAsset(asset)
+}
+
+func (r *Registry) Estimate(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (resp *types.PaymentEstimateResponse, err error) {
+	facilitator, config, ok := r.Lookup(payload.Network)
+	defer func(start time.Time) {
+		r.stats.record(opEstimate, config.Network, start, estimateRejection(resp), err)
+	}(time.Now())
+	if !ok {
+		return &types.PaymentEstimateResponse{
+			Success: false,
+			Error:   types.ErrInvalidNetwork.Error(),
+		}, nil
+	}
+	estimator, ok := facilitator.(Estimator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	resp, err = estimator.Estimate(ctx, payload, req)
+	if err != nil || !resp.Success || !config.Gas.hasCeiling() {
+		return resp, err
+	}
+	// the settlement would be rejected, the estimate says why
+	if ceilingErr := r.gasCeiling(ctx, config, resp); ceilingErr != nil {
+		resp.Success, resp.Error = false, ceilingErr.Error()
+	}
+	return resp, nil
+}
+
+// Supported lists a kind for every registered network and supported x402 version,
+// together with the signer addresses of each CAIP-2 family.
+func (r *Registry) Supported() *types.SupportedResponse {
+	resp := &types.SupportedResponse{
+		Kinds:   []types.SupportedKind{},
+		Signers: make(map[string][]string),
+	}
+	entries := r.snapshot()
+	for _, entry := range entries {
+		extra := entry.facilitator.GetExtra()
+		if _, paused := r.Paused(entry.config.Network); paused {
+			// the extra of the facilitator isn't modified
+			extra = maps.Clone(extra)
+			if extra == nil {
+				extra = make(map[string]any)
+			}
+			extra["paused"] = true
+		}
+		for _, version := range types.SupportedX402Versions {
+			resp.Kinds = append(resp.Kinds, types.SupportedKind{
+				X402Version: int(version),
+				Scheme:      string(entry.config.Scheme),
+				Network:     entry.config.Network,
+				Extra:       extra,
+			})
+		}
+
+	}
+	signers := r.Signers()
+	for _, entry := range entries {
+		family := caip.Family(entry.config.Network)
+		for _, signer := range signers[entry.config.Network] {
+			if !slices.Contains(resp.Signers[family], signer) {
+				resp.Signers[family] = append(resp.Signers[family], signer)
+			}
+		}
+	}
+	return resp
+}
+
+// Signers returns the addresses settlements are signed with on every
+// registered network that has signers.
+func (r *Registry) Signers() map[string][]string {
+	entries := r.snapshot()
+	signers := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		if addresses := entry.facilitator.GetSigners(); len(addresses) > 0 {
+			signers[entry.config.Network] = addresses
+		}
+	}
+	return signers
+}
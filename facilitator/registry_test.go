@@ -0,0 +1,507 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/screening"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+type stubFacilitator struct {
+	network string
+	signer  string
+
+	verifies      int      // calls of Verify
+	invalidReason string   // payments are invalid for this reason if set
+	payer         string   // reported as the payer of valid payments
+	amount        *big.Int // transferred by the authorization of payments if set
+}
+
+func (f *stubFacilitator) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
+	f.verifies++
+	if f.invalidReason != "" {
+		return &types.PaymentVerifyResponse{IsValid: false, InvalidReason: f.invalidReason}, nil
+	}
+	return &types.PaymentVerifyResponse{IsValid: true, Payer: f.payer}, nil
+}
+
+func (f *stubFacilitator) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
+	return &types.PaymentSettleResponse{Success: true}, nil
+}
+
+func (f *stubFacilitator) GetExtra() map[string]any {
+	return map[string]any{"network": f.network}
+}
+
+func (f *stubFacilitator) GetSigners() []string {
+	return []string{f.signer}
+}
+
+func (f *stubFacilitator) Amount(payment *types.PaymentPayload) (*big.Int, bool) {
+	return f.amount, f.amount != nil
+}
+
+func (f *stubFacilitator) ResolveAsset(asset string) (string, int, bool) {
+	return "USDC", 6, asset == "USDC"
+}
+
+// silentFacilitator answers neither with a response nor an error.
+type silentFacilitator struct {
+	stubFacilitator
+}
+
+func (f *silentFacilitator) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
+	return nil, nil
+}
+
+func (f *silentFacilitator) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
+	return nil, nil
+}
+
+func TestRegistryRouting(t *testing.T) {
+	registry := NewRegistry()
+	for _, network := range []string{"eip155:84532", "eip155:8453"} {
+		config := NetworkConfig{Network: network}
+		require.NoError(t, config.Normalize())
+		require.NoError(t, registry.Register(config, &stubFacilitator{network: network, signer: "0xfacilitator"}))
+	}
+	require.Error(t, registry.Register(NetworkConfig{Network: "eip155:8453"}, &stubFacilitator{}))
+
+	t.Run("lookup by CAIP-2 identifier and chain name", func(t *testing.T) {
+		_, config, ok := registry.Lookup("eip155:84532")
+		require.True(t, ok)
+		require.Equal(t, "eip155:84532", config.Network)
+
+		_, config, ok = registry.Lookup("base-sepolia")
+		require.True(t, ok)
+		require.Equal(t, "eip155:84532", config.Network)
+
+		_, _, ok = registry.Lookup("eip155:1")
+		require.False(t, ok)
+	})
+
+	t.Run("unknown networks are rejected", func(t *testing.T) {
+		payload := &types.PaymentPayload{Network: "eip155:1"}
+		res, err := registry.Verify(t.Context(), payload, &types.PaymentRequirements{})
+		require.NoError(t, err)
+		require.False(t, res.IsValid)
+		require.Equal(t, types.ErrInvalidNetwork.Error(), res.InvalidReason)
+
+		_, err = registry.Estimate(t.Context(), &types.PaymentPayload{Network: "eip155:8453"}, &types.PaymentRequirements{})
+		require.ErrorIs(t, err, ErrNotSupported)
+	})
+
+	t.Run("missing responses are errors", func(t *testing.T) {
+		registry := NewRegistry()
+		config := NetworkConfig{Network: "eip155:1"}
+		require.NoError(t, config.Normalize())
+		require.NoError(t, registry.Register(config, &silentFacilitator{}))
+
+		payload := &types.PaymentPayload{Network: "eip155:1"}
+		_, err := registry.Verify(t.Context(), payload, &types.PaymentRequirements{})
+		require.ErrorIs(t, err, ErrNotSupported)
+		_, err = registry.Settle(t.Context(), payload, &types.PaymentRequirements{})
+		require.ErrorIs(t, err, ErrNotSupported)
+	})
+
+	t.Run("supported kinds of all networks", func(t *testing.T) {
+		supported := registry.Supported()
+		require.Len(t, supported.Kinds, 4, "a kind per network and protocol version")
+		require.Equal(t, "eip155:84532", supported.Kinds[0].Network)
+		require.Equal(t, "eip155:84532", supported.Kinds[1].Network)
+		require.Equal(t, "eip155:8453", supported.Kinds[2].Network)
+		require.Equal(t, string(types.EVM), supported.Kinds[0].Scheme)
+		require.Equal(t, int(types.X402VersionV1), supported.Kinds[0].X402Version)
+		require.Equal(t, int(types.X402VersionV2), supported.Kinds[1].X402Version)
+		require.Equal(t, map[string]any{"network": "eip155:8453"}, supported.Kinds[3].Extra)
+		require.Equal(t, map[string][]string{"eip155:*": {"0xfacilitator"}}, supported.Signers)
+		require.Equal(t, map[string][]string{
+			"eip155:84532": {"0xfacilitator"},
+			"eip155:8453":  {"0xfacilitator"},
+		}, registry.Signers())
+	})
+}
+
+func TestRegistryFamilies(t *testing.T) {
+	registry := NewRegistry()
+	exact := NetworkConfig{Network: "eip155:8453"}
+	require.NoError(t, exact.Normalize())
+	require.NoError(t, registry.Register(exact, &stubFacilitator{network: "eip155:8453"}))
+
+	family := NetworkConfig{Network: "eip155:*", ChainRPCURLs: map[string][]string{"eip155:999": {"https://rpc.example.com"}}}
+	require.NoError(t, family.Normalize())
+	require.Equal(t, types.EVM, family.Scheme)
+	var created []NetworkConfig
+	require.NoError(t, registry.RegisterFamily(family, func(config NetworkConfig) (Facilitator, error) {
+		if config.Network == "eip155:42161" {
+			return nil, errors.New("connection refused")
+		}
+		created = append(created, config)
+		return &stubFacilitator{network: config.Network}, nil
+	}))
+	require.Error(t, registry.Register(family, &stubFacilitator{}))
+	require.Error(t, registry.RegisterFamily(exact, nil))
+	require.Error(t, registry.RegisterFamily(family, nil), "registered already")
+
+	for _, c := range []struct {
+		network, route string
+	}{
+		{"eip155:8453", "eip155:8453"}, // exact sections take precedence
+		{"base", "eip155:8453"},
+		{"eip155:999", "eip155:*"},   // endpoints of chainRpcUrls
+		{"eip155:84532", "eip155:*"}, // preset endpoints
+		{"eip155:999", "eip155:*"},
+		{"eip155:123456789", ""}, // no endpoints
+		{"eip155:42161", ""},     // the facilitator couldn't be created
+		{"solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp", ""},
+		{"eip155:*", ""},
+	} {
+		route, ok := registry.Route(c.network)
+		require.Equal(t, c.route != "", ok, c.network)
+		require.Equal(t, c.route, route, c.network)
+	}
+
+	require.Len(t, created, 2, "a facilitator per served network")
+	require.Equal(t, "eip155:999", created[0].Network)
+	require.Equal(t, []string{"https://rpc.example.com"}, created[0].RPCURLs)
+	require.True(t, created[0].LazyDial)
+	require.Empty(t, created[1].RPCURLs, "the presets are used")
+
+	var networks []string
+	for _, config := range registry.Networks() {
+		networks = append(networks, config.Network)
+	}
+	require.Equal(t, []string{"eip155:8453", "eip155:999", "eip155:84532"}, networks)
+	require.Len(t, registry.Families(), 1)
+
+	res, err := registry.Verify(t.Context(), &types.PaymentPayload{Network: "eip155:999"}, &types.PaymentRequirements{Network: "eip155:999"})
+	require.NoError(t, err)
+	require.True(t, res.IsValid, res.InvalidReason)
+
+	solana := NetworkConfig{Network: "solana:*"}
+	require.ErrorContains(t, solana.Normalize(), "only supported for evm networks")
+}
+
+func TestRegistryFiatPolicy(t *testing.T) {
+	config := NetworkConfig{Network: "eip155:8453", Policy: PaymentPolicy{MaxAmountUSD: 5}}
+	require.NoError(t, config.Normalize())
+
+	registry := NewRegistry()
+	require.Error(t, registry.Register(config, &stubFacilitator{}), "a fiat ceiling needs an oracle")
+
+	registry.SetPriceOracle(oracle.NewStatic(map[string]float64{"USDC": 1}))
+	stub := &stubFacilitator{}
+	require.NoError(t, registry.Register(config, stub))
+	payload := &types.PaymentPayload{Network: "eip155:8453"}
+
+	t.Run("payments below the ceiling pass", func(t *testing.T) {
+		req := &types.PaymentRequirements{Asset: "USDC", MaxAmountRequired: "5000000"}
+		res, err := registry.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.True(t, res.IsValid)
+
+		value, err := registry.ValueUSD(t.Context(), "base", payload, req)
+		require.NoError(t, err)
+		require.Equal(t, &PaymentValue{Symbol: "USDC", USD: 5}, value)
+	})
+
+	t.Run("payments above the ceiling are rejected", func(t *testing.T) {
+		req := &types.PaymentRequirements{Asset: "USDC", MaxAmountRequired: "5000001"}
+		res, err := registry.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, res.IsValid)
+		require.Equal(t, types.ErrAmountExceedsLimit.Error(), res.InvalidReason)
+
+		settled, err := registry.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, settled.Success)
+		require.Equal(t, types.ErrAmountExceedsLimit.Error(), settled.Error)
+	})
+
+	t.Run("the authorized value is priced rather than the required amount", func(t *testing.T) {
+		stub.amount = big.NewInt(50_000_000)
+		defer func() { stub.amount = nil }()
+		req := &types.PaymentRequirements{Asset: "USDC", MaxAmountRequired: "1000000"}
+		res, err := registry.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.False(t, res.IsValid)
+		require.Equal(t, types.ErrAmountExceedsLimit.Error(), res.InvalidReason)
+
+		value, err := registry.ValueUSD(t.Context(), "base", payload, req)
+		require.NoError(t, err)
+		require.Equal(t, &PaymentValue{Symbol: "USDC", USD: 50}, value)
+	})
+
+	t.Run("payments that can't be priced are rejected", func(t *testing.T) {
+		registry.SetPriceOracle(oracle.NewStatic(nil))
+		res, err := registry.Verify(t.Context(), payload, &types.PaymentRequirements{Asset: "USDC", MaxAmountRequired: "1"})
+		require.NoError(t, err)
+		require.False(t, res.IsValid)
+		require.Equal(t, types.ErrPriceUnavailable.Error(), res.InvalidReason)
+	})
+}
+
+type stubRecipients map[string]bool
+
+func (r stubRecipients) IsRegistered(ctx context.Context, network, address string) (bool, error) {
+	return r[network+"/"+address], nil
+}
+
+func TestRegistryRecipientPolicy(t *testing.T) {
+	config := NetworkConfig{Network: "eip155:8453", Policy: PaymentPolicy{RegisteredRecipients: true}}
+	require.NoError(t, config.Normalize())
+
+	registry := NewRegistry()
+	require.Error(t, registry.Register(config, &stubFacilitator{}), "the policy needs a recipient registry")
+
+	registry.SetRecipients(stubRecipients{"eip155:8453/0xregistered": true})
+	require.NoError(t, registry.Register(config, &stubFacilitator{}))
+	payload := &types.PaymentPayload{Network: "eip155:8453"}
+
+	res, err := registry.Verify(t.Context(), payload, &types.PaymentRequirements{PayTo: "0xregistered"})
+	require.NoError(t, err)
+	require.True(t, res.IsValid)
+
+	settled, err := registry.Settle(t.Context(), payload, &types.PaymentRequirements{PayTo: "0xother"})
+	require.NoError(t, err)
+	require.False(t, settled.Success)
+	require.Equal(t, types.ErrRecipientNotRegistered.Error(), settled.Error)
+}
+
+func TestRegistryVerifyCache(t *testing.T) {
+	config := NetworkConfig{Network: "eip155:8453"}
+	require.NoError(t, config.Normalize())
+	f := &stubFacilitator{}
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(config, f))
+
+	payload := &types.PaymentPayload{Network: "eip155:8453", Payload: json.RawMessage(`{"signature":"0x01"}`)}
+	req := &types.PaymentRequirements{PayTo: "0xrecipient", MaxAmountRequired: "1"}
+
+	t.Run("valid results are reused for the same payment", func(t *testing.T) {
+		for range 2 {
+			res, err := registry.Verify(t.Context(), payload, req)
+			require.NoError(t, err)
+			require.True(t, res.IsValid)
+		}
+		require.Equal(t, 1, f.verifies)
+
+		_, err := registry.Verify(t.Context(), payload, &types.PaymentRequirements{PayTo: "0xrecipient", MaxAmountRequired: "2"})
+		require.NoError(t, err)
+		require.Equal(t, 2, f.verifies, "other requirements are verified")
+	})
+
+	t.Run("settled payments are verified again", func(t *testing.T) {
+		f.verifies = 0
+		_, err := registry.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		_, err = registry.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		_, err = registry.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+		require.Equal(t, 1, f.verifies)
+	})
+
+	t.Run("bypass", func(t *testing.T) {
+		f.verifies = 0
+		_, err := registry.Verify(BypassVerifyCache(t.Context()), payload, req)
+		require.NoError(t, err)
+		require.Equal(t, 1, f.verifies)
+	})
+
+	t.Run("invalid results aren't reused", func(t *testing.T) {
+		f.verifies, f.invalidReason = 0, "insufficient_funds"
+		defer func() { f.invalidReason = "" }()
+		other := &types.PaymentPayload{Network: "eip155:8453", Payload: json.RawMessage(`{"signature":"0x02"}`)}
+		for range 2 {
+			res, err := registry.Verify(t.Context(), other, req)
+			require.NoError(t, err)
+			require.False(t, res.IsValid)
+		}
+		require.Equal(t, 2, f.verifies)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		cache := newVerifyCache(VerifyCacheConfig{TTL: time.Second})
+		now := time.Now()
+		cache.put("key", &types.PaymentVerifyResponse{IsValid: true}, now)
+		_, ok := cache.get("eip155:8453", "key", now.Add(500*time.Millisecond))
+		require.True(t, ok)
+		_, ok = cache.get("eip155:8453", "key", now.Add(time.Second))
+		require.False(t, ok)
+		require.Nil(t, newVerifyCache(VerifyCacheConfig{Disabled: true}))
+	})
+}
+
+func TestRegistryStats(t *testing.T) {
+	registry := NewRegistry()
+	registry.SetVerifyCache(VerifyCacheConfig{Disabled: true})
+	config := NetworkConfig{Network: "eip155:84532"}
+	require.NoError(t, config.Normalize())
+	stub := &stubFacilitator{network: config.Network}
+	require.NoError(t, registry.Register(config, stub))
+	payload := &types.PaymentPayload{Network: config.Network}
+	req := &types.PaymentRequirements{Network: config.Network, Asset: "USDC", MaxAmountRequired: "1000000"}
+
+	for range 3 {
+		_, err := registry.Verify(t.Context(), payload, req)
+		require.NoError(t, err)
+	}
+	stub.invalidReason = types.ErrInsufficientBalance.Error()
+	_, err := registry.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	_, err = registry.Settle(t.Context(), payload, req)
+	require.NoError(t, err)
+	_, err = registry.Estimate(t.Context(), payload, req)
+	require.ErrorIs(t, err, ErrNotSupported)
+	_, err = registry.Verify(t.Context(), &types.PaymentPayload{Network: "eip155:1"}, req)
+	require.NoError(t, err)
+
+	stats := registry.Stats()
+	require.Equal(t, int64(5), stats.Verify.Calls, "unknown networks are counted in the totals")
+	require.Equal(t, int64(2), stats.Verify.Rejected)
+	require.Equal(t, types.ErrInvalidNetwork.Error(), stats.Verify.LastError)
+	require.Equal(t, 5, stats.Verify.Latency.Samples)
+	require.LessOrEqual(t, stats.Verify.Latency.P50, stats.Verify.Latency.Max)
+	require.NotContains(t, stats.Networks, "eip155:1")
+
+	network := stats.Networks[config.Network]
+	require.Equal(t, int64(4), network.Verify.Calls)
+	require.Equal(t, int64(1), network.Verify.Rejected)
+	require.Equal(t, types.ErrInsufficientBalance.Error(), network.Verify.LastError)
+	require.False(t, network.Verify.LastErrorAt.IsZero())
+	require.Equal(t, OperationStats{Calls: 1, Latency: network.Settle.Latency}, network.Settle)
+	require.Equal(t, int64(1), network.Estimate.Errors)
+	require.Equal(t, ErrNotSupported.Error(), network.Estimate.LastError)
+
+	t.Run("latencies of the last calls", func(t *testing.T) {
+		var o operationStats
+		for i := range statsWindow + 100 {
+			o.add(time.Duration(i), time.Now(), "", false)
+		}
+		snapshot := o.snapshot()
+		require.Equal(t, int64(statsWindow+100), snapshot.Calls)
+		require.Equal(t, statsWindow, snapshot.Latency.Samples)
+		require.Equal(t, time.Duration(statsWindow+99), snapshot.Latency.Max)
+		require.Equal(t, time.Duration(100+511), snapshot.Latency.P50)
+	})
+}
+
+// stubScreener blocks the addresses it lists and fails for the others it lists.
+type stubScreener map[string]error
+
+func (s stubScreener) Screen(ctx context.Context, network, address string) (screening.Result, error) {
+	err, listed := s[address]
+	if !listed {
+		return screening.Result{}, nil
+	}
+	if err != nil {
+		return screening.Result{}, err
+	}
+	return screening.Result{Blocked: true, Reason: "OFAC SDN"}, nil
+}
+
+func TestRegistryScreening(t *testing.T) {
+	registry := NewRegistry()
+	config := NetworkConfig{Network: "eip155:8453"}
+	require.NoError(t, config.Normalize())
+	stub := &stubFacilitator{network: "eip155:8453", payer: "0xpayer"}
+	require.NoError(t, registry.Register(config, stub))
+	registry.SetVerifyCache(VerifyCacheConfig{Disabled: true})
+	payload := &types.PaymentPayload{Network: "eip155:8453"}
+
+	verify := func(t *testing.T, payTo string) string {
+		t.Helper()
+		res, err := registry.Verify(t.Context(), payload, &types.PaymentRequirements{PayTo: payTo})
+		require.NoError(t, err)
+		return res.InvalidReason
+	}
+	settle := func(t *testing.T, payTo string) string {
+		t.Helper()
+		res, err := registry.Settle(t.Context(), payload, &types.PaymentRequirements{PayTo: payTo})
+		require.NoError(t, err)
+		return res.Error
+	}
+
+	require.Empty(t, verify(t, "0xsanctioned"), "addresses aren't screened without a screener")
+
+	registry.SetScreener(stubScreener{"0xsanctioned": nil, "0xunknown": errors.New("status 503")})
+	require.Empty(t, verify(t, "0xmerchant"))
+	require.Empty(t, settle(t, "0xmerchant"))
+	require.Equal(t, types.ErrComplianceRejected.Error(), verify(t, "0xsanctioned"))
+	require.Equal(t, types.ErrComplianceRejected.Error(), settle(t, "0xsanctioned"))
+	require.Equal(t, types.ErrScreeningUnavailable.Error(), verify(t, "0xunknown"), "payments that can't be screened are rejected")
+
+	stub.payer = "0xsanctioned"
+	require.Equal(t, types.ErrComplianceRejected.Error(), verify(t, "0xmerchant"))
+}
+
+// estimatingFacilitator estimates settlements at a fixed cost.
+type estimatingFacilitator struct {
+	stubFacilitator
+	estimate types.PaymentEstimateResponse
+}
+
+func (f *estimatingFacilitator) Estimate(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentEstimateResponse, error) {
+	estimate := f.estimate
+	return &estimate, nil
+}
+
+func TestRegistryGasCeiling(t *testing.T) {
+	stub := &estimatingFacilitator{
+		stubFacilitator: stubFacilitator{network: "eip155:8453"},
+		estimate:        types.PaymentEstimateResponse{Success: true, GasCost: "200000000000000", GasCostNative: "0.0002", NativeCurrency: "ETH"},
+	}
+	payload := &types.PaymentPayload{Network: "eip155:8453"}
+	req := &types.PaymentRequirements{Asset: "USDC"}
+
+	settle := func(t *testing.T, registry *Registry) string {
+		t.Helper()
+		res, err := registry.Settle(t.Context(), payload, req)
+		require.NoError(t, err)
+		return res.Error
+	}
+
+	t.Run("wei", func(t *testing.T) {
+		for ceiling, reason := range map[uint64]string{
+			100_000_000_000_000: types.ErrGasTooExpensive.Error(),
+			200_000_000_000_000: "",
+		} {
+			config := NetworkConfig{Network: "eip155:8453", Gas: GasPolicy{MaxGasCostWei: ceiling}}
+			require.NoError(t, config.Normalize())
+			registry := NewRegistry()
+			require.NoError(t, registry.Register(config, stub))
+			require.Equal(t, reason, settle(t, registry), ceiling)
+
+			estimate, err := registry.Estimate(t.Context(), payload, req)
+			require.NoError(t, err)
+			require.Equal(t, reason == "", estimate.Success)
+			require.Equal(t, reason, estimate.Error)
+		}
+	})
+
+	t.Run("usd", func(t *testing.T) {
+		config := NetworkConfig{Network: "eip155:8453", Gas: GasPolicy{MaxGasCostUSD: 0.5}}
+		require.NoError(t, config.Normalize())
+		registry := NewRegistry()
+		require.Error(t, registry.Register(config, stub), "a USD ceiling needs an oracle")
+
+		registry.SetPriceOracle(oracle.NewStatic(map[string]float64{"ETH": 2000}))
+		require.NoError(t, registry.Register(config, stub))
+		require.Empty(t, settle(t, registry), "0.0002 ETH is 0.40 USD")
+
+		registry.SetPriceOracle(oracle.NewStatic(map[string]float64{"ETH": 3000}))
+		require.Equal(t, types.ErrGasTooExpensive.Error(), settle(t, registry))
+
+		registry.SetPriceOracle(oracle.NewStatic(nil))
+		require.Equal(t, types.ErrPriceUnavailable.Error(), settle(t, registry))
+	})
+}
@@ -0,0 +1,54 @@
+package facilitator
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+// rpcClientOptions makes RPC clients of the network dial their HTTP and
+// websocket connections with the outbound transport, through a dialer counting
+// the open ones in metrics.RPCConnections.
+func rpcClientOptions(network string) []rpc.ClientOption {
+	dialer := outbound.Dialer()
+	gauge := metrics.RPCConnections.WithLabelValues(network)
+	dial := func(ctx context.Context, netw, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, netw, addr)
+		if err != nil {
+			return nil, err
+		}
+		gauge.Inc()
+		return &countedConn{Conn: conn, gauge: gauge}, nil
+	}
+
+	transport := outbound.Transport()
+	transport.DialContext = dial
+	ws := outbound.WebsocketDialer()
+	ws.NetDial = func(netw, addr string) (net.Conn, error) {
+		return dial(context.Background(), netw, addr)
+	}
+	ws.HandshakeTimeout = dialTimeout
+	return []rpc.ClientOption{
+		rpc.WithHTTPClient(&http.Client{Transport: transport}),
+		rpc.WithWebsocketDialer(ws),
+	}
+}
+
+// countedConn takes itself off the gauge of open connections when closed.
+type countedConn struct {
+	net.Conn
+	gauge prometheus.Gauge
+	once  sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(c.gauge.Dec)
+	return c.Conn.Close()
+}
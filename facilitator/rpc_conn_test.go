@@ -0,0 +1,38 @@
+package facilitator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+func TestRPCConnections(t *testing.T) {
+	const network = "eip155:31337"
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x7a69"}`))
+	}))
+	defer node.Close()
+
+	client, err := rpc.DialOptions(t.Context(), node.URL, rpcClientOptions(network)...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	gauge := metrics.RPCConnections.WithLabelValues(network)
+	var chainID string
+	require.NoError(t, client.CallContext(t.Context(), &chainID, "eth_chainId"))
+	require.Equal(t, "0x7a69", chainID)
+	require.Equal(t, 1.0, metrics.GaugeValue(gauge), "the connection is kept alive")
+
+	// connections closed by the server are taken off the gauge once the client notices
+	node.CloseClientConnections()
+	require.Eventually(t, func() bool {
+		return metrics.GaugeValue(gauge) == 0
+	}, time.Second, 10*time.Millisecond)
+}
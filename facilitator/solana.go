@@ -6,19 +6,36 @@ import (
 	"fmt"
 
 	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
 	solTypes "github.com/blocto/solana-go-sdk/types"
 
+	"github.com/gosuda/x402-facilitator/internal/outbound"
 	"github.com/gosuda/x402-facilitator/types"
 )
 
 type SolanaFacilitator struct {
 	scheme   types.Scheme
+	network  string
 	client   *client.Client
 	feePayer solTypes.Account
+	assets   []types.SupportedAsset // configured tokens, in configuration order
+
+	// creates missing token accounts of recipients in preflight, not configurable
+	// until Solana payments are settled
+	createTokenAccounts bool
+	// tables large settlements are compiled against, not configurable until
+	// Solana payments are settled
+	addressLookupTables []string
+	// attaches the request memo in buildTransaction, not configurable until
+	// Solana payments are settled
+	requestMemo bool
 }
 
-func NewSolanaFacilitator(network string, url string, privateKeyHex string) (*SolanaFacilitator, error) {
-	client := client.NewClient(url)
+func NewSolanaFacilitator(config NetworkConfig, privateKeyHex string) (*SolanaFacilitator, error) {
+	if len(config.RPCURLs) == 0 {
+		return nil, fmt.Errorf("network %s: rpc url must be provided", config.Network)
+	}
+	client := client.New(rpc.WithEndpoint(config.RPCURLs[0]), rpc.WithHTTPClient(outbound.Client(0)))
 
 	privKey, err := hex.DecodeString(privateKeyHex)
 	if err != nil {
@@ -30,26 +47,45 @@ func NewSolanaFacilitator(network string, url string, privateKeyHex string) (*So
 		return nil, fmt.Errorf("invalid private key format: %w", err)
 	}
 
+	assets := make([]types.SupportedAsset, 0, len(config.Assets))
+	for _, asset := range config.Assets {
+		assets = append(assets, types.SupportedAsset{
+			Address:        asset.Address,
+			Symbol:         asset.Symbol,
+			Decimals:       asset.Decimals,
+			TransferMethod: types.TransferMethodTransaction,
+		})
+	}
+
 	return &SolanaFacilitator{
 		scheme:   types.Solana,
+		network:  config.Network,
 		client:   client,
 		feePayer: feePayer,
+		assets:   assets,
 	}, nil
 }
 
 func (t *SolanaFacilitator) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
-	return nil, nil
+	return nil, ErrNotSupported
 }
 
 func (t *SolanaFacilitator) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
-	return nil, nil
+	return nil, ErrNotSupported
 }
 
-func (t *SolanaFacilitator) Supported() []*types.SupportedKind {
-	return []*types.SupportedKind{
-		{
-			Scheme:  string(types.Solana),
-			Network: string(types.Solana),
-		},
+// GetExtra advertises the fee payer, which clients must set on the payment
+// transaction, and the configured tokens.
+func (t *SolanaFacilitator) GetExtra() map[string]any {
+	extra := map[string]any{
+		"feePayer": t.feePayer.PublicKey.ToBase58(),
 	}
+	if len(t.assets) > 0 {
+		extra["assets"] = t.assets
+	}
+	return extra
+}
+
+func (t *SolanaFacilitator) GetSigners() []string {
+	return []string{t.feePayer.PublicKey.ToBase58()}
 }
@@ -0,0 +1,62 @@
+package facilitator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/associated_token_account"
+	"github.com/blocto/solana-go-sdk/program/token"
+	solTypes "github.com/blocto/solana-go-sdk/types"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// solanaSignatureFee is the base fee in lamports of each signature of a transaction
+const solanaSignatureFee = 5000
+
+// preflight checks that a settlement transferring mint to payTo with the given
+// number of signatures can land. The token account of the recipient must exist
+// unless the network creates missing accounts, in which case the returned
+// instructions create it and the fee payer must also cover its rent. The
+// instructions go before the transfer; Solana payments aren't settled yet, so
+// nothing calls it outside of tests.
+func (t *SolanaFacilitator) preflight(ctx context.Context, payTo, mint string, signatures int) ([]solTypes.Instruction, error) {
+	owner, tokenMint := common.PublicKeyFromString(payTo), common.PublicKeyFromString(mint)
+	ata, _, err := common.FindAssociatedTokenAddress(owner, tokenMint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token account of %s: %w", payTo, err)
+	}
+	account, err := t.client.GetAccountInfo(ctx, ata.ToBase58())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token account %s: %w", ata.ToBase58(), err)
+	}
+
+	var instructions []solTypes.Instruction
+	need := uint64(signatures) * solanaSignatureFee
+	if account.Owner == (common.PublicKey{}) {
+		if !t.createTokenAccounts {
+			return nil, fmt.Errorf("%w: %s has no token account for %s", types.ErrRecipientAccountMissing, payTo, mint)
+		}
+		rent, err := t.client.GetMinimumBalanceForRentExemption(ctx, token.TokenAccountSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rent exemption: %w", err)
+		}
+		need += rent
+		instructions = append(instructions, associated_token_account.CreateIdempotent(associated_token_account.CreateIdempotentParam{
+			Funder:                 t.feePayer.PublicKey,
+			Owner:                  owner,
+			Mint:                   tokenMint,
+			AssociatedTokenAccount: ata,
+		}))
+	}
+
+	balance, err := t.client.GetBalance(ctx, t.feePayer.PublicKey.ToBase58())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee payer balance: %w", err)
+	}
+	if balance < need {
+		return nil, fmt.Errorf("%w: fee payer holds %d lamports, %d needed", types.ErrFeePayerInsufficientFunds, balance, need)
+	}
+	return instructions, nil
+}
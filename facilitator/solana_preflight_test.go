@@ -0,0 +1,92 @@
+package facilitator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	solTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestSolanaPreflight(t *testing.T) {
+	const (
+		payTo = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+		mint  = "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU"
+		rent  = 2039280
+	)
+	var balance uint64
+	var accountExists bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     uint64 `json:"id"`
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		var result any
+		switch req.Method {
+		case "getAccountInfo":
+			var value any
+			if accountExists {
+				value = map[string]any{
+					"data":       []string{"", "base64"},
+					"lamports":   rent,
+					"owner":      "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA",
+					"executable": false,
+					"rentEpoch":  0,
+				}
+			}
+			result = map[string]any{"context": map[string]any{"slot": 1}, "value": value}
+		case "getMinimumBalanceForRentExemption":
+			result = rent
+		case "getBalance":
+			result = map[string]any{"context": map[string]any{"slot": 1}, "value": balance}
+		default:
+			t.Fatalf("unexpected method %s", req.Method)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer server.Close()
+
+	feePayer := solTypes.NewAccount()
+	newFacilitator := func(createTokenAccounts bool) *SolanaFacilitator {
+		f, err := NewSolanaFacilitator(NetworkConfig{
+			Network: "solana:devnet",
+			RPCURLs: []string{server.URL},
+		}, hex.EncodeToString(feePayer.PrivateKey))
+		require.NoError(t, err)
+		f.createTokenAccounts = createTokenAccounts
+		return f
+	}
+
+	tests := []struct {
+		name          string
+		create        bool
+		accountExists bool
+		balance       uint64
+		instructions  int
+		err           error
+	}{
+		{"existing account", false, true, 10000, 0, nil},
+		{"fee not covered", false, true, 9999, 0, types.ErrFeePayerInsufficientFunds},
+		{"missing account", false, false, 1e9, 0, types.ErrRecipientAccountMissing},
+		{"missing account created", true, false, 10000 + rent, 1, nil},
+		{"rent not covered", true, false, 10000 + rent - 1, 0, types.ErrFeePayerInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accountExists, balance = tt.accountExists, tt.balance
+			instructions, err := newFacilitator(tt.create).preflight(t.Context(), payTo, mint, 2)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, instructions, tt.instructions)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package facilitator
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/address_lookup_table"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	solTypes "github.com/blocto/solana-go-sdk/types"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+)
+
+// solanaPacketSize is the largest serialized transaction the network accepts
+const solanaPacketSize = 1232
+
+// buildTransaction compiles the instructions into a transaction paid by the fee
+// payer and signed by it and the signers. A legacy transaction is built if it
+// fits into a packet, otherwise a v0 transaction that resolves accounts through
+// the address lookup tables of the facilitator. With requestMemo set, a memo
+// instruction carries the request ID of the context. Solana payments aren't
+// settled yet, so nothing calls it outside of tests.
+func (t *SolanaFacilitator) buildTransaction(ctx context.Context, instructions []solTypes.Instruction, recentBlockhash string, signers ...solTypes.Account) (solTypes.Transaction, error) {
+	if memoData := requestMemo(ctx); t.requestMemo && memoData != nil {
+		instructions = append(instructions[:len(instructions):len(instructions)], memo.BuildMemo(memo.BuildMemoParam{Memo: memoData}))
+	}
+	param := solTypes.NewMessageParam{
+		FeePayer:        t.feePayer.PublicKey,
+		Instructions:    instructions,
+		RecentBlockhash: recentBlockhash,
+	}
+	signers = append([]solTypes.Account{t.feePayer}, signers...)
+
+	tx, size, err := compileTransaction(param, signers)
+	if err != nil || size <= solanaPacketSize {
+		return tx, err
+	}
+	if len(t.addressLookupTables) == 0 {
+		return solTypes.Transaction{}, fmt.Errorf("transaction of %d bytes exceeds the packet size of %d and there are no address lookup tables", size, solanaPacketSize)
+	}
+
+	param.AddressLookupTableAccounts, err = t.lookupTables(ctx)
+	if err != nil {
+		return solTypes.Transaction{}, err
+	}
+	legacySize := size
+	tx, size, err = compileTransaction(param, signers)
+	if err != nil {
+		return solTypes.Transaction{}, err
+	}
+	if size > solanaPacketSize {
+		return solTypes.Transaction{}, fmt.Errorf("transaction of %d bytes exceeds the packet size of %d with address lookup tables", size, solanaPacketSize)
+	}
+	logging.Ctx(ctx, logging.RPC).Debug().Str("network", t.network).Int("legacySize", legacySize).Int("size", size).Msg("Built versioned transaction")
+	return tx, nil
+}
+
+// compileTransaction signs the message of param and returns the transaction and its serialized size.
+func compileTransaction(param solTypes.NewMessageParam, signers []solTypes.Account) (solTypes.Transaction, int, error) {
+	tx, err := solTypes.NewTransaction(solTypes.NewTransactionParam{
+		Message: solTypes.NewMessage(param),
+		Signers: signers,
+	})
+	if err != nil {
+		return solTypes.Transaction{}, 0, fmt.Errorf("failed to build transaction: %w", err)
+	}
+	raw, err := tx.Serialize()
+	if err != nil {
+		return solTypes.Transaction{}, 0, fmt.Errorf("failed to serialize transaction: %w", err)
+	}
+	return tx, len(raw), nil
+}
+
+// lookupTables loads the active configured address lookup tables.
+func (t *SolanaFacilitator) lookupTables(ctx context.Context) ([]solTypes.AddressLookupTableAccount, error) {
+	tables := make([]solTypes.AddressLookupTableAccount, 0, len(t.addressLookupTables))
+	for _, address := range t.addressLookupTables {
+		account, err := t.client.GetAccountInfo(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get address lookup table %s: %w", address, err)
+		}
+		table, err := address_lookup_table.DeserializeLookupTable(account.Data, account.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address lookup table %s: %w", address, err)
+		}
+		if table.ProgramState != address_lookup_table.ProgramStateLookupTable || table.DeactivationSlot != math.MaxUint64 {
+			logging.Ctx(ctx, logging.RPC).Warn().Str("network", t.network).Str("table", address).Msg("Skipping inactive address lookup table")
+			continue
+		}
+		// the addresses follow the fixed size metadata, whether the table has an authority or not
+		data := account.Data[address_lookup_table.LOOKUP_TABLE_META_SIZE:]
+		addresses := make([]common.PublicKey, 0, len(data)/32)
+		for i := 0; i+32 <= len(data); i += 32 {
+			addresses = append(addresses, common.PublicKeyFromBytes(data[i:i+32]))
+		}
+		tables = append(tables, solTypes.AddressLookupTableAccount{
+			Key:       common.PublicKeyFromString(address),
+			Addresses: addresses,
+		})
+	}
+	return tables, nil
+}
@@ -0,0 +1,127 @@
+package facilitator
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	solTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/paymentctx"
+)
+
+// lookupTableData serializes an address lookup table holding the addresses.
+func lookupTableData(deactivationSlot uint64, addresses []common.PublicKey) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 1)
+	data = binary.LittleEndian.AppendUint64(data, deactivationSlot)
+	data = binary.LittleEndian.AppendUint64(data, 0)
+	// last extended slot start index and no authority, padded to the metadata size
+	data = append(data, make([]byte, 56-len(data))...)
+	for _, address := range addresses {
+		data = append(data, address.Bytes()...)
+	}
+	return data
+}
+
+func TestSolanaBuildTransaction(t *testing.T) {
+	const blockhash = "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N"
+	table := solTypes.NewAccount().PublicKey
+	var recipients []common.PublicKey
+	for range 30 {
+		recipients = append(recipients, solTypes.NewAccount().PublicKey)
+	}
+	var deactivationSlot uint64 = math.MaxUint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     uint64 `json:"id"`
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "getAccountInfo", req.Method)
+		require.Equal(t, table.ToBase58(), req.Params[0])
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{
+			"context": map[string]any{"slot": 1},
+			"value": map[string]any{
+				"data":       []string{base64.StdEncoding.EncodeToString(lookupTableData(deactivationSlot, recipients)), "base64"},
+				"lamports":   1,
+				"owner":      common.AddressLookupTableProgramID.ToBase58(),
+				"executable": false,
+				"rentEpoch":  0,
+			},
+		}})
+	}))
+	defer server.Close()
+
+	feePayer := solTypes.NewAccount()
+	newFacilitator := func(tables ...string) *SolanaFacilitator {
+		f, err := NewSolanaFacilitator(NetworkConfig{
+			Network: "solana:devnet",
+			RPCURLs: []string{server.URL},
+		}, hex.EncodeToString(feePayer.PrivateKey))
+		require.NoError(t, err)
+		f.addressLookupTables, f.requestMemo = tables, true
+		return f
+	}
+	transfers := func(n int) []solTypes.Instruction {
+		var instructions []solTypes.Instruction
+		for _, to := range recipients[:n] {
+			instructions = append(instructions, system.Transfer(system.TransferParam{From: feePayer.PublicKey, To: to, Amount: 1}))
+		}
+		return instructions
+	}
+
+	t.Run("small transactions are legacy", func(t *testing.T) {
+		tx, err := newFacilitator(table.ToBase58()).buildTransaction(t.Context(), transfers(2), blockhash)
+		require.NoError(t, err)
+		require.Equal(t, solTypes.MessageVersion(solTypes.MessageVersionLegacy), tx.Message.Version)
+		require.Empty(t, tx.Message.AddressLookupTables)
+	})
+
+	t.Run("large transactions use lookup tables", func(t *testing.T) {
+		tx, err := newFacilitator(table.ToBase58()).buildTransaction(t.Context(), transfers(30), blockhash)
+		require.NoError(t, err)
+		require.Equal(t, solTypes.MessageVersion(solTypes.MessageVersionV0), tx.Message.Version)
+		require.Len(t, tx.Message.AddressLookupTables, 1)
+		require.Equal(t, table, tx.Message.AddressLookupTables[0].AccountKey)
+		require.Len(t, tx.Message.AddressLookupTables[0].WritableIndexes, 30)
+
+		raw, err := tx.Serialize()
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(raw), solanaPacketSize)
+		decoded, err := solTypes.TransactionDeserialize(raw)
+		require.NoError(t, err)
+		require.Equal(t, tx.Message.Instructions, decoded.Message.Instructions, "the message survives the round trip")
+		require.Equal(t, tx.Message.AddressLookupTables[0].WritableIndexes, decoded.Message.AddressLookupTables[0].WritableIndexes)
+	})
+
+	t.Run("request memo", func(t *testing.T) {
+		ctx := paymentctx.With(t.Context(), paymentctx.Metadata{RequestID: "6e81_I2ixXbb6"})
+		tx, err := newFacilitator().buildTransaction(ctx, transfers(2), blockhash)
+		require.NoError(t, err)
+		require.Len(t, tx.Message.Instructions, 3)
+		last := tx.Message.Instructions[2]
+		require.Equal(t, common.MemoProgramID, tx.Message.Accounts[last.ProgramIDIndex])
+		require.Equal(t, []byte("x402:6e81_I2ixXbb6"), last.Data)
+	})
+
+	t.Run("large transactions need lookup tables", func(t *testing.T) {
+		_, err := newFacilitator().buildTransaction(t.Context(), transfers(30), blockhash)
+		require.ErrorContains(t, err, "there are no address lookup tables")
+	})
+
+	t.Run("deactivated tables are skipped", func(t *testing.T) {
+		deactivationSlot = 100
+		defer func() { deactivationSlot = math.MaxUint64 }()
+		_, err := newFacilitator(table.ToBase58()).buildTransaction(t.Context(), transfers(30), blockhash)
+		require.ErrorContains(t, err, "with address lookup tables")
+	})
+}
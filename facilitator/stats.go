@@ -0,0 +1,204 @@
+package facilitator
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// statsWindow is the number of recent calls latencies are computed over
+const statsWindow = 1024
+
+// Stats is a snapshot of the verifications, settlements and estimates a
+// registry handled since it was created. Programs embedding the facilitator
+// surface its health with it in their own dashboards, without Prometheus.
+type Stats struct {
+	// When the registry was created
+	Since time.Time
+	// Calls of all networks, including those of networks that aren't served
+	Verify   OperationStats
+	Settle   OperationStats
+	Estimate OperationStats
+	// Calls by CAIP-2 identifier of the served networks
+	Networks map[string]NetworkStats
+}
+
+// NetworkStats counts the calls of a network.
+type NetworkStats struct {
+	Verify   OperationStats
+	Settle   OperationStats
+	Estimate OperationStats
+}
+
+// OperationStats counts the calls of an operation.
+type OperationStats struct {
+	Calls int64
+	// Calls rejecting the payment: invalid verifications, failed settlements and estimates
+	Rejected int64
+	// Calls returning an error
+	Errors int64
+	// Latency of the most recent calls
+	Latency LatencyStats
+	// Error or rejection reason of the last call that failed, empty if none did
+	LastError string
+	// When the last call failed
+	LastErrorAt time.Time
+}
+
+// LatencyStats are percentiles of the latency of recent calls.
+type LatencyStats struct {
+	// Calls the percentiles are computed over, up to the last 1024
+	Samples int
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+type operation int
+
+const (
+	opVerify operation = iota
+	opSettle
+	opEstimate
+	operations
+)
+
+// registryStats records the calls of a registry
+type registryStats struct {
+	since time.Time
+
+	mu       sync.Mutex
+	all      [operations]operationStats
+	networks map[string]*[operations]operationStats
+}
+
+type operationStats struct {
+	calls       int64
+	rejected    int64
+	errors      int64
+	lastError   string
+	lastErrorAt time.Time
+	// the latencies of the last calls, latencies[calls%statsWindow] is the oldest
+	latencies [statsWindow]time.Duration
+}
+
+func newRegistryStats() *registryStats {
+	return &registryStats{
+		since:    time.Now(),
+		networks: make(map[string]*[operations]operationStats),
+	}
+}
+
+// record counts a call of the operation that started at start. network is
+// empty for calls of networks that aren't served, reason the reason the
+// payment was rejected for.
+func (s *registryStats) record(op operation, network string, start time.Time, reason string, err error) {
+	now := time.Now()
+	latency := now.Sub(start)
+	if err != nil {
+		reason = err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.all[op].add(latency, now, reason, err != nil)
+	if network == "" {
+		return
+	}
+	stats, ok := s.networks[network]
+	if !ok {
+		stats = new([operations]operationStats)
+		s.networks[network] = stats
+	}
+	stats[op].add(latency, now, reason, err != nil)
+}
+
+func (o *operationStats) add(latency time.Duration, now time.Time, reason string, failed bool) {
+	o.latencies[o.calls%statsWindow] = latency
+	o.calls++
+	switch {
+	case failed:
+		o.errors++
+	case reason != "":
+		o.rejected++
+	}
+	if reason != "" {
+		o.lastError, o.lastErrorAt = reason, now
+	}
+}
+
+func (s *registryStats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{
+		Since:    s.since,
+		Verify:   s.all[opVerify].snapshot(),
+		Settle:   s.all[opSettle].snapshot(),
+		Estimate: s.all[opEstimate].snapshot(),
+		Networks: make(map[string]NetworkStats, len(s.networks)),
+	}
+	for network, ops := range s.networks {
+		stats.Networks[network] = NetworkStats{
+			Verify:   ops[opVerify].snapshot(),
+			Settle:   ops[opSettle].snapshot(),
+			Estimate: ops[opEstimate].snapshot(),
+		}
+	}
+	return stats
+}
+
+func (o *operationStats) snapshot() OperationStats {
+	samples := slices.Clone(o.latencies[:min(o.calls, statsWindow)])
+	slices.Sort(samples)
+	percentile := func(p int) time.Duration {
+		if len(samples) == 0 {
+			return 0
+		}
+		return samples[(len(samples)-1)*p/100]
+	}
+	return OperationStats{
+		Calls:    o.calls,
+		Rejected: o.rejected,
+		Errors:   o.errors,
+		Latency: LatencyStats{
+			Samples: len(samples),
+			P50:     percentile(50),
+			P90:     percentile(90),
+			P99:     percentile(99),
+			Max:     percentile(100),
+		},
+		LastError:   o.lastError,
+		LastErrorAt: o.lastErrorAt,
+	}
+}
+
+// Stats returns a snapshot of the calls the registry handled.
+func (r *Registry) Stats() Stats {
+	return r.stats.snapshot()
+}
+
+// verifyRejection returns the reason the verification rejected the payment, empty if it didn't.
+func verifyRejection(resp *types.PaymentVerifyResponse) string {
+	if resp == nil || resp.IsValid {
+		return ""
+	}
+	return resp.InvalidReason
+}
+
+// settleRejection returns the reason the settlement failed, empty if it succeeded.
+func settleRejection(resp *types.PaymentSettleResponse) string {
+	if resp == nil || resp.Success {
+		return ""
+	}
+	return resp.Error
+}
+
+// estimateRejection returns the reason the estimate failed, empty if it succeeded.
+func estimateRejection(resp *types.PaymentEstimateResponse) string {
+	if resp == nil || resp.Success {
+		return ""
+	}
+	return resp.Error
+}
@@ -7,25 +7,27 @@ import (
 )
 
 type SuiFacilitator struct {
+	network string
 }
 
-func NewSuiFacilitator(network string, url string, privateKeyHex string) (*SuiFacilitator, error) {
-	return &SuiFacilitator{}, nil
+func NewSuiFacilitator(config NetworkConfig, privateKeyHex string) (*SuiFacilitator, error) {
+	return &SuiFacilitator{
+		network: config.Network,
+	}, nil
 }
 
 func (t *SuiFacilitator) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
-	return nil, nil
+	return nil, ErrNotSupported
 }
 
 func (t *SuiFacilitator) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
-	return nil, nil
+	return nil, ErrNotSupported
 }
 
-func (t *SuiFacilitator) Supported() []*types.SupportedKind {
-	return []*types.SupportedKind{
-		{
-			Scheme:  string(types.Sui),
-			Network: string(types.Sui),
-		},
-	}
+func (t *SuiFacilitator) GetExtra() map[string]any {
+	return nil
+}
+
+func (t *SuiFacilitator) GetSigners() []string {
+	return nil
 }
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+import {SignatureChecker} from "./SignatureChecker.sol";
+
+/// @notice Smart contract wallet accepting EIP-1271 signatures of its owner.
+contract SmartWallet {
+    address public immutable owner;
+
+    constructor(address owner_) {
+        owner = owner_;
+    }
+
+    function isValidSignature(bytes32 hash, bytes memory signature) external view returns (bytes4) {
+        if (SignatureChecker.recover(hash, signature) == owner) {
+            return this.isValidSignature.selector;
+        }
+        return 0xffffffff;
+    }
+}
+
+/// @notice CREATE2 factory of SmartWallet, used as ERC-6492 deployment target.
+contract SmartWalletFactory {
+    function deploy(address owner, bytes32 salt) external returns (address) {
+        address wallet = walletAddress(owner, salt);
+        if (wallet.code.length > 0) {
+            return wallet;
+        }
+        return address(new SmartWallet{salt: salt}(owner));
+    }
+
+    function walletAddress(address owner, bytes32 salt) public view returns (address) {
+        bytes32 initCodeHash = keccak256(abi.encodePacked(type(SmartWallet).creationCode, abi.encode(owner)));
+        return address(uint160(uint256(keccak256(abi.encodePacked(bytes1(0xff), address(this), salt, initCodeHash)))));
+    }
+}
@@ -2,30 +2,101 @@ package facilitator
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
 
 	"github.com/gosuda/x402-facilitator/types"
 )
 
 type TronFacilitator struct {
+	network string
+
+	// signer is the base58 address of the settlement key, empty without a key
+	signer string
+	// resources estimates settlement resources, nil without an RPC URL
+	resources *tronResources
+}
+
+func NewTronFacilitator(config NetworkConfig, privateKeyHex string) (*TronFacilitator, error) {
+	t := &TronFacilitator{
+		network: config.Network,
+	}
+	if privateKeyHex != "" {
+		privateKey, err := hex.DecodeString(strings.TrimPrefix(privateKeyHex, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex private key: %w", err)
+		}
+		key, err := crypto.ToECDSA(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key format: %w", err)
+		}
+		t.signer = TronAddress(crypto.PubkeyToAddress(key.PublicKey))
+	}
+	if len(config.RPCURLs) > 0 {
+		t.resources = newTronResources(config.Network, config.RPCURLs[0])
+	}
+	return t, nil
 }
 
-func NewTronFacilitator(network string, url string, privateKeyHex string) (*TronFacilitator, error) {
-	return &TronFacilitator{}, nil
+// SetResources sets the fee limit and energy margin of the transfers planned
+// by PlanTransfer, and the endpoint energy is rented from. The defaults apply
+// until it is called.
+func (t *TronFacilitator) SetResources(config TronConfig) {
+	if t.resources != nil {
+		t.resources.configure(config)
+	}
+}
+
+// SetEnergyRenter sets where energy the signer lacks for a transfer is rented
+// from, replacing the rental endpoint of SetResources.
+func (t *TronFacilitator) SetEnergyRenter(renter EnergyRenter) {
+	if t.resources != nil {
+		t.resources.renter = renter
+	}
+}
+
+// SetRequestMemo sets whether the plans of PlanTransfer carry the memo of the
+// API request of the context, for the transaction data of the transfer.
+func (t *TronFacilitator) SetRequestMemo(enabled bool) {
+	if t.resources != nil {
+		t.resources.memo = enabled
+	}
+}
+
+// PlanTransfer estimates the energy and bandwidth a TRC-20 transfer of amount
+// of token from the signer to to consumes, ahead of settling it. Energy the
+// signer lacks is rented first if a renter is set. With SetRequestMemo, the
+// plan carries the memo of the request of the context. It fails if the transfer
+// would burn more TRX than the fee limit or the signer holds.
+//
+// Tron payments aren't settled yet, the facilitator itself never calls it. It
+// is a hook for programs embedding the facilitator that send transfers.
+func (t *TronFacilitator) PlanTransfer(ctx context.Context, token, to string, amount *big.Int) (*TronResourcePlan, error) {
+	if t.resources == nil || t.signer == "" {
+		return nil, fmt.Errorf("network %s: resource estimation needs an rpc url and a signer", t.network)
+	}
+	return t.resources.plan(ctx, t.signer, token, to, amount)
 }
 
 func (t *TronFacilitator) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
-	return nil, nil
+	return nil, ErrNotSupported
 }
 
 func (t *TronFacilitator) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
-	return nil, nil
+	return nil, ErrNotSupported
+}
+
+func (t *TronFacilitator) GetExtra() map[string]any {
+	return nil
 }
 
-func (t *TronFacilitator) Supported() []*types.SupportedKind {
-	return []*types.SupportedKind{
-		{
-			Scheme:  string(types.Tron),
-			Network: string(types.Tron),
-		},
+func (t *TronFacilitator) GetSigners() []string {
+	if t.signer == "" {
+		return nil
 	}
+	return []string{t.signer}
 }
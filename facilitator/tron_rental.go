@@ -0,0 +1,64 @@
+package facilitator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+)
+
+// energyRentalTimeout bounds a rental, which providers answer once the energy is delegated
+const energyRentalTimeout = 30 * time.Second
+
+// EnergyRentalWebhook rents energy by posting the receiver and amount as JSON
+// to an HTTP endpoint, e.g. a bridge to an energy market. The endpoint answers
+// with a 2xx status once the energy is delegated to the receiver.
+type EnergyRentalWebhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewEnergyRentalWebhook(config EnergyRentalConfig) *EnergyRentalWebhook {
+	return &EnergyRentalWebhook{
+		url:     config.URL,
+		headers: config.Headers,
+		client:  outbound.Client(energyRentalTimeout),
+	}
+}
+
+// energyRental is the body of an energy rental
+type energyRental struct {
+	Network  string `json:"network"`
+	Receiver string `json:"receiver"`
+	Energy   int64  `json:"energy"`
+}
+
+func (w *EnergyRentalWebhook) RentEnergy(ctx context.Context, network, receiver string, energy int64) error {
+	body, err := json.Marshal(energyRental{Network: network, Receiver: receiver, Energy: energy})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to rent energy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("energy rental rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package facilitator
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mr-tron/base58"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	// DefaultTronFeeLimit is the fee limit in TRX of settlement transactions.
+	// A TRC-20 transfer to an account without a balance of the token burns
+	// about 27 TRX of energy at the current energy price
+	DefaultTronFeeLimit = 50.0
+	// DefaultTronEnergyMargin is the share of energy reserved above the estimate
+	DefaultTronEnergyMargin = 0.1
+
+	// tronTransferBandwidth is the size in bytes of a signed TRC-20 transfer,
+	// which is the bandwidth it consumes
+	tronTransferBandwidth = 345
+	// sunPerTRX is the number of sun in a TRX
+	sunPerTRX = 1_000_000
+	// tronAddressPrefix is the first byte of Tron addresses in hex
+	tronAddressPrefix = 0x41
+	// tronNodeTimeout bounds a single call of the full node API
+	tronNodeTimeout = 10 * time.Second
+)
+
+// TronConfig sets the resources the transfers planned by
+// TronFacilitator.PlanTransfer may consume.
+type TronConfig struct {
+	// TRX a transfer may burn for energy, DefaultTronFeeLimit if 0
+	FeeLimit float64
+	// Share of energy reserved above the estimate, DefaultTronEnergyMargin if 0
+	EnergyMargin float64
+	// Energy missing for a transfer is rented from this endpoint instead of
+	// burning TRX for it, see EnergyRentalWebhook
+	EnergyRental EnergyRentalConfig
+}
+
+// EnergyRentalConfig configures the endpoint energy is rented from.
+type EnergyRentalConfig struct {
+	// Endpoint renting energy, energy isn't rented if empty
+	URL string
+	// Headers sent with every rental, e.g. for authentication
+	Headers map[string]string
+}
+
+// ErrFeeLimitExceeded is returned when a Tron settlement would burn more TRX
+// for its resources than the fee limit allows.
+var ErrFeeLimitExceeded = errors.New("settlement exceeds the fee limit")
+
+// EnergyRenter rents energy to Tron accounts, e.g. from an energy market, so
+// settlements don't burn TRX for it.
+type EnergyRenter interface {
+	// RentEnergy delegates at least the energy to the receiver and returns
+	// once the receiver can use it
+	RentEnergy(ctx context.Context, network, receiver string, energy int64) error
+}
+
+// TronResourcePlan is the resource usage estimated for a TRC-20 transfer.
+type TronResourcePlan struct {
+	// Energy the transfer consumes, including the margin
+	Energy int64
+	// Bandwidth the transfer consumes
+	Bandwidth int64
+	// Energy and bandwidth the signer has available
+	AvailableEnergy    int64
+	AvailableBandwidth int64
+	// Energy rented before the transfer
+	RentedEnergy int64
+	// TRX in sun burnt for missing energy and bandwidth, and the memo fee
+	BurnSun int64
+	// fee_limit of the transaction in sun
+	FeeLimitSun int64
+	// data of the transaction tracing it to its API request, nil without SetRequestMemo
+	Memo []byte
+}
+
+// tronResources estimates the resources of TRC-20 transfers through the HTTP
+// API of a full node.
+type tronResources struct {
+	network  string
+	url      string
+	client   *http.Client
+	feeLimit int64
+	margin   float64
+	renter   EnergyRenter
+	memo     bool
+}
+
+func newTronResources(network, url string) *tronResources {
+	r := &tronResources{
+		network: network,
+		url:     strings.TrimRight(url, "/"),
+		client:  outbound.Client(tronNodeTimeout),
+	}
+	r.configure(TronConfig{})
+	return r
+}
+
+// configure sets the fee limit, the energy margin and the renter of the config.
+func (r *tronResources) configure(config TronConfig) {
+	r.feeLimit = int64(math.Round(cmp.Or(config.FeeLimit, DefaultTronFeeLimit) * sunPerTRX))
+	r.margin = cmp.Or(config.EnergyMargin, DefaultTronEnergyMargin)
+	r.renter = nil
+	if config.EnergyRental.URL != "" {
+		r.renter = NewEnergyRentalWebhook(config.EnergyRental)
+	}
+}
+
+// plan estimates the resources a transfer of amount of token from owner to to
+// consumes. Energy the owner lacks is rented if a renter is set, what is still
+// missing afterwards is paid by burning TRX. It fails if the transfer reverts,
+// burns more than the fee limit or the owner can't pay for the burn.
+func (r *tronResources) plan(ctx context.Context, owner, token, to string, amount *big.Int) (*TronResourcePlan, error) {
+	used, err := r.estimateEnergy(ctx, owner, token, to, amount)
+	if err != nil {
+		return nil, err
+	}
+	plan := &TronResourcePlan{
+		Energy:      int64(math.Ceil(float64(used) * (1 + r.margin))),
+		Bandwidth:   tronTransferBandwidth,
+		FeeLimitSun: r.feeLimit,
+	}
+	if r.memo {
+		plan.Memo = requestMemo(ctx)
+	}
+	if len(plan.Memo) > 0 {
+		// the data field, its tag and length byte
+		plan.Bandwidth += int64(len(plan.Memo)) + 2
+	}
+
+	if err := r.available(ctx, owner, plan); err != nil {
+		return nil, err
+	}
+	if missing := plan.Energy - plan.AvailableEnergy; missing > 0 && r.renter != nil {
+		if err := r.renter.RentEnergy(ctx, r.network, owner, missing); err != nil {
+			// burning TRX remains possible within the fee limit
+			logging.Ctx(ctx, logging.RPC).Warn().Err(err).Str("network", r.network).Int64("energy", missing).Msg("Failed to rent energy")
+		} else {
+			metrics.TronEnergyRented.WithLabelValues(r.network).Add(float64(missing))
+			plan.RentedEnergy = missing
+			if err := r.available(ctx, owner, plan); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	params, err := r.chainParameters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	energyBurn := max(plan.Energy-plan.AvailableEnergy, 0) * params["getEnergyFee"]
+	if energyBurn > plan.FeeLimitSun {
+		return nil, fmt.Errorf("%w: %s TRX of energy needed, the fee limit is %s TRX", ErrFeeLimitExceeded, formatSun(energyBurn), formatSun(plan.FeeLimitSun))
+	}
+	plan.BurnSun = energyBurn
+	if plan.AvailableBandwidth < plan.Bandwidth {
+		// bandwidth is consumed in full from one source, staked or burnt
+		plan.BurnSun += plan.Bandwidth * params["getTransactionFee"]
+	}
+	if len(plan.Memo) > 0 {
+		// charged on top of the resources, 0 on networks without the parameter
+		plan.BurnSun += params["getMemoFee"]
+	}
+
+	if plan.BurnSun > 0 {
+		var account struct {
+			Balance int64 `json:"balance"`
+		}
+		if err := r.call(ctx, "/wallet/getaccount", map[string]any{"address": owner, "visible": true}, &account); err != nil {
+			return nil, fmt.Errorf("failed to get account %s: %w", owner, err)
+		}
+		if account.Balance < plan.BurnSun {
+			return nil, fmt.Errorf("%w: signer holds %s TRX, %s TRX needed", types.ErrFeePayerInsufficientFunds, formatSun(account.Balance), formatSun(plan.BurnSun))
+		}
+	}
+	return plan, nil
+}
+
+// estimateEnergy simulates the transfer and returns the energy it consumes.
+func (r *tronResources) estimateEnergy(ctx context.Context, owner, token, to string, amount *big.Int) (int64, error) {
+	recipient, err := tronAddressBytes(to)
+	if err != nil {
+		return 0, err
+	}
+	parameter := append(common.LeftPadBytes(recipient, 32), common.LeftPadBytes(amount.Bytes(), 32)...)
+
+	var result struct {
+		Result struct {
+			Result  bool   `json:"result"`
+			Message string `json:"message"`
+		} `json:"result"`
+		EnergyUsed  int64 `json:"energy_used"`
+		Transaction struct {
+			Ret []struct {
+				Ret string `json:"ret"`
+			} `json:"ret"`
+		} `json:"transaction"`
+	}
+	err = r.call(ctx, "/wallet/triggerconstantcontract", map[string]any{
+		"owner_address":     owner,
+		"contract_address":  token,
+		"function_selector": "transfer(address,uint256)",
+		"parameter":         hex.EncodeToString(parameter),
+		"visible":           true,
+	}, &result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to simulate transfer: %w", err)
+	}
+	if !result.Result.Result {
+		message, _ := hex.DecodeString(result.Result.Message)
+		return 0, fmt.Errorf("failed to simulate transfer: %s", cmp.Or(string(message), result.Result.Message))
+	}
+	for _, ret := range result.Transaction.Ret {
+		if ret.Ret == "REVERT" {
+			return 0, fmt.Errorf("%w: transfer reverts in simulation", types.ErrTransactionReverted)
+		}
+	}
+	return result.EnergyUsed, nil
+}
+
+// available sets the energy and bandwidth the owner has available in the plan.
+func (r *tronResources) available(ctx context.Context, owner string, plan *TronResourcePlan) error {
+	var resources struct {
+		FreeNetUsed  int64 `json:"freeNetUsed"`
+		FreeNetLimit int64 `json:"freeNetLimit"`
+		NetUsed      int64 `json:"NetUsed"`
+		NetLimit     int64 `json:"NetLimit"`
+		EnergyUsed   int64 `json:"EnergyUsed"`
+		EnergyLimit  int64 `json:"EnergyLimit"`
+	}
+	if err := r.call(ctx, "/wallet/getaccountresource", map[string]any{"address": owner, "visible": true}, &resources); err != nil {
+		return fmt.Errorf("failed to get resources of %s: %w", owner, err)
+	}
+	plan.AvailableEnergy = max(resources.EnergyLimit-resources.EnergyUsed, 0)
+	// staked and free bandwidth can't be combined, the transaction uses whichever covers it
+	plan.AvailableBandwidth = max(resources.NetLimit-resources.NetUsed, resources.FreeNetLimit-resources.FreeNetUsed, 0)
+	return nil
+}
+
+// chainParameters returns the parameters of the network by key.
+func (r *tronResources) chainParameters(ctx context.Context) (map[string]int64, error) {
+	var result struct {
+		ChainParameter []struct {
+			Key   string `json:"key"`
+			Value int64  `json:"value"`
+		} `json:"chainParameter"`
+	}
+	if err := r.call(ctx, "/wallet/getchainparameters", struct{}{}, &result); err != nil {
+		return nil, fmt.Errorf("failed to get chain parameters: %w", err)
+	}
+	params := make(map[string]int64, len(result.ChainParameter))
+	for _, p := range result.ChainParameter {
+		params[p.Key] = p.Value
+	}
+	for _, key := range []string{"getEnergyFee", "getTransactionFee"} {
+		if _, ok := params[key]; !ok {
+			return nil, fmt.Errorf("chain parameter %s is missing", key)
+		}
+	}
+	return params, nil
+}
+
+// call posts the request to the API endpoint at path and decodes the response into result.
+func (r *tronResources) call(ctx context.Context, path string, request, result any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("node responded %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var failure struct {
+		Error string `json:"Error"`
+	}
+	if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+		return errors.New(failure.Error)
+	}
+	return json.Unmarshal(data, result)
+}
+
+// formatSun formats an amount of sun in TRX.
+func formatSun(sun int64) string {
+	return types.FormatUnits(big.NewInt(sun), 6)
+}
+
+// TronAddress returns the base58 Tron address of an Ethereum address of the same key.
+func TronAddress(address common.Address) string {
+	payload := append([]byte{tronAddressPrefix}, address.Bytes()...)
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return base58.Encode(append(payload, second[:4]...))
+}
+
+// tronAddressBytes returns the 20 bytes of a base58 Tron address.
+func tronAddressBytes(address string) ([]byte, error) {
+	decoded, err := base58.Decode(address)
+	if err != nil || len(decoded) != 25 || decoded[0] != tronAddressPrefix {
+		return nil, fmt.Errorf("invalid Tron address %q", address)
+	}
+	first := sha256.Sum256(decoded[:21])
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], decoded[21:]) {
+		return nil, fmt.Errorf("invalid checksum of Tron address %q", address)
+	}
+	return decoded[1:21], nil
+}
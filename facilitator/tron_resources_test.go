@@ -0,0 +1,175 @@
+package facilitator
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// tronNode serves the resources of a single account.
+type tronNode struct {
+	energyUsed  int64
+	energyLimit int64
+	freeNet     int64
+	balance     int64
+	revert      bool
+	parameter   string
+}
+
+func (n *tronNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]any
+	json.NewDecoder(r.Body).Decode(&req)
+	var resp any
+	switch r.URL.Path {
+	case "/wallet/triggerconstantcontract":
+		n.parameter, _ = req["parameter"].(string)
+		ret := "SUCCESS"
+		if n.revert {
+			ret = "REVERT"
+		}
+		resp = map[string]any{
+			"result":      map[string]any{"result": true},
+			"energy_used": n.energyUsed,
+			"transaction": map[string]any{"ret": []map[string]any{{"ret": ret}}},
+		}
+	case "/wallet/getaccountresource":
+		resp = map[string]any{"freeNetLimit": 600, "freeNetUsed": 600 - n.freeNet, "EnergyLimit": n.energyLimit}
+	case "/wallet/getchainparameters":
+		resp = map[string]any{"chainParameter": []map[string]any{
+			{"key": "getTransactionFee", "value": 1000},
+			{"key": "getEnergyFee", "value": 210},
+			{"key": "getMemoFee", "value": 1_000_000},
+		}}
+	case "/wallet/getaccount":
+		resp = map[string]any{"balance": n.balance}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fakeRenter delegates the energy it is asked for on the node.
+type fakeRenter struct {
+	node    *tronNode
+	rented  int64
+	failing bool
+}
+
+func (f *fakeRenter) RentEnergy(_ context.Context, _, _ string, energy int64) error {
+	if f.failing {
+		return errors.New("market closed")
+	}
+	f.rented += energy
+	f.node.energyLimit += energy
+	return nil
+}
+
+func TestTronPlanTransfer(t *testing.T) {
+	const token = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	node := &tronNode{}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	recipientKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	recipient := crypto.PubkeyToAddress(recipientKey.PublicKey)
+	to := TronAddress(recipient)
+
+	newFacilitator := func(feeLimit float64, requestMemo bool) *TronFacilitator {
+		f, err := NewTronFacilitator(NetworkConfig{
+			Network: "tron:nile",
+			RPCURLs: []string{server.URL},
+		}, hex.EncodeToString(crypto.FromECDSA(key)))
+		require.NoError(t, err)
+		f.SetResources(TronConfig{FeeLimit: feeLimit})
+		f.SetRequestMemo(requestMemo)
+		return f
+	}
+
+	tests := []struct {
+		name      string
+		node      tronNode
+		feeLimit  float64
+		renter    bool
+		rentFails bool
+		memo      bool
+		burn      int64
+		rented    int64
+		err       error
+	}{
+		// 65000 energy plus the 10% margin
+		{name: "staked resources", node: tronNode{energyUsed: 65000, energyLimit: 71500, freeNet: 600}},
+		{name: "energy burnt", node: tronNode{energyUsed: 65000, freeNet: 600, balance: 15_015_000}, burn: 71500 * 210},
+		{name: "bandwidth burnt", node: tronNode{energyUsed: 65000, energyLimit: 71500, freeNet: 100, balance: 345_000}, burn: 345 * 1000},
+		{name: "balance too low", node: tronNode{energyUsed: 65000, freeNet: 600, balance: 15_014_999}, err: types.ErrFeePayerInsufficientFunds},
+		{name: "fee limit exceeded", node: tronNode{energyUsed: 65000, freeNet: 600, balance: 1e9}, feeLimit: 15, err: ErrFeeLimitExceeded},
+		{name: "energy rented", node: tronNode{energyUsed: 65000, energyLimit: 1500, freeNet: 600}, renter: true, rented: 70000},
+		{name: "failed rental burns", node: tronNode{energyUsed: 65000, freeNet: 600, balance: 1e9}, renter: true, rentFails: true, burn: 71500 * 210},
+		// 345 + 20 bytes of memo data, and the memo fee
+		{name: "request memo", node: tronNode{energyUsed: 65000, energyLimit: 71500, freeNet: 100, balance: 1e9}, memo: true, burn: 365*1000 + 1_000_000},
+		{name: "reverting transfer", node: tronNode{energyUsed: 65000, revert: true}, err: types.ErrTransactionReverted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*node = tt.node
+			f := newFacilitator(tt.feeLimit, tt.memo)
+			renter := &fakeRenter{node: node, failing: tt.rentFails}
+			if tt.renter {
+				f.SetEnergyRenter(renter)
+			}
+			ctx := paymentctx.With(t.Context(), paymentctx.Metadata{RequestID: "6e81_I2ixXbb6"})
+			plan, err := f.PlanTransfer(ctx, token, to, big.NewInt(1_000_000))
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, int64(71500), plan.Energy)
+			if tt.memo {
+				require.Equal(t, []byte("x402:6e81_I2ixXbb6"), plan.Memo)
+				require.Equal(t, int64(tronTransferBandwidth+20), plan.Bandwidth)
+			} else {
+				require.Nil(t, plan.Memo)
+				require.Equal(t, int64(tronTransferBandwidth), plan.Bandwidth)
+			}
+			require.Equal(t, tt.burn, plan.BurnSun)
+			require.Equal(t, tt.rented, plan.RentedEnergy)
+			require.Equal(t, tt.rented, renter.rented)
+			require.Equal(t, int64(DefaultTronFeeLimit*sunPerTRX), plan.FeeLimitSun)
+			require.Equal(t, "000000000000000000000000"+hex.EncodeToString(recipient.Bytes())+"00000000000000000000000000000000000000000000000000000000000f4240", node.parameter)
+		})
+	}
+}
+
+func TestTronAddress(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	encoded := TronAddress(address)
+	require.Equal(t, byte('T'), encoded[0])
+	decoded, err := tronAddressBytes(encoded)
+	require.NoError(t, err)
+	require.Equal(t, address.Bytes(), decoded)
+
+	_, err = tronAddressBytes("TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t")
+	require.NoError(t, err)
+	_, err = tronAddressBytes("TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u")
+	require.Error(t, err, "the checksum is verified")
+	_, err = tronAddressBytes("0x036CbD53842c5426634e7929541eC2318f3dCF7e")
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package facilitator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// defaultVerifyCacheTTL is how long verify results are reused if the configuration doesn't say otherwise
+const defaultVerifyCacheTTL = 10 * time.Second
+
+// maxVerifyCacheEntries bounds the cached results, expired ones are dropped when it is reached
+const maxVerifyCacheEntries = 10_000
+
+// VerifyCacheConfig sets how long the result of a verification is reused for
+// the same payload and requirements. Resource servers often verify a payment
+// when the request comes in and again right before settling it, the second
+// verification is answered without reading the chain. The policies of the
+// caller are checked every time.
+type VerifyCacheConfig struct {
+	// Turns caching off
+	Disabled bool `mapstructure:"disabled"`
+	// How long results are reused, 0 means 10 seconds
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+type bypassCacheKey struct{}
+
+// BypassVerifyCache returns a copy of ctx whose verifications read the chain
+// even if a result is cached. Their result replaces the cached one.
+func BypassVerifyCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func bypassesCache(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return bypass
+}
+
+// verifyCache keeps the valid results of the facilitators. Invalid results
+// aren't kept, payers may fix them, e.g. by topping up their balance, and retry.
+type verifyCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]verifyEntry
+}
+
+type verifyEntry struct {
+	resp    types.PaymentVerifyResponse
+	expires time.Time
+}
+
+// newVerifyCache creates the cache, nil if caching is disabled.
+func newVerifyCache(config VerifyCacheConfig) *verifyCache {
+	if config.Disabled {
+		return nil
+	}
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = defaultVerifyCacheTTL
+	}
+	return &verifyCache{ttl: ttl, entries: make(map[string]verifyEntry)}
+}
+
+// verifyKey hashes the payload and requirements, empty if they can't be encoded.
+func verifyKey(payload *types.PaymentPayload, req *types.PaymentRequirements) string {
+	encodedPayload, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	encodedReq, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(encodedPayload)
+	h.Write([]byte{0})
+	h.Write(encodedReq)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns a copy of the cached result of the key.
+func (c *verifyCache) get(network, key string, now time.Time) (*types.PaymentVerifyResponse, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || !now.Before(entry.expires) {
+		metrics.ChainCacheLookups.WithLabelValues(network, cacheVerify, "miss").Inc()
+		return nil, false
+	}
+	metrics.ChainCacheLookups.WithLabelValues(network, cacheVerify, "hit").Inc()
+	resp := entry.resp
+	return &resp, true
+}
+
+// put caches the result if it is valid and was checked against the chain.
+func (c *verifyCache) put(key string, resp *types.PaymentVerifyResponse, now time.Time) {
+	if resp == nil || !resp.IsValid || resp.ChainChecksSkipped {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxVerifyCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxVerifyCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = verifyEntry{resp: *resp, expires: now.Add(c.ttl)}
+}
+
+// forget drops the cached result of the key.
+func (c *verifyCache) forget(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
@@ -0,0 +1,189 @@
+// Package hwwallet signs settlement transactions with a Ledger or Trezor
+// hardware wallet connected over USB. Every transaction is shown on the device
+// and only signed once the operator confirms it there, which suits low-volume
+// facilitators that keep their key off the host.
+//
+// Hardware wallets refuse to sign raw digests, so the wallet signs complete
+// transactions instead of acting as a types.Signer.
+package hwwallet
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+)
+
+const (
+	Ledger = "ledger"
+	Trezor = "trezor"
+)
+
+// DefaultDerivationPath is the path of the first account of the Ethereum app
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// defaultConfirmTimeout bounds how long a transaction waits for the operator if the configuration doesn't say otherwise
+const defaultConfirmTimeout = 2 * time.Minute
+
+// ErrNotSigned is returned when the device rejects a transaction, e.g. because the operator declined it
+var ErrNotSigned = errors.New("hardware wallet did not sign the transaction")
+
+// Config selects a hardware wallet account.
+type Config struct {
+	// Kind of the wallet, "ledger" or "trezor"
+	Wallet string `mapstructure:"wallet"`
+	// BIP-32 path of the account, the first Ethereum account if empty
+	DerivationPath string `mapstructure:"derivationPath"`
+	// How long a transaction waits for confirmation on the device, 0 means two minutes
+	ConfirmTimeout time.Duration `mapstructure:"confirmTimeout"`
+}
+
+// Prompter asks the operator for input, e.g. the PIN of a Trezor.
+type Prompter func(message string) (string, error)
+
+// Wallet signs transactions of a single hardware wallet account.
+type Wallet struct {
+	device  accounts.Wallet
+	account accounts.Account
+	timeout time.Duration
+
+	// busy lets one transaction at a time wait for confirmation on the
+	// device. It is held until the device answers, also after the caller gave up
+	busy chan struct{}
+}
+
+// Open connects to the first wallet of the configured kind and derives the account.
+// prompt is asked for the PIN and passphrase of Trezor wallets, it may be nil for Ledger wallets.
+func Open(config Config, prompt Prompter) (*Wallet, error) {
+	path, err := accounts.ParseDerivationPath(cmp.Or(config.DerivationPath, DefaultDerivationPath))
+	if err != nil {
+		return nil, fmt.Errorf("hardware wallet: invalid derivation path: %w", err)
+	}
+
+	var hub *usbwallet.Hub
+	switch config.Wallet {
+	case Ledger:
+		hub, err = usbwallet.NewLedgerHub()
+	case Trezor:
+		hub, err = usbwallet.NewTrezorHubWithHID()
+	default:
+		return nil, fmt.Errorf("hardware wallet: unknown wallet %q", config.Wallet)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hardware wallet: failed to access usb devices: %w", err)
+	}
+
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("hardware wallet: no %s connected", config.Wallet)
+	}
+	device := wallets[0]
+	if err := unlock(device, prompt); err != nil {
+		return nil, fmt.Errorf("hardware wallet: failed to open %s: %w", device.URL(), err)
+	}
+	account, err := device.Derive(path, true)
+	if err != nil {
+		device.Close()
+		return nil, fmt.Errorf("hardware wallet: failed to derive account %s: %w", path, err)
+	}
+	return New(device, account, config.ConfirmTimeout), nil
+}
+
+// unlock opens the device, asking for the PIN and passphrase the device requests.
+func unlock(device accounts.Wallet, prompt Prompter) error {
+	err := device.Open("")
+	for range 2 {
+		var message string
+		switch {
+		case errors.Is(err, usbwallet.ErrTrezorPINNeeded):
+			message = "Enter the PIN of the Trezor by the positions of its digits on the device"
+		case errors.Is(err, usbwallet.ErrTrezorPassphraseNeeded):
+			message = "Enter the passphrase of the Trezor"
+		default:
+			return err
+		}
+		if prompt == nil {
+			return err
+		}
+		input, promptErr := prompt(message)
+		if promptErr != nil {
+			return promptErr
+		}
+		err = device.Open(input)
+	}
+	return err
+}
+
+// New signs with the account of an opened wallet. A zero timeout means two minutes.
+func New(device accounts.Wallet, account accounts.Account, timeout time.Duration) *Wallet {
+	return &Wallet{
+		device:  device,
+		account: account,
+		timeout: cmp.Or(timeout, defaultConfirmTimeout),
+		busy:    make(chan struct{}, 1),
+	}
+}
+
+func (w *Wallet) Address() common.Address {
+	return w.account.Address
+}
+
+// SignTx sends the transaction to the device and waits until the operator
+// confirms or rejects it there, the confirmation timeout passes or ctx is done.
+// The timeout starts once the device is free: it can't be interrupted, so a
+// transaction the caller stopped waiting for keeps it busy until it is answered.
+func (w *Wallet) SignTx(ctx context.Context, tx *ethTypes.Transaction, chainID *big.Int) (*ethTypes.Transaction, error) {
+	select {
+	case w.busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for the hardware wallet: %w", ctx.Err())
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	logger := logging.Ctx(ctx, logging.Settlement).With().
+		Str("wallet", w.device.URL().String()).
+		Str("account", w.account.Address.Hex()).
+		Uint64("nonce", tx.Nonce()).
+		Logger()
+	if tx.To() != nil {
+		logger = logger.With().Str("to", tx.To().Hex()).Logger()
+	}
+	logger.Info().Msg("Confirm the settlement transaction on the hardware wallet")
+
+	type result struct {
+		tx  *ethTypes.Transaction
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() { <-w.busy }()
+		signed, err := w.device.SignTx(w.account, tx, chainID)
+		done <- result{signed, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for confirmation on the hardware wallet: %w", ctx.Err())
+	case res := <-done:
+		if res.err != nil {
+			logger.Warn().Err(res.err).Msg("Hardware wallet didn't sign the settlement transaction")
+			return nil, fmt.Errorf("%w: %w", ErrNotSigned, res.err)
+		}
+		return res.tx, nil
+	}
+}
+
+// Close releases the device.
+func (w *Wallet) Close() error {
+	return w.device.Close()
+}
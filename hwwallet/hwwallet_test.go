@@ -0,0 +1,67 @@
+package hwwallet
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// pendingWallet never answers, like a device waiting for the operator
+type pendingWallet struct {
+	accounts.Wallet
+}
+
+func (pendingWallet) URL() accounts.URL {
+	return accounts.URL{Scheme: Ledger, Path: "test"}
+}
+
+func (pendingWallet) SignTx(accounts.Account, *ethTypes.Transaction, *big.Int) (*ethTypes.Transaction, error) {
+	select {}
+}
+
+func TestWalletSignTx(t *testing.T) {
+	// the keystore stands in for a device, it signs once unlocked
+	store := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := store.NewAccount("")
+	require.NoError(t, err)
+
+	chainID := big.NewInt(84532)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000b0")
+	tx := ethTypes.NewTx(&ethTypes.LegacyTx{Nonce: 7, GasPrice: big.NewInt(1), Gas: 21000, To: &to})
+	wallet := New(store.Wallets()[0], account, 0)
+	require.Equal(t, account.Address, wallet.Address())
+
+	t.Run("declined transactions are not signed", func(t *testing.T) {
+		_, err := wallet.SignTx(t.Context(), tx, chainID)
+		require.ErrorIs(t, err, ErrNotSigned)
+	})
+
+	t.Run("confirmed transactions are signed by the account", func(t *testing.T) {
+		require.NoError(t, store.Unlock(account, ""))
+		signed, err := wallet.SignTx(t.Context(), tx, chainID)
+		require.NoError(t, err)
+
+		sender, err := ethTypes.Sender(ethTypes.LatestSignerForChainID(chainID), signed)
+		require.NoError(t, err)
+		require.Equal(t, account.Address, sender)
+	})
+
+	t.Run("unconfirmed transactions time out", func(t *testing.T) {
+		pending := New(pendingWallet{}, account, 50*time.Millisecond)
+		_, err := pending.SignTx(t.Context(), tx, chainID)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// the device still waits for the abandoned transaction
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		_, err = pending.SignTx(ctx, tx, chainID)
+		require.ErrorContains(t, err, "waiting for the hardware wallet")
+	})
+}
@@ -0,0 +1,377 @@
+// Package indexer follows the blocks of the networks and reconciles the token
+// transfers of the signers with the settlement store. Transfers without a
+// settlement on record, e.g. sent with a leaked key or by a settlement the
+// store lost, and settlements whose transaction was never mined are reported
+// as findings.
+package indexer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/settlement"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	defaultInterval = 15 * time.Second
+	defaultGrace    = 10 * time.Minute
+	defaultLookback = time.Hour
+
+	// maxBlocksPerPass bounds the blocks of a network indexed in one pass, the rest follow in the next
+	maxBlocksPerPass = 500
+	// maxFindings bounds the findings kept, the oldest are dropped
+	maxFindings = 1000
+	// passTimeout bounds indexing a single network
+	passTimeout = 2 * time.Minute
+)
+
+// Config configures the indexer.
+type Config struct {
+	// Runs the indexer
+	Enabled bool `mapstructure:"enabled"`
+	// How often new blocks are indexed, 0 means every 15 seconds
+	Interval time.Duration `mapstructure:"interval"`
+	// Blocks before the head the indexer starts at, 0 starts at the head
+	Backfill uint64 `mapstructure:"backfill"`
+	// How long a settlement transaction may take to be mined before it is missing, 0 means 10 minutes
+	Grace time.Duration `mapstructure:"grace"`
+	// How long before a transfer its settlement may have been created, 0 means one hour.
+	// Settlements older than this aren't checked for missing receipts
+	Lookback time.Duration `mapstructure:"lookback"`
+}
+
+// Indexer reconciles the transfers of the signers of all networks whose
+// facilitator implements facilitator.TransferIndexer. The progress of the
+// indexer isn't persisted, it starts again at the head after a restart.
+type Indexer struct {
+	registry *facilitator.Registry
+	records  store.Store
+	interval time.Duration
+	backfill uint64
+	grace    time.Duration
+	lookback time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	networks map[string]*progress
+	findings []types.ReconciliationFinding // oldest first
+	// transactions that were mined or reported, by hash, with the time they were seen
+	checked map[string]time.Time
+}
+
+// progress is the indexing state of a network
+type progress struct {
+	// next block to index, unset until the first pass
+	next    uint64
+	started bool
+	// last indexed block
+	block uint64
+	err   error
+}
+
+func New(registry *facilitator.Registry, records store.Store, config Config) *Indexer {
+	return &Indexer{
+		registry: registry,
+		records:  records,
+		interval: cmp.Or(config.Interval, defaultInterval),
+		backfill: config.Backfill,
+		grace:    cmp.Or(config.Grace, defaultGrace),
+		lookback: cmp.Or(config.Lookback, defaultLookback),
+		now:      time.Now,
+		networks: make(map[string]*progress),
+		checked:  make(map[string]time.Time),
+	}
+}
+
+// Run indexes new blocks until the context is cancelled. It indexes every
+// interval, and as soon as a network subscribed to its blocks has a new one.
+func (i *Indexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		waitCtx, cancel := context.WithCancel(ctx)
+		head := i.newHead(waitCtx)
+		i.Index(ctx)
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		case <-head:
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// newHead returns a channel that is closed once any network subscribed to its
+// blocks has a new one, nil if none is subscribed. Watching ends with ctx.
+func (i *Indexer) newHead(ctx context.Context) <-chan struct{} {
+	var heads []<-chan struct{}
+	for _, config := range i.registry.Networks() {
+		f, _, ok := i.registry.Lookup(config.Network)
+		if !ok {
+			continue
+		}
+		if notifier, ok := f.(facilitator.HeadNotifier); ok {
+			if head := notifier.NewHead(); head != nil {
+				heads = append(heads, head)
+			}
+		}
+	}
+	if len(heads) == 0 {
+		return nil
+	}
+
+	merged := make(chan struct{})
+	var once sync.Once
+	for _, head := range heads {
+		go func() {
+			select {
+			case <-head:
+				once.Do(func() { close(merged) })
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return merged
+}
+
+// Index reconciles the blocks confirmed since the last pass on every network.
+func (i *Indexer) Index(ctx context.Context) {
+	for _, config := range i.registry.Networks() {
+		f, _, ok := i.registry.Lookup(config.Network)
+		if !ok {
+			continue
+		}
+		indexer, ok := f.(facilitator.TransferIndexer)
+		if !ok {
+			continue
+		}
+		state := i.progress(config.Network)
+		if errors.Is(state.err, facilitator.ErrIndexingUnsupported) {
+			continue
+		}
+
+		passCtx, cancel := context.WithTimeout(ctx, passTimeout)
+		err := i.index(passCtx, config, indexer, state)
+		cancel()
+		if err != nil {
+			logging.For(logging.Indexer).Warn().Err(err).Str("network", config.Network).Msg("Failed to index transfers")
+		}
+		i.mu.Lock()
+		state.err = err
+		i.mu.Unlock()
+	}
+}
+
+// index reconciles the confirmed blocks of the network after state.next.
+func (i *Indexer) index(ctx context.Context, config facilitator.NetworkConfig, indexer facilitator.TransferIndexer, state *progress) error {
+	head, err := indexer.Head(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get head: %w", err)
+	}
+	if head+1 < config.Confirmations {
+		return nil
+	}
+	confirmed := head + 1 - config.Confirmations
+	if !state.started {
+		state.next = confirmed + 1 - min(i.backfill, confirmed)
+		state.started = true
+	}
+	if state.next > confirmed {
+		return nil
+	}
+	last := min(confirmed, state.next+maxBlocksPerPass-1)
+
+	var blocks []*facilitator.BlockTransfers
+	for number := state.next; number <= last; number++ {
+		block, err := indexer.BlockTransfers(ctx, number)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, block)
+	}
+	if err := i.reconcile(ctx, config.Network, blocks, indexer); err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	state.next, state.block = last+1, last
+	i.mu.Unlock()
+	metrics.IndexedBlock.WithLabelValues(config.Network).Set(float64(last))
+	return nil
+}
+
+// reconcile matches the transfers of the blocks with the settlements on record
+// and checks that the transactions of recent settlements were mined.
+func (i *Indexer) reconcile(ctx context.Context, network string, blocks []*facilitator.BlockTransfers, indexer facilitator.TransferIndexer) error {
+	now := i.now()
+	from := now.Add(-i.lookback)
+	if len(blocks) > 0 && blocks[0].Time.Add(-i.lookback).Before(from) {
+		from = blocks[0].Time.Add(-i.lookback)
+	}
+	settlements, err := i.records.ListSettlements(ctx, from, now.Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("failed to list settlements: %w", err)
+	}
+	byTx := make(map[string]*store.Settlement)
+	for _, s := range settlements {
+		if s.Network == network && s.TxHash != "" {
+			byTx[strings.ToLower(s.TxHash)] = s
+		}
+	}
+
+	for _, block := range blocks {
+		for _, hash := range block.Transactions {
+			i.check(hash, now)
+		}
+		for _, transfer := range block.Transfers {
+			s, ok := byTx[strings.ToLower(transfer.TxHash)]
+			switch {
+			case !ok:
+				i.report(types.ReconciliationFinding{
+					Network: network,
+					Kind:    types.FindingOrphanedTransfer,
+					TxHash:  transfer.TxHash,
+					Block:   block.Number,
+					Detail: fmt.Sprintf("transfer of %s of token %s from %s to %s sent by %s has no settlement on record",
+						transfer.Amount, transfer.Token, transfer.From, transfer.To, transfer.Sender),
+				})
+			case settlement.Status(s.Status).IsFailure():
+				i.report(types.ReconciliationFinding{
+					Network:      network,
+					Kind:         types.FindingOrphanedTransfer,
+					TxHash:       transfer.TxHash,
+					Block:        block.Number,
+					SettlementID: s.ID,
+					Detail:       fmt.Sprintf("transfer of %s of token %s was mined, but its settlement is recorded as failed: %s", transfer.Amount, transfer.Token, s.Error),
+				})
+			}
+		}
+	}
+
+	// transactions of settlements past the grace period must have been mined
+	for _, s := range settlements {
+		if s.Network != network || s.TxHash == "" || settlement.Status(s.Status).IsFailure() || s.CreatedAt.After(now.Add(-i.grace)) || i.isChecked(s.TxHash) {
+			continue
+		}
+		mined, err := indexer.HasReceipt(ctx, s.TxHash)
+		if err != nil {
+			return fmt.Errorf("failed to get receipt of %s: %w", s.TxHash, err)
+		}
+		if mined {
+			i.check(s.TxHash, now)
+			continue
+		}
+		i.report(types.ReconciliationFinding{
+			Network:      network,
+			Kind:         types.FindingMissingReceipt,
+			TxHash:       s.TxHash,
+			SettlementID: s.ID,
+			Detail:       fmt.Sprintf("settlement is recorded as %s, but its transaction wasn't mined %s after it was created", s.Status, now.Sub(s.CreatedAt).Round(time.Second)),
+		})
+	}
+	i.prune(now)
+	return nil
+}
+
+// report records the finding, unless the transaction was reported already.
+func (i *Indexer) report(finding types.ReconciliationFinding) {
+	finding.DetectedAt = i.now()
+	key := finding.Kind + ":" + strings.ToLower(finding.TxHash)
+
+	i.mu.Lock()
+	if _, ok := i.checked[key]; ok {
+		i.mu.Unlock()
+		return
+	}
+	i.checked[key] = finding.DetectedAt
+	if _, ok := i.checked[strings.ToLower(finding.TxHash)]; !ok {
+		i.checked[strings.ToLower(finding.TxHash)] = finding.DetectedAt
+	}
+	i.findings = append(i.findings, finding)
+	if len(i.findings) > maxFindings {
+		i.findings = slices.Delete(i.findings, 0, len(i.findings)-maxFindings)
+	}
+	i.mu.Unlock()
+
+	metrics.ReconciliationFindings.WithLabelValues(finding.Network, finding.Kind).Inc()
+	logging.For(logging.Indexer).Warn().
+		Str("network", finding.Network).
+		Str("kind", finding.Kind).
+		Str("tx_hash", finding.TxHash).
+		Str("settlement_id", finding.SettlementID).
+		Msg(finding.Detail)
+}
+
+// check records that the transaction needs no receipt check anymore.
+func (i *Indexer) check(txHash string, now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.checked[strings.ToLower(txHash)] = now
+}
+
+func (i *Indexer) isChecked(txHash string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.checked[strings.ToLower(txHash)]
+	return ok
+}
+
+// prune forgets checked transactions whose settlements are out of the lookback window.
+func (i *Indexer) prune(now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for key, at := range i.checked {
+		if now.Sub(at) > 2*i.lookback {
+			delete(i.checked, key)
+		}
+	}
+}
+
+func (i *Indexer) progress(network string) *progress {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	state, ok := i.networks[network]
+	if !ok {
+		state = &progress{}
+		i.networks[network] = state
+	}
+	return state
+}
+
+// Reconciliation returns the progress of the indexer and its findings, newest first.
+func (i *Indexer) Reconciliation() *types.Reconciliation {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	report := &types.Reconciliation{
+		Networks: []types.IndexedNetwork{},
+		Findings: make([]types.ReconciliationFinding, 0, len(i.findings)),
+	}
+	for network, state := range i.networks {
+		indexed := types.IndexedNetwork{Network: network, Block: state.block}
+		if state.err != nil {
+			indexed.Error = state.err.Error()
+		}
+		report.Networks = append(report.Networks, indexed)
+	}
+	slices.SortFunc(report.Networks, func(a, b types.IndexedNetwork) int {
+		return strings.Compare(a.Network, b.Network)
+	})
+	for _, finding := range slices.Backward(i.findings) {
+		report.Findings = append(report.Findings, finding)
+	}
+	return report
+}
@@ -0,0 +1,113 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const testNetwork = "eip155:84532"
+
+// fakeChain serves the blocks and receipts of a test.
+type fakeChain struct {
+	facilitator.Facilitator
+	head   uint64
+	blocks map[uint64]*facilitator.BlockTransfers
+	mined  map[string]bool
+}
+
+func (c *fakeChain) Head(context.Context) (uint64, error) {
+	return c.head, nil
+}
+
+func (c *fakeChain) BlockTransfers(_ context.Context, number uint64) (*facilitator.BlockTransfers, error) {
+	if block, ok := c.blocks[number]; ok {
+		return block, nil
+	}
+	return &facilitator.BlockTransfers{Number: number}, nil
+}
+
+func (c *fakeChain) HasReceipt(_ context.Context, txHash string) (bool, error) {
+	return c.mined[txHash], nil
+}
+
+func TestIndexer(t *testing.T) {
+	now := time.Now()
+	chain := &fakeChain{head: 100, blocks: make(map[uint64]*facilitator.BlockTransfers), mined: make(map[string]bool)}
+	config := facilitator.NetworkConfig{Network: testNetwork}
+	require.NoError(t, config.Normalize())
+	registry := facilitator.NewRegistry()
+	require.NoError(t, registry.Register(config, chain))
+
+	records := store.NewMemory()
+	save := func(id, txHash, status string, created time.Time) {
+		require.NoError(t, records.SaveSettlement(t.Context(), &store.Settlement{
+			ID: id, Network: testNetwork, TxHash: txHash, Status: status, CreatedAt: created, UpdatedAt: created,
+		}))
+	}
+	save("settled", "0xaa", "confirmed", now.Add(-time.Minute))
+	save("failed", "0xbb", "failed", now.Add(-time.Minute))
+	save("dropped", "0xcc", "submitted", now.Add(-20*time.Minute))
+	save("recent", "0xdd", "submitted", now.Add(-time.Minute))
+
+	indexer := New(registry, records, Config{Backfill: 2})
+	transfer := func(txHash string) facilitator.Transfer {
+		return facilitator.Transfer{TxHash: txHash, Sender: "0xfa", Token: "0xusdc", From: "0x01", To: "0x02", Amount: big.NewInt(10)}
+	}
+	chain.blocks[99] = &facilitator.BlockTransfers{
+		Number:       99,
+		Time:         now,
+		Transactions: []string{"0xaa", "0xbb", "0xee"},
+		Transfers:    []facilitator.Transfer{transfer("0xaa"), transfer("0xbb"), transfer("0xee")},
+	}
+
+	indexer.Index(t.Context())
+	report := indexer.Reconciliation()
+	require.Equal(t, []types.IndexedNetwork{{Network: testNetwork, Block: 100}}, report.Networks)
+
+	kinds := make(map[string]string)
+	for _, finding := range report.Findings {
+		kinds[finding.TxHash] = finding.Kind
+	}
+	require.Equal(t, map[string]string{
+		"0xbb": types.FindingOrphanedTransfer, // recorded as failed
+		"0xcc": types.FindingMissingReceipt,
+		"0xee": types.FindingOrphanedTransfer, // no settlement
+	}, kinds)
+
+	// findings are reported once
+	chain.head = 101
+	indexer.Index(t.Context())
+	report = indexer.Reconciliation()
+	require.Len(t, report.Findings, 3)
+	require.Equal(t, uint64(101), report.Networks[0].Block)
+}
+
+func TestIndexerUnsupported(t *testing.T) {
+	config := facilitator.NetworkConfig{Network: testNetwork}
+	require.NoError(t, config.Normalize())
+	registry := facilitator.NewRegistry()
+	require.NoError(t, registry.Register(config, &unsupportedChain{fakeChain: &fakeChain{head: 10}}))
+
+	indexer := New(registry, store.NewMemory(), Config{Backfill: 1})
+	indexer.Index(t.Context())
+	indexer.Index(t.Context())
+	report := indexer.Reconciliation()
+	require.Len(t, report.Networks, 1)
+	require.Equal(t, facilitator.ErrIndexingUnsupported.Error(), report.Networks[0].Error)
+}
+
+type unsupportedChain struct {
+	*fakeChain
+}
+
+func (c *unsupportedChain) BlockTransfers(context.Context, uint64) (*facilitator.BlockTransfers, error) {
+	return nil, facilitator.ErrIndexingUnsupported
+}
@@ -0,0 +1,57 @@
+// Package hmacauth implements request signing with a shared secret.
+//
+// The signature is the hex encoded HMAC-SHA256 of
+//
+//	<timestamp>\n<nonce>\n<method>\n<request URI>\n<hex SHA-256 of the body>
+//
+// where the timestamp is in unix seconds and the request URI includes the query.
+// It is sent as "X-Signature: sha256=<signature>" together with the X-Key-Id,
+// X-Timestamp and X-Nonce headers.
+package hmacauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Signature"
+	HeaderKeyID     = "X-Key-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+
+	// SignaturePrefix names the hash of the signature header value
+	SignaturePrefix = "sha256="
+)
+
+// Signature computes the hex encoded signature of a request.
+func Signature(secret []byte, timestamp, nonce, method, requestURI string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		timestamp,
+		nonce,
+		strings.ToUpper(method),
+		requestURI,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign sets the authentication headers of the request with a fresh nonce.
+func Sign(req *http.Request, body []byte, keyID string, secret []byte) {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderKeyID, keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce[:]))
+	req.Header.Set(HeaderSignature, SignaturePrefix+Signature(secret, timestamp, req.Header.Get(HeaderNonce), req.Method, req.URL.RequestURI(), body))
+}
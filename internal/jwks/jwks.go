@@ -0,0 +1,237 @@
+// Package jwks fetches the public keys of an identity provider from its JSON
+// Web Key Set (RFC 7517) and keeps them up to date, so tokens signed with a
+// rotated key are accepted as soon as the provider publishes it.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+)
+
+const (
+	// DefaultRefresh is how long keys are used before they are fetched again
+	DefaultRefresh = time.Hour
+	// minRefetch bounds how often an unknown key ID triggers a fetch, so tokens
+	// with made up key IDs can't flood the provider
+	minRefetch   = time.Minute
+	fetchTimeout = 10 * time.Second
+	// maxSetSize bounds the key set read from the provider
+	maxSetSize = 1 << 20
+)
+
+// ErrUnknownKey is returned for key IDs the key set doesn't contain
+var ErrUnknownKey = errors.New("unknown key ID")
+
+// Set is the key set published at a URL.
+type Set struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	// lets concurrent callers share a fetch
+	group singleflight.Group
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	// last fetch, successful or not
+	attempted time.Time
+}
+
+// New creates the key set published at url, fetched on first use and again
+// after refresh, DefaultRefresh if 0.
+func New(url string, refresh time.Duration) *Set {
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	return &Set{
+		url:     url,
+		refresh: refresh,
+		client:  outbound.Client(fetchTimeout),
+	}
+}
+
+// Key returns the public key with the ID. The set is fetched again if it is
+// stale or doesn't know the ID, but at most once a minute: meanwhile a stale
+// key is still used and unknown IDs are rejected. A known key is also used
+// while the provider can't be reached.
+func (s *Set) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	fresh := time.Since(s.fetched) < s.refresh
+	throttled := time.Since(s.attempted) < minRefetch
+	s.mu.Unlock()
+
+	if ok && (fresh || throttled) {
+		return key, nil
+	}
+	if !ok && throttled {
+		return nil, ErrUnknownKey
+	}
+	keys, err := s.refetch(ctx)
+	if err != nil {
+		if ok {
+			// the provider is unreachable, keep using the known key
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok = keys[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refetch fetches the set and keeps it, once for all callers asking at the
+// same time. The fetch goes on if the caller that started it gives up.
+func (s *Set) refetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	result := s.group.DoChan("", func() (any, error) {
+		s.mu.Lock()
+		s.attempted = time.Now()
+		s.mu.Unlock()
+
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+		defer cancel()
+		keys, err := s.fetch(fetchCtx)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.keys, s.fetched = keys, time.Now()
+		s.mu.Unlock()
+		return keys, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(map[string]crypto.PublicKey), nil
+	}
+}
+
+// jwk is a JSON Web Key, only the members of the supported key types
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *Set) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key set: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key set endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxSetSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode key set: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// keys of unsupported types are skipped, providers may publish others
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
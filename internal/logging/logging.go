@@ -0,0 +1,184 @@
+// Package logging configures the zerolog loggers of the facilitator: the level
+// and format of the global logger, sampling of debug lines and the levels of
+// subsystems that log more or less than the rest.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Subsystems whose level can be configured apart from the global level
+const (
+	HTTP       = "http"
+	RPC        = "rpc"
+	Settlement = "settlement"
+	Indexer    = "indexer"
+	Balance    = "balance"
+	Leader     = "leader"
+	Assets     = "assets"
+	// startup and shutdown of the facilitator and the command line tools
+	Server = "server"
+)
+
+// Subsystems lists the subsystems in the order they are documented
+var Subsystems = []string{HTTP, RPC, Settlement, Indexer, Balance, Leader, Assets, Server}
+
+// Formats of log lines
+const (
+	// FormatAuto writes console lines to terminals and JSON otherwise
+	FormatAuto    = "auto"
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+const (
+	defaultLevel          = zerolog.InfoLevel
+	defaultSamplingPeriod = time.Second
+)
+
+// Config configures logging.
+type Config struct {
+	// trace, debug, info, warn or error, info if empty
+	Level string `mapstructure:"level"`
+	// "auto", "json" or "console", auto if empty
+	Format string `mapstructure:"format"`
+	// Whether lines carry the file and line they were logged at
+	Caller bool `mapstructure:"caller"`
+	// Sampling of debug and trace lines
+	Sampling SamplingConfig `mapstructure:"sampling"`
+	// Levels of subsystems overriding Level, e.g. { rpc = "debug", http = "warn" }
+	Subsystems map[string]string `mapstructure:"subsystems"`
+}
+
+// SamplingConfig limits the debug and trace lines logged per period, lines
+// beyond the burst are dropped. Lines of other levels are never sampled.
+type SamplingConfig struct {
+	// Debug and trace lines logged per period, 0 logs all of them
+	Burst uint32 `mapstructure:"burst"`
+	// Length of a period, 0 means a second
+	Period time.Duration `mapstructure:"period"`
+}
+
+// levels maps subsystems to their level, including the global level under ""
+var levels atomic.Pointer[map[string]zerolog.Level]
+
+// Setup replaces the global logger by one configured by config and writing to
+// out, and applies the levels of the subsystems.
+func Setup(config Config, out *os.File) error {
+	logger, err := New(config, out, isatty.IsTerminal(out.Fd()))
+	if err != nil {
+		return err
+	}
+	log.Logger = logger
+	return nil
+}
+
+// New returns a logger configured by config and writing to w, whose format is
+// the console format if auto and terminal is true. It applies the levels of
+// the subsystems and lowers the global level of zerolog as far as they need.
+func New(config Config, w io.Writer, terminal bool) (zerolog.Logger, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return zerolog.Nop(), err
+	}
+	configured := map[string]zerolog.Level{"": level}
+	lowest := level
+	for subsystem, value := range config.Subsystems {
+		l, err := ParseLevel(value)
+		if err != nil {
+			return zerolog.Nop(), fmt.Errorf("subsystem %s: %w", subsystem, err)
+		}
+		configured[subsystem] = l
+		lowest = min(lowest, l)
+	}
+
+	switch config.Format {
+	case "", FormatAuto:
+		if terminal {
+			w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+		}
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339, NoColor: !terminal}
+	case FormatJSON:
+	default:
+		return zerolog.Nop(), fmt.Errorf("unknown format %q", config.Format)
+	}
+
+	ctx := zerolog.New(w).Level(level).With().Timestamp()
+	if config.Caller {
+		ctx = ctx.Caller()
+	}
+	logger := ctx.Logger()
+	if config.Sampling.Burst > 0 {
+		sampler := &zerolog.BurstSampler{
+			Burst:  config.Sampling.Burst,
+			Period: config.Sampling.Period,
+		}
+		if sampler.Period == 0 {
+			sampler.Period = defaultSamplingPeriod
+		}
+		logger = logger.Sample(zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler})
+	}
+
+	// subsystems may log below the level of the global logger
+	zerolog.SetGlobalLevel(lowest)
+	levels.Store(&configured)
+	return logger, nil
+}
+
+// ParseLevel parses a level name, an empty name is the default level.
+func ParseLevel(name string) (zerolog.Level, error) {
+	if name == "" {
+		return defaultLevel, nil
+	}
+	level, err := zerolog.ParseLevel(name)
+	if err != nil || level == zerolog.NoLevel {
+		return zerolog.NoLevel, fmt.Errorf("unknown level %q", name)
+	}
+	return level, nil
+}
+
+// Level returns the level of the subsystem, the global level if it has none.
+func Level(subsystem string) zerolog.Level {
+	configured := levels.Load()
+	if configured == nil {
+		return log.Logger.GetLevel()
+	}
+	if level, ok := (*configured)[subsystem]; ok {
+		return level
+	}
+	return (*configured)[""]
+}
+
+// For returns the global logger at the level of the subsystem.
+func For(subsystem string) *zerolog.Logger {
+	logger := log.Logger.Level(Level(subsystem))
+	return &logger
+}
+
+// Ctx returns the logger of ctx, the global logger if it has none, at the
+// level of the subsystem. The fields of the context logger, e.g. the payment
+// metadata, are kept.
+func Ctx(ctx context.Context, subsystem string) *zerolog.Logger {
+	leveled := FromContext(ctx).Level(Level(subsystem))
+	return &leveled
+}
+
+// FromContext returns the logger of ctx, the global logger if it has none,
+// for attaching fields to the context without changing its level.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		return &log.Logger
+	}
+	return logger
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+// setup configures the global logger writing to the returned buffer, restored when the test ends.
+func setup(t *testing.T, config Config) *bytes.Buffer {
+	t.Helper()
+	global, level := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = global
+		zerolog.SetGlobalLevel(level)
+		levels.Store(nil)
+	})
+
+	var out bytes.Buffer
+	logger, err := New(config, &out, false)
+	require.NoError(t, err)
+	log.Logger = logger
+	return &out
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	out := setup(t, Config{Level: "warn", Subsystems: map[string]string{RPC: "debug", HTTP: "error"}})
+
+	log.Info().Msg("global info")
+	For(RPC).Debug().Msg("rpc debug")
+	For(HTTP).Warn().Msg("http warn")
+	For(Settlement).Warn().Msg("settlement warn")
+	ctx := log.With().Str("request_id", "r1").Logger().WithContext(t.Context())
+	Ctx(ctx, RPC).Debug().Msg("rpc debug with context")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], `"message":"rpc debug"`)
+	require.Contains(t, lines[1], `"message":"settlement warn"`)
+	require.Contains(t, lines[2], `"request_id":"r1"`)
+	require.Equal(t, zerolog.DebugLevel, zerolog.GlobalLevel(), "lowered to the most verbose subsystem")
+}
+
+func TestSampling(t *testing.T) {
+	out := setup(t, Config{Level: "debug", Sampling: SamplingConfig{Burst: 2}})
+
+	for range 5 {
+		log.Debug().Msg("debug")
+		log.Info().Msg("info")
+	}
+	require.Equal(t, 2, strings.Count(out.String(), `"message":"debug"`))
+	require.Equal(t, 5, strings.Count(out.String(), `"message":"info"`), "only debug lines are sampled")
+}
+
+func TestFormat(t *testing.T) {
+	out := setup(t, Config{Format: FormatConsole, Caller: true})
+	log.Info().Msg("hello")
+	require.Contains(t, out.String(), "INF")
+	require.Contains(t, out.String(), "logging_test.go")
+	require.NotContains(t, out.String(), "{")
+
+	_, err := New(Config{Format: "xml"}, &bytes.Buffer{}, false)
+	require.ErrorContains(t, err, `unknown format "xml"`)
+	_, err = New(Config{Subsystems: map[string]string{RPC: "verbose"}}, &bytes.Buffer{}, false)
+	require.ErrorContains(t, err, `subsystem rpc: unknown level "verbose"`)
+}
+
+func TestFromContext(t *testing.T) {
+	out := setup(t, Config{Level: "warn", Subsystems: map[string]string{RPC: "debug"}})
+
+	require.Same(t, &log.Logger, FromContext(t.Context()), "the global logger without a context logger")
+	ctx := log.With().Str("request_id", "r1").Logger().WithContext(t.Context())
+	FromContext(ctx).Warn().Msg("with context")
+	FromContext(ctx).Info().Msg("dropped at the global level")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"request_id":"r1"`)
+}
@@ -0,0 +1,924 @@
+// Package mock provides scriptable chain backends for tests that must not
+// depend on live RPC endpoints.
+package mock
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+var _ sdk.FacilitatorEvmSigner = (*EVMSigner)(nil)
+
+// ErrReverted is returned by EstimateGas for calls that would revert.
+var ErrReverted = errors.New("execution reverted")
+
+// Fault is injected into the calls of a signer method.
+type Fault struct {
+	// Latency delays the call, honoring context cancellation
+	Latency time.Duration
+	// Err fails the call after the latency has passed
+	Err error
+	// Times limits the fault to the next n calls, 0 applies it to every call
+	Times int
+}
+
+// EVMSigner is an in-memory EVM chain with an ERC-20 token per contract
+// address. It implements the facilitator signer interface and can be scripted
+// to be slow, fail, revert transactions and reorganize blocks.
+//
+// Transactions are mined into a new block on submission unless auto mining is
+// disabled with SetAutoMine, in which case they stay pending until Mine is called.
+type EVMSigner struct {
+	mu sync.Mutex
+
+	address  string
+	chainID  *big.Int
+	gasPrice *big.Int
+	gasUsed  uint64
+	autoMine bool
+
+	head      uint64
+	headTime  time.Time
+	balances  map[string]*big.Int // by token and holder address
+	code      map[string][]byte
+	usedNonce map[string]bool     // EIP-3009 authorization nonces by token and payer
+	permits   map[string]uint64   // EIP-2612 nonces by token and owner
+	allowance map[string]*big.Int // by token, owner and spender
+	frozen    map[string]bool     // blacklisted holders by token and holder
+	nonces    map[string]uint64   // account nonces by lower-case address, counting pending transactions
+	results   map[string]any      // results of other view functions by contract and function
+	fees      map[string]int64    // transfer fees in basis points by token
+
+	txs     map[string]*transaction
+	pending []*transaction
+	reverts []string
+	silent  int // transfers that fail without reverting
+	faults  map[string]*Fault
+	calls   map[string]int
+
+	// changed is closed and replaced whenever the chain state changes
+	changed chan struct{}
+}
+
+type transaction struct {
+	hash     string
+	block    uint64 // 0 while pending
+	status   uint64
+	gasPrice *big.Int
+	apply    func() bool // executes the transaction, false if it reverts
+	logs     []*ethTypes.Log
+
+	// a transaction re-included after a reorg keeps the outcome of its first execution
+	executed bool
+	result   uint64
+}
+
+// NewEVMSigner creates a chain with the given ID whose head is block 1.
+func NewEVMSigner(chainID int64, address string) *EVMSigner {
+	return &EVMSigner{
+		address:   common.HexToAddress(address).Hex(),
+		chainID:   big.NewInt(chainID),
+		gasPrice:  big.NewInt(1_000_000_000),
+		gasUsed:   60_000,
+		autoMine:  true,
+		head:      1,
+		headTime:  time.Now(),
+		balances:  make(map[string]*big.Int),
+		code:      make(map[string][]byte),
+		usedNonce: make(map[string]bool),
+		permits:   make(map[string]uint64),
+		allowance: make(map[string]*big.Int),
+		frozen:    make(map[string]bool),
+		nonces:    make(map[string]uint64),
+		results:   make(map[string]any),
+		fees:      make(map[string]int64),
+		txs:       make(map[string]*transaction),
+		faults:    make(map[string]*Fault),
+		calls:     make(map[string]int),
+		changed:   make(chan struct{}),
+	}
+}
+
+// SetAutoMine controls whether submitted transactions are mined immediately.
+func (s *EVMSigner) SetAutoMine(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.autoMine = enabled
+}
+
+// SetBalance sets the token balance of the holder, its native balance if token is empty.
+func (s *EVMSigner) SetBalance(token, holder string, amount *big.Int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[balanceKey(token, holder)] = new(big.Int).Set(amount)
+}
+
+// SetAllowance sets how much of the token of owner the spender may transfer.
+func (s *EVMSigner) SetAllowance(token string, owner, spender common.Address, amount *big.Int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowance[allowanceKey(token, owner, spender)] = new(big.Int).Set(amount)
+}
+
+// Balance returns the token balance of the holder.
+func (s *EVMSigner) Balance(token, holder string) *big.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance(token, holder)
+}
+
+// SetTransferFee makes transferWithAuthorization of the token keep a fee of
+// bps basis points of the value, credited to the token contract.
+func (s *EVMSigner) SetTransferFee(token string, bps int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fees[strings.ToLower(token)] = bps
+}
+
+// SetCode deploys bytecode at the address, making it a contract account.
+func (s *EVMSigner) SetCode(address string, code []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code[strings.ToLower(address)] = code
+}
+
+// SetGasPrice sets the gas price reported to the facilitator.
+func (s *EVMSigner) SetGasPrice(price *big.Int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gasPrice = new(big.Int).Set(price)
+}
+
+// Freeze blacklists the holder of the token, transfers from and to it revert.
+func (s *EVMSigner) Freeze(token, holder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frozen[balanceKey(token, holder)] = true
+}
+
+// SetResult sets what calls of a view function of the contract the chain
+// doesn't model return, like the decoded output of a real call.
+func (s *EVMSigner) SetResult(address, functionName string, result any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[strings.ToLower(address)+":"+functionName] = result
+}
+
+// RevertNext makes the next submitted transaction revert with the reason.
+func (s *EVMSigner) RevertNext(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reverts = append(s.reverts, reason)
+}
+
+// FailSilentlyNext makes the next transferWithAuthorization succeed without
+// moving funds or emitting a Transfer event, like tokens returning false
+// instead of reverting.
+func (s *EVMSigner) FailSilentlyNext() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silent++
+}
+
+// Inject applies the fault to the calls of the named method (e.g. "WriteContract").
+func (s *EVMSigner) Inject(method string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[method] = &fault
+}
+
+// Calls returns how often the named method was called.
+func (s *EVMSigner) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// Head returns the number of the latest block.
+func (s *EVMSigner) Head() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.head
+}
+
+// Mine produces n blocks, the first one including all pending transactions.
+func (s *EVMSigner) Mine(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.mineBlock()
+	}
+	s.notify()
+}
+
+// Reorg drops the latest depth blocks and replaces them with as many empty
+// blocks. Transactions of the dropped blocks return to the pending pool and
+// are included again by the next mined block.
+func (s *EVMSigner) Reorg(depth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fork := s.head - uint64(depth)
+	for _, tx := range s.txs {
+		if tx.block > fork {
+			tx.block, tx.status = 0, 0
+			s.pending = append(s.pending, tx)
+		}
+	}
+	s.head = fork
+	for range depth {
+		s.head++
+		s.headTime = s.headTime.Add(time.Second)
+	}
+	s.notify()
+}
+
+func (s *EVMSigner) GetAddresses() []string {
+	return []string{s.address}
+}
+
+func (s *EVMSigner) ReadContract(ctx context.Context, address string, abi []byte, functionName string, args ...any) (any, error) {
+	if err := s.enter(ctx, "ReadContract"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch functionName {
+	case "balanceOf":
+		holder, ok := argAddress(args, 0)
+		if !ok {
+			return nil, fmt.Errorf("balanceOf: invalid arguments %v", args)
+		}
+		return s.balance(address, holder.Hex()), nil
+	case "authorizationState":
+		payer, ok := argAddress(args, 0)
+		nonce, ok2 := argNonce(args, 1)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("authorizationState: invalid arguments %v", args)
+		}
+		return s.usedNonce[nonceKey(address, payer, nonce)], nil
+	case "nonces":
+		owner, ok := argAddress(args, 0)
+		if !ok {
+			return nil, fmt.Errorf("nonces: invalid arguments %v", args)
+		}
+		return new(big.Int).SetUint64(s.permits[balanceKey(address, owner.Hex())]), nil
+	case "allowance":
+		owner, ok := argAddress(args, 0)
+		spender, ok2 := argAddress(args, 1)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("allowance: invalid arguments %v", args)
+		}
+		return s.allowanceOf(address, owner, spender), nil
+	default:
+		if result, ok := s.results[strings.ToLower(address)+":"+functionName]; ok {
+			return result, nil
+		}
+		return nil, fmt.Errorf("mock: unsupported read %s", functionName)
+	}
+}
+
+// VerifyTypedData verifies EOA signatures over the EIP-712 hash, like a real node would.
+func (s *EVMSigner) VerifyTypedData(ctx context.Context, address string, domain sdk.TypedDataDomain, types map[string][]sdk.TypedDataField, primaryType string, message map[string]any, signature []byte) (bool, error) {
+	if err := s.enter(ctx, "VerifyTypedData"); err != nil {
+		return false, err
+	}
+	digest, err := evm.HashTypedData(evm.NewTypedData(domain, types, primaryType, message))
+	if err != nil {
+		return false, err
+	}
+	return sdk.VerifyEOASignature(digest, signature, common.HexToAddress(address))
+}
+
+// WriteContract submits a transaction. transferWithAuthorization moves token
+// balances and consumes the authorization nonce, permit approves the spender
+// and consumes the nonce of the owner without checking the signature,
+// transferFrom spends the allowance of the signer, transfer moves tokens of the
+// signer and splitWithAuthorization pays the recipients of a splitter contract
+// at address; other functions only succeed.
+func (s *EVMSigner) WriteContract(ctx context.Context, address string, abi []byte, functionName string, args ...any) (string, error) {
+	if err := s.enter(ctx, "WriteContract"); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apply := func() bool { return true }
+	var logs []*ethTypes.Log
+	if functionName == "transferWithAuthorization" && s.silent > 0 {
+		s.silent--
+	} else if functionName == "transferWithAuthorization" {
+		from, ok1 := argAddress(args, 0)
+		to, ok2 := argAddress(args, 1)
+		value, ok3 := argBigInt(args, 2)
+		nonce, ok4 := argNonce(args, 5)
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return "", fmt.Errorf("transferWithAuthorization: invalid arguments %v", args)
+		}
+		fee := s.transferFee(address, value)
+		apply = func() bool {
+			if s.transferRevert(address, from, value, nonce) != nil {
+				return false
+			}
+			key := nonceKey(address, from, nonce)
+			balance := s.balance(address, from.Hex())
+			s.usedNonce[key] = true
+			s.balances[balanceKey(address, from.Hex())] = new(big.Int).Sub(balance, value)
+			s.balances[balanceKey(address, to.Hex())] = new(big.Int).Add(s.balance(address, to.Hex()), new(big.Int).Sub(value, fee))
+			s.balances[balanceKey(address, address)] = new(big.Int).Add(s.balance(address, address), fee)
+			return true
+		}
+		logs = transferWithFeeLogs(address, from, to, value, fee)
+	} else if functionName == "permit" {
+		owner, ok1 := argAddress(args, 0)
+		spender, ok2 := argAddress(args, 1)
+		value, ok3 := argBigInt(args, 2)
+		if !ok1 || !ok2 || !ok3 {
+			return "", fmt.Errorf("permit: invalid arguments %v", args)
+		}
+		apply = func() bool {
+			s.permits[balanceKey(address, owner.Hex())]++
+			s.allowance[allowanceKey(address, owner, spender)] = new(big.Int).Set(value)
+			return true
+		}
+	} else if functionName == "transferFrom" {
+		from, ok1 := argAddress(args, 0)
+		to, ok2 := argAddress(args, 1)
+		value, ok3 := argBigInt(args, 2)
+		if !ok1 || !ok2 || !ok3 {
+			return "", fmt.Errorf("transferFrom: invalid arguments %v", args)
+		}
+		spender := common.HexToAddress(s.address)
+		apply = func() bool {
+			if s.transferFromRevert(address, from, spender, value) != nil {
+				return false
+			}
+			key := allowanceKey(address, from, spender)
+			s.allowance[key] = new(big.Int).Sub(s.allowanceOf(address, from, spender), value)
+			s.balances[balanceKey(address, from.Hex())] = new(big.Int).Sub(s.balance(address, from.Hex()), value)
+			s.balances[balanceKey(address, to.Hex())] = new(big.Int).Add(s.balance(address, to.Hex()), value)
+			return true
+		}
+		logs = []*ethTypes.Log{transferLog(address, from, to, value)}
+	} else if functionName == "transfer" {
+		to, ok1 := argAddress(args, 0)
+		value, ok2 := argBigInt(args, 1)
+		if !ok1 || !ok2 {
+			return "", fmt.Errorf("transfer: invalid arguments %v", args)
+		}
+		from := common.HexToAddress(s.address)
+		apply = func() bool {
+			if s.plainTransferRevert(address, from, to, value) != nil {
+				return false
+			}
+			s.balances[balanceKey(address, from.Hex())] = new(big.Int).Sub(s.balance(address, from.Hex()), value)
+			s.balances[balanceKey(address, to.Hex())] = new(big.Int).Add(s.balance(address, to.Hex()), value)
+			return true
+		}
+		logs = []*ethTypes.Log{transferLog(address, from, to, value)}
+	} else if functionName == "splitWithAuthorization" {
+		token, from, value, nonce, recipients, amounts, err := splitArgs(args)
+		if err != nil {
+			return "", err
+		}
+		splitter := common.HexToAddress(address)
+		apply = func() bool {
+			if s.splitRevert(token, from, value, nonce, recipients) != nil {
+				return false
+			}
+			s.usedNonce[nonceKey(token, from, nonce)] = true
+			s.balances[balanceKey(token, from.Hex())] = new(big.Int).Sub(s.balance(token, from.Hex()), value)
+			for i, recipient := range recipients {
+				s.balances[balanceKey(token, recipient.Hex())] = new(big.Int).Add(s.balance(token, recipient.Hex()), amounts[i])
+			}
+			return true
+		}
+		logs = []*ethTypes.Log{transferLog(token, from, splitter, value)}
+		for i, recipient := range recipients {
+			logs = append(logs, transferLog(token, splitter, recipient, amounts[i]))
+		}
+	}
+	return s.submit(apply, logs...), nil
+}
+
+// SimulateContract runs transferWithAuthorization, transferFrom, transfer and
+// splitWithAuthorization against the current state without changing it,
+// reverting like the token would.
+// Transactions scripted to revert still pass, they only revert on chain.
+func (s *EVMSigner) SimulateContract(ctx context.Context, address string, abi []byte, functionName string, args ...any) error {
+	if err := s.enter(ctx, "SimulateContract"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if functionName == "transferFrom" {
+		from, ok1 := argAddress(args, 0)
+		value, ok2 := argBigInt(args, 2)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("transferFrom: invalid arguments %v", args)
+		}
+		if err := s.transferFromRevert(address, from, common.HexToAddress(s.address), value); err != nil {
+			return err
+		}
+		return nil
+	}
+	switch functionName {
+	case "transfer":
+		to, ok1 := argAddress(args, 0)
+		value, ok2 := argBigInt(args, 1)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("transfer: invalid arguments %v", args)
+		}
+		if err := s.plainTransferRevert(address, common.HexToAddress(s.address), to, value); err != nil {
+			return err
+		}
+		return nil
+	case "splitWithAuthorization":
+		token, from, value, nonce, recipients, _, err := splitArgs(args)
+		if err != nil {
+			return err
+		}
+		if err := s.splitRevert(token, from, value, nonce, recipients); err != nil {
+			return err
+		}
+		return nil
+	}
+	if functionName != "transferWithAuthorization" {
+		return nil
+	}
+	from, ok1 := argAddress(args, 0)
+	value, ok2 := argBigInt(args, 2)
+	nonce, ok3 := argNonce(args, 5)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("transferWithAuthorization: invalid arguments %v", args)
+	}
+	if err := s.transferRevert(address, from, value, nonce); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SimulateLogs runs transferWithAuthorization against the current state
+// without changing it and returns the Transfer events it emits, reverting like
+// SimulateContract. Other calls emit no events.
+func (s *EVMSigner) SimulateLogs(ctx context.Context, address string, abi []byte, functionName string, args ...any) ([]*ethTypes.Log, error) {
+	if err := s.enter(ctx, "SimulateLogs"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if functionName != "transferWithAuthorization" {
+		return nil, nil
+	}
+	from, ok1 := argAddress(args, 0)
+	to, ok2 := argAddress(args, 1)
+	value, ok3 := argBigInt(args, 2)
+	nonce, ok4 := argNonce(args, 5)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("transferWithAuthorization: invalid arguments %v", args)
+	}
+	if err := s.transferRevert(address, from, value, nonce); err != nil {
+		return nil, err
+	}
+	return transferWithFeeLogs(address, from, to, value, s.transferFee(address, value)), nil
+}
+
+// transferFee returns the fee the token keeps of a transfer of value.
+func (s *EVMSigner) transferFee(token string, value *big.Int) *big.Int {
+	fee := new(big.Int).Mul(value, big.NewInt(s.fees[strings.ToLower(token)]))
+	return fee.Quo(fee, big.NewInt(10_000))
+}
+
+// transferWithFeeLogs returns the Transfer events of a transfer of value of
+// which the token keeps the fee.
+func transferWithFeeLogs(token string, from, to common.Address, value, fee *big.Int) []*ethTypes.Log {
+	if fee.Sign() == 0 {
+		return []*ethTypes.Log{transferLog(token, from, to, value)}
+	}
+	return []*ethTypes.Log{
+		transferLog(token, from, to, new(big.Int).Sub(value, fee)),
+		transferLog(token, from, common.HexToAddress(token), fee),
+	}
+}
+
+// transferRevert returns the revert of a transferWithAuthorization call, nil if it succeeds.
+func (s *EVMSigner) transferRevert(token string, from common.Address, value *big.Int, nonce [32]byte) *evm.RevertError {
+	if s.usedNonce[nonceKey(token, from, nonce)] {
+		return &evm.RevertError{Reason: "FiatTokenV2: authorization is used or canceled"}
+	}
+	if s.balance(token, from.Hex()).Cmp(value) < 0 {
+		return &evm.RevertError{Reason: "ERC20: transfer amount exceeds balance"}
+	}
+	return nil
+}
+
+// plainTransferRevert returns the revert of a transfer call of the holder from, nil if it succeeds.
+func (s *EVMSigner) plainTransferRevert(token string, from, to common.Address, value *big.Int) *evm.RevertError {
+	if s.frozen[balanceKey(token, from.Hex())] || s.frozen[balanceKey(token, to.Hex())] {
+		return &evm.RevertError{Reason: "Blacklistable: account is blacklisted"}
+	}
+	if s.balance(token, from.Hex()).Cmp(value) < 0 {
+		return &evm.RevertError{Reason: "ERC20: transfer amount exceeds balance"}
+	}
+	return nil
+}
+
+// splitRevert returns the revert of a splitWithAuthorization call, nil if it succeeds.
+func (s *EVMSigner) splitRevert(token string, from common.Address, value *big.Int, nonce [32]byte, recipients []common.Address) *evm.RevertError {
+	if err := s.transferRevert(token, from, value, nonce); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if s.frozen[balanceKey(token, recipient.Hex())] {
+			return &evm.RevertError{Reason: "Blacklistable: account is blacklisted"}
+		}
+	}
+	return nil
+}
+
+// transferFromRevert returns the revert of a transferFrom call of the spender, nil if it succeeds.
+func (s *EVMSigner) transferFromRevert(token string, from, spender common.Address, value *big.Int) *evm.RevertError {
+	if s.allowanceOf(token, from, spender).Cmp(value) < 0 {
+		return &evm.RevertError{Reason: "ERC20: insufficient allowance"}
+	}
+	if s.balance(token, from.Hex()).Cmp(value) < 0 {
+		return &evm.RevertError{Reason: "ERC20: transfer amount exceeds balance"}
+	}
+	return nil
+}
+
+func (s *EVMSigner) SendTransaction(ctx context.Context, to string, data []byte) (string, error) {
+	if err := s.enter(ctx, "SendTransaction"); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.submit(func() bool { return true }), nil
+}
+
+// PendingNonceAt returns the next nonce of the account, counting transactions
+// submitted with SendRawTransaction.
+func (s *EVMSigner) PendingNonceAt(ctx context.Context, address string) (uint64, error) {
+	if err := s.enter(ctx, "PendingNonceAt"); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonces[strings.ToLower(address)], nil
+}
+
+// SendRawTransaction submits a native transfer signed by another account,
+// rejecting it like a node would if its nonce is not the next one of the sender
+// or the sender can't pay the value. Gas is free.
+func (s *EVMSigner) SendRawTransaction(ctx context.Context, tx *ethTypes.Transaction) (string, error) {
+	if err := s.enter(ctx, "SendRawTransaction"); err != nil {
+		return "", err
+	}
+	from, err := ethTypes.Sender(ethTypes.LatestSignerForChainID(s.chainID), tx)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender: %w", err)
+	}
+	if tx.To() == nil {
+		return "", fmt.Errorf("contract creation is not supported")
+	}
+	to := *tx.To()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(from.Hex())
+	switch {
+	case tx.Nonce() < s.nonces[key]:
+		return "", fmt.Errorf("nonce too low: next nonce %d, tx nonce %d", s.nonces[key], tx.Nonce())
+	case tx.Nonce() > s.nonces[key]:
+		return "", fmt.Errorf("nonce too high: next nonce %d, tx nonce %d", s.nonces[key], tx.Nonce())
+	case s.balance("", from.Hex()).Cmp(tx.Value()) < 0:
+		return "", fmt.Errorf("insufficient funds for gas * price + value")
+	}
+	s.nonces[key]++
+
+	value := tx.Value()
+	apply := func() bool {
+		if s.balance("", from.Hex()).Cmp(value) < 0 {
+			return false
+		}
+		s.balances[balanceKey("", from.Hex())] = new(big.Int).Sub(s.balance("", from.Hex()), value)
+		s.balances[balanceKey("", to.Hex())] = new(big.Int).Add(s.balance("", to.Hex()), value)
+		return true
+	}
+	return s.submitHash(tx.Hash(), apply), nil
+}
+
+// WaitForTransactionReceipt blocks until the transaction is mined.
+func (s *EVMSigner) WaitForTransactionReceipt(ctx context.Context, txHash string) (*sdk.TransactionReceipt, error) {
+	if err := s.enter(ctx, "WaitForTransactionReceipt"); err != nil {
+		return nil, err
+	}
+	for {
+		s.mu.Lock()
+		tx, ok := s.txs[strings.ToLower(txHash)]
+		if !ok {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("transaction %s not found", txHash)
+		}
+		if tx.block != 0 {
+			receipt := &sdk.TransactionReceipt{
+				Status:      tx.status,
+				BlockNumber: tx.block,
+				TxHash:      tx.hash,
+			}
+			s.mu.Unlock()
+			return receipt, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (s *EVMSigner) GetBalance(ctx context.Context, address string, tokenAddress string) (*big.Int, error) {
+	if err := s.enter(ctx, "GetBalance"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance(tokenAddress, address), nil
+}
+
+func (s *EVMSigner) GetChainID(ctx context.Context) (*big.Int, error) {
+	if err := s.enter(ctx, "GetChainID"); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(s.chainID), nil
+}
+
+func (s *EVMSigner) GetCode(ctx context.Context, address string) ([]byte, error) {
+	if err := s.enter(ctx, "GetCode"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.code[strings.ToLower(address)], nil
+}
+
+// EstimateGas returns a fixed gas amount, or ErrReverted if the next transaction is scripted to revert.
+func (s *EVMSigner) EstimateGas(ctx context.Context, address string, abi []byte, functionName string, args ...any) (uint64, error) {
+	if err := s.enter(ctx, "EstimateGas"); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.reverts) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrReverted, s.reverts[0])
+	}
+	return s.gasUsed, nil
+}
+
+func (s *EVMSigner) GasPrice(ctx context.Context) (*big.Int, error) {
+	if err := s.enter(ctx, "GasPrice"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return new(big.Int).Set(s.gasPrice), nil
+}
+
+// TransactionReceipt returns the receipt of a mined transaction, which always used the fixed gas amount.
+func (s *EVMSigner) TransactionReceipt(ctx context.Context, txHash string) (*ethTypes.Receipt, error) {
+	if err := s.enter(ctx, "TransactionReceipt"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.txs[strings.ToLower(txHash)]
+	if !ok || tx.block == 0 {
+		return nil, ethereum.NotFound
+	}
+	receipt := &ethTypes.Receipt{
+		Status:            tx.status,
+		TxHash:            common.HexToHash(tx.hash),
+		BlockNumber:       new(big.Int).SetUint64(tx.block),
+		GasUsed:           s.gasUsed,
+		EffectiveGasPrice: new(big.Int).Set(tx.gasPrice),
+	}
+	if tx.status == ethTypes.ReceiptStatusSuccessful {
+		for _, l := range tx.logs {
+			receipt.Logs = append(receipt.Logs, &ethTypes.Log{
+				Address:     l.Address,
+				Topics:      l.Topics,
+				Data:        l.Data,
+				BlockNumber: tx.block,
+				TxHash:      receipt.TxHash,
+			})
+		}
+	}
+	return receipt, nil
+}
+
+func (s *EVMSigner) BlockNumber(ctx context.Context) (uint64, error) {
+	if err := s.enter(ctx, "BlockNumber"); err != nil {
+		return 0, err
+	}
+	return s.Head(), nil
+}
+
+func (s *EVMSigner) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	if err := s.enter(ctx, "HeaderByNumber"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if number != nil && number.Uint64() != s.head {
+		return nil, fmt.Errorf("mock: only the latest header is available")
+	}
+	return &ethTypes.Header{
+		Number: new(big.Int).SetUint64(s.head),
+		Time:   uint64(s.headTime.Unix()),
+	}, nil
+}
+
+// enter records the call and applies the injected fault of the method.
+func (s *EVMSigner) enter(ctx context.Context, method string) error {
+	s.mu.Lock()
+	s.calls[method]++
+	fault, ok := s.faults[method]
+	var current Fault
+	if ok {
+		current = *fault
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				delete(s.faults, method)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if current.Latency > 0 {
+		timer := time.NewTimer(current.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return current.Err
+}
+
+// submit adds a transaction to the pool and mines it if auto mining is enabled.
+// The logs are emitted if the transaction succeeds. The caller must hold the lock.
+func (s *EVMSigner) submit(apply func() bool, logs ...*ethTypes.Log) string {
+	var hash common.Hash
+	rand.Read(hash[:])
+	return s.submitHash(hash, apply, logs...)
+}
+
+// submitHash submits a transaction with the given hash. The caller must hold the lock.
+func (s *EVMSigner) submitHash(hash common.Hash, apply func() bool, logs ...*ethTypes.Log) string {
+	tx := &transaction{
+		hash:     hash.Hex(),
+		gasPrice: new(big.Int).Set(s.gasPrice),
+		apply:    apply,
+		logs:     logs,
+	}
+	if len(s.reverts) > 0 {
+		s.reverts = s.reverts[1:]
+		tx.apply = func() bool { return false }
+	}
+	s.txs[strings.ToLower(tx.hash)] = tx
+	s.pending = append(s.pending, tx)
+	if s.autoMine {
+		s.mineBlock()
+	}
+	s.notify()
+	return tx.hash
+}
+
+// mineBlock produces a block including all pending transactions. The caller must hold the lock.
+func (s *EVMSigner) mineBlock() {
+	s.head++
+	s.headTime = s.headTime.Add(time.Second)
+	for _, tx := range s.pending {
+		if !tx.executed {
+			tx.executed = true
+			tx.result = ethTypes.ReceiptStatusFailed
+			if tx.apply() {
+				tx.result = ethTypes.ReceiptStatusSuccessful
+			}
+		}
+		tx.block, tx.status = s.head, tx.result
+	}
+	s.pending = nil
+}
+
+// notify wakes up all waiters. The caller must hold the lock.
+func (s *EVMSigner) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *EVMSigner) balance(token, holder string) *big.Int {
+	if balance, ok := s.balances[balanceKey(token, holder)]; ok {
+		return new(big.Int).Set(balance)
+	}
+	return new(big.Int)
+}
+
+// transferTopic is the topic of ERC-20 Transfer events
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// transferLog returns the Transfer event of a token transfer.
+func transferLog(token string, from, to common.Address, value *big.Int) *ethTypes.Log {
+	return &ethTypes.Log{
+		Address: common.HexToAddress(token),
+		Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:    common.LeftPadBytes(value.Bytes(), 32),
+	}
+}
+
+func (s *EVMSigner) allowanceOf(token string, owner, spender common.Address) *big.Int {
+	if allowance, ok := s.allowance[allowanceKey(token, owner, spender)]; ok {
+		return new(big.Int).Set(allowance)
+	}
+	return new(big.Int)
+}
+
+func allowanceKey(token string, owner, spender common.Address) string {
+	return strings.ToLower(token) + "/" + strings.ToLower(owner.Hex()) + "/" + strings.ToLower(spender.Hex())
+}
+
+func balanceKey(token, holder string) string {
+	return strings.ToLower(token) + "/" + strings.ToLower(holder)
+}
+
+func nonceKey(token string, payer common.Address, nonce [32]byte) string {
+	return strings.ToLower(token) + "/" + strings.ToLower(payer.Hex()) + "/" + common.Bytes2Hex(nonce[:])
+}
+
+func argAddress(args []any, i int) (common.Address, bool) {
+	if i >= len(args) {
+		return common.Address{}, false
+	}
+	switch v := args[i].(type) {
+	case common.Address:
+		return v, true
+	case string:
+		return common.HexToAddress(v), common.IsHexAddress(v)
+	}
+	return common.Address{}, false
+}
+
+func argBigInt(args []any, i int) (*big.Int, bool) {
+	if i >= len(args) {
+		return nil, false
+	}
+	v, ok := args[i].(*big.Int)
+	return v, ok
+}
+
+func argNonce(args []any, i int) ([32]byte, bool) {
+	if i >= len(args) {
+		return [32]byte{}, false
+	}
+	v, ok := args[i].([32]byte)
+	return v, ok
+}
+
+// splitArgs decodes the arguments of splitWithAuthorization.
+func splitArgs(args []any) (token string, from common.Address, value *big.Int, nonce [32]byte, recipients []common.Address, amounts []*big.Int, err error) {
+	tokenAddress, ok1 := argAddress(args, 0)
+	from, ok2 := argAddress(args, 1)
+	value, ok3 := argBigInt(args, 2)
+	nonce, ok4 := argNonce(args, 5)
+	ok5, ok6 := len(args) == 9, false
+	if ok5 {
+		recipients, ok5 = args[7].([]common.Address)
+		amounts, ok6 = args[8].([]*big.Int)
+	}
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 || len(recipients) != len(amounts) {
+		return "", common.Address{}, nil, nonce, nil, nil, fmt.Errorf("splitWithAuthorization: invalid arguments %v", args)
+	}
+	return tokenAddress.Hex(), from, value, nonce, recipients, amounts, nil
+}
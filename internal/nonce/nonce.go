@@ -0,0 +1,43 @@
+// Package nonce remembers the nonces requests were sent with, so a request
+// can't be replayed while its timestamp is still accepted.
+package nonce
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache remembers nonces for a fixed time.
+type Cache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Add remembers the nonce and reports whether it was unused.
+func (n *Cache) Add(nonce string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if now.Sub(n.pruned) > n.ttl {
+		for key, seen := range n.seen {
+			if now.Sub(seen) > n.ttl {
+				delete(n.seen, key)
+			}
+		}
+		n.pruned = now
+	}
+	if seen, ok := n.seen[nonce]; ok && now.Sub(seen) <= n.ttl {
+		return false
+	}
+	n.seen[nonce] = now
+	return true
+}
@@ -0,0 +1,18 @@
+package nonce
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheExpiry(t *testing.T) {
+	cache := NewCache(time.Minute)
+	now := time.Now()
+
+	require.True(t, cache.Add("a", now))
+	require.False(t, cache.Add("a", now.Add(30*time.Second)))
+	require.True(t, cache.Add("a", now.Add(2*time.Minute)))
+	require.Len(t, cache.seen, 1, "expired nonces are pruned")
+}
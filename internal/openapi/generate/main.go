@@ -0,0 +1,78 @@
+// Command generate writes the OpenAPI 3.1 document of the API and its clients
+// from the Swagger document swag generates: generate [root]. The root of the
+// repository defaults to the working directory.
+//
+//	api/swagger/swagger.json  input, see make generate-api
+//	api/openapi.yaml          OpenAPI 3.1 document
+//	api/gen/client.go         Go client
+//	api/gen/client.ts         TypeScript client
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gosuda/x402-facilitator/internal/openapi"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// options are the error codes of the API and the fields reporting them
+var options = openapi.Options{
+	ErrorCodes: append(types.ErrorCodes(), types.ErrorCodeTimeout, types.ErrorCodeChainUnavailable, types.ErrorCodeUnsupportedNetwork),
+	ErrorFields: []string{
+		"ErrorResponse.code",
+		"UnsupportedNetworkResponse.code",
+		"PaymentVerifyResponse.invalidReason",
+		"PaymentSettleResponse.error",
+		"PaymentEstimateResponse.error",
+	},
+}
+
+func main() {
+	root := "."
+	if len(os.Args) > 1 {
+		root = os.Args[1]
+	}
+	files, err := generate(root)
+	if err == nil {
+		for name, data := range files {
+			if err = os.WriteFile(filepath.Join(root, name), data, 0o644); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// generate returns the generated files by their path relative to the root.
+func generate(root string) (map[string][]byte, error) {
+	swagger, err := os.ReadFile(filepath.Join(root, "api", "swagger", "swagger.json"))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := openapi.Convert(swagger, options)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := doc.YAML()
+	if err != nil {
+		return nil, err
+	}
+	goClient, err := openapi.GoClient(doc, "gen")
+	if err != nil {
+		return nil, err
+	}
+	tsClient, err := openapi.TypeScriptClient(doc)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		filepath.Join("api", "openapi.yaml"):     spec,
+		filepath.Join("api", "gen", "client.go"): goClient,
+		filepath.Join("api", "gen", "client.ts"): tsClient,
+	}, nil
+}
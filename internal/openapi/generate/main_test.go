@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedUpToDate(t *testing.T) {
+	root := filepath.Join("..", "..", "..")
+	files, err := generate(root)
+	require.NoError(t, err)
+	for name, data := range files {
+		committed, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		require.Equal(t, string(data), string(committed), "%s is outdated, run make generate-api", name)
+	}
+}
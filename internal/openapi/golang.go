@@ -0,0 +1,441 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]bool{"id": true, "url": true, "usd": true, "rpc": true, "api": true, "json": true, "http": true, "uri": true}
+
+// GoClient generates a Go client of the document in the package. Schemas
+// become types named like the schema and operations methods of Client named
+// after their operation ID. Websocket operations are left out.
+func GoClient(doc *Document, pkg string) ([]byte, error) {
+	g := &generator{doc: doc}
+	g.printf("// Code generated by internal/openapi/generate from api/openapi.yaml. DO NOT EDIT.\n\n")
+	g.printf("// Package %s is a client of the %s generated from its OpenAPI document.\n", pkg, doc.Info.Title)
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n\"bytes\"\n\"context\"\n\"encoding/json\"\n\"fmt\"\n\"io\"\n\"net/http\"\n\"net/url\"\n\"strings\"\n)\n\n")
+
+	for _, name := range slices.Sorted(maps.Keys(doc.Components.Schemas)) {
+		if err := g.goSchema(name, doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	g.printf("%s", goClientRuntime)
+	for _, op := range doc.Operations() {
+		if err := g.goOperation(op); err != nil {
+			return nil, fmt.Errorf("operation %s: %w", op.OperationID, err)
+		}
+	}
+
+	out, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format Go client: %w", err)
+	}
+	return out, nil
+}
+
+type generator struct {
+	doc *Document
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// comment writes the text as a comment wrapped at 100 columns, prefixed with indent.
+func (g *generator) comment(indent, prefix, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	for _, line := range wrap(text, 100-len(indent)-len(prefix)) {
+		g.printf("%s%s%s\n", indent, prefix, line)
+	}
+}
+
+// wrap splits the text into lines of at most width characters, breaking at spaces.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (g *generator) goSchema(name string, s *Schema) error {
+	g.comment("", "// ", s.Description)
+	switch {
+	case len(s.Enum) > 0:
+		g.printf("type %s string\n\nconst (\n", name)
+		for i, value := range s.Enum {
+			constant := name + exportedName(value)
+			if i < len(s.EnumVarNames) {
+				constant = s.EnumVarNames[i]
+			}
+			g.printf("%s %s = %q\n", constant, name, value)
+		}
+		g.printf(")\n\n")
+	case s.Type == "object" && s.Properties != nil:
+		g.printf("type %s struct {\n", name)
+		for _, property := range slices.Sorted(maps.Keys(s.Properties)) {
+			schema := s.Properties[property]
+			typ, err := g.goType(schema)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", property, err)
+			}
+			g.comment("\t", "// ", schema.Description)
+			g.printf("%s %s `json:\"%s,omitempty\"`\n", exportedName(property), typ, property)
+		}
+		g.printf("}\n\n")
+	default:
+		typ, err := g.goType(s)
+		if err != nil {
+			return err
+		}
+		g.printf("type %s %s\n\n", name, typ)
+	}
+	return nil
+}
+
+// goType returns the Go type of values of the schema. References to objects
+// are pointers, so optional objects are left out of requests.
+func (g *generator) goType(s *Schema) (string, error) {
+	if s.Ref != "" {
+		target, ok := g.doc.Components.Schemas[s.RefName()]
+		if !ok {
+			return "", fmt.Errorf("unknown reference %s", s.Ref)
+		}
+		if target.Type == "object" {
+			return "*" + s.RefName(), nil
+		}
+		return s.RefName(), nil
+	}
+	if len(s.AnyOf) > 0 {
+		// an enumeration open to other strings is its string type
+		for _, option := range s.AnyOf {
+			if option.Ref != "" {
+				return g.goType(option)
+			}
+		}
+	}
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "integer":
+		return "int64", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "[]any", nil
+		}
+		item, err := g.goType(s.Items)
+		return "[]" + item, err
+	case "object":
+		if s.AdditionalProperties != nil {
+			value, err := g.goType(s.AdditionalProperties)
+			return "map[string]" + value, err
+		}
+		// free form objects are passed through as they are
+		return "json.RawMessage", nil
+	case "":
+		return "any", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", s.Type)
+}
+
+// exportedName returns the exported Go name of a JSON property or enum value.
+func exportedName(name string) string {
+	if strings.ToUpper(name) == name {
+		name = strings.ToLower(name)
+	}
+	var words []string
+	word := []rune{}
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '-' || r == '.' || r == ' ':
+			flush()
+		case unicode.IsUpper(r):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for _, word := range words {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		b.WriteString(string(unicode.ToUpper(runes[0])) + string(runes[1:]))
+	}
+	return b.String()
+}
+
+// success returns the status and response of the operation on success.
+func success(op *Operation) (string, *Response) {
+	for _, status := range slices.Sorted(maps.Keys(op.Responses)) {
+		if strings.HasPrefix(status, "2") {
+			return status, op.Responses[status]
+		}
+	}
+	return "", nil
+}
+
+// responseSchema returns the media type and schema of a response, if it has
+// content. The clients speak JSON to operations that offer other encodings too.
+func responseSchema(resp *Response) (string, *Schema) {
+	if resp == nil {
+		return "", nil
+	}
+	if media, ok := resp.Content["application/json"]; ok {
+		return "application/json", media.Schema
+	}
+	for _, mediaType := range slices.Sorted(maps.Keys(resp.Content)) {
+		return mediaType, resp.Content[mediaType].Schema
+	}
+	return "", nil
+}
+
+func (g *generator) goOperation(op PathOperation) error {
+	status, resp := success(op.Operation)
+	if status == "" {
+		// websockets can't be served by a plain HTTP client
+		return nil
+	}
+	name := exportedName(op.OperationID)
+
+	params := []string{"ctx context.Context"}
+	path := fmt.Sprintf("%q", op.Path)
+	var pathArgs []string
+	var query []*Parameter
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			arg := strings.ToLower(p.Name[:1]) + p.Name[1:]
+			params = append(params, arg+" string")
+			pathArgs = append(pathArgs, p.Name, arg)
+		case "query":
+			query = append(query, p)
+		}
+	}
+	if len(pathArgs) > 0 {
+		path = "expandPath(" + path
+		for i := 0; i < len(pathArgs); i += 2 {
+			path += fmt.Sprintf(", %q, %s", pathArgs[i], pathArgs[i+1])
+		}
+		path += ")"
+	}
+	queryArg := "nil"
+	if len(query) > 0 {
+		paramsType := name + "Params"
+		g.printf("// %s are the query parameters of %s.\ntype %s struct {\n", paramsType, name, paramsType)
+		for _, p := range query {
+			g.comment("\t", "// ", p.Description)
+			g.printf("%s string\n", exportedName(p.Name))
+		}
+		g.printf("}\n\n")
+		params = append(params, "params "+paramsType)
+		queryArg = "url.Values{"
+		for _, p := range query {
+			queryArg += fmt.Sprintf("%q: {params.%s}, ", p.Name, exportedName(p.Name))
+		}
+		queryArg += "}"
+	}
+	bodyArg := "nil"
+	if op.RequestBody != nil {
+		_, schema := responseSchema(&Response{Content: op.RequestBody.Content})
+		typ, err := g.goType(schema)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(typ, "*") {
+			typ = "*" + typ
+		}
+		params = append(params, "body "+typ)
+		bodyArg = "body"
+	}
+
+	mediaType, schema := responseSchema(resp)
+	result := ""
+	if schema != nil {
+		typ, err := g.goType(schema)
+		if err != nil {
+			return err
+		}
+		if mediaType != "application/json" {
+			typ = "string"
+		}
+		result = strings.TrimPrefix(typ, "*")
+	}
+
+	g.comment("", "// ", name+" calls "+op.Method+" "+op.Path+": "+op.Summary+".")
+	if op.Description != "" {
+		g.printf("//\n")
+		g.comment("", "// ", op.Description)
+	}
+	call := fmt.Sprintf("c.do(ctx, %q, %s, %s, %s", op.Method, path, queryArg, bodyArg)
+	if result == "" {
+		g.printf("func (c *Client) %s(%s) error {\nreturn %s, nil)\n}\n\n", name, strings.Join(params, ", "), call)
+		return nil
+	}
+	if strings.HasPrefix(result, "[]") || strings.HasPrefix(result, "map[") || result == "string" {
+		g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(params, ", "), result)
+		g.printf("var result %s\nerr := %s, &result)\nreturn result, err\n}\n\n", result, call)
+		return nil
+	}
+	g.printf("func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(params, ", "), result)
+	g.printf("var result %s\nif err := %s, &result); err != nil {\nreturn nil, err\n}\nreturn &result, nil\n}\n\n", result, call)
+	return nil
+}
+
+// goClientRuntime is the part of the Go client that doesn't depend on the document
+const goClientRuntime = `// Client calls the API of a facilitator.
+type Client struct {
+	// BaseURL of the facilitator, e.g. https://facilitator.example.com
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	// RequestEditors are applied to every request before it is sent, e.g. to authenticate it
+	RequestEditors []func(*http.Request) error
+}
+
+// NewClient returns a client of the facilitator at the base URL.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Error is returned for responses of failed requests.
+type Error struct {
+	StatusCode int
+	// Code of the error, if the facilitator reported one
+	Code string
+	// Message of the error, the response body if it isn't an error object
+	Message string
+	// Body of the response
+	Body []byte
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("facilitator responded %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("facilitator responded %d: %s", e.StatusCode, e.Message)
+}
+
+// expandPath replaces the parameters of the path with their escaped values.
+func expandPath(path string, params ...string) string {
+	for i := 0; i+1 < len(params); i += 2 {
+		path = strings.ReplaceAll(path, "{"+params[i]+"}", url.PathEscape(params[i+1]))
+	}
+	return path
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	target := c.BaseURL + path
+	for key, values := range query {
+		if len(values) == 0 || values[0] == "" {
+			delete(query, key)
+		}
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, edit := range c.RequestEditors {
+		if err := edit(req); err != nil {
+			return err
+		}
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data)), Body: data}
+		var decoded struct {
+			Code    string ` + "`json:\"code\"`" + `
+			Message any    ` + "`json:\"message\"`" + `
+		}
+		if json.Unmarshal(data, &decoded) == nil {
+			apiErr.Code = decoded.Code
+			if message, ok := decoded.Message.(string); ok {
+				apiErr.Message = message
+			}
+		}
+		return apiErr
+	}
+	switch result := result.(type) {
+	case nil:
+		return nil
+	case *string:
+		*result = string(data)
+		return nil
+	default:
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+`
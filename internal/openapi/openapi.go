@@ -0,0 +1,439 @@
+// Package openapi converts the Swagger 2.0 document swag generates from the
+// annotations of the API handlers into an OpenAPI 3.1 document, and generates
+// the clients of resource servers from it.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the OpenAPI version of the documents
+const Version = "3.1.0"
+
+// ErrorCodeSchema names the schema of the error codes of the API
+const ErrorCodeSchema = "ErrorCode"
+
+// Document is an OpenAPI 3.1 document, limited to what the API uses.
+type Document struct {
+	OpenAPI    string               `yaml:"openapi"`
+	Info       Info                 `yaml:"info"`
+	Tags       []Tag                `yaml:"tags,omitempty"`
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components Components           `yaml:"components"`
+}
+
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description,omitempty"`
+	Version     string `yaml:"version"`
+}
+
+type Tag struct {
+	Name string `yaml:"name"`
+}
+
+// PathItem holds the operations of a path by lower case HTTP method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `yaml:"operationId"`
+	Summary     string                `yaml:"summary,omitempty"`
+	Description string                `yaml:"description,omitempty"`
+	Tags        []string              `yaml:"tags,omitempty"`
+	Parameters  []*Parameter          `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody          `yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response  `yaml:"responses"`
+	Security    []map[string][]string `yaml:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description,omitempty"`
+	Required    bool    `yaml:"required,omitempty"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `yaml:"description,omitempty"`
+	Required    bool                  `yaml:"required,omitempty"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+type Response struct {
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `yaml:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `yaml:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `yaml:"type" json:"type"`
+	Description  string `yaml:"description,omitempty" json:"description"`
+	Name         string `yaml:"name,omitempty" json:"name"`
+	In           string `yaml:"in,omitempty" json:"in"`
+	Scheme       string `yaml:"scheme,omitempty" json:"-"`
+	BearerFormat string `yaml:"bearerFormat,omitempty" json:"-"`
+}
+
+// Schema is a JSON schema. Swagger 2.0 schemas are read into it as well.
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty" json:"$ref"`
+	Description          string             `yaml:"description,omitempty" json:"description"`
+	Type                 string             `yaml:"type,omitempty" json:"type"`
+	Format               string             `yaml:"format,omitempty" json:"format"`
+	Enum                 []string           `yaml:"enum,omitempty" json:"enum"`
+	EnumVarNames         []string           `yaml:"x-enum-varnames,omitempty" json:"x-enum-varnames"`
+	Items                *Schema            `yaml:"items,omitempty" json:"items"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty" json:"properties"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties,omitempty" json:"additionalProperties"`
+	AllOf                []*Schema          `yaml:"allOf,omitempty" json:"allOf"`
+	AnyOf                []*Schema          `yaml:"anyOf,omitempty" json:"anyOf"`
+}
+
+// UnmarshalJSON reads the schema, true stands for the schema of any value.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	if string(data) == "true" {
+		*s = Schema{}
+		return nil
+	}
+	type schema Schema
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// RefName returns the name of the component schema the schema refers to, if any.
+func (s *Schema) RefName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// Options are the parts of the document swag can't derive from the annotations.
+type Options struct {
+	// ErrorCodes are the values of the ErrorCode schema
+	ErrorCodes []string
+	// ErrorFields lists the properties holding error codes as "Schema.property"
+	ErrorFields []string
+}
+
+// swagger is the part of a Swagger 2.0 document swag generates
+type swagger struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Paths               map[string]map[string]*swaggerOperation `json:"paths"`
+	Definitions         map[string]*Schema                      `json:"definitions"`
+	SecurityDefinitions map[string]*SecurityScheme              `json:"securityDefinitions"`
+}
+
+type swaggerOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description"`
+	Tags        []string                    `json:"tags"`
+	Consumes    []string                    `json:"consumes"`
+	Produces    []string                    `json:"produces"`
+	Parameters  []*swaggerParameter         `json:"parameters"`
+	Responses   map[string]*swaggerResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security"`
+}
+
+type swaggerParameter struct {
+	Name        string   `json:"name"`
+	In          string   `json:"in"`
+	Description string   `json:"description"`
+	Required    bool     `json:"required"`
+	Type        string   `json:"type"`
+	Format      string   `json:"format"`
+	Enum        []string `json:"enum"`
+	Schema      *Schema  `json:"schema"`
+}
+
+type swaggerResponse struct {
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Convert converts the Swagger 2.0 document to OpenAPI 3.1:
+//   - schemas are named after their Go type without the package, e.g.
+//     PaymentVerifyResponse for types.PaymentVerifyResponse
+//   - body parameters become request bodies and the schemas of responses are
+//     served as every type the operation produces
+//   - references with a description, which Swagger 2.0 wraps into allOf, are
+//     plain references, as OpenAPI 3.1 allows siblings of $ref
+//   - API keys sent in the Authorization header are HTTP bearer schemes.
+//     Authentication is up to the operator, so operations requiring it accept
+//     anonymous requests as well
+//   - the properties listed in opts.ErrorFields refer to the ErrorCode schema
+func Convert(data []byte, opts Options) (*Document, error) {
+	var src swagger
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("failed to parse swagger document: %w", err)
+	}
+
+	names := make(map[string]string, len(src.Definitions))
+	for _, definition := range slices.Sorted(maps.Keys(src.Definitions)) {
+		name := definition[strings.LastIndex(definition, ".")+1:]
+		for other, renamed := range names {
+			if renamed == name {
+				return nil, fmt.Errorf("schemas %s and %s are both named %s", other, definition, name)
+			}
+		}
+		names[definition] = name
+	}
+	c := converter{names: names}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: src.Info.Title, Description: src.Info.Description, Version: src.Info.Version},
+		Paths:   make(map[string]*PathItem, len(src.Paths)),
+		Components: Components{
+			Schemas: make(map[string]*Schema, len(src.Definitions)+1),
+		},
+	}
+	for definition, schema := range src.Definitions {
+		converted, err := c.schema(schema)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", definition, err)
+		}
+		doc.Components.Schemas[names[definition]] = converted
+	}
+	if err := addErrorCodes(doc, opts); err != nil {
+		return nil, err
+	}
+
+	for name, scheme := range src.SecurityDefinitions {
+		if scheme.Type == "apiKey" && scheme.In == "header" && scheme.Name == "Authorization" {
+			scheme = &SecurityScheme{Type: "http", Description: scheme.Description, Scheme: "bearer", BearerFormat: "JWT"}
+		}
+		if doc.Components.SecuritySchemes == nil {
+			doc.Components.SecuritySchemes = make(map[string]*SecurityScheme)
+		}
+		doc.Components.SecuritySchemes[name] = scheme
+	}
+
+	tags := make(map[string]bool)
+	operationIDs := make(map[string]string)
+	for path, methods := range src.Paths {
+		item := make(PathItem, len(methods))
+		for method, op := range methods {
+			where := strings.ToUpper(method) + " " + path
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("%s has no operation ID, annotate it with @ID", where)
+			}
+			if other, ok := operationIDs[op.OperationID]; ok {
+				return nil, fmt.Errorf("%s and %s have the same operation ID %s", other, where, op.OperationID)
+			}
+			operationIDs[op.OperationID] = where
+			converted, err := c.operation(op)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			for _, tag := range op.Tags {
+				tags[tag] = true
+			}
+			item[method] = converted
+		}
+		doc.Paths[path] = &item
+	}
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	return doc, nil
+}
+
+// addErrorCodes adds the ErrorCode schema and refers to it from the error fields.
+func addErrorCodes(doc *Document, opts Options) error {
+	if len(opts.ErrorCodes) == 0 {
+		return nil
+	}
+	doc.Components.Schemas[ErrorCodeSchema] = &Schema{
+		Description: "Machine readable code of a payment or request error. Facilitators may report codes added later, clients should accept unknown codes",
+		Type:        "string",
+		Enum:        opts.ErrorCodes,
+	}
+	for _, field := range opts.ErrorFields {
+		name, property, ok := strings.Cut(field, ".")
+		schema := doc.Components.Schemas[name]
+		if !ok || schema == nil || schema.Properties[property] == nil {
+			return fmt.Errorf("unknown error field %s", field)
+		}
+		schema.Properties[property] = &Schema{
+			Description: schema.Properties[property].Description,
+			AnyOf:       []*Schema{{Ref: "#/components/schemas/" + ErrorCodeSchema}, {Type: "string"}},
+		}
+	}
+	return nil
+}
+
+type converter struct {
+	// names of the schemas by the name of their Swagger definition
+	names map[string]string
+}
+
+func (c converter) schema(s *Schema) (*Schema, error) {
+	if s == nil {
+		return nil, nil
+	}
+	out := *s
+	if s.Ref != "" {
+		definition, ok := strings.CutPrefix(s.Ref, "#/definitions/")
+		if !ok || c.names[definition] == "" {
+			return nil, fmt.Errorf("unknown reference %s", s.Ref)
+		}
+		out.Ref = "#/components/schemas/" + c.names[definition]
+	}
+	if len(s.AllOf) == 1 && s.AllOf[0].Ref != "" && s.Ref == "" && s.Type == "" {
+		ref, err := c.schema(s.AllOf[0])
+		if err != nil {
+			return nil, err
+		}
+		out.Ref, out.AllOf = ref.Ref, nil
+	}
+
+	var err error
+	if out.Items, err = c.schema(s.Items); err != nil {
+		return nil, err
+	}
+	if out.AdditionalProperties, err = c.schema(s.AdditionalProperties); err != nil {
+		return nil, err
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]*Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			if out.Properties[name], err = c.schema(property); err != nil {
+				return nil, fmt.Errorf("property %s: %w", name, err)
+			}
+		}
+	}
+	out.AllOf, err = c.schemas(out.AllOf)
+	if err != nil {
+		return nil, err
+	}
+	out.AnyOf, err = c.schemas(s.AnyOf)
+	return &out, err
+}
+
+func (c converter) schemas(in []*Schema) ([]*Schema, error) {
+	var out []*Schema
+	for _, s := range in {
+		converted, err := c.schema(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, converted)
+	}
+	return out, nil
+}
+
+func (c converter) operation(op *swaggerOperation) (*Operation, error) {
+	out := &Operation{
+		OperationID: op.OperationID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   make(map[string]*Response, len(op.Responses)),
+	}
+	for _, p := range op.Parameters {
+		if p.In == "body" {
+			schema, err := c.schema(p.Schema)
+			if err != nil {
+				return nil, err
+			}
+			out.RequestBody = &RequestBody{
+				Description: p.Description,
+				Required:    p.Required,
+				Content:     content(orDefault(op.Consumes, "application/json"), schema),
+			}
+			continue
+		}
+		out.Parameters = append(out.Parameters, &Parameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == "path",
+			Schema:      &Schema{Type: p.Type, Format: p.Format, Enum: p.Enum},
+		})
+	}
+	for status, resp := range op.Responses {
+		converted := &Response{Description: resp.Description}
+		if resp.Schema != nil {
+			schema, err := c.schema(resp.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("response %s: %w", status, err)
+			}
+			converted.Content = content(orDefault(op.Produces, "application/json"), schema)
+		}
+		out.Responses[status] = converted
+	}
+	if len(op.Security) > 0 {
+		out.Security = append([]map[string][]string{{}}, op.Security...)
+	}
+	return out, nil
+}
+
+func content(types []string, schema *Schema) map[string]*MediaType {
+	content := make(map[string]*MediaType, len(types))
+	for _, t := range types {
+		content[t] = &MediaType{Schema: schema}
+	}
+	return content
+}
+
+func orDefault(values []string, fallback string) []string {
+	if len(values) == 0 {
+		return []string{fallback}
+	}
+	return values
+}
+
+// YAML encodes the document. Keys of maps are sorted, so the encoding of a
+// document is stable.
+func (d *Document) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d); err != nil {
+		return nil, fmt.Errorf("failed to encode document: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode document: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Operations returns the operations of the document sorted by ID, with their
+// path and method.
+func (d *Document) Operations() []PathOperation {
+	var ops []PathOperation
+	for path, item := range d.Paths {
+		for method, op := range *item {
+			ops = append(ops, PathOperation{Path: path, Method: strings.ToUpper(method), Operation: op})
+		}
+	}
+	slices.SortFunc(ops, func(a, b PathOperation) int {
+		return strings.Compare(a.OperationID, b.OperationID)
+	})
+	return ops
+}
+
+// PathOperation is an operation of a path.
+type PathOperation struct {
+	Path   string
+	Method string
+	*Operation
+}
@@ -0,0 +1,112 @@
+package openapi
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSwagger = `{
+	"swagger": "2.0",
+	"info": {"title": "Test API", "version": "1.0"},
+	"paths": {
+		"/items/{id}": {
+			"get": {
+				"operationId": "getItem",
+				"produces": ["application/json"],
+				"parameters": [{"name": "id", "in": "path", "required": true, "type": "string"}],
+				"responses": {
+					"200": {"description": "OK", "schema": {"$ref": "#/definitions/store.Item"}},
+					"504": {"description": "Gateway Timeout", "schema": {"$ref": "#/definitions/types.ErrorResponse"}}
+				},
+				"security": [{"BearerAuth": []}]
+			}
+		},
+		"/items": {
+			"post": {
+				"operationId": "createItem",
+				"parameters": [{"name": "body", "in": "body", "required": true, "schema": {"$ref": "#/definitions/store.Item"}}],
+				"responses": {"204": {"description": "No Content"}}
+			}
+		}
+	},
+	"definitions": {
+		"store.Item": {
+			"type": "object",
+			"properties": {
+				"owner": {"description": "Owner of the item", "allOf": [{"$ref": "#/definitions/store.Owner"}]},
+				"labels": {"type": "object", "additionalProperties": true}
+			}
+		},
+		"store.Owner": {"type": "object", "properties": {"name": {"type": "string"}}},
+		"types.ErrorResponse": {"type": "object", "properties": {"code": {"type": "string"}}}
+	},
+	"securityDefinitions": {
+		"BearerAuth": {"type": "apiKey", "in": "header", "name": "Authorization"}
+	}
+}`
+
+func TestConvert(t *testing.T) {
+	doc, err := Convert([]byte(testSwagger), Options{ErrorCodes: []string{"TIMEOUT"}, ErrorFields: []string{"ErrorResponse.code"}})
+	require.NoError(t, err)
+	require.Equal(t, Version, doc.OpenAPI)
+	require.ElementsMatch(t, []string{"Item", "Owner", "ErrorResponse", ErrorCodeSchema}, slices.Collect(maps.Keys(doc.Components.Schemas)))
+
+	item := doc.Components.Schemas["Item"]
+	require.Equal(t, &Schema{Ref: "#/components/schemas/Owner", Description: "Owner of the item"}, item.Properties["owner"], "references keep their description")
+	require.Equal(t, &Schema{}, item.Properties["labels"].AdditionalProperties)
+	code := doc.Components.Schemas["ErrorResponse"].Properties["code"]
+	require.Equal(t, "#/components/schemas/"+ErrorCodeSchema, code.AnyOf[0].Ref)
+
+	get := (*doc.Paths["/items/{id}"])["get"]
+	require.Equal(t, "#/components/schemas/Item", get.Responses["200"].Content["application/json"].Schema.Ref)
+	require.Equal(t, []map[string][]string{{}, {"BearerAuth": {}}}, get.Security, "authentication is optional")
+	require.Equal(t, "http", doc.Components.SecuritySchemes["BearerAuth"].Type)
+	require.True(t, get.Parameters[0].Required)
+
+	post := (*doc.Paths["/items"])["post"]
+	require.Empty(t, post.Parameters)
+	require.True(t, post.RequestBody.Required)
+	require.Equal(t, "#/components/schemas/Item", post.RequestBody.Content["application/json"].Schema.Ref)
+
+	spec, err := doc.YAML()
+	require.NoError(t, err)
+	require.Contains(t, string(spec), "openapi: 3.1.0\n")
+
+	client, err := GoClient(doc, "client")
+	require.NoError(t, err)
+	require.Contains(t, string(client), "func (c *Client) GetItem(ctx context.Context, id string) (*Item, error) {")
+	require.Contains(t, string(client), "func (c *Client) CreateItem(ctx context.Context, body *Item) error {")
+	require.Contains(t, string(client), "Labels map[string]any")
+
+	ts, err := TypeScriptClient(doc)
+	require.NoError(t, err)
+	require.Contains(t, string(ts), "async getItem(id: string, init: RequestInit = {}): Promise<Item> {")
+	require.Contains(t, string(ts), "code?: ErrorCode | string;")
+}
+
+func TestConvertRejects(t *testing.T) {
+	_, err := Convert([]byte(`{"paths": {"/": {"get": {"responses": {}}}}}`), Options{})
+	require.ErrorContains(t, err, "no operation ID")
+
+	_, err = Convert([]byte(`{"definitions": {"a.Item": {"type": "object"}, "b.Item": {"type": "object"}}}`), Options{})
+	require.ErrorContains(t, err, "both named Item")
+
+	_, err = Convert([]byte(testSwagger), Options{ErrorCodes: []string{"TIMEOUT"}, ErrorFields: []string{"Item.code"}})
+	require.ErrorContains(t, err, "unknown error field Item.code")
+}
+
+func TestExportedName(t *testing.T) {
+	for name, want := range map[string]string{
+		"txHash":                 "TxHash",
+		"networkId":              "NetworkID",
+		"gasCostUsd":             "GasCostUSD",
+		"x402Version":            "X402Version",
+		"invalid_payload_format": "InvalidPayloadFormat",
+		"TIMEOUT":                "Timeout",
+	} {
+		require.Equal(t, want, exportedName(name))
+	}
+}
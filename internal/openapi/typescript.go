@@ -0,0 +1,293 @@
+package openapi
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TypeScriptClient generates a TypeScript client of the document using fetch.
+// Schemas become interfaces and string unions, operations methods of Client
+// named after their operation ID. Websocket operations are left out.
+func TypeScriptClient(doc *Document) ([]byte, error) {
+	g := &generator{doc: doc}
+	g.printf("// Code generated by internal/openapi/generate from api/openapi.yaml. DO NOT EDIT.\n\n")
+	g.printf("// Client of the %s generated from its OpenAPI document.\n\n", doc.Info.Title)
+
+	for _, name := range slices.Sorted(maps.Keys(doc.Components.Schemas)) {
+		if err := g.tsSchema(name, doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	g.printf("%s", tsClientRuntime)
+	for _, op := range doc.Operations() {
+		if err := g.tsOperation(op); err != nil {
+			return nil, fmt.Errorf("operation %s: %w", op.OperationID, err)
+		}
+	}
+	g.printf("}\n")
+	return g.buf.Bytes(), nil
+}
+
+// jsdoc writes the text as a JSDoc comment prefixed with indent.
+func (g *generator) jsdoc(indent, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(text, "*/", "*\\/")
+	g.printf("%s/**\n", indent)
+	g.comment(indent, " * ", text)
+	g.printf("%s */\n", indent)
+}
+
+func (g *generator) tsSchema(name string, s *Schema) error {
+	g.jsdoc("", s.Description)
+	switch {
+	case len(s.Enum) > 0:
+		values := make([]string, len(s.Enum))
+		for i, value := range s.Enum {
+			values[i] = strconv.Quote(value)
+		}
+		g.printf("export type %s =\n  | %s;\n\n", name, strings.Join(values, "\n  | "))
+	case s.Type == "object" && s.Properties != nil:
+		g.printf("export interface %s {\n", name)
+		for _, property := range slices.Sorted(maps.Keys(s.Properties)) {
+			schema := s.Properties[property]
+			typ, err := g.tsType(schema)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", property, err)
+			}
+			g.jsdoc("  ", schema.Description)
+			g.printf("  %s?: %s;\n", property, typ)
+		}
+		g.printf("}\n\n")
+	default:
+		typ, err := g.tsType(s)
+		if err != nil {
+			return err
+		}
+		g.printf("export type %s = %s;\n\n", name, typ)
+	}
+	return nil
+}
+
+// tsType returns the TypeScript type of values of the schema.
+func (g *generator) tsType(s *Schema) (string, error) {
+	if s.Ref != "" {
+		if _, ok := g.doc.Components.Schemas[s.RefName()]; !ok {
+			return "", fmt.Errorf("unknown reference %s", s.Ref)
+		}
+		return s.RefName(), nil
+	}
+	if len(s.AnyOf) > 0 {
+		options := make([]string, len(s.AnyOf))
+		for i, option := range s.AnyOf {
+			typ, err := g.tsType(option)
+			if err != nil {
+				return "", err
+			}
+			options[i] = typ
+		}
+		return strings.Join(options, " | "), nil
+	}
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "integer", "number":
+		return "number", nil
+	case "boolean":
+		return "boolean", nil
+	case "array":
+		if s.Items == nil {
+			return "unknown[]", nil
+		}
+		item, err := g.tsType(s.Items)
+		if strings.Contains(item, " ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]", err
+	case "object":
+		if s.AdditionalProperties != nil {
+			value, err := g.tsType(s.AdditionalProperties)
+			return "Record<string, " + value + ">", err
+		}
+		return "Record<string, unknown>", nil
+	case "":
+		return "unknown", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", s.Type)
+}
+
+func (g *generator) tsOperation(op PathOperation) error {
+	status, resp := success(op.Operation)
+	if status == "" {
+		return nil
+	}
+	name := strings.ToLower(op.OperationID[:1]) + op.OperationID[1:]
+
+	var params []string
+	path := "`" + op.Path + "`"
+	var query []string
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			params = append(params, p.Name+": string")
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", "${encodeURIComponent("+p.Name+")}")
+		case "query":
+			query = append(query, p.Name)
+		}
+	}
+	if op.RequestBody != nil {
+		_, schema := responseSchema(&Response{Content: op.RequestBody.Content})
+		typ, err := g.tsType(schema)
+		if err != nil {
+			return err
+		}
+		params = append(params, "body: "+typ)
+	}
+	if len(query) > 0 {
+		fields := make([]string, len(query))
+		for i, q := range query {
+			fields[i] = q + "?: string"
+		}
+		params = append(params, "query: { "+strings.Join(fields, "; ")+" } = {}")
+	}
+	params = append(params, "init: RequestInit = {}")
+
+	result := "void"
+	parse := "none"
+	if mediaType, schema := responseSchema(resp); schema != nil {
+		typ, err := g.tsType(schema)
+		if err != nil {
+			return err
+		}
+		result, parse = typ, "json"
+		if mediaType != "application/json" {
+			result, parse = "string", "text"
+		}
+	}
+
+	description := op.Summary
+	if op.Description != "" {
+		description += "\n" + op.Description
+	}
+	g.jsdoc("  ", op.Method+" "+op.Path+": "+description)
+	g.printf("  async %s(%s): Promise<%s> {\n", name, strings.Join(params, ", "), result)
+	args := []string{strconv.Quote(op.Method), path, strconv.Quote(parse)}
+	if len(query) > 0 {
+		args = append(args, "query")
+	} else {
+		args = append(args, "undefined")
+	}
+	if op.RequestBody != nil {
+		args = append(args, "body")
+	} else {
+		args = append(args, "undefined")
+	}
+	args = append(args, "init")
+	g.printf("    return (await this.request(%s)) as %s;\n  }\n\n", strings.Join(args, ", "), result)
+	return nil
+}
+
+// tsClientRuntime is the part of the TypeScript client that doesn't depend on the document
+const tsClientRuntime = `/**
+ * Error of a failed request.
+ */
+export class FacilitatorError extends Error {
+  readonly status: number;
+  readonly code: string | undefined;
+  readonly body: string;
+
+  constructor(status: number, code: string | undefined, message: string, body: string) {
+    super(code ? ` + "`facilitator responded ${status} ${code}: ${message}`" + ` : ` + "`facilitator responded ${status}: ${message}`" + `);
+    this.name = "FacilitatorError";
+    this.status = status;
+    this.code = code;
+    this.body = body;
+  }
+}
+
+export interface ClientOptions {
+  /**
+   * Sends the requests, the global fetch by default.
+   */
+  fetch?: typeof fetch;
+  /**
+   * Headers sent with every request, e.g. to authenticate them.
+   */
+  headers?: Record<string, string>;
+}
+
+/**
+ * Client calls the API of a facilitator.
+ */
+export class Client {
+  private readonly baseUrl: string;
+  private readonly options: ClientOptions;
+
+  constructor(baseUrl: string, options: ClientOptions = {}) {
+    this.baseUrl = baseUrl.replace(/\/+$/, "");
+    this.options = options;
+  }
+
+  private async request(
+    method: string,
+    path: string,
+    parse: "json" | "text" | "none",
+    query: Record<string, string | undefined> | undefined,
+    body: unknown,
+    init: RequestInit,
+  ): Promise<unknown> {
+    const params = new URLSearchParams();
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== "") {
+        params.set(key, value);
+      }
+    }
+    const search = params.toString();
+    const headers = new Headers(init.headers);
+    headers.set("Accept", "application/json");
+    for (const [key, value] of Object.entries(this.options.headers ?? {})) {
+      headers.set(key, value);
+    }
+    if (body !== undefined) {
+      headers.set("Content-Type", "application/json");
+    }
+    const send = this.options.fetch ?? fetch;
+    const response = await send(this.baseUrl + path + (search ? "?" + search : ""), {
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await response.text();
+    if (!response.ok) {
+      let code: string | undefined;
+      let message = text.trim();
+      try {
+        const decoded = JSON.parse(text);
+        if (typeof decoded?.code === "string") {
+          code = decoded.code;
+        }
+        if (typeof decoded?.message === "string") {
+          message = decoded.message;
+        }
+      } catch {
+        // the body isn't an error object
+      }
+      throw new FacilitatorError(response.status, code, message, text);
+    }
+    switch (parse) {
+      case "json":
+        return JSON.parse(text);
+      case "text":
+        return text;
+      default:
+        return undefined;
+    }
+  }
+
+`
@@ -0,0 +1,178 @@
+// Package outbound configures the transport of the requests the facilitator
+// sends: RPC calls, webhooks, price oracles and key sets. Deployments behind an
+// egress proxy route them through it, trust its certificate authority and bound
+// how long connections may take.
+package outbound
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	DefaultDialTimeout         = 30 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	keepAlive                  = 30 * time.Second
+)
+
+// ProxySchemes are the schemes of proxy URLs
+var ProxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// Config configures the transport of outbound requests.
+type Config struct {
+	// URL of the proxy requests are sent through, http, https or socks5. The
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables apply if empty
+	Proxy string `mapstructure:"proxy"`
+	// Hosts, domains like ".internal" and CIDR ranges reached without the proxy.
+	// Loopback addresses never use it
+	NoProxy []string `mapstructure:"noProxy"`
+	// PEM file of certificate authorities trusted besides those of the system
+	CAFile string `mapstructure:"caFile"`
+	// How long establishing a connection may take, 30s if 0
+	DialTimeout time.Duration `mapstructure:"dialTimeout"`
+	// How long a TLS handshake may take, 10s if 0
+	TLSHandshakeTimeout time.Duration `mapstructure:"tlsHandshakeTimeout"`
+	// How long to wait for the headers of a response once the request was
+	// sent, unbounded if 0. Requests keep their own overall timeouts
+	ResponseHeaderTimeout time.Duration `mapstructure:"responseHeaderTimeout"`
+}
+
+// settings are the transport and dialer outbound connections are made with
+type settings struct {
+	transport *http.Transport
+	dialer    *net.Dialer
+}
+
+var current atomic.Pointer[settings]
+
+func init() {
+	s, err := newSettings(Config{})
+	if err != nil {
+		panic(err)
+	}
+	current.Store(s)
+}
+
+// Setup applies config to the connections made afterwards. Clients created
+// before keep their transport.
+func Setup(config Config) error {
+	s, err := newSettings(config)
+	if err != nil {
+		return err
+	}
+	current.Store(s)
+	return nil
+}
+
+func newSettings(config Config) (*settings, error) {
+	if config.DialTimeout < 0 || config.TLSHandshakeTimeout < 0 || config.ResponseHeaderTimeout < 0 {
+		return nil, errors.New("timeouts must not be negative")
+	}
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		u, err := url.Parse(config.Proxy)
+		if err != nil || u.Host == "" || !slices.Contains(ProxySchemes, u.Scheme) {
+			return nil, fmt.Errorf("proxy: %q must be a %s URL", config.Proxy, strings.Join(ProxySchemes, " or "))
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  config.Proxy,
+			HTTPSProxy: config.Proxy,
+			NoProxy:    strings.Join(config.NoProxy, ","),
+		}).ProxyFunc()
+		proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cmp.Or(config.DialTimeout, DefaultDialTimeout),
+		KeepAlive: keepAlive,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = cmp.Or(config.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout)
+	transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	if config.CAFile != "" {
+		pool, err := LoadCAFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &settings{transport: transport, dialer: dialer}, nil
+}
+
+// LoadCAFile returns the certificate authorities of the system with those of
+// the PEM file at path added.
+func LoadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("caFile: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("caFile: no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// Transport returns a copy of the configured transport, for callers changing
+// how it dials.
+func Transport() *http.Transport {
+	return current.Load().transport.Clone()
+}
+
+// Dialer returns a copy of the dialer of the configured transport.
+func Dialer() *net.Dialer {
+	dialer := *current.Load().dialer
+	return &dialer
+}
+
+// Client returns an HTTP client of the configured transport whose requests
+// time out after timeout, never if 0.
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: current.Load().transport, Timeout: timeout}
+}
+
+// WebsocketDialer returns a websocket dialer using the proxy and certificate
+// authorities of the configured transport.
+func WebsocketDialer() websocket.Dialer {
+	s := current.Load()
+	dialer := websocket.Dialer{
+		NetDial:          s.dialer.Dial,
+		Proxy:            s.transport.Proxy,
+		HandshakeTimeout: s.transport.TLSHandshakeTimeout,
+	}
+	if s.transport.TLSClientConfig != nil {
+		dialer.TLSClientConfig = s.transport.TLSClientConfig.Clone()
+	}
+	return dialer
+}
+
+// RPCOptions makes JSON-RPC clients connect over HTTP and websockets with the
+// configured transport.
+func RPCOptions() []rpc.ClientOption {
+	return []rpc.ClientOption{
+		rpc.WithHTTPClient(Client(0)),
+		rpc.WithWebsocketDialer(WebsocketDialer()),
+	}
+}
@@ -0,0 +1,83 @@
+package outbound
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T, config Config) {
+	t.Helper()
+	require.NoError(t, Setup(config))
+	t.Cleanup(func() { require.NoError(t, Setup(Config{})) })
+}
+
+func TestProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	setup(t, Config{Proxy: proxy.URL, NoProxy: []string{".internal"}})
+	resp, err := Client(0).Get("http://rpc.example/v1")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, "proxied", string(body))
+	require.Equal(t, []string{"http://rpc.example/v1"}, proxied)
+
+	req, err := http.NewRequest(http.MethodGet, "http://node.internal/", nil)
+	require.NoError(t, err)
+	u, err := Transport().Proxy(req)
+	require.NoError(t, err)
+	require.Nil(t, u, "hosts of noProxy are reached directly")
+
+	// websocket dialers ask for the proxy of the URL with the http scheme
+	req, err = http.NewRequest(http.MethodGet, "http://rpc.example/ws", nil)
+	require.NoError(t, err)
+	u, err = WebsocketDialer().Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, proxy.URL, "http://"+u.Host)
+}
+
+func TestCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := Client(0).Get(server.URL)
+	require.Error(t, err, "the certificate of the test server isn't trusted by default")
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, cert, 0o600))
+	setup(t, Config{CAFile: path})
+	resp, err := Client(0).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotNil(t, WebsocketDialer().TLSClientConfig)
+}
+
+func TestSetupErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+
+	for _, config := range []Config{
+		{Proxy: "proxy.internal:3128"},
+		{Proxy: "ftp://proxy.internal"},
+		{CAFile: empty},
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{DialTimeout: -1},
+	} {
+		require.Error(t, Setup(config), "%+v", config)
+	}
+	// failed setups keep the transport
+	require.Equal(t, DefaultDialTimeout, Dialer().Timeout)
+}
@@ -0,0 +1,189 @@
+package rpcretry
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DialFunc connects to an RPC endpoint.
+type DialFunc func(ctx context.Context) (*ethclient.Client, error)
+
+// Client is an ethclient.Client whose calls are retried by a policy.
+type Client struct {
+	policy *Policy
+	// connects on the first call, nil if the client was connected already
+	dial DialFunc
+
+	mu     sync.Mutex
+	client *ethclient.Client
+}
+
+// NewClient wraps the client, retrying its calls with the policy.
+func NewClient(client *ethclient.Client, policy *Policy) *Client {
+	return &Client{client: client, policy: policy}
+}
+
+// NewLazyClient creates a client that connects with dial on its first call,
+// so creating it doesn't wait for the endpoint. Failed connection attempts
+// are retried like failed calls and by the next call.
+func NewLazyClient(dial DialFunc, policy *Policy) *Client {
+	return &Client{dial: dial, policy: policy}
+}
+
+// Close closes the connection of the wrapped client, if connected.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		c.client.Close()
+	}
+}
+
+// Connected reports whether the client is connected, lazy clients only once
+// a call connected them.
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client != nil
+}
+
+// conn returns the wrapped client, connecting lazy clients on the first call.
+// Concurrent calls wait for the same connection attempt.
+func (c *Client) conn(ctx context.Context) (*ethclient.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	client, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	return client, nil
+}
+
+// do calls op with the wrapped client until the policy gives up.
+func do(ctx context.Context, c *Client, op func(client *ethclient.Client) error) error {
+	return c.policy.Do(ctx, func() error {
+		client, err := c.conn(ctx)
+		if err != nil {
+			return err
+		}
+		return op(client)
+	})
+}
+
+// call is do for operations returning a value.
+func call[T any](ctx context.Context, c *Client, op func(client *ethclient.Client) (T, error)) (T, error) {
+	var result T
+	err := do(ctx, c, func(client *ethclient.Client) error {
+		var err error
+		result, err = op(client)
+		return err
+	})
+	return result, err
+}
+
+func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*big.Int, error) { return client.ChainID(ctx) })
+}
+
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	return call(ctx, c, func(client *ethclient.Client) (uint64, error) { return client.BlockNumber(ctx) })
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*ethTypes.Header, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*ethTypes.Header, error) { return client.HeaderByNumber(ctx, number) })
+}
+
+func (c *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*big.Int, error) { return client.BalanceAt(ctx, account, blockNumber) })
+}
+
+func (c *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, c, func(client *ethclient.Client) ([]byte, error) { return client.CodeAt(ctx, account, blockNumber) })
+}
+
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return call(ctx, c, func(client *ethclient.Client) (uint64, error) { return client.PendingNonceAt(ctx, account) })
+}
+
+func (c *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*big.Int, error) { return client.SuggestGasPrice(ctx) })
+}
+
+func (c *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*big.Int, error) { return client.SuggestGasTipCap(ctx) })
+}
+
+func (c *Client) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*ethereum.FeeHistory, error) {
+		return client.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+	})
+}
+
+func (c *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return call(ctx, c, func(client *ethclient.Client) (uint64, error) { return client.EstimateGas(ctx, msg) })
+}
+
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, c, func(client *ethclient.Client) ([]byte, error) { return client.CallContract(ctx, msg, blockNumber) })
+}
+
+// TransactionReceipt returns the receipt of a mined transaction, ethereum.NotFound
+// while it is pending, which isn't retried.
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*ethTypes.Receipt, error) {
+	return call(ctx, c, func(client *ethclient.Client) (*ethTypes.Receipt, error) {
+		return client.TransactionReceipt(ctx, txHash)
+	})
+}
+
+// SendTransaction broadcasts the signed transaction. A connection may fail after
+// the node accepted it, so a retry the node answers with "already known" succeeds.
+func (c *Client) SendTransaction(ctx context.Context, tx *ethTypes.Transaction) error {
+	retried := false
+	return do(ctx, c, func(client *ethclient.Client) error {
+		err := client.SendTransaction(ctx, tx)
+		if err != nil && retried && strings.Contains(strings.ToLower(err.Error()), "already known") {
+			return nil
+		}
+		retried = true
+		return err
+	})
+}
+
+// CallContext calls the JSON-RPC method and decodes its result into result.
+func (c *Client) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	return do(ctx, c, func(client *ethclient.Client) error {
+		return client.Client().CallContext(ctx, result, method, args...)
+	})
+}
+
+// BatchCallContext sends the calls in a single JSON-RPC batch. The batch is sent
+// again if it failed or any call in it failed for a transient reason, the
+// errors of the other calls are left in their elements.
+func (c *Client) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	return do(ctx, c, func(client *ethclient.Client) error {
+		for i := range batch {
+			batch[i].Error = nil
+		}
+		if err := client.Client().BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+		for _, elem := range batch {
+			if Retriable(elem.Error) {
+				return elem.Error
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,151 @@
+// Package rpcretry retries RPC calls that failed for transient reasons: dropped
+// connections, rate limits and overloaded providers. Calls failing for any
+// other reason, e.g. reverts, fail right away.
+package rpcretry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/gosuda/x402-facilitator/clock"
+)
+
+// Defaults of zero config values
+const (
+	defaultAttempts       = 3
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 2 * time.Second
+)
+
+// limitExceeded is the JSON-RPC error code providers answer rate limited calls with (EIP-1474)
+const limitExceeded = -32005
+
+// Config configures the retries of RPC calls.
+type Config struct {
+	// Attempts of a call including the first one, 3 if 0. 1 disables retries
+	Attempts int `mapstructure:"attempts"`
+	// Wait before the first retry, doubled for every further retry. 200ms if 0
+	InitialBackoff time.Duration `mapstructure:"initialBackoff"`
+	// Upper bound of the wait between attempts, 2s if 0
+	MaxBackoff time.Duration `mapstructure:"maxBackoff"`
+}
+
+// Policy retries calls failing with retriable errors with exponential backoff.
+type Policy struct {
+	attempts int
+	initial  time.Duration
+	max      time.Duration
+	// OnRetry is called with the context of the call before waiting to retry a
+	// call that failed with err, if set
+	OnRetry func(ctx context.Context, attempt int, err error)
+	// Clock times the waits between attempts, clock.Real if nil
+	Clock clock.Clock
+}
+
+// NewPolicy creates the retry policy of the config.
+func NewPolicy(config Config) *Policy {
+	p := &Policy{
+		attempts: config.Attempts,
+		initial:  config.InitialBackoff,
+		max:      config.MaxBackoff,
+	}
+	if p.attempts <= 0 {
+		p.attempts = defaultAttempts
+	}
+	if p.initial <= 0 {
+		p.initial = defaultInitialBackoff
+	}
+	if p.max <= 0 {
+		p.max = defaultMaxBackoff
+	}
+	if p.max < p.initial {
+		p.max = p.initial
+	}
+	return p
+}
+
+// Do calls op until it succeeds, fails with an error that isn't retriable, the
+// attempts are exhausted or the context is done. It returns the last error.
+func (p *Policy) Do(ctx context.Context, op func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || attempt >= p.attempts || !Retriable(err) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(ctx, attempt, err)
+		}
+		if clock.Sleep(ctx, p.clock(), p.backoff(attempt)) != nil {
+			return err
+		}
+	}
+}
+
+// Call is Do for operations returning a value.
+func Call[T any](ctx context.Context, p *Policy, op func() (T, error)) (T, error) {
+	var result T
+	err := p.Do(ctx, func() error {
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+func (p *Policy) clock() clock.Clock {
+	if p.Clock == nil {
+		return clock.Real
+	}
+	return p.Clock
+}
+
+// backoff returns the wait after the failed attempt: the initial backoff doubled
+// per attempt, capped, with up to half of it randomized so clients that failed
+// together don't retry together.
+func (p *Policy) backoff(attempt int) time.Duration {
+	wait := p.max
+	if shift := attempt - 1; shift < 32 && p.initial<<shift < p.max {
+		wait = p.initial << shift
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// Retriable reports whether the call may succeed if it is sent again: the
+// connection failed or timed out, or the provider rate limited or couldn't serve it.
+func Retriable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == limitExceeded
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// errors of some transports only keep the message
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "too many requests")
+}
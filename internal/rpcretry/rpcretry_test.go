@@ -0,0 +1,193 @@
+package rpcretry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/clock"
+)
+
+type codeError struct {
+	code int
+}
+
+func (e codeError) Error() string  { return fmt.Sprintf("rpc error %d", e.code) }
+func (e codeError) ErrorCode() int { return e.code }
+
+func TestRetriable(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		retriable bool
+	}{
+		{"connection reset", fmt.Errorf("post: %w", syscall.ECONNRESET), true},
+		{"rate limited", rpc.HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"bad gateway", rpc.HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{"limit exceeded", codeError{limitExceeded}, true},
+		{"execution reverted", codeError{3}, false},
+		{"unauthorized", rpc.HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("nonce too low"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.retriable, Retriable(tc.err))
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy(Config{Attempts: 3, InitialBackoff: time.Millisecond})
+	var retries int
+	policy.OnRetry = func(context.Context, int, error) { retries++ }
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		retries = 0
+		calls := 0
+		result, err := Call(t.Context(), policy, func() (int, error) {
+			if calls++; calls < 3 {
+				return 0, syscall.ECONNRESET
+			}
+			return 42, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 42, result)
+		require.Equal(t, 2, retries)
+	})
+
+	t.Run("attempts are bounded", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(t.Context(), func() error {
+			calls++
+			return syscall.ECONNRESET
+		})
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("other errors fail right away", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(t.Context(), func() error {
+			calls++
+			return errors.New("execution reverted")
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		p := NewPolicy(Config{InitialBackoff: time.Second, MaxBackoff: 3 * time.Second})
+		for attempt := 1; attempt < 100; attempt++ {
+			require.LessOrEqual(t, p.backoff(attempt), 3*time.Second)
+		}
+		require.GreaterOrEqual(t, p.backoff(1), 500*time.Millisecond)
+	})
+
+	t.Run("retries wait for the backoff", func(t *testing.T) {
+		fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+		p := NewPolicy(Config{Attempts: 2, InitialBackoff: time.Minute})
+		p.Clock = fake
+		var calls atomic.Int32
+		done := make(chan error, 1)
+		go func() {
+			done <- p.Do(t.Context(), func() error {
+				if calls.Add(1) == 1 {
+					return syscall.ECONNRESET
+				}
+				return nil
+			})
+		}()
+
+		fake.WaitTimers(1)
+		require.Equal(t, int32(1), calls.Load())
+		fake.Advance(29 * time.Second)
+		require.Equal(t, int32(1), calls.Load())
+		fake.Advance(31 * time.Second)
+		require.NoError(t, <-done)
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("the context ends the backoff", func(t *testing.T) {
+		p := NewPolicy(Config{Attempts: 2, InitialBackoff: time.Hour})
+		p.Clock = clock.NewFake(time.Unix(1_700_000_000, 0))
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		err := p.Do(ctx, func() error { return syscall.ECONNRESET })
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+	})
+}
+
+func TestClient(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch calls.Add(1) {
+		case 1:
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32005,"message":"limit exceeded"}}`, req.ID)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x14a34"}`, req.ID)
+		}
+	}))
+	defer srv.Close()
+
+	eth, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+	client := NewClient(eth, NewPolicy(Config{InitialBackoff: time.Millisecond}))
+	defer client.Close()
+
+	chainID, err := client.ChainID(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(84532), chainID.Int64())
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestLazyClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x14a34"}`, req.ID)
+	}))
+	defer srv.Close()
+
+	var dials atomic.Int32
+	client := NewLazyClient(func(ctx context.Context) (*ethclient.Client, error) {
+		if dials.Add(1) == 1 {
+			return nil, syscall.ECONNREFUSED
+		}
+		return ethclient.DialContext(ctx, srv.URL)
+	}, NewPolicy(Config{InitialBackoff: time.Millisecond}))
+	defer client.Close()
+	require.False(t, client.Connected())
+	require.Zero(t, dials.Load(), "created without connecting")
+
+	chainID, err := client.ChainID(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(84532), chainID.Int64())
+	require.True(t, client.Connected())
+	require.Equal(t, int32(2), dials.Load(), "the refused connection is retried")
+
+	_, err = client.BlockNumber(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int32(2), dials.Load(), "the connection is kept")
+}
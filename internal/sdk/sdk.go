@@ -47,6 +47,9 @@ type (
 	// ExactEIP3009Payload represents the exact payment payload for EVM networks
 	ExactEIP3009Payload = evm.ExactEIP3009Payload
 
+	// ERC6492SignatureData holds the parts of an ERC-6492 wrapped signature
+	ERC6492SignatureData = evm.ERC6492SignatureData
+
 	// ExactPermit2Payload represents the Permit2 payment payload
 	ExactPermit2Payload = evm.ExactPermit2Payload
 
@@ -111,6 +114,9 @@ var (
 // =============================================================================
 
 var (
+	// HashTypedData hashes arbitrary EIP-712 typed data
+	HashTypedData = evm.HashTypedData
+
 	// HashEIP3009Authorization hashes EIP-3009 TransferWithAuthorization data
 	HashEIP3009Authorization = evm.HashEIP3009Authorization
 
@@ -0,0 +1,173 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AWSSecretsManager reads secrets of AWS Secrets Manager with the credentials
+// of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables.
+type AWSSecretsManager struct {
+	client *http.Client
+	// endpoint returns the API endpoint of the region
+	endpoint func(region string) string
+	now      func() time.Time
+}
+
+func NewAWSSecretsManager() *AWSSecretsManager {
+	return &AWSSecretsManager{
+		client: http.DefaultClient,
+		endpoint: func(region string) string {
+			return fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", region)
+		},
+		now: time.Now,
+	}
+}
+
+// Resolve reads the current version of the secret "<name or ARN>[?query]".
+// The query may set the region, AWS_REGION by default, and the key of the
+// value to return if the secret is a JSON object.
+func (m *AWSSecretsManager) Resolve(ctx context.Context, ref string) (string, error) {
+	secretID, rawQuery, _ := strings.Cut(ref, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	region := query.Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return "", fmt.Errorf("no region, set ?region= or AWS_REGION")
+	}
+	creds := awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return "", fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint(region), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	creds.sign(req, body, region, "secretsmanager", m.now())
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("secrets manager returned status %d: %s", resp.StatusCode, msg)
+	}
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+
+	key := query.Get("key")
+	if key == "" {
+		return secret.SecretString, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no string field %q", key)
+	}
+	return value, nil
+}
+
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// sign adds a Signature Version 4 authorization to the request.
+func (c awsCredentials) sign(req *http.Request, body []byte, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	// signed headers are sorted by name
+	signed := []string{"content-type", "host", "x-amz-date"}
+	if c.sessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	signed = append(signed, "x-amz-target")
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	signedHeaders := strings.Join(signed, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, headers.String(), signedHeaders, sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(awsSigningKey(c.secretAccessKey, date, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKeyID, scope, signedHeaders, signature))
+}
+
+// awsSigningKey derives the Signature Version 4 key of a day, region and service.
+func awsSigningKey(secretAccessKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
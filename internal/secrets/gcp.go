@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1/"
+)
+
+// GCPSecretManager accesses secret versions of Google Cloud Secret Manager
+// with the service account of the workload, taken from the metadata server
+// of GCE, GKE and Cloud Run.
+type GCPSecretManager struct {
+	client   *http.Client
+	tokenURL string
+	apiURL   string
+}
+
+func NewGCPSecretManager() *GCPSecretManager {
+	return &GCPSecretManager{
+		client:   http.DefaultClient,
+		tokenURL: gcpMetadataTokenURL,
+		apiURL:   gcpSecretManagerURL,
+	}
+}
+
+// Resolve accesses the secret version "projects/<p>/secrets/<s>/versions/<v>",
+// the latest version if the version is omitted.
+func (m *GCPSecretManager) Resolve(ctx context.Context, name string) (string, error) {
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/secrets/") {
+		return "", fmt.Errorf("expected projects/<project>/secrets/<secret>[/versions/<version>]")
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	token, err := m.accessToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := m.get(ctx, m.apiURL+name+":access", http.Header{"Authorization": {"Bearer " + token}}, &resp); err != nil {
+		return "", err
+	}
+	secret, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret payload: %w", err)
+	}
+	return string(secret), nil
+}
+
+func (m *GCPSecretManager) accessToken(ctx context.Context) (string, error) {
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := m.get(ctx, m.tokenURL, http.Header{"Metadata-Flavor": {"Google"}}, &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func (m *GCPSecretManager) get(ctx context.Context, url string, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
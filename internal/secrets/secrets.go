@@ -0,0 +1,94 @@
+// Package secrets resolves references to secrets kept outside of the
+// configuration. A reference starts with the scheme of its provider:
+//
+//	file:/run/secrets/key                         contents of a file
+//	env:SIGNER_KEY                                an environment variable
+//	gcpsm://projects/p/secrets/s[/versions/v]     Google Cloud Secret Manager
+//	awssm://<name or ARN>[?region=r&key=field]    AWS Secrets Manager
+//
+// Values without a known scheme are literal secrets and returned as they are.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Provider looks up the secret of a reference without its scheme prefix.
+type Provider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, ref string) (string, error)
+
+func (f ProviderFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// Resolver resolves references with the provider registered for their scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// New creates a resolver of files, environment variables and the Google
+// Cloud and AWS secret managers.
+func New() *Resolver {
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("file:", ProviderFunc(readFile))
+	r.Register("env:", ProviderFunc(lookupEnv))
+	r.Register("gcpsm://", NewGCPSecretManager())
+	r.Register("awssm://", NewAWSSecretsManager())
+	return r
+}
+
+// Register makes references starting with prefix resolve with the provider.
+func (r *Resolver) Register(prefix string, provider Provider) {
+	r.providers[prefix] = provider
+}
+
+// IsReference reports whether the value references a secret instead of being one.
+func (r *Resolver) IsReference(value string) bool {
+	for prefix := range r.providers {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the secret the value references, or the value itself if it
+// isn't a reference. Surrounding whitespace, e.g. the trailing newline of a
+// file, is trimmed.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	for prefix, provider := range r.providers {
+		ref, ok := strings.CutPrefix(value, prefix)
+		if !ok {
+			continue
+		}
+		secret, err := provider.Resolve(ctx, ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s%s: %w", prefix, ref, err)
+		}
+		return strings.TrimSpace(secret), nil
+	}
+	return value, nil
+}
+
+func readFile(_ context.Context, path string) (string, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+func lookupEnv(_ context.Context, name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return secret, nil
+}
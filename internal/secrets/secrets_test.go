@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte("abcd\n"), 0o600))
+	t.Setenv("SIGNER_KEY", "ef01")
+
+	r := New()
+	for value, expected := range map[string]string{
+		"file:" + path:   "abcd",
+		"env:SIGNER_KEY": "ef01",
+		"0123":           "0123",
+		"":               "",
+	} {
+		secret, err := r.Resolve(t.Context(), value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, secret, value)
+	}
+
+	_, err := r.Resolve(t.Context(), "env:MISSING_SIGNER_KEY")
+	require.ErrorContains(t, err, "MISSING_SIGNER_KEY is not set")
+	_, err = r.Resolve(t.Context(), "file:"+filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestGCPSecretManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			require.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "t0ken", "expires_in": 3599})
+		case "/v1/projects/p/secrets/signer/versions/latest:access":
+			require.Equal(t, "Bearer t0ken", r.Header.Get("Authorization"))
+			json.NewEncoder(w).Encode(map[string]any{"payload": map[string]string{
+				"data": base64.StdEncoding.EncodeToString([]byte("abcd")),
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	m := NewGCPSecretManager()
+	m.tokenURL = server.URL + "/token"
+	m.apiURL = server.URL + "/v1/"
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("gcpsm://", m)
+
+	secret, err := r.Resolve(t.Context(), "gcpsm://projects/p/secrets/signer")
+	require.NoError(t, err)
+	require.Equal(t, "abcd", secret)
+
+	_, err = r.Resolve(t.Context(), "gcpsm://projects/p/secrets/other")
+	require.ErrorContains(t, err, "status 404")
+	_, err = r.Resolve(t.Context(), "gcpsm://signer")
+	require.Error(t, err)
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	var region string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		require.Equal(t, "20150830T123600Z", r.Header.Get("X-Amz-Date"))
+		auth := r.Header.Get("Authorization")
+		require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/"+region+"/secretsmanager/aws4_request, "), auth)
+		require.Contains(t, auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature=")
+
+		var body struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.SecretId {
+		case "signer":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "abcd"})
+		case "signers":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"default":"ef01"}`})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+		}
+	}))
+	defer server.Close()
+
+	m := NewAWSSecretsManager()
+	m.endpoint = func(string) string { return server.URL + "/" }
+	m.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register("awssm://", m)
+
+	region = "us-east-1"
+	secret, err := r.Resolve(t.Context(), "awssm://signer")
+	require.NoError(t, err)
+	require.Equal(t, "abcd", secret)
+
+	region = "eu-west-1"
+	secret, err = r.Resolve(t.Context(), "awssm://signers?region=eu-west-1&key=default")
+	require.NoError(t, err)
+	require.Equal(t, "ef01", secret)
+
+	_, err = r.Resolve(t.Context(), "awssm://missing?region=eu-west-1")
+	require.ErrorContains(t, err, "ResourceNotFoundException")
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	// example of the Signature Version 4 documentation
+	key := awsSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	require.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
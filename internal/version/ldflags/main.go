@@ -0,0 +1,31 @@
+// Command ldflags prints the linker flags that set the build information of
+// package version: ldflags [version [commit [date]]]. The commit defaults to
+// the HEAD of the git checkout, if any, and the date to now.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/version"
+)
+
+func main() {
+	args := append(os.Args[1:], "", "", "")
+	v, commit, date := args[0], args[1], args[2]
+	if v == "" {
+		v = "dev"
+	}
+	if commit == "" {
+		if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+			commit = strings.TrimSpace(string(out))
+		}
+	}
+	if date == "" {
+		date = time.Now().UTC().Format(time.RFC3339)
+	}
+	fmt.Println(version.LDFlags(v, commit, date))
+}
@@ -0,0 +1,85 @@
+// Package version reports the build of the running binary. Version, Commit and
+// Date are set at link time, see LDFlags; builds without them fall back to the
+// VCS information the Go toolchain embeds.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set with -ldflags "-X ..." at build time
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the build of the binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"goVersion"`
+	// Whether the working tree had uncommitted changes, known for VCS builds only
+	Modified bool `json:"modified,omitempty"`
+}
+
+// Get returns the build information of the binary.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		// installed with go install module@version
+		info.Version = build.Main.Version
+	}
+	return info
+}
+
+// String formats the build for --version output.
+func (i Info) String() string {
+	var b strings.Builder
+	b.WriteString(i.Version)
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		fmt.Fprintf(&b, " (%s", commit)
+		if i.Modified {
+			b.WriteString(", modified")
+		}
+		b.WriteString(")")
+	}
+	if i.Date != "" {
+		fmt.Fprintf(&b, " built %s", i.Date)
+	}
+	fmt.Fprintf(&b, " with %s", i.GoVersion)
+	return b.String()
+}
+
+// LDFlags returns the linker flags setting the build information, for build
+// scripts: go build -ldflags "$(go run ./internal/version/ldflags v1.2.3)".
+func LDFlags(version, commit, date string) string {
+	const pkg = "github.com/gosuda/x402-facilitator/internal/version"
+	return fmt.Sprintf("-X %s.Version=%s -X %s.Commit=%s -X %s.Date=%s", pkg, version, pkg, commit, pkg, date)
+}
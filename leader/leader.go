@@ -0,0 +1,192 @@
+// Package leader elects one leader among facilitator instances sharing a
+// lock in Postgres or Redis. Running two instances keeps a hot standby ready:
+// both verify payments, but only the leader broadcasts settlements and tops
+// up signers, so the same authorization or nonce is never submitted twice.
+// When the leader stops renewing its lock, the standby takes over.
+package leader
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+// Backends holding the lock
+const (
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+)
+
+const (
+	defaultName     = "x402-facilitator"
+	defaultTTL      = 15 * time.Second
+	defaultInterval = 5 * time.Second
+	releaseTimeout  = 5 * time.Second
+)
+
+// Config configures leader election. Zero values select the defaults.
+type Config struct {
+	// Backend holding the lock, "postgres" or "redis". Election is disabled if empty
+	Backend string `mapstructure:"backend"`
+	// URL of the backend, postgres://... or redis://[:password@]host:port[/db]
+	URL string `mapstructure:"url"`
+	// Name of the lock, instances sharing it elect one leader
+	Name string `mapstructure:"name"`
+	// How long a Redis lock outlives a leader that stopped renewing it.
+	// Postgres locks are released when the leader's connection closes. A
+	// leader that couldn't renew the lock for most of the ttl steps down
+	TTL time.Duration `mapstructure:"ttl"`
+	// How often the leader renews the lock and standbys try to take it, shorter than ttl
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// lock is a lock at most one instance holds.
+type lock interface {
+	// acquire tries to take the lock and reports whether it was taken
+	acquire(ctx context.Context) (bool, error)
+	// hold renews the held lock and reports whether it is still held
+	hold(ctx context.Context) (bool, error)
+	// release gives the lock up, if held, and closes the connection
+	release(ctx context.Context) error
+}
+
+// Elector takes part in the election of a leader. A nil Elector is always
+// the leader, so instances without election behave like a single leader.
+type Elector struct {
+	lock     lock
+	interval time.Duration
+	ttl      time.Duration
+	leader   atomic.Bool
+	// Unix nanoseconds until which the last renewal of the lock lets the
+	// instance lead, see lease
+	leaseEnd atomic.Int64
+
+	mu        sync.Mutex
+	onElected []func(ctx context.Context)
+}
+
+// New creates an elector competing for the lock of the config.
+func New(config Config) (*Elector, error) {
+	name := cmp.Or(config.Name, defaultName)
+	interval := cmp.Or(config.Interval, defaultInterval)
+	ttl := cmp.Or(config.TTL, defaultTTL)
+	if interval >= ttl {
+		return nil, fmt.Errorf("leader: interval %s must be shorter than ttl %s", interval, ttl)
+	}
+
+	var l lock
+	var err error
+	switch config.Backend {
+	case BackendPostgres:
+		l, err = newPostgresLock(config.URL, name)
+	case BackendRedis:
+		l, err = newRedisLock(config.URL, name, ttl)
+	default:
+		return nil, fmt.Errorf("leader: unknown backend %q", config.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Elector{lock: l, interval: interval, ttl: ttl}, nil
+}
+
+// IsLeader reports whether the instance currently leads. A leader whose
+// renewals hang stops leading once its lease ends, before the lock expires
+// and a standby takes over.
+func (e *Elector) IsLeader() bool {
+	return e == nil || e.leader.Load() && time.Now().UnixNano() < e.leaseEnd.Load()
+}
+
+// lease returns how long the instance leads after a renewal of the lock
+// started: the ttl minus a margin for the time the renewal took and clock
+// drift, which still outlasts the interval until the next renewal.
+func (e *Elector) lease() time.Duration {
+	return e.ttl - (e.ttl-e.interval)/2
+}
+
+// OnElected registers fn to run whenever the instance becomes the leader,
+// e.g. to pick up the settlements the previous leader left unfinished.
+func (e *Elector) OnElected(fn func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onElected = append(e.onElected, fn)
+}
+
+// Run competes for leadership until ctx is cancelled, then gives the lock up.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.step(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// step renews the lock of the leader or tries to take it as a standby. A step
+// takes at most the interval, so a backend that doesn't answer can't keep a
+// leader from stepping down.
+func (e *Elector) step(ctx context.Context) {
+	leading := e.leader.Load()
+	started := time.Now()
+	stepCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	var held bool
+	var err error
+	if leading {
+		held, err = e.lock.hold(stepCtx)
+	} else {
+		held, err = e.lock.acquire(stepCtx)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		// without the backend the lock can't be renewed, another instance
+		// takes over once it expires
+		logging.For(logging.Leader).Warn().Err(err).Msg("Failed to reach the leader election backend")
+		held = false
+	}
+	if held {
+		e.leaseEnd.Store(started.Add(e.lease()).UnixNano())
+	}
+
+	switch {
+	case held && !leading:
+		e.leader.Store(true)
+		metrics.Leader.Set(1)
+		logging.For(logging.Leader).Info().Msg("Elected leader, broadcasting settlements")
+		e.mu.Lock()
+		callbacks := e.onElected
+		e.mu.Unlock()
+		for _, fn := range callbacks {
+			fn(ctx)
+		}
+	case !held && leading:
+		e.leader.Store(false)
+		metrics.Leader.Set(0)
+		logging.For(logging.Leader).Warn().Msg("Lost leadership, standing by")
+	}
+}
+
+// resign gives the lock up so a standby can take over without waiting for it to expire.
+func (e *Elector) resign() {
+	e.leader.Store(false)
+	e.leaseEnd.Store(0)
+	metrics.Leader.Set(0)
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	if err := e.lock.release(ctx); err != nil {
+		logging.For(logging.Leader).Warn().Err(err).Msg("Failed to release the leader lock")
+	}
+}
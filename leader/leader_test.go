@@ -0,0 +1,216 @@
+package leader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLock replies to acquire and hold with the queued results
+type fakeLock struct {
+	results  []error
+	held     []bool
+	released bool
+}
+
+func (l *fakeLock) next() (bool, error) {
+	held, err := l.held[0], l.results[0]
+	l.held, l.results = l.held[1:], l.results[1:]
+	return held, err
+}
+
+func (l *fakeLock) acquire(context.Context) (bool, error) { return l.next() }
+func (l *fakeLock) hold(context.Context) (bool, error)    { return l.next() }
+func (l *fakeLock) release(context.Context) error {
+	l.released = true
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	var nilElector *Elector
+	require.True(t, nilElector.IsLeader(), "without election every instance leads")
+
+	lock := &fakeLock{
+		held:    []bool{false, true, true, false},
+		results: []error{nil, nil, nil, errors.New("connection refused")},
+	}
+	e := &Elector{lock: lock, interval: time.Second, ttl: 3 * time.Second}
+	elected := 0
+	e.OnElected(func(context.Context) { elected++ })
+
+	e.step(t.Context())
+	require.False(t, e.IsLeader(), "another instance holds the lock")
+	e.step(t.Context())
+	require.True(t, e.IsLeader())
+	e.step(t.Context())
+	require.True(t, e.IsLeader())
+	require.Equal(t, 1, elected)
+	e.step(t.Context())
+	require.False(t, e.IsLeader(), "steps down when the lock can't be renewed")
+
+	e.resign()
+	require.True(t, lock.released)
+}
+
+// hangingLock is taken, then never answers renewals until they are cancelled
+type hangingLock struct{ fakeLock }
+
+func (l *hangingLock) acquire(context.Context) (bool, error) { return true, nil }
+func (l *hangingLock) hold(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestElectorStepsDownWhileRenewalHangs(t *testing.T) {
+	e := &Elector{lock: &hangingLock{}, interval: 50 * time.Millisecond, ttl: 100 * time.Millisecond}
+	e.step(t.Context())
+	require.True(t, e.IsLeader())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.step(t.Context())
+	}()
+	require.Eventually(t, func() bool { return !e.IsLeader() }, e.ttl, 5*time.Millisecond, "the lease ends before the lock expires")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the renewal isn't bounded by the interval")
+	}
+	require.False(t, e.leader.Load())
+}
+
+func TestRedisLock(t *testing.T) {
+	addr := fakeRedis(t, "s3cret")
+	first, err := newRedisLock("redis://:s3cret@"+addr+"/2", "x402", time.Minute)
+	require.NoError(t, err)
+	second, err := newRedisLock("redis://:s3cret@"+addr+"/2", "x402", time.Minute)
+	require.NoError(t, err)
+
+	held, err := first.acquire(t.Context())
+	require.NoError(t, err)
+	require.True(t, held)
+	held, err = second.acquire(t.Context())
+	require.NoError(t, err)
+	require.False(t, held, "the lock is taken")
+
+	held, err = first.hold(t.Context())
+	require.NoError(t, err)
+	require.True(t, held)
+	held, err = second.hold(t.Context())
+	require.NoError(t, err)
+	require.False(t, held, "only the holder can renew the lock")
+
+	require.NoError(t, second.release(t.Context()), "releasing a lock held by another instance leaves it alone")
+	held, err = second.acquire(t.Context())
+	require.NoError(t, err)
+	require.False(t, held)
+
+	require.NoError(t, first.release(t.Context()))
+	held, err = second.acquire(t.Context())
+	require.NoError(t, err)
+	require.True(t, held)
+
+	wrong, err := newRedisLock("redis://:wrong@"+addr, "x402", time.Minute)
+	require.NoError(t, err)
+	_, err = wrong.acquire(t.Context())
+	require.ErrorContains(t, err, "failed to authenticate")
+
+	_, err = newRedisLock("http://"+addr, "x402", time.Minute)
+	require.Error(t, err)
+}
+
+// fakeRedis serves the commands of the lock on a local port, ignoring expiry.
+func fakeRedis(t *testing.T, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	keys := make(map[string]string)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		authenticated := false
+		for {
+			args, err := readCommand(r)
+			if err != nil {
+				return
+			}
+			reply := func() string {
+				mu.Lock()
+				defer mu.Unlock()
+				switch cmd := strings.ToUpper(args[0]); {
+				case cmd == "AUTH":
+					if args[len(args)-1] != password {
+						return "-WRONGPASS invalid password\r\n"
+					}
+					authenticated = true
+					return "+OK\r\n"
+				case !authenticated:
+					return "-NOAUTH Authentication required\r\n"
+				case cmd == "SELECT":
+					return "+OK\r\n"
+				case cmd == "SET":
+					if _, ok := keys[args[1]]; ok {
+						return "$-1\r\n"
+					}
+					keys[args[1]] = args[2]
+					return "+OK\r\n"
+				case cmd == "EVAL" && keys[args[3]] != args[4]:
+					return ":0\r\n"
+				case cmd == "EVAL" && args[1] == releaseScript:
+					delete(keys, args[3])
+					return ":1\r\n"
+				case cmd == "EVAL":
+					return ":1\r\n"
+				default:
+					return "-ERR unknown command\r\n"
+				}
+			}()
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("not an array: %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		arg, err := readReply(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i], _ = arg.(string)
+	}
+	return args, nil
+}
@@ -0,0 +1,75 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+)
+
+// postgresLock is a session-level advisory lock. Postgres releases it when
+// the connection holding it closes, e.g. because the leader died.
+type postgresLock struct {
+	db   *sql.DB
+	key  int64
+	conn *sql.Conn
+}
+
+func newPostgresLock(url, name string) (*postgresLock, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, fmt.Errorf("leader: %w", err)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &postgresLock{db: db, key: int64(h.Sum64())}, nil
+}
+
+func (l *postgresLock) acquire(ctx context.Context) (bool, error) {
+	if l.conn == nil {
+		conn, err := l.db.Conn(ctx)
+		if err != nil {
+			return false, err
+		}
+		l.conn = conn
+	}
+	var locked bool
+	if err := l.conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&locked); err != nil {
+		l.closeConn()
+		return false, err
+	}
+	return locked, nil
+}
+
+func (l *postgresLock) hold(ctx context.Context) (bool, error) {
+	if l.conn == nil {
+		return false, nil
+	}
+	// the lock is held as long as the session that took it is alive
+	if err := l.conn.PingContext(ctx); err != nil {
+		l.closeConn()
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *postgresLock) release(ctx context.Context) error {
+	defer l.db.Close()
+	if l.conn == nil {
+		return nil
+	}
+	defer l.closeConn()
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key)
+	return err
+}
+
+// closeConn drops the session, and with it the lock if it was held.
+func (l *postgresLock) closeConn() {
+	// a session holding a lock must not go back to the pool
+	_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+	l.conn.Close()
+	l.conn = nil
+}
@@ -0,0 +1,208 @@
+package leader
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scripts renewing and releasing the lock only if it still holds the token of
+// the instance, so a lock that expired and was taken by another instance is left alone
+const (
+	renewScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+const redisDialTimeout = 5 * time.Second
+
+// redisLock is a key set with a random token of the instance and an expiry
+// the leader keeps extending.
+type redisLock struct {
+	addr     string
+	tls      bool
+	username string
+	password string
+	db       int
+	key      string
+	token    string
+	ttl      time.Duration
+
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newRedisLock(rawURL, name string, ttl time.Duration) (*redisLock, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, fmt.Errorf("leader: %q is not a redis:// or rediss:// URL", rawURL)
+	}
+	l := &redisLock{addr: u.Host, tls: u.Scheme == "rediss", key: "leader:" + name, ttl: ttl}
+	if u.Port() == "" {
+		l.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		l.username = u.User.Username()
+		l.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if l.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("leader: invalid redis database %q", db)
+		}
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	l.token = hex.EncodeToString(token)
+	return l, nil
+}
+
+func (l *redisLock) acquire(ctx context.Context) (bool, error) {
+	reply, err := l.do(ctx, "SET", l.key, l.token, "NX", "PX", strconv.FormatInt(l.ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	// nil if the key is set already
+	return reply == "OK", nil
+}
+
+func (l *redisLock) hold(ctx context.Context) (bool, error) {
+	reply, err := l.do(ctx, "EVAL", renewScript, "1", l.key, l.token, strconv.FormatInt(l.ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+func (l *redisLock) release(ctx context.Context) error {
+	defer l.close()
+	_, err := l.do(ctx, "EVAL", releaseScript, "1", l.key, l.token)
+	return err
+}
+
+// do sends a command and reads its reply: a string, an int64, nil or an
+// error. The connection is opened on demand and dropped after errors.
+func (l *redisLock) do(ctx context.Context, args ...string) (any, error) {
+	if l.conn == nil {
+		if err := l.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := l.roundTrip(ctx, args)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		l.close()
+	}
+	return reply, err
+}
+
+func (l *redisLock) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var conn net.Conn
+	var err error
+	if l.tls {
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", l.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", l.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	l.conn, l.r = conn, bufio.NewReader(conn)
+
+	if l.password != "" {
+		args := []string{"AUTH", l.password}
+		if l.username != "" {
+			args = []string{"AUTH", l.username, l.password}
+		}
+		if _, err := l.roundTrip(ctx, args); err != nil {
+			l.close()
+			return fmt.Errorf("failed to authenticate to redis: %w", err)
+		}
+	}
+	if l.db != 0 {
+		if _, err := l.roundTrip(ctx, []string{"SELECT", strconv.Itoa(l.db)}); err != nil {
+			l.close()
+			return fmt.Errorf("failed to select redis database: %w", err)
+		}
+	}
+	return nil
+}
+
+func (l *redisLock) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(l.ttl)
+	}
+	l.conn.SetDeadline(deadline)
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(l.conn, cmd.String()); err != nil {
+		return nil, err
+	}
+	return readReply(l.r)
+}
+
+func (l *redisLock) close() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn, l.r = nil, nil
+	}
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readReply reads a RESP reply. Arrays aren't used by the lock.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '_':
+		// RESP3 null
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,264 @@
+// Package metrics exposes the Prometheus metrics of the facilitator.
+package metrics
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "x402_facilitator"
+
+var (
+	// Settlements counts settlement state transitions by network and status
+	Settlements = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "settlements_total",
+		Help:      "Settlement state transitions by network and status.",
+	}, []string{"network", "status"})
+
+	// Refunds counts refund state transitions by network and status
+	Refunds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refunds_total",
+		Help:      "Refund state transitions by network and status.",
+	}, []string{"network", "status"})
+
+	// SettledValueUSD sums the USD value of confirmed settlements, priced when they were submitted
+	SettledValueUSD = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "settled_value_usd_total",
+		Help:      "USD value of confirmed settlements by network and asset, priced at submission.",
+	}, []string{"network", "asset"})
+
+	// SettlementGasUsed sums the gas used by mined settlement transactions, including reverted ones
+	SettlementGasUsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "settlement_gas_used_total",
+		Help:      "Gas used by mined settlement transactions by network and asset.",
+	}, []string{"network", "asset"})
+
+	// SettlementFee sums the fees paid for settlement transactions in the native token
+	SettlementFee = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "settlement_fee_total",
+		Help:      "Fees paid for settlement transactions by network and asset, in units of the fee currency.",
+	}, []string{"network", "asset", "currency"})
+
+	// SettlementFeeUSD sums the fees paid for settlement transactions in USD, priced when they were mined
+	SettlementFeeUSD = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "settlement_fee_usd_total",
+		Help:      "USD value of the fees paid for settlement transactions by network and asset, priced when mined.",
+	}, []string{"network", "asset"})
+
+	// TronEnergyRented sums the energy rented for Tron settlements
+	TronEnergyRented = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tron_energy_rented_total",
+		Help:      "Energy rented for Tron settlements instead of burning TRX, by network.",
+	}, []string{"network"})
+
+	// SettlementQueueDepth is the number of settlements waiting for a worker
+	SettlementQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "settlement_queue_depth",
+		Help:      "Settlements waiting for a worker or for a settlement of the same signer or authorization.",
+	})
+
+	// SettlementQueueWait observes how long settlements waited in the queue before a worker submitted them
+	SettlementQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "settlement_queue_wait_seconds",
+		Help:      "Time settlements waited in the queue before being submitted, by network.",
+		Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"network"})
+
+	// SettlementPriorityQueueWait observes how long settlements of every priority waited in the queue
+	SettlementPriorityQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "settlement_priority_queue_wait_seconds",
+		Help:      "Time settlements waited in the queue before being submitted, by the priority they were queued with.",
+		Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"priority"})
+
+	// SettlementOrderingWait observes how long ordered settlements waited for the earlier settlements of their ordering key
+	SettlementOrderingWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "settlement_ordering_wait_seconds",
+		Help:      "Time settlements with an ordering key waited for the settlements queued before them with the same key, by network.",
+		Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"network"})
+
+	// SettlementsAged counts settlements whose priority was raised by waiting before they were submitted
+	SettlementsAged = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "settlements_aged_total",
+		Help:      "Settlements submitted at a priority raised by the time they waited in the queue.",
+	})
+
+	// SettlementsInFlight is the number of settlements being submitted by a worker
+	SettlementsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "settlements_in_flight",
+		Help:      "Settlements being submitted by a worker.",
+	})
+
+	// Leader is 1 while the instance leads and broadcasts settlements
+	Leader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "1 while the instance is the elected leader broadcasting settlements, 0 while it stands by.",
+	})
+
+	// TenantSettlements counts the settlement state transitions of tenants by tenant, network and status
+	TenantSettlements = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tenant_settlements_total",
+		Help:      "Settlement state transitions of tenants by tenant, network and status.",
+	}, []string{"tenant", "network", "status"})
+
+	// TenantRateLimited counts the requests of tenants rejected by their rate limit
+	TenantRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tenant_rate_limited_total",
+		Help:      "Requests to the payment endpoints rejected by the rate limit of the tenant.",
+	}, []string{"tenant"})
+
+	// ChainCacheLookups counts the lookups of cached chain reads by network, kind and result
+	ChainCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chain_cache_lookups_total",
+		Help:      "Lookups of cached chain reads by network, kind and result: hit, miss, or shared with a concurrent miss.",
+	}, []string{"network", "kind", "result"})
+
+	// RPCRetries counts the RPC calls retried after transient errors by network
+	RPCRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_retries_total",
+		Help:      "RPC calls retried after connection errors, rate limits or overloaded providers by network.",
+	}, []string{"network"})
+
+	// HeadSubscriptions is 1 for networks whose new blocks are delivered by a live subscription
+	HeadSubscriptions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rpc_head_subscription_up",
+		Help:      "Whether the new blocks of the network are delivered by a live RPC subscription, 0 while they are polled.",
+	}, []string{"network"})
+
+	// RPCConnections is the number of open connections to the RPC endpoints of a network
+	RPCConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rpc_connections",
+		Help:      "Open HTTP and websocket connections to the RPC endpoints by network.",
+	}, []string{"network"})
+
+	// ReconciliationFindings counts the discrepancies between the chain and the settlement store by network and kind
+	ReconciliationFindings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconciliation_findings_total",
+		Help:      "Discrepancies the indexer found between the chain and the settlement store by network and kind: orphaned_transfer or missing_receipt.",
+	}, []string{"network", "kind"})
+
+	// IndexedBlock is the last block the indexer reconciled by network
+	IndexedBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "indexed_block",
+		Help:      "Last block whose transfers the indexer reconciled with the settlement store by network.",
+	}, []string{"network"})
+
+	// NetworkPaused is whether an operator paused the settlements of a network
+	NetworkPaused = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "network_paused",
+		Help:      "Whether an operator paused the settlements of the network, 0 while they proceed.",
+	}, []string{"network"})
+
+	// SignerGasBalance is the native balance of a signer when it was last checked, in whole units
+	SignerGasBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "signer_gas_balance",
+		Help:      "Native balance signers pay for gas with by network and signer, in units of the native currency.",
+	}, []string{"network", "signer", "currency"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// SignerBalance is the native balance of a signer when it was last checked.
+type SignerBalance struct {
+	Network  string
+	Signer   string
+	Currency string
+	Balance  float64
+}
+
+// GaugeValue returns the current value of the gauge.
+func GaugeValue(gauge prometheus.Gauge) float64 {
+	var m dto.Metric
+	if err := gauge.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetGauge().GetValue()
+}
+
+// GaugeValues returns the current values of the gauges by the value of the label.
+func GaugeValues(gauges *prometheus.GaugeVec, label string) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		gauges.Collect(ch)
+		close(ch)
+	}()
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+		for _, l := range m.GetLabel() {
+			if l.GetName() == label {
+				values[l.GetValue()] += m.GetGauge().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+// SignerBalances returns the balances recorded in SignerGasBalance, sorted by network and signer.
+func SignerBalances() []SignerBalance {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		SignerGasBalance.Collect(ch)
+		close(ch)
+	}()
+	var balances []SignerBalance
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+		balance := SignerBalance{Balance: m.GetGauge().GetValue()}
+		for _, label := range m.GetLabel() {
+			switch label.GetName() {
+			case "network":
+				balance.Network = label.GetValue()
+			case "signer":
+				balance.Signer = label.GetValue()
+			case "currency":
+				balance.Currency = label.GetValue()
+			}
+		}
+		balances = append(balances, balance)
+	}
+	slices.SortFunc(balances, func(a, b SignerBalance) int {
+		return cmp.Or(cmp.Compare(a.Network, b.Network), cmp.Compare(a.Signer, b.Signer))
+	})
+	return balances
+}
@@ -0,0 +1,49 @@
+package oracle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache reuses the prices of another oracle for a fixed time. Failed lookups are not cached.
+type Cache struct {
+	oracle PriceOracle
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	prices map[string]cachedPrice
+}
+
+type cachedPrice struct {
+	price   float64
+	fetched time.Time
+}
+
+func NewCache(oracle PriceOracle, ttl time.Duration) *Cache {
+	return &Cache{
+		oracle: oracle,
+		ttl:    ttl,
+		prices: make(map[string]cachedPrice),
+	}
+}
+
+func (c *Cache) PriceUSD(ctx context.Context, symbol string) (float64, error) {
+	symbol = normalizeSymbol(symbol)
+
+	c.mu.Lock()
+	cached, ok := c.prices[symbol]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetched) < c.ttl {
+		return cached.price, nil
+	}
+
+	price, err := c.oracle.PriceUSD(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.prices[symbol] = cachedPrice{price: price, fetched: time.Now()}
+	c.mu.Unlock()
+	return price, nil
+}
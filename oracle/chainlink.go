@@ -0,0 +1,128 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/internal/rpcretry"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+)
+
+// defaultChainlinkMaxAge bounds the age of a feed answer if the configuration doesn't say otherwise
+const defaultChainlinkMaxAge = 24 * time.Hour
+
+// aggregatorABI is the part of the Chainlink AggregatorV3Interface used to read prices
+var aggregatorABI = evm.MustParseABI(`[
+	{"name":"decimals","type":"function","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"name":"latestRoundData","type":"function","stateMutability":"view","inputs":[],"outputs":[
+		{"name":"roundId","type":"uint80"},
+		{"name":"answer","type":"int256"},
+		{"name":"startedAt","type":"uint256"},
+		{"name":"updatedAt","type":"uint256"},
+		{"name":"answeredInRound","type":"uint80"}
+	]}
+]`)
+
+// ChainlinkConfig configures Chainlink USD price feeds as price source.
+type ChainlinkConfig struct {
+	// RPC endpoint of the chain the feeds are deployed on
+	RPCURL string `mapstructure:"rpcUrl"`
+	// Addresses of the <symbol>/USD aggregators by symbol
+	Feeds map[string]string `mapstructure:"feeds"`
+	// Answers older than this are rejected, 0 means one day
+	MaxAge time.Duration `mapstructure:"maxAge"`
+}
+
+// Chainlink reads prices from Chainlink <symbol>/USD aggregators.
+type Chainlink struct {
+	caller ethereum.ContractCaller
+	feeds  map[string]common.Address
+	maxAge time.Duration
+}
+
+// DialChainlink connects to the RPC endpoint of the feeds.
+func DialChainlink(config ChainlinkConfig) (*Chainlink, error) {
+	if config.RPCURL == "" {
+		return nil, fmt.Errorf("chainlink oracle: rpc url must be provided")
+	}
+	client, err := rpc.DialOptions(context.Background(), config.RPCURL, outbound.RPCOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("chainlink oracle: failed to connect to %s: %w", config.RPCURL, err)
+	}
+	return NewChainlink(rpcretry.NewClient(ethclient.NewClient(client), rpcretry.NewPolicy(rpcretry.Config{})), config)
+}
+
+func NewChainlink(caller ethereum.ContractCaller, config ChainlinkConfig) (*Chainlink, error) {
+	feeds := make(map[string]common.Address, len(config.Feeds))
+	for symbol, address := range config.Feeds {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("chainlink oracle: invalid feed address %q of %s", address, symbol)
+		}
+		feeds[normalizeSymbol(symbol)] = common.HexToAddress(address)
+	}
+	maxAge := config.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultChainlinkMaxAge
+	}
+	return &Chainlink{
+		caller: caller,
+		feeds:  feeds,
+		maxAge: maxAge,
+	}, nil
+}
+
+func (c *Chainlink) PriceUSD(ctx context.Context, symbol string) (float64, error) {
+	feed, ok := c.feeds[normalizeSymbol(symbol)]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownSymbol, symbol)
+	}
+
+	decimals, err := c.call(ctx, feed, "decimals")
+	if err != nil {
+		return 0, err
+	}
+	round, err := c.call(ctx, feed, "latestRoundData")
+	if err != nil {
+		return 0, err
+	}
+
+	answer := round[1].(*big.Int)
+	updatedAt := time.Unix(round[3].(*big.Int).Int64(), 0)
+	if answer.Sign() <= 0 {
+		return 0, fmt.Errorf("chainlink feed of %s answered %s", symbol, answer)
+	}
+	if age := time.Since(updatedAt); age > c.maxAge {
+		return 0, fmt.Errorf("chainlink feed of %s is stale, last updated %s ago", symbol, age.Round(time.Second))
+	}
+
+	price := new(big.Float).Quo(
+		new(big.Float).SetInt(answer),
+		new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals[0].(uint8))), nil)),
+	)
+	value, _ := price.Float64()
+	return value, nil
+}
+
+func (c *Chainlink) call(ctx context.Context, feed common.Address, method string) ([]any, error) {
+	data, err := aggregatorABI.Pack(method)
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.caller.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s of chainlink feed %s: %w", method, feed.Hex(), err)
+	}
+	results, err := aggregatorABI.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s of chainlink feed %s: %w", method, feed.Hex(), err)
+	}
+	return results, nil
+}
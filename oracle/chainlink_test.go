@@ -0,0 +1,55 @@
+package oracle
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAggregator answers the calls of an AggregatorV3Interface.
+type fakeAggregator struct {
+	answer    *big.Int
+	updatedAt time.Time
+}
+
+func (a *fakeAggregator) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	method, err := aggregatorABI.MethodById(msg.Data)
+	if err != nil {
+		return nil, err
+	}
+	if method.Name == "decimals" {
+		return method.Outputs.Pack(uint8(8))
+	}
+	return method.Outputs.Pack(big.NewInt(1), a.answer, big.NewInt(a.updatedAt.Unix()), big.NewInt(a.updatedAt.Unix()), big.NewInt(1))
+}
+
+func TestChainlink(t *testing.T) {
+	feed := &fakeAggregator{answer: big.NewInt(2_500_12345678), updatedAt: time.Now()}
+	o, err := NewChainlink(feed, ChainlinkConfig{
+		Feeds:  map[string]string{"eth": "0x71041dddad3595F9CEd3DcCFBe3D1F4b0a16Bb70"},
+		MaxAge: time.Hour,
+	})
+	require.NoError(t, err)
+
+	price, err := o.PriceUSD(t.Context(), "ETH")
+	require.NoError(t, err)
+	require.InDelta(t, 2500.12345678, price, 1e-9)
+
+	_, err = o.PriceUSD(t.Context(), "SOL")
+	require.ErrorIs(t, err, ErrUnknownSymbol)
+
+	feed.updatedAt = time.Now().Add(-2 * time.Hour)
+	_, err = o.PriceUSD(t.Context(), "ETH")
+	require.ErrorContains(t, err, "stale")
+
+	feed.updatedAt, feed.answer = time.Now(), big.NewInt(0)
+	_, err = o.PriceUSD(t.Context(), "ETH")
+	require.Error(t, err)
+
+	_, err = NewChainlink(feed, ChainlinkConfig{Feeds: map[string]string{"ETH": "feed"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+)
+
+const (
+	defaultCoingeckoURL = "https://api.coingecko.com/api/v3"
+	coingeckoTimeout    = 10 * time.Second
+)
+
+// defaultCoingeckoIDs maps the symbols of the supported native currencies and stablecoins to Coingecko coin IDs
+var defaultCoingeckoIDs = map[string]string{
+	"ETH":  "ethereum",
+	"POL":  "polygon-ecosystem-token",
+	"AVAX": "avalanche-2",
+	"SOL":  "solana",
+	"SUI":  "sui",
+	"TRX":  "tron",
+	"USDC": "usd-coin",
+	"USDT": "tether",
+}
+
+// CoingeckoConfig configures the Coingecko HTTP API as price source.
+type CoingeckoConfig struct {
+	// Base URL of the API, the public endpoint is used if empty
+	URL string `mapstructure:"url"`
+	// API key, sent as pro key if the URL points to the pro API and as demo key otherwise
+	APIKey string `mapstructure:"apiKey"`
+	// Coingecko coin IDs by symbol, added to the built-in ones
+	IDs map[string]string `mapstructure:"ids"`
+}
+
+// Coingecko queries prices from the Coingecko simple price API.
+type Coingecko struct {
+	url    string
+	apiKey string
+	ids    map[string]string
+	client *http.Client
+}
+
+func NewCoingecko(config CoingeckoConfig) *Coingecko {
+	ids := make(map[string]string, len(defaultCoingeckoIDs)+len(config.IDs))
+	for symbol, id := range defaultCoingeckoIDs {
+		ids[symbol] = id
+	}
+	for symbol, id := range config.IDs {
+		ids[normalizeSymbol(symbol)] = id
+	}
+
+	baseURL := config.URL
+	if baseURL == "" {
+		baseURL = defaultCoingeckoURL
+	}
+	return &Coingecko{
+		url:    strings.TrimRight(baseURL, "/"),
+		apiKey: config.APIKey,
+		ids:    ids,
+		client: outbound.Client(coingeckoTimeout),
+	}
+}
+
+func (c *Coingecko) PriceUSD(ctx context.Context, symbol string) (float64, error) {
+	id, ok := c.ids[normalizeSymbol(symbol)]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownSymbol, symbol)
+	}
+
+	query := url.Values{"ids": {id}, "vs_currencies": {"usd"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/simple/price?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		if strings.Contains(c.url, "pro-api") {
+			req.Header.Set("x-cg-pro-api-key", c.apiKey)
+		} else {
+			req.Header.Set("x-cg-demo-api-key", c.apiKey)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query coingecko: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("coingecko responded with status %d", resp.StatusCode)
+	}
+
+	var prices map[string]map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return 0, fmt.Errorf("failed to decode coingecko response: %w", err)
+	}
+	price, ok := prices[id]["usd"]
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("coingecko has no usd price of %s", id)
+	}
+	return price, nil
+}
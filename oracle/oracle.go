@@ -0,0 +1,128 @@
+// Package oracle provides token prices used to express on-chain amounts in fiat terms.
+package oracle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrUnknownSymbol is returned when an oracle has no price for a symbol
+var ErrUnknownSymbol = errors.New("no price for symbol")
+
+// defaultCacheTTL is how long prices are cached if the configuration doesn't say otherwise
+const defaultCacheTTL = time.Minute
+
+// PriceOracle reports the USD price of a token identified by its symbol (e.g. "ETH").
+type PriceOracle interface {
+	PriceUSD(ctx context.Context, symbol string) (float64, error)
+}
+
+// Config selects the price source. Prices listed in Fixed are never queried,
+// which suits stablecoins that are accounted at their peg.
+type Config struct {
+	// Price source, "coingecko" or "chainlink". Empty disables the oracle unless fixed prices are given
+	Provider string `mapstructure:"provider"`
+	// How long queried prices are reused, 0 means one minute
+	CacheTTL time.Duration `mapstructure:"cacheTtl"`
+	// USD prices by symbol that are never queried
+	Fixed map[string]float64 `mapstructure:"fixed"`
+
+	Coingecko CoingeckoConfig `mapstructure:"coingecko"`
+	Chainlink ChainlinkConfig `mapstructure:"chainlink"`
+}
+
+// New creates the configured oracle. It returns nil if no price source is configured.
+func New(config Config) (PriceOracle, error) {
+	var oracles []PriceOracle
+	if len(config.Fixed) > 0 {
+		oracles = append(oracles, NewStatic(config.Fixed))
+	}
+
+	var provider PriceOracle
+	switch strings.ToLower(config.Provider) {
+	case "":
+	case "coingecko":
+		provider = NewCoingecko(config.Coingecko)
+	case "chainlink":
+		chainlink, err := DialChainlink(config.Chainlink)
+		if err != nil {
+			return nil, err
+		}
+		provider = chainlink
+	default:
+		return nil, fmt.Errorf("unknown price oracle provider %q", config.Provider)
+	}
+	if provider != nil {
+		ttl := config.CacheTTL
+		if ttl == 0 {
+			ttl = defaultCacheTTL
+		}
+		oracles = append(oracles, NewCache(provider, ttl))
+	}
+
+	switch len(oracles) {
+	case 0:
+		return nil, nil
+	case 1:
+		return oracles[0], nil
+	default:
+		return chain(oracles), nil
+	}
+}
+
+// ValueUSD converts an amount in atomic units of a token into USD.
+func ValueUSD(ctx context.Context, o PriceOracle, symbol string, amount *big.Int, decimals int) (float64, error) {
+	price, err := o.PriceUSD(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	value := new(big.Float).SetInt(amount)
+	if decimals > 0 {
+		value.Quo(value, new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	}
+	units, _ := value.Float64()
+	return units * price, nil
+}
+
+// Static serves fixed prices.
+type Static map[string]float64
+
+// NewStatic creates an oracle serving the given USD prices by symbol.
+func NewStatic(prices map[string]float64) Static {
+	static := make(Static, len(prices))
+	for symbol, price := range prices {
+		static[normalizeSymbol(symbol)] = price
+	}
+	return static
+}
+
+func (s Static) PriceUSD(_ context.Context, symbol string) (float64, error) {
+	price, ok := s[normalizeSymbol(symbol)]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownSymbol, symbol)
+	}
+	return price, nil
+}
+
+// chain asks each oracle in order until one knows the symbol.
+type chain []PriceOracle
+
+func (c chain) PriceUSD(ctx context.Context, symbol string) (float64, error) {
+	var err error
+	for _, o := range c {
+		var price float64
+		price, err = o.PriceUSD(ctx, symbol)
+		if !errors.Is(err, ErrUnknownSymbol) {
+			return price, err
+		}
+	}
+	return 0, err
+}
+
+func normalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
@@ -0,0 +1,106 @@
+package oracle
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueUSD(t *testing.T) {
+	o := NewStatic(map[string]float64{"eth": 2500})
+
+	value, err := ValueUSD(t.Context(), o, "ETH", big.NewInt(1_500_000_000_000_000_000), 18)
+	require.NoError(t, err)
+	require.InDelta(t, 3750, value, 1e-9)
+
+	_, err = ValueUSD(t.Context(), o, "USDC", big.NewInt(1), 6)
+	require.ErrorIs(t, err, ErrUnknownSymbol)
+}
+
+func TestCoingecko(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.Equal(t, "/simple/price", r.URL.Path)
+		require.Equal(t, "usd", r.URL.Query().Get("vs_currencies"))
+		require.Equal(t, "secret", r.Header.Get("x-cg-demo-api-key"))
+		switch r.URL.Query().Get("ids") {
+		case "ethereum":
+			w.Write([]byte(`{"ethereum":{"usd":2512.5}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	o, err := New(Config{
+		Provider:  "coingecko",
+		Fixed:     map[string]float64{"USDC": 1},
+		Coingecko: CoingeckoConfig{URL: srv.URL, APIKey: "secret", IDs: map[string]string{"wbtc": "wrapped-bitcoin"}},
+	})
+	require.NoError(t, err)
+
+	t.Run("fixed prices are not queried", func(t *testing.T) {
+		price, err := o.PriceUSD(t.Context(), "usdc")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, price)
+		require.Zero(t, requests.Load())
+	})
+
+	t.Run("queried prices are cached", func(t *testing.T) {
+		for range 3 {
+			price, err := o.PriceUSD(t.Context(), "ETH")
+			require.NoError(t, err)
+			require.Equal(t, 2512.5, price)
+		}
+		require.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("missing prices fail", func(t *testing.T) {
+		_, err := o.PriceUSD(t.Context(), "WBTC")
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrUnknownSymbol)
+
+		_, err = o.PriceUSD(t.Context(), "DOGE")
+		require.ErrorIs(t, err, ErrUnknownSymbol)
+	})
+}
+
+type countingOracle struct {
+	calls atomic.Int32
+}
+
+func (o *countingOracle) PriceUSD(ctx context.Context, symbol string) (float64, error) {
+	return float64(o.calls.Add(1)), nil
+}
+
+func TestCacheExpiry(t *testing.T) {
+	counter := &countingOracle{}
+	cache := NewCache(counter, 10*time.Millisecond)
+
+	first, err := cache.PriceUSD(t.Context(), "ETH")
+	require.NoError(t, err)
+	cached, err := cache.PriceUSD(t.Context(), "eth")
+	require.NoError(t, err)
+	require.Equal(t, first, cached)
+
+	time.Sleep(20 * time.Millisecond)
+	refreshed, err := cache.PriceUSD(t.Context(), "ETH")
+	require.NoError(t, err)
+	require.NotEqual(t, first, refreshed)
+}
+
+func TestNewDisabled(t *testing.T) {
+	o, err := New(Config{})
+	require.NoError(t, err)
+	require.Nil(t, o)
+
+	_, err = New(Config{Provider: "pyth"})
+	require.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package payment
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// Header is the request header clients send the payment of a resource in
+const Header = "X-PAYMENT"
+
+// EncodeHeader returns the value of the X-PAYMENT header carrying the payment,
+// the base64 encoded JSON of the payload.
+func EncodeHeader(payload *types.PaymentPayload) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// DecodeHeader decodes the value of an X-PAYMENT header. Standard and URL-safe
+// base64 are accepted, with or without padding.
+func DecodeHeader(value string) (*types.PaymentPayload, error) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	raw, err := base64.RawStdEncoding.DecodeString(value)
+	if err != nil {
+		raw, err = base64.RawURLEncoding.DecodeString(value)
+	}
+	if err != nil {
+		return nil, errors.New("invalid X-PAYMENT header: not base64 encoded")
+	}
+	var payload types.PaymentPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("invalid X-PAYMENT header: %w", err)
+	}
+	switch {
+	case payload.X402Version <= 0:
+		return nil, errors.New("invalid X-PAYMENT header: x402Version must be a positive protocol version")
+	case payload.Scheme == "" || payload.Network == "":
+		return nil, errors.New("invalid X-PAYMENT header: scheme and network are required")
+	case len(payload.Payload) == 0:
+		return nil, errors.New("invalid X-PAYMENT header: payload is required")
+	}
+	return &payload, nil
+}
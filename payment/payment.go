@@ -0,0 +1,156 @@
+// Package payment builds the payments x402 clients send to resource servers:
+// EIP-3009 authorizations signed by the payer, wrapped in the payment payload
+// that travels base64 encoded in the X-PAYMENT header. cmd/client signs its
+// payments with it, and so can any Go program paying for resources.
+//
+//	payload, err := payment.Sign(requirements, payer, evm.NewRawPrivateSigner(key))
+//	header, err := payment.EncodeHeader(payload)
+//	req.Header.Set(payment.Header, header)
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const (
+	// DefaultValidity is how long authorizations of requirements without a
+	// maxTimeoutSeconds remain valid
+	DefaultValidity = time.Hour
+	// ClockSkew is how far authorizations are backdated, so facilitators whose
+	// clock is behind the payer's can settle them right away
+	ClockSkew = 10 * time.Minute
+)
+
+// Window is the period an authorization can be settled in.
+type Window struct {
+	ValidAfter  time.Time
+	ValidBefore time.Time
+}
+
+// NewWindow returns the window of an authorization signed at now, valid for
+// validity, DefaultValidity if 0, and opening ClockSkew before now.
+func NewWindow(now time.Time, validity time.Duration) Window {
+	if validity <= 0 {
+		validity = DefaultValidity
+	}
+	return Window{ValidAfter: now.Add(-ClockSkew), ValidBefore: now.Add(validity)}
+}
+
+// NewAuthorization returns an EIP-3009 authorization transferring value from
+// the payer to the recipient in the window, with a random nonce.
+func NewAuthorization(from, to string, value *big.Int, window Window) (*evm.Authorization, error) {
+	if !common.IsHexAddress(from) {
+		return nil, fmt.Errorf("invalid payer address: %q", from)
+	}
+	if !common.IsHexAddress(to) {
+		return nil, fmt.Errorf("invalid recipient address: %q", to)
+	}
+	if value == nil || value.Sign() <= 0 {
+		return nil, errors.New("the amount must be positive")
+	}
+	if !window.ValidBefore.After(window.ValidAfter) {
+		return nil, errors.New("the authorization must be valid before a time after it becomes valid")
+	}
+	validAfter := max(window.ValidAfter.Unix(), 0)
+	return &evm.Authorization{
+		From:        common.HexToAddress(from),
+		To:          common.HexToAddress(to),
+		Value:       new(big.Int).Set(value),
+		ValidAfter:  big.NewInt(validAfter),
+		ValidBefore: big.NewInt(window.ValidBefore.Unix()),
+		Nonce:       evm.GenerateEIP3009Nonce(),
+	}, nil
+}
+
+// Sign signs a payment of the requirements by the payer: an authorization of
+// the amount they ask for to their recipient, valid for their
+// maxTimeoutSeconds. The EIP-712 domain of the asset is taken from their
+// extra details, see Domain.
+func Sign(req *types.PaymentRequirements, from string, signer types.Signer) (*types.PaymentPayload, error) {
+	value, ok := new(big.Int).SetString(req.MaxAmountRequired, 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid amount: %q", req.MaxAmountRequired)
+	}
+	domain, err := Domain(req)
+	if err != nil {
+		return nil, err
+	}
+	window := NewWindow(time.Now(), time.Duration(req.MaxTimeoutSeconds)*time.Second)
+	auth, err := NewAuthorization(from, req.PayTo, value, window)
+	if err != nil {
+		return nil, err
+	}
+	return SignAuthorization(req.Scheme, req.Network, auth, domain, signer)
+}
+
+// SignAuthorization signs the authorization in the EIP-712 domain of the token
+// and returns the payment payload carrying it, in the encoding of x402 clients.
+func SignAuthorization(scheme, network string, auth *evm.Authorization, domain *evm.DomainConfig, signer types.Signer) (*types.PaymentPayload, error) {
+	signature, err := evm.SignEip3009(auth, domain, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign authorization: %w", err)
+	}
+	raw, err := json.Marshal(sdk.ExactEIP3009Payload{
+		Signature: "0x" + signature,
+		Authorization: sdk.ExactEIP3009Authorization{
+			From:        auth.From.Hex(),
+			To:          auth.To.Hex(),
+			Value:       auth.Value.String(),
+			ValidAfter:  auth.ValidAfter.String(),
+			ValidBefore: auth.ValidBefore.String(),
+			Nonce:       hexutil.Encode(auth.Nonce[:]),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.PaymentPayload{
+		X402Version: int(types.X402VersionV1),
+		Scheme:      scheme,
+		Network:     network,
+		Payload:     raw,
+	}, nil
+}
+
+// Domain returns the EIP-712 domain of the asset of the requirements: the name
+// and version of their extra details, or those of the known asset of the
+// network. The network is a CAIP-2 identifier or the name of a known chain.
+func Domain(req *types.PaymentRequirements) (*evm.DomainConfig, error) {
+	chainID, ok := evm.ParseCAIP2(req.Network)
+	if !ok {
+		chainID = evm.GetChainID(req.Network)
+	}
+	if chainID == nil {
+		return nil, fmt.Errorf("can't sign payments on network %s", req.Network)
+	}
+	var extra struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if req.Extra != nil {
+		if err := json.Unmarshal(*req.Extra, &extra); err != nil {
+			return nil, fmt.Errorf("invalid extra details: %w", err)
+		}
+	}
+	if extra.Name != "" && extra.Version != "" && common.IsHexAddress(req.Asset) {
+		return evm.NewDomainConfig(extra.Name, extra.Version, chainID, req.Asset), nil
+	}
+	asset, ok := types.ResolveAsset(req.Network, req.Asset)
+	name, _ := asset.Extra["name"].(string)
+	version, _ := asset.Extra["version"].(string)
+	if !ok || name == "" || version == "" {
+		return nil, fmt.Errorf("no EIP-712 domain for asset %s, the requirements need its name and version in extra", req.Asset)
+	}
+	return evm.NewDomainConfig(name, version, chainID, asset.Address), nil
+}
@@ -0,0 +1,113 @@
+package payment
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/mock"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+const payTo = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+
+func TestSign(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	payer, err := evm.GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	req, err := types.BuildPaymentRequirements("eip155:84532", "USDC", "0.01", payTo, types.WithMaxTimeout(120))
+	require.NoError(t, err)
+
+	payload, err := Sign(req, payer.Hex(), evm.NewRawPrivateSigner(key.Serialize()))
+	require.NoError(t, err)
+	require.Equal(t, req.Network, payload.Network)
+
+	evmPayload, err := evm.ParsePayload(payload.Payload)
+	require.NoError(t, err)
+	auth := evmPayload.Authorization
+	require.Equal(t, payer, auth.From)
+	require.Equal(t, "10000", auth.Value.String())
+	now := time.Now().Unix()
+	require.InDelta(t, now-int64(ClockSkew.Seconds()), auth.ValidAfter.Int64(), 5)
+	require.InDelta(t, now+120, auth.ValidBefore.Int64(), 5)
+
+	// the facilitator accepts the payment
+	chain := mock.NewEVMSigner(84532, "0x00000000000000000000000000000000000000fa")
+	chain.SetBalance(req.Asset, payer.Hex(), big.NewInt(10_000))
+	config := facilitator.NetworkConfig{Network: "eip155:84532"}
+	require.NoError(t, config.Normalize())
+	f, err := facilitator.NewEVMFacilitatorWithSigner(config, chain)
+	require.NoError(t, err)
+	verified, err := f.Verify(t.Context(), payload, req)
+	require.NoError(t, err)
+	require.True(t, verified.IsValid, verified.InvalidReason)
+
+	t.Run("invalid payments", func(t *testing.T) {
+		signer := evm.NewRawPrivateSigner(key.Serialize())
+		for name, change := range map[string]func(*types.PaymentRequirements){
+			"amount":    func(r *types.PaymentRequirements) { r.MaxAmountRequired = "0" },
+			"recipient": func(r *types.PaymentRequirements) { r.PayTo = "merchant" },
+			"network":   func(r *types.PaymentRequirements) { r.Network = "solana:devnet" },
+			"asset":     func(r *types.PaymentRequirements) { r.Asset, r.Extra = payTo, nil },
+		} {
+			invalid := *req
+			change(&invalid)
+			_, err := Sign(&invalid, payer.Hex(), signer)
+			require.Error(t, err, name)
+		}
+	})
+}
+
+func TestDomain(t *testing.T) {
+	extra := json.RawMessage(`{"name":"Other","version":"1"}`)
+	domain, err := Domain(&types.PaymentRequirements{Network: "eip155:84532", Asset: payTo, Extra: &extra})
+	require.NoError(t, err)
+	require.Equal(t, "Other", domain.Name)
+	require.Equal(t, int64(84532), domain.ChainID.Int64())
+
+	domain, err = Domain(&types.PaymentRequirements{Network: "base-sepolia", Asset: "USDC"})
+	require.NoError(t, err)
+	require.Equal(t, "USDC", domain.Name)
+}
+
+func TestNewWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	require.Equal(t, Window{ValidAfter: now.Add(-ClockSkew), ValidBefore: now.Add(DefaultValidity)}, NewWindow(now, 0))
+	require.Equal(t, now.Add(time.Minute), NewWindow(now, time.Minute).ValidBefore)
+
+	_, err := NewAuthorization(payTo, payTo, big.NewInt(1), Window{ValidAfter: now, ValidBefore: now})
+	require.Error(t, err)
+}
+
+func TestHeader(t *testing.T) {
+	payload := &types.PaymentPayload{
+		X402Version: 1,
+		Scheme:      "exact",
+		Network:     "base-sepolia",
+		Payload:     json.RawMessage(`{"signature":"0x01"}`),
+	}
+	header, err := EncodeHeader(payload)
+	require.NoError(t, err)
+	decoded, err := DecodeHeader(header)
+	require.NoError(t, err)
+	require.Equal(t, payload, decoded)
+
+	raw, err := json.Marshal(payload)
+	require.NoError(t, err)
+	decoded, err = DecodeHeader(base64.RawURLEncoding.EncodeToString(raw))
+	require.NoError(t, err)
+	require.Equal(t, payload, decoded)
+
+	for _, invalid := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte(`{"x402Version":1}`))} {
+		_, err := DecodeHeader(invalid)
+		require.ErrorContains(t, err, "invalid X-PAYMENT header")
+	}
+}
@@ -0,0 +1,78 @@
+// Package paymentctx carries the metadata of the payment a request or
+// background task works on in its context: the request ID, the tenant, the
+// network, the payer, the settlement ID and the reference of the resource
+// server. Every field attached is also added to the context's logger, so log
+// lines of all subsystems handling the payment can be correlated without
+// passing the fields around.
+package paymentctx
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+)
+
+type contextKey struct{}
+
+// Metadata describes the payment a context belongs to. Unknown fields are empty.
+type Metadata struct {
+	// ID of the API request, the X-Request-ID header
+	RequestID string
+	// Tenant whose API key made the request
+	Tenant string
+	// CAIP-2 identifier of the network of the payment
+	Network string
+	// Address of the payer
+	Payer string
+	// ID of the settlement of the payment
+	SettlementID string
+	// Reference the resource server attached to the request, e.g. an order ID
+	Reference string
+}
+
+// From returns the metadata attached to ctx.
+func From(ctx context.Context) Metadata {
+	m, _ := ctx.Value(contextKey{}).(Metadata)
+	return m
+}
+
+// With returns a copy of ctx whose metadata is that of ctx updated with the
+// non-empty fields of m. The context's logger, the global logger if it has
+// none, logs the changed fields.
+func With(ctx context.Context, m Metadata) context.Context {
+	current := From(ctx)
+	fields := logging.FromContext(ctx).With()
+	set := func(field *string, value, key string) {
+		if value != "" && value != *field {
+			*field = value
+			fields = fields.Str(key, value)
+		}
+	}
+	set(&current.RequestID, m.RequestID, "request_id")
+	set(&current.Tenant, m.Tenant, "tenant")
+	set(&current.Network, m.Network, "network")
+	set(&current.Payer, m.Payer, "payer")
+	set(&current.SettlementID, m.SettlementID, "settlement_id")
+	set(&current.Reference, m.Reference, "reference")
+
+	ctx = context.WithValue(ctx, contextKey{}, current)
+	return fields.Logger().WithContext(ctx)
+}
+
+// MarshalZerologObject logs the non-empty fields, e.g. with Event.EmbedObject.
+func (m Metadata) MarshalZerologObject(e *zerolog.Event) {
+	for _, field := range [...]struct{ key, value string }{
+		{"request_id", m.RequestID},
+		{"tenant", m.Tenant},
+		{"network", m.Network},
+		{"payer", m.Payer},
+		{"settlement_id", m.SettlementID},
+		{"reference", m.Reference},
+	} {
+		if field.value != "" {
+			e.Str(field.key, field.value)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package paymentctx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWith(t *testing.T) {
+	var out bytes.Buffer
+	ctx := zerolog.New(&out).WithContext(context.Background())
+	require.Equal(t, Metadata{}, From(ctx))
+
+	ctx = With(ctx, Metadata{RequestID: "req-1", Tenant: "shop"})
+	ctx = With(ctx, Metadata{Network: "eip155:8453", Tenant: "shop"})
+	require.Equal(t, Metadata{RequestID: "req-1", Tenant: "shop", Network: "eip155:8453"}, From(ctx))
+
+	zerolog.Ctx(ctx).Info().Msg("settled")
+	var logged map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &logged))
+	require.Equal(t, map[string]any{
+		"level":      "info",
+		"request_id": "req-1",
+		"tenant":     "shop",
+		"network":    "eip155:8453",
+		"message":    "settled",
+	}, logged)
+
+	out.Reset()
+	logger := zerolog.New(&out)
+	logger.Info().EmbedObject(Metadata{SettlementID: "s1"}).Send()
+	require.JSONEq(t, `{"level":"info","settlement_id":"s1"}`, out.String())
+}
@@ -0,0 +1,173 @@
+// Package receipt signs the receipts of settlements. A receipt states that the
+// facilitator settled a payment of an amount of an asset from a payer to a
+// payee in a transaction. It is signed as EIP-712 typed data by a key of the
+// facilitator, so resource servers holding it can later prove to anyone that
+// the payment was facilitated without trusting the facilitator's API.
+package receipt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// ErrInvalidSignature is returned for receipts whose signature isn't the signer's
+var ErrInvalidSignature = errors.New("receipt signature is invalid")
+
+// Domain is the EIP-712 domain receipts are signed in. It has no chain ID,
+// receipts of all networks are signed alike.
+var Domain = sdk.TypedDataDomain{
+	Name:    "x402 facilitator receipt",
+	Version: "1",
+}
+
+// Types are the EIP-712 types of receipts. The primary type is "Receipt", or
+// "ReferencedReceipt" for receipts of settle requests with a reference, which
+// signs the reference as well. Receipts without a reference keep the type
+// they were always signed with. Addresses are strings, since payers and
+// payees aren't EVM addresses on every network.
+var Types = map[string][]sdk.TypedDataField{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+	},
+	"Receipt":           receiptFields,
+	"ReferencedReceipt": append(slices.Clip(receiptFields), sdk.TypedDataField{Name: "reference", Type: "string"}),
+}
+
+var receiptFields = []sdk.TypedDataField{
+	{Name: "network", Type: "string"},
+	{Name: "payer", Type: "string"},
+	{Name: "payee", Type: "string"},
+	{Name: "amount", Type: "uint256"},
+	{Name: "asset", Type: "string"},
+	{Name: "txHash", Type: "string"},
+	{Name: "timestamp", Type: "uint256"},
+}
+
+// Key signs receipts, e.g. a facilitator.PrivateKeySigner.
+type Key interface {
+	Address() common.Address
+	SignHash(digest []byte) ([]byte, error)
+}
+
+// Hash returns the EIP-712 digest of the receipt the signature is over.
+func Hash(receipt *types.SettlementReceipt) ([]byte, error) {
+	amount, ok := new(big.Int).SetString(receipt.Amount, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid receipt amount %q", receipt.Amount)
+	}
+	primaryType, message := "Receipt", map[string]any{
+		"network":   receipt.Network,
+		"payer":     receipt.Payer,
+		"payee":     receipt.Payee,
+		"amount":    amount,
+		"asset":     receipt.Asset,
+		"txHash":    receipt.TxHash,
+		"timestamp": big.NewInt(receipt.Timestamp),
+	}
+	if receipt.Reference != "" {
+		primaryType, message["reference"] = "ReferencedReceipt", receipt.Reference
+	}
+	return evm.HashTypedData(evm.NewTypedData(Domain, Types, primaryType, message))
+}
+
+// Verify checks that the receipt was signed by its signer. Callers must also
+// check that the signer is a key of the facilitator they trust.
+func Verify(receipt *types.SettlementReceipt) error {
+	digest, err := Hash(receipt)
+	if err != nil {
+		return err
+	}
+	sig, err := hexutil.Decode(receipt.Signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return ErrInvalidSignature
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil || !strings.EqualFold(crypto.PubkeyToAddress(*pub).Hex(), receipt.Signer) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Issuer signs the receipts of settlements and keeps them in the store.
+type Issuer struct {
+	key     Key
+	records store.Store
+}
+
+// NewIssuer creates an issuer signing with the key and keeping receipts in records.
+func NewIssuer(key Key, records store.Store) *Issuer {
+	return &Issuer{key: key, records: records}
+}
+
+// Address returns the address receipts are signed by.
+func (i *Issuer) Address() string {
+	return i.key.Address().Hex()
+}
+
+// Issue signs the receipt, setting its signer and signature, and stores it.
+func (i *Issuer) Issue(ctx context.Context, receipt *types.SettlementReceipt) error {
+	receipt.Signer = i.Address()
+	digest, err := Hash(receipt)
+	if err != nil {
+		return err
+	}
+	sig, err := i.key.SignHash(digest)
+	if err != nil {
+		return fmt.Errorf("failed to sign receipt: %w", err)
+	}
+	receipt.Signature = hexutil.Encode(sig)
+
+	if err := i.records.SaveReceipt(ctx, &store.Receipt{
+		TxHash:    receipt.TxHash,
+		Network:   receipt.Network,
+		Payer:     receipt.Payer,
+		Payee:     receipt.Payee,
+		Amount:    receipt.Amount,
+		Asset:     receipt.Asset,
+		IssuedAt:  time.Unix(receipt.Timestamp, 0),
+		Reference: receipt.Reference,
+		Signer:    receipt.Signer,
+		Signature: receipt.Signature,
+	}); err != nil {
+		return fmt.Errorf("failed to save receipt: %w", err)
+	}
+	return nil
+}
+
+// Get returns the receipt of the settlement transaction, store.ErrNotFound if none was issued.
+func (i *Issuer) Get(ctx context.Context, txHash string) (*types.SettlementReceipt, error) {
+	record, err := i.records.GetReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return &types.SettlementReceipt{
+		Network:   record.Network,
+		Payer:     record.Payer,
+		Payee:     record.Payee,
+		Amount:    record.Amount,
+		Asset:     record.Asset,
+		TxHash:    record.TxHash,
+		Timestamp: record.IssuedAt.Unix(),
+		Reference: record.Reference,
+		Signer:    record.Signer,
+		Signature: record.Signature,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package receipt
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestIssue(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := facilitator.NewPrivateKeySigner(crypto.FromECDSA(key))
+	require.NoError(t, err)
+	issuer := NewIssuer(signer, store.NewMemory())
+
+	receipt := &types.SettlementReceipt{
+		Network:   "eip155:84532",
+		Payer:     "0x857b06519E91e3A54538791bDbb0E22373e36b66",
+		Payee:     "0x209693Bc6afc0C5328bA36FaF03C514EF312287C",
+		Amount:    "10000",
+		Asset:     "0x036CbD53842c5426634e7929541eC2318f3dCF7e",
+		TxHash:    "0x0000000000000000000000000000000000000000000000000000000000000001",
+		Timestamp: 1_700_000_000,
+	}
+	require.NoError(t, issuer.Issue(t.Context(), receipt))
+	require.Equal(t, signer.Address().Hex(), receipt.Signer)
+	require.NoError(t, Verify(receipt))
+
+	stored, err := issuer.Get(t.Context(), receipt.TxHash)
+	require.NoError(t, err)
+	require.Equal(t, receipt, stored)
+
+	// any change invalidates the signature
+	tampered := *receipt
+	tampered.Amount = "20000"
+	require.ErrorIs(t, Verify(&tampered), ErrInvalidSignature)
+	tampered = *receipt
+	tampered.Signer = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+	require.ErrorIs(t, Verify(&tampered), ErrInvalidSignature)
+
+	_, err = issuer.Get(t.Context(), "0x02")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	t.Run("reference", func(t *testing.T) {
+		referenced := *receipt
+		referenced.TxHash = "0x0000000000000000000000000000000000000000000000000000000000000002"
+		referenced.Reference = "order-1"
+		require.NoError(t, issuer.Issue(t.Context(), &referenced))
+		require.NoError(t, Verify(&referenced))
+
+		stored, err := issuer.Get(t.Context(), referenced.TxHash)
+		require.NoError(t, err)
+		require.Equal(t, &referenced, stored)
+
+		// the reference is signed, it can neither be changed nor dropped
+		tampered := referenced
+		tampered.Reference = "order-2"
+		require.ErrorIs(t, Verify(&tampered), ErrInvalidSignature)
+		tampered.Reference = ""
+		require.ErrorIs(t, Verify(&tampered), ErrInvalidSignature)
+	})
+}
@@ -0,0 +1,208 @@
+// Package recipient keeps the addresses that registered to receive payments
+// through the facilitator. Networks whose policy requires registered
+// recipients only settle to them, so the facilitator can't be used to route
+// transfers to arbitrary addresses. Owners register an address by signing a
+// message naming it, the network and the time it was issued.
+package recipient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mr-tron/base58"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+var (
+	// ErrUnsupportedNetwork is returned for networks whose addresses can't prove ownership
+	ErrUnsupportedNetwork = errors.New("recipients can't be registered on this network")
+	// ErrInvalidAddress is returned for addresses that aren't valid on the network
+	ErrInvalidAddress = errors.New("invalid recipient address")
+	// ErrInvalidProof is returned if the signature isn't the address's over the registration message
+	ErrInvalidProof = errors.New("signature doesn't prove ownership of the address")
+	// ErrProofExpired is returned for registration messages issued too long ago or in the future
+	ErrProofExpired = errors.New("registration message expired")
+)
+
+// defaultProofMaxAge is how long a registration message is accepted if not configured
+const defaultProofMaxAge = time.Hour
+
+// clockSkew is how far in the future a registration message may be issued
+const clockSkew = 5 * time.Minute
+
+// Config configures the registration of recipients.
+type Config struct {
+	// How long a signed registration message is accepted after it was issued, 1 hour if 0
+	ProofMaxAge time.Duration `mapstructure:"proofMaxAge"`
+}
+
+// Registration is a request to register an address, signed by its owner.
+type Registration struct {
+	// CAIP-2 identifier of the network, e.g. "eip155:8453"
+	Network string `json:"network"`
+	Address string `json:"address"`
+	// When the owner signed the registration message
+	IssuedAt time.Time `json:"issuedAt"`
+	// Signature of the address over Message: EIP-191 personal_sign in hex on
+	// EVM networks, ed25519 in base58 on Solana
+	Signature string `json:"signature"`
+}
+
+// Message returns the message the owner of the address signs to register it.
+func Message(network, address string, issuedAt time.Time) string {
+	return "x402 facilitator recipient registration\n" +
+		"Network: " + network + "\n" +
+		"Address: " + address + "\n" +
+		"Issued At: " + issuedAt.UTC().Format(time.RFC3339)
+}
+
+// Registry registers recipients and answers whether an address is registered.
+type Registry struct {
+	records     store.Store
+	proofMaxAge time.Duration
+	now         func() time.Time
+}
+
+// New creates a registry keeping the recipients in records.
+func New(records store.Store, config Config) *Registry {
+	maxAge := config.ProofMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultProofMaxAge
+	}
+	return &Registry{records: records, proofMaxAge: maxAge, now: time.Now}
+}
+
+// Register checks the proof of the registration and registers the address.
+// Registering an address again, e.g. after it was revoked, needs a message
+// issued after the revocation, so earlier proofs can't undo it.
+func (r *Registry) Register(ctx context.Context, reg Registration) (*store.Recipient, error) {
+	address, err := normalize(reg.Network, reg.Address)
+	if err != nil {
+		return nil, err
+	}
+	now := r.now()
+	if reg.IssuedAt.After(now.Add(clockSkew)) || now.Sub(reg.IssuedAt) > r.proofMaxAge {
+		return nil, ErrProofExpired
+	}
+	existing, err := r.records.GetRecipient(ctx, reg.Network, address)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if existing != nil && existing.RevokedAt != nil && !reg.IssuedAt.After(*existing.RevokedAt) {
+		return nil, ErrProofExpired
+	}
+
+	message := Message(reg.Network, address, reg.IssuedAt)
+	if err := verifyProof(reg.Network, address, message, reg.Signature); err != nil {
+		return nil, err
+	}
+	recipient := &store.Recipient{
+		Network:   reg.Network,
+		Address:   address,
+		Message:   message,
+		Signature: reg.Signature,
+		CreatedAt: now,
+	}
+	if err := r.records.SaveRecipient(ctx, recipient); err != nil {
+		return nil, err
+	}
+	return recipient, nil
+}
+
+// Revoke revokes the registration of the address, store.ErrNotFound if it has none.
+func (r *Registry) Revoke(ctx context.Context, network, address string) error {
+	if normalized, err := normalize(network, address); err == nil {
+		address = normalized
+	}
+	recipient, err := r.records.GetRecipient(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	if recipient.RevokedAt != nil {
+		return nil
+	}
+	now := r.now()
+	recipient.RevokedAt = &now
+	return r.records.SaveRecipient(ctx, recipient)
+}
+
+// List returns all registrations, including revoked ones, oldest first.
+func (r *Registry) List(ctx context.Context) ([]*store.Recipient, error) {
+	return r.records.ListRecipients(ctx)
+}
+
+// IsRegistered reports whether the address has a valid registration on the network.
+func (r *Registry) IsRegistered(ctx context.Context, network, address string) (bool, error) {
+	normalized, err := normalize(network, address)
+	if err != nil {
+		return false, nil
+	}
+	recipient, err := r.records.GetRecipient(ctx, network, normalized)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return recipient.RevokedAt == nil, nil
+}
+
+// normalize returns the canonical spelling of the address on the network, the
+// checksummed hex address on EVM networks.
+func normalize(network, address string) (string, error) {
+	switch caip.Namespace(network) {
+	case caip.EIP155:
+		if !common.IsHexAddress(address) {
+			return "", ErrInvalidAddress
+		}
+		return common.HexToAddress(address).Hex(), nil
+	case caip.Solana:
+		key, err := base58.Decode(address)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return "", ErrInvalidAddress
+		}
+		return address, nil
+	default:
+		return "", ErrUnsupportedNetwork
+	}
+}
+
+// verifyProof checks that the owner of the normalized address signed the message.
+func verifyProof(network, address, message, signature string) error {
+	switch caip.Namespace(network) {
+	case caip.EIP155:
+		sig, err := hexutil.Decode(signature)
+		if err != nil || len(sig) != crypto.SignatureLength {
+			return fmt.Errorf("%w: expected a 65 byte hex signature", ErrInvalidProof)
+		}
+		// wallets sign with v = 27 or 28
+		if sig[crypto.RecoveryIDOffset] >= 27 {
+			sig[crypto.RecoveryIDOffset] -= 27
+		}
+		pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+		if err != nil || crypto.PubkeyToAddress(*pub) != common.HexToAddress(address) {
+			return ErrInvalidProof
+		}
+		return nil
+	case caip.Solana:
+		sig, err := base58.Decode(signature)
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			return fmt.Errorf("%w: expected a base58 ed25519 signature", ErrInvalidProof)
+		}
+		key, _ := base58.Decode(address)
+		if !ed25519.Verify(ed25519.PublicKey(key), []byte(message), sig) {
+			return ErrInvalidProof
+		}
+		return nil
+	default:
+		return ErrUnsupportedNetwork
+	}
+}
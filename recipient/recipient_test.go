@@ -0,0 +1,106 @@
+package recipient
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestRegistry(t *testing.T) {
+	const network = "eip155:8453"
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	sign := func(address string, issuedAt time.Time) string {
+		sig, err := crypto.Sign(accounts.TextHash([]byte(Message(network, address, issuedAt))), key)
+		require.NoError(t, err)
+		sig[64] += 27
+		return hexutil.Encode(sig)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	registry := New(store.NewMemory(), Config{})
+	registry.now = func() time.Time { return now }
+
+	t.Run("unregistered addresses aren't registered", func(t *testing.T) {
+		ok, err := registry.IsRegistered(t.Context(), network, address)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("proofs must be signed by the address", func(t *testing.T) {
+		other, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		_, err = registry.Register(t.Context(), Registration{
+			Network:   network,
+			Address:   crypto.PubkeyToAddress(other.PublicKey).Hex(),
+			IssuedAt:  now,
+			Signature: sign(address, now),
+		})
+		require.ErrorIs(t, err, ErrInvalidProof)
+	})
+
+	t.Run("old proofs are rejected", func(t *testing.T) {
+		issued := now.Add(-2 * time.Hour)
+		_, err := registry.Register(t.Context(), Registration{Network: network, Address: address, IssuedAt: issued, Signature: sign(address, issued)})
+		require.ErrorIs(t, err, ErrProofExpired)
+	})
+
+	t.Run("registered addresses are matched in any case", func(t *testing.T) {
+		issued := now.Add(-time.Minute)
+		recipient, err := registry.Register(t.Context(), Registration{Network: network, Address: address, IssuedAt: issued, Signature: sign(address, issued)})
+		require.NoError(t, err)
+		require.Equal(t, address, recipient.Address)
+
+		ok, err := registry.IsRegistered(t.Context(), network, address)
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = registry.IsRegistered(t.Context(), "eip155:1", address)
+		require.NoError(t, err)
+		require.False(t, ok, "registrations are per network")
+	})
+
+	t.Run("revoked addresses need a newer proof", func(t *testing.T) {
+		require.NoError(t, registry.Revoke(t.Context(), network, address))
+		ok, err := registry.IsRegistered(t.Context(), network, address)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		issued := now.Add(-time.Minute)
+		_, err = registry.Register(t.Context(), Registration{Network: network, Address: address, IssuedAt: issued, Signature: sign(address, issued)})
+		require.ErrorIs(t, err, ErrProofExpired, "proofs issued before the revocation are replays")
+
+		now = now.Add(time.Minute)
+		_, err = registry.Register(t.Context(), Registration{Network: network, Address: address, IssuedAt: now, Signature: sign(address, now)})
+		require.NoError(t, err)
+		ok, err = registry.IsRegistered(t.Context(), network, address)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+}
+
+func TestSolanaProof(t *testing.T) {
+	const network = "solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1"
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	address := base58.Encode(pub)
+	issued := time.Now()
+	signature := base58.Encode(ed25519.Sign(priv, []byte(Message(network, address, issued))))
+
+	registry := New(store.NewMemory(), Config{})
+	_, err = registry.Register(t.Context(), Registration{Network: network, Address: address, IssuedAt: issued.Add(time.Second), Signature: signature})
+	require.ErrorIs(t, err, ErrInvalidProof, "the issue time is part of the message")
+	_, err = registry.Register(t.Context(), Registration{Network: network, Address: address, IssuedAt: issued, Signature: signature})
+	require.NoError(t, err)
+
+	_, err = registry.Register(t.Context(), Registration{Network: "tron:mainnet", Address: address, IssuedAt: issued, Signature: signature})
+	require.ErrorIs(t, err, ErrUnsupportedNetwork)
+}
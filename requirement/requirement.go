@@ -0,0 +1,169 @@
+// Package requirement keeps the payment requirements resource servers
+// register, so verify and settle requests can name them by ID instead of
+// repeating them. Both endpoints then check a payment against the very same
+// requirements. IDs are derived from the requirements and the tenant that
+// registered them, registering the same requirements again returns the same ID.
+// Requirements expire a TTL after they were last registered.
+package requirement
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// ErrNotFound is returned for IDs that weren't registered by the tenant
+var ErrNotFound = errors.New("unknown requirements ID")
+
+const (
+	// idPrefix starts every ID, so they can't be mistaken for other identifiers
+	idPrefix = "req_"
+	// maxCached bounds the requirements kept in memory
+	maxCached = 10_000
+	// defaultTTL is how long requirements stay registered if the configuration doesn't say otherwise
+	defaultTTL = 7 * 24 * time.Hour
+	// pruneInterval is how often expired requirements are deleted from the store
+	pruneInterval = time.Hour
+)
+
+// Config configures the registered requirements.
+type Config struct {
+	// How long requirements stay registered after they were last registered, 0 means 7 days
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// Registry registers requirements and looks them up by ID. Requirements never
+// change once registered, so they are kept in memory after the first lookup.
+type Registry struct {
+	records store.Store
+	ttl     time.Duration
+	now     func() time.Time
+	// when expired requirements were last deleted, in unix nanoseconds
+	pruned atomic.Int64
+
+	mu    sync.Mutex
+	cache map[string]*store.Requirements
+}
+
+// New creates a registry keeping the requirements in records.
+func New(records store.Store, config Config) *Registry {
+	return &Registry{
+		records: records,
+		ttl:     cmp.Or(config.TTL, defaultTTL),
+		now:     time.Now,
+		cache:   make(map[string]*store.Requirements),
+	}
+}
+
+// Register stores the requirements of the tenant and returns their ID.
+// Requirements registered again once half their TTL passed are renewed.
+func (r *Registry) Register(ctx context.Context, tenant string, req *types.PaymentRequirements) (string, error) {
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode requirements: %w", err)
+	}
+	id := requirementsID(tenant, encoded)
+	now := r.now()
+	if record, err := r.load(ctx, id); err == nil && now.Sub(record.CreatedAt) < r.ttl/2 {
+		return id, nil
+	} else if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+
+	record := &store.Requirements{ID: id, Tenant: tenant, Requirements: string(encoded), CreatedAt: now}
+	if err := r.records.SaveRequirements(ctx, record); err != nil {
+		return "", err
+	}
+	r.remember(record)
+	r.prune(ctx, now)
+	return id, nil
+}
+
+// Get returns the requirements the tenant registered under the ID,
+// ErrNotFound if there are none.
+func (r *Registry) Get(ctx context.Context, tenant, id string) (*types.PaymentRequirements, error) {
+	record, err := r.load(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	// IDs of other tenants are reported as unknown, not to reveal them
+	if record.Tenant != tenant || r.expired(record) {
+		return nil, ErrNotFound
+	}
+
+	var req types.PaymentRequirements
+	if err := json.Unmarshal([]byte(record.Requirements), &req); err != nil {
+		return nil, fmt.Errorf("failed to decode requirements %s: %w", id, err)
+	}
+	return &req, nil
+}
+
+// load returns the record with the ID from the cache or the store. Expired
+// records are read from the store again, another instance may have renewed them.
+func (r *Registry) load(ctx context.Context, id string) (*store.Requirements, error) {
+	r.mu.Lock()
+	record, ok := r.cache[id]
+	r.mu.Unlock()
+	if ok && !r.expired(record) {
+		return record, nil
+	}
+
+	record, err := r.records.GetRequirements(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.remember(record)
+	return record, nil
+}
+
+func (r *Registry) expired(record *store.Requirements) bool {
+	return r.now().Sub(record.CreatedAt) >= r.ttl
+}
+
+// prune deletes the expired requirements from the store, at most every
+// pruneInterval. Failures are only logged, the next interval tries again.
+func (r *Registry) prune(ctx context.Context, now time.Time) {
+	last := r.pruned.Load()
+	if now.UnixNano()-last < int64(pruneInterval) || !r.pruned.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	deleted, err := r.records.DeleteRequirements(ctx, now.Add(-r.ttl))
+	if err != nil {
+		logging.Ctx(ctx, logging.HTTP).Warn().Err(err).Msg("Failed to prune expired requirements")
+		return
+	}
+	if deleted > 0 {
+		logging.Ctx(ctx, logging.HTTP).Debug().Int("requirements", deleted).Msg("Pruned expired requirements")
+	}
+}
+
+func (r *Registry) remember(record *store.Requirements) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cache) >= maxCached {
+		clear(r.cache)
+	}
+	r.cache[record.ID] = record
+}
+
+// requirementsID derives the ID of the encoded requirements of the tenant.
+func requirementsID(tenant string, encoded []byte) string {
+	h := sha256.New()
+	h.Write([]byte(tenant))
+	h.Write([]byte{0})
+	h.Write(encoded)
+	return idPrefix + hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,105 @@
+package requirement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestRegistry(t *testing.T) {
+	records := store.NewMemory()
+	registry := New(records, Config{})
+	req := &types.PaymentRequirements{
+		Scheme:            "evm",
+		Network:           "eip155:8453",
+		MaxAmountRequired: "10000",
+		PayTo:             "0x00000000000000000000000000000000000000aa",
+		Asset:             "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+		Resource:          "https://shop.example/article",
+	}
+
+	id, err := registry.Register(t.Context(), "shop", req)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(id, "req_"))
+
+	t.Run("the same requirements get the same ID", func(t *testing.T) {
+		again, err := registry.Register(t.Context(), "shop", req)
+		require.NoError(t, err)
+		require.Equal(t, id, again)
+
+		other := *req
+		other.MaxAmountRequired = "20000"
+		otherID, err := registry.Register(t.Context(), "shop", &other)
+		require.NoError(t, err)
+		require.NotEqual(t, id, otherID)
+	})
+
+	t.Run("IDs belong to their tenant", func(t *testing.T) {
+		got, err := registry.Get(t.Context(), "shop", id)
+		require.NoError(t, err)
+		require.Equal(t, req, got)
+
+		_, err = registry.Get(t.Context(), "outlet", id)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = registry.Get(t.Context(), "shop", "req_unknown")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		outletID, err := registry.Register(t.Context(), "outlet", req)
+		require.NoError(t, err)
+		require.NotEqual(t, id, outletID)
+	})
+
+	t.Run("other instances read the store", func(t *testing.T) {
+		got, err := New(records, Config{}).Get(t.Context(), "shop", id)
+		require.NoError(t, err)
+		require.Equal(t, req, got)
+	})
+}
+
+func TestRegistryExpiry(t *testing.T) {
+	records := store.NewMemory()
+	registry := New(records, Config{TTL: time.Hour})
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+	req := &types.PaymentRequirements{Scheme: "evm", Network: "eip155:8453", MaxAmountRequired: "10000", PayTo: "0x00000000000000000000000000000000000000aa"}
+
+	id, err := registry.Register(t.Context(), "shop", req)
+	require.NoError(t, err)
+
+	t.Run("registering again renews the requirements", func(t *testing.T) {
+		now = now.Add(40 * time.Minute)
+		again, err := registry.Register(t.Context(), "shop", req)
+		require.NoError(t, err)
+		require.Equal(t, id, again)
+
+		now = now.Add(40 * time.Minute)
+		_, err = registry.Get(t.Context(), "shop", id)
+		require.NoError(t, err)
+	})
+
+	t.Run("requirements expire after the TTL", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		_, err := registry.Get(t.Context(), "shop", id)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("expired requirements are pruned from the store", func(t *testing.T) {
+		other := *req
+		other.MaxAmountRequired = "20000"
+		_, err := registry.Register(t.Context(), "shop", &other)
+		require.NoError(t, err)
+		_, err = records.GetRequirements(t.Context(), id)
+		require.ErrorIs(t, err, store.ErrNotFound)
+
+		again, err := registry.Register(t.Context(), "shop", req)
+		require.NoError(t, err)
+		require.Equal(t, id, again, "expired requirements can be registered again")
+		_, err = registry.Get(t.Context(), "shop", id)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,17 @@
+package evm
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// MustParseABI parses the JSON definition of a contract ABI and panics if it
+// is invalid. It is meant for definitions fixed at compile time.
+func MustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
@@ -0,0 +1,45 @@
+package evm
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func init() {
+	types.RegisterAssetResolver(resolveAsset)
+}
+
+// resolveAsset looks up a preset token by symbol or contract address on a
+// network given by chain name or CAIP-2 identifier. The native currency is
+// resolved by its symbol or NativeAsset.
+func resolveAsset(network, asset string) (types.Asset, bool) {
+	chain := network
+	if chainID, ok := ParseCAIP2(network); ok {
+		chain = GetChainName(chainID)
+	}
+	info := GetChainInfo(chain)
+	if info == nil {
+		return types.Asset{}, false
+	}
+	if IsNativeAsset(asset) || strings.EqualFold(asset, info.NativeCurrency) {
+		return types.Asset{Scheme: types.EVM, Address: NativeAsset, Decimals: NativeDecimals}, true
+	}
+	for symbol, domain := range info.TokenContracts {
+		if !strings.EqualFold(symbol, asset) && !(common.IsHexAddress(asset) && common.HexToAddress(asset) == domain.VerifyingContract) {
+			continue
+		}
+		return types.Asset{
+			Scheme:   types.EVM,
+			Address:  domain.VerifyingContract.Hex(),
+			Decimals: GetTokenDecimals(symbol),
+			Extra: map[string]any{
+				"name":    domain.Name,
+				"version": domain.Version,
+			},
+		}, true
+	}
+	return types.Asset{}, false
+}
@@ -4,29 +4,37 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/types/caip"
 )
 
+// CAIP2Namespace is the CAIP-2 namespace of EVM chains
+const CAIP2Namespace = caip.EIP155
+
+// ToCAIP2 returns the CAIP-2 identifier of a chain ID, e.g. "eip155:8453".
+func ToCAIP2(chainID *big.Int) string {
+	return caip.FromEVM(chainID)
+}
+
+// ParseCAIP2 returns the chain ID of an "eip155:<chainId>" identifier.
+func ParseCAIP2(network string) (*big.Int, bool) {
+	return caip.EVMChainID(network)
+}
+
 func GetChainName(chainID *big.Int) string {
 	if chainID == nil {
 		return ""
 	}
-	return chainName[int(chainID.Int64())]
+	return caip.Name(caip.FromEVM(chainID))
 }
 
-var chainName = map[int]string{
-	1:        "ethereum",
-	11155111: "sepolia",
-	8453:     "base",
-	84532:    "base-sepolia",
-	10:       "optimism",
-	11155420: "optimism-sepolia",
-	42161:    "arbitrum",
-	421614:   "arbitrum-sepolia",
-}
+// NativeDecimals is the number of decimals of the native token on EVM chains
+const NativeDecimals = 18
 
 type ChainInfo struct {
 	ChainID        *big.Int
 	DefaultUrl     string
+	NativeCurrency string
 	TokenContracts map[string]DomainConfig
 }
 
@@ -58,9 +66,19 @@ func GetDomainConfig(chain, token string) *DomainConfig {
 	return &domainConfig
 }
 
+// GetTokenDecimals returns the decimals of a well-known token symbol, or 0 if unknown.
+func GetTokenDecimals(token string) int {
+	return tokenDecimals[token]
+}
+
+var tokenDecimals = map[string]int{
+	"USDC": 6,
+}
+
 var chainInfo = map[string]ChainInfo{
 	"ethereum": {
-		ChainID: big.NewInt(1),
+		ChainID:        big.NewInt(1),
+		NativeCurrency: "ETH",
 		TokenContracts: map[string]DomainConfig{
 			"USDC": {
 				Name:              "USD Coin",
@@ -71,8 +89,9 @@ var chainInfo = map[string]ChainInfo{
 		},
 	},
 	"base": {
-		ChainID:    big.NewInt(8453),
-		DefaultUrl: "https://mainnet.base.org",
+		ChainID:        big.NewInt(8453),
+		DefaultUrl:     "https://mainnet.base.org",
+		NativeCurrency: "ETH",
 		TokenContracts: map[string]DomainConfig{
 			"USDC": {
 				Name:              "USD Coin",
@@ -83,8 +102,9 @@ var chainInfo = map[string]ChainInfo{
 		},
 	},
 	"base-sepolia": {
-		ChainID:    big.NewInt(84532),
-		DefaultUrl: "https://sepolia.base.org",
+		ChainID:        big.NewInt(84532),
+		DefaultUrl:     "https://sepolia.base.org",
+		NativeCurrency: "ETH",
 		TokenContracts: map[string]DomainConfig{
 			"USDC": {
 				Name:              "USDC",
@@ -95,8 +115,9 @@ var chainInfo = map[string]ChainInfo{
 		},
 	},
 	"arbitrum": {
-		ChainID:    big.NewInt(42161),
-		DefaultUrl: "https://arb1.arbitrum.io/rpc",
+		ChainID:        big.NewInt(42161),
+		DefaultUrl:     "https://arb1.arbitrum.io/rpc",
+		NativeCurrency: "ETH",
 		TokenContracts: map[string]DomainConfig{
 			"USDC": {
 				Name:              "USD Coin",
@@ -107,8 +128,9 @@ var chainInfo = map[string]ChainInfo{
 		},
 	},
 	"arbitrum-sepolia": {
-		ChainID:    big.NewInt(421614),
-		DefaultUrl: "https://sepolia-rollup.arbitrum.io/rpc",
+		ChainID:        big.NewInt(421614),
+		DefaultUrl:     "https://sepolia-rollup.arbitrum.io/rpc",
+		NativeCurrency: "ETH",
 		TokenContracts: map[string]DomainConfig{
 			"USDC": {
 				Name:              "USDC",
@@ -0,0 +1,62 @@
+package evm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// NativeAsset is the asset address of payments in the native currency of a
+// chain, e.g. ETH or MATIC, following the convention of DEX aggregators.
+const NativeAsset = "0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE"
+
+// IsNativeAsset reports whether the asset is the native currency address.
+func IsNativeAsset(asset string) bool {
+	return strings.EqualFold(asset, NativeAsset)
+}
+
+// NativePayload is the payload of a native currency payment: a transfer
+// transaction signed by the payer, which the facilitator broadcasts. The payer
+// pays for its gas.
+type NativePayload struct {
+	// Transaction is the hex encoded signed transaction (RLP or typed envelope)
+	Transaction string `json:"transaction"`
+}
+
+// NewNativePayload encodes a signed transfer transaction as payment payload.
+func NewNativePayload(tx *ethTypes.Transaction) (*NativePayload, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &NativePayload{Transaction: hexutil.Encode(raw)}, nil
+}
+
+// ParseNativePayload decodes the signed transaction of a native currency payment.
+func ParseNativePayload(raw []byte) (*ethTypes.Transaction, error) {
+	var payload NativePayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Transaction == "" {
+		return nil, fmt.Errorf("payload has no transaction")
+	}
+	encoded, err := hexutil.Decode(payload.Transaction)
+	if err != nil {
+		return nil, fmt.Errorf("transaction must be hex encoded: %w", err)
+	}
+	tx := new(ethTypes.Transaction)
+	if err := tx.UnmarshalBinary(encoded); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// NativeSender returns the payer of a native currency payment, the signer of its transaction.
+func NativeSender(tx *ethTypes.Transaction) (common.Address, error) {
+	return ethTypes.Sender(ethTypes.LatestSignerForChainID(tx.ChainId()), tx)
+}
@@ -0,0 +1,70 @@
+package evm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+)
+
+// ParsePayload decodes an exact EVM payment payload. Payloads of x402 clients
+// carry the authorization as strings, as in sdk.ExactEIP3009Payload, while
+// payloads built with NewEVMPayload use the encoding of EVMPayload.
+func ParsePayload(raw []byte) (*EVMPayload, error) {
+	var wire sdk.ExactEIP3009Payload
+	if err := json.Unmarshal(raw, &wire); err == nil && wire.Authorization.From != "" {
+		return payloadFromSDK(&wire)
+	}
+
+	var payload EVMPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Authorization == nil {
+		return nil, fmt.Errorf("payload has no authorization")
+	}
+	return &payload, nil
+}
+
+// payloadFromSDK converts the string encoded authorization of x402 clients.
+func payloadFromSDK(wire *sdk.ExactEIP3009Payload) (*EVMPayload, error) {
+	auth := wire.Authorization
+	if !common.IsHexAddress(auth.From) || !common.IsHexAddress(auth.To) {
+		return nil, fmt.Errorf("authorization addresses must be hex addresses")
+	}
+	authorization := &Authorization{
+		From: common.HexToAddress(auth.From),
+		To:   common.HexToAddress(auth.To),
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   **big.Int
+	}{
+		{"value", auth.Value, &authorization.Value},
+		{"validAfter", auth.ValidAfter, &authorization.ValidAfter},
+		{"validBefore", auth.ValidBefore, &authorization.ValidBefore},
+	} {
+		n, ok := new(big.Int).SetString(field.value, 10)
+		if !ok || n.Sign() < 0 {
+			return nil, fmt.Errorf("authorization %s must be a non-negative integer", field.name)
+		}
+		*field.dst = n
+	}
+
+	nonce, err := hexutil.Decode(auth.Nonce)
+	if err != nil || len(nonce) != len(authorization.Nonce) {
+		return nil, fmt.Errorf("authorization nonce must be 32 hex encoded bytes")
+	}
+	copy(authorization.Nonce[:], nonce)
+
+	return &EVMPayload{
+		Signature:     wire.Signature,
+		Authorization: authorization,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package evm
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePayload(t *testing.T) {
+	authorization := NewAuthorization("0x00000000000000000000000000000000000000a1", "0x00000000000000000000000000000000000000b0", big.NewInt(10_000))
+	native, err := json.Marshal(EVMPayload{Signature: "0x01", Authorization: authorization})
+	require.NoError(t, err)
+
+	parsed, err := ParsePayload(native)
+	require.NoError(t, err)
+	require.Equal(t, authorization, parsed.Authorization)
+
+	wire := []byte(`{"signature":"0x01","authorization":{
+		"from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000b0",
+		"value":"10000","validAfter":"0","validBefore":"` + authorization.ValidBefore.String() + `",
+		"nonce":"` + hexutil.Encode(authorization.Nonce[:]) + `"}}`)
+	parsed, err = ParsePayload(wire)
+	require.NoError(t, err)
+	require.Equal(t, "0x01", parsed.Signature)
+	require.Equal(t, authorization, parsed.Authorization, "x402 clients encode the same authorization as strings")
+
+	_, err = ParsePayload([]byte(`{"authorization":{"from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000b0","value":"1","validAfter":"0","validBefore":"1","nonce":"0x01"}}`))
+	require.ErrorContains(t, err, "nonce")
+
+	_, err = ParsePayload([]byte(`{"signature":"0x01"}`))
+	require.Error(t, err)
+}
+
+func TestParsePermitPayload(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	owner, err := GetAddrssFromPrivateKey(key.Serialize())
+	require.NoError(t, err)
+	permit := &Permit{
+		Owner:    owner,
+		Spender:  common.HexToAddress("0x00000000000000000000000000000000000000fa"),
+		Value:    big.NewInt(10_000),
+		Nonce:    big.NewInt(3),
+		Deadline: big.NewInt(1_900_000_000),
+	}
+	domain := NewDomainConfig("Token", "1", big.NewInt(84532), "0x00000000000000000000000000000000000000c0")
+	payload, err := NewPermitPayload(permit, domain, NewRawPrivateSigner(key.Serialize()))
+	require.NoError(t, err)
+	raw, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	parsed, signature, err := ParsePermitPayload(raw)
+	require.NoError(t, err)
+	require.Equal(t, permit, parsed)
+	sig, err := ParseSignature(signature)
+	require.NoError(t, err)
+	digest, err := HashTypedData(parsed.TypedData(domain))
+	require.NoError(t, err)
+	pubkey, err := Ecrecover(digest, sig)
+	require.NoError(t, err)
+	require.True(t, VerifySignature(pubkey, digest, sig[:64]))
+
+	_, _, err = ParsePermitPayload([]byte(`{"signature":"0x01","permit":{"owner":"` + owner.Hex() + `","spender":"` + owner.Hex() + `","value":"-1","nonce":"0","deadline":"1"}}`))
+	require.ErrorContains(t, err, "value")
+
+	_, _, err = ParsePermitPayload(raw[:0:0])
+	require.Error(t, err)
+	_, err = ParsePayload(raw)
+	require.Error(t, err, "permits aren't EIP-3009 authorizations")
+}
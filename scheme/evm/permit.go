@@ -0,0 +1,131 @@
+package evm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// PermitTypes are the EIP-712 types of an EIP-2612 permit
+var PermitTypes = map[string][]sdk.TypedDataField{
+	"Permit": {
+		{Name: "owner", Type: "address"},
+		{Name: "spender", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// Permit is an EIP-2612 approval of the spender to transfer value from the
+// owner, for tokens that don't implement EIP-3009. The facilitator signer is
+// the spender: it submits the permit and then transfers the value to the
+// recipient of the payment.
+type Permit struct {
+	Owner    common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+}
+
+// TypedData returns the EIP-712 typed data of the permit in the domain of the token.
+func (p Permit) TypedData(domain *DomainConfig) TypedData {
+	return NewTypedData(domain.TypedDataDomain(), PermitTypes, "Permit", p.Message())
+}
+
+// Message returns the EIP-712 message of the permit.
+func (p Permit) Message() map[string]any {
+	return map[string]any{
+		"owner":    p.Owner.Hex(),
+		"spender":  p.Spender.Hex(),
+		"value":    p.Value,
+		"nonce":    p.Nonce,
+		"deadline": p.Deadline,
+	}
+}
+
+// PermitPayload is the payload of a payment in an EIP-2612 token. Its numbers
+// are decimal strings, like the authorizations of x402 clients.
+type PermitPayload struct {
+	Signature string        `json:"signature"`
+	Permit    PermitMessage `json:"permit"`
+}
+
+// PermitMessage is the permit as encoded in a PermitPayload.
+type PermitMessage struct {
+	Owner    string `json:"owner"`
+	Spender  string `json:"spender"`
+	Value    string `json:"value"`
+	Nonce    string `json:"nonce"`
+	Deadline string `json:"deadline"`
+}
+
+// NewPermitPayload signs the permit in the domain of the token.
+func NewPermitPayload(permit *Permit, domain *DomainConfig, signer types.Signer) (*PermitPayload, error) {
+	signature, err := SignPermit(permit, domain, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &PermitPayload{
+		Signature: signature,
+		Permit: PermitMessage{
+			Owner:    permit.Owner.Hex(),
+			Spender:  permit.Spender.Hex(),
+			Value:    permit.Value.String(),
+			Nonce:    permit.Nonce.String(),
+			Deadline: permit.Deadline.String(),
+		},
+	}, nil
+}
+
+// SignPermit signs the EIP-712 digest of the permit and returns the hex encoded signature.
+func SignPermit(permit *Permit, domain *DomainConfig, signer types.Signer) (string, error) {
+	digest, err := HashTypedData(permit.TypedData(domain))
+	if err != nil {
+		return "", err
+	}
+	sig, err := signer(digest)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// ParsePermitPayload decodes the permit and signature of an EIP-2612 payment payload.
+func ParsePermitPayload(raw []byte) (*Permit, string, error) {
+	var payload PermitPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, "", err
+	}
+	wire := payload.Permit
+	if !common.IsHexAddress(wire.Owner) || !common.IsHexAddress(wire.Spender) {
+		return nil, "", fmt.Errorf("permit addresses must be hex addresses")
+	}
+	permit := &Permit{
+		Owner:   common.HexToAddress(wire.Owner),
+		Spender: common.HexToAddress(wire.Spender),
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   **big.Int
+	}{
+		{"value", wire.Value, &permit.Value},
+		{"nonce", wire.Nonce, &permit.Nonce},
+		{"deadline", wire.Deadline, &permit.Deadline},
+	} {
+		n, ok := new(big.Int).SetString(field.value, 10)
+		if !ok || n.Sign() < 0 {
+			return nil, "", fmt.Errorf("permit %s must be a non-negative integer", field.name)
+		}
+		*field.dst = n
+	}
+	return permit, payload.Signature, nil
+}
@@ -0,0 +1,60 @@
+package evm
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RevertError is returned by simulated calls that revert.
+type RevertError struct {
+	// Reason decoded from the revert data, empty if it couldn't be decoded
+	Reason string
+	// Revert data returned by the contract, if any
+	Data []byte
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+// dataError is implemented by JSON-RPC errors carrying data, such as the revert
+// data of a failed eth_call.
+type dataError interface {
+	Error() string
+	ErrorData() any
+}
+
+// AsRevertError converts the error of an eth_call into a RevertError if the
+// call reverted. Other errors, e.g. of the connection, are returned unchanged.
+func AsRevertError(err error) error {
+	var rpcErr dataError
+	if errors.As(err, &rpcErr) {
+		if hexData, ok := rpcErr.ErrorData().(string); ok {
+			if data, decodeErr := hexutil.Decode(hexData); decodeErr == nil {
+				return &RevertError{Reason: DecodeRevert(data), Data: data}
+			}
+		}
+	}
+	// nodes that don't return revert data only say so in the message
+	if message := err.Error(); strings.Contains(message, "execution reverted") {
+		_, reason, _ := strings.Cut(message, "execution reverted: ")
+		return &RevertError{Reason: reason}
+	}
+	return err
+}
+
+// DecodeRevert decodes Error(string) and Panic(uint256) revert data. It
+// returns an empty string for other data.
+func DecodeRevert(data []byte) string {
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		return ""
+	}
+	return reason
+}
@@ -0,0 +1,47 @@
+package evm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// rpcError mimics the JSON-RPC error of a reverted eth_call
+type rpcError struct {
+	message string
+	data    any
+}
+
+func (e *rpcError) Error() string  { return e.message }
+func (e *rpcError) ErrorData() any { return e.data }
+
+func TestAsRevertError(t *testing.T) {
+	// Error(string) with "authorization is used"
+	data := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000015" +
+		"617574686f72697a6174696f6e20697320757365640000000000000000000000"
+
+	var revert *RevertError
+	err := AsRevertError(&rpcError{message: "execution reverted", data: data})
+	require.ErrorAs(t, err, &revert)
+	require.Equal(t, "authorization is used", revert.Reason)
+	require.Equal(t, hexutil.MustDecode(data), revert.Data)
+	require.Equal(t, "execution reverted: authorization is used", err.Error())
+
+	// nodes without revert data
+	err = AsRevertError(errors.New("execution reverted: insufficient balance"))
+	require.ErrorAs(t, err, &revert)
+	require.Equal(t, "insufficient balance", revert.Reason)
+
+	// unknown custom errors keep their data
+	err = AsRevertError(&rpcError{message: "execution reverted", data: "0x12345678"})
+	require.ErrorAs(t, err, &revert)
+	require.Empty(t, revert.Reason)
+	require.Equal(t, "execution reverted", err.Error())
+
+	connErr := errors.New("connection refused")
+	require.Equal(t, connErr, AsRevertError(connErr))
+}
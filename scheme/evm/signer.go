@@ -42,20 +42,19 @@ func ToGethSigner(signer types.Signer, chainID *big.Int) bind.SignerFn {
 }
 
 func SignEip3009(auth *Authorization, domain *DomainConfig, signer types.Signer) (string, error) {
-	sig, err := signer(HashEip3009(auth, domain))
+	digest, err := HashEip3009(auth, domain)
+	if err != nil {
+		return "", err
+	}
+	sig, err := signer(digest)
 	if err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(sig), nil
 }
 
-func HashEip3009(auth *Authorization, domain *DomainConfig) []byte {
-	domainSeparator := domain.ToMessageHash()
-	messageHash := auth.ToMessageHash()
-
-	// Final EIP-712 hash
-	var prefix = []byte{0x19, 0x01}
-	return Keccak256(
-		append(prefix, append(domainSeparator, messageHash...)...),
-	)
+// HashEip3009 returns the EIP-712 digest of the authorization in the domain of
+// the token, which the payer signs.
+func HashEip3009(auth *Authorization, domain *DomainConfig) ([]byte, error) {
+	return HashTypedData(auth.TypedData(domain))
 }
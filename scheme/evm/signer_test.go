@@ -46,7 +46,8 @@ func TestPayloadSignVerify(t *testing.T) {
 	payload, err := NewEVMPayload(chain, token,
 		"0x1234567890abcdef1234567890abcdef12345678", "0xabcdefabcdefabcdefabcdefabcdefabcdefabcdef", "100", signer)
 	require.NoError(t, err)
-	message := HashEip3009(payload.Authorization, GetDomainConfig(chain, token))
+	message, err := HashEip3009(payload.Authorization, GetDomainConfig(chain, token))
+	require.NoError(t, err)
 	signature, err := hex.DecodeString(payload.Signature)
 	require.NoError(t, err)
 	pubkey, err := Ecrecover(message, signature)
@@ -0,0 +1,106 @@
+package evm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+)
+
+// TypedData is EIP-712 typed data in the JSON form of eth_signTypedData_v4,
+// the form wallets and ethers.js sign.
+type TypedData = apitypes.TypedData
+
+// HashTypedData returns the EIP-712 digest of the typed data. The whole
+// specification is supported: nested structs, arrays of any type including
+// structs and arrays, dynamic bytes, bytes1 to bytes32, bool and every width
+// of signed and unsigned integers. The domain separator hashes the fields the
+// EIP712Domain type lists.
+func HashTypedData(data TypedData) ([]byte, error) {
+	digest, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+	return digest, nil
+}
+
+// NewTypedData returns the typed data of the message in the domain. Its
+// EIP712Domain type lists the fields of the domain that are set, in the order
+// of the specification, like ethers.js derives it.
+func NewTypedData(domain sdk.TypedDataDomain, types map[string][]sdk.TypedDataField, primaryType string, message map[string]any) TypedData {
+	data := TypedData{
+		Types:       make(apitypes.Types, len(types)+1),
+		PrimaryType: primaryType,
+		Domain: apitypes.TypedDataDomain{
+			Name:              domain.Name,
+			Version:           domain.Version,
+			VerifyingContract: domain.VerifyingContract,
+		},
+		Message: message,
+	}
+	for name, fields := range types {
+		typed := make([]apitypes.Type, len(fields))
+		for i, field := range fields {
+			typed[i] = apitypes.Type{Name: field.Name, Type: field.Type}
+		}
+		data.Types[name] = typed
+	}
+	if _, ok := data.Types["EIP712Domain"]; ok {
+		return data
+	}
+
+	var domainType []apitypes.Type
+	if domain.Name != "" {
+		domainType = append(domainType, apitypes.Type{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		domainType = append(domainType, apitypes.Type{Name: "version", Type: "string"})
+	}
+	if domain.ChainID != nil {
+		data.Domain.ChainId = (*math.HexOrDecimal256)(new(big.Int).Set(domain.ChainID))
+		domainType = append(domainType, apitypes.Type{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != "" {
+		domainType = append(domainType, apitypes.Type{Name: "verifyingContract", Type: "address"})
+	}
+	data.Types["EIP712Domain"] = domainType
+	return data
+}
+
+// TransferWithAuthorizationTypes are the EIP-712 types of an EIP-3009 authorization
+var TransferWithAuthorizationTypes = map[string][]sdk.TypedDataField{
+	"TransferWithAuthorization": {
+		{Name: "from", Type: "address"},
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "validAfter", Type: "uint256"},
+		{Name: "validBefore", Type: "uint256"},
+		{Name: "nonce", Type: "bytes32"},
+	},
+}
+
+// TypedData returns the EIP-712 typed data of the authorization in the domain
+// of the token.
+func (a Authorization) TypedData(domain *DomainConfig) TypedData {
+	return NewTypedData(domain.TypedDataDomain(), TransferWithAuthorizationTypes, "TransferWithAuthorization", map[string]any{
+		"from":        a.From.Hex(),
+		"to":          a.To.Hex(),
+		"value":       a.Value,
+		"validAfter":  a.ValidAfter,
+		"validBefore": a.ValidBefore,
+		"nonce":       a.Nonce[:],
+	})
+}
+
+// TypedDataDomain returns the domain in the form of the SDK.
+func (d DomainConfig) TypedDataDomain() sdk.TypedDataDomain {
+	return sdk.TypedDataDomain{
+		Name:              d.Name,
+		Version:           d.Version,
+		ChainID:           d.ChainID,
+		VerifyingContract: d.VerifyingContract.Hex(),
+	}
+}
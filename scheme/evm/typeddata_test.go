@@ -0,0 +1,123 @@
+package evm
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+)
+
+// typedDataVector is a fixture of testdata/eip712: typed data in the JSON form
+// of eth_signTypedData_v4 and its hashes. The specification example is the
+// one of EIP-712, the others were computed by an encoder written apart from
+// this package after the specification, which reproduces that example.
+type typedDataVector struct {
+	Description     string        `json:"description"`
+	TypedData       TypedData     `json:"typedData"`
+	DomainSeparator hexutil.Bytes `json:"domainSeparator"`
+	StructHash      hexutil.Bytes `json:"structHash"`
+	Digest          hexutil.Bytes `json:"digest"`
+}
+
+func loadTypedDataVectors(t *testing.T) map[string]typedDataVector {
+	files, err := filepath.Glob(filepath.Join("testdata", "eip712", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	vectors := make(map[string]typedDataVector, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var vector typedDataVector
+		require.NoError(t, json.Unmarshal(data, &vector), file)
+		vectors[strings.TrimSuffix(filepath.Base(file), ".json")] = vector
+	}
+	return vectors
+}
+
+func TestHashTypedData(t *testing.T) {
+	for name, vector := range loadTypedDataVectors(t) {
+		t.Run(name, func(t *testing.T) {
+			data := vector.TypedData
+			domainSeparator, err := data.HashStruct("EIP712Domain", data.Domain.Map())
+			require.NoError(t, err)
+			require.Equal(t, vector.DomainSeparator, domainSeparator)
+			structHash, err := data.HashStruct(data.PrimaryType, data.Message)
+			require.NoError(t, err)
+			require.Equal(t, vector.StructHash, structHash)
+
+			digest, err := HashTypedData(data)
+			require.NoError(t, err)
+			require.Equal(t, []byte(vector.Digest), digest)
+
+			// the domain type derived from the fields that are set is the one of the fixture
+			domain := sdk.TypedDataDomain{
+				Name:              data.Domain.Name,
+				Version:           data.Domain.Version,
+				VerifyingContract: data.Domain.VerifyingContract,
+			}
+			if data.Domain.ChainId != nil {
+				domain.ChainID = (*big.Int)(data.Domain.ChainId)
+			}
+			types := make(map[string][]sdk.TypedDataField)
+			for name, fields := range data.Types {
+				if name == "EIP712Domain" {
+					continue
+				}
+				for _, field := range fields {
+					types[name] = append(types[name], sdk.TypedDataField{Name: field.Name, Type: field.Type})
+				}
+			}
+			digest, err = HashTypedData(NewTypedData(domain, types, data.PrimaryType, data.Message))
+			require.NoError(t, err)
+			require.Equal(t, []byte(vector.Digest), digest)
+		})
+	}
+}
+
+func TestHashEip3009(t *testing.T) {
+	vector := loadTypedDataVectors(t)["eip3009"]
+	message := vector.TypedData.Message
+	integer := func(field string) *big.Int {
+		n, ok := new(big.Int).SetString(message[field].(string), 10)
+		require.True(t, ok)
+		return n
+	}
+	auth := &Authorization{
+		From:        common.HexToAddress(message["from"].(string)),
+		To:          common.HexToAddress(message["to"].(string)),
+		Value:       integer("value"),
+		ValidAfter:  integer("validAfter"),
+		ValidBefore: integer("validBefore"),
+		Nonce:       common.HexToHash(message["nonce"].(string)),
+	}
+	domain := vector.TypedData.Domain
+	config := NewDomainConfig(domain.Name, domain.Version, (*big.Int)(domain.ChainId), domain.VerifyingContract)
+
+	digest, err := HashEip3009(auth, config)
+	require.NoError(t, err)
+	require.Equal(t, []byte(vector.Digest), digest)
+}
+
+func TestHashTypedDataInvalid(t *testing.T) {
+	vector := loadTypedDataVectors(t)["mail"]
+	data := vector.TypedData
+	data.Message = map[string]any{"from": data.Message["from"], "contents": "no recipient"}
+	_, err := HashTypedData(data)
+	require.Error(t, err)
+
+	data = vector.TypedData
+	data.Types = apitypes.Types{"EIP712Domain": data.Types["EIP712Domain"], "Mail": {{Name: "urgent", Type: "bool"}}}
+	data.Message = map[string]any{"urgent": "yes"}
+	_, err = HashTypedData(data)
+	require.Error(t, err, "values must match their type")
+}
@@ -1,7 +1,6 @@
 package evm
 
 import (
-	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -69,24 +68,6 @@ type Authorization struct {
 	Nonce       [32]byte
 }
 
-var (
-	// EIP-3009 domain separator
-	AuthorizationTypeHash = Keccak256([]byte("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
-)
-
-func (a Authorization) ToMessageHash() []byte {
-	encoded := bytes.Join([][]byte{
-		AuthorizationTypeHash,
-		padAddress(a.From),
-		padAddress(a.To),
-		padBigInt(a.Value),
-		padBigInt(a.ValidAfter),
-		padBigInt(a.ValidBefore),
-		a.Nonce[:], // already 32 bytes
-	}, nil)
-	return Keccak256(encoded)
-}
-
 func NewDomainConfig(name, version string, chainID *big.Int, verifyingContract string) *DomainConfig {
 	return &DomainConfig{
 		Name:              name,
@@ -105,26 +86,6 @@ type DomainConfig struct {
 	VerifyingContract common.Address
 }
 
-var (
-	// EIP-712 domain separator
-	DomainTypeHash = Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
-)
-
-func (d DomainConfig) ToMessageHash() []byte {
-	nameHash := Keccak256([]byte(d.Name))
-	versionHash := Keccak256([]byte(d.Version))
-	chainID := padBigInt(d.ChainID)
-	contract := padAddress(d.VerifyingContract)
-
-	return Keccak256(
-		DomainTypeHash,
-		nameHash,
-		versionHash,
-		chainID,
-		contract,
-	)
-}
-
 func GetAddrssFromPrivateKey(privateKey []byte) (common.Address, error) {
 	if len(privateKey) != 32 {
 		return common.Address{}, errors.New("invalid private key length")
@@ -151,23 +112,6 @@ func Keccak256(data ...[]byte) []byte {
 	return h.Sum(nil)
 }
 
-func padAddress(addr common.Address) []byte {
-	return append(make([]byte, 12), addr[:]...)
-}
-
-func padBigInt(n *big.Int) []byte {
-	return leftPadBytes(n.Bytes(), 32)
-}
-
-func leftPadBytes(b []byte, size int) []byte {
-	if len(b) >= size {
-		return b
-	}
-	padded := make([]byte, size)
-	copy(padded[size-len(b):], b)
-	return padded
-}
-
 // Utility to convert hex string to Address
 func ParseAddress(hexStr string) (common.Address, error) {
 	var a common.Address
@@ -180,6 +124,20 @@ func ParseAddress(hexStr string) (common.Address, error) {
 	return a, nil
 }
 
+// DecodeSignature decodes a signature of any signer type. 65 byte signatures are
+// normalized like in ParseSignature, others (EIP-1271 and ERC-6492 signatures of
+// smart wallets) are returned unchanged.
+func DecodeSignature(sigHex string) ([]byte, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(sigHex, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return sig, nil
+	}
+	return ParseSignature(sigHex)
+}
+
 func ParseSignature(sigHex string) ([]byte, error) {
 	sigHex = strings.TrimPrefix(sigHex, "0x")
 	sig, err := hex.DecodeString(sigHex)
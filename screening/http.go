@@ -0,0 +1,128 @@
+package screening
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+)
+
+const (
+	defaultChainalysisURL = "https://public.chainalysis.com/api/v1"
+	defaultTRMURL         = "https://api.trmlabs.com/public/v1"
+	screeningTimeout      = 10 * time.Second
+)
+
+// Chainalysis screens addresses with the Chainalysis sanctions API, which
+// lists the sanctions designations of an address.
+type Chainalysis struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewChainalysis(baseURL, apiKey string) *Chainalysis {
+	if baseURL == "" {
+		baseURL = defaultChainalysisURL
+	}
+	return &Chainalysis{
+		url:    strings.TrimRight(baseURL, "/"),
+		apiKey: apiKey,
+		client: outbound.Client(screeningTimeout),
+	}
+}
+
+func (c *Chainalysis) Screen(ctx context.Context, _, address string) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/address/"+url.PathEscape(address), nil)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	var identified struct {
+		Identifications []struct {
+			Category string `json:"category"`
+			Name     string `json:"name"`
+		} `json:"identifications"`
+	}
+	if err := do(c.client, req, "chainalysis", &identified); err != nil {
+		return Result{}, err
+	}
+	if len(identified.Identifications) == 0 {
+		return Result{}, nil
+	}
+	names := make([]string, len(identified.Identifications))
+	for i, identification := range identified.Identifications {
+		names[i] = identification.Name
+	}
+	return Result{Blocked: true, Reason: strings.Join(names, "; ")}, nil
+}
+
+// TRM screens addresses with the TRM Labs sanctions screening API.
+type TRM struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewTRM(baseURL, apiKey string) *TRM {
+	if baseURL == "" {
+		baseURL = defaultTRMURL
+	}
+	return &TRM{
+		url:    strings.TrimRight(baseURL, "/"),
+		apiKey: apiKey,
+		client: outbound.Client(screeningTimeout),
+	}
+}
+
+func (t *TRM) Screen(ctx context.Context, _, address string) (Result, error) {
+	body, err := json.Marshal([]map[string]string{{"address": address}})
+	if err != nil {
+		return Result{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url+"/sanctions/screening", bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(t.apiKey, t.apiKey)
+
+	var screened []struct {
+		Address      string `json:"address"`
+		IsSanctioned bool   `json:"isSanctioned"`
+	}
+	if err := do(t.client, req, "trm", &screened); err != nil {
+		return Result{}, err
+	}
+	for _, entry := range screened {
+		if strings.EqualFold(entry.Address, address) && entry.IsSanctioned {
+			return Result{Blocked: true, Reason: "sanctioned according to TRM"}, nil
+		}
+	}
+	return Result{}, nil
+}
+
+// do sends the request and decodes the JSON response of the provider.
+func do(client *http.Client, req *http.Request, provider string, result any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", provider, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package screening checks the addresses of payments against sanctions lists
+// and the risk rules of compliance providers, so regulated operators can
+// refuse to move funds of sanctioned parties.
+package screening
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Screening providers
+const (
+	ProviderChainalysis = "chainalysis"
+	ProviderTRM         = "trm"
+)
+
+// defaultCacheTTL is how long screening results are reused if the configuration doesn't say otherwise
+const defaultCacheTTL = time.Hour
+
+// Screener decides whether payments from or to an address may be processed.
+type Screener interface {
+	// Screen checks the address on the network, given as CAIP-2 identifier
+	Screen(ctx context.Context, network, address string) (Result, error)
+}
+
+// Result is the outcome of screening an address.
+type Result struct {
+	// Whether payments from or to the address must be rejected
+	Blocked bool
+	// Why the address is blocked, e.g. the sanctions list naming it, for the logs
+	Reason string
+}
+
+// Config selects the screening provider. Payers and recipients are screened
+// when payments are verified and settled.
+type Config struct {
+	// Screening provider, "chainalysis" or "trm". Empty disables screening
+	Provider string `mapstructure:"provider"`
+	// Base URL of the provider API, its public endpoint if empty
+	URL string `mapstructure:"url"`
+	// API key of the provider
+	APIKey string `mapstructure:"apiKey"`
+	// How long results are reused, 0 means one hour
+	CacheTTL time.Duration `mapstructure:"cacheTtl"`
+}
+
+// New creates the configured screener, Noop if screening is disabled.
+func New(config Config) (Screener, error) {
+	var screener Screener
+	switch strings.ToLower(config.Provider) {
+	case "":
+		return Noop{}, nil
+	case ProviderChainalysis:
+		screener = NewChainalysis(config.URL, config.APIKey)
+	case ProviderTRM:
+		screener = NewTRM(config.URL, config.APIKey)
+	default:
+		return nil, fmt.Errorf("unknown screening provider %q", config.Provider)
+	}
+	ttl := config.CacheTTL
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	return NewCache(screener, ttl), nil
+}
+
+// Noop lets every address through.
+type Noop struct{}
+
+func (Noop) Screen(context.Context, string, string) (Result, error) {
+	return Result{}, nil
+}
+
+// Cache reuses the results of another screener for a fixed time. Failed
+// screenings are not cached.
+type Cache struct {
+	screener Screener
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	results map[string]cachedResult
+}
+
+type cachedResult struct {
+	result   Result
+	screened time.Time
+}
+
+func NewCache(screener Screener, ttl time.Duration) *Cache {
+	return &Cache{
+		screener: screener,
+		ttl:      ttl,
+		results:  make(map[string]cachedResult),
+	}
+}
+
+func (c *Cache) Screen(ctx context.Context, network, address string) (Result, error) {
+	key := network + "|" + strings.ToLower(address)
+
+	c.mu.Lock()
+	cached, ok := c.results[key]
+	if ok && time.Since(cached.screened) >= c.ttl {
+		delete(c.results, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok {
+		return cached.result, nil
+	}
+
+	result, err := c.screener.Screen(ctx, network, address)
+	if err != nil {
+		return Result{}, err
+	}
+	c.mu.Lock()
+	c.results[key] = cachedResult{result: result, screened: time.Now()}
+	c.mu.Unlock()
+	return result, nil
+}
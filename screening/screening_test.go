@@ -0,0 +1,89 @@
+package screening
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sanctioned = "0x8576aCC5C05D6Ce88f4e49bf65BdF0C62F91353C"
+	clean      = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+)
+
+func TestChainalysis(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		switch r.URL.Path {
+		case "/address/" + sanctioned:
+			w.Write([]byte(`{"identifications":[{"category":"sanctions","name":"SANCTIONS: OFAC SDN Tornado Cash"}]}`))
+		case "/address/" + clean:
+			w.Write([]byte(`{"identifications":[]}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	screener, err := New(Config{Provider: ProviderChainalysis, URL: srv.URL, APIKey: "secret"})
+	require.NoError(t, err)
+
+	result, err := screener.Screen(t.Context(), "eip155:8453", sanctioned)
+	require.NoError(t, err)
+	require.Equal(t, Result{Blocked: true, Reason: "SANCTIONS: OFAC SDN Tornado Cash"}, result)
+
+	t.Run("results are cached", func(t *testing.T) {
+		for range 3 {
+			result, err := screener.Screen(t.Context(), "eip155:8453", clean)
+			require.NoError(t, err)
+			require.False(t, result.Blocked)
+		}
+		require.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("failures are not cached", func(t *testing.T) {
+		for range 2 {
+			_, err := screener.Screen(t.Context(), "eip155:8453", "0x01")
+			require.ErrorContains(t, err, "status 429")
+		}
+		require.Equal(t, int32(4), requests.Load())
+	})
+}
+
+func TestTRM(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/sanctions/screening", r.URL.Path)
+		user, _, _ := r.BasicAuth()
+		require.Equal(t, "secret", user)
+		var addresses []struct {
+			Address string `json:"address"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&addresses))
+		require.Len(t, addresses, 1)
+		json.NewEncoder(w).Encode([]map[string]any{{"address": addresses[0].Address, "isSanctioned": addresses[0].Address == sanctioned}})
+	}))
+	defer srv.Close()
+
+	screener := NewTRM(srv.URL, "secret")
+	result, err := screener.Screen(t.Context(), "eip155:8453", sanctioned)
+	require.NoError(t, err)
+	require.True(t, result.Blocked)
+	result, err = screener.Screen(t.Context(), "eip155:8453", clean)
+	require.NoError(t, err)
+	require.False(t, result.Blocked)
+}
+
+func TestNew(t *testing.T) {
+	screener, err := New(Config{})
+	require.NoError(t, err)
+	require.Equal(t, Noop{}, screener)
+
+	_, err = New(Config{Provider: "elliptic"})
+	require.ErrorContains(t, err, "unknown screening provider")
+}
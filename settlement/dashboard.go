@@ -0,0 +1,67 @@
+package settlement
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// Activity counts the settlements created in [from, to) by outcome, in buckets
+// of the given length and by network. Empty buckets are included, from is
+// rounded down to the start of its bucket.
+func (m *Manager) Activity(ctx context.Context, from, to time.Time, bucket time.Duration) ([]types.DashboardBucket, []types.DashboardNetwork, error) {
+	from = from.Truncate(bucket)
+	settlements, err := m.store.ListSettlements(ctx, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	activity, networks := newActivity(settlements, from, to, bucket)
+	return activity, networks, nil
+}
+
+func newActivity(settlements []*store.Settlement, from, to time.Time, bucket time.Duration) ([]types.DashboardBucket, []types.DashboardNetwork) {
+	activity := []types.DashboardBucket{}
+	for start := from; start.Before(to); start = start.Add(bucket) {
+		activity = append(activity, types.DashboardBucket{Start: start})
+	}
+
+	byNetwork := make(map[string]*types.DashboardNetwork)
+	for _, s := range settlements {
+		i := int(s.CreatedAt.Sub(from) / bucket)
+		if i < 0 || i >= len(activity) {
+			continue
+		}
+		network, ok := byNetwork[s.Network]
+		if !ok {
+			network = &types.DashboardNetwork{Network: s.Network}
+			byNetwork[s.Network] = network
+		}
+		switch status := Status(s.Status); {
+		case status.IsFailure():
+			activity[i].Failed++
+			network.Failed++
+		case status == StatusQueued:
+			activity[i].Pending++
+			network.Pending++
+		default:
+			activity[i].Settled++
+			network.Settled++
+		}
+	}
+
+	networks := make([]types.DashboardNetwork, 0, len(byNetwork))
+	for _, network := range byNetwork {
+		if finished := network.Settled + network.Failed; finished > 0 {
+			network.ErrorRate = float64(network.Failed) / float64(finished)
+		}
+		networks = append(networks, *network)
+	}
+	slices.SortFunc(networks, func(a, b types.DashboardNetwork) int {
+		return strings.Compare(a.Network, b.Network)
+	})
+	return activity, networks
+}
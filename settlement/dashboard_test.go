@@ -0,0 +1,38 @@
+package settlement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestActivity(t *testing.T) {
+	from := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(network string, status Status, minute int) *store.Settlement {
+		return &store.Settlement{Network: network, Status: string(status), CreatedAt: from.Add(time.Duration(minute) * time.Minute)}
+	}
+	settlements := []*store.Settlement{
+		at("eip155:8453", StatusConfirmed, 0),
+		at("eip155:8453", StatusFailed, 0),
+		at("eip155:8453", StatusSubmitted, 2),
+		at("eip155:84532", StatusQueued, 2),
+	}
+
+	activity, networks := newActivity(settlements, from, from.Add(3*time.Minute), time.Minute)
+	require.Len(t, activity, 3, "empty buckets are included")
+	require.Equal(t, from, activity[0].Start)
+	require.Equal(t, 1, activity[0].Settled)
+	require.Equal(t, 1, activity[0].Failed)
+	require.Zero(t, activity[1].Settled+activity[1].Failed+activity[1].Pending)
+	require.Equal(t, 1, activity[2].Settled)
+	require.Equal(t, 1, activity[2].Pending)
+
+	require.Len(t, networks, 2)
+	require.Equal(t, "eip155:8453", networks[0].Network)
+	require.InDelta(t, 1.0/3, networks[0].ErrorRate, 1e-9)
+	require.Equal(t, "eip155:84532", networks[1].Network)
+	require.Zero(t, networks[1].ErrorRate, "pending settlements didn't fail yet")
+}
@@ -0,0 +1,243 @@
+package settlement
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// authorization identifies the single-use authorization of a payment, ok is
+// false if the facilitator of its network can't tell.
+func (m *Manager) authorization(payload *types.PaymentPayload) (key, payer string, ok bool) {
+	f, config, ok := m.registry.Lookup(payload.Network)
+	if !ok {
+		return "", "", false
+	}
+	reader, ok := f.(facilitator.AuthorizationReader)
+	if !ok {
+		return "", "", false
+	}
+	payer, nonce, ok := reader.Authorization(payload)
+	if !ok {
+		return "", "", false
+	}
+	return config.Network + ":" + strings.ToLower(payer) + ":" + nonce, payer, true
+}
+
+// withPayment adds the network of the payment and its payer, if the
+// facilitator of the network can read it, to the payment metadata of ctx.
+func (m *Manager) withPayment(ctx context.Context, payload *types.PaymentPayload) context.Context {
+	meta := paymentctx.Metadata{Network: payload.Network}
+	if _, payer, ok := m.authorization(payload); ok {
+		meta.Payer = payer
+	}
+	return paymentctx.With(ctx, meta)
+}
+
+// Verify verifies the payment and records its authorization. Authorizations
+// already used by a settlement are rejected, even if that settlement happened
+// before a restart and is not yet visible on chain.
+func (m *Manager) Verify(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentVerifyResponse, error) {
+	ctx = m.withPayment(ctx, payload)
+	resp, err := m.registry.Verify(ctx, payload, req)
+	if err != nil || !resp.IsValid {
+		return resp, err
+	}
+	key, _, ok := m.authorization(payload)
+	if !ok {
+		return resp, nil
+	}
+
+	payment, used, err := m.usedPayment(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return &types.PaymentVerifyResponse{
+			IsValid:       false,
+			InvalidReason: types.ErrAuthorizationUsed.Error(),
+			Payer:         resp.Payer,
+		}, nil
+	}
+	if payment == nil {
+		now := m.clock.Now()
+		payment = &store.Payment{Key: key, PayloadHash: payloadHash(payload), CreatedAt: now, UpdatedAt: now}
+		// only inserted, a settlement claiming the authorization meanwhile keeps its record
+		if _, err := m.store.InsertPayment(ctx, payment); err != nil {
+			// settling checks the authorization again, only the record of the verification is lost
+			logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Msg("Failed to store verified payment")
+		}
+		m.pruneVerified(now)
+	}
+	return resp, nil
+}
+
+// pruneVerified deletes the records of verified payments no settlement used
+// once they are older than verifiedPaymentTTL, at most every
+// paymentPruneInterval and in the background.
+func (m *Manager) pruneVerified(now time.Time) {
+	last := m.pruned.Load()
+	if now.UnixNano()-last < int64(paymentPruneInterval) || !m.pruned.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ctx, cancel := context.WithTimeout(m.ctx, storeTimeout)
+		defer cancel()
+		deleted, err := m.store.DeleteVerifiedPayments(ctx, now.Add(-verifiedPaymentTTL))
+		if err != nil {
+			logging.For(logging.Settlement).Warn().Err(err).Msg("Failed to prune verified payments")
+			return
+		}
+		if deleted > 0 {
+			logging.For(logging.Settlement).Debug().Int("payments", deleted).Msg("Pruned verified payments")
+		}
+	}()
+}
+
+// claim records that the settlement uses the authorization of the payment, before
+// anything is broadcast. It returns a failed response if another settlement
+// already used the authorization. The caller must hold the authorization key
+// of the dispatcher.
+func (m *Manager) claim(ctx context.Context, id string, payload *types.PaymentPayload) (*types.PaymentSettleResponse, error) {
+	key, payer, ok := m.authorization(payload)
+	if !ok {
+		return nil, nil
+	}
+
+	payment, used, err := m.usedPayment(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// a deferred settlement claimed the authorization itself before
+	if used && payment.SettlementID != id {
+		return &types.PaymentSettleResponse{
+			Success:   false,
+			Error:     types.ErrAuthorizationUsed.Error(),
+			Payer:     payer,
+			NetworkId: payload.Network,
+		}, nil
+	}
+
+	now := m.clock.Now()
+	if payment == nil {
+		payment = &store.Payment{Key: key, CreatedAt: now}
+	}
+	payment.PayloadHash = payloadHash(payload)
+	payment.SettlementID = id
+	payment.UpdatedAt = now
+	// without the record a restart could settle the authorization again, so nothing is broadcast
+	if err := m.store.SavePayment(ctx, payment); err != nil {
+		return nil, fmt.Errorf("failed to record payment: %w", err)
+	}
+	return nil, nil
+}
+
+// usedPayment returns the recorded payment of the authorization, nil if there is
+// none, and whether a settlement that didn't fail used it.
+func (m *Manager) usedPayment(ctx context.Context, key string) (*store.Payment, bool, error) {
+	payment, err := m.store.GetPayment(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up payment: %w", err)
+	}
+	if payment.SettlementID == "" {
+		return payment, false, nil
+	}
+
+	settlement, err := m.store.GetSettlement(ctx, payment.SettlementID)
+	if errors.Is(err, store.ErrNotFound) {
+		return payment, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up settlement: %w", err)
+	}
+	return payment, !Status(settlement.Status).IsFailure(), nil
+}
+
+// Resume picks up the settlements a previous run left unfinished. Submitted
+// transactions are tracked again. Settlements without a transaction, scheduled
+// ones still waiting for their window included, are failed,
+// which frees their authorization: if their transaction was broadcast after
+// all, settling the authorization again is rejected on chain. Settlements the
+// instance is running itself are left alone, so a leader elected again can
+// resume what its predecessor left. Unfinished refunds are tracked again and
+// the forwarding of split payments carried on as well.
+func (m *Manager) Resume(ctx context.Context) error {
+	// forwardings of split payments run before their settlements wait for them
+	if err := m.resumeSplits(ctx); err != nil {
+		return err
+	}
+	records, err := m.store.ListSettlementsByStatus(ctx, string(StatusScheduled), string(StatusQueued), string(StatusSubmitted), string(StatusMined))
+	if err != nil {
+		return fmt.Errorf("failed to list unfinished settlements: %w", err)
+	}
+
+	for _, record := range records {
+		if m.scheduled.has(record.ID) {
+			continue
+		}
+		if _, running := m.active.LoadOrStore(record.ID, struct{}{}); running {
+			continue
+		}
+		evt := Event{
+			ID:          record.ID,
+			Scheme:      record.Scheme,
+			Network:     record.Network,
+			Payer:       record.Payer,
+			Tenant:      record.Tenant,
+			RequestID:   record.RequestID,
+			Reference:   record.Reference,
+			TxHash:      record.TxHash,
+			PayTo:       record.PayTo,
+			Asset:       record.Asset,
+			Amount:      record.Amount,
+			AmountUSD:   record.AmountUSD,
+			BlockNumber: record.BlockNumber,
+			decimals:    record.AssetDecimals,
+		}
+		if record.Status == string(StatusScheduled) {
+			// the process stopped without closing the manager while it waited for its window
+			evt.Error = ErrScheduleCanceled.Error()
+			m.publish(evt, StatusFailed)
+			continue
+		}
+		if evt.TxHash == "" {
+			evt.Error = "interrupted before the transaction was submitted"
+			m.publish(evt, StatusFailed)
+			continue
+		}
+
+		f, config, ok := m.registry.Lookup(evt.Network)
+		if !ok {
+			logging.For(logging.Settlement).Warn().Str("settlement_id", evt.ID).Str("network", evt.Network).Msg("Can't resume settlement of an unconfigured network")
+			continue
+		}
+		if waiter, ok := f.(facilitator.ReceiptWaiter); ok {
+			m.follow(waiter, config.Confirmations, evt, nil)
+		}
+	}
+	if len(records) > 0 {
+		logging.For(logging.Settlement).Info().Int("settlements", len(records)).Msg("Resumed unfinished settlements")
+	}
+	return m.resumeRefunds(ctx)
+}
+
+// payloadHash returns the hex encoded SHA-256 of the payment payload.
+func payloadHash(payload *types.PaymentPayload) string {
+	sum := sha256.Sum256([]byte(payload.Payload))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,278 @@
+package settlement
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/metrics"
+)
+
+const (
+	defaultWorkers       = 8
+	defaultQueueSize     = 1024
+	defaultPriorityAging = 10 * time.Second
+)
+
+// ErrQueueFull is returned when a settlement can't be queued because too many are waiting
+var ErrQueueFull = errors.New("settlement queue is full")
+
+// DispatcherConfig sizes the settlement worker pool.
+type DispatcherConfig struct {
+	// Number of settlements submitted concurrently, 0 means 8
+	Workers int `mapstructure:"workers"`
+	// Number of settlements waiting for a worker before new ones are rejected, 0 means 1024
+	QueueSize int `mapstructure:"queueSize"`
+	// Number of scheduled settlements waiting for their window before new ones
+	// are rejected, 0 means 1024. See Manager.Schedule
+	MaxScheduled int `mapstructure:"maxScheduled"`
+	// Waiting time raising the priority of a queued settlement by one, so
+	// settlements of low priority still run while higher ones keep coming. 0 means 10 seconds
+	PriorityAging time.Duration `mapstructure:"priorityAging"`
+}
+
+// Dispatcher runs settlements concurrently on a bounded number of workers.
+// Every job holds a set of keys, such as the signer sending its transaction
+// or the authorization it settles, and jobs sharing a key run one after the
+// other. Jobs of higher priority run first, and a job's priority rises the
+// longer it waits, so jobs of low priority are delayed but not starved. Jobs
+// of the same priority and flow run in the order they were queued, while
+// waiting jobs of different flows take turns, so a flow queueing many can't
+// starve the others. Jobs sharing an ordering key run one at a time in the
+// order they were queued, whatever their priority. Networks can further cap
+// their concurrent jobs and the jobs started per block.
+type Dispatcher struct {
+	workers   int
+	queueSize int
+	aging     time.Duration
+	clock     clock.Clock // times the waits of jobs, set by the manager
+
+	mu       sync.Mutex
+	busy     int
+	pending  []*job                   // in the order they start, see schedule
+	running  map[string]bool          // keys of the running jobs
+	ordering map[string]bool          // ordering keys of the running jobs
+	networks map[string]*networkState // of the networks with limits
+	flows    map[string]uint64        // rank of the last job queued by every flow
+	round    uint64                   // rank of the last job started
+	queued   uint64                   // number of jobs queued, the sequence of the last one
+}
+
+// Job describes the settlement a dispatcher runs.
+type Job struct {
+	// Network the settlement is submitted on, subject to its limits
+	Network string
+	// Flow the settlement belongs to, such as its tenant
+	Flow string
+	// Keys held while the settlement runs
+	Keys []string
+	// Priority of the settlement, higher ones run first
+	Priority int
+	// Ordering key of the settlement, if any. Jobs sharing it run one after
+	// the other in the order they were queued
+	Ordering string
+}
+
+type job struct {
+	Job
+	start  chan struct{} // closed once the job may run
+	queued time.Time
+	rank   uint64
+	seq    uint64 // order of queueing
+	// when the jobs queued before it with the same ordering key were done
+	ordered time.Time
+}
+
+// networkState counts the jobs of a network against its limits.
+type networkState struct {
+	limits  facilitator.SettlementLimits
+	running int
+	// jobs started since the last new block
+	inBlock int
+}
+
+func NewDispatcher(config DispatcherConfig) *Dispatcher {
+	return &Dispatcher{
+		workers:   cmp.Or(config.Workers, defaultWorkers),
+		queueSize: cmp.Or(config.QueueSize, defaultQueueSize),
+		aging:     cmp.Or(config.PriorityAging, defaultPriorityAging),
+		clock:     clock.Real,
+		running:   make(map[string]bool),
+		ordering:  make(map[string]bool),
+		networks:  make(map[string]*networkState),
+		flows:     make(map[string]uint64),
+	}
+}
+
+// Limit caps the jobs of the network. A per block limit only takes effect if
+// NewBlock is called for every block of the network.
+func (d *Dispatcher) Limit(network string, limits facilitator.SettlementLimits) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.networks[network] = &networkState{limits: limits}
+	d.schedule()
+}
+
+// NewBlock starts a new block of the network, allowing its per block limit of jobs again.
+func (d *Dispatcher) NewBlock(network string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if state, ok := d.networks[network]; ok && state.inBlock > 0 {
+		state.inBlock = 0
+		d.schedule()
+	}
+}
+
+// Do waits for a free worker, for the jobs sharing one of the keys to finish
+// and for the limits of the network, then runs fn. It returns ErrQueueFull
+// without waiting if the queue is full, and the error of ctx if ctx is done
+// before fn could start.
+func (d *Dispatcher) Do(ctx context.Context, spec Job, fn func()) error {
+	j := &job{Job: spec, start: make(chan struct{}), queued: d.clock.Now()}
+
+	d.mu.Lock()
+	if len(d.pending) >= d.queueSize {
+		d.mu.Unlock()
+		return ErrQueueFull
+	}
+	d.enqueue(j)
+	d.schedule()
+	d.mu.Unlock()
+
+	select {
+	case <-j.start:
+	case <-ctx.Done():
+		d.mu.Lock()
+		if i := slices.Index(d.pending, j); i >= 0 {
+			d.pending = slices.Delete(d.pending, i, i+1)
+			d.schedule()
+			d.mu.Unlock()
+			return ctx.Err()
+		}
+		d.mu.Unlock()
+		// started meanwhile, the worker is already taken
+	}
+
+	defer d.release(j)
+	fn()
+	return nil
+}
+
+// enqueue ranks the job by fair queuing and adds it to the pending jobs.
+// A flow's jobs are ranked one after the other from the later of its last job
+// and the last job started, so a flow that queued nothing for a while is
+// served next rather than after all jobs queued meanwhile. The caller must
+// hold the lock.
+func (d *Dispatcher) enqueue(j *job) {
+	j.rank = max(d.flows[j.Flow], d.round) + 1
+	d.flows[j.Flow] = j.rank
+	d.queued++
+	j.seq = d.queued
+	d.pending = append(d.pending, j)
+}
+
+// schedule starts the pending jobs that can run, by priority and then in the
+// order of their ranks. A job waiting for a key blocks the later jobs sharing
+// any of its keys, so they can't overtake it. Of the jobs sharing an ordering
+// key only the first queued may start, once the one before is done. The
+// caller must hold the lock.
+func (d *Dispatcher) schedule() {
+	now := d.clock.Now()
+	slices.SortFunc(d.pending, func(a, b *job) int {
+		return cmp.Or(cmp.Compare(d.priority(b, now), d.priority(a, now)), cmp.Compare(a.rank, b.rank))
+	})
+	next := make(map[string]*job) // first queued job of every ordering key
+	for _, j := range d.pending {
+		if j.Ordering != "" && (next[j.Ordering] == nil || j.seq < next[j.Ordering].seq) {
+			next[j.Ordering] = j
+		}
+	}
+	for key, j := range next {
+		if !d.ordering[key] && j.ordered.IsZero() {
+			j.ordered = now
+		}
+	}
+
+	blocked := make(map[string]bool)
+	remaining := d.pending[:0]
+	for _, j := range d.pending {
+		if j.Ordering != "" && (next[j.Ordering] != j || d.ordering[j.Ordering]) {
+			// waits for its turn, not for its keys, so it doesn't block them
+			remaining = append(remaining, j)
+			continue
+		}
+		network := d.networks[j.Network]
+		if d.busy < d.workers && network.admits() && !slices.ContainsFunc(j.Keys, func(key string) bool {
+			return d.running[key] || blocked[key]
+		}) {
+			d.busy++
+			for _, key := range j.Keys {
+				d.running[key] = true
+			}
+			if j.Ordering != "" {
+				d.ordering[j.Ordering] = true
+				metrics.SettlementOrderingWait.WithLabelValues(j.Network).Observe(j.ordered.Sub(j.queued).Seconds())
+			}
+			if network != nil {
+				network.running++
+				network.inBlock++
+			}
+			d.round = max(d.round, j.rank)
+			waited := now.Sub(j.queued)
+			metrics.SettlementQueueWait.WithLabelValues(j.Network).Observe(waited.Seconds())
+			metrics.SettlementPriorityQueueWait.WithLabelValues(strconv.Itoa(j.Priority)).Observe(waited.Seconds())
+			if d.priority(j, now) > j.Priority {
+				metrics.SettlementsAged.Inc()
+			}
+			close(j.start)
+			continue
+		}
+		for _, key := range j.Keys {
+			blocked[key] = true
+		}
+		remaining = append(remaining, j)
+	}
+	clear(d.pending[len(remaining):])
+	d.pending = remaining
+	// flows whose jobs all started are ranked from the round again
+	maps.DeleteFunc(d.flows, func(_ string, rank uint64) bool { return rank <= d.round })
+
+	metrics.SettlementQueueDepth.Set(float64(len(d.pending)))
+	metrics.SettlementsInFlight.Set(float64(d.busy))
+}
+
+// priority returns the priority of the job raised by the time it waited.
+func (d *Dispatcher) priority(j *job, now time.Time) int {
+	return j.Priority + int(now.Sub(j.queued)/d.aging)
+}
+
+// release frees the worker and the keys of a finished job.
+func (d *Dispatcher) release(j *job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.busy--
+	for _, key := range j.Keys {
+		delete(d.running, key)
+	}
+	delete(d.ordering, j.Ordering)
+	if network := d.networks[j.Network]; network != nil {
+		network.running--
+	}
+	d.schedule()
+}
+
+// admits reports whether another job fits in the limits of the network.
+func (n *networkState) admits() bool {
+	if n == nil {
+		return true
+	}
+	return (n.limits.MaxConcurrent == 0 || n.running < n.limits.MaxConcurrent) &&
+		(n.limits.MaxPerBlock == 0 || n.inBlock < n.limits.MaxPerBlock)
+}
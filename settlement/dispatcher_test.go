@@ -0,0 +1,302 @@
+package settlement
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/facilitator"
+)
+
+// started runs a job that blocks until release is closed and waits until it holds its worker
+func started(t *testing.T, d *Dispatcher, job Job, release chan struct{}) <-chan error {
+	t.Helper()
+	running := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Do(context.Background(), job, func() {
+			close(running)
+			<-release
+		})
+	}()
+	select {
+	case <-running:
+	case <-time.After(time.Second):
+		t.Fatal("job did not start")
+	}
+	return done
+}
+
+func TestDispatcherSerializesSharedKeys(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{Workers: 4})
+	release := make(chan struct{})
+	first := started(t, d, Job{Keys: []string{"signer:a"}}, release)
+
+	// other signers are not held up
+	require.NoError(t, d.Do(t.Context(), Job{Keys: []string{"signer:b"}}, func() {}))
+
+	var order []int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, d.Do(context.Background(), Job{Keys: []string{"signer:a"}}, func() {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+			}))
+		}()
+		// queue them in order
+		require.Eventually(t, func() bool {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return len(d.pending) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	require.Empty(t, order, "jobs sharing a key must wait")
+
+	close(release)
+	require.NoError(t, <-first)
+	wg.Wait()
+	require.Equal(t, []int{0, 1, 2}, order)
+}
+
+func TestDispatcherNoOvertaking(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{Workers: 4})
+	release := make(chan struct{})
+	first := started(t, d, Job{Keys: []string{"signer:a"}}, release)
+
+	// waits for signer:a, and holds up later jobs of the same authorization
+	var waiting atomic.Bool
+	waiting.Store(true)
+	go d.Do(context.Background(), Job{Keys: []string{"signer:a", "authorization:1"}}, func() { waiting.Store(false) })
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, d.Do(ctx, Job{Keys: []string{"authorization:1"}}, func() {}), context.DeadlineExceeded)
+	require.True(t, waiting.Load())
+
+	close(release)
+	require.NoError(t, <-first)
+	require.Eventually(t, func() bool { return !waiting.Load() }, time.Second, time.Millisecond)
+}
+
+func TestDispatcherLimits(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{Workers: 1, QueueSize: 1})
+	release := make(chan struct{})
+	first := started(t, d, Job{Keys: []string{"signer:a"}}, release)
+
+	queued := make(chan error, 1)
+	go func() { queued <- d.Do(context.Background(), Job{Keys: []string{"signer:b"}}, func() {}) }()
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.pending) == 1
+	}, time.Second, time.Millisecond)
+
+	require.ErrorIs(t, d.Do(t.Context(), Job{Keys: []string{"signer:c"}}, func() {}), ErrQueueFull)
+
+	close(release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-queued)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	require.Zero(t, d.busy)
+	require.Empty(t, d.running)
+}
+
+func TestDispatcherNetworkLimits(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{Workers: 4})
+	d.Limit("eip155:1", facilitator.SettlementLimits{MaxConcurrent: 1, MaxPerBlock: 2})
+	release := make(chan struct{})
+	first := started(t, d, Job{Network: "eip155:1", Keys: []string{"signer:a"}}, release)
+
+	// waits for the running one of the network, other networks aren't held up
+	var ran atomic.Int32
+	done := make(chan error, 2)
+	go func() {
+		done <- d.Do(context.Background(), Job{Network: "eip155:1", Keys: []string{"signer:b"}}, func() { ran.Add(1) })
+	}()
+	require.NoError(t, d.Do(t.Context(), Job{Network: "eip155:8453", Keys: []string{"signer:c"}}, func() {}))
+	require.Zero(t, ran.Load())
+
+	close(release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-done)
+
+	// the block is full after two, the third waits for the next block
+	go func() {
+		done <- d.Do(context.Background(), Job{Network: "eip155:1", Keys: []string{"signer:b"}}, func() { ran.Add(1) })
+	}()
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.pending) == 1
+	}, time.Second, time.Millisecond)
+	d.NewBlock("eip155:1")
+	require.NoError(t, <-done)
+	require.Equal(t, int32(2), ran.Load())
+}
+
+func TestDispatcherFairQueuing(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{Workers: 1})
+	release := make(chan struct{})
+	first := started(t, d, Job{Flow: "busy", Keys: []string{"signer:a"}}, release)
+
+	var order []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := func(flow string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, d.Do(context.Background(), Job{Flow: flow, Keys: []string{"signer:a"}}, func() {
+				mu.Lock()
+				order = append(order, flow)
+				mu.Unlock()
+			}))
+		}()
+		d.mu.Lock()
+		queued := len(d.pending)
+		d.mu.Unlock()
+		require.Eventually(t, func() bool {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return len(d.pending) == queued+1
+		}, time.Second, time.Millisecond)
+	}
+	for range 3 {
+		queue("busy")
+	}
+	queue("quiet")
+	queue("quiet")
+
+	close(release)
+	require.NoError(t, <-first)
+	wg.Wait()
+	require.Equal(t, []string{"busy", "quiet", "busy", "quiet", "busy"}, order, "flows take turns")
+}
+
+func TestDispatcherPriority(t *testing.T) {
+	run := func(t *testing.T, d *Dispatcher, jobs func(queue func(name string, priority int))) []string {
+		t.Helper()
+		release := make(chan struct{})
+		first := started(t, d, Job{Keys: []string{"signer:a"}}, release)
+
+		var order []string
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		jobs(func(name string, priority int) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, d.Do(context.Background(), Job{Keys: []string{"signer:a"}, Priority: priority}, func() {
+					mu.Lock()
+					order = append(order, name)
+					mu.Unlock()
+				}))
+			}()
+			d.mu.Lock()
+			queued := len(d.pending)
+			d.mu.Unlock()
+			require.Eventually(t, func() bool {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return len(d.pending) == queued+1
+			}, time.Second, time.Millisecond)
+		})
+
+		close(release)
+		require.NoError(t, <-first)
+		wg.Wait()
+		return order
+	}
+
+	t.Run("higher first", func(t *testing.T) {
+		order := run(t, NewDispatcher(DispatcherConfig{Workers: 1}), func(queue func(string, int)) {
+			queue("low 1", 0)
+			queue("low 2", 0)
+			queue("high", 2)
+			queue("medium", 1)
+		})
+		require.Equal(t, []string{"high", "medium", "low 1", "low 2"}, order)
+	})
+
+	t.Run("aging", func(t *testing.T) {
+		fake := clock.NewFake(time.Unix(1_700_000_000, 0))
+		d := NewDispatcher(DispatcherConfig{Workers: 1, PriorityAging: time.Minute})
+		d.clock = fake
+		order := run(t, d, func(queue func(string, int)) {
+			queue("low", 0)
+			fake.Advance(3 * time.Minute)
+			queue("high", 2)
+		})
+		require.Equal(t, []string{"low", "high"}, order, "waiting raised the priority of the low job above the high one")
+	})
+}
+
+func TestDispatcherOrdering(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{Workers: 4})
+	release := make(chan struct{})
+	first := started(t, d, Job{Keys: []string{"signer:a"}, Ordering: "payee"}, release)
+
+	// other ordering keys and unordered jobs aren't held up
+	require.NoError(t, d.Do(t.Context(), Job{Keys: []string{"signer:b"}, Ordering: "other"}, func() {}))
+	require.NoError(t, d.Do(t.Context(), Job{Keys: []string{"signer:c"}}, func() {}))
+
+	var order []string
+	var mu sync.Mutex
+	queue := func(ctx context.Context, name string, job Job) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- d.Do(ctx, job, func() {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+			})
+		}()
+		d.mu.Lock()
+		queued := len(d.pending)
+		d.mu.Unlock()
+		require.Eventually(t, func() bool {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return len(d.pending) == queued+1
+		}, time.Second, time.Millisecond)
+		return done
+	}
+	cancelled, cancel := context.WithCancel(t.Context())
+	// free keys and higher priorities don't let a job overtake those queued before it
+	low := queue(context.Background(), "low", Job{Keys: []string{"signer:d"}, Ordering: "payee"})
+	gone := queue(cancelled, "gone", Job{Keys: []string{"signer:e"}, Ordering: "payee"})
+	high := queue(context.Background(), "high", Job{Keys: []string{"signer:f"}, Ordering: "payee", Priority: 5})
+	require.Empty(t, order)
+
+	// a job leaving the queue passes the turn on
+	cancel()
+	require.ErrorIs(t, <-gone, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-low)
+	require.NoError(t, <-high)
+	require.Equal(t, []string{"low", "high"}, order)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	require.Empty(t, d.ordering)
+}
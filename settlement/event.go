@@ -0,0 +1,93 @@
+package settlement
+
+import (
+	"time"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+)
+
+// Status is the lifecycle state of a settlement.
+type Status string
+
+const (
+	// StatusScheduled means the settlement waits for its scheduling window, see Manager.Schedule
+	StatusScheduled Status = "scheduled"
+	// StatusQueued means the settlement request was accepted but nothing was broadcast yet
+	StatusQueued Status = "queued"
+	// StatusSubmitted means the settlement transaction was broadcast to the network
+	StatusSubmitted Status = "submitted"
+	// StatusMined means the settlement transaction was included in a block
+	StatusMined Status = "mined"
+	// StatusConfirmed means the settlement transaction reached the required confirmation depth
+	StatusConfirmed Status = "confirmed"
+	// StatusFailed means the settlement could not be completed
+	StatusFailed Status = "failed"
+	// StatusExpired means the authorization expired before the settlement could be broadcast
+	StatusExpired Status = "expired"
+)
+
+// IsFinal reports whether no further transitions follow this status.
+func (s Status) IsFinal() bool {
+	return s == StatusConfirmed || s.IsFailure()
+}
+
+// IsFailure reports whether the settlement ended without settling the payment.
+func (s Status) IsFailure() bool {
+	return s == StatusFailed || s == StatusExpired
+}
+
+// Event describes a single settlement state transition.
+type Event struct {
+	// Unique ID of the settlement
+	ID string `json:"id"`
+	// ID of the refund of the settlement the event is about, absent for events of the settlement itself
+	RefundID string `json:"refundId,omitempty"`
+	// New status of the settlement
+	Status Status `json:"status"`
+	// Scheme used for the settlement
+	Scheme string `json:"scheme"`
+	// Network the settlement is executed on
+	Network string `json:"network"`
+	// Address of the payer, if known
+	Payer string `json:"payer,omitempty"`
+	// Tenant whose API key requested the settlement, if any
+	Tenant string `json:"tenant,omitempty"`
+	// ID of the API request of the settlement, the X-Request-ID header
+	RequestID string `json:"requestId,omitempty"`
+	// Reference the resource server attached to the settle request, if any
+	Reference string `json:"reference,omitempty"`
+	// Transaction hash, once submitted
+	TxHash string `json:"txHash,omitempty"`
+	// Recipient of the payment
+	PayTo string `json:"payTo,omitempty"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset string `json:"asset,omitempty"`
+	// Amount paid in atomic units of the asset
+	Amount string `json:"amount,omitempty"`
+	// USD value of the payment at submission, present only if a price oracle is configured
+	AmountUSD *float64 `json:"amountUsd,omitempty"`
+	// Block number the transaction was included in, once mined
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	// Error message, if the settlement failed
+	Error string `json:"error,omitempty"`
+	// Time of the transition
+	Timestamp time.Time `json:"timestamp"`
+
+	// diagnostic trail of the settlement, stored with it if it fails
+	trail *diagnostics.Trail
+	// decimals of the asset, 0 if it couldn't be resolved
+	decimals int
+}
+
+// Metadata returns the payment metadata of the settlement.
+func (e Event) Metadata() paymentctx.Metadata {
+	return paymentctx.Metadata{
+		RequestID:    e.RequestID,
+		Tenant:       e.Tenant,
+		Network:      e.Network,
+		Payer:        e.Payer,
+		SettlementID: e.ID,
+		Reference:    e.Reference,
+	}
+}
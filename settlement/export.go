@@ -0,0 +1,199 @@
+package settlement
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// Export formats
+const (
+	ExportCSV     = "csv"
+	ExportParquet = "parquet"
+)
+
+// exportWindow is the period of settlements read from the store at once, so
+// exports of long periods aren't held in memory
+const exportWindow = 24 * time.Hour
+
+// ExportRow is a settlement as exported for accounting. Its fields are the
+// columns of both formats, amounts are kept as decimal strings since they
+// don't fit in 64 bits.
+type ExportRow struct {
+	ID        string    `parquet:"id"`
+	CreatedAt time.Time `parquet:"created_at,timestamp(millisecond)"`
+	UpdatedAt time.Time `parquet:"updated_at,timestamp(millisecond)"`
+	Network   string    `parquet:"network"`
+	Status    string    `parquet:"status"`
+	Tenant    string    `parquet:"tenant"`
+	Payer     string    `parquet:"payer"`
+	PayTo     string    `parquet:"pay_to"`
+	Asset     string    `parquet:"asset"`
+	// Amount in atomic units of the asset, empty for settlements recorded before it was kept
+	Amount string `parquet:"amount"`
+	// Amount in whole units, empty if the decimals of the asset are unknown
+	AmountDecimal string   `parquet:"amount_decimal"`
+	AmountUSD     *float64 `parquet:"amount_usd,optional"`
+	TxHash        string   `parquet:"tx_hash"`
+	BlockNumber   uint64   `parquet:"block_number"`
+	Reverted      bool     `parquet:"reverted"`
+	GasUsed       uint64   `parquet:"gas_used"`
+	// Effective gas price in atomic units of the fee currency
+	GasPrice string `parquet:"gas_price"`
+	// Fee in atomic units of the fee currency, empty until mined
+	Fee string `parquet:"fee"`
+	// Fee in whole units of the fee currency
+	FeeDecimal  string   `parquet:"fee_decimal"`
+	FeeCurrency string   `parquet:"fee_currency"`
+	FeeUSD      *float64 `parquet:"fee_usd,optional"`
+	// Reference the resource server attached to the settle request
+	Reference string `parquet:"reference"`
+}
+
+// exportColumns are the CSV columns, in the order of ExportRow
+var exportColumns = []string{
+	"id", "created_at", "updated_at", "network", "status", "tenant", "payer", "pay_to", "asset",
+	"amount", "amount_decimal", "amount_usd", "tx_hash", "block_number", "reverted", "gas_used",
+	"gas_price", "fee", "fee_decimal", "fee_currency", "fee_usd", "reference",
+}
+
+func newExportRow(s *store.Settlement) ExportRow {
+	row := ExportRow{
+		ID:          s.ID,
+		CreatedAt:   s.CreatedAt.UTC(),
+		UpdatedAt:   s.UpdatedAt.UTC(),
+		Network:     s.Network,
+		Status:      s.Status,
+		Tenant:      s.Tenant,
+		Payer:       s.Payer,
+		PayTo:       s.PayTo,
+		Asset:       s.Asset,
+		Amount:      s.Amount,
+		AmountUSD:   s.AmountUSD,
+		TxHash:      s.TxHash,
+		BlockNumber: s.BlockNumber,
+		Reverted:    s.Reverted,
+		GasUsed:     s.GasUsed,
+		FeeCurrency: s.FeeCurrency,
+		FeeUSD:      s.FeeUSD,
+		Reference:   s.Reference,
+	}
+	if amount, ok := new(big.Int).SetString(s.Amount, 10); ok && s.AssetDecimals > 0 {
+		row.AmountDecimal = types.FormatUnits(amount, s.AssetDecimals)
+	}
+	if s.EffectiveGasPrice != nil {
+		row.GasPrice = s.EffectiveGasPrice.String()
+	}
+	if s.Fee != nil {
+		row.Fee = s.Fee.String()
+		row.FeeDecimal = types.FormatUnits(s.Fee, s.FeeDecimals)
+	}
+	return row
+}
+
+// csvRecord returns the CSV fields of the row.
+func (r ExportRow) csvRecord() []string {
+	optional := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	return []string{
+		r.ID, r.CreatedAt.Format(time.RFC3339Nano), r.UpdatedAt.Format(time.RFC3339Nano), r.Network, r.Status,
+		r.Tenant, r.Payer, r.PayTo, r.Asset, r.Amount, r.AmountDecimal, optional(r.AmountUSD), r.TxHash,
+		strconv.FormatUint(r.BlockNumber, 10), strconv.FormatBool(r.Reverted), strconv.FormatUint(r.GasUsed, 10),
+		r.GasPrice, r.Fee, r.FeeDecimal, r.FeeCurrency, optional(r.FeeUSD), r.Reference,
+	}
+}
+
+// exportWriter writes the rows of an export in one format.
+type exportWriter interface {
+	write(rows []ExportRow) error
+	close() error
+}
+
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e csvExport) write(rows []ExportRow) error {
+	for _, row := range rows {
+		if err := e.w.Write(row.csvRecord()); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e csvExport) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type parquetExport struct {
+	w *parquet.GenericWriter[ExportRow]
+}
+
+func (e parquetExport) write(rows []ExportRow) error {
+	if _, err := e.w.Write(rows); err != nil {
+		return err
+	}
+	// every window becomes a row group, written out as soon as it is complete
+	return e.w.Flush()
+}
+
+func (e parquetExport) close() error {
+	return e.w.Close()
+}
+
+// Export writes the settlements created in [from, to) to w in the format,
+// oldest first. Settlements are read from the store a day at a time and
+// written out as they are read.
+func (m *Manager) Export(ctx context.Context, w io.Writer, format string, from, to time.Time) error {
+	var out exportWriter
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+		out = csvExport{w: cw}
+	case ExportParquet:
+		out = parquetExport{w: parquet.NewGenericWriter[ExportRow](w, parquet.Compression(&parquet.Snappy))}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	for start := from; start.Before(to); start = start.Add(exportWindow) {
+		end := start.Add(exportWindow)
+		if end.After(to) {
+			end = to
+		}
+		settlements, err := m.store.ListSettlements(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		if len(settlements) == 0 {
+			continue
+		}
+		rows := make([]ExportRow, 0, len(settlements))
+		for _, s := range settlements {
+			rows = append(rows, newExportRow(s))
+		}
+		if err := out.write(rows); err != nil {
+			return err
+		}
+	}
+	return out.close()
+}
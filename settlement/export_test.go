@@ -0,0 +1,82 @@
+package settlement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestExport(t *testing.T) {
+	records := store.NewMemory()
+	m := &Manager{store: records}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	usd := 0.01
+	confirmed := &store.Settlement{
+		ID:                "a",
+		Network:           "eip155:8453",
+		Status:            string(StatusConfirmed),
+		Payer:             "0xpayer",
+		PayTo:             "0xpayee",
+		Asset:             "USDC",
+		Amount:            "10000",
+		AssetDecimals:     6,
+		AmountUSD:         &usd,
+		TxHash:            "0x01",
+		BlockNumber:       12,
+		GasUsed:           60_000,
+		EffectiveGasPrice: big.NewInt(1_000_000_000),
+		Fee:               big.NewInt(60_000_000_000_000),
+		FeeCurrency:       "ETH",
+		FeeDecimals:       18,
+		CreatedAt:         start.Add(time.Hour),
+		UpdatedAt:         start.Add(time.Hour + time.Minute),
+	}
+	require.NoError(t, records.SaveSettlement(t.Context(), confirmed))
+	// three days later, so the export spans several windows
+	require.NoError(t, records.SaveSettlement(t.Context(), &store.Settlement{ID: "b", Network: "eip155:8453", Status: string(StatusFailed), CreatedAt: start.Add(72 * time.Hour)}))
+	require.NoError(t, records.SaveSettlement(t.Context(), &store.Settlement{ID: "c", CreatedAt: start.Add(-time.Hour)}))
+	from, to := start, start.Add(96*time.Hour)
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, m.Export(t.Context(), &buf, ExportCSV, from, to))
+		lines, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, lines, 3)
+		require.Equal(t, exportColumns, lines[0])
+
+		row := make(map[string]string)
+		for i, column := range lines[0] {
+			row[column] = lines[1][i]
+		}
+		require.Equal(t, "a", row["id"])
+		require.Equal(t, "0xpayee", row["pay_to"])
+		require.Equal(t, "0.01", row["amount_decimal"])
+		require.Equal(t, "0.01", row["amount_usd"])
+		require.Equal(t, "0.00006", row["fee_decimal"])
+		require.Equal(t, "2026-01-01T01:00:00Z", row["created_at"])
+		require.Equal(t, "b", lines[2][0])
+		require.Empty(t, lines[2][len(lines[2])-1], "unpriced fees are empty")
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, m.Export(t.Context(), &buf, ExportParquet, from, to))
+		rows, err := parquet.Read[ExportRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		require.Equal(t, newExportRow(confirmed), rows[0])
+		require.Nil(t, rows[1].FeeUSD)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		require.Error(t, m.Export(t.Context(), &bytes.Buffer{}, "xlsx", from, to))
+	})
+}
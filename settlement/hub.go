@@ -0,0 +1,53 @@
+package settlement
+
+import "sync"
+
+// subscriberBuffer is the number of events buffered per subscriber.
+// Slow subscribers drop events instead of blocking settlement processing.
+const subscriberBuffer = 64
+
+// Hub fans out settlement events to all active subscribers.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber and returns its event channel
+// together with a function that must be called to unsubscribe.
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers the event to every subscriber without blocking.
+func (h *Hub) Publish(evt Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- evt:
+		default:
+			// subscriber is not keeping up, drop the event
+		}
+	}
+}
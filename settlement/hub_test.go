@@ -0,0 +1,38 @@
+package settlement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubPublishSubscribe(t *testing.T) {
+	hub := NewHub()
+
+	events, unsubscribe := hub.Subscribe()
+	hub.Publish(Event{ID: "1", Status: StatusQueued})
+
+	evt := <-events
+	require.Equal(t, "1", evt.ID)
+	require.Equal(t, StatusQueued, evt.Status)
+
+	unsubscribe()
+	_, ok := <-events
+	require.False(t, ok, "channel should be closed after unsubscribe")
+
+	// publishing without subscribers must not block or panic
+	hub.Publish(Event{ID: "2", Status: StatusFailed})
+	unsubscribe()
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+
+	events, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer*2; i++ {
+		hub.Publish(Event{Status: StatusSubmitted})
+	}
+	require.Len(t, events, subscriberBuffer)
+}
@@ -0,0 +1,704 @@
+package settlement
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/leader"
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/oracle"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/receipt"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// ErrStandby is returned for settlements requested from an instance that isn't the elected leader
+var ErrStandby = errors.New("instance is a standby, settlements are broadcast by the leader")
+
+// receiptTimeout bounds how long a submitted transaction is tracked in the background
+const receiptTimeout = 10 * time.Minute
+
+// storeTimeout bounds persisting a single state transition
+const storeTimeout = 5 * time.Second
+
+// headPollInterval is how often the chain head of networks limiting the
+// settlements per block is read, unless they are subscribed to new blocks
+const headPollInterval = time.Second
+
+// stalledHeadInterval is how often the head is read while subscribed, in case
+// the subscription stops delivering blocks without failing
+const stalledHeadInterval = 15 * time.Second
+
+// verifiedPaymentTTL is how long the record of a verified payment no settlement used is kept
+const verifiedPaymentTTL = 24 * time.Hour
+
+// paymentPruneInterval is how often records of verified payments older than verifiedPaymentTTL are deleted
+const paymentPruneInterval = time.Hour
+
+// drainPollInterval is how often Drain checks whether the settlements are finished
+const drainPollInterval = 100 * time.Millisecond
+
+// headReader is implemented by facilitators that can read the chain head.
+type headReader interface {
+	Head(ctx context.Context) (uint64, error)
+}
+
+// Manager runs settlements through the facilitator of their network,
+// publishes every state transition to its Hub and records it in the store.
+type Manager struct {
+	registry   *facilitator.Registry
+	store      store.Store
+	hub        *Hub
+	dispatcher *Dispatcher
+	// signs the receipts of submitted settlements, optional
+	receipts *receipt.Issuer
+	// election of the instance broadcasting settlements, nil if every instance may
+	elector *leader.Elector
+	// IDs of the settlements this instance is running or tracking
+	active sync.Map
+	// time source of the expiry checks and timestamps of settlements
+	clock clock.Clock
+	// gas price polls of the scheduled settlements waiting for low fees
+	gas gasWatches
+	// scheduled settlements waiting for their window, at most maxScheduled
+	scheduled    scheduledSet
+	maxScheduled int
+	// forwardings of split payments by settlement ID, see startSplit
+	splits sync.Map
+	// Unix nanoseconds of the last pruning of verified payments, see pruneVerified
+	pruned atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithDispatcher sizes the worker pool settlements are submitted on.
+func WithDispatcher(config DispatcherConfig) Option {
+	return func(m *Manager) {
+		m.dispatcher = NewDispatcher(config)
+		m.maxScheduled = cmp.Or(config.MaxScheduled, defaultMaxScheduled)
+	}
+}
+
+// WithClock replaces the clock the settlements are timed with, so tests can
+// move the time of the manager and its dispatcher forward themselves.
+func WithClock(c clock.Clock) Option {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
+
+// WithReceipts signs a receipt of every submitted settlement and returns it in
+// the settle response.
+func WithReceipts(issuer *receipt.Issuer) Option {
+	return func(m *Manager) {
+		m.receipts = issuer
+	}
+}
+
+func NewManager(registry *facilitator.Registry, store store.Store, opts ...Option) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		registry:     registry,
+		store:        store,
+		hub:          NewHub(),
+		dispatcher:   NewDispatcher(DispatcherConfig{}),
+		maxScheduled: defaultMaxScheduled,
+		clock:        clock.Real,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.dispatcher.clock = m.clock
+	for _, config := range registry.Networks() {
+		m.limit(config)
+	}
+	return m
+}
+
+// limit applies the settlement limits of the network to the dispatcher and
+// follows the chain head to enforce the per block limit.
+func (m *Manager) limit(config facilitator.NetworkConfig) {
+	limits := config.Limits
+	if limits == (facilitator.SettlementLimits{}) {
+		return
+	}
+	f, _, _ := m.registry.Lookup(config.Network)
+	heads, ok := f.(headReader)
+	if limits.MaxPerBlock > 0 && !ok {
+		logging.For(logging.Settlement).Warn().Str("network", config.Network).Msg("Settlements per block are not limited, the facilitator can't read the chain head")
+		limits.MaxPerBlock = 0
+	}
+	m.dispatcher.Limit(config.Network, limits)
+	if limits.MaxPerBlock == 0 {
+		return
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(headPollInterval)
+		defer ticker.Stop()
+		notifier, _ := f.(facilitator.HeadNotifier)
+		var last uint64
+		for {
+			// polled every second only while there is no live subscription
+			var next <-chan struct{}
+			poll := ticker.C
+			if notifier != nil {
+				if next = notifier.NewHead(); next != nil {
+					poll = time.After(stalledHeadInterval)
+				}
+			}
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-next:
+			case <-poll:
+			}
+			ctx, cancel := context.WithTimeout(m.ctx, headPollInterval)
+			head, err := heads.Head(ctx)
+			cancel()
+			if err != nil {
+				// settlements wait until the head can be read again
+				logging.For(logging.Settlement).Warn().Err(err).Str("network", config.Network).Msg("Failed to read chain head")
+				continue
+			}
+			if head != last {
+				last = head
+				m.dispatcher.NewBlock(config.Network)
+			}
+		}
+	}()
+}
+
+// WithLeader only settles while the instance is the elected leader. Standby
+// instances keep verifying payments.
+func WithLeader(elector *leader.Elector) Option {
+	return func(m *Manager) {
+		m.elector = elector
+	}
+}
+
+// Hub returns the hub settlement events are published to.
+func (m *Manager) Hub() *Hub {
+	return m.hub
+}
+
+// GetSettlement returns the stored record of the settlement with the ID.
+func (m *Manager) GetSettlement(ctx context.Context, id string) (*store.Settlement, error) {
+	return m.store.GetSettlement(ctx, id)
+}
+
+// priorityKey is the context key of the priority of a settlement
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx whose settlements are queued with the
+// priority. Settlements of higher priority are submitted first when the queue
+// is backed up, see Dispatcher.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priorityFrom returns the priority of ctx, 0 if it has none.
+func priorityFrom(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	return priority
+}
+
+// orderingKey is the context key of the ordering key of a settlement
+type orderingKey struct{}
+
+// WithOrderingKey returns a copy of ctx whose settlements are submitted in
+// order with the other settlements of the tenant and payee with the same
+// key, see Job.Ordering.
+func WithOrderingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, orderingKey{}, key)
+}
+
+// orderingKeyFrom returns the ordering key of ctx, empty if it has none.
+func orderingKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(orderingKey{}).(string)
+	return key
+}
+
+// Settle executes the settlement and returns once the transaction is submitted.
+// Settlements of the same signer or authorization are submitted one at a time,
+// and an authorization already used by another settlement is not settled again.
+// Settlements with an ordering key, see WithOrderingKey, are submitted in the
+// order they arrived.
+// If the facilitator of the network supports receipt tracking, the transaction
+// is followed in the background until it is confirmed or fails. Standby
+// instances return ErrStandby, settlements on paused networks
+// facilitator.ErrNetworkPaused.
+func (m *Manager) Settle(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
+	if err := m.admit(payload.Network); err != nil {
+		return nil, err
+	}
+	ctx, evt := m.newSettlement(ctx, payload, req)
+	return m.run(ctx, evt, payload, req)
+}
+
+// admit returns why settlements on the network can't be started now, nil if they can.
+func (m *Manager) admit(network string) error {
+	if !m.elector.IsLeader() {
+		return ErrStandby
+	}
+	if _, paused := m.registry.Paused(network); paused {
+		return facilitator.ErrNetworkPaused
+	}
+	return nil
+}
+
+// newSettlement assigns the payment a settlement ID and returns its event and
+// ctx with its metadata and diagnostic trail.
+func (m *Manager) newSettlement(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements) (context.Context, Event) {
+	ctx = m.withPayment(ctx, payload)
+	ctx = paymentctx.With(ctx, paymentctx.Metadata{SettlementID: uuid.NewString()})
+	meta := paymentctx.From(ctx)
+	evt := Event{
+		ID:        meta.SettlementID,
+		Scheme:    payload.Scheme,
+		Network:   payload.Network,
+		Payer:     meta.Payer,
+		Tenant:    meta.Tenant,
+		RequestID: meta.RequestID,
+		Reference: meta.Reference,
+		PayTo:     req.PayTo,
+		Asset:     req.Asset,
+		Amount:    req.MaxAmountRequired,
+		trail:     diagnostics.New(),
+	}
+	if symbol, decimals, ok := m.registry.ResolveAsset(payload.Network, req.Asset); ok {
+		evt.Asset, evt.decimals = symbol, decimals
+	}
+	return diagnostics.With(ctx, evt.trail), evt
+}
+
+// run queues the settlement and returns once its transaction is submitted.
+func (m *Manager) run(ctx context.Context, evt Event, payload *types.PaymentPayload, req *types.PaymentRequirements) (*types.PaymentSettleResponse, error) {
+	meta := paymentctx.From(ctx)
+	m.active.Store(evt.ID, struct{}{})
+	m.publish(evt, StatusQueued)
+
+	// settlements still queued when their authorization is about to expire are dropped
+	queueCtx := ctx
+	settleBy, expires := m.registry.SettleBy(payload)
+	if expires {
+		if !m.clock.Now().Before(settleBy) {
+			return m.expire(evt), nil
+		}
+		var cancel context.CancelFunc
+		queueCtx, cancel = clock.WithDeadline(ctx, m.clock, settleBy)
+		defer cancel()
+	}
+
+	var resp *types.PaymentSettleResponse
+	var err error
+	job := m.dispatchJob(payload, meta.Tenant)
+	job.Priority = priorityFrom(ctx)
+	if key := orderingKeyFrom(ctx); key != "" {
+		job.Ordering = strings.Join([]string{meta.Tenant, job.Network, strings.ToLower(req.PayTo), key}, ":")
+	}
+	dispatch := func() error {
+		return m.dispatcher.Do(queueCtx, job, func() {
+			resp, err = m.claim(ctx, evt.ID, payload)
+			if err == nil && resp == nil {
+				resp, err = m.registry.Settle(ctx, payload, req)
+			}
+		})
+	}
+	queueErr := dispatch()
+	for queueErr == nil && err == nil {
+		interval, deferred := m.deferral(payload.Network, resp)
+		if !deferred {
+			break
+		}
+		// the settlement waits for the fees to drop below the ceiling, without holding a worker
+		diagnostics.Record(ctx, diagnostics.KindDeferral, "Gas cost over the ceiling, retrying", map[string]string{"interval": interval.String()})
+		if sleepErr := clock.Sleep(queueCtx, m.clock, interval); sleepErr != nil {
+			if expires && ctx.Err() == nil && errors.Is(sleepErr, context.DeadlineExceeded) {
+				return m.expire(evt), nil
+			}
+			// the request ended, the settlement fails as too expensive
+			break
+		}
+		queueErr = dispatch()
+	}
+	if queueErr != nil {
+		if expires && ctx.Err() == nil && errors.Is(queueErr, context.DeadlineExceeded) {
+			return m.expire(evt), nil
+		}
+		err = queueErr
+	}
+	if err == nil && resp == nil {
+		err = facilitator.ErrNotSupported
+	}
+	if err != nil {
+		evt.Error = err.Error()
+		diagnostics.Record(ctx, diagnostics.KindError, evt.Error, nil)
+		m.publish(evt, StatusFailed)
+		return nil, err
+	}
+	evt.Payer = resp.Payer
+	if !resp.Success {
+		evt.Error = resp.Error
+		diagnostics.Record(ctx, diagnostics.KindError, evt.Error, nil)
+		status := StatusFailed
+		if resp.Error == types.ErrAuthorizationExpired.Error() {
+			status = StatusExpired
+		}
+		m.publish(evt, status)
+		return resp, nil
+	}
+
+	evt.TxHash = resp.TxHash
+	if value, err := m.registry.ValueUSD(ctx, payload.Network, payload, req); err == nil {
+		evt.AmountUSD = &value.USD
+		resp.AmountUsd = &value.USD
+	} else if !errors.Is(err, facilitator.ErrNotSupported) {
+		// USD pricing is best effort, the settlement is already submitted
+		logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Msg("Failed to price settlement in USD")
+	}
+	m.publish(evt, StatusSubmitted)
+	if m.receipts != nil {
+		resp.Receipt = m.issueReceipt(ctx, payload.Network, resp, req)
+	}
+
+	f, config, ok := m.registry.Lookup(payload.Network)
+	if !ok {
+		return resp, nil
+	}
+	m.startSplit(ctx, f, evt, payload, req, resp)
+	if waiter, ok := f.(facilitator.ReceiptWaiter); ok {
+		var verify verifyFunc
+		if verifier, ok := f.(facilitator.TransferVerifier); ok {
+			verify = func(ctx context.Context, txHash string) error {
+				return verifier.VerifyTransfer(ctx, txHash, payload, req)
+			}
+		}
+		m.follow(waiter, config.Confirmations, evt, verify)
+	}
+	return resp, nil
+}
+
+// deferral returns how long a settlement rejected as too expensive waits
+// before it is tried again, false if it isn't deferred.
+func (m *Manager) deferral(network string, resp *types.PaymentSettleResponse) (time.Duration, bool) {
+	if resp == nil || resp.Error != types.ErrGasTooExpensive.Error() {
+		return 0, false
+	}
+	_, config, ok := m.registry.Lookup(network)
+	if !ok || !config.Gas.DeferOverCeiling {
+		return 0, false
+	}
+	return cmp.Or(config.Gas.DeferInterval, facilitator.DefaultGasDeferInterval), true
+}
+
+// expire ends a settlement whose authorization expired before it could be broadcast.
+func (m *Manager) expire(evt Event) *types.PaymentSettleResponse {
+	evt.Error = types.ErrAuthorizationExpired.Error()
+	m.publish(evt, StatusExpired)
+	return &types.PaymentSettleResponse{
+		Success: false,
+		Error:   evt.Error,
+		Payer:   evt.Payer,
+	}
+}
+
+// issueReceipt signs the receipt of the submitted settlement, or returns the
+// one issued when the authorization was settled before. Receipts are best
+// effort, the settlement is submitted already.
+func (m *Manager) issueReceipt(ctx context.Context, network string, resp *types.PaymentSettleResponse, req *types.PaymentRequirements) *types.SettlementReceipt {
+	if issued, err := m.receipts.Get(ctx, resp.TxHash); err == nil {
+		return issued
+	}
+	r := &types.SettlementReceipt{
+		Network:   network,
+		Payer:     resp.Payer,
+		Payee:     req.PayTo,
+		Amount:    req.MaxAmountRequired,
+		Asset:     req.Asset,
+		TxHash:    resp.TxHash,
+		Timestamp: m.clock.Now().Unix(),
+		Reference: paymentctx.From(ctx).Reference,
+	}
+	if err := m.receipts.Issue(ctx, r); err != nil {
+		logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("tx_hash", resp.TxHash).Msg("Failed to issue settlement receipt")
+		return nil
+	}
+	return r
+}
+
+// verifyFunc checks that a mined settlement transaction made the payment
+type verifyFunc func(ctx context.Context, txHash string) error
+
+// follow tracks the submitted transaction in the background. verify is nil
+// if the payment of the settlement isn't known, e.g. when resuming.
+func (m *Manager) follow(waiter facilitator.ReceiptWaiter, confirmations uint64, evt Event, verify verifyFunc) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.track(waiter, confirmations, evt, verify)
+	}()
+}
+
+// dispatchJob describes the settlement to the dispatcher. Settlements of a
+// tenant take turns with those of other tenants, and they are serialized on
+// the signers of the network, whose transaction nonces are assigned one at a
+// time, and the authorization they settle, which can only be used once.
+func (m *Manager) dispatchJob(payload *types.PaymentPayload, tenant string) Job {
+	job := m.signerJob(payload.Network, tenant)
+	if key, _, ok := m.authorization(payload); ok {
+		job.Keys = append(job.Keys, "authorization:"+key)
+	}
+	return job
+}
+
+// signerJob describes a transaction of the signers of the network to the
+// dispatcher, serialized with the settlements they send.
+func (m *Manager) signerJob(network, tenant string) Job {
+	f, config, ok := m.registry.Lookup(network)
+	if !ok {
+		return Job{Network: network, Flow: tenant}
+	}
+	job := Job{Network: config.Network, Flow: tenant}
+	for _, signer := range f.GetSigners() {
+		job.Keys = append(job.Keys, "signer:"+config.Network+":"+strings.ToLower(signer))
+	}
+	return job
+}
+
+// track follows a submitted transaction until it is confirmed or fails.
+func (m *Manager) track(waiter facilitator.ReceiptWaiter, confirmations uint64, evt Event, verify verifyFunc) {
+	if evt.trail == nil {
+		// resumed settlements start a new trail
+		evt.trail = diagnostics.New()
+	}
+	ctx, cancel := context.WithTimeout(paymentctx.With(m.ctx, evt.Metadata()), receiptTimeout)
+	defer cancel()
+	ctx = diagnostics.With(ctx, evt.trail)
+	// a forwarding of the payment nobody waits for anymore isn't kept once it ends
+	defer m.splits.Delete(evt.ID)
+
+	receipt, err := waiter.WaitMined(ctx, evt.TxHash)
+	if m.ctx.Err() != nil {
+		// shutting down, the settlement stays unfinished and is resumed on the next start
+		return
+	}
+	if err != nil {
+		evt.Error = err.Error()
+		diagnostics.Record(ctx, diagnostics.KindRPCError, evt.Error, nil)
+		m.publish(evt, StatusFailed)
+		return
+	}
+	evt.BlockNumber = receipt.BlockNumber
+	m.recordCost(ctx, evt, receipt)
+	diagnostics.Record(ctx, diagnostics.KindReceipt, "", map[string]string{
+		"success":     strconv.FormatBool(receipt.Success),
+		"blockNumber": strconv.FormatUint(receipt.BlockNumber, 10),
+		"gasUsed":     strconv.FormatUint(receipt.GasUsed, 10),
+	})
+	if !receipt.Success {
+		evt.Error = "transaction reverted"
+		m.publish(evt, StatusFailed)
+		return
+	}
+	if verify != nil {
+		if err := verify(ctx, evt.TxHash); err != nil {
+			if m.ctx.Err() != nil {
+				return
+			}
+			logging.Ctx(ctx, logging.Settlement).Error().Err(err).Str("tx_hash", evt.TxHash).Msg("Settlement transaction didn't transfer the payment")
+			evt.Error = err.Error()
+			diagnostics.Record(ctx, diagnostics.KindError, evt.Error, nil)
+			m.publish(evt, StatusFailed)
+			return
+		}
+	}
+	m.publish(evt, StatusMined)
+
+	err = waiter.WaitConfirmed(ctx, receipt, confirmations)
+	if m.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		evt.Error = err.Error()
+		diagnostics.Record(ctx, diagnostics.KindError, evt.Error, nil)
+		m.publish(evt, StatusFailed)
+		return
+	}
+	// a reorg may have moved the transaction into another block
+	evt.BlockNumber = receipt.BlockNumber
+	// the payment of a split is only settled once its shares are forwarded
+	failure, ok := m.awaitSplit(evt)
+	if !ok {
+		return
+	}
+	if failure != "" {
+		evt.Error = failure
+		diagnostics.Record(ctx, diagnostics.KindError, evt.Error, nil)
+		m.publish(evt, StatusFailed)
+		return
+	}
+	m.publish(evt, StatusConfirmed)
+}
+
+// recordCost stores and exports the gas a mined settlement transaction used.
+func (m *Manager) recordCost(ctx context.Context, evt Event, receipt *facilitator.Receipt) {
+	if receipt.GasUsed > 0 {
+		metrics.SettlementGasUsed.WithLabelValues(evt.Network, evt.Asset).Add(float64(receipt.GasUsed))
+	}
+	if receipt.Fee == nil {
+		return
+	}
+
+	fee, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(receipt.Fee),
+		new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(receipt.FeeDecimals)), nil)),
+	).Float64()
+	metrics.SettlementFee.WithLabelValues(evt.Network, evt.Asset, receipt.FeeCurrency).Add(fee)
+
+	var feeUSD *float64
+	if priceOracle := m.registry.PriceOracle(); priceOracle != nil {
+		usd, err := oracle.ValueUSD(ctx, priceOracle, receipt.FeeCurrency, receipt.Fee, receipt.FeeDecimals)
+		if err != nil {
+			logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("tx_hash", evt.TxHash).Msg("Failed to price settlement fee in USD")
+		} else {
+			feeUSD = &usd
+			metrics.SettlementFeeUSD.WithLabelValues(evt.Network, evt.Asset).Add(usd)
+		}
+	}
+
+	m.update(evt.ID, func(record *store.Settlement) {
+		record.Reverted = !receipt.Success
+		record.GasUsed = receipt.GasUsed
+		record.EffectiveGasPrice = receipt.EffectiveGasPrice
+		record.Fee = receipt.Fee
+		record.FeeCurrency = receipt.FeeCurrency
+		record.FeeDecimals = receipt.FeeDecimals
+		record.FeeUSD = feeUSD
+	})
+}
+
+func (m *Manager) publish(evt Event, status Status) {
+	evt.Status = status
+	evt.Timestamp = m.clock.Now()
+	if status.IsFinal() {
+		m.active.Delete(evt.ID)
+	}
+
+	logging.For(logging.Settlement).Debug().
+		EmbedObject(evt.Metadata()).
+		Str("status", string(status)).
+		Str("tx_hash", evt.TxHash).
+		Msg("Settlement state changed")
+
+	metrics.Settlements.WithLabelValues(evt.Network, string(status)).Inc()
+	if evt.Tenant != "" {
+		metrics.TenantSettlements.WithLabelValues(evt.Tenant, evt.Network, string(status)).Inc()
+	}
+	if status == StatusConfirmed && evt.AmountUSD != nil {
+		metrics.SettledValueUSD.WithLabelValues(evt.Network, evt.Asset).Add(*evt.AmountUSD)
+	}
+
+	m.update(evt.ID, func(record *store.Settlement) {
+		record.Scheme = evt.Scheme
+		record.Network = evt.Network
+		record.Payer = evt.Payer
+		record.Tenant = evt.Tenant
+		record.RequestID = evt.RequestID
+		record.Reference = evt.Reference
+		record.PayTo = evt.PayTo
+		record.Asset = evt.Asset
+		record.Amount = evt.Amount
+		record.AssetDecimals = evt.decimals
+		record.AmountUSD = evt.AmountUSD
+		record.Status = string(evt.Status)
+		record.Error = evt.Error
+		record.TxHash = evt.TxHash
+		record.BlockNumber = evt.BlockNumber
+		if status.IsFailure() && evt.trail != nil {
+			record.Diagnostics = evt.trail.Entries()
+		}
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = evt.Timestamp
+		}
+		record.UpdatedAt = evt.Timestamp
+	})
+	m.hub.Publish(evt)
+}
+
+// update applies a change to the stored record of a settlement, creating it if needed.
+// The transitions of a settlement are sequential, so the record is not modified concurrently.
+func (m *Manager) update(id string, change func(record *store.Settlement)) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	record, err := m.store.GetSettlement(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		record, err = &store.Settlement{ID: id}, nil
+	}
+	if err == nil {
+		change(record)
+		err = m.store.SaveSettlement(ctx, record)
+	}
+	if err != nil {
+		// the settlement itself is not affected, only its reporting
+		logging.For(logging.Settlement).Error().Err(err).Str("settlement_id", id).Msg("Failed to store settlement")
+	}
+}
+
+// Drain waits until the settlements and refunds the manager follows are
+// finished or the context is done, and then stops like Close. It is called
+// once the API stopped accepting requests, so a process handing over to its
+// successor finishes what it started. Unfinished settlements are left to the
+// Resume of the successor.
+func (m *Manager) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	defer m.Close()
+	for {
+		unfinished := 0
+		m.active.Range(func(_, _ any) bool {
+			unfinished++
+			return true
+		})
+		if unfinished == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d settlements unfinished: %w", unfinished, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops tracking of in-flight settlements and waits for the trackers to exit.
+// The settlements stay unfinished in the store, Resume picks them up again.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
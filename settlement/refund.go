@@ -0,0 +1,266 @@
+package settlement
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/metrics"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+var (
+	// ErrInvalidRefund is returned for refund requests that can't be recorded as they are
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrNotRefundable is returned for refunds of settlements that didn't transfer a payment
+	ErrNotRefundable = errors.New("settlement did not transfer a payment")
+)
+
+// Refund records a refund of a settlement. An authorization of the payee is
+// settled to the payer like a payment, a transaction the payee sent is only
+// tracked. Either way the refund is followed until it is confirmed or fails,
+// and every transition is published to the hub as an event of the settlement
+// carrying the refund ID. It returns store.ErrNotFound if the settlement
+// doesn't exist.
+func (m *Manager) Refund(ctx context.Context, req *types.RefundRequest) (*store.Refund, error) {
+	if !m.elector.IsLeader() {
+		return nil, ErrStandby
+	}
+	if (req.PaymentPayload == nil) == (req.TxHash == "") {
+		return nil, fmt.Errorf("%w: either a payment payload or a transaction hash is required", ErrInvalidRefund)
+	}
+	if amount, ok := new(big.Int).SetString(req.Amount, 10); !ok || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: amount %q is not a positive integer", ErrInvalidRefund, req.Amount)
+	}
+
+	settled, err := m.store.GetSettlement(ctx, req.SettlementID)
+	if err != nil {
+		return nil, err
+	}
+	if Status(settled.Status).IsFailure() || settled.TxHash == "" {
+		return nil, ErrNotRefundable
+	}
+	f, config, ok := m.registry.Lookup(settled.Network)
+	if !ok {
+		return nil, fmt.Errorf("%w: network %s is not configured", ErrInvalidRefund, settled.Network)
+	}
+
+	now := m.clock.Now()
+	refund := &store.Refund{
+		ID:           uuid.NewString(),
+		SettlementID: settled.ID,
+		Network:      settled.Network,
+		Asset:        cmp.Or(req.Asset, settled.Asset),
+		Amount:       req.Amount,
+		To:           settled.Payer,
+		Reason:       req.Reason,
+		TxHash:       req.TxHash,
+		CreatedAt:    now,
+	}
+	evt := Event{
+		ID:        settled.ID,
+		RefundID:  refund.ID,
+		Scheme:    settled.Scheme,
+		Network:   settled.Network,
+		Payer:     settled.Payer,
+		Tenant:    settled.Tenant,
+		RequestID: settled.RequestID,
+		Reference: settled.Reference,
+		Asset:     refund.Asset,
+	}
+	if req.PaymentPayload != nil {
+		if err := m.settleRefund(ctx, f, refund, req.PaymentPayload, settled.Tenant); err != nil {
+			return nil, err
+		}
+	}
+	evt.TxHash = refund.TxHash
+	if err := m.saveRefund(ctx, refund, evt, StatusSubmitted); err != nil {
+		return nil, err
+	}
+	// the tracker goes on updating the record
+	submitted := *refund
+
+	if waiter, ok := f.(facilitator.ReceiptWaiter); ok {
+		m.active.Store(refund.ID, struct{}{})
+		m.followRefund(waiter, config.Confirmations, refund, evt)
+	}
+	return &submitted, nil
+}
+
+// settleRefund verifies the authorization of the refund and submits it. The
+// policy of the network is not applied, the payer is refunded even if it
+// isn't an allowed recipient of payments.
+func (m *Manager) settleRefund(ctx context.Context, f facilitator.Facilitator, refund *store.Refund, payload *types.PaymentPayload, tenant string) error {
+	if payload.Network != refund.Network {
+		return fmt.Errorf("%w: the authorization is for network %s, the settlement was on %s", ErrInvalidRefund, payload.Network, refund.Network)
+	}
+	req := &types.PaymentRequirements{
+		Scheme:            payload.Scheme,
+		Network:           refund.Network,
+		MaxAmountRequired: refund.Amount,
+		PayTo:             refund.To,
+		Asset:             refund.Asset,
+	}
+	verified, err := f.Verify(ctx, payload, req)
+	if err != nil {
+		return err
+	}
+	if !verified.IsValid {
+		return fmt.Errorf("%w: %s", ErrInvalidRefund, verified.InvalidReason)
+	}
+
+	var resp *types.PaymentSettleResponse
+	if queueErr := m.dispatcher.Do(ctx, m.dispatchJob(payload, tenant), func() {
+		resp, err = f.Settle(ctx, payload, req)
+	}); queueErr != nil {
+		return queueErr
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%w: %s", ErrInvalidRefund, resp.Error)
+	}
+	refund.From = resp.Payer
+	refund.TxHash = resp.TxHash
+	return nil
+}
+
+// GetRefund returns the refund with the ID.
+func (m *Manager) GetRefund(ctx context.Context, id string) (*store.Refund, error) {
+	return m.store.GetRefund(ctx, id)
+}
+
+// Refunds returns the refunds of the settlement, or all refunds if the ID is
+// empty, oldest first.
+func (m *Manager) Refunds(ctx context.Context, settlementID string) ([]*store.Refund, error) {
+	return m.store.ListRefunds(ctx, settlementID)
+}
+
+// followRefund tracks the refund transaction in the background. The caller
+// marks the refund as active, it is unmarked once tracking ends.
+func (m *Manager) followRefund(waiter facilitator.ReceiptWaiter, confirmations uint64, refund *store.Refund, evt Event) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.active.Delete(refund.ID)
+		m.trackRefund(waiter, confirmations, refund, evt)
+	}()
+}
+
+// trackRefund follows a refund transaction until it is confirmed or fails.
+func (m *Manager) trackRefund(waiter facilitator.ReceiptWaiter, confirmations uint64, refund *store.Refund, evt Event) {
+	ctx, cancel := context.WithTimeout(paymentctx.With(m.ctx, evt.Metadata()), receiptTimeout)
+	defer cancel()
+
+	receipt, err := waiter.WaitMined(ctx, evt.TxHash)
+	if m.ctx.Err() != nil {
+		// shutting down, the refund is resumed on the next start
+		return
+	}
+	if err == nil && !receipt.Success {
+		err = errors.New("transaction reverted")
+	}
+	if err != nil {
+		evt.Error = err.Error()
+		m.publishRefund(refund, evt, StatusFailed)
+		return
+	}
+	evt.BlockNumber = receipt.BlockNumber
+	m.publishRefund(refund, evt, StatusMined)
+
+	err = waiter.WaitConfirmed(ctx, receipt, confirmations)
+	if m.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		evt.Error = err.Error()
+		m.publishRefund(refund, evt, StatusFailed)
+		return
+	}
+	evt.BlockNumber = receipt.BlockNumber
+	m.publishRefund(refund, evt, StatusConfirmed)
+}
+
+// saveRefund records a transition of the refund and publishes it.
+func (m *Manager) saveRefund(ctx context.Context, refund *store.Refund, evt Event, status Status) error {
+	evt.Status = status
+	evt.Timestamp = m.clock.Now()
+	refund.Status = string(status)
+	refund.Error = evt.Error
+	refund.BlockNumber = evt.BlockNumber
+	refund.UpdatedAt = evt.Timestamp
+	if err := m.store.SaveRefund(ctx, refund); err != nil {
+		return fmt.Errorf("failed to record refund: %w", err)
+	}
+
+	logging.For(logging.Settlement).Debug().
+		EmbedObject(evt.Metadata()).
+		Str("refund_id", refund.ID).
+		Str("status", string(status)).
+		Str("tx_hash", evt.TxHash).
+		Msg("Refund state changed")
+	metrics.Refunds.WithLabelValues(evt.Network, string(status)).Inc()
+	m.hub.Publish(evt)
+	return nil
+}
+
+// publishRefund records a transition of a tracked refund. Failing to store it
+// only affects reporting, the refund is on chain already.
+func (m *Manager) publishRefund(refund *store.Refund, evt Event, status Status) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.saveRefund(ctx, refund, evt, status); err != nil {
+		logging.For(logging.Settlement).Error().Err(err).Str("refund_id", refund.ID).Msg("Failed to store refund")
+	}
+}
+
+// resumeRefunds tracks the refund transactions a previous run left unfinished.
+func (m *Manager) resumeRefunds(ctx context.Context) error {
+	refunds, err := m.store.ListRefunds(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list refunds: %w", err)
+	}
+	for _, refund := range refunds {
+		if Status(refund.Status).IsFinal() {
+			continue
+		}
+		if _, running := m.active.LoadOrStore(refund.ID, struct{}{}); running {
+			continue
+		}
+		f, config, ok := m.registry.Lookup(refund.Network)
+		if !ok {
+			logging.For(logging.Settlement).Warn().Str("refund_id", refund.ID).Str("network", refund.Network).Msg("Can't resume refund of an unconfigured network")
+			continue
+		}
+		waiter, ok := f.(facilitator.ReceiptWaiter)
+		if !ok {
+			continue
+		}
+		evt := Event{
+			ID:          refund.SettlementID,
+			RefundID:    refund.ID,
+			Network:     refund.Network,
+			Payer:       refund.To,
+			Asset:       refund.Asset,
+			TxHash:      refund.TxHash,
+			BlockNumber: refund.BlockNumber,
+		}
+		if settled, err := m.store.GetSettlement(ctx, refund.SettlementID); err == nil {
+			evt.Scheme = settled.Scheme
+			evt.Tenant = settled.Tenant
+			evt.RequestID = settled.RequestID
+			evt.Reference = settled.Reference
+		}
+		m.followRefund(waiter, config.Confirmations, refund, evt)
+	}
+	return nil
+}
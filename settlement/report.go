@@ -0,0 +1,110 @@
+package settlement
+
+import (
+	"cmp"
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// CostReport sums the fees of the settlement transactions of settlements
+// created in [from, to) by network and asset.
+func (m *Manager) CostReport(ctx context.Context, from, to time.Time) (*types.CostReport, error) {
+	settlements, err := m.store.ListSettlements(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return newCostReport(settlements, from, to), nil
+}
+
+type costKey struct {
+	network string
+	asset   string
+}
+
+// costSum accumulates the costs of one report entry
+type costSum struct {
+	entry       types.CostReportEntry
+	fee         *big.Int
+	feeDecimals int
+	feeUSD      float64
+	amountUSD   float64
+	unpricedFee bool
+	unpricedAmt bool
+}
+
+func newCostReport(settlements []*store.Settlement, from, to time.Time) *types.CostReport {
+	report := &types.CostReport{
+		From:    from,
+		To:      to,
+		Entries: []types.CostReportEntry{},
+	}
+
+	sums := make(map[costKey]*costSum)
+	var keys []costKey
+	for _, s := range settlements {
+		if s.Fee == nil {
+			// never mined, so the facilitator paid nothing
+			continue
+		}
+		key := costKey{network: s.Network, asset: s.Asset}
+		sum, ok := sums[key]
+		if !ok {
+			sum = &costSum{
+				entry: types.CostReportEntry{
+					Network:     s.Network,
+					Asset:       s.Asset,
+					FeeCurrency: s.FeeCurrency,
+				},
+				fee:         new(big.Int),
+				feeDecimals: s.FeeDecimals,
+			}
+			sums[key] = sum
+			keys = append(keys, key)
+		}
+
+		sum.entry.Settlements++
+		sum.entry.GasUsed += s.GasUsed
+		sum.fee.Add(sum.fee, s.Fee)
+		if s.FeeUSD != nil {
+			sum.feeUSD += *s.FeeUSD
+			report.TotalFeeUsd += *s.FeeUSD
+		} else {
+			sum.unpricedFee = true
+		}
+
+		if s.Reverted {
+			sum.entry.Reverted++
+			continue
+		}
+		if s.AmountUSD != nil {
+			sum.amountUSD += *s.AmountUSD
+			report.TotalAmountUsd += *s.AmountUSD
+		} else {
+			sum.unpricedAmt = true
+		}
+	}
+
+	slices.SortFunc(keys, func(a, b costKey) int {
+		return cmp.Or(strings.Compare(a.network, b.network), strings.Compare(a.asset, b.asset))
+	})
+	for _, key := range keys {
+		sum := sums[key]
+		entry := sum.entry
+		entry.Fee = sum.fee.String()
+		entry.FeeNative = types.FormatUnits(sum.fee, sum.feeDecimals)
+		if !sum.unpricedFee {
+			entry.FeeUsd = &sum.feeUSD
+		}
+		if !sum.unpricedAmt {
+			entry.AmountUsd = &sum.amountUSD
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+	return report
+}
@@ -0,0 +1,61 @@
+package settlement
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestCostReport(t *testing.T) {
+	usd := func(v float64) *float64 { return &v }
+	gwei := big.NewInt(1_000_000_000)
+	mined := func(network, asset string, gasUsed int64, amountUSD, feeUSD *float64) *store.Settlement {
+		return &store.Settlement{
+			Network:     network,
+			Asset:       asset,
+			Status:      string(StatusConfirmed),
+			AmountUSD:   amountUSD,
+			GasUsed:     uint64(gasUsed),
+			Fee:         new(big.Int).Mul(gwei, big.NewInt(gasUsed)),
+			FeeCurrency: "ETH",
+			FeeDecimals: 18,
+			FeeUSD:      feeUSD,
+		}
+	}
+
+	reverted := mined("eip155:8453", "USDC", 30_000, usd(2), usd(0.1))
+	reverted.Status, reverted.Reverted = string(StatusFailed), true
+	settlements := []*store.Settlement{
+		mined("eip155:8453", "USDC", 60_000, usd(1), usd(0.2)),
+		reverted,
+		mined("eip155:84532", "USDC", 60_000, nil, nil),
+		{Network: "eip155:8453", Asset: "USDC", Status: string(StatusFailed)}, // never submitted
+	}
+
+	from, to := time.Now().Add(-time.Hour), time.Now()
+	report := newCostReport(settlements, from, to)
+	require.Equal(t, from, report.From)
+	require.Len(t, report.Entries, 2)
+
+	base := report.Entries[0]
+	require.Equal(t, "eip155:8453", base.Network)
+	require.Equal(t, 2, base.Settlements)
+	require.Equal(t, 1, base.Reverted)
+	require.Equal(t, uint64(90_000), base.GasUsed)
+	require.Equal(t, "90000000000000", base.Fee)
+	require.Equal(t, "0.00009", base.FeeNative)
+	require.InDelta(t, 0.3, *base.FeeUsd, 1e-9)
+	require.InDelta(t, 1, *base.AmountUsd, 1e-9, "reverted payments are not settled")
+
+	sepolia := report.Entries[1]
+	require.Equal(t, "eip155:84532", sepolia.Network)
+	require.Nil(t, sepolia.FeeUsd)
+	require.Nil(t, sepolia.AmountUsd)
+
+	require.InDelta(t, 0.3, report.TotalFeeUsd, 1e-9)
+	require.InDelta(t, 1, report.TotalAmountUsd, 1e-9)
+}
@@ -0,0 +1,296 @@
+package settlement
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/diagnostics"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+var (
+	// ErrEmptyWindow is returned for scheduling windows that have passed, close
+	// before they open or, for authorizations that don't expire, have no end
+	ErrEmptyWindow = errors.New("the scheduling window is empty")
+	// ErrScheduleCanceled fails the scheduled settlements still waiting when the manager is closed
+	ErrScheduleCanceled = errors.New("the facilitator stopped before the scheduled settlement was submitted")
+)
+
+// defaultMaxScheduled is how many scheduled settlements may wait for their window by default
+const defaultMaxScheduled = 1024
+
+// RejectedError is returned by Schedule for payments that don't verify or
+// whose authorization another settlement used. Reason is the error code a
+// failed settle response reports.
+type RejectedError struct {
+	Reason string
+	Payer  string
+}
+
+func (e *RejectedError) Error() string {
+	return "payment rejected: " + e.Reason
+}
+
+// scheduledSet holds the IDs of the scheduled settlements waiting for their window.
+type scheduledSet struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+// add adds the ID unless limit settlements are waiting already, and reports whether it did.
+func (s *scheduledSet) add(id string, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ids) >= limit {
+		return false
+	}
+	if s.ids == nil {
+		s.ids = make(map[string]struct{})
+	}
+	s.ids[id] = struct{}{}
+	return true
+}
+
+func (s *scheduledSet) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ids, id)
+}
+
+func (s *scheduledSet) has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.ids[id]
+	return ok
+}
+
+// scheduledTimeout bounds a scheduled settlement from leaving its window until
+// its transaction is submitted
+const scheduledTimeout = time.Minute
+
+// Window bounds when a scheduled settlement is submitted.
+type Window struct {
+	// NotBefore is when the window opens, right away if zero
+	NotBefore time.Time
+	// NotAfter is when the window closes, at the latest the settle-by time of
+	// the authorization, see facilitator.Registry.SettleBy
+	NotAfter time.Time
+}
+
+// Schedule accepts the settlement of the payment within the window and returns
+// its ID and the window, capped by the expiry of the authorization. The
+// payment is verified and its authorization claimed before it is accepted, a
+// payment that doesn't verify or whose authorization is used returns a
+// RejectedError. At most DispatcherConfig.MaxScheduled settlements wait,
+// further ones return ErrQueueFull.
+//
+// The settlement waits in the background until the window opens and the gas
+// price of the network falls to facilitator.GasPolicy.ScheduleBelowGwei, or
+// the window closes, so settlements scheduled alike are submitted together in
+// periods of low fees. It is then settled like Settle does, and its outcome is
+// only published as events. Settlements still waiting when the manager is
+// closed fail with ErrScheduleCanceled, those of a process that stopped
+// without closing it are failed by Resume; they aren't resumed.
+func (m *Manager) Schedule(ctx context.Context, payload *types.PaymentPayload, req *types.PaymentRequirements, window Window) (string, Window, error) {
+	if err := m.admit(payload.Network); err != nil {
+		return "", Window{}, err
+	}
+	now := m.clock.Now()
+	if window.NotBefore.Before(now) {
+		window.NotBefore = now
+	}
+	if settleBy, expires := m.registry.SettleBy(payload); expires && (window.NotAfter.IsZero() || window.NotAfter.After(settleBy)) {
+		window.NotAfter = settleBy
+	}
+	if window.NotAfter.IsZero() || window.NotAfter.Before(window.NotBefore) {
+		return "", Window{}, ErrEmptyWindow
+	}
+
+	verified, err := m.Verify(ctx, payload, req)
+	if err != nil {
+		return "", Window{}, err
+	}
+	if !verified.IsValid {
+		return "", Window{}, &RejectedError{Reason: verified.InvalidReason, Payer: verified.Payer}
+	}
+
+	// the settlement outlives the request, but keeps its metadata
+	runCtx, evt := m.newSettlement(context.WithoutCancel(ctx), payload, req)
+	if !m.scheduled.add(evt.ID, m.maxScheduled) {
+		return "", Window{}, ErrQueueFull
+	}
+	m.publish(evt, StatusScheduled)
+	if err := m.claimScheduled(ctx, runCtx, evt, payload); err != nil {
+		m.scheduled.remove(evt.ID)
+		return "", Window{}, err
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.scheduled.remove(evt.ID)
+		m.runScheduled(runCtx, evt, payload, req, window)
+	}()
+	return evt.ID, window, nil
+}
+
+// claimScheduled claims the authorization of the scheduled settlement, so it
+// can't be scheduled or settled again while the settlement waits. The
+// settlement fails if it can't.
+func (m *Manager) claimScheduled(ctx, runCtx context.Context, evt Event, payload *types.PaymentPayload) error {
+	key, _, ok := m.authorization(payload)
+	if !ok {
+		return nil
+	}
+	var rejected *types.PaymentSettleResponse
+	var err error
+	// held only while claiming, with no network limits since nothing is sent
+	job := Job{Flow: evt.Tenant, Keys: []string{"authorization:" + key}}
+	if queueErr := m.dispatcher.Do(ctx, job, func() {
+		rejected, err = m.claim(runCtx, evt.ID, payload)
+	}); queueErr != nil {
+		err = queueErr
+	}
+	if err == nil && rejected == nil {
+		return nil
+	}
+	if err != nil {
+		evt.Error = err.Error()
+	} else {
+		evt.Error = rejected.Error
+		err = &RejectedError{Reason: rejected.Error, Payer: rejected.Payer}
+	}
+	diagnostics.Record(runCtx, diagnostics.KindError, evt.Error, nil)
+	m.publish(evt, StatusFailed)
+	return err
+}
+
+// runScheduled waits for the window of the settlement and then runs it.
+func (m *Manager) runScheduled(ctx context.Context, evt Event, payload *types.PaymentPayload, req *types.PaymentRequirements, window Window) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(m.ctx, cancel)
+	defer stop()
+
+	err := m.awaitWindow(ctx, evt.Network, window)
+	if err != nil {
+		err = ErrScheduleCanceled
+	} else {
+		err = m.admit(evt.Network)
+	}
+	if err != nil {
+		evt.Error = err.Error()
+		diagnostics.Record(ctx, diagnostics.KindError, evt.Error, nil)
+		m.publish(evt, StatusFailed)
+		return
+	}
+
+	ctx, cancelRun := clock.WithTimeout(ctx, m.clock, scheduledTimeout)
+	defer cancelRun()
+	if _, err := m.run(ctx, evt, payload, req); err != nil {
+		// run published the failure
+		logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Msg("Scheduled settlement failed")
+	}
+}
+
+// awaitWindow blocks until the window opens and then until the gas price of
+// the network falls to its threshold or the window closes.
+func (m *Manager) awaitWindow(ctx context.Context, network string, window Window) error {
+	if err := clock.Sleep(ctx, m.clock, window.NotBefore.Sub(m.clock.Now())); err != nil {
+		return err
+	}
+	f, config, ok := m.registry.Lookup(network)
+	if !ok {
+		return nil
+	}
+	reader, ok := f.(facilitator.GasPriceReader)
+	threshold := config.Gas.ScheduleThreshold()
+	if !ok || threshold == nil {
+		return nil
+	}
+
+	closes := m.clock.NewTimer(window.NotAfter.Sub(m.clock.Now()))
+	defer closes.Stop()
+	cheap, release := m.watchGas(network, reader, config.Gas)
+	defer release()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cheap:
+	case <-closes.C():
+		diagnostics.Record(ctx, diagnostics.KindDeferral, "Scheduling window closed above the gas price threshold", map[string]string{"threshold": threshold.String()})
+	}
+	return nil
+}
+
+// gasWatches share a poll of the gas price per network between the scheduled
+// settlements waiting on it.
+type gasWatches struct {
+	mu      sync.Mutex
+	watches map[string]*gasWatch
+}
+
+type gasWatch struct {
+	waiters int
+	// closed once the gas price falls to the threshold, and then replaced
+	cheap chan struct{}
+	stop  context.CancelFunc
+}
+
+// watchGas returns a channel that is closed once the gas price of the network
+// falls to the schedule threshold of the policy, and the function to call once
+// the caller stops waiting. The price is only polled while someone waits.
+func (m *Manager) watchGas(network string, reader facilitator.GasPriceReader, policy facilitator.GasPolicy) (<-chan struct{}, func()) {
+	m.gas.mu.Lock()
+	defer m.gas.mu.Unlock()
+	w, ok := m.gas.watches[network]
+	if !ok {
+		ctx, stop := context.WithCancel(m.ctx)
+		w = &gasWatch{cheap: make(chan struct{}), stop: stop}
+		if m.gas.watches == nil {
+			m.gas.watches = make(map[string]*gasWatch)
+		}
+		m.gas.watches[network] = w
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.pollGas(ctx, network, reader, policy, w)
+		}()
+	}
+	w.waiters++
+	return w.cheap, func() {
+		m.gas.mu.Lock()
+		defer m.gas.mu.Unlock()
+		if w.waiters--; w.waiters == 0 {
+			w.stop()
+			delete(m.gas.watches, network)
+		}
+	}
+}
+
+// pollGas reads the gas price of the network until ctx is done and wakes the
+// waiters of w whenever it is at the threshold.
+func (m *Manager) pollGas(ctx context.Context, network string, reader facilitator.GasPriceReader, policy facilitator.GasPolicy, w *gasWatch) {
+	threshold := policy.ScheduleThreshold()
+	interval := cmp.Or(policy.DeferInterval, facilitator.DefaultGasDeferInterval)
+	for {
+		price, err := reader.GasPrice(ctx)
+		if err != nil && ctx.Err() == nil {
+			// the waiters keep waiting, at the latest until their window closes
+			logging.For(logging.Settlement).Warn().Err(err).Str("network", network).Msg("Failed to read gas price")
+		} else if err == nil && price.Cmp(threshold) <= 0 {
+			m.gas.mu.Lock()
+			close(w.cheap)
+			w.cheap = make(chan struct{})
+			m.gas.mu.Unlock()
+		}
+		if clock.Sleep(ctx, m.clock, interval) != nil {
+			return
+		}
+	}
+}
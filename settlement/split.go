@@ -0,0 +1,173 @@
+package settlement
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/paymentctx"
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// splitRetryInterval is how long a forwarding that stopped early waits before it is tried again
+const splitRetryInterval = 5 * time.Second
+
+// splitJob is a forwarding of a split payment the manager runs.
+type splitJob struct {
+	forward *facilitator.SplitForward
+	// closed once the forwarding ended with a final status
+	done chan struct{}
+}
+
+// startSplit records the forwarding the settlement of the payment needs, if
+// any, and runs it in the background. The forwarding outlives the request, it
+// is resumed on the next start if the manager is closed before it ends.
+func (m *Manager) startSplit(ctx context.Context, f facilitator.Facilitator, evt Event, payload *types.PaymentPayload, req *types.PaymentRequirements, resp *types.PaymentSettleResponse) {
+	forwarder, ok := f.(facilitator.SplitForwarder)
+	if !ok {
+		return
+	}
+	forward := forwarder.PendingSplit(payload, req, resp)
+	if forward == nil {
+		return
+	}
+	now := m.clock.Now()
+	if err := m.saveSplit(context.WithoutCancel(ctx), evt.ID, forward, now); err != nil {
+		// the forwarding runs anyway, it only can't be resumed before it sends a share
+		logging.Ctx(ctx, logging.Settlement).Error().Err(err).Str("tx_hash", resp.TxHash).Msg("Failed to record split payment")
+	}
+	m.followSplit(forwarder, evt, forward, now)
+}
+
+// followSplit runs the forwarding until it ends or the manager is closed,
+// trying it again while it stops early, e.g. because the chain is unreachable.
+func (m *Manager) followSplit(forwarder facilitator.SplitForwarder, evt Event, forward *facilitator.SplitForward, created time.Time) {
+	job := &splitJob{forward: forward, done: make(chan struct{})}
+	m.splits.Store(evt.ID, job)
+	m.active.Store("split:"+evt.ID, struct{}{})
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.active.Delete("split:" + evt.ID)
+		ctx := paymentctx.With(m.ctx, evt.Metadata())
+		hooks := facilitator.SplitHooks{
+			Send: func(ctx context.Context, fn func()) error {
+				return m.dispatcher.Do(ctx, m.signerJob(forward.Network, evt.Tenant), fn)
+			},
+			Save: func(ctx context.Context, forward *facilitator.SplitForward) error {
+				return m.saveSplit(ctx, evt.ID, forward, created)
+			},
+		}
+		for {
+			err := forwarder.ForwardSplit(ctx, forward, hooks)
+			if m.ctx.Err() != nil {
+				// shutting down, the forwarding is resumed on the next start
+				return
+			}
+			if err == nil {
+				break
+			}
+			logging.Ctx(ctx, logging.Settlement).Warn().Err(err).Str("tx_hash", forward.CollectTxHash).Msg("Split payment forwarding stopped, retrying")
+			if clock.Sleep(m.ctx, m.clock, splitRetryInterval) != nil {
+				return
+			}
+		}
+		logging.Ctx(ctx, logging.Settlement).Info().Str("tx_hash", forward.CollectTxHash).Str("status", forward.Status).Str("error", forward.Error).Msg("Split payment forwarding ended")
+		close(job.done)
+	}()
+}
+
+// awaitSplit waits for the forwarding of the split payment of the settlement
+// to end and returns why the settlement fails, empty if it has no forwarding
+// or the shares were paid. ok is false if the manager is closed first.
+func (m *Manager) awaitSplit(evt Event) (failure string, ok bool) {
+	value, running := m.splits.Load(evt.ID)
+	if !running {
+		// the forwarding may have ended in a previous run
+		record, err := m.store.GetSplitForward(m.ctx, evt.ID)
+		if err != nil {
+			return "", true
+		}
+		return splitFailure(record.Status, record.Forward), true
+	}
+	job := value.(*splitJob)
+	select {
+	case <-m.ctx.Done():
+		return "", false
+	case <-job.done:
+	}
+	m.splits.Delete(evt.ID)
+	if job.forward.Status == facilitator.SplitForwarded {
+		return "", true
+	}
+	return types.ErrSplitFailed.Error() + ": " + job.forward.Error, true
+}
+
+// splitFailure returns why a settlement whose recorded forwarding has the
+// status fails, empty if it doesn't.
+func splitFailure(status, encoded string) string {
+	if status == facilitator.SplitForwarded || status == facilitator.SplitPending {
+		return ""
+	}
+	var forward facilitator.SplitForward
+	_ = json.Unmarshal([]byte(encoded), &forward)
+	return types.ErrSplitFailed.Error() + ": " + forward.Error
+}
+
+// saveSplit records the progress of the forwarding of the settlement.
+func (m *Manager) saveSplit(ctx context.Context, id string, forward *facilitator.SplitForward, created time.Time) error {
+	encoded, err := json.Marshal(forward)
+	if err != nil {
+		return fmt.Errorf("failed to encode split payment: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+	return m.store.SaveSplitForward(ctx, &store.SplitForward{
+		SettlementID: id,
+		Network:      forward.Network,
+		Status:       forward.Status,
+		Forward:      string(encoded),
+		CreatedAt:    created,
+		UpdatedAt:    m.clock.Now(),
+	})
+}
+
+// resumeSplits runs the forwardings a previous run left unfinished again.
+func (m *Manager) resumeSplits(ctx context.Context) error {
+	records, err := m.store.ListSplitForwardsByStatus(ctx, facilitator.SplitPending)
+	if err != nil {
+		return fmt.Errorf("failed to list unfinished split payments: %w", err)
+	}
+	for _, record := range records {
+		if _, running := m.splits.Load(record.SettlementID); running {
+			continue
+		}
+		var forward facilitator.SplitForward
+		if err := json.Unmarshal([]byte(record.Forward), &forward); err != nil {
+			logging.For(logging.Settlement).Error().Err(err).Str("settlement_id", record.SettlementID).Msg("Can't resume unreadable split payment")
+			continue
+		}
+		f, _, ok := m.registry.Lookup(record.Network)
+		forwarder, isForwarder := f.(facilitator.SplitForwarder)
+		if !ok || !isForwarder {
+			logging.For(logging.Settlement).Warn().Str("settlement_id", record.SettlementID).Str("network", record.Network).Msg("Can't resume split payment of an unconfigured network")
+			continue
+		}
+		evt := Event{ID: record.SettlementID, Network: record.Network, Payer: forward.Payer}
+		if settled, err := m.store.GetSettlement(ctx, record.SettlementID); err == nil {
+			evt.Tenant = settled.Tenant
+			evt.RequestID = settled.RequestID
+			evt.Reference = settled.Reference
+		}
+		m.followSplit(forwarder, evt, &forward, record.CreatedAt)
+	}
+	if len(records) > 0 {
+		logging.For(logging.Settlement).Info().Int("splits", len(records)).Msg("Resumed unfinished split payments")
+	}
+	return nil
+}
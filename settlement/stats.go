@@ -0,0 +1,122 @@
+package settlement
+
+import (
+	"cmp"
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/store"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+// Stats aggregates the settlements created in [from, to) into buckets of the
+// given length starting at from truncated to it. granularity names the bucket
+// length in the report.
+func (m *Manager) Stats(ctx context.Context, from, to time.Time, bucket time.Duration, granularity string) (*types.SettlementStats, error) {
+	from = from.Truncate(bucket)
+	settlements, err := m.store.ListSettlements(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	stats := newStats(settlements, from, to, bucket)
+	stats.Granularity = granularity
+	return stats, nil
+}
+
+// volumeSum accumulates the volume of an asset in a bucket
+type volumeSum struct {
+	volume   types.SettlementVolume
+	amount   *big.Int
+	decimals int
+	usd      float64
+	unpriced bool
+}
+
+// bucketSum accumulates the settlements of a bucket
+type bucketSum struct {
+	latency   time.Duration
+	confirmed int
+	volumes   map[costKey]*volumeSum
+}
+
+func newStats(settlements []*store.Settlement, from, to time.Time, bucket time.Duration) *types.SettlementStats {
+	stats := &types.SettlementStats{From: from, To: to, Buckets: []types.SettlementStatsBucket{}}
+	for start := from; start.Before(to); start = start.Add(bucket) {
+		stats.Buckets = append(stats.Buckets, types.SettlementStatsBucket{Start: start, Volumes: []types.SettlementVolume{}})
+	}
+
+	sums := make([]bucketSum, len(stats.Buckets))
+	for _, s := range settlements {
+		i := int(s.CreatedAt.Sub(from) / bucket)
+		if i < 0 || i >= len(stats.Buckets) {
+			continue
+		}
+		b, sum := &stats.Buckets[i], &sums[i]
+		b.Settlements++
+		switch status := Status(s.Status); {
+		case status.IsFailure():
+			b.Failed++
+			continue
+		case status == StatusQueued:
+			b.Pending++
+			continue
+		case status == StatusConfirmed:
+			sum.latency += s.UpdatedAt.Sub(s.CreatedAt)
+			sum.confirmed++
+		}
+		b.Settled++
+
+		key := costKey{network: s.Network, asset: s.Asset}
+		if sum.volumes == nil {
+			sum.volumes = make(map[costKey]*volumeSum)
+		}
+		volume, ok := sum.volumes[key]
+		if !ok {
+			volume = &volumeSum{
+				volume:   types.SettlementVolume{Network: s.Network, Asset: s.Asset},
+				amount:   new(big.Int),
+				decimals: s.AssetDecimals,
+			}
+			sum.volumes[key] = volume
+		}
+		volume.volume.Settlements++
+		if amount, ok := new(big.Int).SetString(s.Amount, 10); ok {
+			volume.amount.Add(volume.amount, amount)
+		}
+		if s.AmountUSD != nil {
+			volume.usd += *s.AmountUSD
+		} else {
+			volume.unpriced = true
+		}
+	}
+
+	for i := range stats.Buckets {
+		b, sum := &stats.Buckets[i], &sums[i]
+		if finished := b.Settled + b.Failed; finished > 0 {
+			rate := float64(b.Settled) / float64(finished)
+			b.SuccessRate = &rate
+		}
+		if sum.confirmed > 0 {
+			latency := float64(sum.latency) / float64(sum.confirmed) / float64(time.Millisecond)
+			b.AvgLatencyMs = &latency
+		}
+		for _, volume := range sum.volumes {
+			entry := volume.volume
+			entry.Amount = volume.amount.String()
+			if volume.decimals > 0 {
+				entry.AmountDecimal = types.FormatUnits(volume.amount, volume.decimals)
+			}
+			if !volume.unpriced {
+				entry.AmountUsd = &volume.usd
+			}
+			b.Volumes = append(b.Volumes, entry)
+		}
+		slices.SortFunc(b.Volumes, func(a, b types.SettlementVolume) int {
+			return cmp.Or(strings.Compare(a.Network, b.Network), strings.Compare(a.Asset, b.Asset))
+		})
+	}
+	return stats
+}
@@ -0,0 +1,62 @@
+package settlement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestStats(t *testing.T) {
+	usd := func(v float64) *float64 { return &v }
+	from := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	created := func(at time.Duration, status Status, asset, amount string, amountUSD *float64) *store.Settlement {
+		return &store.Settlement{
+			Network:       "eip155:8453",
+			Asset:         asset,
+			Amount:        amount,
+			AssetDecimals: 6,
+			AmountUSD:     amountUSD,
+			Status:        string(status),
+			CreatedAt:     from.Add(at),
+			UpdatedAt:     from.Add(at + 4*time.Second),
+		}
+	}
+	settlements := []*store.Settlement{
+		created(time.Minute, StatusConfirmed, "USDC", "1500000", usd(1.5)),
+		created(2*time.Minute, StatusConfirmed, "USDC", "500000", usd(0.5)),
+		created(3*time.Minute, StatusSubmitted, "EURC", "1000000", nil),
+		created(4*time.Minute, StatusFailed, "USDC", "1000000", usd(1)),
+		created(2*time.Hour, StatusQueued, "USDC", "1000000", usd(1)),
+	}
+
+	stats := newStats(settlements, from, from.Add(3*time.Hour), time.Hour)
+	require.Len(t, stats.Buckets, 3)
+
+	first := stats.Buckets[0]
+	require.Equal(t, from, first.Start)
+	require.Equal(t, 4, first.Settlements)
+	require.Equal(t, 3, first.Settled)
+	require.Equal(t, 1, first.Failed)
+	require.InDelta(t, 0.75, *first.SuccessRate, 1e-9)
+	require.InDelta(t, 4000, *first.AvgLatencyMs, 1e-9, "only confirmed settlements have a latency")
+	require.Len(t, first.Volumes, 2)
+	require.Equal(t, "EURC", first.Volumes[0].Asset)
+	require.Equal(t, "1", first.Volumes[0].AmountDecimal)
+	require.Nil(t, first.Volumes[0].AmountUsd)
+	require.Equal(t, 2, first.Volumes[1].Settlements)
+	require.Equal(t, "2000000", first.Volumes[1].Amount, "failed settlements add no volume")
+	require.InDelta(t, 2, *first.Volumes[1].AmountUsd, 1e-9)
+
+	empty := stats.Buckets[1]
+	require.Zero(t, empty.Settlements)
+	require.Nil(t, empty.SuccessRate)
+	require.Empty(t, empty.Volumes)
+
+	pending := stats.Buckets[2]
+	require.Equal(t, 1, pending.Pending)
+	require.Nil(t, pending.SuccessRate, "no settlement finished")
+	require.Nil(t, pending.AvgLatencyMs)
+}
@@ -0,0 +1,97 @@
+package settlement
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/internal/outbound"
+	"github.com/gosuda/x402-facilitator/tenant"
+	webhooksdk "github.com/gosuda/x402-facilitator/webhook"
+)
+
+// webhookTimeout bounds the delivery of a single event
+const webhookTimeout = 10 * time.Second
+
+// Webhooks posts the settlement events of tenants as JSON to the webhook
+// endpoints of the tenant, signed as described in package webhook if the
+// endpoint has a secret.
+type Webhooks struct {
+	tenants *tenant.Tenants
+	client  *http.Client
+}
+
+func NewWebhooks(tenants *tenant.Tenants) *Webhooks {
+	return &Webhooks{
+		tenants: tenants,
+		client:  outbound.Client(webhookTimeout),
+	}
+}
+
+// Run delivers the events published to the hub until ctx is done. Events of a
+// tenant reach its endpoints in the order they were published. Failed
+// deliveries are logged and not retried, and events published while the
+// endpoints are too slow to keep up are dropped like for any subscriber.
+func (w *Webhooks) Run(ctx context.Context, hub *Hub) {
+	events, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-events:
+			t, ok := w.tenants.Get(evt.Tenant)
+			if !ok || len(t.Webhooks) == 0 {
+				continue
+			}
+			var wg sync.WaitGroup
+			for _, webhook := range t.Webhooks {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := w.deliver(ctx, webhook, evt); err != nil {
+						logging.For(logging.Settlement).Warn().Err(err).EmbedObject(evt.Metadata()).Msg("Failed to deliver settlement event")
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func (w *Webhooks) deliver(ctx context.Context, webhook tenant.WebhookConfig, evt Event) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if evt.RequestID != "" {
+		// receivers can correlate the event with the request that caused it
+		req.Header.Set(webhooksdk.HeaderRequestID, evt.RequestID)
+	}
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+	if webhook.Secret != "" {
+		req.Header.Set(webhooksdk.HeaderSignature, webhooksdk.Sign([]byte(webhook.Secret), body, time.Now()))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("settlement event rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package settlement
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/tenant"
+	"github.com/gosuda/x402-facilitator/webhook"
+)
+
+func TestWebhooks(t *testing.T) {
+	received := make(chan *webhook.Event, 16)
+	consumer := webhook.NewHandler([]byte("s3cret"), func(ctx context.Context, evt *webhook.Event) error {
+		received <- evt
+		return nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer t0ken", r.Header.Get("Authorization"))
+		require.Equal(t, "req-1", r.Header.Get("X-Request-ID"))
+		consumer.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	tenants, err := tenant.New(map[string]tenant.Config{
+		"shop": {Webhooks: []tenant.WebhookConfig{{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}, Secret: "s3cret"}}},
+	})
+	require.NoError(t, err)
+	hub := NewHub()
+	go NewWebhooks(tenants).Run(t.Context(), hub)
+
+	// events are published until the webhooks subscribed, only those of the tenant are delivered
+	require.Eventually(t, func() bool {
+		hub.Publish(Event{ID: "other", Status: StatusQueued})
+		hub.Publish(Event{ID: "shop", Tenant: "shop", RequestID: "req-1", Status: StatusQueued})
+		select {
+		case evt := <-received:
+			require.Equal(t, "shop", evt.ID)
+			require.Equal(t, webhook.StatusQueued, evt.Status)
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+// The events of the webhook package are decoded from the settlement events,
+// they must not miss any of their fields.
+func TestWebhookEvent(t *testing.T) {
+	usd := 0.01
+	evt := Event{
+		ID: "a", RefundID: "r", Status: StatusMined, Scheme: "evm", Network: "eip155:8453", Payer: "0xpayer",
+		Tenant: "shop", RequestID: "req-1", TxHash: "0x01", PayTo: "0xpayee", Asset: "USDC", Amount: "10000",
+		AmountUSD: &usd, BlockNumber: 12, Error: "reverted", Timestamp: time.Unix(1_700_000_000, 0).UTC(),
+	}
+	encoded, err := json.Marshal(evt)
+	require.NoError(t, err)
+
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.DisallowUnknownFields()
+	var delivered webhook.Event
+	require.NoError(t, dec.Decode(&delivered))
+	reencoded, err := json.Marshal(delivered)
+	require.NoError(t, err)
+	require.JSONEq(t, string(encoded), string(reencoded))
+}
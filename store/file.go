@@ -0,0 +1,351 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Store = (*File)(nil)
+
+// File keeps the records in memory and appends every change to a journal
+// file before it is applied, so the records survive restarts. The journal is
+// replayed and compacted to the latest version of every record when the file
+// is opened.
+type File struct {
+	*Memory
+
+	mu      sync.Mutex // serializes appends
+	journal *os.File
+}
+
+// journalEntry is a line of the journal, holding one of the records
+type journalEntry struct {
+	Settlement          *Settlement   `json:"settlement,omitempty"`
+	Payment             *Payment      `json:"payment,omitempty"`
+	APIKey              *APIKey       `json:"apiKey,omitempty"`
+	Recipient           *Recipient    `json:"recipient,omitempty"`
+	Requirements        *Requirements `json:"requirements,omitempty"`
+	Receipt             *Receipt      `json:"receipt,omitempty"`
+	Refund              *Refund       `json:"refund,omitempty"`
+	SplitForward        *SplitForward `json:"splitForward,omitempty"`
+	Token               *Token        `json:"token,omitempty"`
+	Audit               *AuditRecord  `json:"audit,omitempty"`
+	DeletedPayment      string        `json:"deletedPayment,omitempty"`      // key of a deleted payment
+	DeletedRequirements string        `json:"deletedRequirements,omitempty"` // ID of deleted requirements
+}
+
+// OpenFile opens the journal at path, creating it if it doesn't exist.
+func OpenFile(path string) (*File, error) {
+	memory := NewMemory()
+	if err := replay(path, memory); err != nil {
+		return nil, fmt.Errorf("store: failed to read %s: %w", path, err)
+	}
+	if err := compact(path, memory); err != nil {
+		return nil, fmt.Errorf("store: failed to compact %s: %w", path, err)
+	}
+	journal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return &File{Memory: memory, journal: journal}, nil
+}
+
+// replay loads the records of the journal. A torn last line, left by a crash
+// while it was written, is ignored since its change was never applied.
+func replay(path string, memory *Memory) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if entry.Settlement != nil {
+			memory.settlements[entry.Settlement.ID] = entry.Settlement
+		}
+		if entry.Payment != nil {
+			memory.payments[entry.Payment.Key] = entry.Payment
+		}
+		if entry.DeletedPayment != "" {
+			delete(memory.payments, entry.DeletedPayment)
+		}
+		if entry.APIKey != nil {
+			memory.apiKeys[entry.APIKey.ID] = entry.APIKey
+		}
+		if entry.Recipient != nil {
+			memory.recipients[recipientKey{entry.Recipient.Network, entry.Recipient.Address}] = entry.Recipient
+		}
+		if entry.Requirements != nil {
+			memory.requirements[entry.Requirements.ID] = entry.Requirements
+		}
+		if entry.DeletedRequirements != "" {
+			delete(memory.requirements, entry.DeletedRequirements)
+		}
+		if entry.Receipt != nil {
+			memory.receipts[entry.Receipt.TxHash] = entry.Receipt
+		}
+		if entry.Refund != nil {
+			memory.refunds[entry.Refund.ID] = entry.Refund
+		}
+		if entry.SplitForward != nil {
+			memory.splits[entry.SplitForward.SettlementID] = entry.SplitForward
+		}
+		if entry.Token != nil {
+			memory.tokens[tokenKey{entry.Token.Network, entry.Token.Address}] = entry.Token
+		}
+		if entry.Audit != nil {
+			memory.audit = append(memory.audit, entry.Audit)
+		}
+	}
+}
+
+// compact replaces the journal by one holding a single entry per record.
+func compact(path string, memory *Memory) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, settlement := range memory.settlements {
+		if err := enc.Encode(journalEntry{Settlement: settlement}); err != nil {
+			return err
+		}
+	}
+	for _, payment := range memory.payments {
+		if err := enc.Encode(journalEntry{Payment: payment}); err != nil {
+			return err
+		}
+	}
+	for _, key := range memory.apiKeys {
+		if err := enc.Encode(journalEntry{APIKey: key}); err != nil {
+			return err
+		}
+	}
+	for _, recipient := range memory.recipients {
+		if err := enc.Encode(journalEntry{Recipient: recipient}); err != nil {
+			return err
+		}
+	}
+	for _, requirements := range memory.requirements {
+		if err := enc.Encode(journalEntry{Requirements: requirements}); err != nil {
+			return err
+		}
+	}
+	for _, receipt := range memory.receipts {
+		if err := enc.Encode(journalEntry{Receipt: receipt}); err != nil {
+			return err
+		}
+	}
+	for _, refund := range memory.refunds {
+		if err := enc.Encode(journalEntry{Refund: refund}); err != nil {
+			return err
+		}
+	}
+	for _, forward := range memory.splits {
+		if err := enc.Encode(journalEntry{SplitForward: forward}); err != nil {
+			return err
+		}
+	}
+	for _, token := range memory.tokens {
+		if err := enc.Encode(journalEntry{Token: token}); err != nil {
+			return err
+		}
+	}
+	for _, record := range memory.audit {
+		if err := enc.Encode(journalEntry{Audit: record}); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *File) SaveSettlement(ctx context.Context, settlement *Settlement) error {
+	if err := f.append(journalEntry{Settlement: settlement}); err != nil {
+		return err
+	}
+	return f.Memory.SaveSettlement(ctx, settlement)
+}
+
+// SavePayment holds the journal lock while it applies the change as well, so
+// the journal keeps the order of concurrent InsertPayment calls.
+func (f *File) SavePayment(ctx context.Context, payment *Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write(journalEntry{Payment: payment}); err != nil {
+		return err
+	}
+	return f.Memory.SavePayment(ctx, payment)
+}
+
+func (f *File) InsertPayment(ctx context.Context, payment *Payment) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.Memory.GetPayment(ctx, payment.Key); err == nil {
+		return false, nil
+	}
+	if err := f.write(journalEntry{Payment: payment}); err != nil {
+		return false, err
+	}
+	return f.Memory.InsertPayment(ctx, payment)
+}
+
+func (f *File) DeleteVerifiedPayments(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Memory.mu.Lock()
+	keys := f.Memory.deleteVerifiedPayments(before)
+	f.Memory.mu.Unlock()
+	for _, key := range keys {
+		// a deletion lost in a crash only keeps the record until the next pruning
+		if err := f.write(journalEntry{DeletedPayment: key}); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+func (f *File) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	if err := f.append(journalEntry{APIKey: key}); err != nil {
+		return err
+	}
+	return f.Memory.SaveAPIKey(ctx, key)
+}
+
+func (f *File) SaveRecipient(ctx context.Context, recipient *Recipient) error {
+	if err := f.append(journalEntry{Recipient: recipient}); err != nil {
+		return err
+	}
+	return f.Memory.SaveRecipient(ctx, recipient)
+}
+
+// SaveRequirements holds the journal lock while it applies the change as
+// well, so the journal keeps its order with DeleteRequirements.
+func (f *File) SaveRequirements(ctx context.Context, requirements *Requirements) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write(journalEntry{Requirements: requirements}); err != nil {
+		return err
+	}
+	return f.Memory.SaveRequirements(ctx, requirements)
+}
+
+func (f *File) DeleteRequirements(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Memory.mu.Lock()
+	ids := f.Memory.deleteRequirements(before)
+	f.Memory.mu.Unlock()
+	for _, id := range ids {
+		// a deletion lost in a crash only keeps the record until the next pruning
+		if err := f.write(journalEntry{DeletedRequirements: id}); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+func (f *File) SaveReceipt(ctx context.Context, receipt *Receipt) error {
+	if err := f.append(journalEntry{Receipt: receipt}); err != nil {
+		return err
+	}
+	return f.Memory.SaveReceipt(ctx, receipt)
+}
+
+func (f *File) SaveRefund(ctx context.Context, refund *Refund) error {
+	if err := f.append(journalEntry{Refund: refund}); err != nil {
+		return err
+	}
+	return f.Memory.SaveRefund(ctx, refund)
+}
+
+func (f *File) SaveSplitForward(ctx context.Context, forward *SplitForward) error {
+	if err := f.append(journalEntry{SplitForward: forward}); err != nil {
+		return err
+	}
+	return f.Memory.SaveSplitForward(ctx, forward)
+}
+
+func (f *File) SaveToken(ctx context.Context, token *Token) error {
+	if err := f.append(journalEntry{Token: token}); err != nil {
+		return err
+	}
+	return f.Memory.SaveToken(ctx, token)
+}
+
+func (f *File) AppendAudit(ctx context.Context, record *AuditRecord) error {
+	if record.ID == "" {
+		record.ID = uuid.NewString()
+	}
+	if err := f.append(journalEntry{Audit: record}); err != nil {
+		return err
+	}
+	return f.Memory.AppendAudit(ctx, record)
+}
+
+// append writes the entry to the journal and waits until it is on disk.
+func (f *File) append(entry journalEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.write(entry)
+}
+
+// write is append for callers holding the journal lock.
+func (f *File) write(entry journalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("store: failed to write journal: %w", err)
+	}
+	if err := f.journal.Sync(); err != nil {
+		return fmt.Errorf("store: failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.journal.Close()
+}
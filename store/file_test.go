@@ -0,0 +1,71 @@
+package store
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	created := time.Now().UTC().Truncate(time.Second)
+
+	f, err := OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.SaveSettlement(t.Context(), &Settlement{ID: "a", Status: "queued", CreatedAt: created}))
+	require.NoError(t, f.SaveSettlement(t.Context(), &Settlement{ID: "a", Status: "submitted", TxHash: "0x01", Fee: big.NewInt(42), CreatedAt: created}))
+	require.NoError(t, f.SavePayment(t.Context(), &Payment{Key: "k", SettlementID: "a"}))
+	_, err = f.InsertPayment(t.Context(), &Payment{Key: "verified", UpdatedAt: created})
+	require.NoError(t, err)
+	deleted, err := f.DeleteVerifiedPayments(t.Context(), created.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+	require.NoError(t, f.SaveRequirements(t.Context(), &Requirements{ID: "req", CreatedAt: created}))
+	deleted, err = f.DeleteRequirements(t.Context(), created.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+	require.NoError(t, f.Close())
+
+	// a crash while writing leaves a torn line behind
+	journal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = journal.WriteString(`{"settlement":{"ID":"b","Sta`)
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	f, err = OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	settlement, err := f.GetSettlement(t.Context(), "a")
+	require.NoError(t, err)
+	require.Equal(t, "submitted", settlement.Status)
+	require.Equal(t, big.NewInt(42), settlement.Fee)
+	require.True(t, created.Equal(settlement.CreatedAt))
+	_, err = f.GetSettlement(t.Context(), "b")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	payment, err := f.GetPayment(t.Context(), "k")
+	require.NoError(t, err)
+	require.Equal(t, "a", payment.SettlementID)
+	_, err = f.GetPayment(t.Context(), "verified")
+	require.ErrorIs(t, err, ErrNotFound, "deletions are replayed")
+	_, err = f.GetRequirements(t.Context(), "req")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, bytes.Count(data, []byte("\n")), "the journal is compacted on open")
+}
+
+func TestFileRejectsCorruptJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o600))
+	_, err := OpenFile(path)
+	require.ErrorContains(t, err, "line 1")
+}
@@ -0,0 +1,400 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Store = (*Memory)(nil)
+
+// Memory keeps the records in memory; they are lost on restart.
+type Memory struct {
+	mu           sync.RWMutex
+	settlements  map[string]*Settlement
+	payments     map[string]*Payment
+	apiKeys      map[string]*APIKey
+	recipients   map[recipientKey]*Recipient
+	receipts     map[string]*Receipt
+	requirements map[string]*Requirements
+	refunds      map[string]*Refund
+	splits       map[string]*SplitForward
+	tokens       map[tokenKey]*Token
+	audit        []*AuditRecord
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		settlements:  make(map[string]*Settlement),
+		payments:     make(map[string]*Payment),
+		apiKeys:      make(map[string]*APIKey),
+		recipients:   make(map[recipientKey]*Recipient),
+		receipts:     make(map[string]*Receipt),
+		requirements: make(map[string]*Requirements),
+		refunds:      make(map[string]*Refund),
+		splits:       make(map[string]*SplitForward),
+		tokens:       make(map[tokenKey]*Token),
+	}
+}
+
+func (m *Memory) SaveSettlement(ctx context.Context, settlement *Settlement) error {
+	record := *settlement
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settlements[record.ID] = &record
+	return nil
+}
+
+func (m *Memory) GetSettlement(ctx context.Context, id string) (*Settlement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.settlements[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	settlement := *record
+	return &settlement, nil
+}
+
+func (m *Memory) ListSettlements(ctx context.Context, from, to time.Time) ([]*Settlement, error) {
+	return m.listSettlements(func(record *Settlement) bool {
+		return !record.CreatedAt.Before(from) && record.CreatedAt.Before(to)
+	}), nil
+}
+
+func (m *Memory) ListSettlementsByStatus(ctx context.Context, statuses ...string) ([]*Settlement, error) {
+	return m.listSettlements(func(record *Settlement) bool {
+		return slices.Contains(statuses, record.Status)
+	}), nil
+}
+
+// listSettlements returns copies of the matching records, oldest first.
+func (m *Memory) listSettlements(match func(record *Settlement) bool) []*Settlement {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var settlements []*Settlement
+	for _, record := range m.settlements {
+		if !match(record) {
+			continue
+		}
+		settlement := *record
+		settlements = append(settlements, &settlement)
+	}
+	slices.SortFunc(settlements, func(a, b *Settlement) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return settlements
+}
+
+func (m *Memory) SavePayment(ctx context.Context, payment *Payment) error {
+	record := *payment
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payments[record.Key] = &record
+	return nil
+}
+
+func (m *Memory) InsertPayment(ctx context.Context, payment *Payment) (bool, error) {
+	record := *payment
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.payments[record.Key]; ok {
+		return false, nil
+	}
+	m.payments[record.Key] = &record
+	return true, nil
+}
+
+func (m *Memory) GetPayment(ctx context.Context, key string) (*Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.payments[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	payment := *record
+	return &payment, nil
+}
+
+func (m *Memory) DeleteVerifiedPayments(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.deleteVerifiedPayments(before)), nil
+}
+
+// deleteVerifiedPayments deletes the payments no settlement used that were
+// last updated before the time and returns their keys. The caller must hold
+// the lock.
+func (m *Memory) deleteVerifiedPayments(before time.Time) []string {
+	var keys []string
+	for key, record := range m.payments {
+		if record.SettlementID == "" && record.UpdatedAt.Before(before) {
+			delete(m.payments, key)
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m *Memory) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	record := *key
+	record.Scopes = slices.Clone(key.Scopes)
+	record.Networks = slices.Clone(key.Networks)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKeys[record.ID] = &record
+	return nil
+}
+
+func (m *Memory) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.apiKeys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key := *record
+	return &key, nil
+}
+
+func (m *Memory) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(m.apiKeys))
+	for _, record := range m.apiKeys {
+		key := *record
+		keys = append(keys, &key)
+	}
+	slices.SortFunc(keys, func(a, b *APIKey) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys, nil
+}
+
+// recipientKey identifies a recipient, addresses are only unique per network
+type recipientKey struct {
+	network, address string
+}
+
+func (m *Memory) SaveRecipient(ctx context.Context, recipient *Recipient) error {
+	record := *recipient
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recipients[recipientKey{record.Network, record.Address}] = &record
+	return nil
+}
+
+func (m *Memory) GetRecipient(ctx context.Context, network, address string) (*Recipient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.recipients[recipientKey{network, address}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	recipient := *record
+	return &recipient, nil
+}
+
+func (m *Memory) ListRecipients(ctx context.Context) ([]*Recipient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	recipients := make([]*Recipient, 0, len(m.recipients))
+	for _, record := range m.recipients {
+		recipient := *record
+		recipients = append(recipients, &recipient)
+	}
+	slices.SortFunc(recipients, func(a, b *Recipient) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return recipients, nil
+}
+
+func (m *Memory) SaveRequirements(ctx context.Context, requirements *Requirements) error {
+	record := *requirements
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requirements[record.ID] = &record
+	return nil
+}
+
+func (m *Memory) GetRequirements(ctx context.Context, id string) (*Requirements, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.requirements[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	requirements := *record
+	return &requirements, nil
+}
+
+func (m *Memory) DeleteRequirements(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.deleteRequirements(before)), nil
+}
+
+// deleteRequirements deletes the requirements created before the time and
+// returns their IDs. The caller must hold the lock.
+func (m *Memory) deleteRequirements(before time.Time) []string {
+	var ids []string
+	for id, record := range m.requirements {
+		if record.CreatedAt.Before(before) {
+			delete(m.requirements, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (m *Memory) SaveReceipt(ctx context.Context, receipt *Receipt) error {
+	record := *receipt
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.receipts[record.TxHash] = &record
+	return nil
+}
+
+func (m *Memory) GetReceipt(ctx context.Context, txHash string) (*Receipt, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.receipts[txHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	receipt := *record
+	return &receipt, nil
+}
+
+func (m *Memory) SaveRefund(ctx context.Context, refund *Refund) error {
+	record := *refund
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refunds[record.ID] = &record
+	return nil
+}
+
+func (m *Memory) GetRefund(ctx context.Context, id string) (*Refund, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.refunds[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	refund := *record
+	return &refund, nil
+}
+
+func (m *Memory) ListRefunds(ctx context.Context, settlementID string) ([]*Refund, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var refunds []*Refund
+	for _, record := range m.refunds {
+		if settlementID != "" && record.SettlementID != settlementID {
+			continue
+		}
+		refund := *record
+		refunds = append(refunds, &refund)
+	}
+	slices.SortFunc(refunds, func(a, b *Refund) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return refunds, nil
+}
+
+func (m *Memory) SaveSplitForward(ctx context.Context, forward *SplitForward) error {
+	record := *forward
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.splits[record.SettlementID] = &record
+	return nil
+}
+
+func (m *Memory) GetSplitForward(ctx context.Context, settlementID string) (*SplitForward, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.splits[settlementID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	forward := *record
+	return &forward, nil
+}
+
+func (m *Memory) ListSplitForwardsByStatus(ctx context.Context, statuses ...string) ([]*SplitForward, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var forwards []*SplitForward
+	for _, record := range m.splits {
+		if !slices.Contains(statuses, record.Status) {
+			continue
+		}
+		forward := *record
+		forwards = append(forwards, &forward)
+	}
+	slices.SortFunc(forwards, func(a, b *SplitForward) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return forwards, nil
+}
+
+// tokenKey identifies a token, addresses are only unique per network
+type tokenKey struct {
+	network, address string
+}
+
+func (m *Memory) SaveToken(ctx context.Context, token *Token) error {
+	record := *token
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[tokenKey{record.Network, record.Address}] = &record
+	return nil
+}
+
+func (m *Memory) GetToken(ctx context.Context, network, address string) (*Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.tokens[tokenKey{network, address}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	token := *record
+	return &token, nil
+}
+
+func (m *Memory) AppendAudit(ctx context.Context, record *AuditRecord) error {
+	if record.ID == "" {
+		record.ID = uuid.NewString()
+	}
+	entry := *record
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, &entry)
+	return nil
+}
+
+func (m *Memory) ListAudit(ctx context.Context, from, to time.Time) ([]*AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var records []*AuditRecord
+	for _, entry := range m.audit {
+		if entry.Time.Before(from) || !entry.Time.Before(to) {
+			continue
+		}
+		record := *entry
+		records = append(records, &record)
+	}
+	slices.SortStableFunc(records, func(a, b *AuditRecord) int {
+		return a.Time.Compare(b.Time)
+	})
+	return records, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	start := time.Now()
+	for i, id := range []string{"b", "a", "c"} {
+		require.NoError(t, m.SaveSettlement(t.Context(), &Settlement{
+			ID:        id,
+			Status:    "queued",
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	record, err := m.GetSettlement(t.Context(), "a")
+	require.NoError(t, err)
+	record.Status = "confirmed"
+	got, _ := m.GetSettlement(t.Context(), "a")
+	require.Equal(t, "queued", got.Status, "records are copied")
+
+	require.NoError(t, m.SaveSettlement(t.Context(), record))
+	got, _ = m.GetSettlement(t.Context(), "a")
+	require.Equal(t, "confirmed", got.Status)
+
+	_, err = m.GetSettlement(t.Context(), "d")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	listed, err := m.ListSettlements(t.Context(), start, start.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	require.Equal(t, "b", listed[0].ID)
+	require.Equal(t, "a", listed[1].ID)
+}
+
+func TestMemoryPayments(t *testing.T) {
+	m := NewMemory()
+	require.NoError(t, m.SaveSettlement(t.Context(), &Settlement{ID: "a", Status: "submitted"}))
+	require.NoError(t, m.SaveSettlement(t.Context(), &Settlement{ID: "b", Status: "confirmed"}))
+
+	pending, err := m.ListSettlementsByStatus(t.Context(), "queued", "submitted")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "a", pending[0].ID)
+
+	_, err = m.GetPayment(t.Context(), "eip155:8453:0xpayer:0x01")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, m.SavePayment(t.Context(), &Payment{Key: "eip155:8453:0xpayer:0x01", SettlementID: "a"}))
+	payment, err := m.GetPayment(t.Context(), "eip155:8453:0xpayer:0x01")
+	require.NoError(t, err)
+	require.Equal(t, "a", payment.SettlementID)
+}
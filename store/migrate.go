@@ -0,0 +1,334 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLock is the Postgres advisory lock held while migrating, so
+// instances starting at the same time don't migrate concurrently
+const migrationLock = 0x78343032 // "x402"
+
+// migration is a numbered schema change. Applied migrations are recorded in
+// schema_migrations and never run again, so a released migration must not be
+// edited; changes go into a new one. Statements must run on SQLite and Postgres.
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// migrations of the SQL stores, in order. Times are stored as Unix
+// nanoseconds and big integers as decimal strings, which both databases
+// store and order the same way.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create settlements and payments",
+		statements: []string{
+			`CREATE TABLE settlements (
+				id TEXT PRIMARY KEY,
+				scheme TEXT NOT NULL,
+				network TEXT NOT NULL,
+				payer TEXT NOT NULL,
+				asset TEXT NOT NULL,
+				amount_usd DOUBLE PRECISION,
+				status TEXT NOT NULL,
+				error TEXT NOT NULL,
+				tx_hash TEXT NOT NULL,
+				block_number BIGINT NOT NULL,
+				reverted BOOLEAN NOT NULL,
+				gas_used BIGINT NOT NULL,
+				effective_gas_price TEXT,
+				fee TEXT,
+				fee_currency TEXT NOT NULL,
+				fee_decimals INTEGER NOT NULL,
+				fee_usd DOUBLE PRECISION,
+				created_at BIGINT NOT NULL,
+				updated_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX settlements_created_at ON settlements (created_at)`,
+			`CREATE INDEX settlements_status ON settlements (status)`,
+			`CREATE TABLE payments (
+				key TEXT PRIMARY KEY,
+				payload_hash TEXT NOT NULL,
+				settlement_id TEXT NOT NULL,
+				created_at BIGINT NOT NULL,
+				updated_at BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     2,
+		description: "create api keys and audit log",
+		statements: []string{
+			`CREATE TABLE api_keys (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				secret_hash TEXT NOT NULL,
+				created_at BIGINT NOT NULL,
+				revoked_at BIGINT
+			)`,
+			`CREATE TABLE audit_log (
+				id TEXT PRIMARY KEY,
+				created_at BIGINT NOT NULL,
+				actor TEXT NOT NULL,
+				action TEXT NOT NULL,
+				target TEXT NOT NULL,
+				details TEXT NOT NULL
+			)`,
+			`CREATE INDEX audit_log_created_at ON audit_log (created_at)`,
+		},
+	},
+	{
+		version:     3,
+		description: "add tenant of settlements",
+		statements: []string{
+			`ALTER TABLE settlements ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     4,
+		description: "create recipients",
+		statements: []string{
+			`CREATE TABLE recipients (
+				network TEXT NOT NULL,
+				address TEXT NOT NULL,
+				message TEXT NOT NULL,
+				signature TEXT NOT NULL,
+				created_at BIGINT NOT NULL,
+				revoked_at BIGINT,
+				PRIMARY KEY (network, address)
+			)`,
+		},
+	},
+	{
+		version:     5,
+		description: "create receipts",
+		statements: []string{
+			`CREATE TABLE receipts (
+				tx_hash TEXT PRIMARY KEY,
+				network TEXT NOT NULL,
+				payer TEXT NOT NULL,
+				payee TEXT NOT NULL,
+				amount TEXT NOT NULL,
+				asset TEXT NOT NULL,
+				issued_at BIGINT NOT NULL,
+				signer TEXT NOT NULL,
+				signature TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     6,
+		description: "add request ID of settlements",
+		statements: []string{
+			`ALTER TABLE settlements ADD COLUMN request_id TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     7,
+		description: "create refunds",
+		statements: []string{
+			`CREATE TABLE refunds (
+				id TEXT PRIMARY KEY,
+				settlement_id TEXT NOT NULL,
+				network TEXT NOT NULL,
+				asset TEXT NOT NULL,
+				amount TEXT NOT NULL,
+				from_address TEXT NOT NULL,
+				to_address TEXT NOT NULL,
+				reason TEXT NOT NULL,
+				status TEXT NOT NULL,
+				error TEXT NOT NULL,
+				tx_hash TEXT NOT NULL,
+				block_number BIGINT NOT NULL,
+				created_at BIGINT NOT NULL,
+				updated_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX refunds_settlement_id ON refunds (settlement_id)`,
+		},
+	},
+	{
+		version:     8,
+		description: "add diagnostics of settlements",
+		statements: []string{
+			`ALTER TABLE settlements ADD COLUMN diagnostics TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     9,
+		description: "add scopes and networks of api keys",
+		statements: []string{
+			`ALTER TABLE api_keys ADD COLUMN scopes TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE api_keys ADD COLUMN networks TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     10,
+		description: "create requirements",
+		statements: []string{
+			`CREATE TABLE requirements (
+				id TEXT PRIMARY KEY,
+				tenant TEXT NOT NULL,
+				requirements TEXT NOT NULL,
+				created_at BIGINT NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     11,
+		description: "add recipient and amount of settlements",
+		statements: []string{
+			`ALTER TABLE settlements ADD COLUMN pay_to TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE settlements ADD COLUMN amount TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE settlements ADD COLUMN asset_decimals INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version:     12,
+		description: "add references of settlements and receipts",
+		statements: []string{
+			`ALTER TABLE settlements ADD COLUMN reference TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE receipts ADD COLUMN reference TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     13,
+		description: "create tokens",
+		statements: []string{
+			`CREATE TABLE tokens (
+				network TEXT NOT NULL,
+				address TEXT NOT NULL,
+				symbol TEXT NOT NULL,
+				decimals INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				version TEXT NOT NULL,
+				resolved_at BIGINT NOT NULL,
+				PRIMARY KEY (network, address)
+			)`,
+		},
+	},
+	{
+		version:     14,
+		description: "create split forwards",
+		statements: []string{
+			`CREATE TABLE split_forwards (
+				settlement_id TEXT PRIMARY KEY,
+				network TEXT NOT NULL,
+				status TEXT NOT NULL,
+				forward TEXT NOT NULL,
+				created_at BIGINT NOT NULL,
+				updated_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX split_forwards_status ON split_forwards (status)`,
+		},
+	},
+	{
+		version:     15,
+		description: "index verified payments",
+		statements: []string{
+			`CREATE INDEX payments_verified ON payments (updated_at) WHERE settlement_id = ''`,
+		},
+	},
+	{
+		version:     16,
+		description: "index requirements by creation",
+		statements: []string{
+			`CREATE INDEX requirements_created_at ON requirements (created_at)`,
+		},
+	},
+}
+
+// dialect is the SQL flavor of a database.
+type dialect int
+
+const (
+	sqliteDialect dialect = iota
+	postgresDialect
+)
+
+// rebind replaces the ? placeholders of the query by the placeholders of the dialect.
+func (d dialect) rebind(query string) string {
+	if d != postgresDialect {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// migrate brings the schema of the database up to the latest migration.
+func migrate(ctx context.Context, db *sql.DB, d dialect) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if d == postgresDialect {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+			return fmt.Errorf("failed to lock schema: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLock)
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("schema version %d is newer than the latest known version %d", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, conn, d, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs the statements of the migration and records it in one transaction.
+func applyMigration(ctx context.Context, conn *sql.Conn, d dialect, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`),
+		m.version, m.description, time.Now().UnixNano()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
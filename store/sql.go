@@ -0,0 +1,642 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	_ "modernc.org/sqlite"             // registers the "sqlite" driver
+)
+
+var _ Store = (*SQL)(nil)
+
+// sqliteBusyTimeout is how long SQLite waits for a lock held by another process
+const sqliteBusyTimeout = 5 * time.Second
+
+// SQL keeps the records in a SQLite or Postgres database.
+type SQL struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// OpenSQLite opens the SQLite database at path, creating it if it doesn't
+// exist, and migrates its schema.
+func OpenSQLite(ctx context.Context, path string) (*SQL, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)", path, sqliteBusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	// SQLite runs one write at a time, a single connection queues them instead of failing them as busy
+	db.SetMaxOpenConns(1)
+	return openSQL(ctx, db, sqliteDialect)
+}
+
+// OpenPostgres connects to the Postgres database at the URL and migrates its schema.
+func OpenPostgres(ctx context.Context, url string) (*SQL, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	return openSQL(ctx, db, postgresDialect)
+}
+
+func openSQL(ctx context.Context, db *sql.DB, d dialect) (*SQL, error) {
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if err := migrate(ctx, db, d); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: failed to migrate schema: %w", err)
+	}
+	return &SQL{db: db, dialect: d}, nil
+}
+
+// Close closes the database.
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+var settlementColumns = []string{
+	"id", "scheme", "network", "payer", "asset", "amount_usd",
+	"status", "error", "tx_hash", "block_number",
+	"reverted", "gas_used", "effective_gas_price", "fee", "fee_currency", "fee_decimals", "fee_usd",
+	"created_at", "updated_at", "tenant", "request_id", "diagnostics",
+	"pay_to", "amount", "asset_decimals", "reference",
+}
+
+func (s *SQL) SaveSettlement(ctx context.Context, settlement *Settlement) error {
+	var trail string
+	if settlement.Diagnostics != nil {
+		encoded, err := json.Marshal(settlement.Diagnostics)
+		if err != nil {
+			return fmt.Errorf("store: failed to encode diagnostics: %w", err)
+		}
+		trail = string(encoded)
+	}
+	_, err := s.db.ExecContext(ctx, s.upsert("settlements", settlementColumns),
+		settlement.ID, settlement.Scheme, settlement.Network, settlement.Payer, settlement.Asset, settlement.AmountUSD,
+		settlement.Status, settlement.Error, settlement.TxHash, settlement.BlockNumber,
+		settlement.Reverted, settlement.GasUsed, bigText(settlement.EffectiveGasPrice), bigText(settlement.Fee),
+		settlement.FeeCurrency, settlement.FeeDecimals, settlement.FeeUSD,
+		nanos(settlement.CreatedAt), nanos(settlement.UpdatedAt), settlement.Tenant, settlement.RequestID, trail,
+		settlement.PayTo, settlement.Amount, settlement.AssetDecimals, settlement.Reference,
+	)
+	if err != nil {
+		return fmt.Errorf("store: failed to save settlement: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetSettlement(ctx context.Context, id string) (*Settlement, error) {
+	settlements, err := s.querySettlements(ctx, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(settlements) == 0 {
+		return nil, ErrNotFound
+	}
+	return settlements[0], nil
+}
+
+func (s *SQL) ListSettlements(ctx context.Context, from, to time.Time) ([]*Settlement, error) {
+	return s.querySettlements(ctx, `WHERE created_at >= ? AND created_at < ? ORDER BY created_at`, nanos(from), nanos(to))
+}
+
+func (s *SQL) ListSettlementsByStatus(ctx context.Context, statuses ...string) ([]*Settlement, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	return s.querySettlements(ctx, `WHERE status IN (`+placeholders+`) ORDER BY created_at`, args...)
+}
+
+// querySettlements returns the settlements selected by the condition.
+func (s *SQL) querySettlements(ctx context.Context, condition string, args ...any) ([]*Settlement, error) {
+	query := `SELECT ` + strings.Join(settlementColumns, ", ") + ` FROM settlements ` + condition
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to query settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []*Settlement
+	for rows.Next() {
+		var (
+			settlement           Settlement
+			gasPrice, fee        sql.NullString
+			createdAt, updatedAt int64
+			trail                string
+		)
+		if err := rows.Scan(
+			&settlement.ID, &settlement.Scheme, &settlement.Network, &settlement.Payer, &settlement.Asset, &settlement.AmountUSD,
+			&settlement.Status, &settlement.Error, &settlement.TxHash, &settlement.BlockNumber,
+			&settlement.Reverted, &settlement.GasUsed, &gasPrice, &fee,
+			&settlement.FeeCurrency, &settlement.FeeDecimals, &settlement.FeeUSD,
+			&createdAt, &updatedAt, &settlement.Tenant, &settlement.RequestID, &trail,
+			&settlement.PayTo, &settlement.Amount, &settlement.AssetDecimals, &settlement.Reference,
+		); err != nil {
+			return nil, fmt.Errorf("store: failed to read settlement: %w", err)
+		}
+		if settlement.EffectiveGasPrice, err = parseBig(gasPrice); err != nil {
+			return nil, fmt.Errorf("store: settlement %s: %w", settlement.ID, err)
+		}
+		if settlement.Fee, err = parseBig(fee); err != nil {
+			return nil, fmt.Errorf("store: settlement %s: %w", settlement.ID, err)
+		}
+		if trail != "" {
+			if err := json.Unmarshal([]byte(trail), &settlement.Diagnostics); err != nil {
+				return nil, fmt.Errorf("store: settlement %s: failed to decode diagnostics: %w", settlement.ID, err)
+			}
+		}
+		settlement.CreatedAt, settlement.UpdatedAt = fromNanos(createdAt), fromNanos(updatedAt)
+		settlements = append(settlements, &settlement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to query settlements: %w", err)
+	}
+	return settlements, nil
+}
+
+func (s *SQL) SavePayment(ctx context.Context, payment *Payment) error {
+	_, err := s.db.ExecContext(ctx, s.upsert("payments", []string{"key", "payload_hash", "settlement_id", "created_at", "updated_at"}),
+		payment.Key, payment.PayloadHash, payment.SettlementID, nanos(payment.CreatedAt), nanos(payment.UpdatedAt))
+	if err != nil {
+		return fmt.Errorf("store: failed to save payment: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) InsertPayment(ctx context.Context, payment *Payment) (bool, error) {
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO payments (key, payload_hash, settlement_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (key) DO NOTHING`),
+		payment.Key, payment.PayloadHash, payment.SettlementID, nanos(payment.CreatedAt), nanos(payment.UpdatedAt))
+	if err != nil {
+		return false, fmt.Errorf("store: failed to insert payment: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("store: failed to insert payment: %w", err)
+	}
+	return inserted > 0, nil
+}
+
+func (s *SQL) GetPayment(ctx context.Context, key string) (*Payment, error) {
+	var (
+		payment              Payment
+		createdAt, updatedAt int64
+	)
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT key, payload_hash, settlement_id, created_at, updated_at FROM payments WHERE key = ?`), key).
+		Scan(&payment.Key, &payment.PayloadHash, &payment.SettlementID, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to get payment: %w", err)
+	}
+	payment.CreatedAt, payment.UpdatedAt = fromNanos(createdAt), fromNanos(updatedAt)
+	return &payment, nil
+}
+
+func (s *SQL) DeleteVerifiedPayments(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM payments WHERE settlement_id = '' AND updated_at < ?`), nanos(before))
+	if err != nil {
+		return 0, fmt.Errorf("store: failed to delete verified payments: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("store: failed to delete verified payments: %w", err)
+	}
+	return int(deleted), nil
+}
+
+func (s *SQL) SaveAPIKey(ctx context.Context, key *APIKey) error {
+	var revokedAt sql.NullInt64
+	if key.RevokedAt != nil {
+		revokedAt = sql.NullInt64{Int64: nanos(*key.RevokedAt), Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx, s.upsert("api_keys", []string{"id", "name", "secret_hash", "created_at", "revoked_at", "scopes", "networks"}),
+		key.ID, key.Name, key.SecretHash, nanos(key.CreatedAt), revokedAt, strings.Join(key.Scopes, " "), strings.Join(key.Networks, " "))
+	if err != nil {
+		return fmt.Errorf("store: failed to save api key: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	keys, err := s.queryAPIKeys(ctx, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	return keys[0], nil
+}
+
+func (s *SQL) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	return s.queryAPIKeys(ctx, `ORDER BY created_at`)
+}
+
+func (s *SQL) queryAPIKeys(ctx context.Context, condition string, args ...any) ([]*APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT id, name, secret_hash, created_at, revoked_at, scopes, networks FROM api_keys `+condition), args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		var (
+			key       APIKey
+			createdAt int64
+			revokedAt sql.NullInt64
+			scopes    string
+			networks  string
+		)
+		if err := rows.Scan(&key.ID, &key.Name, &key.SecretHash, &createdAt, &revokedAt, &scopes, &networks); err != nil {
+			return nil, fmt.Errorf("store: failed to read api key: %w", err)
+		}
+		// scopes and CAIP-2 identifiers contain no spaces
+		key.Scopes, key.Networks = strings.Fields(scopes), strings.Fields(networks)
+		key.CreatedAt = fromNanos(createdAt)
+		if revokedAt.Valid {
+			revoked := fromNanos(revokedAt.Int64)
+			key.RevokedAt = &revoked
+		}
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to query api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *SQL) SaveRecipient(ctx context.Context, recipient *Recipient) error {
+	var revokedAt sql.NullInt64
+	if recipient.RevokedAt != nil {
+		revokedAt = sql.NullInt64{Int64: nanos(*recipient.RevokedAt), Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx, s.upsertKey("recipients", 2, []string{"network", "address", "message", "signature", "created_at", "revoked_at"}),
+		recipient.Network, recipient.Address, recipient.Message, recipient.Signature, nanos(recipient.CreatedAt), revokedAt)
+	if err != nil {
+		return fmt.Errorf("store: failed to save recipient: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetRecipient(ctx context.Context, network, address string) (*Recipient, error) {
+	recipients, err := s.queryRecipients(ctx, `WHERE network = ? AND address = ?`, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, ErrNotFound
+	}
+	return recipients[0], nil
+}
+
+func (s *SQL) ListRecipients(ctx context.Context) ([]*Recipient, error) {
+	return s.queryRecipients(ctx, `ORDER BY created_at`)
+}
+
+func (s *SQL) queryRecipients(ctx context.Context, condition string, args ...any) ([]*Recipient, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT network, address, message, signature, created_at, revoked_at FROM recipients `+condition), args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to query recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var recipients []*Recipient
+	for rows.Next() {
+		var (
+			recipient Recipient
+			createdAt int64
+			revokedAt sql.NullInt64
+		)
+		if err := rows.Scan(&recipient.Network, &recipient.Address, &recipient.Message, &recipient.Signature, &createdAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to read recipient: %w", err)
+		}
+		recipient.CreatedAt = fromNanos(createdAt)
+		if revokedAt.Valid {
+			revoked := fromNanos(revokedAt.Int64)
+			recipient.RevokedAt = &revoked
+		}
+		recipients = append(recipients, &recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to query recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+func (s *SQL) SaveRequirements(ctx context.Context, requirements *Requirements) error {
+	_, err := s.db.ExecContext(ctx, s.upsert("requirements", []string{"id", "tenant", "requirements", "created_at"}),
+		requirements.ID, requirements.Tenant, requirements.Requirements, nanos(requirements.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("store: failed to save requirements: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) DeleteRequirements(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM requirements WHERE created_at < ?`), nanos(before))
+	if err != nil {
+		return 0, fmt.Errorf("store: failed to delete requirements: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("store: failed to delete requirements: %w", err)
+	}
+	return int(deleted), nil
+}
+
+func (s *SQL) GetRequirements(ctx context.Context, id string) (*Requirements, error) {
+	var (
+		requirements Requirements
+		createdAt    int64
+	)
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT id, tenant, requirements, created_at FROM requirements WHERE id = ?`), id).
+		Scan(&requirements.ID, &requirements.Tenant, &requirements.Requirements, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to get requirements: %w", err)
+	}
+	requirements.CreatedAt = fromNanos(createdAt)
+	return &requirements, nil
+}
+
+func (s *SQL) SaveReceipt(ctx context.Context, receipt *Receipt) error {
+	_, err := s.db.ExecContext(ctx, s.upsert("receipts", []string{"tx_hash", "network", "payer", "payee", "amount", "asset", "issued_at", "signer", "signature", "reference"}),
+		receipt.TxHash, receipt.Network, receipt.Payer, receipt.Payee, receipt.Amount, receipt.Asset, nanos(receipt.IssuedAt), receipt.Signer, receipt.Signature, receipt.Reference)
+	if err != nil {
+		return fmt.Errorf("store: failed to save receipt: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetReceipt(ctx context.Context, txHash string) (*Receipt, error) {
+	var (
+		receipt  Receipt
+		issuedAt int64
+	)
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT tx_hash, network, payer, payee, amount, asset, issued_at, signer, signature, reference FROM receipts WHERE tx_hash = ?`), txHash).
+		Scan(&receipt.TxHash, &receipt.Network, &receipt.Payer, &receipt.Payee, &receipt.Amount, &receipt.Asset, &issuedAt, &receipt.Signer, &receipt.Signature, &receipt.Reference)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to get receipt: %w", err)
+	}
+	receipt.IssuedAt = fromNanos(issuedAt)
+	return &receipt, nil
+}
+
+var refundColumns = []string{
+	"id", "settlement_id", "network", "asset", "amount", "from_address", "to_address", "reason",
+	"status", "error", "tx_hash", "block_number", "created_at", "updated_at",
+}
+
+func (s *SQL) SaveRefund(ctx context.Context, refund *Refund) error {
+	_, err := s.db.ExecContext(ctx, s.upsert("refunds", refundColumns),
+		refund.ID, refund.SettlementID, refund.Network, refund.Asset, refund.Amount, refund.From, refund.To, refund.Reason,
+		refund.Status, refund.Error, refund.TxHash, refund.BlockNumber, nanos(refund.CreatedAt), nanos(refund.UpdatedAt))
+	if err != nil {
+		return fmt.Errorf("store: failed to save refund: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetRefund(ctx context.Context, id string) (*Refund, error) {
+	refunds, err := s.queryRefunds(ctx, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(refunds) == 0 {
+		return nil, ErrNotFound
+	}
+	return refunds[0], nil
+}
+
+func (s *SQL) ListRefunds(ctx context.Context, settlementID string) ([]*Refund, error) {
+	if settlementID == "" {
+		return s.queryRefunds(ctx, `ORDER BY created_at`)
+	}
+	return s.queryRefunds(ctx, `WHERE settlement_id = ? ORDER BY created_at`, settlementID)
+}
+
+func (s *SQL) queryRefunds(ctx context.Context, condition string, args ...any) ([]*Refund, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT `+strings.Join(refundColumns, ", ")+` FROM refunds `+condition), args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to query refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []*Refund
+	for rows.Next() {
+		var (
+			refund               Refund
+			createdAt, updatedAt int64
+		)
+		if err := rows.Scan(&refund.ID, &refund.SettlementID, &refund.Network, &refund.Asset, &refund.Amount, &refund.From, &refund.To, &refund.Reason,
+			&refund.Status, &refund.Error, &refund.TxHash, &refund.BlockNumber, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to read refund: %w", err)
+		}
+		refund.CreatedAt = fromNanos(createdAt)
+		refund.UpdatedAt = fromNanos(updatedAt)
+		refunds = append(refunds, &refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to query refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+var splitForwardColumns = []string{"settlement_id", "network", "status", "forward", "created_at", "updated_at"}
+
+func (s *SQL) SaveSplitForward(ctx context.Context, forward *SplitForward) error {
+	_, err := s.db.ExecContext(ctx, s.upsert("split_forwards", splitForwardColumns),
+		forward.SettlementID, forward.Network, forward.Status, forward.Forward, nanos(forward.CreatedAt), nanos(forward.UpdatedAt))
+	if err != nil {
+		return fmt.Errorf("store: failed to save split forward: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetSplitForward(ctx context.Context, settlementID string) (*SplitForward, error) {
+	forwards, err := s.querySplitForwards(ctx, `WHERE settlement_id = ?`, settlementID)
+	if err != nil {
+		return nil, err
+	}
+	if len(forwards) == 0 {
+		return nil, ErrNotFound
+	}
+	return forwards[0], nil
+}
+
+func (s *SQL) ListSplitForwardsByStatus(ctx context.Context, statuses ...string) ([]*SplitForward, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	return s.querySplitForwards(ctx, `WHERE status IN (`+placeholders+`) ORDER BY created_at`, args...)
+}
+
+func (s *SQL) querySplitForwards(ctx context.Context, condition string, args ...any) ([]*SplitForward, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT `+strings.Join(splitForwardColumns, ", ")+` FROM split_forwards `+condition), args...)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to query split forwards: %w", err)
+	}
+	defer rows.Close()
+
+	var forwards []*SplitForward
+	for rows.Next() {
+		var (
+			forward              SplitForward
+			createdAt, updatedAt int64
+		)
+		if err := rows.Scan(&forward.SettlementID, &forward.Network, &forward.Status, &forward.Forward, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("store: failed to read split forward: %w", err)
+		}
+		forward.CreatedAt = fromNanos(createdAt)
+		forward.UpdatedAt = fromNanos(updatedAt)
+		forwards = append(forwards, &forward)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to query split forwards: %w", err)
+	}
+	return forwards, nil
+}
+
+func (s *SQL) SaveToken(ctx context.Context, token *Token) error {
+	_, err := s.db.ExecContext(ctx, s.upsertKey("tokens", 2, []string{"network", "address", "symbol", "decimals", "name", "version", "resolved_at"}),
+		token.Network, token.Address, token.Symbol, token.Decimals, token.Name, token.Version, nanos(token.ResolvedAt))
+	if err != nil {
+		return fmt.Errorf("store: failed to save token: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) GetToken(ctx context.Context, network, address string) (*Token, error) {
+	var (
+		token      Token
+		resolvedAt int64
+	)
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT network, address, symbol, decimals, name, version, resolved_at FROM tokens WHERE network = ? AND address = ?`), network, address).
+		Scan(&token.Network, &token.Address, &token.Symbol, &token.Decimals, &token.Name, &token.Version, &resolvedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to get token: %w", err)
+	}
+	token.ResolvedAt = fromNanos(resolvedAt)
+	return &token, nil
+}
+
+func (s *SQL) AppendAudit(ctx context.Context, record *AuditRecord) error {
+	if record.ID == "" {
+		record.ID = uuid.NewString()
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO audit_log (id, created_at, actor, action, target, details) VALUES (?, ?, ?, ?, ?, ?)`),
+		record.ID, nanos(record.Time), record.Actor, record.Action, record.Target, record.Details)
+	if err != nil {
+		return fmt.Errorf("store: failed to append audit record: %w", err)
+	}
+	return nil
+}
+
+func (s *SQL) ListAudit(ctx context.Context, from, to time.Time) ([]*AuditRecord, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT id, created_at, actor, action, target, details FROM audit_log
+		WHERE created_at >= ? AND created_at < ? ORDER BY created_at`), nanos(from), nanos(to))
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*AuditRecord
+	for rows.Next() {
+		var (
+			record    AuditRecord
+			createdAt int64
+		)
+		if err := rows.Scan(&record.ID, &createdAt, &record.Actor, &record.Action, &record.Target, &record.Details); err != nil {
+			return nil, fmt.Errorf("store: failed to read audit record: %w", err)
+		}
+		record.Time = fromNanos(createdAt)
+		records = append(records, &record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: failed to query audit log: %w", err)
+	}
+	return records, nil
+}
+
+// upsert returns the statement inserting a row of the columns, or replacing
+// the row whose first column, the primary key, is the same.
+func (s *SQL) upsert(table string, columns []string) string {
+	return s.upsertKey(table, 1, columns)
+}
+
+// upsertKey is upsert for tables whose primary key is made of the first keys columns.
+func (s *SQL) upsertKey(table string, keys int, columns []string) string {
+	updates := make([]string, 0, len(columns)-keys)
+	for _, column := range columns[keys:] {
+		updates = append(updates, column+" = excluded."+column)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return s.dialect.rebind(`INSERT INTO ` + table + ` (` + strings.Join(columns, ", ") + `) VALUES (` + placeholders + `)
+		ON CONFLICT (` + strings.Join(columns[:keys], ", ") + `) DO UPDATE SET ` + strings.Join(updates, ", "))
+}
+
+// nanos returns the Unix time of t in nanoseconds, 0 for the zero time.
+func nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// bigText returns the decimal representation of n, NULL if n is nil.
+func bigText(n *big.Int) any {
+	if n == nil {
+		return nil
+	}
+	return n.String()
+}
+
+func parseBig(s sql.NullString) (*big.Int, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	n, ok := new(big.Int).SetString(s.String, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s.String)
+	}
+	return n, nil
+}
@@ -0,0 +1,346 @@
+package store
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+)
+
+// testStore checks the behavior every store implementation shares.
+func testStore(t *testing.T, s Store) {
+	ctx := t.Context()
+	start := time.Unix(1_700_000_000, 0)
+	usd := 0.01
+
+	settlement := &Settlement{
+		ID:                "a",
+		Scheme:            "evm",
+		Network:           "eip155:8453",
+		Payer:             "0xpayer",
+		Tenant:            "shop",
+		RequestID:         "req-1",
+		Reference:         "order-1",
+		PayTo:             "0xpayee",
+		Asset:             "USDC",
+		Amount:            "10000",
+		AssetDecimals:     6,
+		AmountUSD:         &usd,
+		Status:            "confirmed",
+		TxHash:            "0x01",
+		BlockNumber:       12,
+		GasUsed:           60_000,
+		EffectiveGasPrice: big.NewInt(1_000_000_000),
+		Fee:               new(big.Int).Mul(big.NewInt(60_000), big.NewInt(1_000_000_000)),
+		FeeCurrency:       "ETH",
+		FeeDecimals:       18,
+		CreatedAt:         start,
+		UpdatedAt:         start.Add(time.Minute),
+	}
+	require.NoError(t, s.SaveSettlement(ctx, settlement))
+	require.NoError(t, s.SaveSettlement(ctx, &Settlement{ID: "b", Status: "queued", CreatedAt: start.Add(-time.Minute)}))
+	require.NoError(t, s.SaveSettlement(ctx, &Settlement{ID: "c", Status: "submitted", CreatedAt: start.Add(time.Hour)}))
+
+	got, err := s.GetSettlement(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, settlement.Fee, got.Fee)
+	require.Equal(t, settlement.EffectiveGasPrice, got.EffectiveGasPrice)
+	require.Equal(t, usd, *got.AmountUSD)
+	require.Nil(t, got.FeeUSD)
+	require.True(t, settlement.CreatedAt.Equal(got.CreatedAt))
+	require.Equal(t, uint64(12), got.BlockNumber)
+	require.Equal(t, "shop", got.Tenant)
+	require.Equal(t, "req-1", got.RequestID)
+	require.Equal(t, "order-1", got.Reference)
+	require.Equal(t, "0xpayee", got.PayTo)
+	require.Equal(t, "10000", got.Amount)
+	require.Equal(t, 6, got.AssetDecimals)
+
+	require.Nil(t, got.Diagnostics)
+
+	got.Status, got.Reverted = "failed", true
+	got.Diagnostics = []diagnostics.Entry{{
+		Time:    start.Add(time.Second).UTC(),
+		Kind:    diagnostics.KindSimulation,
+		Message: "execution reverted",
+		Data:    map[string]string{"revertData": "0x08c379a0"},
+	}}
+	require.NoError(t, s.SaveSettlement(ctx, got))
+	got, err = s.GetSettlement(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "failed", got.Status)
+	require.True(t, got.Reverted)
+	require.Len(t, got.Diagnostics, 1)
+	require.Equal(t, "0x08c379a0", got.Diagnostics[0].Data["revertData"])
+	require.True(t, got.Diagnostics[0].Time.Equal(start.Add(time.Second)))
+
+	_, err = s.GetSettlement(ctx, "d")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	listed, err := s.ListSettlements(ctx, start.Add(-time.Hour), start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	require.Equal(t, "b", listed[0].ID)
+	require.Equal(t, "a", listed[1].ID)
+
+	pending, err := s.ListSettlementsByStatus(ctx, "queued", "submitted")
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, "b", pending[0].ID)
+	require.Equal(t, "c", pending[1].ID)
+
+	_, err = s.GetPayment(ctx, "eip155:8453:0xpayer:0x01")
+	require.ErrorIs(t, err, ErrNotFound)
+	inserted, err := s.InsertPayment(ctx, &Payment{Key: "eip155:8453:0xpayer:0x01", PayloadHash: "ab", CreatedAt: start, UpdatedAt: start})
+	require.NoError(t, err)
+	require.True(t, inserted)
+	require.NoError(t, s.SavePayment(ctx, &Payment{Key: "eip155:8453:0xpayer:0x01", PayloadHash: "ab", SettlementID: "a", CreatedAt: start, UpdatedAt: start}))
+	inserted, err = s.InsertPayment(ctx, &Payment{Key: "eip155:8453:0xpayer:0x01", PayloadHash: "ab", CreatedAt: start, UpdatedAt: start})
+	require.NoError(t, err)
+	require.False(t, inserted, "existing records are kept")
+	payment, err := s.GetPayment(ctx, "eip155:8453:0xpayer:0x01")
+	require.NoError(t, err)
+	require.Equal(t, "a", payment.SettlementID)
+
+	_, err = s.InsertPayment(ctx, &Payment{Key: "eip155:8453:0xpayer:0x02", PayloadHash: "cd", CreatedAt: start, UpdatedAt: start})
+	require.NoError(t, err)
+	_, err = s.InsertPayment(ctx, &Payment{Key: "eip155:8453:0xpayer:0x03", PayloadHash: "ef", CreatedAt: start, UpdatedAt: start.Add(time.Hour)})
+	require.NoError(t, err)
+	deleted, err := s.DeleteVerifiedPayments(ctx, start.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted, "only the verified payment last updated before")
+	_, err = s.GetPayment(ctx, "eip155:8453:0xpayer:0x02")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetPayment(ctx, "eip155:8453:0xpayer:0x03")
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveAPIKey(ctx, &APIKey{ID: "k2", Name: "second", SecretHash: "22", CreatedAt: start.Add(time.Second)}))
+	require.NoError(t, s.SaveAPIKey(ctx, &APIKey{ID: "k1", Name: "first", SecretHash: "11", Scopes: []string{"verify", "settle"}, Networks: []string{"eip155:*"}, CreatedAt: start}))
+	key, err := s.GetAPIKey(ctx, "k1")
+	require.NoError(t, err)
+	require.Equal(t, "11", key.SecretHash)
+	require.Equal(t, []string{"verify", "settle"}, key.Scopes)
+	require.Equal(t, []string{"eip155:*"}, key.Networks)
+	require.Nil(t, key.RevokedAt)
+	revoked := start.Add(time.Hour)
+	key.RevokedAt = &revoked
+	require.NoError(t, s.SaveAPIKey(ctx, key))
+	keys, err := s.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "k1", keys[0].ID)
+	require.True(t, revoked.Equal(*keys[0].RevokedAt))
+	_, err = s.GetAPIKey(ctx, "k3")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.SaveRecipient(ctx, &Recipient{Network: "eip155:8453", Address: "0xB", Message: "m", Signature: "s", CreatedAt: start.Add(time.Second)}))
+	require.NoError(t, s.SaveRecipient(ctx, &Recipient{Network: "eip155:8453", Address: "0xA", Message: "m", Signature: "s", CreatedAt: start}))
+	require.NoError(t, s.SaveRecipient(ctx, &Recipient{Network: "eip155:1", Address: "0xA", Message: "m", Signature: "s", CreatedAt: start.Add(2 * time.Second)}))
+	recipient, err := s.GetRecipient(ctx, "eip155:8453", "0xA")
+	require.NoError(t, err)
+	require.Nil(t, recipient.RevokedAt)
+	recipient.RevokedAt = &revoked
+	require.NoError(t, s.SaveRecipient(ctx, recipient))
+	recipients, err := s.ListRecipients(ctx)
+	require.NoError(t, err)
+	require.Len(t, recipients, 3, "addresses are registered per network")
+	require.Equal(t, "0xA", recipients[0].Address)
+	require.True(t, revoked.Equal(*recipients[0].RevokedAt))
+	require.Nil(t, recipients[2].RevokedAt)
+	_, err = s.GetRecipient(ctx, "eip155:1", "0xB")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.SaveRequirements(ctx, &Requirements{ID: "req1", Tenant: "shop", Requirements: `{"payTo":"0xpayee"}`, CreatedAt: start}))
+	requirements, err := s.GetRequirements(ctx, "req1")
+	require.NoError(t, err)
+	require.Equal(t, "shop", requirements.Tenant)
+	require.JSONEq(t, `{"payTo":"0xpayee"}`, requirements.Requirements)
+	require.True(t, start.Equal(requirements.CreatedAt))
+	_, err = s.GetRequirements(ctx, "req2")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, s.SaveRequirements(ctx, &Requirements{ID: "req3", Tenant: "shop", Requirements: `{}`, CreatedAt: start.Add(time.Hour)}))
+	deleted, err = s.DeleteRequirements(ctx, start.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted, "only the requirements created before")
+	_, err = s.GetRequirements(ctx, "req1")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetRequirements(ctx, "req3")
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveReceipt(ctx, &Receipt{
+		TxHash: "0x01", Network: "eip155:8453", Payer: "0xpayer", Payee: "0xpayee", Amount: "10000", Asset: "0xusdc",
+		IssuedAt: start, Reference: "order-1", Signer: "0xfacilitator", Signature: "0xsig",
+	}))
+	receipt, err := s.GetReceipt(ctx, "0x01")
+	require.NoError(t, err)
+	require.Equal(t, "10000", receipt.Amount)
+	require.Equal(t, "0xsig", receipt.Signature)
+	require.Equal(t, "order-1", receipt.Reference)
+	require.True(t, start.Equal(receipt.IssuedAt))
+	_, err = s.GetReceipt(ctx, "0x02")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.SaveRefund(ctx, &Refund{
+		ID: "r1", SettlementID: "0x01", Network: "eip155:8453", Asset: "0xusdc", Amount: "5000",
+		From: "0xpayee", To: "0xpayer", Reason: "order cancelled", Status: "submitted", TxHash: "0x10", CreatedAt: start,
+	}))
+	require.NoError(t, s.SaveRefund(ctx, &Refund{ID: "r2", SettlementID: "0x02", Amount: "1", Status: "submitted", CreatedAt: start.Add(-time.Second)}))
+	require.NoError(t, s.SaveRefund(ctx, &Refund{
+		ID: "r1", SettlementID: "0x01", Network: "eip155:8453", Asset: "0xusdc", Amount: "5000",
+		From: "0xpayee", To: "0xpayer", Reason: "order cancelled", Status: "confirmed", TxHash: "0x10", BlockNumber: 12,
+		CreatedAt: start, UpdatedAt: start.Add(time.Minute),
+	}))
+	refund, err := s.GetRefund(ctx, "r1")
+	require.NoError(t, err)
+	require.Equal(t, "confirmed", refund.Status)
+	require.Equal(t, uint64(12), refund.BlockNumber)
+	require.Equal(t, "order cancelled", refund.Reason)
+	require.True(t, start.Add(time.Minute).Equal(refund.UpdatedAt))
+	refunds, err := s.ListRefunds(ctx, "0x01")
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	refunds, err = s.ListRefunds(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+	require.Equal(t, "r2", refunds[0].ID)
+	_, err = s.GetRefund(ctx, "r3")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.SaveSplitForward(ctx, &SplitForward{SettlementID: "a", Network: "eip155:8453", Status: "pending", Forward: `{"Status":"pending"}`, CreatedAt: start}))
+	require.NoError(t, s.SaveSplitForward(ctx, &SplitForward{SettlementID: "b", Status: "pending", Forward: "{}", CreatedAt: start.Add(-time.Second)}))
+	require.NoError(t, s.SaveSplitForward(ctx, &SplitForward{SettlementID: "a", Network: "eip155:8453", Status: "forwarded", Forward: `{"Status":"forwarded"}`, CreatedAt: start, UpdatedAt: start.Add(time.Minute)}))
+	forward, err := s.GetSplitForward(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "forwarded", forward.Status)
+	require.Equal(t, `{"Status":"forwarded"}`, forward.Forward)
+	require.True(t, start.Add(time.Minute).Equal(forward.UpdatedAt))
+	forwards, err := s.ListSplitForwardsByStatus(ctx, "pending")
+	require.NoError(t, err)
+	require.Len(t, forwards, 1)
+	require.Equal(t, "b", forwards[0].SettlementID)
+	_, err = s.GetSplitForward(ctx, "c")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.SaveToken(ctx, &Token{Network: "eip155:8453", Address: "0xtoken", Symbol: "EURC", Decimals: 6, Name: "EURC", Version: "1", ResolvedAt: start}))
+	token, err := s.GetToken(ctx, "eip155:8453", "0xtoken")
+	require.NoError(t, err)
+	require.Equal(t, &Token{Network: "eip155:8453", Address: "0xtoken", Symbol: "EURC", Decimals: 6, Name: "EURC", Version: "1", ResolvedAt: token.ResolvedAt}, token)
+	require.True(t, start.Equal(token.ResolvedAt))
+	_, err = s.GetToken(ctx, "eip155:1", "0xtoken")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	record := &AuditRecord{Time: start, Actor: "k2", Action: "apikey.revoke", Target: "k1"}
+	require.NoError(t, s.AppendAudit(ctx, record))
+	require.NotEmpty(t, record.ID)
+	require.NoError(t, s.AppendAudit(ctx, &AuditRecord{Time: start.Add(-time.Second), Actor: "admin", Action: "apikey.create", Target: "k1"}))
+	audit, err := s.ListAudit(ctx, start.Add(-time.Minute), start.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, audit, 2)
+	require.Equal(t, "apikey.create", audit[0].Action)
+	require.Equal(t, record.ID, audit[1].ID)
+}
+
+func TestStores(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testStore(t, NewMemory())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		f, err := OpenFile(filepath.Join(t.TempDir(), "records.jsonl"))
+		require.NoError(t, err)
+		defer f.Close()
+		testStore(t, f)
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := OpenSQLite(t.Context(), filepath.Join(t.TempDir(), "records.db"))
+		require.NoError(t, err)
+		defer db.Close()
+		testStore(t, db)
+	})
+
+	// runs against a scratch database, whose tables are dropped first
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv("X402_TEST_POSTGRES_URL")
+		if url == "" {
+			t.Skip("X402_TEST_POSTGRES_URL is not set")
+		}
+		resetPostgres(t, url)
+		db, err := OpenPostgres(t.Context(), url)
+		require.NoError(t, err)
+		defer db.Close()
+		testStore(t, db)
+	})
+}
+
+func resetPostgres(t *testing.T, url string) {
+	t.Helper()
+	db, err := OpenPostgres(t.Context(), url)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.db.ExecContext(t.Context(), `DROP TABLE IF EXISTS settlements, payments, api_keys, audit_log, recipients, receipts, refunds, schema_migrations`)
+	require.NoError(t, err)
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	db, err := OpenSQLite(t.Context(), path)
+	require.NoError(t, err)
+	require.NoError(t, db.SaveSettlement(t.Context(), &Settlement{ID: "a", Status: "queued"}))
+	require.Equal(t, len(migrations), schemaVersion(t, db))
+	require.NoError(t, db.Close())
+
+	// reopening keeps the records and applies nothing twice
+	db, err = OpenSQLite(t.Context(), path)
+	require.NoError(t, err)
+	_, err = db.GetSettlement(t.Context(), "a")
+	require.NoError(t, err)
+	require.Equal(t, len(migrations), schemaVersion(t, db))
+
+	// a schema written by a newer build is left alone
+	_, err = db.db.ExecContext(t.Context(), `INSERT INTO schema_migrations (version, description, applied_at) VALUES (999, 'future', 0)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	_, err = OpenSQLite(t.Context(), path)
+	require.ErrorContains(t, err, "schema version 999 is newer")
+}
+
+func schemaVersion(t *testing.T, db *SQL) int {
+	t.Helper()
+	var version int
+	require.NoError(t, db.db.QueryRowContext(context.Background(), `SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	return version
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM settlements WHERE status IN (?, ?) AND created_at >= ?`
+	require.Equal(t, query, sqliteDialect.rebind(query))
+	require.Equal(t, `SELECT id FROM settlements WHERE status IN ($1, $2) AND created_at >= $3`, postgresDialect.rebind(query))
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(Config{})
+	require.NoError(t, err)
+	require.IsType(t, &Memory{}, s)
+
+	s, err = New(Config{Path: filepath.Join(t.TempDir(), "records.jsonl")})
+	require.NoError(t, err)
+	require.IsType(t, &File{}, s)
+	s.(*File).Close()
+
+	s, err = New(Config{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "records.db")})
+	require.NoError(t, err)
+	require.IsType(t, &SQL{}, s)
+	s.(*SQL).Close()
+
+	_, err = New(Config{Driver: DriverPostgres})
+	require.ErrorContains(t, err, "requires a url")
+	_, err = New(Config{Driver: "mysql"})
+	require.ErrorContains(t, err, "unknown driver")
+}
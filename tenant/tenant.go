@@ -0,0 +1,169 @@
+// Package tenant scopes the facilitator to the callers sharing it. Every API
+// key belongs to at most one tenant, whose policy limits the networks, assets
+// and recipients its payments may use and how often it may call the API.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/time/rate"
+
+	"github.com/gosuda/x402-facilitator/types"
+	"github.com/gosuda/x402-facilitator/types/caip"
+)
+
+// Config configures a tenant. Empty allowlists allow everything.
+type Config struct {
+	// Key IDs of the API keys authenticating as the tenant
+	Keys []string `mapstructure:"keys"`
+	// CAIP-2 identifiers of the networks payments may be settled on, or families like "eip155:*"
+	Networks []string `mapstructure:"networks"`
+	// Symbols or addresses of the assets payments may be made in
+	Assets []string `mapstructure:"assets"`
+	// Addresses payments may be paid to
+	Recipients []string `mapstructure:"recipients"`
+	// Requests to the payment endpoints, unlimited if the rate is 0
+	RateLimit RateLimitConfig `mapstructure:"rateLimit"`
+	// Priority of the settlements of the tenant when the settlement queue is
+	// backed up, higher ones are submitted first. Requests may ask for a lower one
+	Priority int `mapstructure:"priority"`
+	// Endpoints receiving the settlement events of the tenant
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+}
+
+// RateLimitConfig configures a token bucket.
+type RateLimitConfig struct {
+	// Sustained requests per second, 0 means unlimited
+	Rate float64 `mapstructure:"rate"`
+	// Requests allowed at once, 0 means the rate rounded up
+	Burst int `mapstructure:"burst"`
+}
+
+// WebhookConfig configures an endpoint settlement events are posted to.
+type WebhookConfig struct {
+	URL string `mapstructure:"url"`
+	// Headers sent with every event, e.g. for authentication
+	Headers map[string]string `mapstructure:"headers"`
+	// Secret events are signed with in the X-Webhook-Signature header, unsigned if empty
+	Secret string `mapstructure:"secret"`
+}
+
+// Tenant is a configured tenant.
+type Tenant struct {
+	ID string
+	Config
+
+	limiter *rate.Limiter // nil if unlimited
+}
+
+// Allow reports whether a request fits into the rate limit of the tenant.
+func (t *Tenant) Allow() bool {
+	return t.limiter == nil || t.limiter.Allow()
+}
+
+// Permits checks the payment against the allowlists of the tenant. asset is
+// the address of the paid asset and symbol its symbol, empty if unknown.
+func (t *Tenant) Permits(network, asset, symbol, payTo string) error {
+	if len(t.Networks) > 0 && !caip.MatchAny(t.Networks, network) {
+		return types.ErrNetworkNotAllowed
+	}
+	if len(t.Assets) > 0 && !slices.ContainsFunc(t.Assets, func(allowed string) bool {
+		return strings.EqualFold(allowed, asset) || (symbol != "" && strings.EqualFold(allowed, symbol))
+	}) {
+		return types.ErrAssetNotAllowed
+	}
+	if len(t.Recipients) > 0 && !slices.ContainsFunc(t.Recipients, func(allowed string) bool {
+		return strings.EqualFold(allowed, payTo)
+	}) {
+		return types.ErrRecipientNotAllowed
+	}
+	return nil
+}
+
+// Tenants looks up tenants by ID and by the key IDs of their API keys.
+type Tenants struct {
+	byID  map[string]*Tenant
+	byKey map[string]*Tenant
+}
+
+// New creates the tenants of the configuration, keyed by tenant ID.
+// A key can't belong to more than one tenant.
+func New(configs map[string]Config) (*Tenants, error) {
+	tenants := &Tenants{
+		byID:  make(map[string]*Tenant, len(configs)),
+		byKey: make(map[string]*Tenant),
+	}
+	for id, config := range configs {
+		t := &Tenant{ID: id, Config: config}
+		if config.RateLimit.Rate < 0 || config.RateLimit.Burst < 0 {
+			return nil, fmt.Errorf("tenant %s: rate limit must not be negative", id)
+		}
+		if config.RateLimit.Rate > 0 {
+			burst := config.RateLimit.Burst
+			if burst == 0 {
+				burst = int(config.RateLimit.Rate + 0.999)
+			}
+			t.limiter = rate.NewLimiter(rate.Limit(config.RateLimit.Rate), burst)
+		}
+		for _, webhook := range config.Webhooks {
+			if webhook.URL == "" {
+				return nil, fmt.Errorf("tenant %s: webhook without url", id)
+			}
+		}
+		for _, key := range config.Keys {
+			if other, ok := tenants.byKey[key]; ok {
+				return nil, fmt.Errorf("key %s belongs to tenants %s and %s", key, other.ID, id)
+			}
+			tenants.byKey[key] = t
+		}
+		tenants.byID[id] = t
+	}
+	return tenants, nil
+}
+
+// Get returns the tenant with the ID.
+func (t *Tenants) Get(id string) (*Tenant, bool) {
+	tenant, ok := t.byID[id]
+	return tenant, ok
+}
+
+// ByKey returns the tenant of the API key.
+func (t *Tenants) ByKey(keyID string) (*Tenant, bool) {
+	tenant, ok := t.byKey[keyID]
+	return tenant, ok
+}
+
+// SettlePriority returns the priority of a settlement the tenant asked to run
+// at requested, capped by the priority of the tenant. 0 asks for the
+// priority of the tenant.
+func (t *Tenant) SettlePriority(requested int) int {
+	if requested <= 0 {
+		return t.Priority
+	}
+	return min(requested, t.Priority)
+}
+
+// tenantKey is the context key of the tenant of a request
+var tenantKey = &struct{}{}
+
+// NewContext returns a context carrying the tenant.
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, t)
+}
+
+// FromContext returns the tenant of the context, nil if there is none.
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(tenantKey).(*Tenant)
+	return t
+}
+
+// ID returns the ID of the tenant of the context, empty if there is none.
+func ID(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.ID
+	}
+	return ""
+}
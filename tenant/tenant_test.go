@@ -0,0 +1,74 @@
+package tenant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestPermits(t *testing.T) {
+	tenants, err := New(map[string]Config{
+		"shop": {
+			Keys:       []string{"shop"},
+			Networks:   []string{"eip155:8453"},
+			Assets:     []string{"USDC"},
+			Recipients: []string{"0x00000000000000000000000000000000000000AA"},
+		},
+		"open": {Keys: []string{"open"}},
+		"evm":  {Keys: []string{"evm"}, Networks: []string{"eip155:*"}},
+	})
+	require.NoError(t, err)
+	shop, ok := tenants.ByKey("shop")
+	require.True(t, ok)
+	require.Equal(t, "shop", shop.ID)
+
+	const usdc = "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+	const payTo = "0x00000000000000000000000000000000000000aa"
+	require.NoError(t, shop.Permits("eip155:8453", usdc, "USDC", payTo))
+	require.ErrorIs(t, shop.Permits("eip155:84532", usdc, "USDC", payTo), types.ErrNetworkNotAllowed)
+	require.ErrorIs(t, shop.Permits("eip155:8453", usdc, "", payTo), types.ErrAssetNotAllowed)
+	require.ErrorIs(t, shop.Permits("eip155:8453", usdc, "USDC", "0x01"), types.ErrRecipientNotAllowed)
+
+	open, ok := tenants.Get("open")
+	require.True(t, ok)
+	require.NoError(t, open.Permits("eip155:1", "0x01", "", "0x02"))
+	require.True(t, open.Allow(), "unlimited")
+
+	evm, ok := tenants.Get("evm")
+	require.True(t, ok)
+	require.NoError(t, evm.Permits("eip155:84532", usdc, "USDC", payTo))
+	require.NoError(t, evm.Permits("base", usdc, "USDC", payTo), "network names are converted")
+	require.ErrorIs(t, evm.Permits("solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp", usdc, "USDC", payTo), types.ErrNetworkNotAllowed)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(map[string]Config{
+		"a": {Keys: []string{"shared"}},
+		"b": {Keys: []string{"shared"}},
+	})
+	require.ErrorContains(t, err, "key shared belongs to tenants")
+
+	_, err = New(map[string]Config{"a": {RateLimit: RateLimitConfig{Rate: -1}}})
+	require.ErrorContains(t, err, "must not be negative")
+
+	_, err = New(map[string]Config{"a": {Webhooks: []WebhookConfig{{}}}})
+	require.ErrorContains(t, err, "webhook without url")
+
+	tenants, err := New(map[string]Config{"a": {RateLimit: RateLimitConfig{Rate: 0.5}}})
+	require.NoError(t, err)
+	a, _ := tenants.Get("a")
+	require.True(t, a.Allow())
+	require.False(t, a.Allow(), "the burst defaults to the rate rounded up")
+}
+
+func TestSettlePriority(t *testing.T) {
+	tenants, err := New(map[string]Config{"premium": {Priority: 5}})
+	require.NoError(t, err)
+	premium, _ := tenants.Get("premium")
+
+	require.Equal(t, 5, premium.SettlePriority(0), "the priority of the tenant applies by default")
+	require.Equal(t, 2, premium.SettlePriority(2))
+	require.Equal(t, 5, premium.SettlePriority(9), "requests can't exceed the priority of the tenant")
+}
@@ -0,0 +1,112 @@
+// Package tokenmeta resolves the assets configured by their address alone.
+// The symbol, decimals and EIP-712 domain of their tokens are read from the
+// token contracts and kept in the store, so they are read once and the
+// facilitator starts without the RPC endpoints answering them again. Assets
+// whose metadata can't be read are rejected until it is, they are tried
+// again on an interval.
+package tokenmeta
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/internal/logging"
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+const (
+	// retryInterval is how often tokens whose metadata couldn't be read are tried again
+	retryInterval = time.Minute
+	// readTimeout bounds reading the tokens of a network
+	readTimeout = 30 * time.Second
+)
+
+// Cache keeps the metadata of tokens in the store, see facilitator.TokenCache.
+type Cache struct {
+	records store.Store
+	clock   clock.Clock
+}
+
+var _ facilitator.TokenCache = (*Cache)(nil)
+
+// NewCache creates a cache keeping the metadata in records.
+func NewCache(records store.Store) *Cache {
+	return &Cache{records: records, clock: clock.Real}
+}
+
+// Token returns the metadata kept of the token, nil if none.
+func (c *Cache) Token(ctx context.Context, network, address string) (*facilitator.TokenMetadata, error) {
+	token, err := c.records.GetToken(ctx, network, address)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &facilitator.TokenMetadata{
+		Symbol:   token.Symbol,
+		Decimals: token.Decimals,
+		Name:     token.Name,
+		Version:  token.Version,
+	}, nil
+}
+
+// SaveToken keeps the metadata of the token.
+func (c *Cache) SaveToken(ctx context.Context, network, address string, token *facilitator.TokenMetadata) error {
+	return c.records.SaveToken(ctx, &store.Token{
+		Network:    network,
+		Address:    address,
+		Symbol:     token.Symbol,
+		Decimals:   token.Decimals,
+		Name:       token.Name,
+		Version:    token.Version,
+		ResolvedAt: c.clock.Now(),
+	})
+}
+
+// Resolver resolves the tokens of the networks of a registry.
+type Resolver struct {
+	registry *facilitator.Registry
+	cache    facilitator.TokenCache
+	clock    clock.Clock
+}
+
+// New creates a resolver of the networks in registry, keeping the metadata in records.
+func New(registry *facilitator.Registry, records store.Store) *Resolver {
+	return &Resolver{registry: registry, cache: NewCache(records), clock: clock.Real}
+}
+
+// Resolve resolves the tokens of every network and returns the errors of
+// those that couldn't be.
+func (r *Resolver) Resolve(ctx context.Context) error {
+	var errs []error
+	for _, config := range r.registry.Networks() {
+		f, _, _ := r.registry.Lookup(config.Network)
+		resolver, ok := f.(facilitator.TokenResolver)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(ctx, readTimeout)
+		errs = append(errs, resolver.ResolveTokens(ctx, r.cache))
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// Run retries the tokens that couldn't be resolved until all are or ctx is done.
+func (r *Resolver) Run(ctx context.Context) {
+	for {
+		if clock.Sleep(ctx, r.clock, retryInterval) != nil {
+			return
+		}
+		err := r.Resolve(ctx)
+		if err == nil {
+			return
+		}
+		if ctx.Err() == nil {
+			logging.For(logging.Assets).Warn().Err(err).Msg("Failed to resolve token metadata")
+		}
+	}
+}
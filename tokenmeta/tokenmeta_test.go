@@ -0,0 +1,41 @@
+package tokenmeta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosuda/x402-facilitator/clock"
+	"github.com/gosuda/x402-facilitator/facilitator"
+	"github.com/gosuda/x402-facilitator/store"
+)
+
+func TestCache(t *testing.T) {
+	const (
+		network = "eip155:8453"
+		address = "0x00000000000000000000000000000000000000d1"
+	)
+	records := store.NewMemory()
+	cache := NewCache(records)
+	now := time.Unix(1_700_000_000, 0)
+	cache.clock = clock.NewFake(now)
+
+	token, err := cache.Token(t.Context(), network, address)
+	require.NoError(t, err)
+	require.Nil(t, token, "nothing is cached")
+
+	metadata := &facilitator.TokenMetadata{Symbol: "DOM", Decimals: 18, Name: "Domain Token", Version: "2"}
+	require.NoError(t, cache.SaveToken(t.Context(), network, address, metadata))
+	token, err = cache.Token(t.Context(), network, address)
+	require.NoError(t, err)
+	require.Equal(t, metadata, token)
+
+	record, err := records.GetToken(t.Context(), network, address)
+	require.NoError(t, err)
+	require.True(t, now.Equal(record.ResolvedAt))
+
+	token, err = cache.Token(t.Context(), "eip155:84532", address)
+	require.NoError(t, err)
+	require.Nil(t, token, "tokens are cached by network")
+}
@@ -0,0 +1,24 @@
+package types
+
+import "time"
+
+// APIKey describes an API key, without its secret.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Scopes granted to the key: verify, settle, read-status or admin
+	Scopes []string `json:"scopes"`
+	// Networks the key may pay on, all if absent
+	Networks []string `json:"networks,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	// When the key was revoked, absent while it is valid
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// CreatedAPIKey is a newly created API key.
+type CreatedAPIKey struct {
+	APIKey
+	// The key, sent in the X-API-Key header. It is not stored and only returned once
+	Key string `json:"key"`
+}
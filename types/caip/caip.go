@@ -0,0 +1,165 @@
+// Package caip handles CAIP-2 chain identifiers, "<namespace>:<reference>"
+// such as "eip155:8453", the way x402 names networks. Besides parsing it
+// matches identifiers against family patterns like "eip155:*" and converts
+// between identifiers, EVM chain IDs and the network names of x402 version 1.
+package caip
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Namespaces of the chains supported by the facilitator
+const (
+	EIP155 = "eip155"
+	Solana = "solana"
+	Sui    = "sui"
+	Tron   = "tron"
+)
+
+// Wildcard is the reference of patterns matching every chain of a namespace
+const Wildcard = "*"
+
+// ErrInvalid is returned for strings that aren't CAIP-2 chain identifiers
+var ErrInvalid = errors.New("not a CAIP-2 identifier")
+
+// syntax of CAIP-2 chain identifiers
+var (
+	namespacePattern = regexp.MustCompile(`^[-a-z0-9]{3,8}$`)
+	referencePattern = regexp.MustCompile(`^[-_a-zA-Z0-9]{1,32}$`)
+)
+
+// ChainID is a parsed CAIP-2 chain identifier.
+type ChainID struct {
+	Namespace string
+	Reference string
+}
+
+// Parse parses a CAIP-2 chain identifier, e.g. "eip155:8453".
+func Parse(network string) (ChainID, error) {
+	namespace, reference, ok := strings.Cut(network, ":")
+	if !ok || !namespacePattern.MatchString(namespace) || !referencePattern.MatchString(reference) {
+		return ChainID{}, fmt.Errorf("network %q is %w, e.g. \"eip155:8453\"", network, ErrInvalid)
+	}
+	return ChainID{Namespace: namespace, Reference: reference}, nil
+}
+
+// String returns the identifier, e.g. "eip155:8453".
+func (id ChainID) String() string {
+	return id.Namespace + ":" + id.Reference
+}
+
+// Valid reports whether network is a CAIP-2 chain identifier.
+func Valid(network string) bool {
+	_, err := Parse(network)
+	return err == nil
+}
+
+// Namespace returns the namespace of the identifier, e.g. "eip155", or an
+// empty string if it has none.
+func Namespace(network string) string {
+	namespace, _, ok := strings.Cut(network, ":")
+	if !ok {
+		return ""
+	}
+	return namespace
+}
+
+// Family returns the pattern matching every chain of the identifier's
+// namespace, e.g. "eip155:*".
+func Family(network string) string {
+	return Namespace(network) + ":" + Wildcard
+}
+
+// IsFamily reports whether pattern is a family pattern like "eip155:*".
+func IsFamily(pattern string) bool {
+	namespace, ok := strings.CutSuffix(pattern, ":"+Wildcard)
+	return ok && namespacePattern.MatchString(namespace)
+}
+
+// ValidPattern reports whether pattern is a chain identifier or a family
+// pattern like "eip155:*".
+func ValidPattern(pattern string) bool {
+	return IsFamily(pattern) || Valid(pattern)
+}
+
+// Match reports whether the network matches the pattern, either the same
+// chain or a family pattern of its namespace. Network names are converted to
+// their identifiers first.
+func Match(pattern, network string) bool {
+	network = Normalize(network)
+	if namespace, ok := strings.CutSuffix(pattern, ":"+Wildcard); ok {
+		return Namespace(network) == namespace
+	}
+	return Normalize(pattern) == network
+}
+
+// MatchAny reports whether the network matches one of the patterns.
+func MatchAny(patterns []string, network string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, network) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromEVM returns the identifier of an EVM chain ID, e.g. "eip155:8453".
+func FromEVM(chainID *big.Int) string {
+	return EIP155 + ":" + chainID.String()
+}
+
+// EVMChainID returns the chain ID of an "eip155:<chainId>" identifier.
+func EVMChainID(network string) (*big.Int, bool) {
+	reference, ok := strings.CutPrefix(network, EIP155+":")
+	if !ok {
+		return nil, false
+	}
+	chainID, ok := new(big.Int).SetString(reference, 10)
+	if !ok || chainID.Sign() <= 0 {
+		return nil, false
+	}
+	return chainID, true
+}
+
+// networkNames maps the network names of x402 version 1 to their identifiers
+var networkNames = map[string]string{
+	"ethereum":         "eip155:1",
+	"sepolia":          "eip155:11155111",
+	"base":             "eip155:8453",
+	"base-sepolia":     "eip155:84532",
+	"optimism":         "eip155:10",
+	"optimism-sepolia": "eip155:11155420",
+	"arbitrum":         "eip155:42161",
+	"arbitrum-sepolia": "eip155:421614",
+	"solana":           "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp",
+	"solana-devnet":    "solana:EtWTRABZaYq6iMfeYKouRu166VU2xqa1",
+}
+
+// FromName returns the identifier of a network name, e.g. "eip155:8453" for "base".
+func FromName(name string) (string, bool) {
+	network, ok := networkNames[name]
+	return network, ok
+}
+
+// Name returns the network name of the identifier, e.g. "base" for
+// "eip155:8453", or an empty string if it has none.
+func Name(network string) string {
+	for name, id := range networkNames {
+		if id == network {
+			return name
+		}
+	}
+	return ""
+}
+
+// Normalize returns the identifier of a network name and any other string unchanged.
+func Normalize(network string) string {
+	if id, ok := networkNames[network]; ok {
+		return id
+	}
+	return network
+}
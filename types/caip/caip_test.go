@@ -0,0 +1,67 @@
+package caip
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	id, err := Parse("eip155:8453")
+	require.NoError(t, err)
+	require.Equal(t, ChainID{Namespace: EIP155, Reference: "8453"}, id)
+	require.Equal(t, "eip155:8453", id.String())
+
+	for _, network := range []string{"", "base", "eip155:", ":8453", "EIP155:8453", "eip155:84 53", "ab:1", "eip155:*"} {
+		_, err := Parse(network)
+		require.ErrorIs(t, err, ErrInvalid, network)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, network string
+		expected         bool
+	}{
+		{"eip155:8453", "eip155:8453", true},
+		{"eip155:8453", "eip155:84532", false},
+		{"eip155:*", "eip155:84532", true},
+		{"eip155:*", "solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp", false},
+		{"eip155:8453", "base", true},
+		{"base", "eip155:8453", true},
+		{"solana:*", "solana-devnet", true},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expected, Match(c.pattern, c.network), "%s ~ %s", c.pattern, c.network)
+	}
+	require.True(t, MatchAny([]string{"solana:*", "eip155:8453"}, "eip155:8453"))
+	require.False(t, MatchAny(nil, "eip155:8453"))
+
+	require.True(t, ValidPattern("eip155:*"))
+	require.True(t, ValidPattern("eip155:8453"))
+	require.False(t, ValidPattern("*"))
+	require.False(t, ValidPattern("base"))
+	require.True(t, IsFamily("eip155:*"))
+	require.False(t, IsFamily("eip155:8453"))
+	require.Equal(t, "eip155:*", Family("eip155:8453"))
+}
+
+func TestConversions(t *testing.T) {
+	require.Equal(t, "eip155:8453", FromEVM(big.NewInt(8453)))
+	chainID, ok := EVMChainID("eip155:84532")
+	require.True(t, ok)
+	require.Equal(t, int64(84532), chainID.Int64())
+	for _, network := range []string{"eip155:0", "eip155:base", "solana:8453"} {
+		_, ok := EVMChainID(network)
+		require.False(t, ok, network)
+	}
+
+	network, ok := FromName("base-sepolia")
+	require.True(t, ok)
+	require.Equal(t, "eip155:84532", network)
+	require.Equal(t, "base-sepolia", Name(network))
+	require.Equal(t, "", Name("eip155:999999"))
+	require.Equal(t, "eip155:84532", Normalize("base-sepolia"))
+	require.Equal(t, "eip155:999999", Normalize("eip155:999999"))
+}
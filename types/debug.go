@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+
+	"github.com/gosuda/x402-facilitator/diagnostics"
+)
+
+// SettlementDebug is a settlement and the diagnostic trail it failed with:
+// the simulation and its revert data, gas estimates, RPC errors, retries and
+// the raw signed transaction.
+type SettlementDebug struct {
+	ID      string `json:"id"`
+	Scheme  string `json:"scheme"`
+	Network string `json:"network"`
+	Payer   string `json:"payer,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+	// ID of the API request of the settlement, the X-Request-ID header
+	RequestID string `json:"requestId,omitempty"`
+	// Reference the resource server attached to the settle request
+	Reference string `json:"reference,omitempty"`
+	Asset     string `json:"asset,omitempty"`
+	// queued, submitted, mined, confirmed, failed or expired
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	TxHash      string `json:"txHash,omitempty"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	Reverted    bool   `json:"reverted,omitempty"`
+	GasUsed     uint64 `json:"gasUsed,omitempty"`
+	// Steps of the settlement in the order they happened, empty unless it failed
+	Diagnostics []diagnostics.Entry `json:"diagnostics"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
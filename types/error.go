@@ -12,4 +12,111 @@ var (
 	ErrInvalidToken         = errors.New("invalid_token")
 	ErrTokenMismatch        = errors.New("token_mismatch")
 	ErrInsufficientBalance  = errors.New("insufficient_balance")
+	ErrRPCAnomaly           = errors.New("rpc_anomaly")
+	ErrAmountExceedsLimit   = errors.New("amount_exceeds_limit")
+	ErrPriceUnavailable     = errors.New("price_unavailable")
+	ErrAuthorizationUsed    = errors.New("authorization_already_used")
+
+	ErrAuthorizationExpired     = errors.New("authorization_expired")
+	ErrAuthorizationNotYetValid = errors.New("authorization_not_yet_valid")
+	ErrInsufficientAllowance    = errors.New("insufficient_allowance")
+	ErrTransactionReverted      = errors.New("transaction_reverted")
+	ErrTransferNotEmitted       = errors.New("transfer_not_emitted")
+	ErrRecipientMismatch        = errors.New("recipient_mismatch")
+	ErrSpenderMismatch          = errors.New("spender_mismatch")
+	ErrUnregisteredRequirements = errors.New("unregistered_requirements")
+	ErrValueMismatch            = errors.New("value_mismatch")
+	ErrNonceTooHigh             = errors.New("nonce_too_high")
+	ErrFeeOnTransferToken       = errors.New("fee_on_transfer_token")
+	ErrGasTooExpensive          = errors.New("gas_too_expensive")
+
+	ErrFeePayerInsufficientFunds = errors.New("fee_payer_insufficient_funds")
+	ErrRecipientAccountMissing   = errors.New("recipient_account_missing")
+
+	ErrNetworkNotAllowed      = errors.New("network_not_allowed")
+	ErrAssetNotAllowed        = errors.New("asset_not_allowed")
+	ErrRecipientNotAllowed    = errors.New("recipient_not_allowed")
+	ErrRecipientNotRegistered = errors.New("recipient_not_registered")
+	ErrRecipientUnavailable   = errors.New("recipient_registry_unavailable")
+	ErrComplianceRejected     = errors.New("compliance_rejected")
+	ErrScreeningUnavailable   = errors.New("screening_unavailable")
+
+	ErrInvalidSplit      = errors.New("invalid_split")
+	ErrSplitNotSupported = errors.New("split_not_supported")
+	ErrSplitFailed       = errors.New("split_failed")
 )
+
+// ErrorCodes returns the codes of the errors above, which responses report in
+// their error and invalidReason fields.
+func ErrorCodes() []string {
+	errs := []error{
+		ErrInvalidPayloadFormat,
+		ErrIncompatibleScheme,
+		ErrNetworkMismatch,
+		ErrInvalidNetwork,
+		ErrNetworkIDMismatch,
+		ErrInvalidSignature,
+		ErrInvalidToken,
+		ErrTokenMismatch,
+		ErrInsufficientBalance,
+		ErrRPCAnomaly,
+		ErrAmountExceedsLimit,
+		ErrPriceUnavailable,
+		ErrAuthorizationUsed,
+		ErrAuthorizationExpired,
+		ErrAuthorizationNotYetValid,
+		ErrInsufficientAllowance,
+		ErrTransactionReverted,
+		ErrTransferNotEmitted,
+		ErrRecipientMismatch,
+		ErrSpenderMismatch,
+		ErrUnregisteredRequirements,
+		ErrValueMismatch,
+		ErrNonceTooHigh,
+		ErrFeeOnTransferToken,
+		ErrGasTooExpensive,
+		ErrFeePayerInsufficientFunds,
+		ErrRecipientAccountMissing,
+		ErrNetworkNotAllowed,
+		ErrAssetNotAllowed,
+		ErrRecipientNotAllowed,
+		ErrRecipientNotRegistered,
+		ErrRecipientUnavailable,
+		ErrComplianceRejected,
+		ErrScreeningUnavailable,
+		ErrInvalidSplit,
+		ErrSplitNotSupported,
+		ErrSplitFailed,
+	}
+	codes := make([]string, len(errs))
+	for i, err := range errs {
+		codes[i] = err.Error()
+	}
+	return codes
+}
+
+// ErrorCodeTimeout is the code of requests aborted because their deadline passed
+const ErrorCodeTimeout = "TIMEOUT"
+
+// ErrorCodeChainUnavailable is the code of requests that need the chain while
+// the RPC endpoints of the network can't be reached
+const ErrorCodeChainUnavailable = "CHAIN_UNAVAILABLE"
+
+// ErrorCodeUnsupportedNetwork is the code of payments on networks the
+// facilitator isn't configured for
+const ErrorCodeUnsupportedNetwork = "UNSUPPORTED_NETWORK"
+
+// ErrorResponse is the body of errors clients are expected to handle programmatically.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// UnsupportedNetworkResponse is returned with status 400 for payments on
+// networks the facilitator isn't configured for.
+type UnsupportedNetworkResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// CAIP-2 identifiers of the networks payments are accepted on
+	SupportedNetworks []string `json:"supportedNetworks"`
+}
@@ -26,9 +26,9 @@ type PaymentRequirements struct {
 	// Address of the EIP-3009 compliant ERC20 contract
 	Asset string `json:"asset"`
 	// Output schema of the resource response (optional)
-	OutputSchema *json.RawMessage `json:"outputSchema,omitempty"`
+	OutputSchema *json.RawMessage `json:"outputSchema,omitempty" swaggertype:"object"`
 	// Extra information about the payment details specific to the scheme
-	Extra *json.RawMessage `json:"extra,omitempty"`
+	Extra *json.RawMessage `json:"extra,omitempty" swaggertype:"object"`
 }
 
 // PaymentPayload represents the data the client sends in the X-PAYMENT header.
@@ -40,14 +40,21 @@ type PaymentPayload struct {
 	// Network ID of the accepted paymentRequirements the client is using to pay
 	Network string `json:"network"`
 	// Payload is E-dependent and may contain authorization and signature data
-	Payload json.RawMessage `json:"payload"`
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
 }
 
 // PaymentVerifyRequest is the request body sent to facilitator's /verify endpoint.
 type PaymentVerifyRequest struct {
-	X402Version         int                 `json:"x402Version"`
-	PaymentHeader       PaymentPayload      `json:"paymentHeader"`
-	PaymentRequirements PaymentRequirements `json:"paymentRequirements"`
+	X402Version   int            `json:"x402Version"`
+	PaymentHeader PaymentPayload `json:"paymentHeader"`
+	// Requirements of the payment, required unless requirementsId is set
+	PaymentRequirements PaymentRequirements `json:"paymentRequirements,omitzero"`
+	// ID of requirements registered with POST /requirements, instead of paymentRequirements
+	RequirementsID string `json:"requirementsId,omitempty"`
+	// Opaque reference of the resource server, e.g. an order ID, returned in the
+	// response and, on settlement, kept with the settlement, its webhook events
+	// and receipt. At most 256 characters
+	Reference string `json:"reference,omitempty"`
 }
 
 // PaymentVerifyResponse is the response returned from the /verify endpoint.
@@ -57,13 +64,39 @@ type PaymentVerifyResponse struct {
 	// Error message or reason for invalidity, if applicable
 	InvalidReason string `json:"invalidReason,omitempty"`
 	Payer         string `json:"payer,omitempty"`
+	// Whether the chain couldn't be reached, so only the signature and terms of
+	// the payment were checked, not the balance of the payer or whether the
+	// authorization was used
+	ChainChecksSkipped bool `json:"chainChecksSkipped,omitempty"`
+	// Reference of the request, if it had one
+	Reference string `json:"reference,omitempty"`
 }
 
 // PaymentSettleRequest is the request body sent to facilitator's /settle endpoint.
 type PaymentSettleRequest struct {
-	X402Version         int                 `json:"x402Version"`
-	PaymentHeader       PaymentPayload      `json:"paymentHeader"`
-	PaymentRequirements PaymentRequirements `json:"paymentRequirements"`
+	X402Version   int            `json:"x402Version"`
+	PaymentHeader PaymentPayload `json:"paymentHeader"`
+	// Requirements of the payment, required unless requirementsId is set
+	PaymentRequirements PaymentRequirements `json:"paymentRequirements,omitzero"`
+	// ID of requirements registered with POST /requirements, instead of paymentRequirements
+	RequirementsID string `json:"requirementsId,omitempty"`
+	// Deadline of the settlement in milliseconds, capped by the server maximum. The server default applies if 0
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+	// Priority of the settlement when the queue is backed up, capped by the priority of the tenant. The tenant's applies if 0
+	Priority int `json:"priority,omitempty"`
+	// Settlements of the same tenant and payee with this key are submitted one
+	// after the other in the order they arrive, at most 128 characters
+	OrderingKey string `json:"orderingKey,omitempty"`
+	// Opaque reference of the resource server, e.g. an order ID, returned in the
+	// response and, on settlement, kept with the settlement, its webhook events
+	// and receipt. At most 256 characters
+	Reference string `json:"reference,omitempty"`
+	// Unix time before which the settlement isn't submitted. Requests with a
+	// scheduling window are answered with 202 and settled in the background
+	NotBefore int64 `json:"notBefore,omitempty"`
+	// Unix time by which the settlement is submitted, at the latest when the
+	// authorization expires. The window opens at notBefore or right away
+	NotAfter int64 `json:"notAfter,omitempty"`
 }
 
 // PaymentSettleResponse is the response from the /settle endpoint.
@@ -76,16 +109,149 @@ type PaymentSettleResponse struct {
 	TxHash string `json:"txHash,omitempty"`
 	// Network ID where the transaction was submitted
 	NetworkId string `json:"networkId,omitempty"`
+	// Address of the payer
+	Payer string `json:"payer,omitempty"`
+	// Value of the settled amount in USD, present only if a price oracle is configured
+	AmountUsd *float64 `json:"amountUsd,omitempty"`
+	// Receipt of the settlement signed by the facilitator, present only if receipts are enabled
+	Receipt *SettlementReceipt `json:"receipt,omitempty"`
+	// Shares of a split payment sent to their recipients
+	Splits []SplitTransfer `json:"splits,omitempty"`
+	// Reference of the request, if it had one
+	Reference string `json:"reference,omitempty"`
 }
 
-// SupportedKind represents a supported scheme and network pair
+// ScheduledSettlementResponse is the response of /settle to requests with a
+// scheduling window. The outcome of the settlement is published as settlement
+// events under its ID, to the websocket stream and webhooks.
+type ScheduledSettlementResponse struct {
+	// ID of the settlement
+	SettlementID string `json:"settlementId"`
+	// Status of the settlement, "scheduled"
+	Status string `json:"status"`
+	// Unix time the window opens
+	NotBefore int64 `json:"notBefore"`
+	// Unix time the window closes, capped by the expiry of the authorization
+	NotAfter int64 `json:"notAfter"`
+	// Reference of the request, if it had one
+	Reference string `json:"reference,omitempty"`
+}
+
+// SettlementReceipt is a statement of the facilitator that it settled a
+// payment, signed as EIP-712 typed data so anyone holding it can prove the
+// payment was facilitated.
+type SettlementReceipt struct {
+	// CAIP-2 identifier of the network the payment was settled on
+	Network string `json:"network"`
+	// Address of the payer
+	Payer string `json:"payer"`
+	// Address of the payee
+	Payee string `json:"payee"`
+	// Amount in atomic units of the asset
+	Amount string `json:"amount"`
+	// Address of the paid asset
+	Asset string `json:"asset"`
+	// Hash of the settlement transaction
+	TxHash string `json:"txHash"`
+	// Unix time in seconds the settlement was submitted at
+	Timestamp int64 `json:"timestamp"`
+	// Reference the settle request carried, signed only if present
+	Reference string `json:"reference,omitempty"`
+	// Address of the facilitator key that signed the receipt
+	Signer string `json:"signer"`
+	// EIP-712 signature of the signer over the receipt, hex encoded
+	Signature string `json:"signature"`
+}
+
+// SupportedKind represents a supported protocol version, scheme and network
 // used in the /supported endpoint.
 type SupportedKind struct {
-	Scheme  string `json:"scheme"`
-	Network string `json:"network"`
+	X402Version int    `json:"x402Version"`
+	Scheme      string `json:"scheme"`
+	Network     string `json:"network"`
+	// Extra information clients need to pay with this kind (e.g. the Solana fee
+	// payer), the accepted assets under "assets"
+	Extra map[string]any `json:"extra,omitempty"`
+}
+
+// Transfer methods of supported assets
+const (
+	// The payer signs an EIP-3009 transferWithAuthorization the facilitator submits
+	TransferMethodEIP3009 = "eip3009"
+	// The payer signs an EIP-2612 permit of the facilitator signer, which submits it and transfers the payment
+	TransferMethodEIP2612 = "eip2612"
+	// The payer signs the transfer transaction, which the facilitator broadcasts
+	TransferMethodTransaction = "transaction"
+)
+
+// SupportedAsset is an asset accepted on a network, listed in the extra of its
+// supported kinds so clients don't need to know token addresses.
+type SupportedAsset struct {
+	Address  string `json:"address"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+	// How the payer authorizes the transfer, one of the TransferMethod constants
+	TransferMethod string `json:"transferMethod"`
+	// EIP-712 domain name and version of EIP-3009 and EIP-2612 tokens
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Address EIP-2612 permits must approve, the signer submitting the settlement
+	Spender string `json:"spender,omitempty"`
+}
+
+// Assets returns the assets accepted with the kind, none if the facilitator doesn't list them.
+func (k SupportedKind) Assets() ([]SupportedAsset, error) {
+	raw, ok := k.Extra["assets"]
+	if !ok {
+		return nil, nil
+	}
+	// the extra of a decoded response holds generic JSON values
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var assets []SupportedAsset
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, err
+	}
+	return assets, nil
 }
 
 // SupportedResponse is the response structure returned from the /supported endpoint.
 type SupportedResponse struct {
 	Kinds []SupportedKind `json:"kinds"`
+	// Addresses of the facilitator signers by CAIP-2 family (e.g. "eip155:*")
+	Signers map[string][]string `json:"signers,omitempty"`
+}
+
+// WellKnownResponse is the response structure returned from the /.well-known/x402
+// endpoint, describing the facilitator to clients discovering it.
+type WellKnownResponse struct {
+	// x402 versions the facilitator accepts
+	X402Versions []int           `json:"x402Versions"`
+	Kinds        []SupportedKind `json:"kinds"`
+	// Addresses of the facilitator signers by CAIP-2 network (e.g. "eip155:8453")
+	Signers map[string][]string `json:"signers"`
+}
+
+// PaymentEstimateResponse is the response from the /settle/estimate endpoint.
+type PaymentEstimateResponse struct {
+	// Whether the simulated settlement succeeded
+	Success bool `json:"success"`
+	// Error message of the failed simulation, if any
+	Error string `json:"error,omitempty"`
+	// Estimated gas limit of the settlement transaction
+	GasLimit uint64 `json:"gasLimit,omitempty"`
+	// Gas price in atomic units of the native token
+	GasPrice string `json:"gasPrice,omitempty"`
+	// Estimated gas cost in atomic units of the native token
+	GasCost string `json:"gasCost,omitempty"`
+	// Estimated gas cost in the native token, as a decimal string
+	GasCostNative string `json:"gasCostNative,omitempty"`
+	// Symbol of the native token the gas is paid in
+	NativeCurrency string `json:"nativeCurrency,omitempty"`
+	// Estimated gas cost in USD, present only if a price oracle is configured
+	GasCostUsd *float64 `json:"gasCostUsd,omitempty"`
+	// Address of the payer
+	Payer string `json:"payer,omitempty"`
 }
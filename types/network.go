@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// NetworkPause is the response from the /admin/networks/{network}/pause and
+// /resume endpoints, whether settlements on a network are paused.
+type NetworkPause struct {
+	// CAIP-2 identifier of the network
+	Network string `json:"network"`
+	Paused  bool   `json:"paused"`
+	// When settlements were paused, unset while they proceed
+	PausedAt *time.Time `json:"pausedAt,omitempty"`
+}
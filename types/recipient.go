@@ -0,0 +1,16 @@
+package types
+
+import "time"
+
+// Recipient is an address registered to receive payments on a network.
+type Recipient struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+	// Registration message and the signature of the address over it
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+
+	RegisteredAt time.Time `json:"registeredAt"`
+	// When the registration was revoked, absent while it is valid
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
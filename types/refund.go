@@ -0,0 +1,42 @@
+package types
+
+import "time"
+
+// RefundRequest records a refund of a settlement. The refund is either an
+// EIP-3009 authorization from the payee to the payer, which the facilitator
+// settles, or a transaction already sent, which it only tracks.
+type RefundRequest struct {
+	// ID of the settlement the refund reverses
+	SettlementID string `json:"settlementId"`
+	// Refunded amount in atomic units of the asset
+	Amount string `json:"amount"`
+	// Address of the refunded asset, the asset of the settlement if empty
+	Asset  string `json:"asset,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Authorization of the transfer back to the payer, mutually exclusive with TxHash
+	PaymentPayload *PaymentPayload `json:"paymentPayload,omitempty"`
+	// Hash of a refund transaction sent by the payee, mutually exclusive with PaymentPayload
+	TxHash string `json:"txHash,omitempty"`
+}
+
+// Refund is a refund of a settlement and its progress.
+type Refund struct {
+	ID           string `json:"id"`
+	SettlementID string `json:"settlementId"`
+	Network      string `json:"network"`
+	Asset        string `json:"asset"`
+	// Refunded amount in atomic units of the asset
+	Amount string `json:"amount"`
+	// Sender of the refund, absent for transactions sent by the payee
+	From   string `json:"from,omitempty"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+	// submitted, mined, confirmed or failed
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	TxHash      string `json:"txHash,omitempty"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
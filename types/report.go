@@ -0,0 +1,168 @@
+package types
+
+import "time"
+
+// CostReport is the response from the /admin/costs endpoint. It sums the fees the
+// facilitator paid for settlement transactions mined in a period.
+type CostReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Costs by network and asset
+	Entries []CostReportEntry `json:"entries"`
+	// Sum of the fees that could be priced in USD
+	TotalFeeUsd float64 `json:"totalFeeUsd"`
+	// Sum of the settled payments that could be priced in USD
+	TotalAmountUsd float64 `json:"totalAmountUsd"`
+}
+
+// CostReportEntry sums the costs of the settlements of one asset on one network.
+type CostReportEntry struct {
+	Network string `json:"network"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset string `json:"asset"`
+	// Number of mined settlement transactions, including reverted ones
+	Settlements int `json:"settlements"`
+	// Number of settlement transactions that reverted
+	Reverted int `json:"reverted"`
+	// Total gas used
+	GasUsed uint64 `json:"gasUsed"`
+	// Total fees in atomic units of the fee currency
+	Fee string `json:"fee"`
+	// Total fees in the fee currency, as a decimal string
+	FeeNative string `json:"feeNative"`
+	// Symbol of the token fees are paid in
+	FeeCurrency string `json:"feeCurrency"`
+	// Total fees in USD, priced when each transaction was mined. Only present if every fee could be priced
+	FeeUsd *float64 `json:"feeUsd,omitempty"`
+	// Total value of the successful settlements in USD. Only present if every payment could be priced
+	AmountUsd *float64 `json:"amountUsd,omitempty"`
+}
+
+// Dashboard is the response from the /admin/dashboard/data endpoint, the state
+// the dashboard page displays.
+type Dashboard struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Settlements created in the window, by minute
+	Activity []DashboardBucket `json:"activity"`
+	// Settlements created in the window by network
+	Networks []DashboardNetwork `json:"networks"`
+	// Settlements waiting for a worker
+	QueueDepth int `json:"queueDepth"`
+	// Settlements being submitted by a worker
+	InFlight int `json:"inFlight"`
+	// Gas balances of the signers when they were last checked
+	Signers []DashboardSigner `json:"signers"`
+	// Networks whose settlements are paused
+	Paused []NetworkPause `json:"paused"`
+}
+
+// DashboardBucket counts the settlements created in a period by outcome.
+type DashboardBucket struct {
+	Start time.Time `json:"start"`
+	// Settlements submitted, mined or confirmed
+	Settled int `json:"settled"`
+	Failed  int `json:"failed"`
+	// Settlements not submitted yet
+	Pending int `json:"pending"`
+}
+
+// DashboardNetwork counts the settlements of a network by outcome.
+type DashboardNetwork struct {
+	Network string `json:"network"`
+	Settled int    `json:"settled"`
+	Failed  int    `json:"failed"`
+	Pending int    `json:"pending"`
+	// Share of the finished settlements that failed, 0 if none finished
+	ErrorRate float64 `json:"errorRate"`
+}
+
+// DashboardSigner is the gas balance of a signer.
+type DashboardSigner struct {
+	Network  string  `json:"network"`
+	Signer   string  `json:"signer"`
+	Balance  float64 `json:"balance"`
+	Currency string  `json:"currency"`
+}
+
+// Kinds of reconciliation findings
+const (
+	// FindingOrphanedTransfer is a transfer of a signer on chain without a settlement on record
+	FindingOrphanedTransfer = "orphaned_transfer"
+	// FindingMissingReceipt is a settlement on record whose transaction wasn't mined
+	FindingMissingReceipt = "missing_receipt"
+)
+
+// Reconciliation is the response from the /admin/reconciliation endpoint, the
+// discrepancies the indexer found between the chain and the settlement store.
+type Reconciliation struct {
+	// Progress of the indexer by network
+	Networks []IndexedNetwork `json:"networks"`
+	// Most recent findings, newest first
+	Findings []ReconciliationFinding `json:"findings"`
+}
+
+// IndexedNetwork is the progress of the indexer on a network.
+type IndexedNetwork struct {
+	Network string `json:"network"`
+	// Last block whose transfers were reconciled, 0 before the first one
+	Block uint64 `json:"block"`
+	// Error of the last indexing pass, empty if it succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// ReconciliationFinding is a discrepancy between the chain and the settlement store.
+type ReconciliationFinding struct {
+	Network string `json:"network"`
+	// FindingOrphanedTransfer or FindingMissingReceipt
+	Kind   string `json:"kind"`
+	TxHash string `json:"txHash"`
+	// Block of the transfer, 0 for missing receipts
+	Block uint64 `json:"block,omitempty"`
+	// Settlement the finding concerns, empty for transfers without one
+	SettlementID string    `json:"settlementId,omitempty"`
+	Detail       string    `json:"detail"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// SettlementStats is the response from the /admin/stats endpoint. It
+// aggregates the settlements created in a period by time bucket.
+type SettlementStats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Length of the buckets: minute, hour or day
+	Granularity string `json:"granularity"`
+	// Buckets of the period in order, including empty ones
+	Buckets []SettlementStatsBucket `json:"buckets"`
+}
+
+// SettlementStatsBucket aggregates the settlements created in a bucket.
+type SettlementStatsBucket struct {
+	Start time.Time `json:"start"`
+	// Settlements created
+	Settlements int `json:"settlements"`
+	// Settlements submitted, mined or confirmed
+	Settled int `json:"settled"`
+	Failed  int `json:"failed"`
+	// Settlements not submitted yet
+	Pending int `json:"pending"`
+	// Share of the settled and failed settlements that settled, absent if none finished
+	SuccessRate *float64 `json:"successRate,omitempty"`
+	// Mean time from the settle request to the confirmation of confirmed settlements in milliseconds, absent if none was confirmed
+	AvgLatencyMs *float64 `json:"avgLatencyMs,omitempty"`
+	// Settled amounts by network and asset
+	Volumes []SettlementVolume `json:"volumes"`
+}
+
+// SettlementVolume sums the settled payments of one asset on one network.
+type SettlementVolume struct {
+	Network string `json:"network"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset       string `json:"asset"`
+	Settlements int    `json:"settlements"`
+	// Total amount in atomic units of the asset
+	Amount string `json:"amount"`
+	// Total amount in whole units, absent if the decimals of the asset are unknown
+	AmountDecimal string `json:"amountDecimal,omitempty"`
+	// Total value in USD at submission. Only present if every payment could be priced
+	AmountUsd *float64 `json:"amountUsd,omitempty"`
+}
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxTimeoutSeconds is the time a resource server has to respond if the requirements don't say otherwise
+const DefaultMaxTimeoutSeconds = 60
+
+// Asset describes an asset payments can be made in on a network.
+type Asset struct {
+	// Scheme payments in the asset use
+	Scheme Scheme
+	// Address of the asset contract
+	Address  string
+	Decimals int
+	// Scheme specific details carried in the extra field of the requirements,
+	// e.g. the EIP-712 domain name and version of EVM tokens
+	Extra map[string]any
+}
+
+// AssetResolver looks up the asset with the symbol or address on the network,
+// ok is false if the resolver doesn't know it.
+type AssetResolver func(network, asset string) (Asset, bool)
+
+var (
+	resolversMu    sync.RWMutex
+	assetResolvers []AssetResolver
+)
+
+// RegisterAssetResolver adds a resolver BuildPaymentRequirements looks assets
+// up with. Scheme packages register the assets they know when imported.
+func RegisterAssetResolver(resolver AssetResolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	assetResolvers = append(assetResolvers, resolver)
+}
+
+// ResolveAsset looks the asset up with the registered resolvers.
+func ResolveAsset(network, asset string) (Asset, bool) {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	for _, resolve := range assetResolvers {
+		if resolved, ok := resolve(network, asset); ok {
+			return resolved, true
+		}
+	}
+	return Asset{}, false
+}
+
+// RequirementsOption sets an optional field of payment requirements.
+type RequirementsOption func(*PaymentRequirements)
+
+// WithResource describes the resource paid for.
+func WithResource(url, description, mimeType string) RequirementsOption {
+	return func(req *PaymentRequirements) {
+		req.Resource = url
+		req.Description = description
+		req.MimeType = mimeType
+	}
+}
+
+// WithMaxTimeout sets the time in seconds the resource server has to respond.
+func WithMaxTimeout(seconds int) RequirementsOption {
+	return func(req *PaymentRequirements) {
+		req.MaxTimeoutSeconds = seconds
+	}
+}
+
+// WithOutputSchema sets the schema of the resource response.
+func WithOutputSchema(schema json.RawMessage) RequirementsOption {
+	return func(req *PaymentRequirements) {
+		req.OutputSchema = &schema
+	}
+}
+
+// BuildPaymentRequirements creates the requirements of a payment of amount,
+// in whole units of the asset such as "0.01", to payTo. The asset is a symbol
+// or address looked up with the registered resolvers, which provide its
+// scheme, address, decimals and extra details.
+func BuildPaymentRequirements(network, asset, amount, payTo string, opts ...RequirementsOption) (*PaymentRequirements, error) {
+	resolved, ok := ResolveAsset(network, asset)
+	if !ok {
+		return nil, fmt.Errorf("unknown asset %s on network %s", asset, network)
+	}
+	if payTo == "" {
+		return nil, fmt.Errorf("payTo is required")
+	}
+	atomic, err := ParseUnits(amount, resolved.Decimals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	if atomic.Sign() <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	req := &PaymentRequirements{
+		Scheme:            string(resolved.Scheme),
+		Network:           network,
+		MaxAmountRequired: atomic.String(),
+		PayTo:             payTo,
+		MaxTimeoutSeconds: DefaultMaxTimeoutSeconds,
+		Asset:             resolved.Address,
+	}
+	if len(resolved.Extra) > 0 {
+		extra, err := json.Marshal(resolved.Extra)
+		if err != nil {
+			return nil, err
+		}
+		req.Extra = (*json.RawMessage)(&extra)
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	return req, nil
+}
+
+// RegisteredRequirements are payment requirements registered with the
+// facilitator and the ID payments can name them by.
+type RegisteredRequirements struct {
+	// ID to send as requirementsId of verify and settle requests
+	ID                  string              `json:"id"`
+	PaymentRequirements PaymentRequirements `json:"paymentRequirements"`
+}
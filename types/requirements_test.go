@@ -0,0 +1,47 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/gosuda/x402-facilitator/scheme/evm"
+	"github.com/gosuda/x402-facilitator/types"
+)
+
+func TestBuildPaymentRequirements(t *testing.T) {
+	const payTo = "0x209693Bc6afc0C5328bA36FaF03C514EF312287C"
+
+	req, err := types.BuildPaymentRequirements("eip155:8453", "USDC", "0.01", payTo,
+		types.WithResource("https://example.com/weather", "Weather report", "application/json"))
+	require.NoError(t, err)
+	require.Equal(t, "evm", req.Scheme)
+	require.Equal(t, "eip155:8453", req.Network)
+	require.Equal(t, "10000", req.MaxAmountRequired)
+	require.Equal(t, "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", req.Asset)
+	require.Equal(t, payTo, req.PayTo)
+	require.Equal(t, types.DefaultMaxTimeoutSeconds, req.MaxTimeoutSeconds)
+	require.Equal(t, "https://example.com/weather", req.Resource)
+	require.JSONEq(t, `{"name":"USD Coin","version":"2"}`, string(*req.Extra))
+
+	// by chain name and address
+	req, err = types.BuildPaymentRequirements("base-sepolia", "0x036cbd53842c5426634e7929541ec2318f3dcf7e", "2", payTo, types.WithMaxTimeout(300))
+	require.NoError(t, err)
+	require.Equal(t, "2000000", req.MaxAmountRequired)
+	require.Equal(t, 300, req.MaxTimeoutSeconds)
+	require.JSONEq(t, `{"name":"USDC","version":"2"}`, string(*req.Extra))
+	_, err = json.Marshal(req)
+	require.NoError(t, err)
+
+	for _, tt := range []struct{ network, asset, amount, payTo string }{
+		{"eip155:8453", "DAI", "1", payTo},
+		{"eip155:999999", "USDC", "1", payTo},
+		{"eip155:8453", "USDC", "0.0000001", payTo},
+		{"eip155:8453", "USDC", "0", payTo},
+		{"eip155:8453", "USDC", "1", ""},
+	} {
+		_, err := types.BuildPaymentRequirements(tt.network, tt.asset, tt.amount, tt.payTo)
+		require.Error(t, err, "%+v", tt)
+	}
+}
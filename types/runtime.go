@@ -0,0 +1,52 @@
+package types
+
+import "time"
+
+// RuntimeStats is the response from the /admin/runtime endpoint, a snapshot of
+// the Go runtime and the connections of the facilitator.
+type RuntimeStats struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Seconds since the process started
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+	GoVersion     string  `json:"goVersion"`
+	Goroutines    int     `json:"goroutines"`
+	// Value of GOMAXPROCS
+	MaxProcs int         `json:"maxProcs"`
+	Heap     RuntimeHeap `json:"heap"`
+	GC       RuntimeGC   `json:"gc"`
+	// Open connections to the RPC endpoints by network
+	RPCConnections map[string]int `json:"rpcConnections"`
+}
+
+// RuntimeHeap reports the heap memory in bytes.
+type RuntimeHeap struct {
+	// Bytes of allocated heap objects
+	Alloc uint64 `json:"alloc"`
+	// Bytes in spans holding at least one object
+	InUse uint64 `json:"inUse"`
+	// Bytes in spans without objects, including those returned to the OS
+	Idle uint64 `json:"idle"`
+	// Bytes returned to the OS
+	Released uint64 `json:"released"`
+	// Bytes of heap memory obtained from the OS
+	Sys uint64 `json:"sys"`
+	// Number of allocated heap objects
+	Objects uint64 `json:"objects"`
+	// Total bytes of memory obtained from the OS, not only for the heap
+	TotalSys uint64 `json:"totalSys"`
+}
+
+// RuntimeGC reports the garbage collections.
+type RuntimeGC struct {
+	// Completed collections
+	Count uint32 `json:"count"`
+	// Heap size the next collection starts at, in bytes
+	NextHeap uint64     `json:"nextHeap"`
+	LastAt   *time.Time `json:"lastAt,omitempty"`
+	// Total stop-the-world pause time in milliseconds
+	PauseTotalMs float64 `json:"pauseTotalMs"`
+	// Pauses of the most recent collections in milliseconds, newest first
+	RecentPausesMs []float64 `json:"recentPausesMs"`
+	// Share of the CPU time used by the collector since the process started
+	CPUFraction float64 `json:"cpuFraction"`
+}
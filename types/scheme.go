@@ -13,6 +13,10 @@ type X402Version int
 
 const (
 	X402VersionV1 X402Version = 1
+	X402VersionV2 X402Version = 2
 )
 
+// SupportedX402Versions lists the protocol versions the facilitator accepts
+var SupportedX402Versions = []X402Version{X402VersionV1, X402VersionV2}
+
 type Signer func(digest []byte) (signature []byte, err error)
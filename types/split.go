@@ -0,0 +1,102 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// SplitTotalBps is the sum of the shares of a split, in basis points
+const SplitTotalBps = 10_000
+
+// Methods facilitators settle split payments with
+const (
+	// A splitter contract collects the payment and pays the recipients in the
+	// settlement transaction, so the split is atomic
+	SplitMethodContract = "contract"
+	// The facilitator signer collects the payment and transfers the shares one
+	// after the other, returning what it couldn't forward to the payer
+	SplitMethodSequential = "sequential"
+)
+
+// Split divides a payment between recipients. It is carried as "split" in the
+// extra of the requirements, whose payTo is then the collector the network
+// lists in the split support of its supported kinds.
+type Split struct {
+	Recipients []SplitRecipient `json:"recipients"`
+}
+
+// SplitRecipient is a recipient of a split and its share.
+type SplitRecipient struct {
+	Address string `json:"address"`
+	// Share of the payment in basis points, the shares of a split add up to SplitTotalBps
+	Bps int `json:"bps"`
+}
+
+// SplitOf returns the split in the extra of the requirements, nil if they
+// don't divide the payment.
+func SplitOf(req *PaymentRequirements) (*Split, error) {
+	if req.Extra == nil {
+		return nil, nil
+	}
+	// extras of other shapes don't carry a split
+	var extra map[string]json.RawMessage
+	if json.Unmarshal(*req.Extra, &extra) != nil || extra["split"] == nil {
+		return nil, nil
+	}
+	var split Split
+	if err := json.Unmarshal(extra["split"], &split); err != nil {
+		return nil, fmt.Errorf("invalid split: %w", err)
+	}
+	return &split, nil
+}
+
+// Amounts divides value between the recipients by their shares. Amounts are
+// rounded down, the remainder goes to the first recipient.
+func (s *Split) Amounts(value *big.Int) []*big.Int {
+	amounts := make([]*big.Int, len(s.Recipients))
+	rest := new(big.Int).Set(value)
+	for i, recipient := range s.Recipients {
+		amounts[i] = new(big.Int).Mul(value, big.NewInt(int64(recipient.Bps)))
+		amounts[i].Quo(amounts[i], big.NewInt(SplitTotalBps))
+		rest.Sub(rest, amounts[i])
+	}
+	if len(amounts) > 0 {
+		amounts[0].Add(amounts[0], rest)
+	}
+	return amounts
+}
+
+// WithSplit divides the payment between the recipients. payTo of the
+// requirements must be the collector of split payments on the network.
+func WithSplit(recipients ...SplitRecipient) RequirementsOption {
+	return func(req *PaymentRequirements) {
+		extra := map[string]any{}
+		if req.Extra != nil {
+			_ = json.Unmarshal(*req.Extra, &extra)
+		}
+		extra["split"] = Split{Recipients: recipients}
+		raw, _ := json.Marshal(extra)
+		req.Extra = (*json.RawMessage)(&raw)
+	}
+}
+
+// SplitSupport describes how a network settles split payments, listed as
+// "split" in the extra of its supported kinds.
+type SplitSupport struct {
+	// SplitMethodContract or SplitMethodSequential
+	Method string `json:"method"`
+	// Address the authorization of a split payment pays, the payTo of its requirements
+	Collector string `json:"collector"`
+	// Most recipients of a split
+	MaxRecipients int `json:"maxRecipients"`
+}
+
+// SplitTransfer is a share of a split payment sent to its recipient.
+type SplitTransfer struct {
+	Recipient string `json:"recipient"`
+	// Amount in atomic units of the asset
+	Amount string `json:"amount"`
+	// Transaction of the transfer, the settlement transaction with splitter contracts
+	TxHash string `json:"txHash"`
+}
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// FormatUnits converts an amount in atomic units into a decimal string,
+// e.g. FormatUnits(1500000, 6) returns "1.5".
+func FormatUnits(amount *big.Int, decimals int) string {
+	if amount == nil {
+		return "0"
+	}
+	if decimals <= 0 {
+		return amount.String()
+	}
+
+	neg := amount.Sign() < 0
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+
+	s := whole
+	if frac != "" {
+		s += "." + frac
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// ParseUnits converts a decimal string into atomic units, e.g.
+// ParseUnits("1.5", 6) returns 1500000. It fails if the value has more
+// fractional digits than decimals.
+func ParseUnits(value string, decimals int) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(value), ".")
+	neg := strings.HasPrefix(whole, "-")
+	whole = strings.TrimPrefix(whole, "-")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("empty amount")
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("more than %d decimals", decimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", decimals-len(frac))
+	if strings.ContainsFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) {
+		return nil, fmt.Errorf("not a decimal number")
+	}
+	amount, _ := new(big.Int).SetString(digits, 10)
+	if neg {
+		amount.Neg(amount)
+	}
+	return amount, nil
+}
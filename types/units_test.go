@@ -0,0 +1,52 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatUnits(t *testing.T) {
+	cases := []struct {
+		amount   int64
+		decimals int
+		expected string
+	}{
+		{1500000, 6, "1.5"},
+		{1, 6, "0.000001"},
+		{0, 6, "0"},
+		{1000000, 6, "1"},
+		{-250, 2, "-2.5"},
+		{42, 0, "42"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expected, FormatUnits(big.NewInt(c.amount), c.decimals))
+	}
+	require.Equal(t, "0", FormatUnits(nil, 18))
+}
+
+func TestParseUnits(t *testing.T) {
+	cases := []struct {
+		value    string
+		decimals int
+		expected int64
+	}{
+		{"1.5", 6, 1500000},
+		{"0.000001", 6, 1},
+		{"1", 6, 1000000},
+		{".5", 2, 50},
+		{"-2.5", 2, -250},
+		{"42", 0, 42},
+		{"1.10", 1, 11},
+	}
+	for _, c := range cases {
+		amount, err := ParseUnits(c.value, c.decimals)
+		require.NoError(t, err, c.value)
+		require.Equal(t, big.NewInt(c.expected), amount, c.value)
+	}
+	for _, value := range []string{"", "0.0000001", "1e6", "abc", "1.2.3"} {
+		_, err := ParseUnits(value, 6)
+		require.Error(t, err, value)
+	}
+}
@@ -0,0 +1,160 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/gosuda/x402-facilitator/internal/sdk"
+)
+
+// PaymentVerifyRequestV2 is the request body of /verify in version 2 of the protocol.
+type PaymentVerifyRequestV2 struct {
+	X402Version    int                `json:"x402Version"`
+	PaymentPayload sdk.PaymentPayload `json:"paymentPayload"`
+	// Requirements of the payment, required unless requirementsId is set
+	PaymentRequirements sdk.PaymentRequirements `json:"paymentRequirements,omitzero"`
+	// ID of requirements registered with POST /requirements, instead of paymentRequirements
+	RequirementsID string `json:"requirementsId,omitempty"`
+	// Opaque reference of the resource server, e.g. an order ID, returned in the
+	// response and, on settlement, kept with the settlement, its webhook events
+	// and receipt. At most 256 characters
+	Reference string `json:"reference,omitempty"`
+}
+
+// PaymentSettleRequestV2 is the request body of /settle in version 2 of the protocol.
+type PaymentSettleRequestV2 struct {
+	X402Version    int                `json:"x402Version"`
+	PaymentPayload sdk.PaymentPayload `json:"paymentPayload"`
+	// Requirements of the payment, required unless requirementsId is set
+	PaymentRequirements sdk.PaymentRequirements `json:"paymentRequirements,omitzero"`
+	// ID of requirements registered with POST /requirements, instead of paymentRequirements
+	RequirementsID string `json:"requirementsId,omitempty"`
+	// Deadline of the settlement in milliseconds, capped by the server maximum. The server default applies if 0
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+	// Priority of the settlement when the queue is backed up, capped by the priority of the tenant. The tenant's applies if 0
+	Priority int `json:"priority,omitempty"`
+	// Settlements of the same tenant and payee with this key are submitted one
+	// after the other in the order they arrive, at most 128 characters
+	OrderingKey string `json:"orderingKey,omitempty"`
+	// Opaque reference of the resource server, e.g. an order ID, returned in the
+	// response and, on settlement, kept with the settlement, its webhook events
+	// and receipt. At most 256 characters
+	Reference string `json:"reference,omitempty"`
+	// Unix time before which the settlement isn't submitted. Requests with a
+	// scheduling window are answered with 202 and settled in the background
+	NotBefore int64 `json:"notBefore,omitempty"`
+	// Unix time by which the settlement is submitted, at the latest when the
+	// authorization expires. The window opens at notBefore or right away
+	NotAfter int64 `json:"notAfter,omitempty"`
+}
+
+// Validate checks the fields the facilitator relies on.
+func (r *PaymentVerifyRequestV2) Validate() []FieldError {
+	errs := validatePaymentV2(r.X402Version, &r.PaymentPayload, &r.PaymentRequirements, r.RequirementsID)
+	return append(errs, validateReference(r.Reference)...)
+}
+
+// Validate checks the fields the facilitator relies on.
+func (r *PaymentSettleRequestV2) Validate() []FieldError {
+	errs := validatePaymentV2(r.X402Version, &r.PaymentPayload, &r.PaymentRequirements, r.RequirementsID)
+	if r.TimeoutMs < 0 {
+		errs = append(errs, FieldError{Field: "timeoutMs", Message: "must not be negative"})
+	}
+	if r.Priority < 0 {
+		errs = append(errs, FieldError{Field: "priority", Message: "must not be negative"})
+	}
+	if len(r.OrderingKey) > MaxOrderingKeyLength {
+		errs = append(errs, FieldError{Field: "orderingKey", Message: fmt.Sprintf("must not be longer than %d characters", MaxOrderingKeyLength)})
+	}
+	errs = append(errs, validateWindow(r.NotBefore, r.NotAfter)...)
+	return append(errs, validateReference(r.Reference)...)
+}
+
+// Payment returns the payment in the representation the facilitators process.
+func (r *PaymentVerifyRequestV2) Payment() (*PaymentPayload, *PaymentRequirements) {
+	return paymentFromV2(&r.PaymentPayload, &r.PaymentRequirements)
+}
+
+// Payment returns the payment in the representation the facilitators process.
+func (r *PaymentSettleRequestV2) Payment() (*PaymentPayload, *PaymentRequirements) {
+	return paymentFromV2(&r.PaymentPayload, &r.PaymentRequirements)
+}
+
+// paymentFromV2 maps the payment onto the version 1 structures: the scheme and
+// network the payload was accepted with, and the amount as maximum amount.
+func paymentFromV2(payload *sdk.PaymentPayload, req *sdk.PaymentRequirements) (*PaymentPayload, *PaymentRequirements) {
+	// a decoded JSON object always encodes again
+	raw, _ := json.Marshal(payload.Payload)
+	v1Payload := &PaymentPayload{
+		X402Version: payload.X402Version,
+		Scheme:      payload.Accepted.Scheme,
+		Network:     payload.Accepted.Network,
+		Payload:     raw,
+	}
+
+	v1Req := &PaymentRequirements{
+		Scheme:            req.Scheme,
+		Network:           req.Network,
+		MaxAmountRequired: req.Amount,
+		PayTo:             req.PayTo,
+		MaxTimeoutSeconds: req.MaxTimeoutSeconds,
+		Asset:             req.Asset,
+	}
+	if payload.Resource != nil {
+		v1Req.Resource = payload.Resource.URL
+		v1Req.Description = payload.Resource.Description
+		v1Req.MimeType = payload.Resource.MimeType
+	}
+	if req.Extra != nil {
+		extra, _ := json.Marshal(req.Extra)
+		v1Req.Extra = (*json.RawMessage)(&extra)
+	}
+	return v1Payload, v1Req
+}
+
+func validatePaymentV2(version int, payload *sdk.PaymentPayload, req *sdk.PaymentRequirements, requirementsID string) []FieldError {
+	var errs []FieldError
+	add := func(field, message string) {
+		errs = append(errs, FieldError{Field: field, Message: message})
+	}
+	required := func(field, value string) {
+		if value == "" {
+			add(field, "is required")
+		}
+	}
+
+	if version != int(X402VersionV2) {
+		add("x402Version", "must be 2")
+	}
+
+	if payload.X402Version != int(X402VersionV2) {
+		add("paymentPayload.x402Version", "must be 2")
+	}
+	required("paymentPayload.accepted.scheme", payload.Accepted.Scheme)
+	required("paymentPayload.accepted.network", payload.Accepted.Network)
+	if payload.Payload == nil {
+		add("paymentPayload.payload", "is required")
+	}
+	if requirementsID != "" {
+		if !reflect.ValueOf(*req).IsZero() {
+			add("paymentRequirements", "must not be set with requirementsId")
+		}
+		return errs
+	}
+
+	required("paymentRequirements.scheme", req.Scheme)
+	required("paymentRequirements.network", req.Network)
+	required("paymentRequirements.payTo", req.PayTo)
+	required("paymentRequirements.asset", req.Asset)
+	if req.Amount == "" {
+		add("paymentRequirements.amount", "is required")
+	} else if amount, ok := new(big.Int).SetString(req.Amount, 10); !ok || amount.Sign() < 0 {
+		add("paymentRequirements.amount", "must be a non-negative integer in atomic units")
+	}
+	if req.MaxTimeoutSeconds < 0 {
+		add("paymentRequirements.maxTimeoutSeconds", "must not be negative")
+	}
+	return errs
+}
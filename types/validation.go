@@ -0,0 +1,140 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// MaxOrderingKeyLength bounds the ordering keys of settle requests
+const MaxOrderingKeyLength = 128
+
+// MaxReferenceLength bounds the references of verify and settle requests
+const MaxReferenceLength = 256
+
+// FieldError describes an invalid field of a request body.
+type FieldError struct {
+	// JSON path of the field (e.g. "paymentRequirements.payTo")
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is returned with status 422 for request bodies that fail validation.
+type ValidationErrorResponse struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+// Validate checks the fields the facilitator relies on.
+func (r *PaymentVerifyRequest) Validate() []FieldError {
+	errs := validatePayment(r.X402Version, &r.PaymentHeader, &r.PaymentRequirements, r.RequirementsID)
+	return append(errs, validateReference(r.Reference)...)
+}
+
+// Validate checks the fields the facilitator relies on.
+func (r *PaymentSettleRequest) Validate() []FieldError {
+	errs := validatePayment(r.X402Version, &r.PaymentHeader, &r.PaymentRequirements, r.RequirementsID)
+	if r.TimeoutMs < 0 {
+		errs = append(errs, FieldError{Field: "timeoutMs", Message: "must not be negative"})
+	}
+	if r.Priority < 0 {
+		errs = append(errs, FieldError{Field: "priority", Message: "must not be negative"})
+	}
+	if len(r.OrderingKey) > MaxOrderingKeyLength {
+		errs = append(errs, FieldError{Field: "orderingKey", Message: fmt.Sprintf("must not be longer than %d characters", MaxOrderingKeyLength)})
+	}
+	errs = append(errs, validateWindow(r.NotBefore, r.NotAfter)...)
+	return append(errs, validateReference(r.Reference)...)
+}
+
+// validateWindow checks the scheduling window of a settle request.
+func validateWindow(notBefore, notAfter int64) []FieldError {
+	var errs []FieldError
+	if notBefore < 0 {
+		errs = append(errs, FieldError{Field: "notBefore", Message: "must not be negative"})
+	}
+	if notAfter < 0 {
+		errs = append(errs, FieldError{Field: "notAfter", Message: "must not be negative"})
+	} else if notAfter > 0 && notAfter < notBefore {
+		errs = append(errs, FieldError{Field: "notAfter", Message: "must not be before notBefore"})
+	}
+	return errs
+}
+
+// validateReference checks the reference of a verify or settle request.
+func validateReference(reference string) []FieldError {
+	if len(reference) > MaxReferenceLength {
+		return []FieldError{{Field: "reference", Message: fmt.Sprintf("must not be longer than %d characters", MaxReferenceLength)}}
+	}
+	return nil
+}
+
+// Validate checks the fields the facilitator relies on.
+func (r *PaymentRequirements) Validate() []FieldError {
+	return validateRequirements("", r)
+}
+
+// validatePayment checks the payment, whose requirements are either given or
+// registered under requirementsID.
+func validatePayment(version int, payload *PaymentPayload, req *PaymentRequirements, requirementsID string) []FieldError {
+	var errs []FieldError
+	add := func(field, message string) {
+		errs = append(errs, FieldError{Field: field, Message: message})
+	}
+	required := func(field, value string) {
+		if value == "" {
+			add(field, "is required")
+		}
+	}
+
+	if version <= 0 {
+		add("x402Version", "must be a positive protocol version")
+	}
+
+	if payload.X402Version <= 0 {
+		add("paymentHeader.x402Version", "must be a positive protocol version")
+	}
+	required("paymentHeader.scheme", payload.Scheme)
+	required("paymentHeader.network", payload.Network)
+	if trimmed := bytes.TrimSpace(payload.Payload); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		add("paymentHeader.payload", "is required")
+	} else if trimmed[0] != '{' {
+		add("paymentHeader.payload", "must be an object")
+	}
+
+	switch {
+	case requirementsID == "":
+		errs = append(errs, validateRequirements("paymentRequirements.", req)...)
+	case *req != PaymentRequirements{}:
+		add("paymentRequirements", "must not be set with requirementsId")
+	}
+	return errs
+}
+
+// validateRequirements checks the requirements, whose fields are reported
+// under the prefix.
+func validateRequirements(prefix string, req *PaymentRequirements) []FieldError {
+	var errs []FieldError
+	add := func(field, message string) {
+		errs = append(errs, FieldError{Field: prefix + field, Message: message})
+	}
+	required := func(field, value string) {
+		if value == "" {
+			add(field, "is required")
+		}
+	}
+
+	required("scheme", req.Scheme)
+	required("network", req.Network)
+	required("payTo", req.PayTo)
+	required("asset", req.Asset)
+	if req.MaxAmountRequired == "" {
+		add("maxAmountRequired", "is required")
+	} else if amount, ok := new(big.Int).SetString(req.MaxAmountRequired, 10); !ok || amount.Sign() < 0 {
+		add("maxAmountRequired", "must be a non-negative integer in atomic units")
+	}
+	if req.MaxTimeoutSeconds < 0 {
+		add("maxTimeoutSeconds", "must not be negative")
+	}
+	return errs
+}
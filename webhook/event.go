@@ -0,0 +1,74 @@
+package webhook
+
+import "time"
+
+// Status is the lifecycle state of a settlement.
+type Status string
+
+const (
+	// StatusQueued means the settlement request was accepted but nothing was broadcast yet
+	StatusQueued Status = "queued"
+	// StatusSubmitted means the settlement transaction was broadcast to the network
+	StatusSubmitted Status = "submitted"
+	// StatusMined means the settlement transaction was included in a block
+	StatusMined Status = "mined"
+	// StatusConfirmed means the settlement transaction reached the required confirmation depth
+	StatusConfirmed Status = "confirmed"
+	// StatusFailed means the settlement could not be completed
+	StatusFailed Status = "failed"
+	// StatusExpired means the authorization expired before the settlement could be broadcast
+	StatusExpired Status = "expired"
+)
+
+// IsFinal reports whether no further transitions follow this status.
+func (s Status) IsFinal() bool {
+	return s == StatusConfirmed || s.IsFailure()
+}
+
+// IsFailure reports whether the settlement ended without settling the payment.
+func (s Status) IsFailure() bool {
+	return s == StatusFailed || s == StatusExpired
+}
+
+// Event is a settlement state transition delivered to a webhook.
+type Event struct {
+	// Unique ID of the settlement
+	ID string `json:"id"`
+	// ID of the refund of the settlement the event is about, empty for events of the settlement itself
+	RefundID string `json:"refundId,omitempty"`
+	// New status of the settlement, or of the refund
+	Status Status `json:"status"`
+	// Scheme used for the settlement
+	Scheme string `json:"scheme"`
+	// Network the settlement is executed on
+	Network string `json:"network"`
+	// Address of the payer, if known
+	Payer string `json:"payer,omitempty"`
+	// Tenant whose API key requested the settlement
+	Tenant string `json:"tenant,omitempty"`
+	// ID of the API request of the settlement, the X-Request-ID header it was sent with
+	RequestID string `json:"requestId,omitempty"`
+	// Reference the resource server attached to the settle request, e.g. an order ID
+	Reference string `json:"reference,omitempty"`
+	// Transaction hash, once submitted
+	TxHash string `json:"txHash,omitempty"`
+	// Recipient of the payment
+	PayTo string `json:"payTo,omitempty"`
+	// Symbol of the paid asset, or its address if the symbol is unknown
+	Asset string `json:"asset,omitempty"`
+	// Amount paid in atomic units of the asset
+	Amount string `json:"amount,omitempty"`
+	// USD value of the payment at submission, present only if the facilitator prices payments
+	AmountUSD *float64 `json:"amountUsd,omitempty"`
+	// Block number the transaction was included in, once mined
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	// Error message, if the settlement failed
+	Error string `json:"error,omitempty"`
+	// Time of the transition
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IsRefund reports whether the event is about a refund of the settlement.
+func (e *Event) IsRefund() bool {
+	return e.RefundID != ""
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxBodySize bounds the deliveries read by the handler, events are far smaller
+const maxBodySize = 64 << 10
+
+// HandlerFunc processes a verified event. Deliveries it fails are answered
+// with 500, which the facilitator logs as failed.
+type HandlerFunc func(ctx context.Context, evt *Event) error
+
+// NewHandler returns an HTTP handler that checks the signature of deliveries
+// with the secret, decodes their event and passes it to handle. Deliveries
+// with a missing or invalid signature are answered with 401 without calling
+// handle, and handled ones with 204.
+func NewHandler(secret []byte, handle HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if err := VerifySignature(secret, body, r.Header.Get(HeaderSignature)); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var evt Event
+		if err := json.Unmarshal(body, &evt); err != nil {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+		if err := handle(r.Context(), &evt); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
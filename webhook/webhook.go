@@ -0,0 +1,110 @@
+// Package webhook helps resource servers consume the settlement events the
+// facilitator posts to the webhooks of their tenant.
+//
+// Webhooks configured with a secret are signed. The signature header reads
+//
+//	X-Webhook-Signature: t=<timestamp>,v1=<signature>
+//
+// where the timestamp is in unix seconds and the signature is the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret. Receivers check it
+// with VerifySignature, or let NewHandler do so before decoding the event:
+//
+//	http.Handle("/x402", webhook.NewHandler(secret, func(ctx context.Context, evt *webhook.Event) error {
+//		if evt.Status == webhook.StatusConfirmed {
+//			return fulfill(ctx, evt.RequestID)
+//		}
+//		return nil
+//	}))
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderSignature carries the signature of a delivery
+	HeaderSignature = "X-Webhook-Signature"
+	// HeaderRequestID carries the ID of the API request that caused the event, if any
+	HeaderRequestID = "X-Request-ID"
+
+	// DefaultTolerance is how old a signature may be, bounding replays of captured deliveries
+	DefaultTolerance = 5 * time.Minute
+
+	signatureVersion = "v1"
+)
+
+var (
+	// ErrNoSignature is returned for deliveries without a signature header
+	ErrNoSignature = errors.New("webhook: no signature")
+	// ErrInvalidSignature is returned for malformed signatures and those not made with the secret
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrExpiredSignature is returned for signatures made outside the tolerance
+	ErrExpiredSignature = errors.New("webhook: signature timestamp outside the tolerance")
+)
+
+// Sign returns the signature header value of the body signed at t.
+func Sign(secret, body []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + "," + signatureVersion + "=" + signature(secret, timestamp, body)
+}
+
+// VerifySignature checks that the signature header of a delivery was made
+// over the body with the secret within DefaultTolerance of now.
+func VerifySignature(secret, body []byte, header string) error {
+	return verify(secret, body, header, time.Now(), DefaultTolerance)
+}
+
+func verify(secret, body []byte, header string, now time.Time, tolerance time.Duration) error {
+	if header == "" {
+		return ErrNoSignature
+	}
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case signatureVersion:
+			// several signatures are sent while the secret is rotated
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected := []byte(signature(secret, timestamp, body))
+	valid := false
+	for _, sig := range signatures {
+		if hmac.Equal(expected, []byte(sig)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)).Abs(); skew > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+// signature computes the hex encoded HMAC-SHA256 of the signed payload.
+func signature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"id":"a","status":"confirmed"}`)
+	now := time.Unix(1_700_000_000, 0)
+	header := Sign(secret, body, now)
+	require.True(t, strings.HasPrefix(header, "t=1700000000,v1="))
+
+	require.NoError(t, verify(secret, body, header, now.Add(time.Minute), DefaultTolerance))
+	// a second signature is sent while the secret is rotated
+	rotated := Sign([]byte("old"), body, now) + "," + strings.Split(header, ",")[1]
+	require.NoError(t, verify(secret, body, rotated, now, DefaultTolerance))
+
+	for name, tc := range map[string]struct {
+		secret, body []byte
+		header       string
+		now          time.Time
+		err          error
+	}{
+		"missing":       {secret, body, "", now, ErrNoSignature},
+		"other secret":  {[]byte("other"), body, header, now, ErrInvalidSignature},
+		"other body":    {secret, []byte(`{"id":"b"}`), header, now, ErrInvalidSignature},
+		"no timestamp":  {secret, body, strings.Split(header, ",")[1], now, ErrInvalidSignature},
+		"malformed":     {secret, body, "sha256=abc", now, ErrInvalidSignature},
+		"replayed late": {secret, body, header, now.Add(DefaultTolerance + time.Second), ErrExpiredSignature},
+	} {
+		require.ErrorIs(t, verify(tc.secret, tc.body, tc.header, tc.now, DefaultTolerance), tc.err, name)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	secret := []byte("s3cret")
+	var handled []*Event
+	failing := errors.New("database down")
+	handler := NewHandler(secret, func(ctx context.Context, evt *Event) error {
+		if evt.ID == "fail" {
+			return failing
+		}
+		handled = append(handled, evt)
+		return nil
+	})
+	deliver := func(body, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/x402", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set(HeaderSignature, signature)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	sign := func(body string) string { return Sign(secret, []byte(body), time.Now()) }
+
+	body := `{"id":"a","status":"confirmed","network":"eip155:8453","amount":"10000","timestamp":"2026-01-01T00:00:00Z"}`
+	require.Equal(t, http.StatusNoContent, deliver(body, sign(body)))
+	require.Len(t, handled, 1)
+	require.Equal(t, StatusConfirmed, handled[0].Status)
+	require.True(t, handled[0].Status.IsFinal())
+	require.Equal(t, "10000", handled[0].Amount)
+
+	require.Equal(t, http.StatusUnauthorized, deliver(body, ""))
+	require.Equal(t, http.StatusUnauthorized, deliver(body, Sign([]byte("other"), []byte(body), time.Now())))
+	require.Equal(t, http.StatusBadRequest, deliver("not json", sign("not json")))
+	require.Equal(t, http.StatusInternalServerError, deliver(`{"id":"fail"}`, sign(`{"id":"fail"}`)))
+	require.Len(t, handled, 1, "rejected deliveries are not handled")
+}